@@ -3,18 +3,27 @@
 package main
 
 import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+
+	libcrypto "github.com/shuLhan/share/lib/crypto"
 	"github.com/shuLhan/share/lib/email"
 	"github.com/shuLhan/share/lib/smtp"
 )
 
 const (
-	envSMTPUsername = `SMTP_USERNAME`
-	envSMTPPassword = `SMTP_PASSWORD`
+	envSMTPUsername  = `SMTP_USERNAME`
+	envSMTPPassword  = `SMTP_PASSWORD`
+	envPGPPassphrase = `PGP_PASSPHRASE`
 )
 
 func main() {
@@ -33,6 +42,15 @@ func main() {
 		fileBodyHTML string
 		serverURL    string
 
+		attachments []string
+		inlines     []string
+
+		smimeSignCert     string
+		smimeSignKey      string
+		smimeEncryptCerts []string
+		pgpSignKey        string
+		pgpEncryptKeys    []string
+
 		content []byte
 		mailb   []byte
 
@@ -48,6 +66,37 @@ func main() {
 	flag.StringVar(&subject, "subject", "", "Set the subject.")
 	flag.StringVar(&fileBodyText, "bodytext", "", "Set the text body from content of file.")
 	flag.StringVar(&fileBodyHTML, `bodyhtml`, ``, `Set the HTML body from content of file.`)
+	flag.Func(`attach`, `Attach the file to the message (optional, can be repeated).`,
+		func(path string) error {
+			attachments = append(attachments, path)
+			return nil
+		})
+	flag.Func(`inline`, `Add inline file as "<content-id>=<path>" (optional, can be repeated).`,
+		func(value string) error {
+			if !strings.Contains(value, `=`) {
+				return fmt.Errorf(`invalid inline value %q, expecting "<content-id>=<path>"`, value)
+			}
+			inlines = append(inlines, value)
+			return nil
+		})
+	flag.StringVar(&smimeSignCert, `smime-sign-cert`, ``,
+		`Sign the message using S/MIME with the PEM certificate file (optional).`)
+	flag.StringVar(&smimeSignKey, `smime-sign-key`, ``,
+		`Set the PEM private key file for -smime-sign-cert.`)
+	flag.Func(`smime-encrypt-cert`,
+		`Encrypt the message using S/MIME for the recipient PEM certificate file (optional, can be repeated).`,
+		func(path string) error {
+			smimeEncryptCerts = append(smimeEncryptCerts, path)
+			return nil
+		})
+	flag.StringVar(&pgpSignKey, `pgp-sign-key`, ``,
+		`Sign the message using OpenPGP with the armored secret key file (optional).`)
+	flag.Func(`pgp-encrypt-key`,
+		`Encrypt the message using OpenPGP for the recipient armored public key file (optional, can be repeated).`,
+		func(path string) error {
+			pgpEncryptKeys = append(pgpEncryptKeys, path)
+			return nil
+		})
 	flag.Usage = usage
 	flag.Parse()
 
@@ -109,6 +158,44 @@ func main() {
 		_ = msg.SetBodyHtml(content)
 	}
 
+	for _, path := range attachments {
+		err = msg.AttachFile(path)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	for _, value := range inlines {
+		var cid, path, _ = strings.Cut(value, `=`)
+		err = msg.InlineFile(cid, path)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if len(smimeSignCert) > 0 && len(pgpSignKey) > 0 {
+		log.Printf(`only one of -smime-sign-cert or -pgp-sign-key can be set`)
+		os.Exit(1)
+	}
+	if len(smimeEncryptCerts) > 0 && len(pgpEncryptKeys) > 0 {
+		log.Printf(`only one of -smime-encrypt-cert or -pgp-encrypt-key can be set`)
+		os.Exit(1)
+	}
+
+	err = protectSMIME(&msg, smimeSignCert, smimeSignKey, smimeEncryptCerts)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	err = protectPGP(&msg, pgpSignKey, pgpEncryptKeys)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+
 	mailb, err = msg.Pack()
 	if err != nil {
 		log.Println(err)
@@ -141,6 +228,151 @@ func main() {
 	fmt.Printf("SMTP response: %+v\n", smtpRes)
 }
 
+// protectSMIME sign and/or encrypt the message using S/MIME.
+// The message is signed first and then encrypted.
+func protectSMIME(msg *email.Message, signCert, signKey string, encryptCerts []string) (err error) {
+	var (
+		cert *x509.Certificate
+		pkey crypto.PrivateKey
+	)
+
+	if len(signCert) > 0 {
+		if len(signKey) == 0 {
+			return errors.New(`missing -smime-sign-key`)
+		}
+
+		cert, err = loadCertificate(signCert)
+		if err != nil {
+			return err
+		}
+
+		pkey, err = libcrypto.LoadPrivateKeyInteractive(nil, signKey)
+		if err != nil {
+			return err
+		}
+
+		var signer, ok = pkey.(crypto.Signer)
+		if !ok {
+			return fmt.Errorf(`%s: unsupported private key %T`, signKey, pkey)
+		}
+
+		err = msg.SMIMESign(cert, signer)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(encryptCerts) == 0 {
+		return nil
+	}
+
+	var recipients []*x509.Certificate
+
+	for _, path := range encryptCerts {
+		cert, err = loadCertificate(path)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, cert)
+	}
+
+	return msg.SMIMEEncrypt(recipients)
+}
+
+// protectPGP sign and/or encrypt the message using OpenPGP.
+// If both signing key and encryption keys are set, the message is signed
+// and encrypted at once.
+func protectPGP(msg *email.Message, signKey string, encryptKeys []string) (err error) {
+	var (
+		signer     *openpgp.Entity
+		recipients []*openpgp.Entity
+		keyring    openpgp.EntityList
+	)
+
+	if len(signKey) > 0 {
+		keyring, err = loadPGPKeyRing(signKey)
+		if err != nil {
+			return err
+		}
+		signer = keyring[0]
+
+		var passphrase = []byte(os.Getenv(envPGPPassphrase))
+
+		if signer.PrivateKey == nil {
+			return fmt.Errorf(`%s: missing private key`, signKey)
+		}
+		if signer.PrivateKey.Encrypted {
+			err = signer.PrivateKey.Decrypt(passphrase)
+			if err != nil {
+				return fmt.Errorf(`%s: %w`, signKey, err)
+			}
+		}
+	}
+
+	for _, path := range encryptKeys {
+		keyring, err = loadPGPKeyRing(path)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, keyring...)
+	}
+
+	if len(recipients) > 0 {
+		return msg.PGPEncrypt(recipients, signer)
+	}
+	if signer != nil {
+		return msg.PGPSign(signer)
+	}
+	return nil
+}
+
+// loadCertificate load the first certificate from PEM file.
+func loadCertificate(file string) (cert *x509.Certificate, err error) {
+	var raw []byte
+
+	raw, err = os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var block *pem.Block
+	for {
+		block, raw = pem.Decode(raw)
+		if block == nil {
+			return nil, fmt.Errorf(`%s: missing PEM certificate`, file)
+		}
+		if block.Type == `CERTIFICATE` {
+			break
+		}
+	}
+
+	cert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, file, err)
+	}
+	return cert, nil
+}
+
+// loadPGPKeyRing load the armored OpenPGP keys from file.
+func loadPGPKeyRing(file string) (keyring openpgp.EntityList, err error) {
+	var f *os.File
+
+	f, err = os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	keyring, err = openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, file, err)
+	}
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf(`%s: empty key ring`, file)
+	}
+	return keyring, nil
+}
+
 func usage() {
 	fmt.Println(`
 sendemail - command line interface to send an email using SMTP.
@@ -162,6 +394,19 @@ The 'from' and 'to' flags are set using mailbox format, for example
 
 Only one of 'bodytext' and 'bodyhtml' is required.
 
+The 'attach' and 'inline' flags can be set multiple times.
+The inline file can be referenced from HTML body using its content-id, for
+example "<img src="cid:logo@example.com">".
+
+The message can be signed and/or encrypted end-to-end using S/MIME or
+OpenPGP, but not both.
+The S/MIME certificates and private key are read from PEM files; if the
+private key is encrypted, the passphrase is prompted from terminal.
+The OpenPGP keys are read from armored key files; if the secret key is
+encrypted, the passphrase is read from environment variable
+PGP_PASSPHRASE.
+The message is signed first and then encrypted.
+
 == SERVER_URL
 
 The SERVER_URL argument define the SMTP server where the email will be submitted.
@@ -187,5 +432,36 @@ Send an email with message body set and read from HTML file,
 		-to="John <john@example.com>, Jane <jane@example.com>" \
 		-subject="Happy new years!" \
 		-bodytext=/path/to/message.html \
+		smtps://mail.myserver.com
+
+Send an email with HTML body, inline image, and attachment,
+
+	$ sendemail -from="my@email.tld" \
+		-to="John <john@example.com>" \
+		-subject="Monthly report" \
+		-bodyhtml=/path/to/message.html \
+		-inline=logo@example.com=/path/to/logo.png \
+		-attach=/path/to/report.pdf \
+		smtps://mail.myserver.com
+
+Send an email signed and encrypted using S/MIME,
+
+	$ sendemail -from="my@email.tld" \
+		-to="John <john@example.com>" \
+		-subject="Contract" \
+		-bodytext=/path/to/message.txt \
+		-smime-sign-cert=/path/to/my.crt \
+		-smime-sign-key=/path/to/my.key \
+		-smime-encrypt-cert=/path/to/john.crt \
+		smtps://mail.myserver.com
+
+Send an email signed and encrypted using OpenPGP,
+
+	$ PGP_PASSPHRASE=secret sendemail -from="my@email.tld" \
+		-to="John <john@example.com>" \
+		-subject="Contract" \
+		-bodytext=/path/to/message.txt \
+		-pgp-sign-key=/path/to/my-secret.asc \
+		-pgp-encrypt-key=/path/to/john.asc \
 		smtps://mail.myserver.com`)
 }
@@ -3,12 +3,15 @@ module github.com/shuLhan/share
 go 1.20
 
 require (
+	github.com/ProtonMail/go-crypto v1.1.6
 	golang.org/x/crypto v0.21.0
 	golang.org/x/net v0.22.0
 	golang.org/x/sys v0.18.0
 	golang.org/x/term v0.18.0
 )
 
+require github.com/cloudflare/circl v1.3.7 // indirect
+
 replace golang.org/x/crypto => git.sr.ht/~shulhan/go-x-crypto v0.18.1-0.20240119171712-4b35f92ea767
 
 //replace golang.org/x/term => ../../../golang.org/x/term
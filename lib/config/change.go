@@ -0,0 +1,98 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/shuLhan/share/lib/ini"
+)
+
+// Change contains the key whose values has been changed after reload.
+type Change struct {
+	// Key is the path to key in the format "section:sub:key".
+	Key string
+
+	// Old contains the values before reload.
+	// It is empty if the key is new.
+	Old []string
+
+	// New contains the values after reload.
+	// It is empty if the key has been removed.
+	New []string
+}
+
+// diff return the list of changed keys between old and new Ini, sorted by
+// key.
+func diff(oldIni, newIni *ini.Ini) (changes []Change) {
+	var (
+		oldKeys = asMap(oldIni)
+		newKeys = asMap(newIni)
+
+		key     string
+		oldVals []string
+		newVals []string
+		ok      bool
+	)
+	for key, oldVals = range oldKeys {
+		newVals, ok = newKeys[key]
+		if ok && isEqual(oldVals, newVals) {
+			continue
+		}
+		changes = append(changes, Change{
+			Key: key,
+			Old: oldVals,
+			New: newVals,
+		})
+	}
+	for key, newVals = range newKeys {
+		_, ok = oldKeys[key]
+		if ok {
+			continue
+		}
+		changes = append(changes, Change{
+			Key: key,
+			New: newVals,
+		})
+	}
+	sort.Slice(changes, func(x, y int) bool {
+		return changes[x].Key < changes[y].Key
+	})
+	return changes
+}
+
+// asMap return the Ini keys and values, where the key is lower cased.
+func asMap(in *ini.Ini) (out map[string][]string) {
+	out = make(map[string][]string)
+	if in == nil {
+		return out
+	}
+
+	var (
+		key  string
+		vals []string
+		x    int
+	)
+	for key, vals = range in.AsMap(``, ``) {
+		x = strings.LastIndexByte(key, ':')
+		key = key[:x+1] + strings.ToLower(key[x+1:])
+		out[key] = append(out[key], vals...)
+	}
+	return out
+}
+
+func isEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	var x int
+	for ; x < len(a); x++ {
+		if a[x] != b[x] {
+			return false
+		}
+	}
+	return true
+}
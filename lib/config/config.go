@@ -0,0 +1,384 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package config provide a loader for layered configuration in INI format,
+// with live reload.
+//
+// The configuration is loaded from multiple sources, or layers, in the
+// priority order they are added: files, directories of "*.conf" files,
+// environment variables, and command line flags.
+// The value in the later layer override the value in the previous layers,
+// using the same rules as [ini.Ini.Rebase].
+//
+// For example, to load the system, user, local configuration, and then the
+// command line flags,
+//
+//	var (
+//		cfg    Config
+//		loader = config.NewLoader(&cfg)
+//	)
+//	loader.AddFile(`/etc/app/app.conf`)
+//	loader.AddDir(`/etc/app/conf.d`)
+//	loader.AddFile(filepath.Join(userHomeDir, `.app.conf`))
+//	loader.AddEnv(`APP_`)
+//	loader.AddFlags(flag.CommandLine)
+//
+//	err = loader.Load()
+//
+// To reload the configuration when the files changes, call Watch,
+//
+//	loader.Subscribe(func(changes []config.Change, err error) {
+//		...
+//	})
+//	err = loader.Watch()
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/shuLhan/share/lib/ini"
+	"github.com/shuLhan/share/lib/memfs"
+)
+
+// Handler define the function that is called when the configuration has
+// been reloaded.
+// The changes contains the list of changed keys.
+// If the reload failed, the changes is nil and err is not nil, and the
+// target keep the previous values.
+type Handler func(changes []Change, err error)
+
+// Loader load and merge the configuration from multiple layers into
+// struct.
+type Loader struct {
+	// target is the pointer to struct where the configuration
+	// unmarshaled.
+	target reflect.Value
+
+	// in contains the merged configuration from the last load.
+	in *ini.Ini
+
+	done chan struct{}
+
+	layers   []*layer
+	handlers []Handler
+
+	fileWatchers []*memfs.Watcher
+	dirWatchers  []*memfs.DirWatcher
+
+	// Delay define the interval to check the changes on files, if the
+	// file system notification cannot be used.
+	// This field is optional, default to 5 seconds.
+	Delay time.Duration
+
+	wg sync.WaitGroup
+
+	// mtx protect the target and in from being read while reloading.
+	mtx sync.RWMutex
+
+	// mtxLoad serialize the Load and reload.
+	mtxLoad sync.Mutex
+
+	mtxHandler sync.Mutex
+
+	// Interpolate if true, the references to environment variable and
+	// other key in the values are expanded before unmarshaling.
+	// See [ini.Ini.Interpolate] for the syntax.
+	Interpolate bool
+}
+
+// NewLoader create new configuration loader that unmarshal the
+// configuration into target.
+// The target must be pointer to struct, the same as [ini.Unmarshal].
+func NewLoader(target any) (loader *Loader) {
+	loader = &Loader{
+		target: reflect.ValueOf(target),
+	}
+	return loader
+}
+
+// AddFile add the INI file as the next layer.
+// The file is not required to be exist when loaded.
+func (loader *Loader) AddFile(path string) {
+	loader.layers = append(loader.layers, &layer{
+		kind: layerKindFile,
+		path: path,
+	})
+}
+
+// AddDir add all files with ".conf" extension inside the directory as the
+// next layer.
+// The files are loaded sorted by its name.
+// The directory is not required to be exist when loaded.
+func (loader *Loader) AddDir(dir string) {
+	loader.layers = append(loader.layers, &layer{
+		kind: layerKindDir,
+		path: dir,
+	})
+}
+
+// AddEnv add the environment variables with prefix as the next layer.
+//
+// The name after prefix is split by double underscores into section and
+// key, or section, subsection, and key.
+// For example, with prefix "APP_", the "APP_SERVER__PORT" set the
+// "server::port" and "APP_REMOTE__origin__URL" set the "remote:origin:url".
+func (loader *Loader) AddEnv(prefix string) {
+	loader.layers = append(loader.layers, &layer{
+		kind:   layerKindEnv,
+		prefix: prefix,
+	})
+}
+
+// AddFlags add the command line flags as the next layer.
+//
+// Only the flags that has been set are loaded, so the flags should be
+// parsed before calling Load.
+// The flag name is split by dot into section and key, or section,
+// subsection, and key.
+// For example, the flag "server.port" set the "server::port" and
+// "remote.origin.url" set the "remote:origin:url".
+func (loader *Loader) AddFlags(flags *flag.FlagSet) {
+	loader.layers = append(loader.layers, &layer{
+		kind:  layerKindFlags,
+		flags: flags,
+	})
+}
+
+// Ini return the merged configuration from the last load.
+// The returned Ini should be treated as read only.
+func (loader *Loader) Ini() (in *ini.Ini) {
+	loader.mtx.RLock()
+	in = loader.in
+	loader.mtx.RUnlock()
+	return in
+}
+
+// Load all layers and unmarshal the merged configuration into target.
+//
+// The configuration is unmarshaled into new value first, and then copied
+// into target only if its success.
+// On fail, the target is not modified.
+func (loader *Loader) Load() (err error) {
+	var logp = `Load`
+
+	_, err = loader.load()
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return nil
+}
+
+// RLock lock the target for reading.
+// Any reload will wait until the RUnlock is called.
+func (loader *Loader) RLock() {
+	loader.mtx.RLock()
+}
+
+// RUnlock unlock the target for reading.
+func (loader *Loader) RUnlock() {
+	loader.mtx.RUnlock()
+}
+
+// Subscribe register the handler to be called when the configuration has
+// been reloaded by Watch.
+// The handler is called only if one or more keys changes or the reload
+// failed.
+func (loader *Loader) Subscribe(handler Handler) {
+	if handler == nil {
+		return
+	}
+	loader.mtxHandler.Lock()
+	loader.handlers = append(loader.handlers, handler)
+	loader.mtxHandler.Unlock()
+}
+
+// Watch the files and directories for changes and reload the
+// configuration.
+//
+// The file that does not exist when Watch is called is not watched, and
+// the file that has been deleted is no longer watched.
+// The environment variables and flags are loaded on each reload, but its
+// changes does not trigger reload.
+func (loader *Loader) Watch() (err error) {
+	var logp = `Watch`
+
+	if loader.done != nil {
+		return fmt.Errorf(`%s: already watching`, logp)
+	}
+
+	loader.done = make(chan struct{})
+
+	var l *layer
+	for _, l = range loader.layers {
+		switch l.kind {
+		case layerKindFile:
+			err = loader.watchFile(l.path)
+		case layerKindDir:
+			err = loader.watchDir(l.path)
+		}
+		if err != nil {
+			loader.Stop()
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+	}
+	return nil
+}
+
+// Stop watching the changes.
+func (loader *Loader) Stop() {
+	if loader.done == nil {
+		return
+	}
+
+	var (
+		fw *memfs.Watcher
+		dw *memfs.DirWatcher
+	)
+	for _, fw = range loader.fileWatchers {
+		fw.Stop()
+	}
+	for _, dw = range loader.dirWatchers {
+		dw.Stop()
+	}
+
+	close(loader.done)
+	loader.wg.Wait()
+
+	loader.fileWatchers = nil
+	loader.dirWatchers = nil
+	loader.done = nil
+}
+
+// load all layers, unmarshal the merged configuration into target, and
+// return the list of changed keys.
+func (loader *Loader) load() (changes []Change, err error) {
+	if loader.target.Kind() != reflect.Ptr || loader.target.IsNil() {
+		return nil, errors.New(`expecting non-nil pointer to struct`)
+	}
+
+	loader.mtxLoad.Lock()
+	defer loader.mtxLoad.Unlock()
+
+	var in *ini.Ini
+
+	in, err = loadLayers(loader.layers)
+	if err != nil {
+		return nil, err
+	}
+
+	if loader.Interpolate {
+		err = in.Interpolate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var newValue = reflect.New(loader.target.Elem().Type())
+
+	err = in.Unmarshal(newValue.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	loader.mtx.Lock()
+	changes = diff(loader.in, in)
+	loader.target.Elem().Set(newValue.Elem())
+	loader.in = in
+	loader.mtx.Unlock()
+
+	return changes, nil
+}
+
+// reload the configuration and notify the subscribers.
+func (loader *Loader) reload() {
+	var changes, err = loader.load()
+	if err == nil && len(changes) == 0 {
+		return
+	}
+
+	loader.mtxHandler.Lock()
+	var handlers = make([]Handler, len(loader.handlers))
+	copy(handlers, loader.handlers)
+	loader.mtxHandler.Unlock()
+
+	var handler Handler
+	for _, handler = range handlers {
+		handler(changes, err)
+	}
+}
+
+// watchFile start watching the file, if its exist.
+func (loader *Loader) watchFile(path string) (err error) {
+	var fw *memfs.Watcher
+
+	fw, err = memfs.NewWatcher(path, loader.Delay)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	loader.fileWatchers = append(loader.fileWatchers, fw)
+
+	loader.wg.Add(1)
+	go loader.consume(fw.C)
+
+	return nil
+}
+
+// watchDir start watching the directory, if its exist.
+func (loader *Loader) watchDir(dir string) (err error) {
+	var fi fs.FileInfo
+
+	fi, err = os.Stat(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf(`%q is not a directory`, dir)
+	}
+
+	var dw = &memfs.DirWatcher{
+		Options: memfs.Options{
+			Root: dir,
+		},
+		Delay: loader.Delay,
+	}
+
+	err = dw.Start()
+	if err != nil {
+		return err
+	}
+	loader.dirWatchers = append(loader.dirWatchers, dw)
+
+	loader.wg.Add(1)
+	go loader.consume(dw.C)
+
+	return nil
+}
+
+// consume the changes from watcher and reload the configuration.
+func (loader *Loader) consume(qchanges <-chan memfs.NodeState) {
+	defer loader.wg.Done()
+
+	var done = loader.done
+	for {
+		select {
+		case <-qchanges:
+			loader.reload()
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+type testConfig struct {
+	Name    string        `ini:"server::name"`
+	Plugins []string      `ini:"server::plugin"`
+	Port    int           `ini:"server::port:8080" constraint:"min=1,max=65535"`
+	Timeout time.Duration `ini:"server::timeout:10s"`
+	Debug   bool          `ini:"server::debug"`
+	Remote  string        `ini:"remote:origin:url"`
+}
+
+func writeFile(t *testing.T, path, content string) {
+	var err = os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoader_Load(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		confDir = filepath.Join(dir, `conf.d`)
+		flags   = flag.NewFlagSet(`test`, flag.ContinueOnError)
+
+		got    testConfig
+		loader = NewLoader(&got)
+		err    error
+	)
+
+	err = os.Mkdir(confDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(dir, `system.conf`),
+		"[server]\nname = system\nport = 80\nplugin = a\n")
+	writeFile(t, filepath.Join(confDir, `10-b.conf`),
+		"[server]\nname = dir-b\nplugin = b\n")
+	writeFile(t, filepath.Join(confDir, `01-a.conf`),
+		"[server]\nname = dir-a\n[remote \"origin\"]\nurl = file\n")
+	writeFile(t, filepath.Join(confDir, `ignored.txt`),
+		"[server]\nname = ignored\n")
+
+	t.Setenv(`TESTCONFIG_SERVER__TIMEOUT`, `1m`)
+	t.Setenv(`TESTCONFIG_remote__origin__URL`, `env`)
+
+	flags.Bool(`server.debug`, false, ``)
+	flags.Int(`server.port`, 0, ``)
+	err = flags.Parse([]string{`-server.debug`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loader.AddFile(filepath.Join(dir, `system.conf`))
+	loader.AddFile(filepath.Join(dir, `notexist.conf`))
+	loader.AddDir(confDir)
+	loader.AddEnv(`TESTCONFIG_`)
+	loader.AddFlags(flags)
+
+	err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exp = testConfig{
+		Name:    `dir-b`,
+		Plugins: []string{`a`, `b`},
+		Port:    80,
+		Timeout: time.Minute,
+		Debug:   true,
+		Remote:  `env`,
+	}
+	test.Assert(t, `Load`, exp, got)
+
+	_, err = os.Stat(filepath.Join(dir, `notexist.conf`))
+	test.Assert(t, `notexist.conf is not created`, true, os.IsNotExist(err))
+}
+
+func TestLoader_Load_error(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		file = filepath.Join(dir, `app.conf`)
+
+		got    testConfig
+		loader = NewLoader(&got)
+		err    error
+	)
+
+	writeFile(t, file, "[server]\nname = old\n")
+
+	loader.AddFile(file)
+
+	err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, file, "[server]\nname = new\nport = http\n")
+
+	err = loader.Load()
+	test.Assert(t, `error`,
+		`Load: ini: Unmarshal: server::port: invalid value "http"`, err.Error())
+	test.Assert(t, `target is not modified`, `old`, got.Name)
+
+	err = NewLoader(got).Load()
+	test.Assert(t, `non pointer`,
+		`Load: expecting non-nil pointer to struct`, err.Error())
+}
+
+func TestLoader_Watch(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		file    = filepath.Join(dir, `app.conf`)
+		confDir = filepath.Join(dir, `conf.d`)
+
+		got    testConfig
+		loader = NewLoader(&got)
+		err    error
+	)
+
+	err = os.Mkdir(confDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, file, "[server]\nname = old\nport = 80\n")
+
+	loader.Delay = 100 * time.Millisecond
+	loader.AddFile(file)
+	loader.AddDir(confDir)
+
+	err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var qchanges = make(chan []Change, 8)
+
+	loader.Subscribe(func(changes []Change, err error) {
+		if err != nil {
+			t.Log(err)
+			return
+		}
+		qchanges <- changes
+	})
+
+	err = loader.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(loader.Stop)
+
+	// Make sure the modification time is changes.
+	time.Sleep(100 * time.Millisecond)
+
+	writeFile(t, file, "[server]\nname = new\nport = 80\n")
+
+	var exp = []Change{{
+		Key: `server::name`,
+		Old: []string{`old`},
+		New: []string{`new`},
+	}}
+	test.Assert(t, `file changes`, exp, waitChanges(t, qchanges))
+
+	loader.RLock()
+	test.Assert(t, `Name`, `new`, got.Name)
+	loader.RUnlock()
+
+	writeFile(t, filepath.Join(confDir, `a.conf`), "[server]\nport = 443\n")
+
+	exp = []Change{{
+		Key: `server::port`,
+		Old: []string{`80`},
+		New: []string{`80`, `443`},
+	}}
+	test.Assert(t, `new file in directory`, exp, waitChanges(t, qchanges))
+
+	loader.RLock()
+	test.Assert(t, `Port`, 443, got.Port)
+	loader.RUnlock()
+}
+
+func waitChanges(t *testing.T, qchanges chan []Change) (changes []Change) {
+	var timer = time.NewTimer(10 * time.Second)
+	defer timer.Stop()
+
+	select {
+	case changes = <-qchanges:
+	case <-timer.C:
+		t.Fatal(`timeout waiting for changes`)
+	}
+	return changes
+}
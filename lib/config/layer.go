@@ -0,0 +1,217 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/shuLhan/share/lib/ini"
+)
+
+// List of layer kind.
+const (
+	layerKindFile = iota
+	layerKindDir
+	layerKindEnv
+	layerKindFlags
+)
+
+// dirFileExt define the extension of files to be loaded from directory.
+const dirFileExt = `.conf`
+
+// layer define one source of configuration.
+type layer struct {
+	flags *flag.FlagSet
+
+	// path to the file or directory.
+	path string
+
+	// prefix of environment variables.
+	prefix string
+
+	kind int
+}
+
+// load the layer into Ini.
+// The file or directory that does not exist is loaded as empty Ini.
+func (l *layer) load() (in *ini.Ini, err error) {
+	switch l.kind {
+	case layerKindFile:
+		return loadFile(l.path)
+	case layerKindDir:
+		return loadDir(l.path)
+	case layerKindEnv:
+		return loadEnv(l.prefix, os.Environ()), nil
+	case layerKindFlags:
+		return loadFlags(l.flags), nil
+	}
+	return &ini.Ini{}, nil
+}
+
+// loadFile parse the ini file.
+// Unlike [ini.Open], the file is not created if its not exist.
+func loadFile(path string) (in *ini.Ini, err error) {
+	_, err = os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &ini.Ini{}, nil
+		}
+		return nil, err
+	}
+	return ini.Open(path)
+}
+
+// loadDir parse all files with ".conf" extension inside the directory,
+// sorted by its name.
+// The file that loaded later override the value from previous one.
+func loadDir(dir string) (in *ini.Ini, err error) {
+	var des []os.DirEntry
+
+	des, err = os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &ini.Ini{}, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	var de os.DirEntry
+	for _, de = range des {
+		if de.IsDir() || filepath.Ext(de.Name()) != dirFileExt {
+			continue
+		}
+		names = append(names, de.Name())
+	}
+	sort.Strings(names)
+
+	in = &ini.Ini{}
+
+	var (
+		name  string
+		other *ini.Ini
+	)
+	for _, name = range names {
+		other, err = loadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		in.Rebase(other)
+	}
+	return in, nil
+}
+
+// loadEnv convert the environment variables with prefix into Ini.
+// The name after prefix is split by double underscores into section and
+// key, or section, subsection, and key.
+// The section and key are case insensitive, while the subsection is case
+// sensitive.
+// For example, with prefix "APP_", the "APP_SERVER__PORT" is converted
+// into "server::port" and "APP_REMOTE__origin__URL" into
+// "remote:origin:url".
+func loadEnv(prefix string, environ []string) (in *ini.Ini) {
+	in = &ini.Ini{}
+
+	var (
+		env   string
+		name  string
+		value string
+		names []string
+		ok    bool
+	)
+	for _, env = range environ {
+		name, value, ok = strings.Cut(env, `=`)
+		if !ok {
+			continue
+		}
+		name, ok = strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		names = strings.Split(name, `__`)
+		switch len(names) {
+		case 2:
+			in.Add(names[0], ``, strings.ToLower(names[1]), value)
+		case 3:
+			in.Add(names[0], names[1], strings.ToLower(names[2]), value)
+		}
+	}
+	return in
+}
+
+// loadFlags convert the flags that has been set in the command line into
+// Ini.
+// The flag name is split by dot into section and key, or section,
+// subsection, and key.
+// For example, "server.port" is converted into "server::port" and
+// "remote.origin.url" into "remote:origin:url".
+// The subsection may contains dot, for example "branch.release.1.remote".
+func loadFlags(flags *flag.FlagSet) (in *ini.Ini) {
+	in = &ini.Ini{}
+	if flags == nil {
+		return in
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		var (
+			start = strings.IndexByte(f.Name, '.')
+			end   = strings.LastIndexByte(f.Name, '.')
+		)
+		if start <= 0 || end == len(f.Name)-1 {
+			return
+		}
+		var (
+			secName = f.Name[:start]
+			key     = strings.ToLower(f.Name[end+1:])
+			subName string
+		)
+		if end > start {
+			subName = f.Name[start+1 : end]
+		}
+		in.Add(secName, subName, key, f.Value.String())
+	})
+	return in
+}
+
+// loadLayers load and merge all layers into one Ini.
+func loadLayers(layers []*layer) (in *ini.Ini, err error) {
+	in = &ini.Ini{}
+
+	var (
+		l     *layer
+		other *ini.Ini
+	)
+	for _, l = range layers {
+		other, err = l.load()
+		if err != nil {
+			return nil, fmt.Errorf(`%s: %w`, l, err)
+		}
+		in.Rebase(other)
+	}
+	in.Prune()
+	return in, nil
+}
+
+// String return the representation of layer for error message.
+func (l *layer) String() string {
+	switch l.kind {
+	case layerKindFile:
+		return `file ` + l.path
+	case layerKindDir:
+		return `directory ` + l.path
+	case layerKindEnv:
+		return `environment ` + l.prefix
+	case layerKindFlags:
+		return `flags`
+	}
+	return ``
+}
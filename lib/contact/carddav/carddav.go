@@ -0,0 +1,88 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package carddav implement the client and server for CardDAV protocol,
+// RFC 6352, with collection synchronization from RFC 6578.
+//
+// The [Client] discover the address books of user, synchronize the
+// contacts using sync-token or ctag, and push the changes back to server.
+//
+// The [Handler] is a minimal CardDAV server that store each contact as
+// vCard file on disk, in the following layout,
+//
+//	{dir}/{user}/{address-book}/{name}.vcf
+//
+// The Handler implement [http.Handler] so it can be mounted in
+// [libhttp.Server] using RegisterHandler,
+//
+//	srv.RegisterHandler(`/carddav`, handler)
+//	srv.RegisterHandler(carddav.WellKnownPath, handler)
+//
+// [libhttp.Server]: https://pkg.go.dev/github.com/shuLhan/share/lib/http#Server
+package carddav
+
+import (
+	"errors"
+
+	"github.com/shuLhan/share/lib/contact/vcard"
+)
+
+// WellKnownPath is the path for discovering the CardDAV service, RFC
+// 6764.
+const WellKnownPath = `/.well-known/carddav`
+
+// ContentTypeVCard define the content type of vCard object.
+const ContentTypeVCard = `text/vcard; charset=utf-8`
+
+// ErrInvalidSyncToken define an error returned by [Client.Sync] when the
+// server reject the sync-token.
+// The client should re-synchronize with empty sync-token.
+var ErrInvalidSyncToken = errors.New(`invalid sync-token`)
+
+// ErrPrecondition define an error returned by [Client.Put] and
+// [Client.Delete] when the object has been modified or created by other
+// client.
+var ErrPrecondition = errors.New(`precondition failed`)
+
+// AddressBook define the address book collection in CardDAV server.
+type AddressBook struct {
+	// Href is the path of address book in server, end with slash.
+	Href string
+
+	// Name is the display name of address book.
+	Name string
+
+	Description string
+
+	// CTag is the collection entity tag, that changes every time the
+	// object in address book is modified.
+	CTag string
+
+	// SyncToken is the current token of address book.
+	SyncToken string
+}
+
+// Object define the vCard object in address book.
+type Object struct {
+	Card *vcard.VCard
+
+	// Href is the path of object in server.
+	Href string
+
+	// ETag is the entity tag of object.
+	ETag string
+}
+
+// SyncResult contains the changes in address book since the last
+// synchronization.
+type SyncResult struct {
+	// Token is the new sync-token to be used in the next Sync.
+	Token string
+
+	// Updated contains the new or modified objects.
+	Updated []*Object
+
+	// Deleted contains the href of deleted objects.
+	Deleted []string
+}
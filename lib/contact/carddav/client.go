@@ -0,0 +1,527 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package carddav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/shuLhan/share/lib/contact/vcard"
+	libhttp "github.com/shuLhan/share/lib/http"
+)
+
+// maxRedirect is the maximum number of redirect followed by client.
+const maxRedirect = 5
+
+// List of request body.
+const (
+	bodyPropfindPrincipal = xmlHeader + `<propfind xmlns="DAV:"><prop>` +
+		`<current-user-principal/></prop></propfind>`
+
+	bodyPropfindHomeSet = xmlHeader + `<propfind xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">` +
+		`<prop><C:addressbook-home-set/></prop></propfind>`
+
+	bodyPropfindBook = xmlHeader + `<propfind xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav" xmlns:CS="http://calendarserver.org/ns/">` +
+		`<prop><resourcetype/><displayname/><C:addressbook-description/><CS:getctag/><sync-token/></prop></propfind>`
+
+	bodyPropfindETag = xmlHeader + `<propfind xmlns="DAV:"><prop><getetag/></prop></propfind>`
+)
+
+// ClientOptions define the options for CardDAV client.
+type ClientOptions struct {
+	// ServerURL is the URL of CardDAV server, for example
+	// "https://example.com" or "https://example.com/carddav/".
+	// If the path is empty, the client discover the CardDAV service
+	// using the well-known URI.
+	ServerURL string
+
+	// Username and Password for HTTP Basic authentication.
+	Username string
+	Password string
+
+	// HTTP define the options for HTTP client.
+	// The ServerUrl field is ignored.
+	HTTP libhttp.ClientOptions
+}
+
+// Client for CardDAV server.
+type Client struct {
+	httpc   *libhttp.Client
+	baseURL *url.URL
+	opts    ClientOptions
+}
+
+// NewClient create new CardDAV client.
+func NewClient(opts ClientOptions) (client *Client, err error) {
+	var logp = `NewClient`
+
+	client = &Client{
+		opts: opts,
+	}
+
+	client.baseURL, err = url.Parse(opts.ServerURL)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if len(client.baseURL.Scheme) == 0 || len(client.baseURL.Host) == 0 {
+		return nil, fmt.Errorf(`%s: invalid ServerURL %q`, logp, opts.ServerURL)
+	}
+
+	client.httpc = libhttp.NewClient(&client.opts.HTTP)
+
+	// The redirect is handled manually to keep the request method and
+	// body.
+	client.httpc.Client.CheckRedirect = func(_ *http.Request, _ []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return client, nil
+}
+
+// Discover the address books of current user.
+//
+// The client find the current user principal from ServerURL, or from the
+// well-known URI if the ServerURL does not have path, then the
+// address book home set of principal, and list all the address books in
+// the home set.
+func (client *Client) Discover() (books []*AddressBook, err error) {
+	var (
+		logp  = `Discover`
+		start = client.baseURL.Path
+		ms    *multistatus
+	)
+	if len(start) == 0 || start == `/` {
+		start = WellKnownPath
+	}
+
+	ms, err = client.propfind(start, `0`, bodyPropfindPrincipal)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	var principal = findHref(ms, func(pv *propValue) *href { return pv.CurrentUserPrinc })
+	if len(principal) == 0 {
+		return nil, fmt.Errorf(`%s: current-user-principal not found`, logp)
+	}
+
+	ms, err = client.propfind(principal, `0`, bodyPropfindHomeSet)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	var home = findHref(ms, func(pv *propValue) *href { return pv.AddressBookHomeSet })
+	if len(home) == 0 {
+		return nil, fmt.Errorf(`%s: addressbook-home-set not found`, logp)
+	}
+
+	ms, err = client.propfind(home, `1`, bodyPropfindBook)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	for x := range ms.Responses {
+		var book = newAddressBook(&ms.Responses[x])
+		if book != nil {
+			books = append(books, book)
+		}
+	}
+	return books, nil
+}
+
+// AddressBook get the properties of address book in href, including its
+// current CTag and SyncToken.
+func (client *Client) AddressBook(hrefPath string) (book *AddressBook, err error) {
+	var (
+		logp = `AddressBook`
+		ms   *multistatus
+	)
+	ms, err = client.propfind(hrefPath, `0`, bodyPropfindBook)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	for x := range ms.Responses {
+		book = newAddressBook(&ms.Responses[x])
+		if book != nil {
+			return book, nil
+		}
+	}
+	return nil, fmt.Errorf(`%s: %s is not an address book`, logp, hrefPath)
+}
+
+// List the href and ETag of all objects in address book, without their
+// content.
+//
+// This method can be used to synchronize the address book using CTag,
+// for server that does not support sync-token: if the CTag changes, the
+// client compare the ETag of objects with the local copies and fetch the
+// changed objects using [Client.Multiget].
+func (client *Client) List(book *AddressBook) (objects []*Object, err error) {
+	var (
+		logp = `List`
+		ms   *multistatus
+	)
+	ms, err = client.propfind(book.Href, `1`, bodyPropfindETag)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	for x := range ms.Responses {
+		var (
+			resp = &ms.Responses[x]
+			pv   = resp.okProp()
+		)
+		if pv == nil || len(pv.GetETag) == 0 {
+			// The address book itself.
+			continue
+		}
+		objects = append(objects, &Object{
+			Href: resp.Href,
+			ETag: pv.GetETag,
+		})
+	}
+	return objects, nil
+}
+
+// Multiget fetch the objects by their href in address book.
+// The href that does not exist in server is ignored.
+func (client *Client) Multiget(book *AddressBook, hrefs []string) (objects []*Object, err error) {
+	var logp = `Multiget`
+
+	if len(hrefs) == 0 {
+		return nil, nil
+	}
+
+	var body bytes.Buffer
+	body.WriteString(xmlHeader)
+	body.WriteString(`<C:addressbook-multiget xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">`)
+	body.WriteString(`<prop><getetag/><C:address-data/></prop>`)
+	for _, hrefPath := range hrefs {
+		body.WriteString(`<href>`)
+		_ = xml.EscapeText(&body, []byte(hrefPath))
+		body.WriteString(`</href>`)
+	}
+	body.WriteString(`</C:addressbook-multiget>`)
+
+	var ms *multistatus
+	ms, err = client.report(book.Href, body.String())
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	objects, err = parseObjects(ms)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return objects, nil
+}
+
+// Sync the address book since the last token using sync-collection
+// report, RFC 6578.
+// If token is empty, all objects are returned in SyncResult Updated.
+//
+// If the server reject the token, it will return [ErrInvalidSyncToken].
+func (client *Client) Sync(book *AddressBook, token string) (result *SyncResult, err error) {
+	var logp = `Sync`
+
+	var body bytes.Buffer
+	body.WriteString(xmlHeader)
+	body.WriteString(`<sync-collection xmlns="DAV:"><sync-token>`)
+	_ = xml.EscapeText(&body, []byte(token))
+	body.WriteString(`</sync-token><sync-level>1</sync-level>`)
+	body.WriteString(`<prop><getetag/></prop></sync-collection>`)
+
+	var ms *multistatus
+	ms, err = client.report(book.Href, body.String())
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	result = &SyncResult{
+		Token: ms.SyncToken,
+	}
+
+	var updated []string
+	for x := range ms.Responses {
+		var resp = &ms.Responses[x]
+		if len(resp.Status) > 0 && !isStatusOK(resp.Status) {
+			result.Deleted = append(result.Deleted, resp.Href)
+			continue
+		}
+		updated = append(updated, resp.Href)
+	}
+
+	result.Updated, err = client.Multiget(book, updated)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return result, nil
+}
+
+// Get the object by its href.
+func (client *Client) Get(hrefPath string) (obj *Object, err error) {
+	var (
+		logp = `Get`
+		res  *http.Response
+		body []byte
+	)
+	res, body, err = client.doHeader(http.MethodGet, hrefPath, http.Header{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(`%s: %s`, logp, res.Status)
+	}
+
+	obj = &Object{
+		Href: hrefPath,
+		ETag: res.Header.Get(`ETag`),
+	}
+	obj.Card, err = parseCard(body)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return obj, nil
+}
+
+// Put create or update the object in address book.
+//
+// If the object Href is empty, it will be set to the card UID with
+// ".vcf" extension inside the address book.
+// If the object ETag is empty, the object must not exist in server,
+// otherwise the object in server must have the same ETag.
+// On success, the object ETag is updated with the new value from server,
+// which may be empty if server does not return it.
+// It will return [ErrPrecondition] if the object has been modified by
+// other client.
+func (client *Client) Put(book *AddressBook, obj *Object) (err error) {
+	var logp = `Put`
+
+	if obj.Card == nil {
+		return fmt.Errorf(`%s: empty Card`, logp)
+	}
+	if len(obj.Href) == 0 {
+		if len(obj.Card.UID) == 0 {
+			return fmt.Errorf(`%s: empty Href and Card UID`, logp)
+		}
+		obj.Href = path.Join(book.Href, url.PathEscape(obj.Card.UID)+`.vcf`)
+	}
+
+	var raw []byte
+	raw, err = vcard.Marshal(obj.Card)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var hdr = http.Header{}
+	hdr.Set(libhttp.HeaderContentType, ContentTypeVCard)
+	if len(obj.ETag) == 0 {
+		hdr.Set(`If-None-Match`, `*`)
+	} else {
+		hdr.Set(`If-Match`, obj.ETag)
+	}
+
+	var res *http.Response
+	res, _, err = client.doHeader(http.MethodPut, obj.Href, hdr, raw)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	case http.StatusPreconditionFailed:
+		return fmt.Errorf(`%s: %w`, logp, ErrPrecondition)
+	default:
+		return fmt.Errorf(`%s: %s`, logp, res.Status)
+	}
+	obj.ETag = res.Header.Get(`ETag`)
+	return nil
+}
+
+// Delete the object from server.
+// If the object ETag is not empty, the object in server must have the
+// same ETag, or it will return [ErrPrecondition].
+func (client *Client) Delete(obj *Object) (err error) {
+	var (
+		logp = `Delete`
+		hdr  = http.Header{}
+		res  *http.Response
+	)
+	if len(obj.ETag) > 0 {
+		hdr.Set(`If-Match`, obj.ETag)
+	}
+	res, _, err = client.doHeader(http.MethodDelete, obj.Href, hdr, nil)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+	case http.StatusPreconditionFailed:
+		return fmt.Errorf(`%s: %w`, logp, ErrPrecondition)
+	default:
+		return fmt.Errorf(`%s: %s`, logp, res.Status)
+	}
+	return nil
+}
+
+func (client *Client) propfind(hrefPath, depth, body string) (ms *multistatus, err error) {
+	var hdr = http.Header{}
+	hdr.Set(`Depth`, depth)
+	return client.multistatus(`PROPFIND`, hrefPath, hdr, body)
+}
+
+func (client *Client) report(hrefPath, body string) (ms *multistatus, err error) {
+	var hdr = http.Header{}
+	hdr.Set(`Depth`, `1`)
+	return client.multistatus(`REPORT`, hrefPath, hdr, body)
+}
+
+// multistatus send the XML request and parse the multistatus response.
+func (client *Client) multistatus(method, hrefPath string, hdr http.Header, body string) (ms *multistatus, err error) {
+	hdr.Set(libhttp.HeaderContentType, contentTypeXML)
+
+	var (
+		res     *http.Response
+		resBody []byte
+	)
+	res, resBody, err = client.doHeader(method, hrefPath, hdr, []byte(body))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusForbidden && bytes.Contains(resBody, []byte(nameValidSyncToken.Local)) {
+		return nil, ErrInvalidSyncToken
+	}
+	if res.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf(`%s %s: %s`, method, hrefPath, res.Status)
+	}
+
+	ms = &multistatus{}
+	err = xml.Unmarshal(resBody, ms)
+	if err != nil {
+		return nil, fmt.Errorf(`%s %s: %w`, method, hrefPath, err)
+	}
+	return ms, nil
+}
+
+// doHeader send the request to the href, relative to the ServerURL, and
+// follow the redirect.
+func (client *Client) doHeader(method, hrefPath string, hdr http.Header, body []byte) (res *http.Response, resBody []byte, err error) {
+	var target *url.URL
+
+	target, err = client.baseURL.Parse(hrefPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for x := 0; x <= maxRedirect; x++ {
+		var req *http.Request
+
+		req, err = http.NewRequest(method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, err
+		}
+		for key, values := range client.opts.HTTP.Headers {
+			req.Header[key] = values
+		}
+		for key, values := range hdr {
+			req.Header[key] = values
+		}
+		if len(client.opts.Username) > 0 {
+			req.SetBasicAuth(client.opts.Username, client.opts.Password)
+		}
+
+		res, resBody, err = client.httpc.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch res.StatusCode {
+		case http.StatusMovedPermanently, http.StatusFound,
+			http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return res, resBody, nil
+		}
+
+		var loc = res.Header.Get(libhttp.HeaderLocation)
+		if len(loc) == 0 {
+			return res, resBody, nil
+		}
+		target, err = target.Parse(loc)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return nil, nil, errors.New(`too many redirects`)
+}
+
+// findHref return the href value from the first response that has it.
+func findHref(ms *multistatus, get func(pv *propValue) *href) string {
+	for x := range ms.Responses {
+		var pv = ms.Responses[x].okProp()
+		if pv == nil {
+			continue
+		}
+		var h = get(pv)
+		if h != nil && len(h.Href) > 0 {
+			return strings.TrimSpace(h.Href)
+		}
+	}
+	return ``
+}
+
+// newAddressBook create AddressBook from response if its resource type
+// is address book.
+func newAddressBook(resp *response) *AddressBook {
+	var pv = resp.okProp()
+	if pv == nil || pv.ResourceType == nil || pv.ResourceType.AddressBook == nil {
+		return nil
+	}
+	var book = &AddressBook{
+		Href:        resp.Href,
+		Name:        pv.DisplayName,
+		Description: pv.Description,
+		CTag:        pv.GetCTag,
+		SyncToken:   pv.SyncToken,
+	}
+	if !strings.HasSuffix(book.Href, `/`) {
+		book.Href += `/`
+	}
+	return book
+}
+
+// parseObjects parse the objects with address-data in multistatus.
+func parseObjects(ms *multistatus) (objects []*Object, err error) {
+	for x := range ms.Responses {
+		var (
+			resp = &ms.Responses[x]
+			pv   = resp.okProp()
+		)
+		if pv == nil || len(pv.AddressData) == 0 {
+			continue
+		}
+		var obj = &Object{
+			Href: resp.Href,
+			ETag: pv.GetETag,
+		}
+		obj.Card, err = parseCard([]byte(pv.AddressData))
+		if err != nil {
+			return nil, fmt.Errorf(`%s: %w`, resp.Href, err)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// parseCard parse the raw content as single vCard.
+func parseCard(raw []byte) (card *vcard.VCard, err error) {
+	var cards []*vcard.VCard
+	cards, err = vcard.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) != 1 {
+		return nil, fmt.Errorf(`expecting one vCard, got %d`, len(cards))
+	}
+	return cards[0], nil
+}
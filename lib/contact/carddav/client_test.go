@@ -0,0 +1,278 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package carddav
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/contact"
+	"github.com/shuLhan/share/lib/contact/vcard"
+	libhttp "github.com/shuLhan/share/lib/http"
+	"github.com/shuLhan/share/lib/test"
+)
+
+const testAddress = `127.0.0.1:14839`
+
+func TestMain(m *testing.M) {
+	var dir, err = os.MkdirTemp(``, `carddav`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var handler *Handler
+	handler, err = NewHandler(`/carddav`, dir, func(user, pass string) bool {
+		return user == `alice` && pass == `secret`
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var srv *libhttp.Server
+	srv, err = libhttp.NewServer(&libhttp.ServerOptions{
+		Address: testAddress,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = srv.RegisterHandler(`/carddav`, handler)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = srv.RegisterHandler(WellKnownPath, handler)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	go func() {
+		var errStart = srv.Start()
+		if errStart != nil {
+			log.Println(errStart)
+		}
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	var status = m.Run()
+
+	_ = srv.Stop(0)
+	_ = os.RemoveAll(dir)
+	os.Exit(status)
+}
+
+func newTestClient(t *testing.T, pass string) (client *Client) {
+	var err error
+	client, err = NewClient(ClientOptions{
+		ServerURL: `http://` + testAddress,
+		Username:  `alice`,
+		Password:  pass,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestClient(t *testing.T) {
+	var (
+		client = newTestClient(t, `secret`)
+
+		books []*AddressBook
+		err   error
+	)
+
+	books, err = client.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Discover`, []*AddressBook{{
+		Href:      `/carddav/alice/default/`,
+		Name:      `default`,
+		CTag:      `data:,0`,
+		SyncToken: `data:,0`,
+	}}, books)
+
+	var (
+		book = books[0]
+		res  *SyncResult
+	)
+
+	res, err = client.Sync(book, ``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Sync: empty`, &SyncResult{Token: `data:,0`}, res)
+
+	var objA = &Object{
+		Card: vcard.FromRecord(&contact.Record{
+			Name:   contact.Name{Given: `John`, Family: `Doe`},
+			Emails: []contact.Email{{Address: `john@example.com`}},
+		}),
+	}
+	objA.Card.UID = `a`
+
+	err = client.Put(book, objA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Put: Href`, `/carddav/alice/default/a.vcf`, objA.Href)
+
+	// Creating the same object again should fail.
+	err = client.Put(book, &Object{Card: objA.Card})
+	test.Assert(t, `Put: exist`, true, errors.Is(err, ErrPrecondition))
+
+	res, err = client.Sync(book, `data:,0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Sync: Token`, `data:,1`, res.Token)
+	test.Assert(t, `Sync: Updated`, []*Object{objA}, res.Updated)
+
+	var objB = &Object{
+		Card: &vcard.VCard{Version: vcard.Version4, UID: `b`, Fn: `Jane`},
+	}
+	err = client.Put(book, objB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Delete(objA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = client.Sync(book, `data:,1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Sync: Token`, `data:,3`, res.Token)
+	test.Assert(t, `Sync: Updated`, []*Object{objB}, res.Updated)
+	test.Assert(t, `Sync: Deleted`, []string{objA.Href}, res.Deleted)
+
+	_, err = client.Sync(book, `data:,99`)
+	test.Assert(t, `Sync: invalid token`, true, errors.Is(err, ErrInvalidSyncToken))
+
+	// Update the object with old ETag should fail.
+	var oldETag = objB.ETag
+	objB.Card.Note = []string{`updated`}
+	err = client.Put(book, objB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Put(book, &Object{Href: objB.Href, ETag: oldETag, Card: objB.Card})
+	test.Assert(t, `Put: old ETag`, true, errors.Is(err, ErrPrecondition))
+
+	book, err = client.AddressBook(book.Href)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `AddressBook: CTag`, `data:,4`, book.CTag)
+
+	var objects []*Object
+	objects, err = client.List(book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `List`, []*Object{{Href: objB.Href, ETag: objB.ETag}}, objects)
+
+	var got *Object
+	got, err = client.Get(objB.Href)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Get`, objB, got)
+}
+
+func TestClient_unauthorized(t *testing.T) {
+	var (
+		client = newTestClient(t, `wrong`)
+		_, err = client.Discover()
+	)
+	test.Assert(t, `Discover`, `Discover: PROPFIND /.well-known/carddav: 401 Unauthorized`, err.Error())
+}
+
+func TestHandler_authorize(t *testing.T) {
+	var (
+		dir          = t.TempDir()
+		handler, err = NewHandler(`/carddav`, dir, func(user, pass string) bool {
+			return pass == `secret`
+		})
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Allow anyone to access the address book "shared" of alice.
+	handler.Authorize = func(user, owner, book string) bool {
+		return owner == `alice` && book == `shared`
+	}
+
+	type testCase struct {
+		desc    string
+		user    string
+		method  string
+		path    string
+		expCode int
+	}
+
+	var cases = []testCase{{
+		desc:    `With own home`,
+		user:    `alice`,
+		method:  `MKCOL`,
+		path:    `/carddav/alice/shared/`,
+		expCode: http.StatusCreated,
+	}, {
+		desc:    `With other user home`,
+		user:    `bob`,
+		method:  `PROPFIND`,
+		path:    `/carddav/alice/`,
+		expCode: http.StatusForbidden,
+	}, {
+		desc:    `With other user address book`,
+		user:    `bob`,
+		method:  http.MethodDelete,
+		path:    `/carddav/alice/default/`,
+		expCode: http.StatusForbidden,
+	}, {
+		desc:    `With unknown user home`,
+		user:    `bob`,
+		method:  `PROPFIND`,
+		path:    `/carddav/mallory/`,
+		expCode: http.StatusForbidden,
+	}, {
+		desc:    `With authorized address book`,
+		user:    `bob`,
+		method:  http.MethodGet,
+		path:    `/carddav/alice/shared/`,
+		expCode: http.StatusOK,
+	}}
+
+	var (
+		c   testCase
+		req *http.Request
+		rec *httptest.ResponseRecorder
+	)
+	for _, c = range cases {
+		req = httptest.NewRequest(c.method, c.path, nil)
+		req.SetBasicAuth(c.user, `secret`)
+		rec = httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		test.Assert(t, c.desc, c.expCode, rec.Code)
+	}
+
+	// The home is only created for the authenticated user.
+	var names []string
+
+	names, err = listDir(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `user homes`, []string{`alice`}, names)
+}
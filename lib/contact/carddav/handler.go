@@ -0,0 +1,895 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package carddav
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shuLhan/share/lib/contact/vcard"
+)
+
+const (
+	// defBookName is the name of address book created for new user.
+	defBookName = `default`
+
+	// fileChanges is the name of file inside the address book directory
+	// that record the changes of objects.
+	fileChanges = `.changes`
+
+	// maxObjectSize is the maximum size of vCard object in PUT request.
+	maxObjectSize = 4 << 20
+
+	// syncTokenPrefix is the prefix of sync-token URI.
+	syncTokenPrefix = `data:,`
+
+	contentTypeXML = `application/xml; charset=utf-8`
+)
+
+// AuthFunc define the function to authenticate the user name and
+// password.
+type AuthFunc func(username, password string) bool
+
+// AuthorizeFunc define the function to allow the authenticated user to
+// access the home of other user, the owner.
+// The book is the address book name, or empty if the request is for the
+// owner home itself.
+type AuthorizeFunc func(user, owner, book string) bool
+
+// Handler define the CardDAV server that store the vCard in directory.
+//
+// Each request must be authenticated using HTTP Basic authentication.
+// The home of each user is "{prefix}/{user}/", which also act as the
+// principal URL.
+// The address book "default" is created the first time the user access
+// their home.
+// The user can only access their own home, unless it is allowed by
+// Authorize.
+//
+// The synchronization using sync-token only report the changes that made
+// through the Handler.
+type Handler struct {
+	auth AuthFunc
+
+	// Authorize define the optional function to allow user access the
+	// home or address book of other user, for example to share the
+	// same address book within the team.
+	// If its nil, the request to the home of other user is forbidden.
+	Authorize AuthorizeFunc
+
+	prefix string
+	dir    string
+	mtx    sync.Mutex
+}
+
+// target define the resource in request path.
+type target struct {
+	user string
+	book string
+	name string
+}
+
+// NewHandler create new CardDAV handler that mounted at prefix and store
+// the vCard under directory dir.
+// The auth function is required.
+func NewHandler(prefix, dir string, auth AuthFunc) (handler *Handler, err error) {
+	var logp = `NewHandler`
+
+	if auth == nil {
+		return nil, fmt.Errorf(`%s: nil AuthFunc`, logp)
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	handler = &Handler{
+		auth:   auth,
+		prefix: `/` + strings.Trim(prefix, `/`),
+		dir:    dir,
+	}
+	if handler.prefix == `/` {
+		handler.prefix = ``
+	}
+	return handler, nil
+}
+
+// ServeHTTP handle the CardDAV request.
+func (h *Handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == WellKnownPath {
+		http.Redirect(res, req, h.prefix+`/`, http.StatusMovedPermanently)
+		return
+	}
+
+	var user, pass, ok = req.BasicAuth()
+	if !ok || !h.auth(user, pass) {
+		res.Header().Set(`WWW-Authenticate`, `Basic realm="CardDAV"`)
+		res.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var tgt *target
+	tgt, ok = h.parsePath(req.URL.Path)
+	if !ok {
+		res.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(tgt.user) == 0:
+		// The root, that return the current user principal.
+	case tgt.user == user:
+		var err = h.initHome(user)
+		if err != nil {
+			h.internalError(res, err)
+			return
+		}
+	case h.Authorize != nil && h.Authorize(user, tgt.user, tgt.book):
+	default:
+		res.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodOptions:
+		res.Header().Set(`DAV`, `1, 3, addressbook`)
+		res.Header().Set(`Allow`, `OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT, MKCOL`)
+		res.WriteHeader(http.StatusOK)
+	case `PROPFIND`:
+		h.propfind(res, req, user, tgt)
+	case `REPORT`:
+		h.report(res, req, tgt)
+	case `MKCOL`:
+		h.mkcol(res, tgt)
+	case http.MethodGet, http.MethodHead:
+		h.get(res, req, tgt)
+	case http.MethodPut:
+		h.put(res, req, tgt)
+	case http.MethodDelete:
+		h.delete(res, req, tgt)
+	default:
+		res.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// parsePath parse the request path into target.
+// It return false if the path is not under prefix or contains invalid
+// name.
+func (h *Handler) parsePath(reqPath string) (tgt *target, ok bool) {
+	var rel string
+
+	rel, ok = strings.CutPrefix(path.Clean(reqPath), h.prefix)
+	if !ok || (len(rel) > 0 && rel[0] != '/') {
+		return nil, false
+	}
+	rel = strings.Trim(rel, `/`)
+
+	tgt = &target{}
+	if len(rel) == 0 {
+		return tgt, true
+	}
+
+	var parts = strings.Split(rel, `/`)
+	if len(parts) > 3 {
+		return nil, false
+	}
+	for _, name := range parts {
+		if name[0] == '.' || strings.ContainsRune(name, '\\') {
+			return nil, false
+		}
+	}
+	tgt.user = parts[0]
+	if len(parts) >= 2 {
+		tgt.book = parts[1]
+	}
+	if len(parts) == 3 {
+		tgt.name = parts[2]
+	}
+	return tgt, true
+}
+
+// initHome create the user home and its default address book if its not
+// exist.
+func (h *Handler) initHome(user string) (err error) {
+	var dir = filepath.Join(h.dir, user)
+
+	_, err = os.Stat(dir)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.MkdirAll(filepath.Join(dir, defBookName), 0700)
+}
+
+func (h *Handler) userHref(user string) string {
+	return h.prefix + `/` + url.PathEscape(user) + `/`
+}
+
+func (h *Handler) bookHref(user, book string) string {
+	return h.userHref(user) + url.PathEscape(book) + `/`
+}
+
+func (h *Handler) objectHref(user, book, name string) string {
+	return h.bookHref(user, book) + url.PathEscape(name)
+}
+
+func (h *Handler) bookDir(tgt *target) string {
+	return filepath.Join(h.dir, tgt.user, tgt.book)
+}
+
+// propfind handle the PROPFIND request.
+// The Depth "infinity" is handled as "1".
+func (h *Handler) propfind(res http.ResponseWriter, req *http.Request, authUser string, tgt *target) {
+	var (
+		pf  propfindRequest
+		err = decodeBody(req, &pf)
+	)
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var names []element
+	if pf.Prop != nil {
+		names = pf.Prop.Names
+	}
+
+	var (
+		depth = req.Header.Get(`Depth`)
+		ms    multistatus
+	)
+
+	switch {
+	case len(tgt.user) == 0:
+		var pv = propValue{
+			ResourceType:     &resourceType{Collection: &struct{}{}},
+			CurrentUserPrinc: &href{Href: h.userHref(authUser)},
+		}
+		ms.Responses = append(ms.Responses, newResponse(h.prefix+`/`, &pv, names))
+
+	case len(tgt.book) == 0:
+		var pv = propValue{
+			ResourceType:       &resourceType{Collection: &struct{}{}, Principal: &struct{}{}},
+			CurrentUserPrinc:   &href{Href: h.userHref(authUser)},
+			PrincipalURL:       &href{Href: h.userHref(tgt.user)},
+			AddressBookHomeSet: &href{Href: h.userHref(tgt.user)},
+			DisplayName:        tgt.user,
+		}
+		ms.Responses = append(ms.Responses, newResponse(h.userHref(tgt.user), &pv, names))
+		if depth == `0` {
+			break
+		}
+
+		var books []string
+		books, err = listDir(filepath.Join(h.dir, tgt.user), true)
+		if err != nil {
+			h.internalError(res, err)
+			return
+		}
+		for _, book := range books {
+			var bookTgt = &target{user: tgt.user, book: book}
+			pv, err = h.bookProps(bookTgt)
+			if err != nil {
+				h.internalError(res, err)
+				return
+			}
+			ms.Responses = append(ms.Responses, newResponse(h.bookHref(tgt.user, book), &pv, names))
+		}
+
+	case len(tgt.name) == 0:
+		var pv propValue
+		pv, err = h.bookProps(tgt)
+		if err != nil {
+			h.notFoundOrError(res, err)
+			return
+		}
+		ms.Responses = append(ms.Responses, newResponse(h.bookHref(tgt.user, tgt.book), &pv, names))
+		if depth == `0` {
+			break
+		}
+
+		var objects []string
+		objects, err = listDir(h.bookDir(tgt), false)
+		if err != nil {
+			h.internalError(res, err)
+			return
+		}
+		for _, name := range objects {
+			var objTgt = &target{user: tgt.user, book: tgt.book, name: name}
+			pv, _, err = h.objectProps(objTgt)
+			if err != nil {
+				h.internalError(res, err)
+				return
+			}
+			ms.Responses = append(ms.Responses, newResponse(h.objectHref(tgt.user, tgt.book, name), &pv, names))
+		}
+
+	default:
+		var pv propValue
+		pv, _, err = h.objectProps(tgt)
+		if err != nil {
+			h.notFoundOrError(res, err)
+			return
+		}
+		ms.Responses = append(ms.Responses, newResponse(h.objectHref(tgt.user, tgt.book, tgt.name), &pv, names))
+	}
+
+	h.writeMultistatus(res, &ms)
+}
+
+// bookProps return the properties of address book.
+func (h *Handler) bookProps(tgt *target) (pv propValue, err error) {
+	var dir = h.bookDir(tgt)
+
+	var fi os.FileInfo
+	fi, err = os.Stat(dir)
+	if err != nil {
+		return pv, err
+	}
+	if !fi.IsDir() {
+		return pv, fs.ErrNotExist
+	}
+
+	var changes []string
+	changes, err = readChanges(dir)
+	if err != nil {
+		return pv, err
+	}
+
+	var token = syncTokenPrefix + strconv.Itoa(len(changes))
+
+	pv = propValue{
+		ResourceType: &resourceType{
+			Collection:  &struct{}{},
+			AddressBook: &struct{}{},
+		},
+		SupportedReportSet: &supportedReportSet{
+			Reports: []supportedReport{
+				{Report: element{XMLName: nameAddressBookMultiget}},
+				{Report: element{XMLName: nameAddressBookQuery}},
+				{Report: element{XMLName: nameSyncCollection}},
+			},
+		},
+		DisplayName: tgt.book,
+		GetCTag:     token,
+		SyncToken:   token,
+	}
+	return pv, nil
+}
+
+// objectProps return the properties and content of vCard object.
+func (h *Handler) objectProps(tgt *target) (pv propValue, content []byte, err error) {
+	var file = filepath.Join(h.bookDir(tgt), tgt.name)
+
+	var fi os.FileInfo
+	fi, err = os.Stat(file)
+	if err != nil {
+		return pv, nil, err
+	}
+	if fi.IsDir() {
+		return pv, nil, fs.ErrNotExist
+	}
+
+	content, err = os.ReadFile(file)
+	if err != nil {
+		return pv, nil, err
+	}
+
+	pv = propValue{
+		ResourceType:     &resourceType{},
+		GetContentLength: strconv.Itoa(len(content)),
+		GetContentType:   ContentTypeVCard,
+		GetETag:          etag(content),
+		GetLastModified:  fi.ModTime().UTC().Format(http.TimeFormat),
+		AddressData:      string(content),
+	}
+	return pv, content, nil
+}
+
+// report handle the REPORT request on address book.
+func (h *Handler) report(res http.ResponseWriter, req *http.Request, tgt *target) {
+	if len(tgt.book) == 0 || len(tgt.name) > 0 {
+		res.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var (
+		rr  reportRequest
+		err = decodeBody(req, &rr)
+	)
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var names []element
+	if rr.Prop != nil {
+		names = rr.Prop.Names
+	}
+
+	var (
+		dir = h.bookDir(tgt)
+		ms  multistatus
+		pv  propValue
+	)
+
+	_, err = os.Stat(dir)
+	if err != nil {
+		h.notFoundOrError(res, err)
+		return
+	}
+
+	switch rr.XMLName {
+	case nameAddressBookMultiget:
+		for _, hrefPath := range rr.Hrefs {
+			var objTgt *target
+			objTgt, err = h.parseHref(hrefPath)
+			if err != nil || objTgt.user != tgt.user || objTgt.book != tgt.book || len(objTgt.name) == 0 {
+				ms.Responses = append(ms.Responses, response{
+					Href:   hrefPath,
+					Status: statusLine(http.StatusNotFound),
+				})
+				continue
+			}
+			pv, _, err = h.objectProps(objTgt)
+			if err != nil {
+				ms.Responses = append(ms.Responses, response{
+					Href:   hrefPath,
+					Status: statusLine(http.StatusNotFound),
+				})
+				continue
+			}
+			ms.Responses = append(ms.Responses, newResponse(hrefPath, &pv, names))
+		}
+
+	case nameAddressBookQuery:
+		// The filter is not supported, all objects are returned.
+		var objects []string
+		objects, err = listDir(dir, false)
+		if err != nil {
+			h.internalError(res, err)
+			return
+		}
+		for _, name := range objects {
+			pv, _, err = h.objectProps(&target{user: tgt.user, book: tgt.book, name: name})
+			if err != nil {
+				h.internalError(res, err)
+				return
+			}
+			ms.Responses = append(ms.Responses, newResponse(h.objectHref(tgt.user, tgt.book, name), &pv, names))
+		}
+
+	case nameSyncCollection:
+		h.mtx.Lock()
+		err = h.syncCollection(tgt, &rr, names, &ms)
+		h.mtx.Unlock()
+		if err != nil {
+			if errors.Is(err, ErrInvalidSyncToken) {
+				writeError(res, http.StatusForbidden, nameValidSyncToken)
+				return
+			}
+			h.internalError(res, err)
+			return
+		}
+
+	default:
+		res.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	h.writeMultistatus(res, &ms)
+}
+
+// syncCollection generate the response for sync-collection report.
+// If the sync-token is empty, all objects are reported.
+func (h *Handler) syncCollection(tgt *target, rr *reportRequest, names []element, ms *multistatus) (err error) {
+	var (
+		dir = h.bookDir(tgt)
+
+		changes []string
+		objects []string
+	)
+
+	changes, err = readChanges(dir)
+	if err != nil {
+		return err
+	}
+	ms.SyncToken = syncTokenPrefix + strconv.Itoa(len(changes))
+
+	if len(rr.SyncToken) == 0 {
+		objects, err = listDir(dir, false)
+		if err != nil {
+			return err
+		}
+	} else {
+		var (
+			v, ok = strings.CutPrefix(rr.SyncToken, syncTokenPrefix)
+			seq   int
+		)
+		if !ok {
+			return ErrInvalidSyncToken
+		}
+		seq, err = strconv.Atoi(v)
+		if err != nil || seq < 0 || seq > len(changes) {
+			return ErrInvalidSyncToken
+		}
+
+		var seen = map[string]bool{}
+		for _, name := range changes[seq:] {
+			name = name[1:]
+			if !seen[name] {
+				seen[name] = true
+				objects = append(objects, name)
+			}
+		}
+		sort.Strings(objects)
+	}
+
+	var pv propValue
+	for _, name := range objects {
+		var hrefPath = h.objectHref(tgt.user, tgt.book, name)
+
+		pv, _, err = h.objectProps(&target{user: tgt.user, book: tgt.book, name: name})
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			ms.Responses = append(ms.Responses, response{
+				Href:   hrefPath,
+				Status: statusLine(http.StatusNotFound),
+			})
+			continue
+		}
+		ms.Responses = append(ms.Responses, newResponse(hrefPath, &pv, names))
+	}
+	return nil
+}
+
+// parseHref parse the href, an absolute path or URL, into target.
+func (h *Handler) parseHref(hrefPath string) (tgt *target, err error) {
+	var u *url.URL
+	u, err = url.Parse(hrefPath)
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	tgt, ok = h.parsePath(u.Path)
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return tgt, nil
+}
+
+// mkcol create new address book.
+func (h *Handler) mkcol(res http.ResponseWriter, tgt *target) {
+	if len(tgt.book) == 0 || len(tgt.name) > 0 {
+		res.WriteHeader(http.StatusForbidden)
+		return
+	}
+	var err = os.Mkdir(h.bookDir(tgt), 0700)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			res.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		h.internalError(res, err)
+		return
+	}
+	res.WriteHeader(http.StatusCreated)
+}
+
+// get return the content of vCard object, or all vCard objects in
+// address book.
+func (h *Handler) get(res http.ResponseWriter, req *http.Request, tgt *target) {
+	if len(tgt.book) == 0 {
+		res.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var (
+		content []byte
+		err     error
+	)
+	if len(tgt.name) > 0 {
+		var pv propValue
+		pv, content, err = h.objectProps(tgt)
+		if err != nil {
+			h.notFoundOrError(res, err)
+			return
+		}
+		res.Header().Set(`ETag`, pv.GetETag)
+		res.Header().Set(`Last-Modified`, pv.GetLastModified)
+	} else {
+		var objects []string
+		objects, err = listDir(h.bookDir(tgt), false)
+		if err != nil {
+			h.notFoundOrError(res, err)
+			return
+		}
+		var buf bytes.Buffer
+		for _, name := range objects {
+			content, err = os.ReadFile(filepath.Join(h.bookDir(tgt), name))
+			if err != nil {
+				h.internalError(res, err)
+				return
+			}
+			buf.Write(content)
+		}
+		content = buf.Bytes()
+	}
+
+	res.Header().Set(`Content-Type`, ContentTypeVCard)
+	res.Header().Set(`Content-Length`, strconv.Itoa(len(content)))
+	res.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodGet {
+		_, _ = res.Write(content)
+	}
+}
+
+// put create or update the vCard object.
+// The request body must contains exactly one vCard.
+func (h *Handler) put(res http.ResponseWriter, req *http.Request, tgt *target) {
+	if len(tgt.name) == 0 {
+		res.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var (
+		body, err = io.ReadAll(io.LimitReader(req.Body, maxObjectSize+1))
+		cards     []*vcard.VCard
+	)
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxObjectSize {
+		res.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	cards, err = vcard.Parse(body)
+	if err != nil || len(cards) != 1 {
+		writeError(res, http.StatusForbidden, nameValidAddressData)
+		return
+	}
+
+	var dir = h.bookDir(tgt)
+
+	_, err = os.Stat(dir)
+	if err != nil {
+		res.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	var (
+		file        = filepath.Join(dir, tgt.name)
+		tmpFile     = filepath.Join(dir, `.tmp-`+tgt.name)
+		ifMatch     = req.Header.Get(`If-Match`)
+		ifNoneMatch = req.Header.Get(`If-None-Match`)
+		code        = http.StatusNoContent
+		currentETag string
+		current     []byte
+	)
+	current, err = os.ReadFile(file)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			h.internalError(res, err)
+			return
+		}
+		code = http.StatusCreated
+	} else {
+		currentETag = etag(current)
+	}
+	if len(ifMatch) > 0 && (code == http.StatusCreated || (ifMatch != `*` && ifMatch != currentETag)) {
+		res.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	if ifNoneMatch == `*` && code != http.StatusCreated {
+		res.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	err = os.WriteFile(tmpFile, body, 0600)
+	if err != nil {
+		h.internalError(res, err)
+		return
+	}
+	err = os.Rename(tmpFile, file)
+	if err != nil {
+		h.internalError(res, err)
+		return
+	}
+	err = appendChange(dir, `+`+tgt.name)
+	if err != nil {
+		h.internalError(res, err)
+		return
+	}
+
+	res.Header().Set(`ETag`, etag(body))
+	res.WriteHeader(code)
+}
+
+// delete remove the vCard object or address book.
+func (h *Handler) delete(res http.ResponseWriter, req *http.Request, tgt *target) {
+	if len(tgt.book) == 0 {
+		res.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var (
+		dir = h.bookDir(tgt)
+		err error
+	)
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if len(tgt.name) == 0 {
+		_, err = os.Stat(dir)
+		if err != nil {
+			h.notFoundOrError(res, err)
+			return
+		}
+		err = os.RemoveAll(dir)
+		if err != nil {
+			h.internalError(res, err)
+			return
+		}
+		res.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var (
+		file    = filepath.Join(dir, tgt.name)
+		content []byte
+	)
+	content, err = os.ReadFile(file)
+	if err != nil {
+		h.notFoundOrError(res, err)
+		return
+	}
+	var ifMatch = req.Header.Get(`If-Match`)
+	if len(ifMatch) > 0 && ifMatch != `*` && ifMatch != etag(content) {
+		res.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	err = os.Remove(file)
+	if err != nil {
+		h.internalError(res, err)
+		return
+	}
+	err = appendChange(dir, `-`+tgt.name)
+	if err != nil {
+		h.internalError(res, err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) writeMultistatus(res http.ResponseWriter, ms *multistatus) {
+	var body, err = xml.Marshal(ms)
+	if err != nil {
+		h.internalError(res, err)
+		return
+	}
+	res.Header().Set(`Content-Type`, contentTypeXML)
+	res.WriteHeader(http.StatusMultiStatus)
+	_, _ = res.Write([]byte(xmlHeader))
+	_, _ = res.Write(body)
+}
+
+func (h *Handler) notFoundOrError(res http.ResponseWriter, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		res.WriteHeader(http.StatusNotFound)
+		return
+	}
+	h.internalError(res, err)
+}
+
+func (h *Handler) internalError(res http.ResponseWriter, err error) {
+	log.Printf(`carddav: %s`, err)
+	res.WriteHeader(http.StatusInternalServerError)
+}
+
+// writeError write the DAV:error response with the precondition.
+func writeError(res http.ResponseWriter, code int, cond xml.Name) {
+	var body, _ = xml.Marshal(errorBody{Condition: element{XMLName: cond}})
+	res.Header().Set(`Content-Type`, contentTypeXML)
+	res.WriteHeader(code)
+	_, _ = res.Write([]byte(xmlHeader))
+	_, _ = res.Write(body)
+}
+
+// decodeBody decode the XML request body into v.
+// Empty body is not an error.
+func decodeBody(req *http.Request, v interface{}) (err error) {
+	var body []byte
+	body, err = io.ReadAll(io.LimitReader(req.Body, maxObjectSize))
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	return xml.Unmarshal(body, v)
+}
+
+// listDir return the sorted name of sub directories, if isDir is true,
+// or files inside the dir, excluding the hidden one.
+func listDir(dir string, isDir bool) (names []string, err error) {
+	var entries []os.DirEntry
+	entries, err = os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), `.`) || entry.IsDir() != isDir {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// readChanges read the list of changes in address book directory.
+// Each change is the object name prefixed with "+" for created or
+// updated, or "-" for deleted.
+func readChanges(dir string) (changes []string, err error) {
+	var content []byte
+	content, err = os.ReadFile(filepath.Join(dir, fileChanges))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if len(line) > 1 {
+			changes = append(changes, line)
+		}
+	}
+	return changes, nil
+}
+
+func appendChange(dir, change string) (err error) {
+	var f *os.File
+	f, err = os.OpenFile(filepath.Join(dir, fileChanges), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(change + "\n")
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// etag return the strong entity tag of content.
+func etag(content []byte) string {
+	var sum = sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
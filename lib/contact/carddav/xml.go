@@ -0,0 +1,234 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package carddav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+)
+
+// List of XML namespaces.
+const (
+	nsDAV     = `DAV:`
+	nsCardDAV = `urn:ietf:params:xml:ns:carddav`
+	nsCS      = `http://calendarserver.org/ns/`
+)
+
+// List of property and report names.
+var (
+	nameAddressBookDescription = xml.Name{Space: nsCardDAV, Local: `addressbook-description`}
+	nameAddressBookHomeSet     = xml.Name{Space: nsCardDAV, Local: `addressbook-home-set`}
+	nameAddressBookMultiget    = xml.Name{Space: nsCardDAV, Local: `addressbook-multiget`}
+	nameAddressBookQuery       = xml.Name{Space: nsCardDAV, Local: `addressbook-query`}
+	nameAddressData            = xml.Name{Space: nsCardDAV, Local: `address-data`}
+	nameCurrentUserPrincipal   = xml.Name{Space: nsDAV, Local: `current-user-principal`}
+	nameDisplayName            = xml.Name{Space: nsDAV, Local: `displayname`}
+	nameGetContentLength       = xml.Name{Space: nsDAV, Local: `getcontentlength`}
+	nameGetContentType         = xml.Name{Space: nsDAV, Local: `getcontenttype`}
+	nameGetCTag                = xml.Name{Space: nsCS, Local: `getctag`}
+	nameGetETag                = xml.Name{Space: nsDAV, Local: `getetag`}
+	nameGetLastModified        = xml.Name{Space: nsDAV, Local: `getlastmodified`}
+	namePrincipalURL           = xml.Name{Space: nsDAV, Local: `principal-URL`}
+	nameResourceType           = xml.Name{Space: nsDAV, Local: `resourcetype`}
+	nameSupportedReportSet     = xml.Name{Space: nsDAV, Local: `supported-report-set`}
+	nameSyncCollection         = xml.Name{Space: nsDAV, Local: `sync-collection`}
+	nameSyncToken              = xml.Name{Space: nsDAV, Local: `sync-token`}
+	nameValidAddressData       = xml.Name{Space: nsCardDAV, Local: `valid-address-data`}
+	nameValidSyncToken         = xml.Name{Space: nsDAV, Local: `valid-sync-token`}
+)
+
+// xmlHeader is the XML declaration written before the XML response.
+const xmlHeader = `<?xml version="1.0" encoding="utf-8"?>` + "\n"
+
+// element define XML element without content.
+type element struct {
+	XMLName xml.Name
+}
+
+// href define the property that contains single href, for example
+// current-user-principal.
+type href struct {
+	Href string `xml:"DAV: href"`
+}
+
+type resourceType struct {
+	Collection  *struct{} `xml:"DAV: collection,omitempty"`
+	Principal   *struct{} `xml:"DAV: principal,omitempty"`
+	AddressBook *struct{} `xml:"urn:ietf:params:xml:ns:carddav addressbook,omitempty"`
+}
+
+type supportedReport struct {
+	Report element `xml:"DAV: report"`
+}
+
+type supportedReportSet struct {
+	Reports []supportedReport `xml:"DAV: supported-report"`
+}
+
+// propValue define the values of known properties.
+// The requested property that is unknown is stored in Others.
+type propValue struct {
+	XMLName xml.Name `xml:"DAV: prop"`
+
+	ResourceType       *resourceType       `xml:"DAV: resourcetype,omitempty"`
+	CurrentUserPrinc   *href               `xml:"DAV: current-user-principal,omitempty"`
+	PrincipalURL       *href               `xml:"DAV: principal-URL,omitempty"`
+	AddressBookHomeSet *href               `xml:"urn:ietf:params:xml:ns:carddav addressbook-home-set,omitempty"`
+	SupportedReportSet *supportedReportSet `xml:"DAV: supported-report-set,omitempty"`
+
+	DisplayName      string `xml:"DAV: displayname,omitempty"`
+	Description      string `xml:"urn:ietf:params:xml:ns:carddav addressbook-description,omitempty"`
+	GetContentLength string `xml:"DAV: getcontentlength,omitempty"`
+	GetContentType   string `xml:"DAV: getcontenttype,omitempty"`
+	GetCTag          string `xml:"http://calendarserver.org/ns/ getctag,omitempty"`
+	GetETag          string `xml:"DAV: getetag,omitempty"`
+	GetLastModified  string `xml:"DAV: getlastmodified,omitempty"`
+	SyncToken        string `xml:"DAV: sync-token,omitempty"`
+	AddressData      string `xml:"urn:ietf:params:xml:ns:carddav address-data,omitempty"`
+
+	Others []element `xml:",any"`
+}
+
+type propstat struct {
+	Prop   propValue `xml:"DAV: prop"`
+	Status string    `xml:"DAV: status"`
+}
+
+type response struct {
+	Href      string     `xml:"DAV: href"`
+	Status    string     `xml:"DAV: status,omitempty"`
+	Propstats []propstat `xml:"DAV: propstat"`
+}
+
+type multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	SyncToken string     `xml:"DAV: sync-token,omitempty"`
+	Responses []response `xml:"DAV: response"`
+}
+
+type propNames struct {
+	Names []element `xml:",any"`
+}
+
+type propfindRequest struct {
+	XMLName xml.Name   `xml:"DAV: propfind"`
+	AllProp *struct{}  `xml:"DAV: allprop"`
+	Prop    *propNames `xml:"DAV: prop"`
+}
+
+// reportRequest define the body of REPORT request for
+// addressbook-multiget, addressbook-query, and sync-collection.
+type reportRequest struct {
+	XMLName   xml.Name
+	Prop      *propNames `xml:"DAV: prop"`
+	SyncToken string     `xml:"DAV: sync-token"`
+	SyncLevel string     `xml:"DAV: sync-level"`
+	Hrefs     []string   `xml:"DAV: href"`
+}
+
+type errorBody struct {
+	XMLName   xml.Name `xml:"DAV: error"`
+	Condition element
+}
+
+// statusLine return the HTTP status line for DAV:status.
+func statusLine(code int) string {
+	return fmt.Sprintf(`HTTP/1.1 %d %s`, code, http.StatusText(code))
+}
+
+// filter return the propValue that contains only the requested property
+// names, and the list of names that is not found.
+// If names is empty, it return all properties except address-data, like
+// DAV:allprop.
+func (pv *propValue) filter(names []element) (found propValue, notFound []element) {
+	if len(names) == 0 {
+		found = *pv
+		found.AddressData = ``
+		return found, nil
+	}
+
+	var ok bool
+	for _, name := range names {
+		switch name.XMLName {
+		case nameResourceType:
+			found.ResourceType, ok = pv.ResourceType, pv.ResourceType != nil
+		case nameCurrentUserPrincipal:
+			found.CurrentUserPrinc, ok = pv.CurrentUserPrinc, pv.CurrentUserPrinc != nil
+		case namePrincipalURL:
+			found.PrincipalURL, ok = pv.PrincipalURL, pv.PrincipalURL != nil
+		case nameAddressBookHomeSet:
+			found.AddressBookHomeSet, ok = pv.AddressBookHomeSet, pv.AddressBookHomeSet != nil
+		case nameSupportedReportSet:
+			found.SupportedReportSet, ok = pv.SupportedReportSet, pv.SupportedReportSet != nil
+		case nameDisplayName:
+			found.DisplayName, ok = pv.DisplayName, len(pv.DisplayName) > 0
+		case nameAddressBookDescription:
+			found.Description, ok = pv.Description, len(pv.Description) > 0
+		case nameGetContentLength:
+			found.GetContentLength, ok = pv.GetContentLength, len(pv.GetContentLength) > 0
+		case nameGetContentType:
+			found.GetContentType, ok = pv.GetContentType, len(pv.GetContentType) > 0
+		case nameGetCTag:
+			found.GetCTag, ok = pv.GetCTag, len(pv.GetCTag) > 0
+		case nameGetETag:
+			found.GetETag, ok = pv.GetETag, len(pv.GetETag) > 0
+		case nameGetLastModified:
+			found.GetLastModified, ok = pv.GetLastModified, len(pv.GetLastModified) > 0
+		case nameSyncToken:
+			found.SyncToken, ok = pv.SyncToken, len(pv.SyncToken) > 0
+		case nameAddressData:
+			found.AddressData, ok = pv.AddressData, len(pv.AddressData) > 0
+		default:
+			ok = false
+		}
+		if !ok {
+			notFound = append(notFound, name)
+		}
+	}
+	return found, notFound
+}
+
+// newResponse create the response for href with the properties in pv
+// filtered by names.
+func newResponse(hrefPath string, pv *propValue, names []element) (resp response) {
+	var found, notFound = pv.filter(names)
+
+	resp.Href = hrefPath
+	resp.Propstats = append(resp.Propstats, propstat{
+		Prop:   found,
+		Status: statusLine(http.StatusOK),
+	})
+	if len(notFound) > 0 {
+		resp.Propstats = append(resp.Propstats, propstat{
+			Prop:   propValue{Others: notFound},
+			Status: statusLine(http.StatusNotFound),
+		})
+	}
+	return resp
+}
+
+// okProp return the property from the propstat with status 200.
+func (resp *response) okProp() *propValue {
+	for x, ps := range resp.Propstats {
+		if isStatusOK(ps.Status) {
+			return &resp.Propstats[x].Prop
+		}
+	}
+	return nil
+}
+
+// isStatusOK return true if the DAV:status line is 2xx.
+func isStatusOK(status string) bool {
+	var code int
+	var _, err = fmt.Sscanf(status, `HTTP/1.1 %d`, &code)
+	if err != nil {
+		_, err = fmt.Sscanf(status, `HTTP/1.0 %d`, &code)
+		if err != nil {
+			return false
+		}
+	}
+	return code >= 200 && code < 300
+}
@@ -0,0 +1,220 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vcard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Marshal encode one or more cards into vCard format, with line folded
+// at 75 octets and terminated by CRLF.
+//
+// Each card is written using its Version, default to 4.0 if its empty.
+// The properties that only defined in vCard 4.0, like KIND, GENDER, and
+// ANNIVERSARY, are written as is on version 3.0.
+func Marshal(cards ...*VCard) (raw []byte, err error) {
+	var (
+		logp = `Marshal`
+		buf  bytes.Buffer
+	)
+	for _, card := range cards {
+		err = card.marshal(&buf)
+		if err != nil {
+			return nil, fmt.Errorf(`%s: %w`, logp, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (card *VCard) marshal(buf *bytes.Buffer) (err error) {
+	var version = card.Version
+	if len(version) == 0 {
+		version = Version4
+	}
+	if version != Version3 && version != Version4 {
+		return fmt.Errorf(`unsupported version %q`, version)
+	}
+
+	var (
+		isV3 = version == Version3
+		add  = func(prop *Property) {
+			buf.WriteString(prop.String())
+		}
+		addText = func(name, value string) {
+			if len(value) > 0 {
+				add(&Property{Name: name, Value: value})
+			}
+		}
+		v string
+	)
+
+	buf.WriteString("BEGIN:VCARD\r\n")
+	addText(`VERSION`, version)
+	addText(`PRODID`, escapeText(card.ProdID))
+	addText(`UID`, card.UID)
+	addText(`KIND`, card.Kind)
+	for _, v = range card.Source {
+		addText(`SOURCE`, v)
+	}
+
+	add(&Property{Name: `FN`, Value: escapeText(card.Fn)})
+
+	var n = card.N
+	if isV3 || len(n.Family+n.Given+n.Middle+n.Prefix+n.Suffix) > 0 {
+		v = joinValue([]string{n.Family, n.Given, n.Middle, n.Prefix, n.Suffix}, `;`)
+		add(&Property{Name: `N`, Value: v})
+	}
+	addText(propPhoneticGiven, escapeText(n.GivenSound))
+	addText(propPhoneticFamily, escapeText(n.FamilySound))
+
+	if len(card.Nickname) > 0 {
+		add(&Property{Name: `NICKNAME`, Value: joinValue(card.Nickname, `,`)})
+	}
+	for _, res := range card.Photo {
+		add(res.property(`PHOTO`, isV3))
+	}
+	addText(`BDAY`, card.Bday.VCardString())
+	addText(`ANNIVERSARY`, card.Anniversary.VCardString())
+
+	if card.Gender.Sex != 0 || len(card.Gender.Desc) > 0 {
+		v = ``
+		if card.Gender.Sex != 0 {
+			v = string(card.Gender.Sex)
+		}
+		if len(card.Gender.Desc) > 0 {
+			v += `;` + escapeText(card.Gender.Desc)
+		}
+		add(&Property{Name: `GENDER`, Value: v})
+	}
+
+	for _, adr := range card.Adr {
+		var prop = &Property{
+			Name: `ADR`,
+			Value: joinValue([]string{adr.POBox, adr.Ext,
+				adr.Street, adr.City, adr.StateOrProv,
+				adr.PostalCode, adr.Country}, `;`),
+		}
+		prop.setType(adr.Type)
+		add(prop)
+	}
+	for _, tel := range card.Tel {
+		var prop = &Property{Name: `TEL`, Value: escapeText(tel.Number)}
+		prop.setType(tel.Type)
+		add(prop)
+	}
+	for _, email := range card.Email {
+		var prop = &Property{Name: `EMAIL`, Value: escapeText(email.Address)}
+		prop.setType(email.Type)
+		add(prop)
+	}
+	for _, impp := range card.Impp {
+		var prop = &Property{Name: `IMPP`, Value: impp.URI}
+		prop.setType(impp.Type)
+		add(prop)
+	}
+	for _, v = range card.Lang {
+		addText(`LANG`, v)
+	}
+	addText(`TZ`, escapeText(card.TZ))
+	for _, geo := range card.Geo {
+		var (
+			lat  = strconv.FormatFloat(float64(geo.Lat), 'f', -1, 32)
+			long = strconv.FormatFloat(float64(geo.Long), 'f', -1, 32)
+		)
+		if isV3 {
+			v = lat + `;` + long
+		} else {
+			v = `geo:` + lat + `,` + long
+		}
+		add(&Property{Name: `GEO`, Value: v})
+	}
+	for _, v = range card.Title {
+		addText(`TITLE`, escapeText(v))
+	}
+	for _, v = range card.Role {
+		addText(`ROLE`, escapeText(v))
+	}
+	for _, res := range card.Logo {
+		add(res.property(`LOGO`, isV3))
+	}
+	if len(card.Org) > 0 {
+		add(&Property{Name: `ORG`, Value: joinValue(card.Org, `;`)})
+	}
+	for _, rel := range card.Related {
+		var prop = &Property{Name: `RELATED`, Value: rel.URI}
+		prop.setType(rel.Type)
+		add(prop)
+	}
+	if len(card.Categories) > 0 {
+		add(&Property{Name: `CATEGORIES`, Value: joinValue(card.Categories, `,`)})
+	}
+	for _, v = range card.Note {
+		addText(`NOTE`, escapeText(v))
+	}
+	for _, res := range card.Sound {
+		add(res.property(`SOUND`, isV3))
+	}
+	for _, v = range card.URL {
+		addText(`URL`, v)
+	}
+	for _, res := range card.Key {
+		add(res.property(`KEY`, isV3))
+	}
+	addText(`CLIENTPIDMAP`, card.ClientPIDMap)
+	addText(`REV`, card.Rev)
+	for _, prop := range card.Extensions {
+		add(prop)
+	}
+	buf.WriteString("END:VCARD\r\n")
+
+	return nil
+}
+
+// setType set the TYPE parameter from comma separated types.
+func (prop *Property) setType(types string) {
+	if len(types) > 0 {
+		prop.setParam(ParamType, strings.Split(types, `,`)...)
+	}
+}
+
+// property convert the resource into property with the name.
+// On vCard 3.0, the embedded data is encoded with "ENCODING=b" and the
+// media sub-type as TYPE, while on vCard 4.0 it is encoded as "data:"
+// URI.
+func (res *Resource) property(name string, isV3 bool) (prop *Property) {
+	prop = &Property{
+		Name: name,
+	}
+
+	var subtype = res.Type
+	if x := strings.IndexByte(subtype, '/'); x >= 0 {
+		subtype = subtype[x+1:]
+	}
+
+	if res.Data != nil {
+		var data = base64.StdEncoding.EncodeToString(res.Data)
+		if isV3 {
+			prop.setParam(ParamEncoding, `b`)
+			prop.setParam(ParamType, strings.ToUpper(subtype))
+			prop.Value = data
+		} else {
+			prop.Value = `data:` + res.Type + `;base64,` + data
+		}
+		return prop
+	}
+
+	prop.Value = res.URI
+	if isV3 {
+		prop.setParam(ParamValue, `uri`)
+		prop.setParam(ParamType, strings.ToUpper(subtype))
+	} else {
+		prop.setParam(ParamMediaType, res.Type)
+	}
+	return prop
+}
@@ -0,0 +1,347 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vcard
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/shuLhan/share/lib/contact"
+)
+
+// List of non-standard properties that are mapped into VCard fields.
+const (
+	propPhoneticFamily = `X-PHONETIC-LAST-NAME`
+	propPhoneticGiven  = `X-PHONETIC-FIRST-NAME`
+)
+
+// Parse one or more vCard version 3.0 or 4.0 from raw.
+// The content lines can be terminated by CRLF or LF, and the folded lines
+// are joined before being parsed.
+func Parse(raw []byte) (cards []*VCard, err error) {
+	var (
+		logp  = `Parse`
+		lines = unfold(string(raw))
+
+		card *VCard
+		prop *Property
+		x    int
+	)
+	for x = range lines {
+		prop, err = parseProperty(lines[x])
+		if err != nil {
+			return nil, fmt.Errorf(`%s: line %d: %w`, logp, x+1, err)
+		}
+
+		switch prop.Name {
+		case `BEGIN`:
+			if !strings.EqualFold(prop.Value, `VCARD`) {
+				return nil, fmt.Errorf(`%s: line %d: unknown BEGIN %q`, logp, x+1, prop.Value)
+			}
+			if card != nil {
+				return nil, fmt.Errorf(`%s: line %d: nested BEGIN:VCARD`, logp, x+1)
+			}
+			card = &VCard{}
+
+		case `END`:
+			if card == nil {
+				return nil, fmt.Errorf(`%s: line %d: END without BEGIN`, logp, x+1)
+			}
+			cards = append(cards, card)
+			card = nil
+
+		default:
+			if card == nil {
+				return nil, fmt.Errorf(`%s: line %d: property %s outside of vCard`, logp, x+1, prop.Name)
+			}
+			err = card.setProperty(prop)
+			if err != nil {
+				return nil, fmt.Errorf(`%s: line %d: %w`, logp, x+1, err)
+			}
+		}
+	}
+	if card != nil {
+		return nil, fmt.Errorf(`%s: missing END:VCARD`, logp)
+	}
+	return cards, nil
+}
+
+// setProperty set the field in card based on property name.
+// Unknown property is stored in Extensions.
+func (card *VCard) setProperty(prop *Property) (err error) {
+	var (
+		list []string
+		ok   bool
+	)
+
+	switch prop.Name {
+	case `VERSION`:
+		card.Version = prop.Value
+	case `UID`:
+		card.UID = prop.Value
+	case `SOURCE`:
+		card.Source = append(card.Source, prop.Value)
+	case `KIND`:
+		card.Kind = strings.ToLower(prop.Value)
+	case `FN`:
+		card.Fn = unescapeText(prop.Value)
+	case `N`:
+		list = splitValueN(prop.Value, ';', 5)
+		card.N.Family = list[0]
+		card.N.Given = list[1]
+		card.N.Middle = list[2]
+		card.N.Prefix = list[3]
+		card.N.Suffix = list[4]
+	case propPhoneticFamily:
+		card.N.FamilySound = unescapeText(prop.Value)
+	case propPhoneticGiven:
+		card.N.GivenSound = unescapeText(prop.Value)
+	case `NICKNAME`:
+		card.Nickname = append(card.Nickname, splitValue(prop.Value, ',')...)
+	case `PHOTO`:
+		card.Photo, err = appendResource(card.Photo, prop)
+	case `BDAY`:
+		card.Bday, ok = parseDate(prop.Value)
+		if !ok {
+			card.Extensions = append(card.Extensions, prop)
+		}
+	case `ANNIVERSARY`:
+		card.Anniversary, ok = parseDate(prop.Value)
+		if !ok {
+			card.Extensions = append(card.Extensions, prop)
+		}
+	case `GENDER`:
+		list = splitValueN(prop.Value, ';', 2)
+		if len(list[0]) > 0 {
+			card.Gender.Sex = []rune(strings.ToUpper(list[0]))[0]
+		}
+		card.Gender.Desc = list[1]
+	case `ADR`:
+		list = splitValueN(prop.Value, ';', 7)
+		card.Adr = append(card.Adr, contact.Address{
+			Type:        prop.types(),
+			POBox:       list[0],
+			Ext:         list[1],
+			Street:      list[2],
+			City:        list[3],
+			StateOrProv: list[4],
+			PostalCode:  list[5],
+			Country:     list[6],
+		})
+	case `TEL`:
+		card.Tel = append(card.Tel, contact.Phone{
+			Type:   prop.types(),
+			Number: strings.TrimPrefix(unescapeText(prop.Value), `tel:`),
+		})
+	case `EMAIL`:
+		card.Email = append(card.Email, contact.Email{
+			Type:    prop.types(),
+			Address: unescapeText(prop.Value),
+		})
+	case `IMPP`:
+		card.Impp = append(card.Impp, Messaging{
+			Type: prop.types(),
+			URI:  prop.Value,
+		})
+	case `LANG`:
+		card.Lang = append(card.Lang, prop.Value)
+	case `TZ`:
+		card.TZ = unescapeText(prop.Value)
+	case `GEO`:
+		var geo GeoLocation
+		geo, ok = parseGeo(prop.Value)
+		if !ok {
+			return fmt.Errorf(`invalid GEO %q`, prop.Value)
+		}
+		card.Geo = append(card.Geo, geo)
+	case `TITLE`:
+		card.Title = append(card.Title, unescapeText(prop.Value))
+	case `ROLE`:
+		card.Role = append(card.Role, unescapeText(prop.Value))
+	case `LOGO`:
+		card.Logo, err = appendResource(card.Logo, prop)
+	case `ORG`:
+		card.Org = splitValue(prop.Value, ';')
+	case `RELATED`:
+		card.Related = append(card.Related, Relation{
+			Type: prop.types(),
+			URI:  prop.Value,
+		})
+	case `CATEGORIES`:
+		card.Categories = append(card.Categories, splitValue(prop.Value, ',')...)
+	case `NOTE`:
+		card.Note = append(card.Note, unescapeText(prop.Value))
+	case `PRODID`:
+		card.ProdID = unescapeText(prop.Value)
+	case `SOUND`:
+		card.Sound, err = appendResource(card.Sound, prop)
+	case `CLIENTPIDMAP`:
+		card.ClientPIDMap = prop.Value
+	case `KEY`:
+		card.Key, err = appendResource(card.Key, prop)
+	case `URL`:
+		card.URL = append(card.URL, prop.Value)
+	case `REV`:
+		card.Rev = prop.Value
+	default:
+		card.Extensions = append(card.Extensions, prop)
+	}
+	return err
+}
+
+// splitValueN split the structured value by sep into exactly n values.
+func splitValueN(s string, sep byte, n int) (list []string) {
+	list = splitValue(s, sep)
+	for len(list) < n {
+		list = append(list, ``)
+	}
+	return list[:n]
+}
+
+// parseDate parse the date value in the format "YYYYMMDD", "YYYY-MM-DD",
+// "--MMDD", or "--MM-DD", with optional time after "T".
+func parseDate(v string) (date contact.Date, ok bool) {
+	v, _, _ = strings.Cut(v, `T`)
+	if strings.HasPrefix(v, `--`) {
+		v = strings.ReplaceAll(v[2:], `-`, ``)
+		if len(v) != 4 || !isDigits(v) {
+			return date, false
+		}
+		date.Month = v[:2]
+		date.Day = v[2:]
+		return date, true
+	}
+	v = strings.ReplaceAll(v, `-`, ``)
+	if len(v) != 8 || !isDigits(v) {
+		return date, false
+	}
+	date.Year = v[:4]
+	date.Month = v[4:6]
+	date.Day = v[6:]
+	return date, true
+}
+
+func isDigits(v string) bool {
+	for x := 0; x < len(v); x++ {
+		if v[x] < '0' || v[x] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseGeo parse the GEO value in vCard 4.0 format "geo:lat,long" or
+// vCard 3.0 format "lat;long".
+func parseGeo(v string) (geo GeoLocation, ok bool) {
+	var lat, long string
+	if strings.HasPrefix(v, `geo:`) {
+		v, _, _ = strings.Cut(v[4:], `;`)
+		lat, long, ok = strings.Cut(v, `,`)
+	} else {
+		lat, long, ok = strings.Cut(v, `;`)
+	}
+	if !ok {
+		return geo, false
+	}
+
+	var f float64
+	var err error
+
+	f, err = strconv.ParseFloat(strings.TrimSpace(lat), 32)
+	if err != nil {
+		return geo, false
+	}
+	geo.Lat = float32(f)
+
+	f, err = strconv.ParseFloat(strings.TrimSpace(long), 32)
+	if err != nil {
+		return geo, false
+	}
+	geo.Long = float32(f)
+
+	return geo, true
+}
+
+// appendResource parse the property PHOTO, LOGO, SOUND, or KEY as
+// Resource and append it to list.
+//
+// The embedded data can be in vCard 3.0 format, with parameter
+// "ENCODING=b", or in vCard 4.0 format as "data:" URI.
+// The Resource Type is set to media type, for example "image/jpeg".
+func appendResource(list []Resource, prop *Property) ([]Resource, error) {
+	var (
+		res = Resource{
+			Type: prop.Param(ParamMediaType),
+		}
+		err error
+	)
+	if len(res.Type) == 0 {
+		res.Type = mediaType(prop.Name, prop.Param(ParamType))
+	}
+
+	var enc = strings.ToLower(prop.Param(ParamEncoding))
+	switch {
+	case enc == `b` || enc == `base64`:
+		res.Data, err = decodeBase64(prop.Value)
+		if err != nil {
+			return list, fmt.Errorf(`%s: %w`, prop.Name, err)
+		}
+
+	case strings.HasPrefix(prop.Value, `data:`):
+		var meta, data, _ = strings.Cut(prop.Value[5:], `,`)
+		var isBase64 bool
+
+		meta, isBase64 = strings.CutSuffix(meta, `;base64`)
+		if len(meta) > 0 {
+			res.Type = meta
+		}
+		if isBase64 {
+			res.Data, err = decodeBase64(data)
+		} else {
+			data, err = url.PathUnescape(data)
+			res.Data = []byte(data)
+		}
+		if err != nil {
+			return list, fmt.Errorf(`%s: %w`, prop.Name, err)
+		}
+
+	default:
+		res.URI = prop.Value
+	}
+	return append(list, res), nil
+}
+
+// mediaType convert the vCard 3.0 TYPE parameter, for example "JPEG",
+// into media type based on property name, for example "image/jpeg".
+func mediaType(propName, typ string) string {
+	if len(typ) == 0 || strings.Contains(typ, `/`) {
+		return typ
+	}
+	typ = strings.ToLower(typ)
+	switch propName {
+	case `PHOTO`, `LOGO`:
+		return `image/` + typ
+	case `SOUND`:
+		return `audio/` + typ
+	}
+	return `application/` + typ
+}
+
+func decodeBase64(v string) ([]byte, error) {
+	v = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, v)
+	var data, err = base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(v)
+	}
+	return data, err
+}
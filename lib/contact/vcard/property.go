@@ -0,0 +1,290 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vcard
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLineLength is the maximum length of content line, in octets,
+// excluding the line break, before it is folded.
+const maxLineLength = 75
+
+// List of parameter names.
+const (
+	ParamEncoding  = `ENCODING`
+	ParamMediaType = `MEDIATYPE`
+	ParamType      = `TYPE`
+	ParamValue     = `VALUE`
+)
+
+// Property represent single content line in vCard, in the format
+// "[group.]NAME[;PARAM=VALUE]:VALUE".
+type Property struct {
+	// Params contains the parameters with uppercase name.
+	Params map[string][]string
+
+	// Group of property, for example "item1" in
+	// "item1.X-ABLABEL:Work".
+	Group string
+
+	// Name of property in uppercase.
+	Name string
+
+	// Value of property, as is, without unescaping.
+	Value string
+}
+
+// parseProperty parse the unfolded content line.
+func parseProperty(line string) (prop *Property, err error) {
+	var (
+		x       int
+		inQuote bool
+	)
+	for ; x < len(line); x++ {
+		if line[x] == '"' {
+			inQuote = !inQuote
+		}
+		if line[x] == ':' && !inQuote {
+			break
+		}
+	}
+	if x == len(line) {
+		return nil, fmt.Errorf(`missing ':' in %q`, line)
+	}
+
+	prop = &Property{
+		Value: line[x+1:],
+	}
+
+	var (
+		fields = splitUnquoted(line[:x], ';')
+		name   = fields[0]
+	)
+
+	var group, n, found = strings.Cut(name, `.`)
+	if found {
+		prop.Group = group
+		name = n
+	}
+	prop.Name = strings.ToUpper(strings.TrimSpace(name))
+	if len(prop.Name) == 0 {
+		return nil, fmt.Errorf(`empty property name in %q`, line)
+	}
+
+	for _, param := range fields[1:] {
+		var key, values, hasValue = strings.Cut(param, `=`)
+
+		key = strings.ToUpper(strings.TrimSpace(key))
+		if !hasValue {
+			// vCard 2.1 parameter without name, for example
+			// "TEL;HOME:...", is the TYPE parameter.
+			values = key
+			key = ParamType
+		}
+		if prop.Params == nil {
+			prop.Params = map[string][]string{}
+		}
+		for _, v := range splitUnquoted(values, ',') {
+			v = strings.Trim(v, `"`)
+			prop.Params[key] = append(prop.Params[key], decodeParamValue(v))
+		}
+	}
+	return prop, nil
+}
+
+// splitUnquoted split s by sep that is not inside double quotes.
+func splitUnquoted(s string, sep byte) (list []string) {
+	var (
+		start   int
+		inQuote bool
+	)
+	for x := 0; x < len(s); x++ {
+		switch s[x] {
+		case '"':
+			inQuote = !inQuote
+		case sep:
+			if !inQuote {
+				list = append(list, s[start:x])
+				start = x + 1
+			}
+		}
+	}
+	return append(list, s[start:])
+}
+
+// decodeParamValue decode the circumflex encoding in parameter value,
+// RFC 6868.
+func decodeParamValue(v string) string {
+	if !strings.Contains(v, `^`) {
+		return v
+	}
+	return strings.NewReplacer(`^n`, "\n", `^N`, "\n", `^^`, `^`, `^'`, `"`).Replace(v)
+}
+
+// encodeParamValue encode the parameter value using circumflex encoding
+// and quote it if its contains special characters.
+func encodeParamValue(v string) string {
+	v = strings.NewReplacer(`^`, `^^`, "\n", `^n`, `"`, `^'`).Replace(v)
+	if strings.ContainsAny(v, `,;:`) {
+		return `"` + v + `"`
+	}
+	return v
+}
+
+// Param return the first value of parameter key.
+func (prop *Property) Param(key string) string {
+	var values = prop.Params[strings.ToUpper(key)]
+	if len(values) == 0 {
+		return ``
+	}
+	return values[0]
+}
+
+// setParam set the parameter key with one or more values.
+// Empty values are ignored.
+func (prop *Property) setParam(key string, values ...string) {
+	var list []string
+	for _, v := range values {
+		if len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+	if len(list) == 0 {
+		return
+	}
+	if prop.Params == nil {
+		prop.Params = map[string][]string{}
+	}
+	prop.Params[key] = list
+}
+
+// types return the value of TYPE parameter in lowercase, joined by comma.
+func (prop *Property) types() string {
+	return strings.ToLower(strings.Join(prop.Params[ParamType], `,`))
+}
+
+// String return the folded content line of property, terminated by CRLF.
+func (prop *Property) String() string {
+	var sb strings.Builder
+
+	if len(prop.Group) > 0 {
+		sb.WriteString(prop.Group)
+		sb.WriteByte('.')
+	}
+	sb.WriteString(prop.Name)
+
+	var keys = make([]string, 0, len(prop.Params))
+	for key := range prop.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var values = make([]string, 0, len(prop.Params[key]))
+		for _, v := range prop.Params[key] {
+			values = append(values, encodeParamValue(v))
+		}
+		sb.WriteByte(';')
+		sb.WriteString(key)
+		sb.WriteByte('=')
+		sb.WriteString(strings.Join(values, `,`))
+	}
+	sb.WriteByte(':')
+	sb.WriteString(prop.Value)
+
+	return fold(sb.String())
+}
+
+// fold the line into multiple lines with maximum length of 75 octets,
+// without splitting the UTF-8 character.
+func fold(line string) string {
+	var sb strings.Builder
+	for n := maxLineLength; len(line) > n; n = maxLineLength - 1 {
+		var x = n
+		for x > 0 && !utf8.RuneStart(line[x]) {
+			x--
+		}
+		sb.WriteString(line[:x])
+		sb.WriteString("\r\n ")
+		line = line[x:]
+	}
+	sb.WriteString(line)
+	sb.WriteString("\r\n")
+	return sb.String()
+}
+
+// unfold join the folded lines in raw and return list of content lines.
+// The line break can be CRLF or LF.
+func unfold(raw string) (lines []string) {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	for _, line := range strings.Split(raw, "\n") {
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// escapeText escape the backslash, comma, semicolon, and new line in
+// text value.
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `,`, `\,`, `;`, `\;`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// unescapeText unescape the text value.
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for x := 0; x < len(s); x++ {
+		if s[x] != '\\' || x+1 == len(s) {
+			sb.WriteByte(s[x])
+			continue
+		}
+		x++
+		switch s[x] {
+		case 'n', 'N':
+			sb.WriteByte('\n')
+		default:
+			sb.WriteByte(s[x])
+		}
+	}
+	return sb.String()
+}
+
+// splitValue split the structured or list value by unescaped sep and
+// unescape each of them.
+func splitValue(s string, sep byte) (list []string) {
+	var start int
+	for x := 0; x < len(s); x++ {
+		if s[x] == '\\' {
+			x++
+			continue
+		}
+		if s[x] == sep {
+			list = append(list, unescapeText(s[start:x]))
+			start = x + 1
+		}
+	}
+	return append(list, unescapeText(s[start:]))
+}
+
+// joinValue escape each of value in list and join them with sep.
+func joinValue(list []string, sep string) string {
+	var escaped = make([]string, 0, len(list))
+	for _, v := range list {
+		escaped = append(escaped, escapeText(v))
+	}
+	return strings.Join(escaped, sep)
+}
@@ -0,0 +1,356 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vcard
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/shuLhan/share/lib/contact"
+)
+
+// FromRecord create new vCard 4.0 from contact record.
+//
+// The FN property is generated from the record name, or from the
+// company or the first email address if the name is empty.
+func FromRecord(rec *contact.Record) (card *VCard) {
+	card = &VCard{
+		Version: Version4,
+		N:       rec.Name,
+		Adr:     append([]contact.Address(nil), rec.Addresses...),
+		Tel:     append([]contact.Phone(nil), rec.Phones...),
+		Email:   append([]contact.Email(nil), rec.Emails...),
+		URL:     append([]string(nil), rec.Links...),
+		Note:    append([]string(nil), rec.Notes...),
+	}
+	if rec.Birthday != nil {
+		card.Bday = *rec.Birthday
+	}
+	if rec.Anniversary != nil {
+		card.Anniversary = *rec.Anniversary
+	}
+	if len(rec.Company) > 0 {
+		card.Org = []string{rec.Company}
+	}
+	if len(rec.JobTitle) > 0 {
+		card.Title = []string{rec.JobTitle}
+	}
+
+	var names []string
+	for _, v := range []string{rec.Name.Prefix, rec.Name.Given, rec.Name.Middle, rec.Name.Family, rec.Name.Suffix} {
+		if len(v) > 0 {
+			names = append(names, v)
+		}
+	}
+	card.Fn = strings.Join(names, ` `)
+	if len(card.Fn) == 0 {
+		card.Fn = rec.Company
+	}
+	if len(card.Fn) == 0 && len(rec.Emails) > 0 {
+		card.Fn = rec.Emails[0].Address
+	}
+	return card
+}
+
+// Record convert the vCard into contact record.
+//
+// If the N property is empty, the FN is stored as given name, unless the FN
+// is equal to the first ORG or the first EMAIL, as generated by
+// [FromRecord].
+// The Company is set from the first value of ORG and JobTitle from the
+// first TITLE.
+func (card *VCard) Record() (rec *contact.Record) {
+	rec = &contact.Record{
+		Name:      card.N,
+		Addresses: append([]contact.Address(nil), card.Adr...),
+		Emails:    append([]contact.Email(nil), card.Email...),
+		Phones:    append([]contact.Phone(nil), card.Tel...),
+		Links:     append([]string(nil), card.URL...),
+		Notes:     append([]string(nil), card.Note...),
+	}
+	var n = card.N
+	if len(n.Family+n.Given+n.Middle+n.Prefix+n.Suffix) == 0 && !card.isFnGenerated() {
+		rec.Name.Given = card.Fn
+	}
+	if len(card.Bday.Month) > 0 {
+		var bday = card.Bday
+		rec.Birthday = &bday
+	}
+	if len(card.Anniversary.Month) > 0 {
+		var anniv = card.Anniversary
+		rec.Anniversary = &anniv
+	}
+	if len(card.Org) > 0 {
+		rec.Company = card.Org[0]
+	}
+	if len(card.Title) > 0 {
+		rec.JobTitle = card.Title[0]
+	}
+	return rec
+}
+
+// isFnGenerated return true if the FN is equal to the first ORG or the
+// first EMAIL address.
+func (card *VCard) isFnGenerated() bool {
+	if len(card.Org) > 0 && card.Fn == card.Org[0] {
+		return true
+	}
+	if len(card.Email) > 0 && card.Fn == card.Email[0].Address {
+		return true
+	}
+	return false
+}
+
+// IsDuplicate return true if card and other have the same UID, or share
+// at least one email address or phone number.
+// The email address is compared case insensitively and the phone number
+// is compared by its digits only.
+func (card *VCard) IsDuplicate(other *VCard) bool {
+	if len(card.UID) > 0 && card.UID == other.UID {
+		return true
+	}
+	for _, a := range card.Email {
+		for _, b := range other.Email {
+			if len(a.Address) > 0 && strings.EqualFold(a.Address, b.Address) {
+				return true
+			}
+		}
+	}
+	for _, a := range card.Tel {
+		var na = normalizePhone(a.Number)
+		if len(na) == 0 {
+			continue
+		}
+		for _, b := range other.Tel {
+			if na == normalizePhone(b.Number) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Merge the other card into card.
+// The empty fields in card are filled with the value from other, while
+// the list fields are appended with the value from other that does not
+// exist in card.
+func (card *VCard) Merge(other *VCard) {
+	mergeString(&card.Version, other.Version)
+	mergeString(&card.UID, other.UID)
+	mergeString(&card.Kind, other.Kind)
+	mergeString(&card.Fn, other.Fn)
+	mergeString(&card.N.Given, other.N.Given)
+	mergeString(&card.N.Middle, other.N.Middle)
+	mergeString(&card.N.Family, other.N.Family)
+	mergeString(&card.N.Prefix, other.N.Prefix)
+	mergeString(&card.N.Suffix, other.N.Suffix)
+	mergeString(&card.N.GivenSound, other.N.GivenSound)
+	mergeString(&card.N.FamilySound, other.N.FamilySound)
+	mergeString(&card.TZ, other.TZ)
+	mergeString(&card.ProdID, other.ProdID)
+	mergeString(&card.ClientPIDMap, other.ClientPIDMap)
+	mergeString(&card.Rev, other.Rev)
+
+	if len(card.Bday.Month) == 0 {
+		card.Bday = other.Bday
+	}
+	if len(card.Anniversary.Month) == 0 {
+		card.Anniversary = other.Anniversary
+	}
+	if card.Gender.Sex == 0 && len(card.Gender.Desc) == 0 {
+		card.Gender = other.Gender
+	}
+	if len(card.Org) == 0 {
+		card.Org = append(card.Org, other.Org...)
+	}
+
+	card.Source = mergeStrings(card.Source, other.Source)
+	card.Nickname = mergeStrings(card.Nickname, other.Nickname)
+	card.Lang = mergeStrings(card.Lang, other.Lang)
+	card.Title = mergeStrings(card.Title, other.Title)
+	card.Role = mergeStrings(card.Role, other.Role)
+	card.Categories = mergeStrings(card.Categories, other.Categories)
+	card.Note = mergeStrings(card.Note, other.Note)
+	card.URL = mergeStrings(card.URL, other.URL)
+
+	for _, adr := range other.Adr {
+		if !hasAddress(card.Adr, adr) {
+			card.Adr = append(card.Adr, adr)
+		}
+	}
+	for _, tel := range other.Tel {
+		if !hasPhone(card.Tel, tel) {
+			card.Tel = append(card.Tel, tel)
+		}
+	}
+	for _, email := range other.Email {
+		if !hasEmail(card.Email, email) {
+			card.Email = append(card.Email, email)
+		}
+	}
+	for _, impp := range other.Impp {
+		if !hasMessaging(card.Impp, impp) {
+			card.Impp = append(card.Impp, impp)
+		}
+	}
+	for _, geo := range other.Geo {
+		if !hasGeo(card.Geo, geo) {
+			card.Geo = append(card.Geo, geo)
+		}
+	}
+	for _, rel := range other.Related {
+		if !hasRelation(card.Related, rel) {
+			card.Related = append(card.Related, rel)
+		}
+	}
+	card.Photo = mergeResources(card.Photo, other.Photo)
+	card.Logo = mergeResources(card.Logo, other.Logo)
+	card.Sound = mergeResources(card.Sound, other.Sound)
+	card.Key = mergeResources(card.Key, other.Key)
+
+	for _, prop := range other.Extensions {
+		if !hasProperty(card.Extensions, prop) {
+			card.Extensions = append(card.Extensions, prop)
+		}
+	}
+}
+
+// Merge the duplicate cards in list, see [VCard.IsDuplicate].
+// The duplicate card is merged into the first card that match with it,
+// see [VCard.Merge], so the cards in list may be modified.
+func Merge(list ...*VCard) (merged []*VCard) {
+	var (
+		card  *VCard
+		found bool
+	)
+	for _, card = range list {
+		found = false
+		for _, prev := range merged {
+			if prev.IsDuplicate(card) {
+				prev.Merge(card)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, card)
+		}
+	}
+	return merged
+}
+
+// normalizePhone return only the digits in phone number.
+func normalizePhone(number string) string {
+	var sb strings.Builder
+	for x := 0; x < len(number); x++ {
+		if number[x] >= '0' && number[x] <= '9' {
+			sb.WriteByte(number[x])
+		}
+	}
+	return sb.String()
+}
+
+func mergeString(dst *string, v string) {
+	if len(*dst) == 0 {
+		*dst = v
+	}
+}
+
+func mergeStrings(list, others []string) []string {
+	for _, v := range others {
+		var found bool
+		for _, exist := range list {
+			if exist == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func hasAddress(list []contact.Address, adr contact.Address) bool {
+	for _, v := range list {
+		if v == adr {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPhone(list []contact.Phone, tel contact.Phone) bool {
+	var number = normalizePhone(tel.Number)
+	for _, v := range list {
+		if normalizePhone(v.Number) == number {
+			return true
+		}
+	}
+	return false
+}
+
+func hasEmail(list []contact.Email, email contact.Email) bool {
+	for _, v := range list {
+		if strings.EqualFold(v.Address, email.Address) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasMessaging(list []Messaging, impp Messaging) bool {
+	for _, v := range list {
+		if v.URI == impp.URI {
+			return true
+		}
+	}
+	return false
+}
+
+func hasGeo(list []GeoLocation, geo GeoLocation) bool {
+	for _, v := range list {
+		if v == geo {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRelation(list []Relation, rel Relation) bool {
+	for _, v := range list {
+		if v.URI == rel.URI {
+			return true
+		}
+	}
+	return false
+}
+
+func mergeResources(list, others []Resource) []Resource {
+	for _, res := range others {
+		var found bool
+		for _, v := range list {
+			if v.URI == res.URI && bytes.Equal(v.Data, res.Data) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, res)
+		}
+	}
+	return list
+}
+
+func hasProperty(list []*Property, prop *Property) bool {
+	var s = prop.String()
+	for _, v := range list {
+		if v.String() == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vcard
+
+import (
+	"testing"
+
+	"github.com/shuLhan/share/lib/contact"
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestFromRecord(t *testing.T) {
+	var rec = &contact.Record{
+		Name: contact.Name{
+			Given:  `John`,
+			Family: `Doe`,
+		},
+		Birthday: &contact.Date{Year: `1980`, Month: `01`, Day: `30`},
+		Company:  `Example`,
+		JobTitle: `Engineer`,
+		Emails: []contact.Email{{
+			Type:    `work`,
+			Address: `john@example.com`,
+		}},
+		Phones: []contact.Phone{{
+			Type:   `cell`,
+			Number: `+1 555 0100`,
+		}},
+		Links: []string{`https://example.com`},
+		Notes: []string{`a note`},
+	}
+
+	var card = FromRecord(rec)
+
+	test.Assert(t, `Fn`, `John Doe`, card.Fn)
+	test.Assert(t, `Record`, rec, card.Record())
+
+	var (
+		raw   []byte
+		cards []*VCard
+		err   error
+	)
+	raw, err = Marshal(card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cards, err = Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Parse(Marshal).Record`, rec, cards[0].Record())
+}
+
+func TestVCard_Record_roundTrip(t *testing.T) {
+	type testCase struct {
+		rec   *contact.Record
+		desc  string
+		expFn string
+	}
+
+	var cases = []testCase{{
+		desc: `With company only`,
+		rec: &contact.Record{
+			Company: `Example`,
+			Phones: []contact.Phone{{
+				Number: `+1 555 0100`,
+			}},
+		},
+		expFn: `Example`,
+	}, {
+		desc: `With email only`,
+		rec: &contact.Record{
+			Emails: []contact.Email{{
+				Address: `john@example.com`,
+			}},
+		},
+		expFn: `john@example.com`,
+	}, {
+		desc: `With company and email`,
+		rec: &contact.Record{
+			Company: `Example`,
+			Emails: []contact.Email{{
+				Address: `john@example.com`,
+			}},
+		},
+		expFn: `Example`,
+	}}
+
+	var (
+		c     testCase
+		card  *VCard
+		raw   []byte
+		cards []*VCard
+		err   error
+	)
+	for _, c = range cases {
+		card = FromRecord(c.rec)
+
+		test.Assert(t, c.desc+`: Fn`, c.expFn, card.Fn)
+		test.Assert(t, c.desc+`: Record`, c.rec, card.Record())
+
+		raw, err = Marshal(card)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cards, err = Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Assert(t, c.desc+`: Parse(Marshal).Record`, c.rec, cards[0].Record())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	var (
+		a = &VCard{
+			Fn:    `John Doe`,
+			Email: []contact.Email{{Address: `John@Example.com`}},
+			Tel:   []contact.Phone{{Number: `+1 (555) 0100`}},
+		}
+		b = &VCard{
+			Fn:    `Johnny`,
+			Email: []contact.Email{{Address: `john@example.com`}},
+			Title: []string{`Engineer`},
+		}
+		c = &VCard{
+			Fn:  `J. Doe`,
+			Tel: []contact.Phone{{Number: `+15550100`}, {Number: `+15550199`}},
+		}
+		d = &VCard{
+			Fn:    `Jane`,
+			Email: []contact.Email{{Address: `jane@example.com`}},
+		}
+	)
+
+	var got = Merge(a, b, c, d)
+
+	var exp = []*VCard{{
+		Fn:    `John Doe`,
+		Email: []contact.Email{{Address: `John@Example.com`}},
+		Tel:   []contact.Phone{{Number: `+1 (555) 0100`}, {Number: `+15550199`}},
+		Title: []string{`Engineer`},
+	}, d}
+
+	test.Assert(t, `Merge`, exp, got)
+}
@@ -4,14 +4,33 @@
 
 // Package vcard implement RFC6350 for encoding and decoding VCard formatted
 // data.
+//
+// The [Parse] function accept vCard version 3.0 (RFC 2426) and 4.0
+// (RFC 6350), while [Marshal] write the vCard using its Version, default
+// to 4.0.
+// The property that does not have specific field in VCard, for example
+// the "X-" properties, is stored in Extensions.
+//
+// The VCard can be converted from and to [contact.Record] using
+// [FromRecord] and [VCard.Record], and the duplicate contacts can be
+// merged using [Merge].
 package vcard
 
 import (
 	"github.com/shuLhan/share/lib/contact"
 )
 
+// List of supported vCard versions.
+const (
+	Version3 = `3.0`
+	Version4 = `4.0`
+)
+
 // VCard define vcard 4.0 data structure.
 type VCard struct {
+	// Version of vCard, either "3.0" or "4.0".
+	Version string
+
 	UID          string
 	Source       []string
 	Kind         string
@@ -40,4 +59,10 @@ type VCard struct {
 	Sound        []Resource
 	ClientPIDMap string
 	Key          []Resource
+	URL          []string
+	Rev          string
+
+	// Extensions contains the properties that does not have specific
+	// field, for example "X-" properties, including their group.
+	Extensions []*Property
 }
@@ -0,0 +1,198 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vcard
+
+import (
+	"os"
+	"testing"
+
+	"github.com/shuLhan/share/lib/contact"
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestParse(t *testing.T) {
+	var (
+		raw   []byte
+		cards []*VCard
+		err   error
+	)
+
+	raw, err = os.ReadFile(`testdata/v3.vcf`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cards, err = Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `len(cards)`, 1, len(cards))
+
+	var card = cards[0]
+
+	test.Assert(t, `Version`, Version3, card.Version)
+	test.Assert(t, `Fn`, `Dr. John Q. Doe, Jr.`, card.Fn)
+	test.Assert(t, `N`, contact.Name{
+		Given:      `John`,
+		Middle:     `Q.`,
+		Family:     `Doe`,
+		Prefix:     `Dr.`,
+		Suffix:     `Jr.`,
+		GivenSound: `Jon`,
+	}, card.N)
+	test.Assert(t, `Org`, []string{`Example, Inc.`, `R&D`}, card.Org)
+	test.Assert(t, `Email`, []contact.Email{{
+		Type:    `internet,work`,
+		Address: `john@example.com`,
+	}}, card.Email)
+	test.Assert(t, `Adr`, []contact.Address{{
+		Type:        `home`,
+		Street:      `1 Main St`,
+		City:        `Springfield`,
+		StateOrProv: `IL`,
+		PostalCode:  `62701`,
+		Country:     `USA`,
+	}}, card.Adr)
+	test.Assert(t, `Bday`, contact.Date{Year: `1980`, Month: `01`, Day: `30`}, card.Bday)
+	test.Assert(t, `Geo`, []GeoLocation{{Lat: 37.386013, Long: -122.08293}}, card.Geo)
+	test.Assert(t, `Note`, []string{"First line\nSecond line; with semicolon"}, card.Note)
+	test.Assert(t, `Photo.Type`, `image/jpeg`, card.Photo[0].Type)
+	test.Assert(t, `len(Photo.Data)`, 160, len(card.Photo[0].Data))
+	test.Assert(t, `Extensions`, []*Property{{
+		Group: `item1`,
+		Name:  `X-ABADR`,
+		Value: `us`,
+	}}, card.Extensions)
+}
+
+func TestParse_error(t *testing.T) {
+	type testCase struct {
+		desc   string
+		raw    string
+		expErr string
+	}
+
+	var cases = []testCase{{
+		desc:   `without BEGIN`,
+		raw:    "FN:A\r\nEND:VCARD\r\n",
+		expErr: `Parse: line 1: property FN outside of vCard`,
+	}, {
+		desc:   `without END`,
+		raw:    "BEGIN:VCARD\r\nFN:A\r\n",
+		expErr: `Parse: missing END:VCARD`,
+	}, {
+		desc:   `nested BEGIN`,
+		raw:    "BEGIN:VCARD\nBEGIN:VCARD\n",
+		expErr: `Parse: line 2: nested BEGIN:VCARD`,
+	}, {
+		desc:   `missing colon`,
+		raw:    "BEGIN:VCARD\nFN\n",
+		expErr: `Parse: line 2: missing ':' in "FN"`,
+	}, {
+		desc:   `invalid GEO`,
+		raw:    "BEGIN:VCARD\nGEO:x\n",
+		expErr: `Parse: line 2: invalid GEO "x"`,
+	}}
+
+	var (
+		c   testCase
+		err error
+	)
+	for _, c = range cases {
+		_, err = Parse([]byte(c.raw))
+		test.Assert(t, c.desc, c.expErr, err.Error())
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	var (
+		raw   []byte
+		exp   []byte
+		got   []byte
+		cards []*VCard
+		err   error
+	)
+
+	raw, err = os.ReadFile(`testdata/v3.vcf`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, err = os.ReadFile(`testdata/v4.vcf`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cards, err = Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Convert vCard 3.0 to 4.0.
+	cards[0].Version = Version4
+	got, err = Marshal(cards...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Marshal v4`, string(exp), string(got))
+
+	// Convert it back to 3.0 and parse it again.
+	cards[0].Version = Version3
+	got, err = Marshal(cards...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reparsed []*VCard
+	reparsed, err = Parse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Parse(Marshal(v3))`, cards, reparsed)
+}
+
+func TestProperty_String(t *testing.T) {
+	type testCase struct {
+		desc string
+		prop Property
+		exp  string
+	}
+
+	var cases = []testCase{{
+		desc: `with group and params`,
+		prop: Property{
+			Group: `item1`,
+			Name:  `TEL`,
+			Params: map[string][]string{
+				ParamType:  {`cell`, `voice`},
+				ParamValue: {`a"b:c`},
+			},
+			Value: `+1 555`,
+		},
+		exp: "item1.TEL;TYPE=cell,voice;VALUE=\"a^'b:c\":+1 555\r\n",
+	}, {
+		desc: `folded with UTF-8`,
+		prop: Property{
+			Name:  `NOTE`,
+			Value: `0123456789012345678901234567890123456789012345678901234567890123456789ééé`,
+		},
+		exp: "NOTE:0123456789012345678901234567890123456789012345678901234567890123456789\r\n" +
+			" ééé\r\n",
+	}}
+
+	var (
+		c   testCase
+		got *Property
+		err error
+	)
+	for _, c = range cases {
+		test.Assert(t, c.desc, c.exp, c.prop.String())
+
+		got, err = parseProperty(unfold(c.exp)[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Assert(t, c.desc+`: parse`, &c.prop, got)
+	}
+}
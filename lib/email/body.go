@@ -103,6 +103,9 @@ func (body *Body) Add(mime *MIME) {
 // getPart get the body part by top and sub content type.
 func (body *Body) getPart(top, sub string) (mime *MIME) {
 	for _, mime = range body.Parts {
+		if mime.isAttached() {
+			continue
+		}
 		if !strings.EqualFold(mime.contentType.Top, top) {
 			continue
 		}
@@ -121,6 +124,9 @@ func (body *Body) Set(mime *MIME) {
 		part *MIME
 	)
 	for _, part = range body.Parts {
+		if part.isAttached() {
+			continue
+		}
 		if part.contentType.isEqual(mime.contentType) {
 			part.Header = mime.Header
 			part.Content = mime.Content
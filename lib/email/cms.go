@@ -0,0 +1,593 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// List of object identifiers used in Cryptographic Message Syntax (CMS),
+// RFC 5652.
+var (
+	oidCMSData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidCMSSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidCMSEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+
+	oidAttrContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttrSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidDigestSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDigestSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidDigestSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+
+	oidAES128CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// ErrCMSUnsupported define an error when the CMS content use algorithm or
+// structure that is not supported.
+var ErrCMSUnsupported = errors.New(`unsupported CMS content`)
+
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+
+	// Content is the explicitly tagged [0] content.
+	Content asn1.RawValue
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo cmsEncapContentInfo
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos      []cmsSignerInfo `asn1:"set"`
+}
+
+// cmsEncapContentInfo define the signed content.
+// The EContent is empty if the signature is detached.
+type cmsEncapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"optional,explicit,tag:0"`
+}
+
+type cmsIssuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                cmsIssuerAndSerial
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type cmsEnvelopedData struct {
+	Version              int
+	RecipientInfos       []cmsKeyTransRecipientInfo `asn1:"set"`
+	EncryptedContentInfo cmsEncryptedContentInfo
+}
+
+type cmsKeyTransRecipientInfo struct {
+	Version                int
+	RID                    cmsIssuerAndSerial
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type cmsEncryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"optional,tag:0"`
+}
+
+// cmsSign create the detached CMS SignedData of content, signed by pk
+// using SHA-256.
+// The signer certificate is included in the SignedData.
+func cmsSign(content []byte, cert *x509.Certificate, pk crypto.Signer) (der []byte, err error) {
+	var sigAlg pkix.AlgorithmIdentifier
+
+	switch pk.Public().(type) {
+	case *rsa.PublicKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}
+	case *ecdsa.PublicKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+	default:
+		return nil, fmt.Errorf(`%w: key type %T`, ErrCMSUnsupported, pk.Public())
+	}
+
+	var digest = crypto.SHA256.New()
+	digest.Write(content)
+
+	var attrs = []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{
+		{oidAttrContentType, oidCMSData},
+		{oidAttrSigningTime, time.Now().UTC()},
+		{oidAttrMessageDigest, digest.Sum(nil)},
+	}
+
+	var rawAttrs [][]byte
+	for _, attr := range attrs {
+		var value, errMarshal = asn1.Marshal(attr.value)
+		if errMarshal != nil {
+			return nil, errMarshal
+		}
+		var raw []byte
+		raw, err = asn1.Marshal(cmsAttribute{
+			Type: attr.oid,
+			Values: asn1.RawValue{
+				Tag:        asn1.TagSet,
+				IsCompound: true,
+				Bytes:      value,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		rawAttrs = append(rawAttrs, raw)
+	}
+
+	// The DER encoding of SET OF must be sorted.
+	sort.Slice(rawAttrs, func(x, y int) bool {
+		return bytes.Compare(rawAttrs[x], rawAttrs[y]) < 0
+	})
+	var signedAttrs = bytes.Join(rawAttrs, nil)
+
+	// The signature is computed over the DER encoding of signed
+	// attributes with SET tag.
+	var toSign []byte
+	toSign, err = asn1.Marshal(asn1.RawValue{
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      signedAttrs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	digest.Reset()
+	digest.Write(toSign)
+
+	var sig []byte
+	sig, err = pk.Sign(rand.Reader, digest.Sum(nil), crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		digestAlg = pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256}
+		sd        = cmsSignedData{
+			Version:          1,
+			DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlg},
+			EncapContentInfo: cmsEncapContentInfo{
+				EContentType: oidCMSData,
+			},
+			Certificates: asn1.RawValue{
+				Class:      asn1.ClassContextSpecific,
+				Tag:        0,
+				IsCompound: true,
+				Bytes:      cert.Raw,
+			},
+			SignerInfos: []cmsSignerInfo{{
+				Version: 1,
+				SID: cmsIssuerAndSerial{
+					Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+					SerialNumber: cert.SerialNumber,
+				},
+				DigestAlgorithm: digestAlg,
+				SignedAttrs: asn1.RawValue{
+					Class:      asn1.ClassContextSpecific,
+					Tag:        0,
+					IsCompound: true,
+					Bytes:      signedAttrs,
+				},
+				SignatureAlgorithm: sigAlg,
+				Signature:          sig,
+			}},
+		}
+	)
+
+	return marshalContentInfo(oidCMSSignedData, sd)
+}
+
+// cmsVerify verify the detached CMS SignedData of content.
+// It return the certificate of the first signer.
+// If roots is not nil, the signer certificate is verified against it.
+func cmsVerify(der, content []byte, roots *x509.CertPool) (signer *x509.Certificate, err error) {
+	var sd cmsSignedData
+
+	err = unmarshalContentInfo(der, oidCMSSignedData, &sd)
+	if err != nil {
+		return nil, err
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, fmt.Errorf(`%w: empty signer infos`, ErrCMSUnsupported)
+	}
+	if len(sd.EncapContentInfo.EContent.Bytes) > 0 {
+		// The content should be detached.
+		// Accept the encapsulated content only if its equal to the
+		// content, so the signature of other content cannot be
+		// reused.
+		var econtent []byte
+
+		_, err = asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &econtent)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(econtent, content) {
+			return nil, errors.New(`encapsulated content mismatch`)
+		}
+	}
+
+	var certs []*x509.Certificate
+	if len(sd.Certificates.Bytes) > 0 {
+		certs, err = x509.ParseCertificates(sd.Certificates.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var si = sd.SignerInfos[0]
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, si.SID.Issuer.FullBytes) &&
+			cert.SerialNumber.Cmp(si.SID.SerialNumber) == 0 {
+			signer = cert
+			break
+		}
+	}
+	if signer == nil {
+		return nil, errors.New(`signer certificate not found`)
+	}
+
+	var hash crypto.Hash
+
+	hash, err = cmsDigestHash(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var h = hash.New()
+	h.Write(content)
+	var contentDigest = h.Sum(nil)
+
+	var signed = content
+	if len(si.SignedAttrs.Bytes) > 0 {
+		var msgDigest []byte
+
+		msgDigest, err = cmsAttrMessageDigest(si.SignedAttrs.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(msgDigest, contentDigest) {
+			return nil, errors.New(`message digest mismatch`)
+		}
+
+		// Replace the IMPLICIT [0] tag with SET tag.
+		signed = append([]byte{}, si.SignedAttrs.FullBytes...)
+		signed[0] = 0x31
+	}
+
+	var sigAlg x509.SignatureAlgorithm
+
+	sigAlg, err = cmsSignatureAlgorithm(signer.PublicKeyAlgorithm, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	err = signer.CheckSignature(sigAlg, signed, si.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	if roots == nil {
+		return signer, nil
+	}
+
+	var opts = x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, cert := range certs {
+		if cert != signer {
+			opts.Intermediates.AddCert(cert)
+		}
+	}
+	_, err = signer.Verify(opts)
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// cmsEncrypt create the CMS EnvelopedData of content for each recipient,
+// using AES-256-CBC for content encryption and RSA PKCS #1 v1.5 for key
+// transport.
+func cmsEncrypt(content []byte, recipients []*x509.Certificate) (der []byte, err error) {
+	var (
+		key = make([]byte, 32)
+		iv  = make([]byte, aes.BlockSize)
+	)
+	_, err = rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	_, err = rand.Read(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	var block cipher.Block
+
+	block, err = aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// PKCS #7 padding.
+	var (
+		npad      = aes.BlockSize - len(content)%aes.BlockSize
+		plain     = append(append([]byte{}, content...), bytes.Repeat([]byte{byte(npad)}, npad)...)
+		encrypted = make([]byte, len(plain))
+	)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, plain)
+
+	var ivParam []byte
+	ivParam, err = asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	var ed = cmsEnvelopedData{
+		EncryptedContentInfo: cmsEncryptedContentInfo{
+			ContentType: oidCMSData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  oidAES256CBC,
+				Parameters: asn1.RawValue{FullBytes: ivParam},
+			},
+			EncryptedContent: encrypted,
+		},
+	}
+
+	for _, cert := range recipients {
+		var pub, ok = cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf(`%w: recipient key type %T`, ErrCMSUnsupported, cert.PublicKey)
+		}
+
+		var encKey []byte
+		encKey, err = rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+		if err != nil {
+			return nil, err
+		}
+
+		ed.RecipientInfos = append(ed.RecipientInfos, cmsKeyTransRecipientInfo{
+			RID: cmsIssuerAndSerial{
+				Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+				SerialNumber: cert.SerialNumber,
+			},
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  oidRSAEncryption,
+				Parameters: asn1.NullRawValue,
+			},
+			EncryptedKey: encKey,
+		})
+	}
+
+	return marshalContentInfo(oidCMSEnvelopedData, ed)
+}
+
+// cmsDecrypt decrypt the CMS EnvelopedData for recipient cert using its
+// private key pk.
+func cmsDecrypt(der []byte, cert *x509.Certificate, pk crypto.Decrypter) (content []byte, err error) {
+	var ed cmsEnvelopedData
+
+	err = unmarshalContentInfo(der, oidCMSEnvelopedData, &ed)
+	if err != nil {
+		return nil, err
+	}
+
+	var ri *cmsKeyTransRecipientInfo
+	for x, info := range ed.RecipientInfos {
+		if bytes.Equal(info.RID.Issuer.FullBytes, cert.RawIssuer) &&
+			info.RID.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			ri = &ed.RecipientInfos[x]
+			break
+		}
+	}
+	if ri == nil {
+		return nil, errors.New(`no recipient info for certificate`)
+	}
+	if !ri.KeyEncryptionAlgorithm.Algorithm.Equal(oidRSAEncryption) {
+		return nil, fmt.Errorf(`%w: key encryption %s`, ErrCMSUnsupported, ri.KeyEncryptionAlgorithm.Algorithm)
+	}
+
+	var key []byte
+
+	key, err = pk.Decrypt(rand.Reader, ri.EncryptedKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		eci    = ed.EncryptedContentInfo
+		alg    = eci.ContentEncryptionAlgorithm.Algorithm
+		keyLen int
+	)
+	switch {
+	case alg.Equal(oidAES128CBC):
+		keyLen = 16
+	case alg.Equal(oidAES192CBC):
+		keyLen = 24
+	case alg.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf(`%w: content encryption %s`, ErrCMSUnsupported, alg)
+	}
+	if len(key) != keyLen {
+		return nil, errors.New(`invalid content encryption key`)
+	}
+
+	var iv []byte
+	_, err = asn1.Unmarshal(eci.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize || len(eci.EncryptedContent)%aes.BlockSize != 0 ||
+		len(eci.EncryptedContent) == 0 {
+		return nil, errors.New(`invalid encrypted content`)
+	}
+
+	var block cipher.Block
+
+	block, err = aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	content = make([]byte, len(eci.EncryptedContent))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(content, eci.EncryptedContent)
+
+	var npad = int(content[len(content)-1])
+	if npad == 0 || npad > aes.BlockSize {
+		return nil, errors.New(`invalid padding`)
+	}
+	for _, c := range content[len(content)-npad:] {
+		if int(c) != npad {
+			return nil, errors.New(`invalid padding`)
+		}
+	}
+	return content[:len(content)-npad], nil
+}
+
+func marshalContentInfo(oid asn1.ObjectIdentifier, content interface{}) (der []byte, err error) {
+	var inner []byte
+
+	inner, err = asn1.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(cmsContentInfo{
+		ContentType: oid,
+		Content: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      inner,
+		},
+	})
+}
+
+func unmarshalContentInfo(der []byte, oid asn1.ObjectIdentifier, content interface{}) (err error) {
+	var ci cmsContentInfo
+
+	_, err = asn1.Unmarshal(der, &ci)
+	if err != nil {
+		return err
+	}
+	if ci.Content.Class != asn1.ClassContextSpecific || ci.Content.Tag != 0 {
+		return fmt.Errorf(`%w: invalid content tag`, ErrCMSUnsupported)
+	}
+	if !ci.ContentType.Equal(oid) {
+		return fmt.Errorf(`%w: content type %s`, ErrCMSUnsupported, ci.ContentType)
+	}
+	_, err = asn1.Unmarshal(ci.Content.Bytes, content)
+	return err
+}
+
+// cmsAttrMessageDigest return the value of message-digest attribute from
+// signed attributes.
+func cmsAttrMessageDigest(raw []byte) (digest []byte, err error) {
+	var attr cmsAttribute
+	for len(raw) > 0 {
+		raw, err = asn1.Unmarshal(raw, &attr)
+		if err != nil {
+			return nil, err
+		}
+		if !attr.Type.Equal(oidAttrMessageDigest) {
+			continue
+		}
+		_, err = asn1.Unmarshal(attr.Values.Bytes, &digest)
+		if err != nil {
+			return nil, err
+		}
+		return digest, nil
+	}
+	return nil, errors.New(`missing message-digest attribute`)
+}
+
+func cmsDigestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidDigestSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidDigestSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidDigestSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidDigestSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf(`%w: digest algorithm %s`, ErrCMSUnsupported, oid)
+}
+
+func cmsSignatureAlgorithm(pubAlg x509.PublicKeyAlgorithm, hash crypto.Hash) (x509.SignatureAlgorithm, error) {
+	switch pubAlg {
+	case x509.RSA:
+		switch hash {
+		case crypto.SHA1:
+			return x509.SHA1WithRSA, nil
+		case crypto.SHA256:
+			return x509.SHA256WithRSA, nil
+		case crypto.SHA384:
+			return x509.SHA384WithRSA, nil
+		case crypto.SHA512:
+			return x509.SHA512WithRSA, nil
+		}
+	case x509.ECDSA:
+		switch hash {
+		case crypto.SHA1:
+			return x509.ECDSAWithSHA1, nil
+		case crypto.SHA256:
+			return x509.ECDSAWithSHA256, nil
+		case crypto.SHA384:
+			return x509.ECDSAWithSHA384, nil
+		case crypto.SHA512:
+			return x509.ECDSAWithSHA512, nil
+		}
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf(`%w: signature algorithm %s with %s`,
+		ErrCMSUnsupported, pubAlg, hash)
+}
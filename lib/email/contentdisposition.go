@@ -0,0 +1,85 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	libbytes "github.com/shuLhan/share/lib/bytes"
+)
+
+// List of disposition type, RFC 2183 section 2.
+const (
+	DispositionAttachment = `attachment`
+	DispositionInline     = `inline`
+)
+
+// ContentDisposition represent MIME header "Content-Disposition" field, as
+// defined in RFC 2183.
+type ContentDisposition struct {
+	// Type of disposition, either "inline" or "attachment".
+	Type   string
+	Params []Param
+}
+
+// ParseContentDisposition parse the Content-Disposition from raw bytes.
+func ParseContentDisposition(raw []byte) (cd *ContentDisposition, err error) {
+	var (
+		logp   = `ParseContentDisposition`
+		parser = libbytes.NewParser(bytes.TrimSpace(raw), []byte{';'})
+
+		tok []byte
+		c   byte
+	)
+
+	tok, c = parser.Read()
+	tok = bytes.TrimSpace(tok)
+	if !isValidToken(tok, false) {
+		return nil, fmt.Errorf(`%s: invalid type '%s'`, logp, tok)
+	}
+
+	cd = &ContentDisposition{
+		Type: strings.ToLower(string(tok)),
+	}
+	if c == 0 {
+		return cd, nil
+	}
+
+	cd.Params, err = parseParams(parser, c)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	return cd, nil
+}
+
+// Filename return the decoded value of parameter "filename".
+func (cd *ContentDisposition) Filename() string {
+	return paramValue(cd.Params, ParamNameFilename)
+}
+
+// String return text representation of content disposition with its
+// parameters.
+func (cd *ContentDisposition) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(cd.Type)
+	for _, p := range cd.Params {
+		sb.WriteString(`; `)
+		sb.WriteString(p.Key)
+		sb.WriteByte('=')
+		if p.Quoted {
+			sb.WriteByte('"')
+		}
+		sb.WriteString(p.Value)
+		if p.Quoted {
+			sb.WriteByte('"')
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,113 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestParseContentDisposition(t *testing.T) {
+	type testCase struct {
+		desc        string
+		in          string
+		expErr      string
+		expType     string
+		expFilename string
+	}
+
+	var cases = []testCase{{
+		desc:   `With empty input`,
+		expErr: `ParseContentDisposition: invalid type ''`,
+	}, {
+		desc:    `Without parameter`,
+		in:      `Inline`,
+		expType: DispositionInline,
+	}, {
+		desc:        `With quoted filename`,
+		in:          `attachment; filename="report 2024.pdf"`,
+		expType:     DispositionAttachment,
+		expFilename: `report 2024.pdf`,
+	}, {
+		desc:        `With extended filename`,
+		in:          `attachment; filename*=UTF-8''%E2%82%AC%20rates.txt`,
+		expType:     DispositionAttachment,
+		expFilename: `€ rates.txt`,
+	}, {
+		desc:        `With ISO-8859-1 filename`,
+		in:          `attachment; filename*=iso-8859-1'en'caf%E9.txt`,
+		expType:     DispositionAttachment,
+		expFilename: `café.txt`,
+	}, {
+		desc: `With continuations`,
+		in: `attachment; filename*0*=UTF-8''%E2%82%AC%20long; ` +
+			`filename*1=" file"; filename*2*=.txt`,
+		expType:     DispositionAttachment,
+		expFilename: `€ long file.txt`,
+	}, {
+		desc:   `With invalid parameter`,
+		in:     `attachment; filename="a.txt`,
+		expErr: `ParseContentDisposition: missing closing quote`,
+	}}
+
+	var (
+		c   testCase
+		cd  *ContentDisposition
+		err error
+	)
+	for _, c = range cases {
+		cd, err = ParseContentDisposition([]byte(c.in))
+		if err != nil {
+			test.Assert(t, c.desc, c.expErr, err.Error())
+			continue
+		}
+
+		test.Assert(t, c.desc+`: Type`, c.expType, cd.Type)
+		test.Assert(t, c.desc+`: Filename`, c.expFilename, cd.Filename())
+	}
+}
+
+func TestContentDisposition_String(t *testing.T) {
+	type testCase struct {
+		desc     string
+		filename string
+		exp      string
+	}
+
+	var cases = []testCase{{
+		desc:     `With ASCII filename`,
+		filename: `report 2024.pdf`,
+		exp:      `attachment; filename="report 2024.pdf"`,
+	}, {
+		desc:     `With UTF-8 filename`,
+		filename: `€ rates.txt`,
+		exp:      `attachment; filename*=UTF-8''%E2%82%AC%20rates.txt`,
+	}, {
+		desc:     `With double quote`,
+		filename: `a"b.txt`,
+		exp:      `attachment; filename*=UTF-8''a%22b.txt`,
+	}}
+
+	var (
+		c   testCase
+		cd  *ContentDisposition
+		got *ContentDisposition
+		err error
+	)
+	for _, c = range cases {
+		cd = &ContentDisposition{
+			Type:   DispositionAttachment,
+			Params: []Param{newParam(ParamNameFilename, c.filename)},
+		}
+		test.Assert(t, c.desc, c.exp, cd.String())
+
+		got, err = ParseContentDisposition([]byte(cd.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Assert(t, c.desc+`: Filename`, c.filename, got.Filename())
+	}
+}
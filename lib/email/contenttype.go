@@ -13,9 +13,10 @@ import (
 )
 
 var (
-	topText  = `text`
-	subPlain = `plain`
-	subHTML  = `html`
+	topMultipart = `multipart`
+	topText      = `text`
+	subPlain     = `plain`
+	subHTML      = `html`
 )
 
 // ContentType represent MIME header "Content-Type" field.
@@ -68,50 +69,9 @@ func ParseContentType(raw []byte) (ct *ContentType, err error) {
 		return nil, fmt.Errorf(`%s: invalid character '%c'`, logp, c)
 	}
 
-	parser.SetDelimiters([]byte{'=', '"', ';'})
-	for c == ';' {
-		param := Param{}
-
-		tok, c = parser.ReadNoSpace()
-		if c == 0 {
-			// Ignore key without value.
-			param.Key = string(tok)
-			break
-		}
-
-		if !isValidToken(tok, false) {
-			return nil, fmt.Errorf(`%s: invalid parameter key '%s'`, logp, tok)
-		}
-		if c != '=' {
-			return nil, fmt.Errorf(`%s: expecting '=', got '%c'`, logp, c)
-		}
-		param.Key = string(tok)
-
-		tok, c = parser.ReadNoSpace()
-		if c == '"' {
-			if len(tok) != 0 {
-				return nil, fmt.Errorf(`%s: invalid parameter value '%s'`, logp, tok)
-			}
-
-			// The param value may contain '=' or ';', remove it
-			// temporarily.
-			parser.RemoveDelimiters([]byte{'=', ';'})
-
-			tok, c = parser.Read()
-			if c != '"' {
-				return nil, fmt.Errorf(`%s: missing closing quote`, logp)
-			}
-			param.Quoted = true
-
-			parser.AddDelimiters([]byte{'=', ';'})
-
-			c = parser.Skip()
-		}
-		if !isValidToken(tok, param.Quoted) {
-			return nil, fmt.Errorf(`%s: invalid parameter value '%s'`, logp, tok)
-		}
-		param.Value = string(tok)
-		ct.Params = append(ct.Params, param)
+	ct.Params, err = parseParams(parser, c)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
 	}
 
 	return ct, nil
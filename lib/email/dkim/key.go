@@ -6,6 +6,7 @@ package dkim
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
@@ -20,9 +21,12 @@ type Key struct {
 	// ("k=", plain-text, OPTIONAL, default is "rsa").
 	Type *KeyType
 
-	// RSA contains parsed Public key.
+	// RSA contains parsed Public key with type "rsa".
 	RSA *rsa.PublicKey
 
+	// Ed25519 contains parsed Public key with type "ed25519".
+	Ed25519 ed25519.PublicKey
+
 	// Public contains public key data.
 	// ("p=", base64, REQUIRED)
 	Public []byte
@@ -91,6 +95,11 @@ func ParseTXT(txt []byte, ttl uint32) (key *Key, err error) {
 		}
 	}
 
+	err = key.parsePublic()
+	if err != nil {
+		return nil, err
+	}
+
 	key.ExpiredAt = time.Now().Unix() + int64(ttl)
 
 	return key, nil
@@ -157,6 +166,56 @@ func (key *Key) Pack() string {
 	return bb.String()
 }
 
+// parsePublic parse the public key data ("p=") based on the key type
+// ("k=").
+// The "rsa" key is encoded using DER SubjectPublicKeyInfo, while the
+// "ed25519" key is the raw 32 octets public key, RFC 8463 section 4.2.
+func (key *Key) parsePublic() (err error) {
+	if len(key.Public) == 0 {
+		return nil
+	}
+
+	var pkey []byte
+
+	pkey, err = decodePublic(key.Public)
+	if err != nil {
+		return err
+	}
+
+	if key.Type != nil && *key.Type == KeyTypeEd25519 {
+		if len(pkey) != ed25519.PublicKeySize {
+			return fmt.Errorf("dkim: invalid ed25519 public key size %d", len(pkey))
+		}
+		key.Ed25519 = ed25519.PublicKey(pkey)
+		return nil
+	}
+
+	pk, err := x509.ParsePKIXPublicKey(pkey)
+	if err != nil {
+		return fmt.Errorf("dkim: error parsing public key: %w", err)
+	}
+
+	var ok bool
+
+	key.RSA, ok = pk.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("dkim: expecting RSA public key, got %T", pk)
+	}
+	return nil
+}
+
+// decodePublic decode the base64 public key data, with or without
+// padding.
+func decodePublic(v []byte) (pkey []byte, err error) {
+	v = bytes.TrimRight(v, "=")
+
+	pkey, err = base64.RawStdEncoding.DecodeString(string(v))
+	if err != nil {
+		return nil, fmt.Errorf("dkim: error decode public key: %w", err)
+	}
+	return pkey, nil
+}
+
 // IsExpired will return true if key ExpiredAt time is less than current time;
 // otherwise it will return false.
 func (key *Key) IsExpired() bool {
@@ -169,18 +228,10 @@ func (key *Key) set(t *tag) (err error) {
 	}
 	switch t.key {
 	case tagDNSPublicKey:
-		pkey, err := base64.RawStdEncoding.DecodeString(string(t.value))
+		_, err = decodePublic(t.value)
 		if err != nil {
-			err = fmt.Errorf("dkim: error decode public key: %w", err)
 			return err
 		}
-		pk, err := x509.ParsePKIXPublicKey(pkey)
-		if err != nil {
-			err = fmt.Errorf("dkim: error parsing public key: %w", err)
-			return err
-		}
-
-		key.RSA = pk.(*rsa.PublicKey)
 		key.Public = t.value
 
 	case tagDNSVersion:
@@ -11,13 +11,34 @@ import (
 	"sync"
 )
 
+// KeyLookupFunc define a function to lookup the DKIM public key by its
+// domain name ("s=" value + "._domainkey." + "d=" value).
+type KeyLookupFunc func(dname string) (key *Key, err error)
+
 // KeyPool maintain cached DKIM public keys.
 type KeyPool struct {
 	pool map[string]*Key
 
+	// lookup define the function to lookup the key if its not exist in
+	// the pool.
+	// If its nil, the key will be looked up using DNS/TXT method.
+	lookup KeyLookupFunc
+
 	sync.Mutex
 }
 
+// NewKeyPool create new, empty, key pool that use the lookup function to
+// retrieve the key that does not exist in the pool.
+// If lookup is nil, the key will be looked up using DNS/TXT method, the
+// same as DefaultKeyPool.
+func NewKeyPool(lookup KeyLookupFunc) (kp *KeyPool) {
+	kp = &KeyPool{
+		pool:   make(map[string]*Key),
+		lookup: lookup,
+	}
+	return kp
+}
+
 // Clear the contents of key pool.
 func (kp *KeyPool) Clear() {
 	kp.Lock()
@@ -43,7 +64,11 @@ func (kp *KeyPool) Get(dname string) (key *Key, err error) {
 	}
 	kp.Unlock()
 
-	key, err = LookupKey(QueryMethod{}, dname)
+	if kp.lookup != nil {
+		key, err = kp.lookup(dname)
+	} else {
+		key, err = LookupKey(QueryMethod{}, dname)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -7,6 +7,7 @@ package dkim
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/shuLhan/share/lib/test"
 )
@@ -107,3 +108,27 @@ func TestKeyPoolGet(t *testing.T) {
 		test.Assert(t, "DefaultKeyPool.Get", c.exp, got)
 	}
 }
+
+func TestNewKeyPool(t *testing.T) {
+	var (
+		nlookup int
+		kp      = NewKeyPool(func(dname string) (*Key, error) {
+			nlookup++
+			var key = &Key{
+				ExpiredAt: time.Now().Add(time.Hour).Unix(),
+			}
+			return key, nil
+		})
+		dname = `s._domainkey.example.com`
+	)
+
+	for x := 0; x < 2; x++ {
+		key, err := kp.Get(dname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Assert(t, `key is not nil`, true, key != nil)
+	}
+	test.Assert(t, `number of lookup`, 1, nlookup)
+	test.Assert(t, `KeyPool`, 1, len(kp.pool))
+}
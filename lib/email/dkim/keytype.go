@@ -13,13 +13,15 @@ type KeyType byte
 
 // List of valid key types.
 const (
-	KeyTypeRSA KeyType = iota // "rsa" (default)
+	KeyTypeRSA     KeyType = iota // "rsa" (default)
+	KeyTypeEd25519                // "ed25519", RFC 8463
 )
 
 // keyTypeNames contains mapping between key type and their text
 // representation.
 var keyTypeNames = map[KeyType][]byte{
-	KeyTypeRSA: []byte("rsa"),
+	KeyTypeRSA:     []byte("rsa"),
+	KeyTypeEd25519: []byte("ed25519"),
 }
 
 func parseKeyType(in []byte) (t *KeyType) {
@@ -9,12 +9,14 @@ type SignAlg byte
 
 // List of valid and known signing/verifying algorithms.
 const (
-	SignAlgRS256 SignAlg = iota // rsa-sha256 (default)
-	SignAlgRS1                  // rsa-sha1
+	SignAlgRS256   SignAlg = iota // rsa-sha256 (default)
+	SignAlgRS1                    // rsa-sha1
+	SignAlgED25519                // ed25519-sha256, RFC 8463
 )
 
 // signAlgNames contains mapping between known algorithm type and their names.
 var signAlgNames = map[SignAlg][]byte{
-	SignAlgRS256: []byte("rsa-sha256"),
-	SignAlgRS1:   []byte("rsa-sha1"),
+	SignAlgRS256:   []byte("rsa-sha256"),
+	SignAlgRS1:     []byte("rsa-sha1"),
+	SignAlgED25519: []byte("ed25519-sha256"),
 }
@@ -7,6 +7,7 @@ package dkim
 import (
 	"bytes"
 	"crypto"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1"
@@ -22,7 +23,7 @@ import (
 // Signature represents the value of DKIM-Signature header field tag.
 type Signature struct {
 	// Algorithm used to generate the signature.
-	// Valid values is "rsa-sha1" or "rsa-sha256".
+	// Valid values is "rsa-sha1", "rsa-sha256", or "ed25519-sha256".
 	// ("a=", text, REQUIRED).
 	Alg *SignAlg
 
@@ -150,12 +151,12 @@ func NewSignature(sdid, selector []byte) (sig *Signature) {
 // Hash compute the hash of input using the defined signature algorithm and
 // return their binary and base64 representation.
 func (sig *Signature) Hash(in []byte) (h, h64 []byte) {
-	if sig.Alg == nil || *sig.Alg == SignAlgRS256 {
-		h256 := sha256.Sum256(in)
-		h = h256[:]
-	} else {
+	if sig.Alg != nil && *sig.Alg == SignAlgRS1 {
 		h1 := sha1.Sum(in)
 		h = h1[:]
+	} else {
+		h256 := sha256.Sum256(in)
+		h = h256[:]
 	}
 
 	h64 = make([]byte, base64.StdEncoding.EncodedLen(len(h)))
@@ -229,10 +230,16 @@ func (sig *Signature) Pack(simple bool) []byte {
 			queryTypeNames[sig.QMethod.Type],
 			queryOptionNames[sig.QMethod.Option])
 	}
-	bb.WriteByte('\r')
-	bb.WriteByte('\n')
 
-	return bb.Bytes()
+	var out = bb.Bytes()
+	if simple {
+		// Remove trailing folding white spaces, to prevent
+		// folded line with empty content.
+		out = bytes.TrimRight(out, " \r\n")
+	}
+	out = append(out, '\r', '\n')
+
+	return out
 }
 
 func wrap(bb *bytes.Buffer, simple bool) {
@@ -267,21 +274,50 @@ func (sig *Signature) SetDefault() {
 
 // Sign compute the signature of message hash header using specific private
 // key and store the base64 result in Signature.Value ("b=").
-func (sig *Signature) Sign(pk *rsa.PrivateKey, hashHeader []byte) (err error) {
+//
+// The private key must be *rsa.PrivateKey for algorithm "rsa-sha1" and
+// "rsa-sha256", or ed25519.PrivateKey for algorithm "ed25519-sha256".
+func (sig *Signature) Sign(pk crypto.Signer, hashHeader []byte) (err error) {
 	if pk == nil {
 		return fmt.Errorf("email/dkim: empty private key for signing")
 	}
 
-	cryptoHash := crypto.SHA256
-	if sig.Alg != nil && *sig.Alg == SignAlgRS1 {
-		cryptoHash = crypto.SHA1
-	}
+	var b []byte
 
-	rng := rand.Reader
-	b, err := rsa.SignPKCS1v15(rng, pk, cryptoHash, hashHeader)
-	if err != nil {
-		err = fmt.Errorf("email/dkim: failed to sign message: %w", err)
-		return err
+	switch key := pk.(type) {
+	case *rsa.PrivateKey:
+		if key == nil {
+			return fmt.Errorf("email/dkim: empty private key for signing")
+		}
+		if sig.Alg != nil && *sig.Alg == SignAlgED25519 {
+			return fmt.Errorf("email/dkim: RSA key for algorithm %s", signAlgNames[*sig.Alg])
+		}
+
+		cryptoHash := crypto.SHA256
+		if sig.Alg != nil && *sig.Alg == SignAlgRS1 {
+			cryptoHash = crypto.SHA1
+		}
+
+		b, err = rsa.SignPKCS1v15(rand.Reader, key, cryptoHash, hashHeader)
+		if err != nil {
+			return fmt.Errorf("email/dkim: failed to sign message: %w", err)
+		}
+
+	case ed25519.PrivateKey:
+		if len(key) != ed25519.PrivateKeySize {
+			return fmt.Errorf("email/dkim: invalid ed25519 private key")
+		}
+		if sig.Alg == nil || *sig.Alg != SignAlgED25519 {
+			return fmt.Errorf("email/dkim: ed25519 key require algorithm %s",
+				signAlgNames[SignAlgED25519])
+		}
+
+		// The Ed25519 sign the SHA-256 hash of header, RFC 8463
+		// section 3.
+		b = ed25519.Sign(key, hashHeader)
+
+	default:
+		return fmt.Errorf("email/dkim: unsupported private key %T", pk)
 	}
 
 	sig.Value = make([]byte, base64.StdEncoding.EncodedLen(len(b)))
@@ -362,9 +398,6 @@ func (sig *Signature) Verify(key *Key, headerHash []byte) (err error) {
 	if key == nil {
 		return fmt.Errorf("email/dkim: key record is empty")
 	}
-	if key.RSA == nil {
-		return fmt.Errorf("email/dkim: public key is empty")
-	}
 
 	sigValue := make([]byte, base64.StdEncoding.DecodedLen(len(sig.Value)))
 	n, err := base64.StdEncoding.Decode(sigValue, sig.Value)
@@ -373,6 +406,19 @@ func (sig *Signature) Verify(key *Key, headerHash []byte) (err error) {
 	}
 	sigValue = sigValue[:n]
 
+	if sig.Alg != nil && *sig.Alg == SignAlgED25519 {
+		if key.Ed25519 == nil {
+			return fmt.Errorf("email/dkim: public key is empty")
+		}
+		if !ed25519.Verify(key.Ed25519, headerHash, sigValue) {
+			return fmt.Errorf("email/dkim: verification failed: invalid ed25519 signature")
+		}
+		return nil
+	}
+	if key.RSA == nil {
+		return fmt.Errorf("email/dkim: public key is empty")
+	}
+
 	cryptoHash := crypto.SHA256
 	if sig.Alg != nil && *sig.Alg == SignAlgRS1 {
 		cryptoHash = crypto.SHA1
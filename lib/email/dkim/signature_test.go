@@ -5,7 +5,10 @@
 package dkim
 
 import (
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
+	"encoding/base64"
 	"testing"
 
 	"github.com/shuLhan/share/lib/test"
@@ -583,7 +586,7 @@ func TestSignatureSet(t *testing.T) {
 	}{{
 		desc: "With empty input",
 		expSimple: "v=; a=rsa-sha256; d=; s=;\r\n " +
-			"h=;\r\n bh=;\r\n b=;\r\n \r\n",
+			"h=;\r\n bh=;\r\n b=;\r\n",
 	}}
 
 	for _, c := range cases {
@@ -601,3 +604,48 @@ func TestSignatureSet(t *testing.T) {
 		test.Assert(t, "Signature", c.expSimple, string(got))
 	}
 }
+
+func TestSignature_ed25519(t *testing.T) {
+	// The private and public keys from RFC 8463 appendix A.
+	var (
+		seed, _ = base64.StdEncoding.DecodeString(`nWGxne/9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A=`)
+		pk      = ed25519.NewKeyFromSeed(seed)
+		txt     = `v=DKIM1; k=ed25519; p=11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=`
+		sig     = NewSignature([]byte(`football.example.com`), []byte(`brisbane`))
+		signAlg = SignAlgED25519
+
+		key *Key
+		err error
+	)
+
+	key, err = ParseTXT([]byte(txt), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `public key`, pk.Public(), crypto.PublicKey(key.Ed25519))
+
+	sig.Alg = &signAlg
+
+	var hashed, _ = sig.Hash([]byte(`header`))
+
+	err = sig.Sign(privateKey, hashed)
+	if err == nil {
+		t.Fatal(`expecting error when signing ed25519 with RSA key`)
+	}
+
+	err = sig.Sign(pk, hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sig.Verify(key, hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashed, _ = sig.Hash([]byte(`modified header`))
+
+	err = sig.Verify(key, hashed)
+	test.Assert(t, `Verify modified`,
+		`email/dkim: verification failed: invalid ed25519 signature`, err.Error())
+}
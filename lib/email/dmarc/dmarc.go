@@ -0,0 +1,226 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dmarc implement the policy evaluation of Domain-based Message
+// Authentication, Reporting, and Conformance (DMARC), as defined in
+// RFC 7489.
+//
+// This package does not verify the SPF and DKIM by itself.
+// The caller should evaluate both of them first, and pass the results
+// into [Evaluate].
+//
+// The Organizational Domain is derived using simple heuristic: the last
+// two labels of domain name, instead of using the Public Suffix List.
+package dmarc
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// List of result code of DMARC evaluation.
+const (
+	// ResultCodeNone means the author domain does not publish DMARC
+	// record.
+	ResultCodeNone byte = iota
+
+	// ResultCodePass means the message pass the SPF or DKIM check
+	// and its identifier aligned with the author domain.
+	ResultCodePass
+
+	// ResultCodeFail means the message does not pass the aligned SPF
+	// or DKIM check.
+	ResultCodeFail
+
+	// ResultCodeTempError means there is transient error when looking
+	// up the DMARC record.
+	ResultCodeTempError
+
+	// ResultCodePermError means the DMARC record could not be parsed.
+	ResultCodePermError
+)
+
+var resultCodeNames = map[byte]string{
+	ResultCodeNone:      `none`,
+	ResultCodePass:      `pass`,
+	ResultCodeFail:      `fail`,
+	ResultCodeTempError: `temperror`,
+	ResultCodePermError: `permerror`,
+}
+
+// Resolver define an interface to lookup the DNS TXT records.
+//
+// The LookupTXT method should return empty records without an error if
+// the domain name does not exist.
+type Resolver interface {
+	LookupTXT(domain string) (txts []string, err error)
+}
+
+// Input contains the identifiers and the results of SPF and DKIM checks
+// of a message.
+type Input struct {
+	// FromDomain is the domain part of the "From" header field, the
+	// author domain.
+	FromDomain string
+
+	// SPFDomain is the domain that has been checked by SPF, usually the
+	// domain part of SMTP MAIL FROM.
+	SPFDomain string
+
+	// DKIMDomain is the "d=" value of DKIM-Signature that has been
+	// verified.
+	DKIMDomain string
+
+	// SPFPass is true if the SPF check result is "pass".
+	SPFPass bool
+
+	// DKIMPass is true if the DKIM verification pass.
+	DKIMPass bool
+}
+
+// Result contains the output of DMARC evaluation.
+type Result struct {
+	// Record is the DMARC record that is used for evaluation.
+	// It will be nil if the author domain does not publish DMARC
+	// record.
+	Record *Record
+
+	// Err contains the error message for temperror or permerror.
+	Err string
+
+	// Domain is the author domain.
+	Domain string
+
+	// Policy is the policy that should be applied to the message if the
+	// result is fail.
+	// Its value is already adjusted based on the subdomain policy and
+	// the percentage in the record.
+	Policy string
+
+	// Code is the result of evaluation.
+	Code byte
+}
+
+// String return the result code as text, for example "pass" or "fail".
+func (res *Result) String() string {
+	return resultCodeNames[res.Code]
+}
+
+// Evaluate the DMARC policy of the author domain in input, using
+// resolver r to lookup the DMARC record.
+func Evaluate(r Resolver, in *Input) (res *Result) {
+	var (
+		fromDomain = strings.ToLower(strings.TrimSuffix(in.FromDomain, `.`))
+		orgDomain  = OrganizationalDomain(fromDomain)
+
+		err error
+	)
+
+	res = &Result{
+		Domain: fromDomain,
+		Policy: PolicyNone,
+	}
+
+	if len(fromDomain) == 0 {
+		res.Code = ResultCodePermError
+		res.Err = `empty author domain`
+		return res
+	}
+
+	res.Record, err = lookup(r, fromDomain)
+	if err == nil && res.Record == nil && orgDomain != fromDomain {
+		res.Record, err = lookup(r, orgDomain)
+	}
+	if err != nil {
+		res.Err = err.Error()
+		if _, ok := err.(*parseError); ok {
+			res.Code = ResultCodePermError
+		} else {
+			res.Code = ResultCodeTempError
+		}
+		return res
+	}
+	if res.Record == nil {
+		res.Code = ResultCodeNone
+		return res
+	}
+
+	var rec = res.Record
+
+	if in.DKIMPass && isAligned(rec.ADKIM, fromDomain, in.DKIMDomain) {
+		res.Code = ResultCodePass
+		return res
+	}
+	if in.SPFPass && isAligned(rec.ASPF, fromDomain, in.SPFDomain) {
+		res.Code = ResultCodePass
+		return res
+	}
+
+	res.Code = ResultCodeFail
+	res.Policy = rec.Policy
+	if fromDomain != orgDomain && len(rec.SubdomainPolicy) > 0 {
+		res.Policy = rec.SubdomainPolicy
+	}
+	if rec.Percent < 100 && rand.Intn(100) >= rec.Percent {
+		// Messages that are not sampled use the next less
+		// strict policy, RFC 7489 section 6.6.4.
+		switch res.Policy {
+		case PolicyReject:
+			res.Policy = PolicyQuarantine
+		case PolicyQuarantine:
+			res.Policy = PolicyNone
+		}
+	}
+	return res
+}
+
+// OrganizationalDomain return the organizational domain of domain, which
+// is the last two labels of domain name.
+func OrganizationalDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, `.`))
+
+	var labels = strings.Split(domain, `.`)
+	if len(labels) <= 2 {
+		return domain
+	}
+	return strings.Join(labels[len(labels)-2:], `.`)
+}
+
+// isAligned return true if the domain identifier is aligned with the
+// author domain, based on alignment mode.
+func isAligned(mode byte, fromDomain, domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, `.`))
+	if len(domain) == 0 {
+		return false
+	}
+	if mode == AlignmentStrict {
+		return domain == fromDomain
+	}
+	return OrganizationalDomain(domain) == OrganizationalDomain(fromDomain)
+}
+
+// lookup the DMARC record on "_dmarc." + domain.
+// It will return nil record without an error if the domain does not have
+// DMARC record.
+func lookup(r Resolver, domain string) (rec *Record, err error) {
+	var txts []string
+
+	txts, err = r.LookupTXT(`_dmarc.` + domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []string
+	for _, txt := range txts {
+		if strings.HasPrefix(txt, `v=DMARC1`) {
+			found = append(found, txt)
+		}
+	}
+	// If the remaining set contains multiple records or no records,
+	// policy discovery terminates, RFC 7489 section 6.6.3.
+	if len(found) != 1 {
+		return nil, nil
+	}
+	return Parse(found[0])
+}
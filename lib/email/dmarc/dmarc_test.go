@@ -0,0 +1,156 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dmarc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+type mockResolver map[string][]string
+
+func (res mockResolver) LookupTXT(domain string) ([]string, error) {
+	if domain == `_dmarc.temp.test` {
+		return nil, errors.New(`timeout`)
+	}
+	return res[domain], nil
+}
+
+func TestEvaluate(t *testing.T) {
+	var res = mockResolver{
+		`_dmarc.example.com`: {`v=DMARC1; p=reject; sp=quarantine; adkim=s`},
+		`_dmarc.relaxed.com`: {`v=DMARC1; p=quarantine`},
+		`_dmarc.invalid.com`: {`v=DMARC1; p=drop`},
+	}
+
+	type testCase struct {
+		in        Input
+		desc      string
+		expCode   string
+		expPolicy string
+	}
+
+	var cases = []testCase{{
+		desc: `With DKIM strict aligned`,
+		in: Input{
+			FromDomain: `example.com`,
+			DKIMDomain: `example.com`,
+			DKIMPass:   true,
+		},
+		expCode:   `pass`,
+		expPolicy: PolicyNone,
+	}, {
+		desc: `With DKIM strict not aligned`,
+		in: Input{
+			FromDomain: `example.com`,
+			DKIMDomain: `mail.example.com`,
+			DKIMPass:   true,
+		},
+		expCode:   `fail`,
+		expPolicy: PolicyReject,
+	}, {
+		desc: `With SPF relaxed aligned`,
+		in: Input{
+			FromDomain: `example.com`,
+			SPFDomain:  `bounce.example.com`,
+			SPFPass:    true,
+		},
+		expCode:   `pass`,
+		expPolicy: PolicyNone,
+	}, {
+		desc: `With subdomain policy`,
+		in: Input{
+			FromDomain: `news.example.com`,
+			SPFDomain:  `other.net`,
+			SPFPass:    true,
+		},
+		expCode:   `fail`,
+		expPolicy: PolicyQuarantine,
+	}, {
+		desc: `With relaxed DKIM`,
+		in: Input{
+			FromDomain: `relaxed.com`,
+			DKIMDomain: `mail.relaxed.com`,
+			DKIMPass:   true,
+		},
+		expCode:   `pass`,
+		expPolicy: PolicyNone,
+	}, {
+		desc: `With no record`,
+		in: Input{
+			FromDomain: `norecord.com`,
+		},
+		expCode:   `none`,
+		expPolicy: PolicyNone,
+	}, {
+		desc: `With invalid record`,
+		in: Input{
+			FromDomain: `invalid.com`,
+		},
+		expCode:   `permerror`,
+		expPolicy: PolicyNone,
+	}, {
+		desc: `With DNS failure`,
+		in: Input{
+			FromDomain: `temp.test`,
+		},
+		expCode:   `temperror`,
+		expPolicy: PolicyNone,
+	}}
+
+	var (
+		c   testCase
+		got *Result
+	)
+	for _, c = range cases {
+		got = Evaluate(res, &c.in)
+		test.Assert(t, c.desc+`: code`, c.expCode, got.String())
+		test.Assert(t, c.desc+`: policy`, c.expPolicy, got.Policy)
+	}
+}
+
+func TestParse(t *testing.T) {
+	type testCase struct {
+		exp    *Record
+		txt    string
+		expErr string
+	}
+
+	var cases = []testCase{{
+		txt: `v=DMARC1; p=none; rua=mailto:a@example.com, mailto:b@example.com; pct=50; aspf=s`,
+		exp: &Record{
+			Policy:  PolicyNone,
+			RUA:     []string{`mailto:a@example.com`, `mailto:b@example.com`},
+			Percent: 50,
+			ADKIM:   AlignmentRelaxed,
+			ASPF:    AlignmentStrict,
+		},
+	}, {
+		txt:    `p=none; v=DMARC1`,
+		expErr: `Parse: invalid version "p=none"`,
+	}, {
+		txt:    `v=DMARC1; sp=reject`,
+		expErr: `Parse: missing policy tag "p"`,
+	}, {
+		txt:    `v=DMARC1; p=none; pct=101`,
+		expErr: `Parse: invalid percent "101"`,
+	}}
+
+	var (
+		c   testCase
+		got *Record
+		err error
+	)
+	for _, c = range cases {
+		got, err = Parse(c.txt)
+		if err != nil {
+			test.Assert(t, c.txt, c.expErr, err.Error())
+			continue
+		}
+		test.Assert(t, c.txt, c.exp, got)
+	}
+}
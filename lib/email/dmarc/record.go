@@ -0,0 +1,157 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dmarc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// List of requested mail receiver policy.
+const (
+	PolicyNone       = `none`
+	PolicyQuarantine = `quarantine`
+	PolicyReject     = `reject`
+)
+
+// List of identifier alignment mode.
+const (
+	AlignmentRelaxed byte = 'r'
+	AlignmentStrict  byte = 's'
+)
+
+// Record contains the DMARC policy record published by domain owner.
+type Record struct {
+	// Policy is the requested mail receiver policy, tag "p".
+	Policy string
+
+	// SubdomainPolicy is the requested mail receiver policy for all
+	// subdomains, tag "sp".
+	// If its empty, the Policy is used.
+	SubdomainPolicy string
+
+	// RUA contains addresses for aggregate feedback, tag "rua".
+	RUA []string
+
+	// RUF contains addresses for failure reporting, tag "ruf".
+	RUF []string
+
+	// Percent of messages to which the policy is to be applied, tag
+	// "pct".
+	// Default to 100.
+	Percent int
+
+	// ADKIM is the DKIM identifier alignment mode, tag "adkim".
+	// Default to relaxed.
+	ADKIM byte
+
+	// ASPF is the SPF identifier alignment mode, tag "aspf".
+	// Default to relaxed.
+	ASPF byte
+}
+
+// parseError define an error when parsing the DMARC record.
+type parseError struct {
+	msg string
+}
+
+func (perr *parseError) Error() string {
+	return perr.msg
+}
+
+// Parse the DMARC record from DNS TXT value.
+//
+// The record must start with tag "v=DMARC1" follow by tag "p".
+func Parse(txt string) (rec *Record, err error) {
+	var (
+		logp = `Parse`
+		tags = strings.Split(txt, `;`)
+	)
+
+	rec = &Record{
+		Percent: 100,
+		ADKIM:   AlignmentRelaxed,
+		ASPF:    AlignmentRelaxed,
+	}
+
+	for x, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if len(tag) == 0 {
+			continue
+		}
+
+		var key, val, ok = strings.Cut(tag, `=`)
+		if !ok {
+			return nil, &parseError{fmt.Sprintf(`%s: invalid tag %q`, logp, tag)}
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+
+		if x == 0 {
+			if key != `v` || val != `DMARC1` {
+				return nil, &parseError{fmt.Sprintf(`%s: invalid version %q`, logp, tag)}
+			}
+			continue
+		}
+
+		switch key {
+		case `p`:
+			rec.Policy, err = parsePolicy(val)
+		case `sp`:
+			rec.SubdomainPolicy, err = parsePolicy(val)
+		case `adkim`:
+			rec.ADKIM, err = parseAlignment(val)
+		case `aspf`:
+			rec.ASPF, err = parseAlignment(val)
+		case `pct`:
+			rec.Percent, err = strconv.Atoi(val)
+			if err == nil && (rec.Percent < 0 || rec.Percent > 100) {
+				err = fmt.Errorf(`invalid percent %q`, val)
+			}
+		case `rua`:
+			rec.RUA = parseURIs(val)
+		case `ruf`:
+			rec.RUF = parseURIs(val)
+		}
+		if err != nil {
+			return nil, &parseError{fmt.Sprintf(`%s: %s`, logp, err)}
+		}
+	}
+	if len(rec.Policy) == 0 {
+		return nil, &parseError{fmt.Sprintf(`%s: missing policy tag "p"`, logp)}
+	}
+	return rec, nil
+}
+
+func parsePolicy(val string) (policy string, err error) {
+	policy = strings.ToLower(val)
+	switch policy {
+	case PolicyNone, PolicyQuarantine, PolicyReject:
+		return policy, nil
+	}
+	return ``, fmt.Errorf(`invalid policy %q`, val)
+}
+
+func parseAlignment(val string) (mode byte, err error) {
+	switch strings.ToLower(val) {
+	case `r`:
+		return AlignmentRelaxed, nil
+	case `s`:
+		return AlignmentStrict, nil
+	}
+	return 0, fmt.Errorf(`invalid alignment mode %q`, val)
+}
+
+func parseURIs(val string) (uris []string) {
+	for _, uri := range strings.Split(val, `,`) {
+		uri = strings.TrimSpace(uri)
+		if len(uri) > 0 {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
@@ -50,6 +50,41 @@
 //	| Key | Value | Quoted |
 //	+-----+-------+--------+
 //
+// # Attachments
+//
+// A [MIME] with content type multipart contains the nested Parts, for
+// example a message with attachment may contains multipart/alternative
+// inside multipart/mixed.
+// Use [Message.AddAttachment] or [Message.AttachFile] to attach a file,
+// and [Message.AddInline] or [Message.InlineFile] to add an image that
+// referenced from HTML body by its Content-ID.
+// The file name is encoded using RFC 2231 if its contains non US-ASCII
+// characters.
+// On received message, use [Message.Walk] to iterate all parts, and
+// [Message.Attachments] to get list of attachment.
+//
+// # Internationalization
+//
+// The header field value that contains RFC 2047 encoded-words can be
+// decoded using [DecodeHeader], and the display name in [Mailbox] is
+// decoded when parsed.
+// When setting the header, the non US-ASCII value is encoded
+// automatically using [EncodeHeader], while the UTF-8 address is kept as is
+// as allowed by RFC 6532.
+// Charset other than UTF-8, US-ASCII, and ISO-8859-1 can be supported by
+// setting [CharsetReader].
+//
+// # End-to-end Protection
+//
+// The message can be signed and encrypted using S/MIME (RFC 8551) with
+// [Message.SMIMESign] and [Message.SMIMEEncrypt], or using OpenPGP/MIME
+// (RFC 3156) with [Message.PGPSign] and [Message.PGPEncrypt].
+// Each method pack the message and replace its body with the protected
+// MIME entity, so it should be called after the message is complete.
+// To sign and encrypt, call the sign method first.
+// On received message, use [Message.SMIMEVerify] and [Message.SMIMEDecrypt],
+// or [Message.PGPVerify] and [Message.PGPDecrypt].
+//
 // # Notes
 //
 // In the comment and/or methods of some type, you will see the word "simple"
@@ -17,6 +17,7 @@ const (
 	contentTypeMultipartAlternative = "multipart/alternative"
 	contentTypeTextPlain            = `text/plain; charset="utf-8"`
 	contentTypeTextHTML             = `text/html; charset="utf-8"`
+	encodingBase64                  = `base64`
 	encodingQuotedPrintable         = "quoted-printable"
 	mimeVersion1                    = "1.0"
 )
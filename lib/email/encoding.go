@@ -0,0 +1,125 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// CharsetReader define the function to convert the input in charset into
+// UTF-8.
+// It is used to decode the header encoded-words (RFC 2047) and parameter
+// values (RFC 2231) with charset other than UTF-8, US-ASCII, and
+// ISO-8859-1.
+//
+// By default it is nil, which means decoding other charset will return an
+// error.
+// Application that need to support other charsets, for example
+// ISO-2022-JP or Shift_JIS, can set it using the encoding from
+// golang.org/x/text,
+//
+//	email.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+//		enc, err := htmlindex.Get(charset)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return enc.NewDecoder().Reader(input), nil
+//	}
+var CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+
+// wordDecoder decode the RFC 2047 encoded-words using CharsetReader.
+var wordDecoder = &mime.WordDecoder{
+	CharsetReader: charsetReader,
+}
+
+// DecodeHeader decode the RFC 2047 encoded-words in header field value,
+// for example "=?UTF-8?B?5pel5pys6Kqe?=" into "日本語".
+// The value without encoded-words is returned as is.
+func DecodeHeader(value string) (decoded string, err error) {
+	decoded, err = wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value, fmt.Errorf(`DecodeHeader: %w`, err)
+	}
+	return decoded, nil
+}
+
+// EncodeHeader encode the header field value that contains non US-ASCII
+// characters into RFC 2047 encoded-words using UTF-8 charset.
+// The value that contains only US-ASCII characters is returned as is.
+//
+// The value that mostly contains non US-ASCII characters, for example
+// Japanese text, is encoded using "B" encoding, otherwise it will use "Q"
+// encoding.
+func EncodeHeader(value string) string {
+	var nonASCII int
+	for x := 0; x < len(value); x++ {
+		if value[x] >= utf8.RuneSelf {
+			nonASCII++
+		}
+	}
+	if nonASCII == 0 {
+		return value
+	}
+	if nonASCII*3 > len(value) {
+		return mime.BEncoding.Encode(`UTF-8`, value)
+	}
+	return mime.QEncoding.Encode(`UTF-8`, value)
+}
+
+// charsetReader return the reader that convert input in charset into
+// UTF-8.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case ``, `utf-8`, `utf8`, `us-ascii`:
+		return input, nil
+	case `iso-8859-1`, `latin1`:
+		var (
+			content, err = io.ReadAll(input)
+			sb           strings.Builder
+		)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range content {
+			sb.WriteRune(rune(c))
+		}
+		return strings.NewReader(sb.String()), nil
+	}
+	if CharsetReader == nil {
+		return nil, fmt.Errorf(`unsupported charset %q`, charset)
+	}
+	return CharsetReader(charset, input)
+}
+
+// decodeCharset convert the value in charset into UTF-8.
+func decodeCharset(charset, value string) (decoded string, err error) {
+	var (
+		r       io.Reader
+		content []byte
+	)
+
+	r, err = charsetReader(charset, strings.NewReader(value))
+	if err != nil {
+		return value, err
+	}
+
+	content, err = io.ReadAll(r)
+	if err != nil {
+		return value, err
+	}
+	return string(content), nil
+}
+
+// isASCII return true if all characters in value is US-ASCII.
+func isASCII(value []byte) bool {
+	return bytes.IndexFunc(value, func(r rune) bool {
+		return r >= utf8.RuneSelf
+	}) < 0
+}
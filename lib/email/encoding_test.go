@@ -0,0 +1,117 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestDecodeHeader(t *testing.T) {
+	type testCase struct {
+		desc   string
+		in     string
+		exp    string
+		expErr string
+	}
+
+	var cases = []testCase{{
+		desc: `Without encoded-words`,
+		in:   `Rapat bulanan`,
+		exp:  `Rapat bulanan`,
+	}, {
+		desc: `With B encoding`,
+		in:   `=?UTF-8?B?5pel5pys6Kqe?=`,
+		exp:  `日本語`,
+	}, {
+		desc: `With Q encoding`,
+		in:   `=?iso-8859-1?q?Selamat_pagi,_Andr=E9!?=`,
+		exp:  `Selamat pagi, André!`,
+	}, {
+		desc: `With adjacent encoded-words`,
+		in:   `Re: =?UTF-8?B?5pel5pys?= =?UTF-8?B?6Kqe?= test`,
+		exp:  `Re: 日本語 test`,
+	}, {
+		desc:   `With unknown charset`,
+		in:     `=?ISO-2022-JP?B?GyRCRnxLXDhsGyhC?=`,
+		exp:    `=?ISO-2022-JP?B?GyRCRnxLXDhsGyhC?=`,
+		expErr: `DecodeHeader: unsupported charset "iso-2022-jp"`,
+	}}
+
+	var (
+		c   testCase
+		got string
+		err error
+	)
+	for _, c = range cases {
+		got, err = DecodeHeader(c.in)
+		if err != nil {
+			test.Assert(t, c.desc+`: error`, c.expErr, err.Error())
+		}
+		test.Assert(t, c.desc, c.exp, got)
+	}
+}
+
+func TestDecodeHeader_CharsetReader(t *testing.T) {
+	CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// Mock decoder that convert the content to upper case.
+		var content, err = io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(strings.ToUpper(string(content))), nil
+	}
+	defer func() {
+		CharsetReader = nil
+	}()
+
+	var got, err = DecodeHeader(`=?x-custom?Q?halo?=`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `DecodeHeader`, `HALO`, got)
+}
+
+func TestEncodeHeader(t *testing.T) {
+	type testCase struct {
+		desc string
+		in   string
+		exp  string
+	}
+
+	var cases = []testCase{{
+		desc: `With ASCII`,
+		in:   `Rapat bulanan`,
+		exp:  `Rapat bulanan`,
+	}, {
+		desc: `With few non ASCII`,
+		in:   `Selamat pagi, André`,
+		exp:  `=?UTF-8?q?Selamat_pagi,_Andr=C3=A9?=`,
+	}, {
+		desc: `With mostly non ASCII`,
+		in:   `日本語`,
+		exp:  `=?UTF-8?b?5pel5pys6Kqe?=`,
+	}}
+
+	var (
+		c       testCase
+		got     string
+		decoded string
+		err     error
+	)
+	for _, c = range cases {
+		got = EncodeHeader(c.in)
+		test.Assert(t, c.desc, c.exp, got)
+
+		decoded, err = DecodeHeader(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Assert(t, c.desc+`: decoded`, c.in, decoded)
+	}
+}
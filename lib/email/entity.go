@@ -0,0 +1,218 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// isContentField return true if the field type is part of MIME entity
+// header, the fields that start with "Content-".
+func isContentField(ft FieldType) bool {
+	switch ft {
+	case FieldTypeContentType, FieldTypeContentTransferEncoding,
+		FieldTypeContentID, FieldTypeContentDescription,
+		FieldTypeContentDisposition:
+		return true
+	}
+	return false
+}
+
+// packEntity pack the message and split it into the outer header and the
+// MIME entity.
+// The outer header contains all fields except the "Content-" fields and
+// "MIME-Version".
+// The entity contains the "Content-" fields and the body, in canonical
+// form, ready to be signed or encrypted.
+func (msg *Message) packEntity() (outer *Header, entity []byte, err error) {
+	var packed []byte
+
+	packed, err = msg.Pack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		hdr  *Header
+		rest []byte
+	)
+
+	hdr, rest, err = ParseHeader(packed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+
+	if hdr != nil {
+		for _, f := range hdr.fields {
+			if isContentField(f.Type) {
+				buf.Write(f.Simple())
+			}
+		}
+	}
+	buf.WriteString("\r\n")
+
+	outer = &Header{}
+	for _, f := range msg.Header.fields {
+		if isContentField(f.Type) || f.Type == FieldTypeMIMEVersion {
+			continue
+		}
+		outer.fields = append(outer.fields, f)
+	}
+	buf.Write(rest)
+
+	return outer, buf.Bytes(), nil
+}
+
+// setEntity replace the header and body of message with outer header and
+// single part body with the content type and transfer encoding.
+func (msg *Message) setEntity(outer *Header, contentType, encoding string, content []byte) (err error) {
+	msg.Header = *outer
+
+	err = msg.Header.Set(FieldTypeMIMEVersion, []byte(mimeVersion1))
+	if err != nil {
+		return err
+	}
+	err = msg.Header.Set(FieldTypeContentType, []byte(contentType))
+	if err != nil {
+		return err
+	}
+	if len(encoding) > 0 {
+		err = msg.Header.Set(FieldTypeContentTransferEncoding, []byte(encoding))
+		if err != nil {
+			return err
+		}
+	}
+
+	msg.Body = Body{
+		Parts: []*MIME{{
+			Header:  &Header{},
+			Content: content,
+		}},
+		raw: content,
+	}
+	return nil
+}
+
+// unwrapEntity replace the "Content-" fields and body of message with
+// the MIME entity, the result of decrypting the message.
+func (msg *Message) unwrapEntity(entity []byte) (err error) {
+	var (
+		hdr  *Header
+		body *Body
+		rest []byte
+	)
+
+	hdr, rest, err = ParseHeader(entity)
+	if err != nil {
+		return err
+	}
+	if hdr == nil {
+		hdr = &Header{}
+	}
+
+	var fields []*Field
+	for _, f := range msg.Header.fields {
+		if !isContentField(f.Type) {
+			fields = append(fields, f)
+		}
+	}
+	msg.Header.fields = append(fields, hdr.fields...)
+
+	body, _, err = ParseBody(rest, []byte(hdr.Boundary()))
+	if err != nil {
+		return err
+	}
+	msg.Body = Body{}
+	if body != nil {
+		msg.Body = *body
+	}
+	return nil
+}
+
+// rawBody return the original body of message, or the content of single
+// body part if the message is not parsed from raw input.
+func (msg *Message) rawBody() []byte {
+	if len(msg.Body.raw) > 0 {
+		return msg.Body.raw
+	}
+	if len(msg.Body.Parts) == 1 {
+		return msg.Body.Parts[0].Content
+	}
+	return nil
+}
+
+// splitSigned split the body of "multipart/signed" message, RFC 1847
+// section 2.1, into the signed entity and the signature part.
+// The signed entity is returned as is, while the signature part is
+// returned as MIME.
+func (msg *Message) splitSigned(protocol string) (entity []byte, sig *MIME, err error) {
+	var ct = msg.Header.ContentType()
+	if ct == nil || !ct.isEqual(&ContentType{Top: topMultipart, Sub: `signed`}) {
+		return nil, nil, errors.New(`not a multipart/signed message`)
+	}
+	var gotProtocol = ct.GetParamValue(`protocol`)
+	if !strings.EqualFold(gotProtocol, protocol) {
+		return nil, nil, fmt.Errorf(`unknown signature protocol %q`, gotProtocol)
+	}
+
+	var (
+		boundary = ct.GetParamValue(ParamNameBoundary)
+		raw      = msg.rawBody()
+		delim    = []byte("--" + boundary + "\r\n")
+	)
+	if len(boundary) == 0 {
+		return nil, nil, errors.New(`missing boundary`)
+	}
+
+	var start = bytes.Index(raw, delim)
+	if start < 0 || (start > 0 && !bytes.HasSuffix(raw[:start], []byte("\r\n"))) {
+		return nil, nil, errors.New(`missing signed entity`)
+	}
+	start += len(delim)
+
+	var end = bytes.Index(raw[start:], []byte("\r\n--"+boundary))
+	if end < 0 {
+		return nil, nil, errors.New(`missing signature part`)
+	}
+	entity = raw[start : start+end]
+
+	var body *Body
+
+	body, _, err = ParseBody(raw, []byte(boundary))
+	if err != nil {
+		return nil, nil, err
+	}
+	if body == nil || len(body.Parts) != 2 {
+		return nil, nil, errors.New(`invalid number of signed parts`)
+	}
+	return entity, body.Parts[1], nil
+}
+
+// signedContent create the body of "multipart/signed" message from the
+// entity and the signature part.
+func signedContent(boundary string, entity []byte, sigHeader *Header, sig []byte) (out []byte, err error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.Write(entity)
+	fmt.Fprintf(&buf, "\r\n--%s\r\n", boundary)
+
+	var part = &MIME{
+		Header:  sigHeader,
+		Content: sig,
+	}
+	_, err = part.WriteTo(&buf)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
@@ -138,11 +138,15 @@ func (field *Field) parseName(raw []byte) (rest []byte, err error) {
 // parseValue parse field value.
 // Format,
 //
-//	field-body = 1*(FWS / WSP / %d33-126) CRLF
+//	field-body = 1*(FWS / WSP / %d33-126 / UTF8-non-ascii) CRLF
 //	FWS        = CRLF WSP              ; \r\n followed by space.
 //	WSP        = %d9 / %d32            ; tab or space.
 //
-// [Reference]: https://datatracker.ietf.org/doc/html/rfc5322#section-2.2
+// The UTF8-non-ascii is the UTF-8 character as allowed by [RFC 6532].
+// See [RFC 5322 section 2.2].
+//
+// [RFC 5322 section 2.2]: https://datatracker.ietf.org/doc/html/rfc5322#section-2.2
+// [RFC 6532]: https://datatracker.ietf.org/doc/html/rfc6532#section-3.2
 func (field *Field) parseValue(raw []byte) (rest []byte, err error) {
 	var (
 		logp = `parseValue`
@@ -158,7 +162,7 @@ func (field *Field) parseValue(raw []byte) (rest []byte, err error) {
 				x++
 				break
 			}
-			if raw[x] < 33 || raw[x] > 126 {
+			if raw[x] < 33 || raw[x] == 127 {
 				return nil, fmt.Errorf(`%s: invalid field value %q`, logp, raw[x])
 			}
 		}
@@ -208,6 +212,9 @@ func (field *Field) addMailboxes(mailboxes []byte) (err error) {
 		field.Value += `, `
 	}
 
+	if !isASCII(mailboxes) {
+		mailboxes = []byte(formatMailboxes(mboxes))
+	}
 	mailboxes = relaxedValue(mailboxes)
 	field.Value += string(mailboxes)
 
@@ -291,6 +298,39 @@ func (field *Field) setValue(raw []byte) {
 	field.unpacked = false
 }
 
+// Date return the unpacked value of field Date or Resent-Date.
+// It will return nil if the field is not a date or the value is invalid.
+func (field *Field) Date() *time.Time {
+	if field.Type != FieldTypeDate && field.Type != FieldTypeResentDate {
+		return nil
+	}
+	if field.date == nil {
+		var err = field.unpackDate()
+		if err != nil {
+			return nil
+		}
+	}
+	return field.date
+}
+
+// Mailboxes return the list of mailbox in field From, Sender, Reply-To,
+// To, CC, BCC, and their Resent- fields, with the display name decoded.
+// It will return nil if the field is not one of those or the value is
+// invalid.
+func (field *Field) Mailboxes() []*Mailbox {
+	if !isMailboxField(field.Type) {
+		return nil
+	}
+	if field.mboxes == nil {
+		var mboxes, err = ParseMailboxes([]byte(field.Value))
+		if err != nil {
+			return nil
+		}
+		field.mboxes = mboxes
+	}
+	return field.mboxes
+}
+
 // Relaxed return the relaxed canonicalization of field name and value.
 func (field *Field) Relaxed() (out []byte) {
 	out = make([]byte, 0, len(field.Name)+len(field.Value)+1)
@@ -582,3 +622,48 @@ func (field *Field) unpackContentType() (err error) {
 
 	return nil
 }
+
+// encodeFieldValue encode the field value that contains non US-ASCII
+// characters based on the field type.
+func encodeFieldValue(ft FieldType, value []byte) (out []byte, err error) {
+	if isASCII(value) {
+		return value, nil
+	}
+
+	switch {
+	case ft == FieldTypeSubject || ft == FieldTypeComments:
+		value = bytes.TrimSpace(value)
+		return []byte(EncodeHeader(string(value))), nil
+
+	case isMailboxField(ft):
+		var mboxes []*Mailbox
+
+		mboxes, err = ParseMailboxes(value)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(formatMailboxes(mboxes)), nil
+	}
+	return value, nil
+}
+
+// isMailboxField return true if the field type value is list of mailbox.
+func isMailboxField(ft FieldType) bool {
+	switch ft {
+	case FieldTypeFrom, FieldTypeSender, FieldTypeReplyTo,
+		FieldTypeTo, FieldTypeCC, FieldTypeBCC,
+		FieldTypeResentFrom, FieldTypeResentSender,
+		FieldTypeResentTo, FieldTypeResentCC, FieldTypeResentBCC:
+		return true
+	}
+	return false
+}
+
+// formatMailboxes format list of mailbox into header field value.
+func formatMailboxes(mboxes []*Mailbox) string {
+	var list = make([]string, 0, len(mboxes))
+	for _, mbox := range mboxes {
+		list = append(list, mbox.String())
+	}
+	return strings.Join(list, `, `)
+}
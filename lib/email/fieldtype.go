@@ -47,6 +47,9 @@ const (
 	FieldTypeContentID
 	FieldTypeContentDescription
 
+	// Content-Disposition header field, RFC 2183.
+	FieldTypeContentDisposition
+
 	// DKIM Signature, RFC 6376.
 	FieldTypeDKIMSignature
 )
@@ -87,6 +90,7 @@ var fieldNames = map[FieldType]string{
 	FieldTypeContentTransferEncoding: `content-transfer-encoding`,
 	FieldTypeContentID:               `content-id`,
 	FieldTypeContentDescription:      `content-description`,
+	FieldTypeContentDisposition:      `content-disposition`,
 
 	FieldTypeDKIMSignature: `dkim-signature`,
 }
@@ -78,6 +78,10 @@ func (hdr *Header) addMailboxes(ft FieldType, mailboxes []byte) (err error) {
 		}
 	}
 	if field == nil {
+		mailboxes, err = encodeFieldValue(ft, mailboxes)
+		if err != nil {
+			return err
+		}
 		field = &Field{
 			Type: ft,
 		}
@@ -162,6 +166,18 @@ func (hdr *Header) Filter(ft FieldType) (fields []*Field) {
 	return
 }
 
+// FilterName return all fields with the name, case insensitive.
+// It is used to filter the optional fields that does not have specific
+// field type.
+func (hdr *Header) FilterName(name string) (fields []*Field) {
+	for x := len(hdr.fields) - 1; x >= 0; x-- {
+		if strings.EqualFold(hdr.fields[x].Name, name) {
+			fields = append(fields, hdr.fields[x])
+		}
+	}
+	return
+}
+
 // ID return the Message-ID or empty if not exist.
 func (hdr *Header) ID() string {
 	for x := len(hdr.fields) - 1; x >= 0; x-- {
@@ -192,6 +208,11 @@ func (hdr *Header) Relaxed() []byte {
 
 // Set the header's value based on specific type.
 // If no field type found, the new field will be added to the list.
+//
+// The value that contains non US-ASCII characters is encoded, see
+// [EncodeHeader].
+// For field with mailbox, only the display name is encoded while the
+// address is kept in UTF-8 as allowed by RFC 6532.
 func (hdr *Header) Set(ft FieldType, value []byte) (err error) {
 	var (
 		field = &Field{
@@ -202,6 +223,11 @@ func (hdr *Header) Set(ft FieldType, value []byte) (err error) {
 		x int
 	)
 
+	value, err = encodeFieldValue(ft, value)
+	if err != nil {
+		return fmt.Errorf("Set: %w", err)
+	}
+
 	field.setName([]byte(fieldNames[ft]))
 	field.setValue(value)
 	err = field.unpack()
@@ -276,7 +302,10 @@ func (hdr *Header) WriteTo(w io.Writer) (n int64, err error) {
 		if f.Type == FieldTypeContentType {
 			m, err = fmt.Fprintf(w, "%s: %s\r\n", f.Name, f.contentType.String())
 		} else if f.Type == FieldTypeMessageID {
-			m, err = fmt.Fprintf(w, "%s: <%s>\r\n", f.Name, f.oriValue)
+			// The value of parsed field contains the angle
+			// brackets and CRLF.
+			var id = strings.Trim(strings.TrimSpace(f.oriValue), `<>`)
+			m, err = fmt.Fprintf(w, "%s: <%s>\r\n", f.Name, id)
 		} else {
 			m, err = fmt.Fprintf(w, "%s: %s", f.Name, f.Value)
 		}
@@ -4,7 +4,11 @@
 
 package email
 
-import "github.com/shuLhan/share/lib/ascii"
+import (
+	"unicode/utf8"
+
+	"github.com/shuLhan/share/lib/ascii"
+)
 
 var specialChars, _ = ascii.MakeSet(`()<>[]:;@\,"`)
 
@@ -12,7 +16,7 @@ var specialChars, _ = ascii.MakeSet(`()<>[]:;@\,"`)
 // Local part must,
 //   - start or end without dot character,
 //   - contains only printable US-ASCII characters, excluding special
-//     characters
+//     characters, or UTF-8 characters as allowed by RFC 6532
 //   - no multiple sequence of dots.
 //
 // List of special characters,
@@ -27,6 +31,9 @@ func IsValidLocal(local []byte) bool {
 	}
 	dot := false
 	for x := 0; x < len(local); x++ {
+		if local[x] >= utf8.RuneSelf {
+			continue
+		}
 		if local[x] < 33 || local[x] > 126 {
 			return false
 		}
@@ -43,5 +50,5 @@ func IsValidLocal(local []byte) bool {
 			return false
 		}
 	}
-	return true
+	return utf8.Valid(local)
 }
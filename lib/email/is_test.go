@@ -29,6 +29,13 @@ func TestIsValidLocal(t *testing.T) {
 	}, {
 		desc: "With space",
 		in:   []byte("loc al"),
+	}, {
+		desc: "With UTF-8",
+		in:   []byte("δοκιμή.用户"),
+		exp:  true,
+	}, {
+		desc: "With invalid UTF-8",
+		in:   []byte("loc\xffal"),
 	}}
 
 	for _, c := range cases {
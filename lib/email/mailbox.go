@@ -9,7 +9,9 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/shuLhan/share/lib/ascii"
 	libbytes "github.com/shuLhan/share/lib/bytes"
 	libjson "github.com/shuLhan/share/lib/json"
 	libnet "github.com/shuLhan/share/lib/net"
@@ -29,7 +31,7 @@ func (mbox *Mailbox) String() string {
 	var sb strings.Builder
 
 	if len(mbox.Name) > 0 {
-		sb.WriteString(mbox.Name)
+		sb.WriteString(formatDisplayName(mbox.Name))
 		sb.WriteByte(' ')
 	}
 	sb.WriteByte('<')
@@ -117,7 +119,7 @@ func ParseMailboxes(raw []byte) (mboxes []*Mailbox, err error) {
 		isGroup = true
 	} else if c == '<' {
 		mbox.isAngle = true
-		mbox.Name = string(token)
+		mbox.Name = decodeDisplayName(token)
 	} else if c == '@' {
 		if len(token) == 0 {
 			return nil, fmt.Errorf(`%s: empty local`, logp)
@@ -177,7 +179,7 @@ func parseMailbox(mbox *Mailbox, parser *libbytes.Parser, prevd byte) (c byte, e
 
 		if c == '<' {
 			mbox.isAngle = true
-			mbox.Name = string(value)
+			mbox.Name = decodeDisplayName(value)
 		} else if c == '@' {
 			if len(value) == 0 {
 				return c, fmt.Errorf(`%s: empty local`, logp)
@@ -217,7 +219,7 @@ domain:
 		// Remove all spaces between characters to handle obsolete
 		// domain value, for example "domain . tld" are valid.
 		value = libbytes.RemoveSpaces(value)
-		if !libnet.IsHostnameValid(value, false) {
+		if !isValidDomain(value) {
 			return c, fmt.Errorf(`%s: invalid domain '%s'`, logp, value)
 		}
 	}
@@ -252,7 +254,7 @@ domain:
 func parseMailboxText(parser *libbytes.Parser) (text []byte, c byte, err error) {
 	var (
 		logp   = `parseMailboxText`
-		delims = []byte{'\\', ')'}
+		delims = []byte{'\\', ')', '"'}
 
 		token []byte
 	)
@@ -264,6 +266,14 @@ func parseMailboxText(parser *libbytes.Parser) (text []byte, c byte, err error)
 	for {
 		text = append(text, token...)
 
+		if c == '"' {
+			text, err = parseQuotedString(parser, text)
+			if err != nil {
+				return nil, 0, fmt.Errorf(`%s: %w`, logp, err)
+			}
+			token, c = parser.ReadNoSpace()
+			continue
+		}
 		if c == ')' {
 			return nil, c, fmt.Errorf(`%s: invalid character '%c'`, logp, c)
 		}
@@ -287,6 +297,89 @@ func parseMailboxText(parser *libbytes.Parser) (text []byte, c byte, err error)
 	return text, c, nil
 }
 
+// parseQuotedString read the quoted-string until the closing DQUOTE and
+// append it, including the DQUOTEs, into text.
+// The quoted-pair inside the quoted-string is unescaped.
+func parseQuotedString(parser *libbytes.Parser, text []byte) ([]byte, error) {
+	var (
+		delims = parser.Delimiters()
+
+		token []byte
+		c     byte
+	)
+
+	parser.SetDelimiters([]byte{'"', '\\'})
+	defer parser.SetDelimiters(delims)
+
+	text = append(text, '"')
+	for {
+		token, c = parser.Read()
+		text = append(text, token...)
+		if c == '\\' {
+			token, _ = parser.ReadN(1)
+			text = append(text, token...)
+			continue
+		}
+		if c == '"' {
+			break
+		}
+		return nil, errors.New(`missing closing quote`)
+	}
+	text = append(text, '"')
+	return text, nil
+}
+
+// decodeDisplayName remove the DQUOTE around the display name and decode
+// the RFC 2047 encoded-words inside it.
+func decodeDisplayName(raw []byte) string {
+	var name = string(raw)
+	if len(name) >= 2 && name[0] == '"' && name[len(name)-1] == '"' {
+		name = name[1 : len(name)-1]
+	}
+	name, _ = DecodeHeader(name)
+	return name
+}
+
+// formatDisplayName format the display name for header field value.
+// The name that contains non US-ASCII characters is encoded using RFC 2047
+// encoded-words, while the name that contains special characters is
+// quoted.
+func formatDisplayName(name string) string {
+	if !isASCII([]byte(name)) {
+		return EncodeHeader(name)
+	}
+	for x := 0; x < len(name); x++ {
+		if name[x] == '.' || specialChars.Contains(name[x]) {
+			var r = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+			return `"` + r.Replace(name) + `"`
+		}
+	}
+	return name
+}
+
+// isValidDomain return true if the domain is valid hostname, or
+// internationalized domain name in UTF-8 as allowed by RFC 6532.
+func isValidDomain(domain []byte) bool {
+	if isASCII(domain) {
+		return libnet.IsHostnameValid(domain, false)
+	}
+	if !utf8.Valid(domain) {
+		return false
+	}
+	for _, label := range bytes.Split(domain, []byte{'.'}) {
+		if len(label) == 0 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if c >= utf8.RuneSelf || c == '-' || c == '_' || ascii.IsAlnum(c) {
+				continue
+			}
+			return false
+		}
+	}
+	return true
+}
+
 // skipComment skip all characters inside parentheses, '(' and ')'.
 //
 // A comment can contains quoted-pair, which means opening or closing
@@ -178,6 +178,26 @@ func TestParseMailboxes(t *testing.T) {
 		desc: `With group, multiple addresses`,
 		in:   `(c)Group name(c): <(c)local(c)@(c)domain(c)>, Test One <test@one>;(c)`,
 		exp:  `[<local@domain> Test One <test@one>]`,
+	}, {
+		desc: `With quoted display name`,
+		in:   `"Doe, John" <john@example>, "Budi \"B\"" <budi@example>`,
+		exp:  `["Doe, John" <john@example> "Budi \"B\"" <budi@example>]`,
+	}, {
+		desc:   `With quoted display name, missing closing quote`,
+		in:     `"Doe, John <john@example>`,
+		expErr: `ParseMailboxes: parseMailboxText: missing closing quote`,
+	}, {
+		desc: `With encoded display name`,
+		in:   `=?ISO-8859-1?Q?Andr=E9?= <andre@example>`,
+		exp:  `[=?UTF-8?q?Andr=C3=A9?= <andre@example>]`,
+	}, {
+		desc: `With UTF-8 address`,
+		in:   `山田 <山田@例え.jp>`,
+		exp:  `[=?UTF-8?b?5bGx55Sw?= <山田@例え.jp>]`,
+	}, {
+		desc:   `With invalid UTF-8 domain`,
+		in:     `山田 <山田@例え..jp>`,
+		expErr: `ParseMailboxes: parseMailbox: invalid domain '例え..jp'`,
 	}, {
 		desc:   `With list, invalid ','`,
 		in:     `on,e@example , two@example`,
@@ -0,0 +1,81 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package maildir
+
+import (
+	"sort"
+	"strings"
+)
+
+// List of standard info flags.
+// In the file name, the flags must be sorted in ASCII order.
+const (
+	FlagDraft   byte = 'D' // The message is a draft.
+	FlagFlagged byte = 'F' // The message is flagged for special attention.
+	FlagPassed  byte = 'P' // The message has been resent or forwarded.
+	FlagReplied byte = 'R' // The message has been replied.
+	FlagSeen    byte = 'S' // The message has been viewed.
+	FlagTrashed byte = 'T' // The message has been marked for deletion.
+)
+
+// infoVersion2 is the prefix of info in the file name, followed by comma
+// and list of flags.
+const infoVersion2 = `:2`
+
+// splitInfo split the file name into its unique key and list of flags.
+// The file name without info, or with info ":2" only, return empty flags.
+func splitInfo(name string) (key, flags string) {
+	var x = strings.LastIndex(name, infoVersion2)
+	if x < 0 {
+		return name, ``
+	}
+	var info = name[x+len(infoVersion2):]
+	if len(info) == 0 {
+		return name[:x], ``
+	}
+	if info[0] != ',' {
+		return name, ``
+	}
+	return name[:x], normalizeFlags(info[1:])
+}
+
+// joinInfo create the file name from key and list of flags.
+func joinInfo(key, flags string) string {
+	return key + infoVersion2 + `,` + normalizeFlags(flags)
+}
+
+// normalizeFlags remove the duplicate and invalid flags, and sort the
+// flags in ASCII order.
+// The valid flag is uppercase letter for standard flags or lowercase
+// letter for experimental flags.
+func normalizeFlags(flags string) string {
+	var list = make([]byte, 0, len(flags))
+	for x := 0; x < len(flags); x++ {
+		var c = flags[x]
+		if !(c >= 'A' && c <= 'Z') && !(c >= 'a' && c <= 'z') {
+			continue
+		}
+		if strings.IndexByte(string(list), c) >= 0 {
+			continue
+		}
+		list = append(list, c)
+	}
+	sort.Slice(list, func(x, y int) bool {
+		return list[x] < list[y]
+	})
+	return string(list)
+}
+
+// removeFlags return flags without the flags in del.
+func removeFlags(flags, del string) string {
+	var sb strings.Builder
+	for x := 0; x < len(flags); x++ {
+		if strings.IndexByte(del, flags[x]) >= 0 {
+			continue
+		}
+		sb.WriteByte(flags[x])
+	}
+	return normalizeFlags(sb.String())
+}
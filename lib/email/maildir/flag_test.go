@@ -0,0 +1,49 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package maildir
+
+import (
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestSplitInfo(t *testing.T) {
+	type testCase struct {
+		name     string
+		expKey   string
+		expFlags string
+	}
+
+	var cases = []testCase{{
+		name:   `1684640949.M875494_P1000_Q2.localhost`,
+		expKey: `1684640949.M875494_P1000_Q2.localhost`,
+	}, {
+		name:   `1684640949.M875494_P1000_Q2.localhost:2`,
+		expKey: `1684640949.M875494_P1000_Q2.localhost`,
+	}, {
+		name:     `1684640949.M875494_P1000_Q2.localhost,S=15:2,TSRF`,
+		expKey:   `1684640949.M875494_P1000_Q2.localhost,S=15`,
+		expFlags: `FRST`,
+	}, {
+		name:     `key:2,SaS1`,
+		expKey:   `key`,
+		expFlags: `Sa`,
+	}, {
+		name:   `key:2x`,
+		expKey: `key:2x`,
+	}}
+
+	var (
+		c        testCase
+		gotKey   string
+		gotFlags string
+	)
+	for _, c = range cases {
+		gotKey, gotFlags = splitInfo(c.name)
+		test.Assert(t, c.name+`: key`, c.expKey, gotKey)
+		test.Assert(t, c.name+`: flags`, c.expFlags, gotFlags)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"unicode"
 )
@@ -182,6 +183,247 @@ func (folder *Folder) Get(file string) (msg []byte, err error) {
 	return msg, nil
 }
 
+// Dir return the full path of folder directory.
+func (folder *Folder) Dir() string {
+	return folder.dir
+}
+
+// Read the content of message file from "cur" or "new" directory.
+// The message can be referenced by its full file name or by its unique
+// key.
+func (folder *Folder) Read(name string) (msg []byte, err error) {
+	var (
+		logp = `Read`
+		dir  string
+	)
+
+	dir, name, err = folder.locate(name)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	msg, err = os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return msg, nil
+}
+
+// List return all messages in the "new" and "cur" directories, sorted by
+// their Date.
+// The message header is parsed using [email.ParseHeader].
+func (folder *Folder) List() (list []*Message, err error) {
+	var logp = `List`
+
+	list, err = folder.readDir(folder.dirNew, true)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var listCur []*Message
+
+	listCur, err = folder.readDir(folder.dirCur, false)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	list = append(list, listCur...)
+	sortMessages(list)
+
+	return list, nil
+}
+
+// AddFlags add one or more flags to the message file name.
+// See [Folder.SetFlags] for more information.
+func (folder *Folder) AddFlags(name, flags string) (newName string, err error) {
+	var (
+		logp = `AddFlags`
+		dir  string
+	)
+
+	dir, name, err = folder.locate(name)
+	if err != nil {
+		return ``, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var key, oldFlags = splitInfo(name)
+
+	newName, err = folder.rename(dir, name, joinInfo(key, oldFlags+flags))
+	if err != nil {
+		return ``, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return newName, nil
+}
+
+// RemoveFlags remove one or more flags from the message file name.
+// See [Folder.SetFlags] for more information.
+func (folder *Folder) RemoveFlags(name, flags string) (newName string, err error) {
+	var (
+		logp = `RemoveFlags`
+		dir  string
+	)
+
+	dir, name, err = folder.locate(name)
+	if err != nil {
+		return ``, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var key, oldFlags = splitInfo(name)
+
+	newName, err = folder.rename(dir, name, joinInfo(key, removeFlags(oldFlags, flags)))
+	if err != nil {
+		return ``, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return newName, nil
+}
+
+// SetFlags replace the flags of message file name, for example "FS" for
+// flagged and seen.
+// If the message is in "new" directory, it will be moved to "cur".
+// The message can be referenced by its full file name or by its unique
+// key.
+// On success it will return the new file name.
+func (folder *Folder) SetFlags(name, flags string) (newName string, err error) {
+	var (
+		logp = `SetFlags`
+		dir  string
+	)
+
+	dir, name, err = folder.locate(name)
+	if err != nil {
+		return ``, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var key, _ = splitInfo(name)
+
+	newName, err = folder.rename(dir, name, joinInfo(key, flags))
+	if err != nil {
+		return ``, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return newName, nil
+}
+
+// Move the message file to another folder dst, by linking it into dst
+// and then removing it from folder.
+// The message in "new" directory is moved to "new" directory in dst,
+// while the message in "cur" is moved to "cur" with the same flags.
+// It will return an error if the file with the same name already exist in
+// dst.
+// On success it will return the file name in dst.
+func (folder *Folder) Move(name string, dst *Folder) (newName string, err error) {
+	var (
+		logp = `Move`
+		dir  string
+	)
+
+	if dst == nil {
+		return ``, fmt.Errorf(`%s: nil destination folder`, logp)
+	}
+
+	dir, name, err = folder.locate(name)
+	if err != nil {
+		return ``, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var dirDst = dst.dirCur
+	if dir == folder.dirNew {
+		dirDst = dst.dirNew
+	}
+
+	var src = filepath.Join(dir, name)
+
+	// Unlike rename, link does not overwrite the existing file.
+	err = os.Link(src, filepath.Join(dirDst, name))
+	if err != nil {
+		return ``, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	err = os.Remove(src)
+	if err != nil {
+		return ``, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return name, nil
+}
+
+// locate find the message file by its name or by its unique key in "cur"
+// and "new" directories.
+// It will return the directory and the current file name.
+func (folder *Folder) locate(name string) (dir, fname string, err error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 || name == `.` || name == `..` ||
+		strings.ContainsRune(name, '/') {
+		return ``, ``, fmt.Errorf(`invalid file name %q`, name)
+	}
+
+	var (
+		key, _ = splitInfo(name)
+		dirs   = []string{folder.dirCur, folder.dirNew}
+
+		entries []os.DirEntry
+	)
+	for _, dir = range dirs {
+		_, err = os.Stat(filepath.Join(dir, name))
+		if err == nil {
+			return dir, name, nil
+		}
+	}
+	// The flags may have been changed by other process, find the file
+	// by its key.
+	for _, dir = range dirs {
+		entries, err = os.ReadDir(dir)
+		if err != nil {
+			return ``, ``, err
+		}
+		for _, entry := range entries {
+			fname, _ = splitInfo(entry.Name())
+			if fname == key {
+				return dir, entry.Name(), nil
+			}
+		}
+	}
+	return ``, ``, fmt.Errorf(`%s: %w`, name, os.ErrNotExist)
+}
+
+// rename the file name in dir into newName in "cur" directory.
+func (folder *Folder) rename(dir, name, newName string) (string, error) {
+	if dir == folder.dirCur && name == newName {
+		return newName, nil
+	}
+	var err = os.Rename(filepath.Join(dir, name), filepath.Join(folder.dirCur, newName))
+	if err != nil {
+		return ``, err
+	}
+	return newName, nil
+}
+
+// readDir read all messages in directory dir.
+func (folder *Folder) readDir(dir string, isNew bool) (list []*Message, err error) {
+	var (
+		entries []os.DirEntry
+		msg     *Message
+	)
+
+	entries, err = os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		msg, err = readMessage(dir, entry.Name(), isNew)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// The file has been moved or deleted by other
+				// process.
+				continue
+			}
+			return nil, err
+		}
+		list = append(list, msg)
+	}
+	return list, nil
+}
+
 func (folder *Folder) initDirs() (err error) {
 	var logp = `initDirs`
 
@@ -251,3 +493,13 @@ func sanitizeFolderName(name string) (out string, err error) {
 	}
 	return out, nil
 }
+
+// sortMessages sort the list of message by Date and then by Key.
+func sortMessages(list []*Message) {
+	sort.Slice(list, func(x, y int) bool {
+		if list[x].Date.Equal(list[y].Date) {
+			return list[x].Key < list[y].Key
+		}
+		return list[x].Date.Before(list[y].Date)
+	})
+}
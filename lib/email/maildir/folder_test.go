@@ -6,10 +6,12 @@ package maildir
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/shuLhan/share/lib/email"
 	"github.com/shuLhan/share/lib/test"
 )
 
@@ -317,3 +319,183 @@ func assertFolder(t *testing.T, folder *Folder) {
 		t.Fatalf(`want %s, got %s`, fileMd, err)
 	}
 }
+
+func TestFolder_messages(t *testing.T) {
+	var (
+		dir = t.TempDir()
+
+		inbox *Folder
+		trash *Folder
+		err   error
+	)
+
+	inbox, err = CreateFolder(dir, `.Inbox`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trash, err = CreateFolder(dir, `.Trash`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestMessages(t, inbox)
+
+	// Case: List.
+
+	var list []*Message
+
+	list, err = inbox.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type messageInfo struct {
+		name  string
+		flags string
+		isNew bool
+	}
+
+	var (
+		expList = []messageInfo{{
+			name:  `1001.a:2,S`,
+			flags: `S`,
+		}, {
+			name:  `1002.b`,
+			isNew: true,
+		}, {
+			name:  `1003.c:2,SR`,
+			flags: `RS`,
+		}}
+		gotList []messageInfo
+	)
+	for _, msg := range list {
+		gotList = append(gotList, messageInfo{
+			name:  msg.Name,
+			flags: msg.Flags,
+			isNew: msg.IsNew,
+		})
+	}
+	test.Assert(t, `List`, expList, gotList)
+	test.Assert(t, `List: Header`, `budi@contoh.id`,
+		list[1].Header.Filter(email.FieldTypeFrom)[0].Mailboxes()[0].Address)
+
+	// Case: SetFlags on new message move it to cur.
+
+	var name string
+
+	name, err = inbox.SetFlags(`1002.b`, `SFx`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `SetFlags`, `1002.b:2,FSx`, name)
+
+	_, err = os.Stat(filepath.Join(inbox.dirCur, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Case: AddFlags and RemoveFlags using the unique key.
+
+	name, err = inbox.AddFlags(`1001.a`, `TF`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `AddFlags`, `1001.a:2,FST`, name)
+
+	name, err = inbox.RemoveFlags(name, `SF`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `RemoveFlags`, `1001.a:2,T`, name)
+
+	_, err = inbox.SetFlags(`1009.z`, `S`)
+	var expError = `SetFlags: 1009.z: file does not exist`
+	test.Assert(t, `SetFlags: not exist`, expError, err.Error())
+
+	// Case: Move.
+
+	name, err = inbox.Move(name, trash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(filepath.Join(trash.dirCur, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err = inbox.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `List after Move`, 2, len(list))
+
+	// Case: Move into folder that has the same file name.
+
+	_, err = inbox.Move(name, trash)
+	expError = `Move: ` + name + `: file does not exist`
+	test.Assert(t, `Move: not exist`, expError, err.Error())
+
+	err = os.WriteFile(filepath.Join(inbox.dirCur, name), []byte(`other`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = inbox.Move(name, trash)
+	test.Assert(t, `Move: destination exist`, true, errors.Is(err, os.ErrExist))
+
+	var content []byte
+
+	content, err = os.ReadFile(filepath.Join(trash.dirCur, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Move: destination not overwritten`, false, string(content) == `other`)
+
+	// Case: invalid file names.
+
+	for _, name = range []string{`.`, `..`, `../x`} {
+		_, err = inbox.Move(name, trash)
+		expError = fmt.Sprintf(`Move: invalid file name %q`, name)
+		test.Assert(t, `Move: `+name, expError, err.Error())
+	}
+}
+
+// writeTestMessages write three messages into folder, one in "new" and two
+// in "cur".
+func writeTestMessages(t *testing.T, folder *Folder) {
+	var files = []struct {
+		dir     string
+		name    string
+		content string
+	}{{
+		dir:  folder.dirCur,
+		name: `1003.c:2,SR`,
+		content: "From: =?UTF-8?B?5bGx55Sw?= <yamada@example.jp>\r\n" +
+			"To: Siti <siti@contoh.id>\r\n" +
+			"Date: Wed, 3 Jan 2024 10:00:00 +0000\r\n" +
+			"Subject: =?UTF-8?B?5Lya6K2w?= meeting\r\n" +
+			"\r\nbody\r\n",
+	}, {
+		dir:  folder.dirNew,
+		name: `1002.b`,
+		content: "From: Budi <budi@contoh.id>\n" +
+			"To: Siti <siti@contoh.id>, Andi <andi@contoh.id>\n" +
+			"Date: Tue, 2 Jan 2024 10:00:00 +0000\n" +
+			"Subject: Rapat bulanan\n" +
+			"\nbody\n",
+	}, {
+		dir:  folder.dirCur,
+		name: `1001.a:2,S`,
+		content: "From: Siti <siti@contoh.id>\r\n" +
+			"To: Budi <budi@contoh.id>\r\n" +
+			"Date: Mon, 1 Jan 2024 10:00:00 +0000\r\n" +
+			"Subject: Laporan\r\n" +
+			"\r\nbody\r\n",
+	}}
+
+	for _, f := range files {
+		var err = os.WriteFile(filepath.Join(f.dir, f.name), []byte(f.content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
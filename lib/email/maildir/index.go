@@ -0,0 +1,255 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package maildir
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shuLhan/share/lib/email"
+)
+
+// Index is an in-memory index of messages in folder, to search the
+// messages by From, To, Subject, and Date.
+//
+// The index does not watch the folder for changes.
+// Call [Index.Update] to synchronize the index after the messages in
+// folder has been changed.
+type Index struct {
+	folder *Folder
+
+	// entries contains the indexed message by its key.
+	entries map[string]*indexEntry
+
+	// sorted contains the entries sorted by message Date.
+	sorted []*indexEntry
+
+	mtx sync.RWMutex
+}
+
+// indexEntry contains the message and its lower case header values for
+// searching.
+type indexEntry struct {
+	msg     *Message
+	from    string
+	to      string
+	subject string
+}
+
+// SearchQuery define the parameters to search messages in [Index].
+// The message must match all of non-empty fields.
+type SearchQuery struct {
+	// Since filter the message with Date equal or after it.
+	Since time.Time
+
+	// Before filter the message with Date before it.
+	Before time.Time
+
+	// From, To, and Subject filter the message that contains the
+	// value in the header field, case insensitive.
+	// The From and To are matched with the mailbox display name and
+	// address.
+	From    string
+	To      string
+	Subject string
+}
+
+// NewIndex create and initialize the index of messages in folder.
+func NewIndex(folder *Folder) (idx *Index, err error) {
+	if folder == nil {
+		return nil, errors.New(`NewIndex: nil folder`)
+	}
+
+	idx = &Index{
+		folder:  folder,
+		entries: make(map[string]*indexEntry),
+	}
+
+	err = idx.Update()
+	if err != nil {
+		return nil, fmt.Errorf(`NewIndex: %w`, err)
+	}
+	return idx, nil
+}
+
+// Search the messages in index that match with query, sorted by Date.
+func (idx *Index) Search(q SearchQuery) (list []*Message) {
+	var (
+		from    = strings.ToLower(q.From)
+		to      = strings.ToLower(q.To)
+		subject = strings.ToLower(q.Subject)
+	)
+
+	idx.mtx.RLock()
+	defer idx.mtx.RUnlock()
+
+	var start int
+	if !q.Since.IsZero() {
+		start = sort.Search(len(idx.sorted), func(x int) bool {
+			return !idx.sorted[x].msg.Date.Before(q.Since)
+		})
+	}
+
+	for _, entry := range idx.sorted[start:] {
+		if !q.Before.IsZero() && !entry.msg.Date.Before(q.Before) {
+			break
+		}
+		if !strings.Contains(entry.from, from) {
+			continue
+		}
+		if !strings.Contains(entry.to, to) {
+			continue
+		}
+		if !strings.Contains(entry.subject, subject) {
+			continue
+		}
+		list = append(list, entry.msg)
+	}
+	return list
+}
+
+// Update synchronize the index with the messages in folder.
+// Only the header of new message is parsed, the message that has been
+// indexed only updated its file name and flags.
+func (idx *Index) Update() (err error) {
+	var (
+		logp  = `Update`
+		names = map[string]bool{}
+		found = map[string]bool{}
+	)
+
+	err = idx.scanNames(idx.folder.dirNew, true, names)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	err = idx.scanNames(idx.folder.dirCur, false, names)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	var (
+		entry *indexEntry
+		msg   *Message
+		dir   string
+	)
+	for name, isNew := range names {
+		var key, flags = splitInfo(name)
+
+		found[key] = true
+
+		entry = idx.entries[key]
+		if entry != nil {
+			entry.msg.Name = name
+			entry.msg.Flags = flags
+			entry.msg.IsNew = isNew
+			continue
+		}
+
+		dir = idx.folder.dirCur
+		if isNew {
+			dir = idx.folder.dirNew
+		}
+		msg, err = readMessage(dir, name, isNew)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				delete(found, key)
+				continue
+			}
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+		idx.entries[key] = newIndexEntry(msg)
+	}
+
+	for key := range idx.entries {
+		if !found[key] {
+			delete(idx.entries, key)
+		}
+	}
+
+	idx.sorted = idx.sorted[:0]
+	for _, entry = range idx.entries {
+		idx.sorted = append(idx.sorted, entry)
+	}
+	sort.Slice(idx.sorted, func(x, y int) bool {
+		var a, b = idx.sorted[x].msg, idx.sorted[y].msg
+		if a.Date.Equal(b.Date) {
+			return a.Key < b.Key
+		}
+		return a.Date.Before(b.Date)
+	})
+
+	return nil
+}
+
+// scanNames read the file names in dir into names.
+func (idx *Index) scanNames(dir string, isNew bool, names map[string]bool) (err error) {
+	var entries []os.DirEntry
+
+	entries, err = os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names[entry.Name()] = isNew
+		}
+	}
+	return nil
+}
+
+// newIndexEntry create index entry from message header.
+func newIndexEntry(msg *Message) (entry *indexEntry) {
+	entry = &indexEntry{
+		msg:     msg,
+		from:    mailboxesText(msg.Header, email.FieldTypeFrom),
+		to:      mailboxesText(msg.Header, email.FieldTypeTo),
+		subject: headerText(msg.Header, email.FieldTypeSubject),
+	}
+	entry.to += ` ` + mailboxesText(msg.Header, email.FieldTypeCC)
+	return entry
+}
+
+// headerText return the lower case and decoded value of header field.
+func headerText(hdr *email.Header, ft email.FieldType) string {
+	var sb strings.Builder
+	for _, field := range hdr.Filter(ft) {
+		var value, _ = email.DecodeHeader(strings.TrimSpace(field.Value))
+		sb.WriteString(value)
+		sb.WriteByte(' ')
+	}
+	return strings.ToLower(sb.String())
+}
+
+// mailboxesText return the lower case display names and addresses in the
+// header field.
+// If the field value cannot be parsed as mailboxes, it will return the
+// decoded field value.
+func mailboxesText(hdr *email.Header, ft email.FieldType) string {
+	var sb strings.Builder
+	for _, field := range hdr.Filter(ft) {
+		var mboxes = field.Mailboxes()
+		if mboxes == nil {
+			var value, _ = email.DecodeHeader(strings.TrimSpace(field.Value))
+			sb.WriteString(value)
+			sb.WriteByte(' ')
+			continue
+		}
+		for _, mbox := range mboxes {
+			sb.WriteString(mbox.Name)
+			sb.WriteByte(' ')
+			sb.WriteString(mbox.Address)
+			sb.WriteByte(' ')
+		}
+	}
+	return strings.ToLower(sb.String())
+}
@@ -0,0 +1,112 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package maildir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestIndex_Search(t *testing.T) {
+	var (
+		folder *Folder
+		idx    *Index
+		err    error
+	)
+
+	folder, err = CreateFolder(t.TempDir(), `.Inbox`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestMessages(t, folder)
+
+	idx, err = NewIndex(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		desc string
+		q    SearchQuery
+		exp  []string
+	}
+
+	var cases = []testCase{{
+		desc: `With empty query`,
+		exp:  []string{`1001.a`, `1002.b`, `1003.c`},
+	}, {
+		desc: `With From address`,
+		q:    SearchQuery{From: `CONTOH.ID`},
+		exp:  []string{`1001.a`, `1002.b`},
+	}, {
+		desc: `With decoded From name`,
+		q:    SearchQuery{From: `山田`},
+		exp:  []string{`1003.c`},
+	}, {
+		desc: `With To name`,
+		q:    SearchQuery{To: `andi`},
+		exp:  []string{`1002.b`},
+	}, {
+		desc: `With decoded Subject`,
+		q:    SearchQuery{Subject: `会議`},
+		exp:  []string{`1003.c`},
+	}, {
+		desc: `With date range`,
+		q: SearchQuery{
+			Since:  time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			Before: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+		},
+		exp: []string{`1002.b`},
+	}, {
+		desc: `With no match`,
+		q:    SearchQuery{From: `siti`, Subject: `rapat`},
+	}}
+
+	var (
+		c   testCase
+		got []string
+	)
+	for _, c = range cases {
+		got = nil
+		for _, msg := range idx.Search(c.q) {
+			got = append(got, msg.Key)
+		}
+		test.Assert(t, c.desc, c.exp, got)
+	}
+
+	// Case: Update after the message flags changed, new message
+	// added, and message deleted.
+
+	_, err = folder.SetFlags(`1002.b`, `S`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = folder.Delete(`1001.a:2,S`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(folder.dirNew, `1004.d`),
+		[]byte("From: Andi <andi@contoh.id>\r\nSubject: Rapat\r\n\r\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = idx.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var list = idx.Search(SearchQuery{Subject: `rapat`})
+	got = nil
+	for _, msg := range list {
+		got = append(got, msg.Name)
+	}
+	test.Assert(t, `Update`, []string{`1002.b:2,S`, `1004.d`}, got)
+}
@@ -22,6 +22,15 @@
 // hostname is the system host name, and
 // size is the message size.
 //
+// The message in "cur" directory may have info suffix ":2," followed by
+// list of flags sorted in ASCII order, for example ":2,FRS" for message
+// that has been flagged, replied, and seen.
+// The flags can be changed using [Folder.SetFlags], [Folder.AddFlags],
+// and [Folder.RemoveFlags], and the message can be moved between folders
+// using [Folder.Move].
+// The messages in folder can be listed using [Folder.List] or searched by
+// their header using [Index].
+//
 // References,
 //
 //   - [Courier Maildir]
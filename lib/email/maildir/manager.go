@@ -10,6 +10,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -71,6 +72,23 @@ func NewManager(dir string) (mg *Manager, err error) {
 	return mg, nil
 }
 
+// GetFolder return the folder by its name, for example ".Sent", or nil if
+// the folder does not exist.
+func (mg *Manager) GetFolder(name string) *Folder {
+	return mg.folders[name]
+}
+
+// FolderNames return the sorted list of folder names inside the main
+// maildir.
+func (mg *Manager) FolderNames() (names []string) {
+	names = make([]string, 0, len(mg.folders))
+	for name := range mg.folders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // initDirs initialize the maildir directories.
 func (mg *Manager) initDirs(dir string) (err error) {
 	var logp = `initDirs`
@@ -196,6 +214,54 @@ func (mg *Manager) Incoming(msg []byte) (fnNew string, err error) {
 	return fnNew, nil
 }
 
+// Append save the message into the "cur" directory of folder with the
+// flags, for example when client store a copy of sent message.
+// If folder is nil, the message is saved into the main maildir.
+// On success it will return the file name in folder.
+func (mg *Manager) Append(folder *Folder, msg []byte, flags string) (name string, err error) {
+	var logp = `Append`
+
+	if len(msg) == 0 {
+		return ``, fmt.Errorf(`%s: empty message`, logp)
+	}
+	if folder == nil {
+		folder = &mg.Folder
+	}
+
+	var (
+		fname   = createFilename(mg.pid, mg.counter, mg.hostname)
+		pathTmp = filepath.Join(mg.dirTmp, fname.nameTmp)
+	)
+
+	err = os.WriteFile(pathTmp, msg, 0660)
+	if err != nil {
+		return ``, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	name, err = fname.generateNameNew(pathTmp, int64(len(msg)))
+	if err != nil {
+		_ = os.Remove(pathTmp)
+		return ``, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	name = joinInfo(name, flags)
+
+	err = os.Link(pathTmp, filepath.Join(folder.dirCur, name))
+	if err != nil {
+		_ = os.Remove(pathTmp)
+		return ``, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	err = os.Remove(pathTmp)
+	if err != nil {
+		log.Printf(`%s: %s`, logp, err)
+	}
+
+	mg.counter++
+
+	return name, nil
+}
+
 // OutgoingQueue save the message in temporary queue directory before sending
 // it to external MTA or processed.
 //
@@ -0,0 +1,131 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package maildir
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shuLhan/share/lib/email"
+)
+
+// Message contains the information of message file in folder, and its
+// parsed header.
+type Message struct {
+	// Header of message.
+	// If the header cannot be parsed, it will be empty.
+	Header *email.Header
+
+	// Date of message from the header field Date, or the file
+	// modification time if the field Date does not exist or invalid.
+	Date time.Time
+
+	// Name of message file, including the info.
+	Name string
+
+	// Key is the unique part of file name, without the info.
+	Key string
+
+	// Flags contains list of info flags, for example "FRS".
+	Flags string
+
+	// Size of message file in bytes.
+	Size int64
+
+	// IsNew is true if the message is in the "new" directory, the
+	// message that has not been seen by any client.
+	IsNew bool
+}
+
+// HasFlag return true if the message has the flag.
+func (msg *Message) HasFlag(flag byte) bool {
+	return strings.IndexByte(msg.Flags, flag) >= 0
+}
+
+// readMessage read the message information and its header from file
+// name in directory dir.
+func readMessage(dir, name string, isNew bool) (msg *Message, err error) {
+	var (
+		path = filepath.Join(dir, name)
+
+		f  *os.File
+		fi os.FileInfo
+	)
+
+	f, err = os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err = f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	msg = &Message{
+		Name:  name,
+		Date:  fi.ModTime(),
+		Size:  fi.Size(),
+		IsNew: isNew,
+	}
+	msg.Key, msg.Flags = splitInfo(name)
+
+	var raw []byte
+
+	raw, err = readHeader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.Header, _, err = email.ParseHeader(raw)
+	if err != nil || msg.Header == nil {
+		msg.Header = &email.Header{}
+		return msg, nil
+	}
+
+	var fields = msg.Header.Filter(email.FieldTypeDate)
+	if len(fields) > 0 {
+		var date = fields[0].Date()
+		if date != nil {
+			msg.Date = *date
+		}
+	}
+
+	return msg, nil
+}
+
+// readHeader read the message header until the first empty line.
+// Line that end with LF only is converted to CRLF.
+func readHeader(r io.Reader) (raw []byte, err error) {
+	var (
+		reader = bufio.NewReader(r)
+
+		line []byte
+	)
+	for {
+		line, err = reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			break
+		}
+		raw = append(raw, line...)
+		raw = append(raw, '\r', '\n')
+		if err != nil {
+			break
+		}
+	}
+	raw = append(raw, '\r', '\n')
+	return raw, nil
+}
@@ -6,8 +6,10 @@ package email
 
 import (
 	"bytes"
-	"crypto/rsa"
+	"crypto"
+	"crypto/ed25519"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
@@ -114,6 +116,9 @@ func ParseMessage(raw []byte) (msg *Message, rest []byte, err error) {
 	if err != nil {
 		return nil, rest, fmt.Errorf("%s: %w", logp, err)
 	}
+	if hdr == nil {
+		hdr = &Header{}
+	}
 
 	boundary = hdr.Boundary()
 
@@ -123,11 +128,151 @@ func ParseMessage(raw []byte) (msg *Message, rest []byte, err error) {
 	}
 
 	msg.Header = *hdr
-	msg.Body = *body
+	if body != nil {
+		msg.Body = *body
+	}
 
 	return msg, rest, nil
 }
 
+// AddAttachment attach the content read from r as file with name.
+// If the contentType is empty, it will be detected from the file name
+// extension or from the content.
+func (msg *Message) AddAttachment(name, contentType string, r io.Reader) (err error) {
+	var logp = `AddAttachment`
+
+	err = msg.attach(DispositionAttachment, name, contentType, ``, r)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return nil
+}
+
+// AddInline add the content read from r as inline part with Content-ID,
+// that can be referenced from HTML body using "cid:<contentID>", for
+// example
+//
+//	<img src="cid:logo@example.com">
+//
+// If the contentType is empty, it will be detected from the file name
+// extension or from the content.
+func (msg *Message) AddInline(contentID, name, contentType string, r io.Reader) (err error) {
+	var logp = `AddInline`
+
+	if len(contentID) == 0 {
+		return fmt.Errorf(`%s: empty Content-ID`, logp)
+	}
+
+	err = msg.attach(DispositionInline, name, contentType, contentID, r)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return nil
+}
+
+// AttachFile attach the content of file to the message.
+func (msg *Message) AttachFile(path string) (err error) {
+	var (
+		logp = `AttachFile`
+		f    *os.File
+	)
+
+	f, err = os.Open(path)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	defer f.Close()
+
+	err = msg.attach(DispositionAttachment, path, ``, ``, f)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return nil
+}
+
+// InlineFile add the content of file as inline part with Content-ID.
+// See [Message.AddInline] for more information.
+func (msg *Message) InlineFile(contentID, path string) (err error) {
+	var (
+		logp = `InlineFile`
+		f    *os.File
+	)
+
+	if len(contentID) == 0 {
+		return fmt.Errorf(`%s: empty Content-ID`, logp)
+	}
+
+	f, err = os.Open(path)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	defer f.Close()
+
+	err = msg.attach(DispositionInline, path, ``, contentID, f)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return nil
+}
+
+func (msg *Message) attach(disposition, name, contentType, contentID string, r io.Reader) (err error) {
+	var (
+		content []byte
+		mime    *MIME
+	)
+
+	content, err = io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	mime, err = newAttachment(disposition, name, contentType, contentID, content)
+	if err != nil {
+		return err
+	}
+
+	msg.Body.Add(mime)
+
+	return nil
+}
+
+// Attachments return list of attachment in the message, including the
+// attachment inside the nested multipart.
+func (msg *Message) Attachments() (list []*MIME) {
+	_ = msg.Walk(func(mime *MIME) error {
+		if mime.IsAttachment() {
+			list = append(list, mime)
+		}
+		return nil
+	})
+	return list
+}
+
+// Inline return the inline part with specific Content-ID, or nil if not
+// exist.
+func (msg *Message) Inline(contentID string) (inline *MIME) {
+	_ = msg.Walk(func(mime *MIME) error {
+		if inline == nil && mime.IsInline() && mime.ContentID() == contentID {
+			inline = mime
+		}
+		return nil
+	})
+	return inline
+}
+
+// Walk call fn for each body part in the message, including the nested
+// parts, from top to bottom.
+// If fn return an error, the walk stopped and the error is returned.
+func (msg *Message) Walk(fn func(mime *MIME) error) (err error) {
+	for _, mime := range msg.Body.Parts {
+		err = mime.walk(fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // AddCC add one or more recipients to the message header CC.
 func (msg *Message) AddCC(mailboxes string) (err error) {
 	err = msg.addMailboxes(FieldTypeCC, []byte(mailboxes))
@@ -158,9 +303,12 @@ func (msg *Message) addMailboxes(ft FieldType, mailboxes []byte) error {
 // The only required fields in signature is SDID and Selector, any other
 // required fields that are empty will be initialized with default values.
 //
+// The private key pk must be *rsa.PrivateKey for RSA signing algorithm or
+// ed25519.PrivateKey for "ed25519-sha256" algorithm (RFC 8463).
+//
 // Upon calling this function, any field values in header and body MUST be
 // already encoded.
-func (msg *Message) DKIMSign(pk *rsa.PrivateKey, sig *dkim.Signature) (err error) {
+func (msg *Message) DKIMSign(pk crypto.Signer, sig *dkim.Signature) (err error) {
 	if pk == nil {
 		return fmt.Errorf("email: empty private key for signing")
 	}
@@ -168,6 +316,13 @@ func (msg *Message) DKIMSign(pk *rsa.PrivateKey, sig *dkim.Signature) (err error
 		return fmt.Errorf("email: empty signature for signing")
 	}
 
+	if sig.Alg == nil {
+		if _, ok := pk.(ed25519.PrivateKey); ok {
+			var signAlg = dkim.SignAlgED25519
+			sig.Alg = &signAlg
+		}
+	}
+
 	sig.SetDefault()
 	msg.setDKIMHeaders(sig)
 
@@ -190,12 +345,11 @@ func (msg *Message) DKIMSign(pk *rsa.PrivateKey, sig *dkim.Signature) (err error
 	_, sig.BodyHash = sig.Hash(msg.CanonBody())
 
 	dkimField := &Field{
-		Type:     FieldTypeDKIMSignature,
-		Name:     fieldNames[FieldTypeDKIMSignature],
-		Value:    string(sig.Pack(false)),
-		oriName:  `DKIM-Signature`,
-		oriValue: string(sig.Pack(true)),
+		Type:    FieldTypeDKIMSignature,
+		Name:    fieldNames[FieldTypeDKIMSignature],
+		oriName: `DKIM-Signature`,
 	}
+	dkimField.setValue(sig.Pack(true))
 
 	subHeader := &Header{
 		fields: make([]*Field, len(msg.Header.fields)),
@@ -210,8 +364,7 @@ func (msg *Message) DKIMSign(pk *rsa.PrivateKey, sig *dkim.Signature) (err error
 	}
 
 	// Regenerate the DKIM field again with non empty signature "b=".
-	dkimField.Value = string(sig.Pack(false))
-	dkimField.oriValue = string(sig.Pack(true))
+	dkimField.setValue(sig.Pack(true))
 
 	msg.Header.PushTop(dkimField)
 
@@ -219,7 +372,14 @@ func (msg *Message) DKIMSign(pk *rsa.PrivateKey, sig *dkim.Signature) (err error
 }
 
 // DKIMVerify verify the message signature using DKIM.
+// The public key is retrieved from [dkim.DefaultKeyPool].
 func (msg *Message) DKIMVerify() (*dkim.Status, error) {
+	return msg.DKIMVerifyWith(dkim.DefaultKeyPool)
+}
+
+// DKIMVerifyWith verify the message signature using DKIM, with public key
+// retrieved from the key pool kp.
+func (msg *Message) DKIMVerifyWith(kp *dkim.KeyPool) (*dkim.Status, error) {
 	// Do not run verify again if the message has no DKIM-Signature or
 	// already permanent failed.
 	if msg.dkimStatus != nil {
@@ -266,7 +426,7 @@ func (msg *Message) DKIMVerify() (*dkim.Status, error) {
 
 	// Get the public key.
 	dname := fmt.Sprintf("%s._domainkey.%s", sig.Selector, sig.SDID)
-	key, err := dkim.DefaultKeyPool.Get(dname)
+	key, err := kp.Get(dname)
 	if err != nil {
 		if strings.Contains(err.Error(), "timeout") {
 			msg.dkimStatus.Type = dkim.StatusTempFail
@@ -286,7 +446,7 @@ func (msg *Message) DKIMVerify() (*dkim.Status, error) {
 		err = fmt.Errorf("email: body hash did not verify")
 		msg.dkimStatus.Type = dkim.StatusPermFail
 		msg.dkimStatus.Error = err
-		return nil, err
+		return msg.dkimStatus, err
 	}
 
 	canonHeader := msg.CanonHeader(subHeader, subHeader.fields[0])
@@ -364,6 +524,16 @@ func (msg *Message) SetID(id string) {
 	_ = msg.Header.Set(FieldTypeMessageID, []byte(id))
 }
 
+// Subject return the decoded value of header field Subject.
+func (msg *Message) Subject() string {
+	var fields = msg.Header.Filter(FieldTypeSubject)
+	if len(fields) == 0 {
+		return ``
+	}
+	var subject, _ = DecodeHeader(strings.TrimSpace(fields[0].Value))
+	return subject
+}
+
 // SetSubject set or replace the subject.
 // It will do nothing if the subject is empty.
 func (msg *Message) SetSubject(subject string) {
@@ -477,6 +647,21 @@ func (msg *Message) CanonHeader(subHeader *Header, dkimField *Field) []byte {
 // to text/html.
 // If both the text and HTML parts exist, the generated content-type will be
 // set to multipart/alternative.
+//
+// If the message has inline parts, the body and inline parts are wrapped
+// inside multipart/related.
+// If the message has attachments, the body and attachments are wrapped
+// inside multipart/mixed.
+// For example, message with text, HTML, inline image, and attachment
+// will have the following structure,
+//
+//	multipart/mixed
+//	├── multipart/related
+//	│   ├── multipart/alternative
+//	│   │   ├── text/plain
+//	│   │   └── text/html
+//	│   └── image/png (inline)
+//	└── application/pdf (attachment)
 func (msg *Message) Pack() (out []byte, err error) {
 	// TODO: check from, to, subject.
 
@@ -517,6 +702,14 @@ func (msg *Message) Pack() (out []byte, err error) {
 		msg.SetID(id)
 	}
 
+	if msg.hasAttached() {
+		out, err = msg.packMultipartMixed()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", logp, err)
+		}
+		return out, nil
+	}
+
 	if len(msg.Body.Parts) >= 2 {
 		out, err = msg.packMultipartAlternative()
 		if err != nil {
@@ -595,6 +788,104 @@ func (msg *Message) packMultipartAlternative() (out []byte, err error) {
 	return buf.Bytes(), nil
 }
 
+// hasAttached return true if the message body contains attachment or
+// inline part.
+func (msg *Message) hasAttached() bool {
+	for _, mime := range msg.Body.Parts {
+		if mime.isAttached() {
+			return true
+		}
+	}
+	return false
+}
+
+// packMultipartMixed pack the message that contains attachments or inline
+// parts into tree of multipart.
+func (msg *Message) packMultipartMixed() (out []byte, err error) {
+	var (
+		bodies      []*MIME
+		inlines     []*MIME
+		attachments []*MIME
+		top         *MIME
+		mime        *MIME
+		buf         bytes.Buffer
+	)
+
+	// Make sure the text part written first.
+	mime = msg.Body.getPart(topText, subPlain)
+	if mime != nil {
+		bodies = append(bodies, mime)
+	}
+	mime = msg.Body.getPart(topText, subHTML)
+	if mime != nil {
+		bodies = append(bodies, mime)
+	}
+
+	for _, mime = range msg.Body.Parts {
+		switch {
+		case mime.IsAttachment():
+			attachments = append(attachments, mime)
+		case mime.IsInline():
+			inlines = append(inlines, mime)
+		case mime.isContentType(topText, subPlain), mime.isContentType(topText, subHTML):
+			// Already added.
+		default:
+			bodies = append(bodies, mime)
+		}
+	}
+
+	switch len(bodies) {
+	case 0:
+	case 1:
+		top = bodies[0]
+	default:
+		top, err = newMultipartMIME(`alternative`, bodies)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(inlines) > 0 {
+		if top != nil {
+			inlines = append([]*MIME{top}, inlines...)
+		}
+		top, err = newMultipartMIME(`related`, inlines)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(attachments) > 0 {
+		if top != nil {
+			attachments = append([]*MIME{top}, attachments...)
+		}
+		top, err = newMultipartMIME(`mixed`, attachments)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = msg.Header.Set(FieldTypeMIMEVersion, []byte(mimeVersion1))
+	if err != nil {
+		return nil, err
+	}
+
+	err = msg.Header.Set(FieldTypeContentType, []byte(top.contentType.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = msg.Header.WriteTo(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	buf.WriteString("\r\n")
+	buf.Write(top.Content)
+
+	return buf.Bytes(), nil
+}
+
 func (msg *Message) packSingle() (out []byte, err error) {
 	var (
 		mime = msg.Body.Parts[0]
@@ -5,8 +5,10 @@
 package email
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/shuLhan/share/lib/email/dkim"
@@ -123,6 +125,133 @@ func TestMessageParseMessage(t *testing.T) {
 	}
 }
 
+func TestMessage_AddAttachment(t *testing.T) {
+	var (
+		msg Message
+		err error
+	)
+
+	var (
+		logo   = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+		report = bytes.Repeat([]byte("%PDF-1.4\x00\xff"), 20)
+		notes  = []byte("Catatan rapat.\r\nBaris kedua: €10.\r\n")
+	)
+
+	err = msg.SetBodyText([]byte(`See attachments.`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = msg.SetBodyHtml([]byte(`<p>See <img src="cid:logo@example.com"></p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = msg.AddInline(`logo@example.com`, `logo.png`, ``, bytes.NewReader(logo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = msg.AddAttachment(`laporan 2024.pdf`, ``, bytes.NewReader(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = msg.AddAttachment(`catatan-€.txt`, `text/plain; charset="utf-8"`, bytes.NewReader(notes))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Setting the body text should not replace the text attachment.
+	err = msg.SetBodyText([]byte(`See attachments below.`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packed []byte
+
+	packed, err = msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got *Message
+
+	got, _, err = ParseMessage(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		expTree = []string{
+			`multipart/related`,
+			`multipart/alternative`,
+			`text/plain`,
+			`text/html`,
+			`image/png`,
+			`application/pdf`,
+			`text/plain`,
+		}
+		gotTree []string
+	)
+	err = got.Walk(func(mime *MIME) error {
+		var ct = mime.ContentType()
+		gotTree = append(gotTree, ct.Top+`/`+ct.Sub)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Content-Type`, `multipart/mixed`, got.Header.ContentType().Top+`/`+got.Header.ContentType().Sub)
+	test.Assert(t, `Walk`, expTree, gotTree)
+
+	type attachment struct {
+		name     string
+		encoding string
+		content  []byte
+	}
+
+	var (
+		expAttachments = []attachment{{
+			name:     `laporan 2024.pdf`,
+			encoding: encodingBase64,
+			content:  report,
+		}, {
+			name:     `catatan-€.txt`,
+			encoding: encodingQuotedPrintable,
+			content:  notes,
+		}}
+		gotAttachments []attachment
+		content        []byte
+	)
+	for _, mime := range got.Attachments() {
+		content, err = mime.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotAttachments = append(gotAttachments, attachment{
+			name:     mime.Filename(),
+			encoding: mime.transferEncoding(),
+			content:  content,
+		})
+	}
+	test.Assert(t, `Attachments`, expAttachments, gotAttachments)
+
+	var inline = got.Inline(`logo@example.com`)
+	if inline == nil {
+		t.Fatal(`Inline: logo@example.com not found`)
+	}
+	content, err = inline.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Inline`, logo, content)
+
+	var html = got.Body.Parts[0].Parts[0].Parts[1]
+	content, err = html.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `HTML body`, `<p>See <img src="cid:logo@example.com"></p>`,
+		strings.TrimSpace(string(content)))
+}
+
 func TestMessage_AddCC(t *testing.T) {
 	var (
 		msg Message
@@ -503,6 +632,60 @@ func TestMessage_SetSubject(t *testing.T) {
 	}
 }
 
+func TestMessage_Pack_international(t *testing.T) {
+	var (
+		msg Message
+		err error
+	)
+
+	err = msg.SetFrom(`Budi Santoso <budi@contoh.id>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = msg.SetTo(`山田太郎 <山田@例え.jp>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = msg.AddTo(`"Aminah, Siti" <siti@contoh.id>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.SetSubject(`会議の件 / Rapat`)
+
+	err = msg.SetBodyText([]byte(`body`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packed []byte
+
+	packed, err = msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got *Message
+
+	got, _, err = ParseMessage(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `Subject`, `会議の件 / Rapat`, got.Subject())
+
+	var (
+		expTo = []string{
+			`山田太郎 山田@例え.jp`,
+			`Aminah, Siti siti@contoh.id`,
+		}
+		gotTo []string
+	)
+	for _, mbox := range got.Header.Filter(FieldTypeTo)[0].Mailboxes() {
+		gotTo = append(gotTo, mbox.Name+` `+mbox.Address)
+	}
+	test.Assert(t, `To`, expTo, gotTo)
+}
+
 func TestMessage_SetTo(t *testing.T) {
 	var (
 		msg Message
@@ -6,10 +6,16 @@ package email
 
 import (
 	"bytes"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
+	gomime "mime"
 	"mime/quotedprintable"
+	"net/http"
+	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	libbytes "github.com/shuLhan/share/lib/bytes"
 )
@@ -20,6 +26,11 @@ type MIME struct {
 
 	Header  *Header
 	Content []byte
+
+	// Parts contains the nested body parts if the content type is
+	// multipart, for example "multipart/related" inside
+	// "multipart/mixed".
+	Parts []*MIME
 }
 
 // newMIME append new body with specific content type and charset.
@@ -61,6 +72,158 @@ func newMIME(contentType, content []byte) (mime *MIME, err error) {
 	return mime, nil
 }
 
+// newAttachment create new MIME for file attachment or inline part.
+// If the contentType is empty, it will be detected from the file name
+// extension or from the content.
+// The text content is encoded using quoted-printable, while the other is
+// encoded using base64.
+func newAttachment(disposition, name, contentType, contentID string, content []byte) (
+	mime *MIME, err error,
+) {
+	if len(contentType) == 0 {
+		contentType = gomime.TypeByExtension(filepath.Ext(name))
+		if len(contentType) == 0 {
+			contentType = http.DetectContentType(content)
+		}
+	}
+
+	var ct *ContentType
+
+	ct, err = ParseContentType([]byte(contentType))
+	if err != nil {
+		return nil, err
+	}
+
+	var cd = &ContentDisposition{
+		Type: disposition,
+	}
+	if len(name) > 0 {
+		name = filepath.Base(name)
+		ct.Params = append(ct.Params, newParam(ParamNameName, name))
+		cd.Params = append(cd.Params, newParam(ParamNameFilename, name))
+	}
+
+	mime = &MIME{
+		Header: &Header{},
+	}
+
+	err = mime.Header.Set(FieldTypeContentType, []byte(ct.String()))
+	if err != nil {
+		return nil, err
+	}
+	mime.contentType = mime.Header.ContentType()
+
+	err = mime.Header.Set(FieldTypeContentDisposition, []byte(cd.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contentID) > 0 {
+		err = mime.Header.Set(FieldTypeContentID, []byte(`<`+contentID+`>`))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if isTextContent(ct, content) {
+		err = mime.Header.Set(FieldTypeContentTransferEncoding, []byte(encodingQuotedPrintable))
+		if err != nil {
+			return nil, err
+		}
+
+		var w = quotedprintable.NewWriter(&buf)
+		_, err = w.Write(content)
+		if err != nil {
+			return nil, err
+		}
+		w.Close()
+
+		// Use soft line break to end the content that does not end
+		// with new line, so the decoded content is equal with the
+		// original.
+		if !bytes.HasSuffix(buf.Bytes(), []byte{lf}) {
+			buf.WriteString("=\r\n")
+		}
+	} else {
+		err = mime.Header.Set(FieldTypeContentTransferEncoding, []byte(encodingBase64))
+		if err != nil {
+			return nil, err
+		}
+
+		writeBase64(&buf, content)
+	}
+
+	mime.Content = buf.Bytes()
+
+	return mime, nil
+}
+
+// writeBase64 write the base64 encoded content into buf, split into lines
+// of 76 characters, RFC 2045 section 6.8.
+func writeBase64(buf *bytes.Buffer, content []byte) {
+	var enc = base64.StdEncoding.EncodeToString(content)
+	for len(enc) > 76 {
+		buf.WriteString(enc[:76])
+		buf.WriteString("\r\n")
+		enc = enc[76:]
+	}
+	buf.WriteString(enc)
+	buf.WriteString("\r\n")
+}
+
+// newMultipartMIME create new MIME with content type "multipart/<sub>"
+// that contains the parts.
+func newMultipartMIME(sub string, parts []*MIME) (mime *MIME, err error) {
+	mime = &MIME{
+		Header: &Header{},
+		Parts:  parts,
+	}
+
+	err = mime.Header.Set(FieldTypeContentType, []byte(topMultipart+`/`+sub))
+	if err != nil {
+		return nil, err
+	}
+	mime.contentType = mime.Header.ContentType()
+
+	var boundary = randomString(32)
+	mime.contentType.SetBoundary(boundary)
+
+	var buf bytes.Buffer
+
+	for _, part := range parts {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		_, err = part.WriteTo(&buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	mime.Content = buf.Bytes()
+
+	return mime, nil
+}
+
+// isTextContent return true if the content type is text and the content
+// is valid UTF-8 without control characters, so it can be encoded using
+// quoted-printable.
+func isTextContent(ct *ContentType, content []byte) bool {
+	if !strings.EqualFold(ct.Top, topText) {
+		return false
+	}
+	if !utf8.Valid(content) {
+		return false
+	}
+	for _, c := range content {
+		if c < 32 && c != '\t' && c != cr && c != lf {
+			return false
+		}
+	}
+	return true
+}
+
 // ParseBodyPart parse one body part using boundary and return the rest of
 // body.
 func ParseBodyPart(raw, boundary []byte) (mime *MIME, rest []byte, err error) {
@@ -110,6 +273,13 @@ func ParseBodyPart(raw, boundary []byte) (mime *MIME, rest []byte, err error) {
 	if err != nil {
 		return nil, raw, err
 	}
+	if mime.Header != nil {
+		mime.contentType = mime.Header.ContentType()
+	}
+	if mime.contentType == nil {
+		// Default content type as defined in RFC 2045 section 5.2.
+		mime.contentType, _ = ParseContentType(nil)
+	}
 
 	parser.Reset(rest, []byte{lf})
 
@@ -141,7 +311,180 @@ func ParseBodyPart(raw, boundary []byte) (mime *MIME, rest []byte, err error) {
 
 	rest, _ = parser.Stop()
 
-	return mime, rest, err
+	err = mime.parseParts()
+	if err != nil {
+		return nil, raw, err
+	}
+
+	return mime, rest, nil
+}
+
+// parseParts parse the nested body parts if the MIME is multipart.
+func (mime *MIME) parseParts() (err error) {
+	if !strings.EqualFold(mime.contentType.Top, topMultipart) {
+		return nil
+	}
+
+	var boundary = mime.contentType.GetParamValue(ParamNameBoundary)
+	if len(boundary) == 0 {
+		return nil
+	}
+
+	var body *Body
+
+	body, _, err = ParseBody(mime.Content, []byte(boundary))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		mime.Parts = body.Parts
+	}
+	return nil
+}
+
+// ContentID return the value of header field "Content-ID" without the
+// angle brackets.
+func (mime *MIME) ContentID() string {
+	if mime.Header == nil {
+		return ``
+	}
+	var fields = mime.Header.Filter(FieldTypeContentID)
+	if len(fields) == 0 {
+		return ``
+	}
+	var id = strings.TrimSpace(fields[0].Value)
+	id = strings.TrimPrefix(id, `<`)
+	return strings.TrimSuffix(id, `>`)
+}
+
+// ContentType return the content type of MIME.
+func (mime *MIME) ContentType() *ContentType {
+	return mime.contentType
+}
+
+// Decode return the content decoded based on the header field
+// "Content-Transfer-Encoding".
+// Only base64 and quoted-printable encoding are decoded, the other
+// encoding is returned as is.
+func (mime *MIME) Decode() (content []byte, err error) {
+	var logp = `Decode`
+
+	switch mime.transferEncoding() {
+	case encodingBase64:
+		var raw = bytes.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, mime.Content)
+
+		content = make([]byte, base64.StdEncoding.DecodedLen(len(raw)))
+
+		var n int
+
+		n, err = base64.StdEncoding.Decode(content, raw)
+		if err != nil {
+			return nil, fmt.Errorf(`%s: %w`, logp, err)
+		}
+		return content[:n], nil
+
+	case encodingQuotedPrintable:
+		content, err = io.ReadAll(quotedprintable.NewReader(bytes.NewReader(mime.Content)))
+		if err != nil {
+			return nil, fmt.Errorf(`%s: %w`, logp, err)
+		}
+		return content, nil
+	}
+	return mime.Content, nil
+}
+
+// Disposition return the unpacked value of header field
+// "Content-Disposition", or nil if the field does not exist or invalid.
+func (mime *MIME) Disposition() (cd *ContentDisposition) {
+	if mime.Header == nil {
+		return nil
+	}
+	var fields = mime.Header.Filter(FieldTypeContentDisposition)
+	if len(fields) == 0 {
+		return nil
+	}
+	cd, _ = ParseContentDisposition([]byte(fields[0].Value))
+	return cd
+}
+
+// Filename return the file name of MIME from parameter "filename" in
+// Content-Disposition, or from parameter "name" in Content-Type.
+func (mime *MIME) Filename() (name string) {
+	var cd = mime.Disposition()
+	if cd != nil {
+		name = cd.Filename()
+		if len(name) > 0 {
+			return name
+		}
+	}
+	if mime.contentType != nil {
+		name = paramValue(mime.contentType.Params, ParamNameName)
+	}
+	return name
+}
+
+// IsAttachment return true if the MIME is an attachment, either it has
+// Content-Disposition "attachment" or it has file name but not an inline
+// part.
+func (mime *MIME) IsAttachment() bool {
+	var cd = mime.Disposition()
+	if cd != nil {
+		if cd.Type == DispositionAttachment {
+			return true
+		}
+		if cd.Type == DispositionInline {
+			return false
+		}
+	}
+	if mime.contentType != nil && strings.EqualFold(mime.contentType.Top, topMultipart) {
+		return false
+	}
+	return len(mime.Filename()) > 0
+}
+
+// IsInline return true if the MIME is an inline part that referenced by
+// its Content-ID from other part, for example an image inside HTML.
+func (mime *MIME) IsInline() bool {
+	return len(mime.ContentID()) > 0 && !mime.IsAttachment()
+}
+
+// isAttached return true if the MIME is an attachment or inline part.
+func (mime *MIME) isAttached() bool {
+	return mime.IsAttachment() || mime.IsInline()
+}
+
+// transferEncoding return the lower case value of header field
+// "Content-Transfer-Encoding".
+func (mime *MIME) transferEncoding() string {
+	if mime.Header == nil {
+		return ``
+	}
+	var fields = mime.Header.Filter(FieldTypeContentTransferEncoding)
+	if len(fields) == 0 {
+		return ``
+	}
+	return strings.ToLower(strings.TrimSpace(fields[0].Value))
+}
+
+// walk call fn for the MIME and each of its nested parts, from top to
+// bottom.
+func (mime *MIME) walk(fn func(mime *MIME) error) (err error) {
+	err = fn(mime)
+	if err != nil {
+		return err
+	}
+	for _, part := range mime.Parts {
+		err = part.walk(fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (mime *MIME) isContentType(top, sub string) bool {
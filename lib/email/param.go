@@ -4,6 +4,15 @@
 
 package email
 
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	libbytes "github.com/shuLhan/share/lib/bytes"
+)
+
 // List of known parameter name in header field's value.
 const (
 	// Parameter for Text Media Type, RFC 2046 section 4.1.
@@ -20,6 +29,13 @@ const (
 	ParamNameID     = `id`
 	ParamNameNumber = `number`
 	ParamNameTotal  = `total`
+
+	// Parameters for "Content-Disposition", RFC 2183.
+	ParamNameFilename = `filename`
+
+	// Parameter for file name in "Content-Type", as used by older mail
+	// clients.
+	ParamNameName = `name`
 )
 
 // Param represent a mapping of key with its value.
@@ -28,3 +44,207 @@ type Param struct {
 	Value  string
 	Quoted bool // Quoted is true if value is contains special characters.
 }
+
+// newParam create new Param with key and value.
+// If the value contains non US-ASCII or characters that cannot be quoted,
+// it will be encoded using the extended notation as defined in RFC 2231
+// section 4, for example
+//
+//	filename*=UTF-8''%E2%82%AC%20rates.txt
+func newParam(key, value string) (param Param) {
+	var (
+		sb strings.Builder
+		c  byte
+		x  int
+	)
+	for x = 0; x < len(value); x++ {
+		c = value[x]
+		if c < 32 || c >= 127 || c == '"' || c == '\\' {
+			break
+		}
+		// Leading, trailing, and sequence of spaces are not
+		// preserved in header field value.
+		if c == ' ' && (x == 0 || x == len(value)-1 || value[x+1] == ' ') {
+			break
+		}
+	}
+	if x == len(value) {
+		return Param{Key: key, Value: value, Quoted: true}
+	}
+
+	sb.WriteString(`UTF-8''`)
+	for x = 0; x < len(value); x++ {
+		c = value[x]
+		if isAttributeChar(c) {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, `%%%02X`, c)
+	}
+	return Param{Key: key + `*`, Value: sb.String()}
+}
+
+// isAttributeChar return true if c is allowed in the extended parameter
+// value without percent encoding, RFC 2231 section 7.
+func isAttributeChar(c byte) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+		return true
+	}
+	switch c {
+	case '!', '#', '$', '&', '+', '-', '.', '^', '_', '`', '|', '~':
+		return true
+	}
+	return false
+}
+
+// paramValue return the value of parameter name from list of params.
+// The parameter value that use the extended notation or continuations (RFC
+// 2231) is decoded and merged into single value.
+// It will return empty string if no parameter with that name.
+func paramValue(params []Param, name string) string {
+	var (
+		sections = map[int]string{}
+		prefix   = strings.ToLower(name) + `*`
+
+		p        Param
+		key      string
+		value    string
+		charset  string
+		n        int
+		err      error
+		isFound  bool
+		isExtend bool
+	)
+
+	for _, p = range params {
+		key = strings.ToLower(p.Key)
+		if key == strings.ToLower(name) {
+			if !isFound {
+				value = p.Value
+				isFound = true
+			}
+			continue
+		}
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		key = key[len(prefix):]
+		isExtend = strings.HasSuffix(key, `*`) || len(key) == 0
+		key = strings.TrimSuffix(key, `*`)
+		if len(key) == 0 {
+			n = 0
+		} else {
+			n, err = strconv.Atoi(key)
+			if err != nil || n < 0 {
+				continue
+			}
+		}
+		if !isExtend {
+			sections[n] = p.Value
+			continue
+		}
+		if n == 0 {
+			charset, p.Value = splitExtendedValue(p.Value)
+		}
+		sections[n] = percentDecode(p.Value)
+	}
+	if len(sections) == 0 {
+		return value
+	}
+
+	var sb strings.Builder
+	for n = 0; ; n++ {
+		value, isFound = sections[n]
+		if !isFound {
+			break
+		}
+		sb.WriteString(value)
+	}
+	value, _ = decodeCharset(charset, sb.String())
+	return value
+}
+
+// splitExtendedValue split the extended value "charset'language'value"
+// into charset and value.
+func splitExtendedValue(raw string) (charset, value string) {
+	var fields = strings.SplitN(raw, `'`, 3)
+	if len(fields) != 3 {
+		return ``, raw
+	}
+	return strings.ToLower(fields[0]), fields[2]
+}
+
+// percentDecode decode the "%XX" encoded characters in raw.
+// Invalid encoding is kept as is.
+func percentDecode(raw string) string {
+	var (
+		out = make([]byte, 0, len(raw))
+		v   uint64
+		err error
+	)
+	for x := 0; x < len(raw); x++ {
+		if raw[x] == '%' && x+2 < len(raw) {
+			v, err = strconv.ParseUint(raw[x+1:x+3], 16, 8)
+			if err == nil {
+				out = append(out, byte(v))
+				x += 2
+				continue
+			}
+		}
+		out = append(out, raw[x])
+	}
+	return string(out)
+}
+
+// parseParams parse the list of parameters "; key=value; ..." after the
+// header field value, where c is the last delimiter read by parser.
+func parseParams(parser *libbytes.Parser, c byte) (params []Param, err error) {
+	var tok []byte
+
+	parser.SetDelimiters([]byte{'=', '"', ';'})
+	for c == ';' {
+		param := Param{}
+
+		tok, c = parser.ReadNoSpace()
+		if c == 0 {
+			// Ignore key without value.
+			param.Key = string(tok)
+			break
+		}
+
+		if !isValidToken(tok, false) {
+			return nil, fmt.Errorf(`invalid parameter key '%s'`, tok)
+		}
+		if c != '=' {
+			return nil, fmt.Errorf(`expecting '=', got '%c'`, c)
+		}
+		param.Key = string(tok)
+
+		tok, c = parser.ReadNoSpace()
+		if c == '"' {
+			if len(tok) != 0 {
+				return nil, fmt.Errorf(`invalid parameter value '%s'`, tok)
+			}
+
+			// The param value may contain '=' or ';', remove it
+			// temporarily.
+			parser.RemoveDelimiters([]byte{'=', ';'})
+
+			tok, c = parser.Read()
+			if c != '"' {
+				return nil, errors.New(`missing closing quote`)
+			}
+			param.Quoted = true
+
+			parser.AddDelimiters([]byte{'=', ';'})
+
+			c = parser.Skip()
+		}
+		if !isValidToken(tok, param.Quoted) {
+			return nil, fmt.Errorf(`invalid parameter value '%s'`, tok)
+		}
+		param.Value = string(tok)
+		params = append(params, param)
+	}
+	return params, nil
+}
@@ -0,0 +1,280 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// List of OpenPGP/MIME content types, RFC 3156.
+const (
+	contentTypePGPSignature = `application/pgp-signature`
+	contentTypePGPEncrypted = `application/pgp-encrypted`
+	contentTypeOctetStream  = `application/octet-stream`
+)
+
+// PGPSign sign the message using OpenPGP/MIME, RFC 3156 section 5.
+// The message is packed and converted into "multipart/signed" with the
+// armored detached signature created by signer.
+// The signer private key must be decrypted before calling this method.
+func (msg *Message) PGPSign(signer *openpgp.Entity) (err error) {
+	var (
+		logp = `PGPSign`
+
+		outer  *Header
+		entity []byte
+		sig    bytes.Buffer
+	)
+
+	outer, entity, err = msg.packEntity()
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	err = openpgp.ArmoredDetachSign(&sig, signer, bytes.NewReader(entity), nil)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	sig.WriteString("\r\n")
+
+	var sigHeader = &Header{}
+
+	err = sigHeader.Set(FieldTypeContentType, []byte(contentTypePGPSignature+`; name=signature.asc`))
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	err = sigHeader.Set(FieldTypeContentDisposition, []byte(`attachment; filename=signature.asc`))
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var (
+		boundary = randomString(32)
+		content  []byte
+	)
+
+	content, err = signedContent(boundary, entity, sigHeader, toCRLF(sig.Bytes()))
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	// The default hash used by openpgp is SHA-256.
+	var contentType = fmt.Sprintf(`multipart/signed; protocol="%s"; micalg=pgp-sha256; boundary=%s`,
+		contentTypePGPSignature, boundary)
+
+	err = msg.setEntity(outer, contentType, ``, content)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return nil
+}
+
+// PGPVerify verify the OpenPGP/MIME "multipart/signed" message using the
+// public keys in keyring.
+// On success it will return the entity of signer.
+func (msg *Message) PGPVerify(keyring openpgp.KeyRing) (signer *openpgp.Entity, err error) {
+	var (
+		logp = `PGPVerify`
+
+		entity  []byte
+		sigPart *MIME
+		sig     []byte
+	)
+
+	entity, sigPart, err = msg.splitSigned(contentTypePGPSignature)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	sig, err = sigPart.Decode()
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	signer, err = openpgp.CheckArmoredDetachedSignature(keyring,
+		bytes.NewReader(entity), bytes.NewReader(sig), nil)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return signer, nil
+}
+
+// PGPEncrypt encrypt the message using OpenPGP/MIME, RFC 3156 section 4.
+// The message is packed and converted into "multipart/encrypted" with the
+// armored message encrypted for each entity in recipients.
+// If signer is not nil, the message is also signed, as described in RFC
+// 3156 section 6.2.
+func (msg *Message) PGPEncrypt(recipients []*openpgp.Entity, signer *openpgp.Entity) (err error) {
+	var (
+		logp = `PGPEncrypt`
+
+		outer   *Header
+		entity  []byte
+		armored bytes.Buffer
+		wa      io.WriteCloser
+		we      io.WriteCloser
+	)
+
+	if len(recipients) == 0 {
+		return fmt.Errorf(`%s: empty recipients`, logp)
+	}
+
+	outer, entity, err = msg.packEntity()
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	wa, err = armor.Encode(&armored, `PGP MESSAGE`, nil)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	we, err = openpgp.Encrypt(wa, recipients, signer, nil, nil)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	_, err = we.Write(entity)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	err = we.Close()
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	err = wa.Close()
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	armored.WriteString("\r\n")
+
+	var (
+		versionPart = &MIME{
+			Header:  &Header{},
+			Content: []byte("Version: 1\r\n"),
+		}
+		dataPart = &MIME{
+			Header:  &Header{},
+			Content: toCRLF(armored.Bytes()),
+		}
+	)
+
+	err = versionPart.Header.Set(FieldTypeContentType, []byte(contentTypePGPEncrypted))
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	err = dataPart.Header.Set(FieldTypeContentType, []byte(contentTypeOctetStream+`; name=encrypted.asc`))
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var (
+		boundary = randomString(32)
+		buf      bytes.Buffer
+	)
+	for _, part := range []*MIME{versionPart, dataPart} {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		_, err = part.WriteTo(&buf)
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	var contentType = fmt.Sprintf(`multipart/encrypted; protocol="%s"; boundary=%s`,
+		contentTypePGPEncrypted, boundary)
+
+	err = msg.setEntity(outer, contentType, ``, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return nil
+}
+
+// PGPDecrypt decrypt the OpenPGP/MIME "multipart/encrypted" message using
+// the private keys in keyring.
+// On success, the header "Content-" fields and the body of message are
+// replaced with the decrypted entity.
+//
+// If the encrypted message is also signed, the signature is verified
+// using the keyring and the entity of signer is returned.
+func (msg *Message) PGPDecrypt(keyring openpgp.KeyRing) (signer *openpgp.Entity, err error) {
+	var (
+		logp = `PGPDecrypt`
+		ct   = msg.Header.ContentType()
+	)
+
+	if ct == nil || !ct.isEqual(&ContentType{Top: topMultipart, Sub: `encrypted`}) {
+		return nil, fmt.Errorf(`%s: not a multipart/encrypted message`, logp)
+	}
+	var protocol = ct.GetParamValue(`protocol`)
+	if !strings.EqualFold(protocol, contentTypePGPEncrypted) {
+		return nil, fmt.Errorf(`%s: unknown encryption protocol %q`, logp, protocol)
+	}
+
+	var body *Body
+
+	body, _, err = ParseBody(msg.rawBody(), []byte(ct.GetParamValue(ParamNameBoundary)))
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if body == nil || len(body.Parts) != 2 {
+		return nil, fmt.Errorf(`%s: invalid number of encrypted parts`, logp)
+	}
+
+	var (
+		block *armor.Block
+		md    *openpgp.MessageDetails
+		data  []byte
+	)
+
+	data, err = body.Parts[1].Decode()
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	block, err = armor.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	md, err = openpgp.ReadMessage(block.Body, keyring, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	// The signature is verified only after the whole body has been
+	// read.
+	data, err = io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if md.IsSigned {
+		if md.SignatureError != nil {
+			return nil, fmt.Errorf(`%s: %w`, logp, md.SignatureError)
+		}
+		if md.SignedBy == nil {
+			return nil, fmt.Errorf(`%s: unknown signer`, logp)
+		}
+		signer = md.SignedBy.Entity
+	}
+
+	err = msg.unwrapEntity(data)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return signer, nil
+}
+
+// toCRLF convert the bare LF in text into CRLF.
+func toCRLF(text []byte) []byte {
+	text = bytes.ReplaceAll(text, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(text, []byte("\n"), []byte("\r\n"))
+}
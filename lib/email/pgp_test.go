@@ -0,0 +1,150 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func newTestPGPEntity(t *testing.T, name, email string) (entity *openpgp.Entity) {
+	var (
+		// Set the DefaultHash to make the entity prefer SHA-256,
+		// otherwise the Encrypt use RIPEMD-160.
+		cfg = &packet.Config{
+			DefaultHash: crypto.SHA256,
+		}
+		err error
+	)
+
+	entity, err = openpgp.NewEntity(name, ``, email, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+func TestMessage_PGPSign(t *testing.T) {
+	var (
+		alice = newTestPGPEntity(t, `Alice`, `alice@example.com`)
+		eve   = newTestPGPEntity(t, `Eve`, `eve@example.net`)
+		msg   = newTestMessage(t)
+
+		err error
+	)
+
+	err = msg.PGPSign(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packed []byte
+
+	packed, err = msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got *Message
+
+	got, _, err = ParseMessage(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ct = got.Header.ContentType()
+	test.Assert(t, `Content-Type`, `multipart/signed`, ct.Top+`/`+ct.Sub)
+	test.Assert(t, `protocol`, contentTypePGPSignature, ct.GetParamValue(`protocol`))
+	test.Assert(t, `micalg`, `pgp-sha256`, ct.GetParamValue(`micalg`))
+
+	var signer *openpgp.Entity
+
+	signer, err = got.PGPVerify(openpgp.EntityList{eve, alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `signer`, alice.PrimaryKey.KeyId, signer.PrimaryKey.KeyId)
+
+	_, err = got.PGPVerify(openpgp.EntityList{eve})
+	test.Assert(t, `unknown signer`, `PGPVerify: openpgp: signature made by unknown entity`, err.Error())
+
+	var tampered = bytes.Replace(packed, []byte(`The plan is attached.`), []byte(`The plan is changed.!`), 1)
+
+	got, _, err = ParseMessage(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = got.PGPVerify(openpgp.EntityList{alice})
+	if err == nil {
+		t.Fatal(`expecting error on tampered message`)
+	}
+}
+
+func TestMessage_PGPEncrypt(t *testing.T) {
+	var (
+		alice = newTestPGPEntity(t, `Alice`, `alice@example.com`)
+		bob   = newTestPGPEntity(t, `Bob`, `bob@example.org`)
+		eve   = newTestPGPEntity(t, `Eve`, `eve@example.net`)
+		msg   = newTestMessage(t)
+
+		err error
+	)
+
+	err = msg.PGPEncrypt([]*openpgp.Entity{bob}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packed []byte
+
+	packed, err = msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(packed, []byte(`The plan is attached.`)) {
+		t.Fatal(`encrypted message contains plain text`)
+	}
+
+	var got *Message
+
+	got, _, err = ParseMessage(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ct = got.Header.ContentType()
+	test.Assert(t, `Content-Type`, `multipart/encrypted`, ct.Top+`/`+ct.Sub)
+
+	_, err = got.PGPDecrypt(openpgp.EntityList{eve})
+	test.Assert(t, `other recipient`, `PGPDecrypt: openpgp: incorrect key`, err.Error())
+
+	var signer *openpgp.Entity
+
+	signer, err = got.PGPDecrypt(openpgp.EntityList{bob, alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `signer`, alice.PrimaryKey.KeyId, signer.PrimaryKey.KeyId)
+	test.Assert(t, `Subject`, `Secret plan`, got.Subject())
+
+	var plan = got.Attachments()
+	if len(plan) != 1 {
+		t.Fatalf(`got %d attachments, want 1`, len(plan))
+	}
+
+	var content []byte
+
+	content, err = plan[0].Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `attachment`, "Step 1.\r\nStep 2.\r\n", string(content))
+}
@@ -0,0 +1,206 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// List of S/MIME content types, RFC 8551.
+const (
+	contentTypeSMIMESignature = `application/pkcs7-signature`
+	contentTypeSMIMEMime      = `application/pkcs7-mime`
+)
+
+// SMIMESign sign the message using S/MIME, RFC 8551 section 3.5.
+// The message is packed and converted into "multipart/signed" with the
+// detached CMS signature, created using the private key pk of the
+// certificate cert.
+//
+// The certificate and private key can be loaded using [x509.ParseCertificate]
+// and [crypto.LoadPrivateKey] from package lib/crypto.
+//
+// [crypto.LoadPrivateKey]: https://pkg.go.dev/github.com/shuLhan/share/lib/crypto#LoadPrivateKey
+func (msg *Message) SMIMESign(cert *x509.Certificate, pk crypto.Signer) (err error) {
+	var (
+		logp = `SMIMESign`
+
+		outer  *Header
+		entity []byte
+		sig    []byte
+	)
+
+	outer, entity, err = msg.packEntity()
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	sig, err = cmsSign(entity, cert, pk)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var sigHeader = &Header{}
+
+	err = sigHeader.Set(FieldTypeContentType, []byte(contentTypeSMIMESignature+`; name=smime.p7s`))
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	err = sigHeader.Set(FieldTypeContentTransferEncoding, []byte(encodingBase64))
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	err = sigHeader.Set(FieldTypeContentDisposition, []byte(`attachment; filename=smime.p7s`))
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var buf bytes.Buffer
+	writeBase64(&buf, sig)
+
+	var (
+		boundary = randomString(32)
+		content  []byte
+	)
+
+	content, err = signedContent(boundary, entity, sigHeader, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var contentType = fmt.Sprintf(`multipart/signed; protocol="%s"; micalg=sha-256; boundary=%s`,
+		contentTypeSMIMESignature, boundary)
+
+	err = msg.setEntity(outer, contentType, ``, content)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return nil
+}
+
+// SMIMEVerify verify the S/MIME "multipart/signed" message.
+// On success it will return the certificate of signer.
+//
+// If roots is not nil, the signer certificate must be issued by one of
+// the certificate in roots; otherwise only the signature is verified.
+func (msg *Message) SMIMEVerify(roots *x509.CertPool) (signer *x509.Certificate, err error) {
+	var (
+		logp = `SMIMEVerify`
+
+		entity  []byte
+		sigPart *MIME
+		sig     []byte
+	)
+
+	entity, sigPart, err = msg.splitSigned(contentTypeSMIMESignature)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	sig, err = sigPart.Decode()
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	signer, err = cmsVerify(sig, entity, roots)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return signer, nil
+}
+
+// SMIMEEncrypt encrypt the message using S/MIME, RFC 8551 section 3.3.
+// The message is packed and converted into "application/pkcs7-mime" with
+// CMS enveloped-data for each certificate in recipients.
+// Only recipients with RSA public key are supported.
+func (msg *Message) SMIMEEncrypt(recipients []*x509.Certificate) (err error) {
+	var (
+		logp = `SMIMEEncrypt`
+
+		outer  *Header
+		entity []byte
+		der    []byte
+	)
+
+	if len(recipients) == 0 {
+		return fmt.Errorf(`%s: empty recipients`, logp)
+	}
+
+	outer, entity, err = msg.packEntity()
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	der, err = cmsEncrypt(entity, recipients)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var buf bytes.Buffer
+	writeBase64(&buf, der)
+
+	var contentType = contentTypeSMIMEMime + `; smime-type=enveloped-data; name=smime.p7m`
+
+	err = msg.setEntity(outer, contentType, encodingBase64, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	err = msg.Header.Set(FieldTypeContentDisposition, []byte(`attachment; filename=smime.p7m`))
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return nil
+}
+
+// SMIMEDecrypt decrypt the S/MIME "application/pkcs7-mime" message using
+// the certificate cert and its private key pk.
+// On success, the header "Content-" fields and the body of message are
+// replaced with the decrypted entity.
+func (msg *Message) SMIMEDecrypt(cert *x509.Certificate, pk crypto.Decrypter) (err error) {
+	var (
+		logp = `SMIMEDecrypt`
+		ct   = msg.Header.ContentType()
+	)
+
+	if ct == nil || !strings.EqualFold(ct.Top+`/`+ct.Sub, contentTypeSMIMEMime) {
+		return fmt.Errorf(`%s: not an %s message`, logp, contentTypeSMIMEMime)
+	}
+	var smimeType = ct.GetParamValue(`smime-type`)
+	if len(smimeType) > 0 && !strings.EqualFold(smimeType, `enveloped-data`) {
+		return fmt.Errorf(`%s: unsupported smime-type %q`, logp, smimeType)
+	}
+
+	var (
+		part = &MIME{
+			Header:  &msg.Header,
+			Content: msg.rawBody(),
+		}
+		der    []byte
+		entity []byte
+	)
+
+	der, err = part.Decode()
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if len(der) == 0 {
+		return fmt.Errorf(`%s: empty content`, logp)
+	}
+
+	entity, err = cmsDecrypt(der, cert, pk)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	err = msg.unwrapEntity(entity)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return nil
+}
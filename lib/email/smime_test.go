@@ -0,0 +1,310 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+// newTestMessage create message with text, HTML, and attachment for
+// testing signing and encryption.
+func newTestMessage(t *testing.T) (msg *Message) {
+	var err error
+
+	msg = &Message{}
+
+	err = msg.SetFrom(`Alice <alice@example.com>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = msg.SetTo(`Bob <bob@example.org>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.SetSubject(`Secret plan`)
+
+	err = msg.SetBodyText([]byte(`The plan is attached.`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = msg.SetBodyHtml([]byte(`<p>The plan is attached.</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = msg.AddAttachment(`plan.txt`, ``, bytes.NewReader([]byte("Step 1.\r\nStep 2.\r\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// newTestCertificate create self-signed certificate and its RSA private
+// key.
+func newTestCertificate(t *testing.T, name string) (cert *x509.Certificate, pk *rsa.PrivateKey) {
+	var err error
+
+	pk, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tmpl = &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		EmailAddresses:        []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	var der []byte
+
+	der, err = x509.CreateCertificate(rand.Reader, tmpl, tmpl, &pk.PublicKey, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, pk
+}
+
+func TestMessage_SMIMESign(t *testing.T) {
+	var (
+		cert, pk = newTestCertificate(t, `alice@example.com`)
+		other, _ = newTestCertificate(t, `eve@example.net`)
+		msg      = newTestMessage(t)
+
+		err error
+	)
+
+	err = msg.SMIMESign(cert, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packed []byte
+
+	packed, err = msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got *Message
+
+	got, _, err = ParseMessage(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `Subject`, `Secret plan`, got.Subject())
+
+	var ct = got.Header.ContentType()
+	test.Assert(t, `Content-Type`, `multipart/signed`, ct.Top+`/`+ct.Sub)
+	test.Assert(t, `protocol`, contentTypeSMIMESignature, ct.GetParamValue(`protocol`))
+
+	var (
+		roots  = x509.NewCertPool()
+		signer *x509.Certificate
+	)
+	roots.AddCert(cert)
+
+	signer, err = got.SMIMEVerify(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `signer`, cert.Raw, signer.Raw)
+
+	// Verify against other roots.
+	var otherRoots = x509.NewCertPool()
+	otherRoots.AddCert(other)
+
+	_, err = got.SMIMEVerify(otherRoots)
+	if err == nil {
+		t.Fatal(`expecting error on untrusted signer`)
+	}
+
+	// Verify tampered message.
+	var tampered = bytes.Replace(packed, []byte(`The plan is attached.`), []byte(`The plan is changed.!`), 1)
+
+	got, _, err = ParseMessage(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = got.SMIMEVerify(nil)
+	test.Assert(t, `tampered`, `SMIMEVerify: message digest mismatch`, err.Error())
+}
+
+func TestMessage_SMIMEEncrypt(t *testing.T) {
+	var (
+		cert, pk      = newTestCertificate(t, `bob@example.org`)
+		other, otherK = newTestCertificate(t, `eve@example.net`)
+		msg           = newTestMessage(t)
+
+		err error
+	)
+
+	err = msg.SMIMEEncrypt([]*x509.Certificate{cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packed []byte
+
+	packed, err = msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(packed, []byte(`The plan is attached.`)) {
+		t.Fatal(`encrypted message contains plain text`)
+	}
+
+	var got *Message
+
+	got, _, err = ParseMessage(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = got.SMIMEDecrypt(other, otherK)
+	test.Assert(t, `other recipient`, `SMIMEDecrypt: no recipient info for certificate`, err.Error())
+
+	err = got.SMIMEDecrypt(cert, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `Subject`, `Secret plan`, got.Subject())
+
+	var plan = got.Attachments()
+	if len(plan) != 1 {
+		t.Fatalf(`got %d attachments, want 1`, len(plan))
+	}
+
+	var content []byte
+
+	content, err = plan[0].Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `attachment`, "Step 1.\r\nStep 2.\r\n", string(content))
+
+	var (
+		expTree = []string{
+			`multipart/alternative`,
+			`text/plain`,
+			`text/html`,
+			`text/plain`,
+		}
+		gotTree []string
+	)
+	err = got.Walk(func(mime *MIME) error {
+		var ct = mime.ContentType()
+		gotTree = append(gotTree, ct.Top+`/`+ct.Sub)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `tree`, expTree, gotTree)
+}
+
+func TestCMSVerify_encapsulated(t *testing.T) {
+	var (
+		cert, pk = newTestCertificate(t, `alice@example.com`)
+		body     = []byte("Content-Type: text/plain\r\n\r\nPay Bob 10.\r\n")
+		forged   = []byte("Content-Type: text/plain\r\n\r\nPay Eve 1000.\r\n")
+
+		sd  cmsSignedData
+		der []byte
+		err error
+	)
+
+	// Sign the body and encapsulate it into the signature, as if the
+	// signature is reused from other message.
+	der, err = cmsSign(body, cert, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = unmarshalContentInfo(der, oidCMSSignedData, &sd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var econtent []byte
+
+	econtent, err = asn1.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd.EncapContentInfo.EContent = asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      econtent,
+	}
+	der, err = marshalContentInfo(oidCMSSignedData, sd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cmsVerify(der, forged, nil)
+	test.Assert(t, `forged`, `encapsulated content mismatch`, err.Error())
+
+	var signer *x509.Certificate
+
+	signer, err = cmsVerify(der, body, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `signer`, cert.Raw, signer.Raw)
+}
+
+func TestCMSDecrypt_padding(t *testing.T) {
+	var (
+		cert, pk = newTestCertificate(t, `bob@example.org`)
+
+		ed  cmsEnvelopedData
+		der []byte
+		err error
+	)
+
+	// The content is less than one block, so the padding bytes are in
+	// the first block and can be changed through the IV.
+	der, err = cmsEncrypt([]byte(`Hello`), []*x509.Certificate{cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = unmarshalContentInfo(der, oidCMSEnvelopedData, &ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip one padding byte, keeping the last byte unchanged.
+	// The first two bytes of IV parameter is the OCTET STRING tag and
+	// length.
+	ed.EncryptedContentInfo.ContentEncryptionAlgorithm.Parameters.FullBytes[2+6] ^= 1
+
+	der, err = marshalContentInfo(oidCMSEnvelopedData, ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cmsDecrypt(der, cert, pk)
+	test.Assert(t, `tampered padding`, `invalid padding`, err.Error())
+}
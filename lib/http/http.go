@@ -249,6 +249,13 @@ const (
 	AcceptRangesNone  = `none`
 )
 
+// List of header value for HTTP header Cache-Control.
+const (
+	// CacheControlImmutable mark the response as never changes, used
+	// for fingerprinted files.
+	CacheControlImmutable = `public, max-age=31536000, immutable`
+)
+
 // List of known "Content-Encoding" header values.
 const (
 	ContentEncodingBzip2    = `bzip2`
@@ -0,0 +1,27 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net/http"
+	"strings"
+)
+
+// prefixHandler define the handler for request with path prefix, see
+// [Server.RegisterHandler].
+type prefixHandler struct {
+	handler http.Handler
+	prefix  string
+}
+
+// isMatch return true if the path is equal to prefix or start with prefix
+// followed by slash.
+func (ph *prefixHandler) isMatch(path string) bool {
+	var prefix = strings.TrimSuffix(ph.prefix, `/`)
+	if path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, prefix+`/`)
+}
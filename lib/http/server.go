@@ -41,6 +41,7 @@ type Server struct {
 	Options *ServerOptions
 
 	evals        []Evaluator
+	handlers     []*prefixHandler
 	routeDeletes []*route
 	routeGets    []*route
 	routePatches []*route
@@ -127,6 +128,49 @@ func (srv *Server) RegisterEndpoint(ep *Endpoint) (err error) {
 	return err
 }
 
+// RegisterHandler register the handler for all requests, with any
+// method, where the path is equal to prefix or start with prefix followed
+// by slash.
+// For example, the prefix "/dav" match "/dav" and "/dav/a", but not
+// "/davx".
+// The handler take precedence over the registered endpoints and the
+// Memfs.
+//
+// The handler is called directly, bypassing the Evaluators registered
+// using [Server.RegisterEvaluator] and the CORS handling, so the handler
+// should do its own authentication.
+//
+// This method can be used to mount the handler that implement
+// non-standard methods, for example WebDAV.
+// It will return [ErrEndpointAmbiguous] if the same prefix is already
+// registered.
+func (srv *Server) RegisterHandler(prefix string, handler http.Handler) (err error) {
+	var logp = `RegisterHandler`
+
+	if len(prefix) == 0 || prefix[0] != '/' {
+		return fmt.Errorf(`%s: invalid prefix %q`, logp, prefix)
+	}
+	if handler == nil {
+		return fmt.Errorf(`%s: nil handler`, logp)
+	}
+	for _, ph := range srv.handlers {
+		if ph.prefix == prefix {
+			return fmt.Errorf(`%s: %w`, logp, ErrEndpointAmbiguous)
+		}
+	}
+
+	srv.handlers = append(srv.handlers, &prefixHandler{
+		prefix:  prefix,
+		handler: handler,
+	})
+
+	// Sort the handlers by longest prefix first.
+	sort.SliceStable(srv.handlers, func(x, y int) bool {
+		return len(srv.handlers[x].prefix) > len(srv.handlers[y].prefix)
+	})
+	return nil
+}
+
 // RegisterSSE register Server-Sent Events endpoint.
 // It will return an error if the [SSEEndpoint.Call] field is not set or
 // [ErrEndpointAmbiguous] if the same path is already registered.
@@ -272,6 +316,13 @@ func (srv *Server) registerPut(ep *Endpoint) (err error) {
 
 // ServeHTTP handle mapping of client request to registered endpoints.
 func (srv *Server) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	for _, ph := range srv.handlers {
+		if ph.isMatch(req.URL.Path) {
+			ph.handler.ServeHTTP(res, req)
+			return
+		}
+	}
+
 	switch req.Method {
 	case http.MethodDelete:
 		srv.handleDelete(res, req)
@@ -409,6 +460,12 @@ func (srv *Server) handleDelete(res http.ResponseWriter, req *http.Request) {
 // response body set to the content of file.
 // If the request Method is HEAD, only the header will be sent back to client.
 //
+// The ETag is set to the hash of file content, or to the modification time
+// if the content is not mapped in memory.
+// If the request Path is fingerprinted path (see [memfs.Options]
+// Fingerprint) of the current file content, the response Cache-Control is
+// set to immutable.
+//
 // If the request Path is not exist it will return 404 Not Found.
 func (srv *Server) HandleFS(res http.ResponseWriter, req *http.Request) {
 	var (
@@ -450,9 +507,17 @@ func (srv *Server) HandleFS(res http.ResponseWriter, req *http.Request) {
 
 	res.Header().Set(HeaderContentType, node.ContentType)
 
+	if node.Fingerprint() == req.URL.Path &&
+		srv.Options.Memfs.IsFingerprint(req.URL.Path) {
+		res.Header().Set(HeaderCacheControl, CacheControlImmutable)
+	}
+
 	var nodeModtime = node.ModTime().Unix()
 
-	responseETag = strconv.FormatInt(nodeModtime, 10)
+	responseETag = node.ETag()
+	if len(responseETag) == 0 {
+		responseETag = strconv.FormatInt(nodeModtime, 10)
+	}
 	requestETag = req.Header.Get(HeaderIfNoneMatch)
 	if requestETag == responseETag {
 		res.WriteHeader(http.StatusNotModified)
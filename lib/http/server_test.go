@@ -12,6 +12,7 @@ import (
 	"log"
 	"mime"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -591,6 +592,56 @@ func TestRegisterPut(t *testing.T) {
 	}
 }
 
+func TestRegisterHandler(t *testing.T) {
+	var handler = http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(res, `%s %s`, req.Method, req.URL.Path)
+	})
+
+	var err = testServer.RegisterHandler(`/handler`, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testServer.RegisterHandler(`/handler`, handler)
+	test.Assert(t, `duplicate prefix`, `RegisterHandler: `+ErrEndpointAmbiguous.Error(), err.Error())
+
+	var req *http.Request
+
+	req, err = http.NewRequest(`PROPFIND`, testServerURL+`/handler/a`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res *http.Response
+
+	res, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body []byte
+
+	body, err = io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+
+	test.Assert(t, `StatusCode`, http.StatusMultiStatus, res.StatusCode)
+	test.Assert(t, `body`, `PROPFIND /handler/a`, string(body))
+
+	// The path that start with prefix but not at the path segment
+	// should not be handled by handler.
+	res, err = client.Get(testServerURL + `/handlerfoo/a`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+
+	test.Assert(t, `StatusCode /handlerfoo/a`, http.StatusNotFound, res.StatusCode)
+}
+
 func TestServeHTTPOptions(t *testing.T) {
 	epDelete := &Endpoint{
 		Path:         "/options",
@@ -907,6 +958,110 @@ func TestServer_Options_HandleFS(t *testing.T) {
 	}
 }
 
+func TestServer_HandleFS_fingerprint(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		err error
+	)
+
+	err = os.WriteFile(filepath.Join(dir, `app.js`), []byte(`app`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mfs *memfs.MemFS
+
+	mfs, err = memfs.New(&memfs.Options{
+		Root:        dir,
+		Fingerprint: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var srv *Server
+
+	srv, err = NewServer(&ServerOptions{
+		Memfs: mfs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		desc            string
+		reqPath         string
+		reqETag         string
+		expCacheControl string
+		expStatusCode   int
+	}
+
+	var (
+		etag = mfs.MustGet(`/app.js`).ETag()
+
+		cases = []testCase{{
+			desc:          `original path`,
+			reqPath:       `/app.js`,
+			expStatusCode: http.StatusOK,
+		}, {
+			desc:            `fingerprinted path`,
+			reqPath:         mfs.Fingerprint(`/app.js`),
+			expCacheControl: CacheControlImmutable,
+			expStatusCode:   http.StatusOK,
+		}, {
+			desc:          `with If-None-Match`,
+			reqPath:       `/app.js`,
+			reqETag:       etag,
+			expStatusCode: http.StatusNotModified,
+		}}
+
+		c   testCase
+		req *http.Request
+		res *httptest.ResponseRecorder
+	)
+	for _, c = range cases {
+		req = httptest.NewRequest(http.MethodGet, c.reqPath, nil)
+		if len(c.reqETag) != 0 {
+			req.Header.Set(HeaderIfNoneMatch, c.reqETag)
+		}
+		res = httptest.NewRecorder()
+
+		srv.HandleFS(res, req)
+
+		test.Assert(t, c.desc+`: status code`, c.expStatusCode, res.Code)
+		test.Assert(t, c.desc+`: Cache-Control`, c.expCacheControl,
+			res.Header().Get(HeaderCacheControl))
+		if c.expStatusCode == http.StatusOK {
+			test.Assert(t, c.desc+`: ETag`, etag, res.Header().Get(HeaderETag))
+			test.Assert(t, c.desc+`: body`, `app`, res.Body.String())
+		}
+	}
+
+	// The old fingerprinted path must not be resolved to the new
+	// content.
+
+	var oldPath = mfs.Fingerprint(`/app.js`)
+
+	err = os.WriteFile(filepath.Join(dir, `app.js`), []byte(`new app`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mfs.MustGet(`/app.js`).Update(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, oldPath, nil)
+	res = httptest.NewRecorder()
+
+	srv.HandleFS(res, req)
+
+	test.Assert(t, `stale fingerprinted path: status code`,
+		http.StatusNotFound, res.Code)
+	test.Assert(t, `stale fingerprinted path: Cache-Control`, ``,
+		res.Header().Get(HeaderCacheControl))
+}
+
 func TestServer_handleRange(t *testing.T) {
 	var (
 		clOpts = &ClientOptions{
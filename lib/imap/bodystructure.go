@@ -0,0 +1,396 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imap
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/shuLhan/share/lib/email"
+)
+
+// bodyPart contains the message or one of its MIME part, used to generate
+// the BODYSTRUCTURE and to fetch the body section.
+type bodyPart struct {
+	header *email.Header
+	ct     *email.ContentType
+
+	// message contains the encapsulated message if the part is
+	// "message/rfc822".
+	message *bodyPart
+
+	rawHeader []byte
+	content   []byte
+
+	parts []*bodyPart
+}
+
+// parseBodyPart parse the raw message into bodyPart using
+// [email.ParseMessage].
+// The message that cannot be parsed is treated as "text/plain".
+func parseBodyPart(raw []byte) (bp *bodyPart) {
+	bp = &bodyPart{}
+	bp.rawHeader, bp.content = splitMessage(raw)
+
+	var msg, _, err = email.ParseMessage(raw)
+	if err != nil || msg == nil {
+		bp.header = &email.Header{}
+		bp.ct, _ = email.ParseContentType(nil)
+		return bp
+	}
+
+	bp.header = &msg.Header
+	bp.ct = bp.header.ContentType()
+	if bp.ct == nil {
+		bp.ct, _ = email.ParseContentType(nil)
+	}
+	if bp.isMultipart() && len(bp.header.Boundary()) > 0 {
+		for _, mime := range msg.Body.Parts {
+			bp.parts = append(bp.parts, newBodyPart(mime))
+		}
+	}
+	return bp
+}
+
+// newBodyPart create bodyPart from MIME.
+func newBodyPart(mime *email.MIME) (bp *bodyPart) {
+	bp = &bodyPart{
+		header:  mime.Header,
+		ct:      mime.ContentType(),
+		content: bytes.TrimSuffix(mime.Content, []byte("\r\n")),
+	}
+	if bp.header == nil {
+		bp.header = &email.Header{}
+	}
+	if bp.ct == nil {
+		bp.ct, _ = email.ParseContentType(nil)
+	}
+
+	var buf bytes.Buffer
+	_, _ = bp.header.WriteTo(&buf)
+	buf.WriteString("\r\n")
+	bp.rawHeader = buf.Bytes()
+
+	if strings.EqualFold(bp.ct.Top, `message`) && strings.EqualFold(bp.ct.Sub, `rfc822`) {
+		bp.message = parseBodyPart(bp.content)
+	}
+	for _, sub := range mime.Parts {
+		bp.parts = append(bp.parts, newBodyPart(sub))
+	}
+	return bp
+}
+
+// splitMessage split the raw message into header, including the empty
+// line, and its text.
+func splitMessage(raw []byte) (header, text []byte) {
+	if bytes.HasPrefix(raw, []byte("\r\n")) {
+		return raw[:2], raw[2:]
+	}
+	var x = bytes.Index(raw, []byte("\r\n\r\n"))
+	if x < 0 {
+		return raw, nil
+	}
+	return raw[:x+4], raw[x+4:]
+}
+
+func (bp *bodyPart) isMultipart() bool {
+	return strings.EqualFold(bp.ct.Top, `multipart`)
+}
+
+// child return the n-th body part, start from 1.
+func (bp *bodyPart) child(n int) *bodyPart {
+	var cur = bp
+	if cur.message != nil {
+		cur = cur.message
+	}
+	if cur.isMultipart() {
+		if n < 1 || n > len(cur.parts) {
+			return nil
+		}
+		return cur.parts[n-1]
+	}
+	if n == 1 {
+		return cur
+	}
+	return nil
+}
+
+// section return the content of body section, or nil if the section does
+// not exist.
+func (bp *bodyPart) section(sec *section) (content []byte, ok bool) {
+	var cur = bp
+	for _, n := range sec.part {
+		cur = cur.child(n)
+		if cur == nil {
+			return nil, false
+		}
+	}
+
+	var msg = cur
+	if len(sec.part) > 0 {
+		msg = cur.message
+	}
+
+	switch sec.spec {
+	case ``:
+		if len(sec.part) == 0 {
+			return append(append([]byte{}, cur.rawHeader...), cur.content...), true
+		}
+		return cur.content, true
+	case sectionMIME:
+		if len(sec.part) == 0 {
+			return nil, false
+		}
+		return cur.rawHeader, true
+	}
+
+	if msg == nil {
+		return nil, false
+	}
+	switch sec.spec {
+	case sectionHeader:
+		return msg.rawHeader, true
+	case sectionText:
+		return msg.content, true
+	case sectionHeaderFields:
+		return filterHeader(msg.rawHeader, sec.fields, false), true
+	case sectionHeaderFieldsNot:
+		return filterHeader(msg.rawHeader, sec.fields, true), true
+	}
+	return nil, false
+}
+
+// filterHeader return the header fields that match, or not match if isNot
+// is true, with the list of names, followed by an empty line.
+func filterHeader(raw []byte, names []string, isNot bool) (out []byte) {
+	var (
+		lines   = bytes.SplitAfter(raw, []byte("\r\n"))
+		isMatch bool
+	)
+	for _, line := range lines {
+		if len(line) == 0 || bytes.Equal(line, []byte("\r\n")) {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			var name, _, _ = bytes.Cut(line, []byte{':'})
+			isMatch = false
+			for _, want := range names {
+				if strings.EqualFold(string(bytes.TrimSpace(name)), want) {
+					isMatch = true
+					break
+				}
+			}
+		}
+		if isMatch != isNot {
+			out = append(out, line...)
+		}
+	}
+	return append(out, '\r', '\n')
+}
+
+// structure return the BODYSTRUCTURE of body part, or BODY if isExt is
+// false.
+func (bp *bodyPart) structure(isExt bool) string {
+	var sb strings.Builder
+	bp.writeStructure(&sb, isExt)
+	return sb.String()
+}
+
+func (bp *bodyPart) writeStructure(sb *strings.Builder, isExt bool) {
+	sb.WriteByte('(')
+
+	if bp.isMultipart() && len(bp.parts) > 0 {
+		for _, sub := range bp.parts {
+			sub.writeStructure(sb, isExt)
+		}
+		sb.WriteByte(' ')
+		sb.WriteString(quote(strings.ToUpper(bp.ct.Sub)))
+		if isExt {
+			sb.WriteByte(' ')
+			sb.WriteString(formatParams(bp.ct.Params))
+			sb.WriteByte(' ')
+			sb.WriteString(bp.disposition())
+			sb.WriteString(` NIL NIL`)
+		}
+		sb.WriteByte(')')
+		return
+	}
+
+	var encoding = strings.ToUpper(headerValue(bp.header, email.FieldTypeContentTransferEncoding))
+	if len(encoding) == 0 {
+		encoding = `7BIT`
+	}
+
+	sb.WriteString(quote(strings.ToUpper(bp.ct.Top)))
+	sb.WriteByte(' ')
+	sb.WriteString(quote(strings.ToUpper(bp.ct.Sub)))
+	sb.WriteByte(' ')
+	sb.WriteString(formatParams(bp.ct.Params))
+	sb.WriteByte(' ')
+	sb.WriteString(nstring(headerValue(bp.header, email.FieldTypeContentID)))
+	sb.WriteByte(' ')
+	sb.WriteString(nstring(headerValue(bp.header, email.FieldTypeContentDescription)))
+	sb.WriteByte(' ')
+	sb.WriteString(quote(encoding))
+	sb.WriteByte(' ')
+	sb.WriteString(strconv.Itoa(len(bp.content)))
+
+	if bp.message != nil {
+		sb.WriteByte(' ')
+		sb.WriteString(envelope(bp.message.header))
+		sb.WriteByte(' ')
+		bp.message.writeStructure(sb, isExt)
+		sb.WriteByte(' ')
+		sb.WriteString(strconv.Itoa(countLines(bp.content)))
+	} else if strings.EqualFold(bp.ct.Top, `text`) {
+		sb.WriteByte(' ')
+		sb.WriteString(strconv.Itoa(countLines(bp.content)))
+	}
+
+	if isExt {
+		sb.WriteString(` NIL `)
+		sb.WriteString(bp.disposition())
+		sb.WriteString(` NIL NIL`)
+	}
+	sb.WriteByte(')')
+}
+
+// disposition return the body disposition, the type and its parameters,
+// or NIL if the part does not have Content-Disposition.
+func (bp *bodyPart) disposition() string {
+	var value = headerValue(bp.header, email.FieldTypeContentDisposition)
+	if len(value) == 0 {
+		return `NIL`
+	}
+	var cd, err = email.ParseContentDisposition([]byte(value))
+	if err != nil {
+		return `NIL`
+	}
+	return `(` + quote(strings.ToUpper(cd.Type)) + ` ` + formatParams(cd.Params) + `)`
+}
+
+// envelope return the ENVELOPE of message header.
+func envelope(hdr *email.Header) string {
+	var (
+		from    = formatAddresses(hdr, email.FieldTypeFrom)
+		sender  = formatAddresses(hdr, email.FieldTypeSender)
+		replyTo = formatAddresses(hdr, email.FieldTypeReplyTo)
+		sb      strings.Builder
+	)
+	if sender == `NIL` {
+		sender = from
+	}
+	if replyTo == `NIL` {
+		replyTo = from
+	}
+
+	sb.WriteByte('(')
+	sb.WriteString(nstring(headerValue(hdr, email.FieldTypeDate)))
+	sb.WriteByte(' ')
+	sb.WriteString(nstring(headerValue(hdr, email.FieldTypeSubject)))
+	sb.WriteByte(' ')
+	sb.WriteString(from)
+	sb.WriteByte(' ')
+	sb.WriteString(sender)
+	sb.WriteByte(' ')
+	sb.WriteString(replyTo)
+	sb.WriteByte(' ')
+	sb.WriteString(formatAddresses(hdr, email.FieldTypeTo))
+	sb.WriteByte(' ')
+	sb.WriteString(formatAddresses(hdr, email.FieldTypeCC))
+	sb.WriteByte(' ')
+	sb.WriteString(formatAddresses(hdr, email.FieldTypeBCC))
+	sb.WriteByte(' ')
+	sb.WriteString(nstring(headerValue(hdr, email.FieldTypeInReplyTo)))
+	sb.WriteByte(' ')
+	sb.WriteString(nstring(headerValue(hdr, email.FieldTypeMessageID)))
+	sb.WriteByte(')')
+
+	return sb.String()
+}
+
+// formatAddresses return the list of address structure of mailboxes in
+// header field with type ft, or NIL if the field does not exist.
+func formatAddresses(hdr *email.Header, ft email.FieldType) string {
+	var (
+		fields = hdr.Filter(ft)
+		sb     strings.Builder
+	)
+	for _, f := range fields {
+		for _, mbox := range f.Mailboxes() {
+			sb.WriteByte('(')
+			sb.WriteString(nstring(email.EncodeHeader(mbox.Name)))
+			sb.WriteString(` NIL `)
+			sb.WriteString(nstring(mbox.Local))
+			sb.WriteByte(' ')
+			sb.WriteString(nstring(mbox.Domain))
+			sb.WriteByte(')')
+		}
+	}
+	if sb.Len() == 0 {
+		return `NIL`
+	}
+	return `(` + sb.String() + `)`
+}
+
+// formatParams return the parenthesized list of parameters key and value,
+// or NIL if params is empty.
+func formatParams(params []email.Param) string {
+	if len(params) == 0 {
+		return `NIL`
+	}
+	var list = make([]string, 0, len(params)*2)
+	for _, p := range params {
+		list = append(list, quote(strings.ToUpper(p.Key)), quote(p.Value))
+	}
+	return `(` + strings.Join(list, ` `) + `)`
+}
+
+// headerValue return the value of the first field with type ft in header.
+func headerValue(hdr *email.Header, ft email.FieldType) string {
+	var fields = hdr.Filter(ft)
+	if len(fields) == 0 {
+		return ``
+	}
+	return strings.TrimSpace(fields[0].Value)
+}
+
+// countLines return the number of lines in content.
+func countLines(content []byte) (n int) {
+	n = bytes.Count(content, []byte{'\n'})
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// nstring return the quoted string or NIL if s is empty.
+func nstring(s string) string {
+	if len(s) == 0 {
+		return `NIL`
+	}
+	return quote(s)
+}
+
+// quote return the quoted string, or literal if s contains CR, LF, or non
+// US-ASCII characters.
+func quote(s string) string {
+	for x := 0; x < len(s); x++ {
+		if s[x] == '\r' || s[x] == '\n' || s[x] == 0 || s[x] >= 0x80 {
+			return `{` + strconv.Itoa(len(s)) + "}\r\n" + s
+		}
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for x := 0; x < len(s); x++ {
+		if s[x] == '"' || s[x] == '\\' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[x])
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,396 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxLineLength define the maximum length of command line, excluding
+	// the literals.
+	maxLineLength = 65536
+
+	// maxLiteralSize define the maximum size of literal string, for
+	// example the message in APPEND command.
+	maxLiteralSize = 64 << 20
+)
+
+// errLineTooLong returned when the command line is longer than
+// maxLineLength.
+var errLineTooLong = errors.New(`command line too long`)
+
+// field represent an argument in command, either a string or a
+// parenthesized list of fields.
+type field struct {
+	value string
+	list  []*field
+
+	isList bool
+
+	// isString is true if the value is quoted string or literal.
+	isString bool
+}
+
+// upper return the uppercase value of field.
+func (f *field) upper() string {
+	return strings.ToUpper(f.value)
+}
+
+// command contains the tag, name, and arguments of client command.
+type command struct {
+	tag  string
+	name string
+	args []*field
+}
+
+// commandReader read and parse the command from client.
+type commandReader struct {
+	reader *bufio.Reader
+
+	// cont is called to send the command continuation request before
+	// reading the synchronizing literal.
+	cont func() error
+
+	n int
+}
+
+// readCommand read the command line, including its literals.
+func (cr *commandReader) readCommand() (cmd *command, err error) {
+	var f *field
+
+	cr.n = 0
+	cmd = &command{}
+
+	cmd.tag, err = cr.readAtom()
+	if err != nil {
+		return nil, err
+	}
+	if len(cmd.tag) == 0 {
+		_ = cr.discard()
+		return nil, fmt.Errorf(`missing tag`)
+	}
+
+	err = cr.expectSpace()
+	if err != nil {
+		return cmd, err
+	}
+
+	f, err = cr.readField()
+	if err != nil {
+		return cmd, err
+	}
+	cmd.name = f.upper()
+	if len(cmd.name) == 0 || f.isList || f.isString {
+		_ = cr.discard()
+		return cmd, fmt.Errorf(`invalid command`)
+	}
+
+	cmd.args, err = cr.readFields(false)
+	if err != nil {
+		return cmd, err
+	}
+	return cmd, nil
+}
+
+// readLine read the raw line until CRLF, for example the continuation
+// response in AUTHENTICATE and "DONE" in IDLE.
+func (cr *commandReader) readLine() (line string, err error) {
+	var sb strings.Builder
+	for {
+		var c byte
+		c, err = cr.reader.ReadByte()
+		if err != nil {
+			return ``, err
+		}
+		if c == '\n' {
+			break
+		}
+		if sb.Len() >= maxLineLength {
+			_ = cr.discard()
+			return ``, errLineTooLong
+		}
+		sb.WriteByte(c)
+	}
+	return strings.TrimSuffix(sb.String(), "\r"), nil
+}
+
+// discard the rest of command line.
+func (cr *commandReader) discard() (err error) {
+	for {
+		var c byte
+		c, err = cr.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		if c == '\n' {
+			return nil
+		}
+	}
+}
+
+func (cr *commandReader) readByte() (c byte, err error) {
+	c, err = cr.reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	cr.n++
+	if cr.n > maxLineLength {
+		_ = cr.discard()
+		return 0, errLineTooLong
+	}
+	return c, nil
+}
+
+func (cr *commandReader) peekByte() (c byte, err error) {
+	var b []byte
+	b, err = cr.reader.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// expectSpace read a single space or the end of line.
+func (cr *commandReader) expectSpace() (err error) {
+	var c byte
+	c, err = cr.peekByte()
+	if err != nil {
+		return err
+	}
+	if c == '\r' || c == '\n' {
+		return nil
+	}
+	if c != ' ' {
+		_ = cr.discard()
+		return fmt.Errorf(`expecting space, got %q`, c)
+	}
+	_, err = cr.readByte()
+	return err
+}
+
+// readFields read the fields until the end of line, or until the
+// closing parenthesis if inList is true.
+func (cr *commandReader) readFields(inList bool) (list []*field, err error) {
+	var (
+		f *field
+		c byte
+	)
+	for {
+		c, err = cr.peekByte()
+		if err != nil {
+			return nil, err
+		}
+		switch c {
+		case ' ':
+			_, err = cr.readByte()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case '\r', '\n':
+			if inList {
+				_ = cr.discard()
+				return nil, fmt.Errorf(`missing closing parenthesis`)
+			}
+			err = cr.discard()
+			return list, err
+		case ')':
+			_, err = cr.readByte()
+			if err != nil {
+				return nil, err
+			}
+			if !inList {
+				_ = cr.discard()
+				return nil, fmt.Errorf(`unexpected closing parenthesis`)
+			}
+			return list, nil
+		}
+
+		f, err = cr.readField()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, f)
+	}
+}
+
+// readField read single atom, quoted string, literal, or list.
+func (cr *commandReader) readField() (f *field, err error) {
+	var c byte
+
+	c, err = cr.peekByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c {
+	case '(':
+		_, _ = cr.readByte()
+		f = &field{isList: true}
+		f.list, err = cr.readFields(true)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	case '"':
+		f = &field{isString: true}
+		f.value, err = cr.readQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	case '{':
+		f = &field{isString: true}
+		f.value, err = cr.readLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+
+	f = &field{}
+	f.value, err = cr.readAtom()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// readAtom read the atom until space, parenthesis, or end of line.
+// The characters inside the square brackets, like in "BODY[HEADER.FIELDS
+// (FROM)]", are part of atom.
+func (cr *commandReader) readAtom() (atom string, err error) {
+	var (
+		sb       strings.Builder
+		c        byte
+		brackets int
+	)
+	for {
+		c, err = cr.peekByte()
+		if err != nil {
+			return ``, err
+		}
+		if c == '\r' || c == '\n' {
+			break
+		}
+		if brackets == 0 && (c == ' ' || c == '(' || c == ')') {
+			break
+		}
+		switch c {
+		case '[':
+			brackets++
+		case ']':
+			brackets--
+		}
+		c, err = cr.readByte()
+		if err != nil {
+			return ``, err
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String(), nil
+}
+
+// readQuoted read the quoted string, with backslash as escape character.
+func (cr *commandReader) readQuoted() (s string, err error) {
+	var (
+		sb strings.Builder
+		c  byte
+	)
+
+	_, _ = cr.readByte()
+	for {
+		c, err = cr.readByte()
+		if err != nil {
+			return ``, err
+		}
+		switch c {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			c, err = cr.readByte()
+			if err != nil {
+				return ``, err
+			}
+		case '\r':
+			_ = cr.discard()
+			return ``, fmt.Errorf(`unterminated quoted string`)
+		case '\n':
+			return ``, fmt.Errorf(`unterminated quoted string`)
+		}
+		sb.WriteByte(c)
+	}
+}
+
+// readLiteral read the literal string "{n}" CRLF followed by n octets.
+// The non-synchronizing literal "{n+}" (RFC 7888) does not wait for the
+// continuation request.
+func (cr *commandReader) readLiteral() (s string, err error) {
+	var (
+		sb strings.Builder
+		c  byte
+	)
+
+	_, _ = cr.readByte()
+	for {
+		c, err = cr.readByte()
+		if err != nil {
+			return ``, err
+		}
+		if c == '}' {
+			break
+		}
+		sb.WriteByte(c)
+	}
+
+	var (
+		spec   = sb.String()
+		isSync = !strings.HasSuffix(spec, `+`)
+		size   int
+	)
+
+	size, err = strconv.Atoi(strings.TrimSuffix(spec, `+`))
+	if err != nil || size < 0 {
+		_ = cr.discard()
+		return ``, fmt.Errorf(`invalid literal size %q`, spec)
+	}
+	if size > maxLiteralSize {
+		_ = cr.discard()
+		return ``, fmt.Errorf(`literal size %d too large`, size)
+	}
+
+	c, err = cr.readByte()
+	if err == nil && c == '\r' {
+		c, err = cr.readByte()
+	}
+	if err != nil {
+		return ``, err
+	}
+	if c != '\n' {
+		_ = cr.discard()
+		return ``, fmt.Errorf(`missing CRLF after literal size`)
+	}
+
+	if isSync && cr.cont != nil {
+		err = cr.cont()
+		if err != nil {
+			return ``, err
+		}
+	}
+
+	var b = make([]byte, size)
+
+	_, err = io.ReadFull(cr.reader, b)
+	if err != nil {
+		return ``, err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package imap provide a minimal IMAP4rev1 (RFC 3501) and IMAP4rev2
+// (RFC 9051) server backed by [maildir.Manager].
+//
+// # Accounts and mailboxes
+//
+// The server authenticate the user using the accounts in
+// [smtp.Environment], the same environment used by the SMTP server, with
+// the full address "local@domain" as the user name.
+// System account, an account without password, cannot login.
+//
+// The maildir of each account is located at
+// "{DirMaildir}/{domain}/{local}".
+// The mailbox INBOX is the main maildir, while other mailboxes are
+// Maildir++ folders, for example mailbox "Archive" is stored in folder
+// ".Archive", with "." as the hierarchy delimiter.
+//
+// The message UIDs are stored in file "imap-uidlist" inside each folder.
+// The message flags \Seen, \Answered, \Flagged, \Deleted, and \Draft are
+// stored as maildir info flags "S", "R", "F", "T", and "D".
+// The message in "new" directory is moved to "cur" and marked as \Recent
+// when the mailbox is selected.
+//
+// # Commands
+//
+// The server support the following commands: CAPABILITY, NOOP, LOGOUT,
+// STARTTLS, LOGIN, AUTHENTICATE (PLAIN), ENABLE, SELECT, EXAMINE, CREATE,
+// LIST, LSUB, SUBSCRIBE, UNSUBSCRIBE, STATUS, APPEND, IDLE (RFC 2177),
+// CHECK, CLOSE, UNSELECT (RFC 3691), EXPUNGE, SEARCH, FETCH, STORE, COPY,
+// MOVE (RFC 6851), and their UID variants.
+//
+// The LOGIN and AUTHENTICATE commands are disabled until the client
+// issue STARTTLS, unless the Server AllowInsecureAuth is true.
+//
+// The client that issue "ENABLE IMAP4rev2" receive the ESEARCH response
+// for SEARCH command and does not receive the \Recent flag.
+package imap
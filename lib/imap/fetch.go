@@ -0,0 +1,244 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// List of section text in BODY[section].
+const (
+	sectionHeader          = `HEADER`
+	sectionHeaderFields    = `HEADER.FIELDS`
+	sectionHeaderFieldsNot = `HEADER.FIELDS.NOT`
+	sectionMIME            = `MIME`
+	sectionText            = `TEXT`
+)
+
+// List of FETCH data item names.
+const (
+	fetchBody          = `BODY`
+	fetchBodyPeek      = `BODY.PEEK`
+	fetchBodyStructure = `BODYSTRUCTURE`
+	fetchEnvelope      = `ENVELOPE`
+	fetchFlags         = `FLAGS`
+	fetchInternalDate  = `INTERNALDATE`
+	fetchRFC822        = `RFC822`
+	fetchRFC822Header  = `RFC822.HEADER`
+	fetchRFC822Size    = `RFC822.SIZE`
+	fetchRFC822Text    = `RFC822.TEXT`
+	fetchUID           = `UID`
+)
+
+// layoutDateTime is the format of INTERNALDATE.
+const layoutDateTime = `02-Jan-2006 15:04:05 -0700`
+
+// section contains the parsed body section, for example
+// "1.2.HEADER.FIELDS (FROM TO)".
+type section struct {
+	spec   string
+	part   []int
+	fields []string
+}
+
+// String return the normalized section for response.
+func (sec *section) String() string {
+	var list = make([]string, 0, len(sec.part)+1)
+	for _, n := range sec.part {
+		list = append(list, strconv.Itoa(n))
+	}
+	if len(sec.spec) > 0 {
+		list = append(list, sec.spec)
+	}
+	var s = strings.Join(list, `.`)
+	if len(sec.fields) > 0 {
+		s += ` (` + strings.Join(sec.fields, ` `) + `)`
+	}
+	return s
+}
+
+// fetchItem contains the parsed data item in FETCH command.
+type fetchItem struct {
+	section *section
+	name    string
+
+	start  int
+	length int
+
+	isPartial bool
+}
+
+// parseFetchItems parse the data items or macro in FETCH command.
+// The UID item is always included if isUID is true.
+func parseFetchItems(arg *field, isUID bool) (items []*fetchItem, err error) {
+	var list []*field
+
+	if arg.isList {
+		list = arg.list
+	} else {
+		switch arg.upper() {
+		case `ALL`:
+			list = atoms(fetchFlags, fetchInternalDate, fetchRFC822Size, fetchEnvelope)
+		case `FAST`:
+			list = atoms(fetchFlags, fetchInternalDate, fetchRFC822Size)
+		case `FULL`:
+			list = atoms(fetchFlags, fetchInternalDate, fetchRFC822Size, fetchEnvelope, fetchBody)
+		default:
+			list = []*field{arg}
+		}
+	}
+
+	var (
+		item   *fetchItem
+		hasUID bool
+	)
+	for _, f := range list {
+		if f.isList || f.isString {
+			return nil, fmt.Errorf(`invalid fetch item`)
+		}
+		item, err = parseFetchItem(f.value)
+		if err != nil {
+			return nil, err
+		}
+		if item.name == fetchUID {
+			hasUID = true
+		}
+		items = append(items, item)
+	}
+	if isUID && !hasUID {
+		items = append([]*fetchItem{{name: fetchUID}}, items...)
+	}
+	return items, nil
+}
+
+func atoms(list ...string) (fields []*field) {
+	for _, s := range list {
+		fields = append(fields, &field{value: s})
+	}
+	return fields
+}
+
+// parseFetchItem parse single data item, for example "FLAGS" or
+// "BODY.PEEK[1.MIME]<0.1024>".
+func parseFetchItem(raw string) (item *fetchItem, err error) {
+	var (
+		name, rest, hasSection = strings.Cut(raw, `[`)
+	)
+
+	item = &fetchItem{
+		name: strings.ToUpper(name),
+	}
+	if !hasSection {
+		switch item.name {
+		case fetchBody, fetchBodyStructure, fetchEnvelope, fetchFlags,
+			fetchInternalDate, fetchRFC822, fetchRFC822Header,
+			fetchRFC822Size, fetchRFC822Text, fetchUID:
+			return item, nil
+		}
+		return nil, fmt.Errorf(`unknown fetch item %q`, raw)
+	}
+	if item.name != fetchBody && item.name != fetchBodyPeek {
+		return nil, fmt.Errorf(`unknown fetch item %q`, raw)
+	}
+
+	var x = strings.LastIndexByte(rest, ']')
+	if x < 0 {
+		return nil, fmt.Errorf(`missing ']' in %q`, raw)
+	}
+
+	item.section, err = parseSection(rest[:x])
+	if err != nil {
+		return nil, err
+	}
+
+	rest = rest[x+1:]
+	if len(rest) == 0 {
+		return item, nil
+	}
+
+	// Parse the partial "<start.length>".
+	if rest[0] != '<' || rest[len(rest)-1] != '>' {
+		return nil, fmt.Errorf(`invalid partial in %q`, raw)
+	}
+	var start, length, _ = strings.Cut(rest[1:len(rest)-1], `.`)
+
+	item.start, err = strconv.Atoi(start)
+	if err != nil || item.start < 0 {
+		return nil, fmt.Errorf(`invalid partial in %q`, raw)
+	}
+	item.length, err = strconv.Atoi(length)
+	if err != nil || item.length <= 0 {
+		return nil, fmt.Errorf(`invalid partial in %q`, raw)
+	}
+	item.isPartial = true
+	return item, nil
+}
+
+// parseSection parse the body section inside the square brackets.
+func parseSection(raw string) (sec *section, err error) {
+	sec = &section{}
+
+	var spec, fields, hasFields = strings.Cut(strings.TrimSpace(raw), ` `)
+
+	for len(spec) > 0 {
+		var (
+			num, rest, _ = strings.Cut(spec, `.`)
+			n            int
+		)
+		n, err = strconv.Atoi(num)
+		if err != nil {
+			break
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf(`invalid section part %q`, raw)
+		}
+		sec.part = append(sec.part, n)
+		spec = rest
+	}
+
+	sec.spec = strings.ToUpper(spec)
+	switch sec.spec {
+	case ``, sectionHeader, sectionText:
+	case sectionMIME:
+		if len(sec.part) == 0 {
+			return nil, fmt.Errorf(`invalid section %q`, raw)
+		}
+	case sectionHeaderFields, sectionHeaderFieldsNot:
+		if !hasFields {
+			return nil, fmt.Errorf(`missing header list in section %q`, raw)
+		}
+		fields = strings.TrimSpace(fields)
+		if len(fields) < 2 || fields[0] != '(' || fields[len(fields)-1] != ')' {
+			return nil, fmt.Errorf(`invalid header list in section %q`, raw)
+		}
+		for _, name := range strings.Fields(fields[1 : len(fields)-1]) {
+			sec.fields = append(sec.fields, strings.ToUpper(strings.Trim(name, `"`)))
+		}
+		return sec, nil
+	default:
+		return nil, fmt.Errorf(`invalid section %q`, raw)
+	}
+	if hasFields {
+		return nil, fmt.Errorf(`invalid section %q`, raw)
+	}
+	return sec, nil
+}
+
+// internalDate return the time when the message is delivered, from the
+// epoch in maildir file name, or from its Date if the file name does not
+// begin with epoch.
+func internalDate(msg *message) time.Time {
+	var (
+		epoch, _, _ = strings.Cut(msg.Key, `.`)
+		sec, err    = strconv.ParseInt(epoch, 10, 64)
+	)
+	if err != nil || sec <= 0 {
+		return msg.Date
+	}
+	return time.Unix(sec, 0)
+}
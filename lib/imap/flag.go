@@ -0,0 +1,67 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imap
+
+import (
+	"strings"
+
+	"github.com/shuLhan/share/lib/email/maildir"
+)
+
+// List of IMAP system flags, RFC 9051 section 2.3.2.
+const (
+	FlagAnswered = `\Answered`
+	FlagDeleted  = `\Deleted`
+	FlagDraft    = `\Draft`
+	FlagFlagged  = `\Flagged`
+	FlagRecent   = `\Recent`
+	FlagSeen     = `\Seen`
+)
+
+// systemFlags contains the mapping of IMAP system flags and the maildir
+// info flags, sorted by the maildir flag.
+var systemFlags = []struct {
+	name    string
+	maildir byte
+}{
+	{FlagDraft, maildir.FlagDraft},
+	{FlagFlagged, maildir.FlagFlagged},
+	{FlagAnswered, maildir.FlagReplied},
+	{FlagSeen, maildir.FlagSeen},
+	{FlagDeleted, maildir.FlagTrashed},
+}
+
+// permanentFlags contains list of flags that can be stored permanently.
+const permanentFlags = `(\Answered \Deleted \Draft \Flagged \Seen)`
+
+// toMaildirFlags convert the list of IMAP flags into maildir info flags.
+// The \Recent flag and keywords are ignored, since they cannot be stored
+// in maildir.
+func toMaildirFlags(list []*field) (flags string) {
+	for _, f := range list {
+		for _, sf := range systemFlags {
+			if strings.EqualFold(f.value, sf.name) {
+				flags += string(sf.maildir)
+				break
+			}
+		}
+	}
+	return flags
+}
+
+// formatFlags return the parenthesized list of IMAP flags from the maildir
+// info flags.
+func formatFlags(flags string, isRecent bool) string {
+	var list []string
+	for _, sf := range systemFlags {
+		if strings.IndexByte(flags, sf.maildir) >= 0 {
+			list = append(list, sf.name)
+		}
+	}
+	if isRecent {
+		list = append(list, FlagRecent)
+	}
+	return `(` + strings.Join(list, ` `) + `)`
+}
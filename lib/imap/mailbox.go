@@ -0,0 +1,457 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shuLhan/share/lib/email/maildir"
+)
+
+// MailboxInbox is the name of special mailbox that map to the main
+// maildir.
+const MailboxInbox = `INBOX`
+
+// hierarchyDelimiter is the separator between mailbox name, as used by
+// Maildir++ folders.
+const hierarchyDelimiter = `.`
+
+// fileUIDList is the name of file inside maildir folder that store the
+// UID of each message.
+const fileUIDList = `imap-uidlist`
+
+// uidListMtx protect the read and write of UID list files between
+// sessions.
+var uidListMtx sync.Mutex
+
+// message contains the maildir message and its IMAP attributes in the
+// selected mailbox.
+type message struct {
+	*maildir.Message
+
+	// size contains the size of message after the line endings are
+	// converted to CRLF.
+	// It is zero until the message content has been read.
+	size int64
+
+	uid uint32
+
+	// isRecent is true if this session is the first session notified
+	// about the message.
+	isRecent bool
+}
+
+// mailbox contains the state of selected mailbox.
+type mailbox struct {
+	folder *maildir.Folder
+	name   string
+
+	// msgs contains list of message sorted by UID, where the index+1
+	// is the message sequence number.
+	msgs []*message
+
+	uidValidity uint32
+	uidNext     uint32
+
+	isReadOnly bool
+}
+
+// folderName convert the IMAP mailbox name into maildir folder name.
+func folderName(name string) string {
+	if strings.EqualFold(name, MailboxInbox) {
+		return ``
+	}
+	return hierarchyDelimiter + name
+}
+
+// mailboxName convert the maildir folder name into IMAP mailbox name.
+func mailboxName(folder string) string {
+	if len(folder) == 0 {
+		return MailboxInbox
+	}
+	return strings.TrimPrefix(folder, hierarchyDelimiter)
+}
+
+// lookupFolder return the maildir folder for mailbox name, or nil if the
+// mailbox does not exist.
+func lookupFolder(mg *maildir.Manager, name string) *maildir.Folder {
+	var fname = folderName(name)
+	if len(fname) == 0 {
+		return &mg.Folder
+	}
+	return mg.GetFolder(fname)
+}
+
+// openMailbox read the messages in folder and assign their UID.
+// If isReadOnly is false, the messages in "new" directory are moved to
+// "cur" and marked as recent.
+func openMailbox(folder *maildir.Folder, name string, isReadOnly bool) (mbox *mailbox, err error) {
+	mbox = &mailbox{
+		folder:     folder,
+		name:       name,
+		isReadOnly: isReadOnly,
+	}
+
+	_, _, err = mbox.sync()
+	if err != nil {
+		return nil, err
+	}
+	return mbox, nil
+}
+
+// sync re-read the messages in folder.
+// It return the sequence numbers of expunged messages, in descending
+// order, and list of messages that their flags changed by other process.
+func (mbox *mailbox) sync() (expunged []uint32, changed []*message, err error) {
+	var list []*maildir.Message
+
+	list, err = mbox.folder.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ul *uidList
+
+	uidListMtx.Lock()
+	ul, err = loadUIDList(filepath.Join(mbox.folder.Dir(), fileUIDList))
+	if err == nil {
+		err = ul.sync(list)
+	}
+	uidListMtx.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var byKey = make(map[string]*maildir.Message, len(list))
+	for _, msg := range list {
+		byKey[msg.Key] = msg
+	}
+
+	var (
+		msgs = make([]*message, 0, len(list))
+		last uint32
+	)
+	for x := len(mbox.msgs) - 1; x >= 0; x-- {
+		var msg = mbox.msgs[x]
+		if _, ok := byKey[msg.Key]; !ok {
+			expunged = append(expunged, uint32(x+1))
+		}
+	}
+	for _, msg := range mbox.msgs {
+		var md = byKey[msg.Key]
+		if md == nil {
+			continue
+		}
+		if md.Flags != msg.Flags {
+			changed = append(changed, msg)
+		}
+		msg.Message = md
+		msgs = append(msgs, msg)
+		last = msg.uid
+		delete(byKey, msg.Key)
+	}
+
+	var added []*message
+	for key, md := range byKey {
+		var uid = ul.keys[key]
+		if uid <= last && len(mbox.msgs) > 0 {
+			// The message is not new, it may be removed in
+			// this session but restored by other process.
+			continue
+		}
+		added = append(added, &message{
+			Message:  md,
+			uid:      uid,
+			isRecent: md.IsNew && !mbox.isReadOnly,
+		})
+	}
+	sort.Slice(added, func(x, y int) bool {
+		return added[x].uid < added[y].uid
+	})
+
+	if !mbox.isReadOnly {
+		for _, msg := range added {
+			if !msg.IsNew {
+				continue
+			}
+			msg.Name, err = mbox.folder.SetFlags(msg.Name, msg.Flags)
+			if err != nil {
+				return nil, nil, err
+			}
+			msg.IsNew = false
+		}
+	}
+
+	mbox.msgs = append(msgs, added...)
+	mbox.uidValidity = ul.validity
+	mbox.uidNext = ul.next
+
+	return expunged, changed, nil
+}
+
+// maxSeq return the largest message sequence number.
+func (mbox *mailbox) maxSeq() uint32 {
+	return uint32(len(mbox.msgs))
+}
+
+// maxUID return the largest UID in mailbox.
+func (mbox *mailbox) maxUID() uint32 {
+	if len(mbox.msgs) == 0 {
+		return 0
+	}
+	return mbox.msgs[len(mbox.msgs)-1].uid
+}
+
+// lookup return the messages and their sequence numbers in set.
+func (mbox *mailbox) lookup(set seqSet, isUID bool) (seqs []uint32, msgs []*message) {
+	var (
+		maxSeq = mbox.maxSeq()
+		maxUID = mbox.maxUID()
+	)
+	for x, msg := range mbox.msgs {
+		var seq = uint32(x + 1)
+		if isUID {
+			if !set.contains(msg.uid, maxUID) {
+				continue
+			}
+		} else if !set.contains(seq, maxSeq) {
+			continue
+		}
+		seqs = append(seqs, seq)
+		msgs = append(msgs, msg)
+	}
+	return seqs, msgs
+}
+
+// remove the messages from mailbox and return their sequence numbers in
+// descending order.
+func (mbox *mailbox) remove(removed []*message) (expunged []uint32) {
+	var (
+		isRemoved = make(map[*message]bool, len(removed))
+		msgs      = make([]*message, 0, len(mbox.msgs))
+	)
+	for _, msg := range removed {
+		isRemoved[msg] = true
+	}
+	for x := len(mbox.msgs) - 1; x >= 0; x-- {
+		if isRemoved[mbox.msgs[x]] {
+			expunged = append(expunged, uint32(x+1))
+		}
+	}
+	for _, msg := range mbox.msgs {
+		if !isRemoved[msg] {
+			msgs = append(msgs, msg)
+		}
+	}
+	mbox.msgs = msgs
+	return expunged
+}
+
+// countUnseen return the number of messages without \Seen flag and the
+// sequence number of the first one.
+func (mbox *mailbox) countUnseen() (n int, first uint32) {
+	for x, msg := range mbox.msgs {
+		if msg.HasFlag(maildir.FlagSeen) {
+			continue
+		}
+		if n == 0 {
+			first = uint32(x + 1)
+		}
+		n++
+	}
+	return n, first
+}
+
+// countRecent return the number of recent messages.
+func (mbox *mailbox) countRecent() (n int) {
+	for _, msg := range mbox.msgs {
+		if msg.isRecent {
+			n++
+		}
+	}
+	return n
+}
+
+// read the message content with the line endings converted into CRLF.
+func (mbox *mailbox) read(msg *message) (raw []byte, err error) {
+	raw, err = mbox.folder.Read(msg.Name)
+	if err != nil {
+		return nil, err
+	}
+	raw = toCRLF(raw)
+	msg.size = int64(len(raw))
+	return raw, nil
+}
+
+// rfc822Size return the size of message in octets, with CRLF line
+// endings.
+func (mbox *mailbox) rfc822Size(msg *message) (size int64, err error) {
+	if msg.size == 0 {
+		_, err = mbox.read(msg)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return msg.size, nil
+}
+
+// toCRLF convert the bare LF in raw into CRLF.
+func toCRLF(raw []byte) []byte {
+	var n = bytes.Count(raw, []byte{'\n'}) - bytes.Count(raw, []byte("\r\n"))
+	if n <= 0 {
+		return raw
+	}
+	var out = make([]byte, 0, len(raw)+n)
+	for x, c := range raw {
+		if c == '\n' && (x == 0 || raw[x-1] != '\r') {
+			out = append(out, '\r')
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// uidList contains the UID of each message key in maildir folder.
+//
+// The file format is the UID validity and the next UID in the first line,
+// followed by the UID and message key in each line,
+//
+//	1700000000 4
+//	1 1700000000.M1P1Q1.host
+//	3 1700000100.M2P1Q2.host
+type uidList struct {
+	keys map[string]uint32
+	path string
+
+	validity uint32
+	next     uint32
+}
+
+// loadUIDList load the UID list from file path.
+// If the file does not exist, it will return new UID list with the
+// current time as the UID validity.
+func loadUIDList(path string) (ul *uidList, err error) {
+	ul = &uidList{
+		keys:     map[string]uint32{},
+		path:     path,
+		validity: uint32(time.Now().Unix()),
+		next:     1,
+	}
+
+	var f *os.File
+
+	f, err = os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ul, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		scanner = bufio.NewScanner(f)
+		isFirst = true
+	)
+	for scanner.Scan() {
+		var (
+			fields = strings.Fields(scanner.Text())
+			a, b   uint64
+		)
+		if len(fields) != 2 {
+			continue
+		}
+		if isFirst {
+			isFirst = false
+			a, err = strconv.ParseUint(fields[0], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf(`%s: invalid UID validity: %w`, path, err)
+			}
+			b, err = strconv.ParseUint(fields[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf(`%s: invalid next UID: %w`, path, err)
+			}
+			ul.validity = uint32(a)
+			ul.next = uint32(b)
+			continue
+		}
+		a, err = strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			continue
+		}
+		ul.keys[fields[1]] = uint32(a)
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	return ul, nil
+}
+
+// sync assign new UID to the new messages in list, ordered by their date,
+// remove the key of message that does not exist anymore, and save the
+// list if its changed.
+func (ul *uidList) sync(list []*maildir.Message) (err error) {
+	var (
+		exist     = make(map[string]bool, len(list))
+		isChanged bool
+	)
+	for _, msg := range list {
+		exist[msg.Key] = true
+		if _, ok := ul.keys[msg.Key]; ok {
+			continue
+		}
+		ul.keys[msg.Key] = ul.next
+		ul.next++
+		isChanged = true
+	}
+	for key := range ul.keys {
+		if !exist[key] {
+			delete(ul.keys, key)
+			isChanged = true
+		}
+	}
+	if !isChanged {
+		return nil
+	}
+	return ul.save()
+}
+
+// save the UID list into temporary file and rename it.
+func (ul *uidList) save() (err error) {
+	var (
+		keys = make([]string, 0, len(ul.keys))
+		buf  bytes.Buffer
+	)
+	for key := range ul.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(x, y int) bool {
+		return ul.keys[keys[x]] < ul.keys[keys[y]]
+	})
+
+	fmt.Fprintf(&buf, "%d %d\n", ul.validity, ul.next)
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%d %s\n", ul.keys[key], key)
+	}
+
+	var pathTmp = ul.path + `.tmp`
+
+	err = os.WriteFile(pathTmp, buf.Bytes(), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(pathTmp, ul.path)
+}
@@ -0,0 +1,328 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imap
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shuLhan/share/lib/email"
+	"github.com/shuLhan/share/lib/email/maildir"
+)
+
+// layoutDate is the format of date in SEARCH command.
+const layoutDate = `2-Jan-2006`
+
+// searchKey contains the parsed search criteria.
+type searchKey struct {
+	date time.Time
+
+	name   string
+	header string
+	value  string
+
+	set  seqSet
+	keys []*searchKey
+
+	size int64
+}
+
+// parseSearchKeys parse all search keys in the arguments.
+func parseSearchKeys(args []*field) (keys []*searchKey, err error) {
+	var key *searchKey
+	for len(args) > 0 {
+		key, args, err = parseSearchKey(args)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf(`missing search key`)
+	}
+	return keys, nil
+}
+
+// parseSearchKey parse single search key from the beginning of args and
+// return the rest of arguments.
+func parseSearchKey(args []*field) (key *searchKey, rest []*field, err error) {
+	var f = args[0]
+
+	rest = args[1:]
+	if f.isList {
+		key = &searchKey{name: `AND`}
+		key.keys, err = parseSearchKeys(f.list)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, rest, nil
+	}
+
+	key = &searchKey{name: f.upper()}
+
+	var nextArg = func() (*field, error) {
+		if len(rest) == 0 {
+			return nil, fmt.Errorf(`missing argument for %s`, key.name)
+		}
+		var arg = rest[0]
+		rest = rest[1:]
+		if arg.isList {
+			return nil, fmt.Errorf(`invalid argument for %s`, key.name)
+		}
+		return arg, nil
+	}
+
+	var arg *field
+
+	switch key.name {
+	case `ALL`, `ANSWERED`, `DELETED`, `DRAFT`, `FLAGGED`, `NEW`, `OLD`,
+		`RECENT`, `SEEN`, `UNANSWERED`, `UNDELETED`, `UNDRAFT`,
+		`UNFLAGGED`, `UNSEEN`:
+		return key, rest, nil
+
+	case `BCC`, `BODY`, `CC`, `FROM`, `KEYWORD`, `SUBJECT`, `TEXT`, `TO`,
+		`UNKEYWORD`:
+		arg, err = nextArg()
+		if err != nil {
+			return nil, nil, err
+		}
+		key.value = strings.ToLower(arg.value)
+
+	case `HEADER`:
+		arg, err = nextArg()
+		if err != nil {
+			return nil, nil, err
+		}
+		key.header = strings.ToLower(arg.value)
+		arg, err = nextArg()
+		if err != nil {
+			return nil, nil, err
+		}
+		key.value = strings.ToLower(arg.value)
+
+	case `BEFORE`, `ON`, `SINCE`, `SENTBEFORE`, `SENTON`, `SENTSINCE`:
+		arg, err = nextArg()
+		if err != nil {
+			return nil, nil, err
+		}
+		key.date, err = time.Parse(layoutDate, arg.value)
+		if err != nil {
+			return nil, nil, fmt.Errorf(`invalid date %q`, arg.value)
+		}
+
+	case `LARGER`, `SMALLER`:
+		arg, err = nextArg()
+		if err != nil {
+			return nil, nil, err
+		}
+		key.size, err = strconv.ParseInt(arg.value, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf(`invalid size %q`, arg.value)
+		}
+
+	case `UID`:
+		arg, err = nextArg()
+		if err != nil {
+			return nil, nil, err
+		}
+		key.set, err = parseSeqSet(arg.value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+	case `NOT`:
+		if len(rest) == 0 {
+			return nil, nil, fmt.Errorf(`missing argument for %s`, key.name)
+		}
+		var sub *searchKey
+		sub, rest, err = parseSearchKey(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		key.keys = []*searchKey{sub}
+
+	case `OR`:
+		for x := 0; x < 2; x++ {
+			if len(rest) == 0 {
+				return nil, nil, fmt.Errorf(`missing argument for %s`, key.name)
+			}
+			var sub *searchKey
+			sub, rest, err = parseSearchKey(rest)
+			if err != nil {
+				return nil, nil, err
+			}
+			key.keys = append(key.keys, sub)
+		}
+
+	default:
+		key.set, err = parseSeqSet(f.value)
+		if err != nil {
+			return nil, nil, fmt.Errorf(`unknown search key %q`, f.value)
+		}
+		key.name = `SEQ`
+	}
+	return key, rest, nil
+}
+
+// searchContext contains the message being evaluated by search keys.
+type searchContext struct {
+	mbox *mailbox
+	msg  *message
+	raw  []byte
+	err  error
+	seq  uint32
+}
+
+// content return the message content, read only once.
+func (sc *searchContext) content() []byte {
+	if sc.raw == nil && sc.err == nil {
+		sc.raw, sc.err = sc.mbox.read(sc.msg)
+	}
+	return sc.raw
+}
+
+// matchAll return true if all keys match the message.
+func (sc *searchContext) matchAll(keys []*searchKey) bool {
+	for _, key := range keys {
+		if !sc.match(key) {
+			return false
+		}
+	}
+	return true
+}
+
+func (sc *searchContext) match(key *searchKey) bool {
+	var msg = sc.msg
+
+	switch key.name {
+	case `ALL`:
+		return true
+	case `AND`:
+		return sc.matchAll(key.keys)
+	case `NOT`:
+		return !sc.match(key.keys[0])
+	case `OR`:
+		return sc.match(key.keys[0]) || sc.match(key.keys[1])
+
+	case `ANSWERED`:
+		return msg.HasFlag(maildir.FlagReplied)
+	case `DELETED`:
+		return msg.HasFlag(maildir.FlagTrashed)
+	case `DRAFT`:
+		return msg.HasFlag(maildir.FlagDraft)
+	case `FLAGGED`:
+		return msg.HasFlag(maildir.FlagFlagged)
+	case `SEEN`:
+		return msg.HasFlag(maildir.FlagSeen)
+	case `NEW`:
+		return msg.isRecent && !msg.HasFlag(maildir.FlagSeen)
+	case `OLD`:
+		return !msg.isRecent
+	case `RECENT`:
+		return msg.isRecent
+	case `UNANSWERED`:
+		return !msg.HasFlag(maildir.FlagReplied)
+	case `UNDELETED`:
+		return !msg.HasFlag(maildir.FlagTrashed)
+	case `UNDRAFT`:
+		return !msg.HasFlag(maildir.FlagDraft)
+	case `UNFLAGGED`:
+		return !msg.HasFlag(maildir.FlagFlagged)
+	case `UNSEEN`:
+		return !msg.HasFlag(maildir.FlagSeen)
+	case `KEYWORD`:
+		return false
+	case `UNKEYWORD`:
+		return true
+
+	case `BCC`:
+		return matchHeader(msg.Header, `bcc`, key.value)
+	case `CC`:
+		return matchHeader(msg.Header, `cc`, key.value)
+	case `FROM`:
+		return matchHeader(msg.Header, `from`, key.value)
+	case `SUBJECT`:
+		return matchHeader(msg.Header, `subject`, key.value)
+	case `TO`:
+		return matchHeader(msg.Header, `to`, key.value)
+	case `HEADER`:
+		return matchHeader(msg.Header, key.header, key.value)
+
+	case `BODY`:
+		var _, text = splitMessage(sc.content())
+		return containsFold(text, key.value)
+	case `TEXT`:
+		return containsFold(sc.content(), key.value)
+
+	case `BEFORE`:
+		return compareDate(internalDate(msg), key.date) < 0
+	case `ON`:
+		return compareDate(internalDate(msg), key.date) == 0
+	case `SINCE`:
+		return compareDate(internalDate(msg), key.date) >= 0
+	case `SENTBEFORE`:
+		return compareDate(msg.Date, key.date) < 0
+	case `SENTON`:
+		return compareDate(msg.Date, key.date) == 0
+	case `SENTSINCE`:
+		return compareDate(msg.Date, key.date) >= 0
+
+	case `LARGER`:
+		var size, err = sc.mbox.rfc822Size(msg)
+		return err == nil && size > key.size
+	case `SMALLER`:
+		var size, err = sc.mbox.rfc822Size(msg)
+		return err == nil && size < key.size
+
+	case `UID`:
+		return key.set.contains(msg.uid, sc.mbox.maxUID())
+	case `SEQ`:
+		return key.set.contains(sc.seq, sc.mbox.maxSeq())
+	}
+	return false
+}
+
+// matchHeader return true if the decoded value of field name in header
+// contains the value, case insensitive.
+// The empty value match the message that contains the field.
+func matchHeader(hdr *email.Header, name, value string) bool {
+	if hdr == nil {
+		return false
+	}
+	for _, f := range hdr.FilterName(name) {
+		var decoded, _ = email.DecodeHeader(f.Value)
+		if strings.Contains(strings.ToLower(decoded), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold return true if content contains the lowercase value, case
+// insensitive.
+func containsFold(content []byte, value string) bool {
+	return bytes.Contains(bytes.ToLower(content), []byte(value))
+}
+
+// compareDate compare only the date of t and other, ignoring the time and
+// time zone.
+func compareDate(t, other time.Time) int {
+	var (
+		y1, m1, d1 = t.Date()
+		y2, m2, d2 = other.Date()
+		a          = y1*10000 + int(m1)*100 + d1
+		b          = y2*10000 + int(m2)*100 + d2
+	)
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,112 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// seqRange contains the range of message sequence numbers or UIDs.
+// The zero value of start or end represent the "*", the largest number in
+// mailbox.
+type seqRange struct {
+	start uint32
+	end   uint32
+}
+
+// seqSet represent the sequence-set, for example "1,3:5,7:*".
+type seqSet []seqRange
+
+// parseSeqSet parse the sequence-set from string.
+func parseSeqSet(raw string) (set seqSet, err error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf(`empty sequence set`)
+	}
+
+	var (
+		list = strings.Split(raw, `,`)
+		r    seqRange
+	)
+	for _, item := range list {
+		var start, end, isRange = strings.Cut(item, `:`)
+
+		r.start, err = parseSeqNumber(start)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid sequence set %q`, raw)
+		}
+		r.end = r.start
+		if isRange {
+			r.end, err = parseSeqNumber(end)
+			if err != nil {
+				return nil, fmt.Errorf(`invalid sequence set %q`, raw)
+			}
+		}
+		set = append(set, r)
+	}
+	return set, nil
+}
+
+// parseSeqNumber parse the non-zero number or "*".
+func parseSeqNumber(raw string) (n uint32, err error) {
+	if raw == `*` {
+		return 0, nil
+	}
+
+	var v uint64
+
+	v, err = strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	if v == 0 {
+		return 0, fmt.Errorf(`zero sequence number`)
+	}
+	return uint32(v), nil
+}
+
+// contains return true if n is in the set, where max is the largest
+// number in mailbox that replace the "*".
+func (set seqSet) contains(n, max uint32) bool {
+	for _, r := range set {
+		var start, end = r.start, r.end
+		if start == 0 {
+			start = max
+		}
+		if end == 0 {
+			end = max
+		}
+		if start > end {
+			start, end = end, start
+		}
+		if n >= start && n <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSeqSet format the sorted list of numbers into sequence-set, for
+// example [1 2 3 5] into "1:3,5".
+func formatSeqSet(list []uint32) string {
+	var sb strings.Builder
+	for x := 0; x < len(list); x++ {
+		var y = x
+		for y+1 < len(list) && list[y+1] == list[y]+1 {
+			y++
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatUint(uint64(list[x]), 10))
+		if y > x {
+			sb.WriteByte(':')
+			sb.WriteString(strconv.FormatUint(uint64(list[y]), 10))
+		}
+		x = y
+	}
+	return sb.String()
+}
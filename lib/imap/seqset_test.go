@@ -0,0 +1,58 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imap
+
+import (
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestSeqSet_contains(t *testing.T) {
+	type testCase struct {
+		desc   string
+		raw    string
+		expErr string
+		exp    []uint32
+	}
+
+	var cases = []testCase{{
+		desc: `single and range`,
+		raw:  `1,3:4`,
+		exp:  []uint32{1, 3, 4},
+	}, {
+		desc: `star`,
+		raw:  `4:*`,
+		exp:  []uint32{4, 5},
+	}, {
+		desc: `reversed range`,
+		raw:  `*:4`,
+		exp:  []uint32{4, 5},
+	}, {
+		desc:   `zero`,
+		raw:    `0:2`,
+		expErr: `invalid sequence set "0:2"`,
+	}}
+
+	for _, c := range cases {
+		var set, err = parseSeqSet(c.raw)
+		if err != nil {
+			test.Assert(t, c.desc, c.expErr, err.Error())
+			continue
+		}
+
+		var got []uint32
+		for n := uint32(1); n <= 5; n++ {
+			if set.contains(n, 5) {
+				got = append(got, n)
+			}
+		}
+		test.Assert(t, c.desc, c.exp, got)
+	}
+}
+
+func TestFormatSeqSet(t *testing.T) {
+	test.Assert(t, `formatSeqSet`, `1:3,5,7:8`, formatSeqSet([]uint32{1, 2, 3, 5, 7, 8}))
+}
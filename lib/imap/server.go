@@ -0,0 +1,161 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shuLhan/share/lib/email/maildir"
+	"github.com/shuLhan/share/lib/smtp"
+)
+
+const (
+	// defAddress is the default address to listen for connections.
+	defAddress = `:143`
+
+	// defIdleInterval is the default interval to check the mailbox
+	// changes during IDLE.
+	defIdleInterval = 5 * time.Second
+)
+
+// Server defines parameters for running an IMAP server.
+type Server struct {
+	// Env contains the domains and accounts that can login to the
+	// server, the same environment used by [smtp.Server].
+	// This field is required.
+	Env *smtp.Environment
+
+	// TLSConfig define the TLS configuration for STARTTLS command.
+	// This field is optional, if its nil the STARTTLS is not
+	// advertised.
+	TLSConfig *tls.Config
+
+	listener net.Listener
+
+	// DirMaildir define the base directory where the maildir of each
+	// account is located, as "{DirMaildir}/{domain}/{local}".
+	// This field is required.
+	DirMaildir string
+
+	// Address to listen for incoming connections.
+	// This field is optional, default to ":143".
+	Address string
+
+	wg sync.WaitGroup
+
+	// IdleInterval define the interval to check the changes on
+	// selected mailbox during IDLE command.
+	// This field is optional, default to 5 seconds.
+	IdleInterval time.Duration
+
+	// AllowInsecureAuth allow the LOGIN and AUTHENTICATE commands on
+	// connection without TLS.
+	// By default, the client must issue STARTTLS before login.
+	AllowInsecureAuth bool
+}
+
+// Start listening for IMAP connections.
+// Each client connection will be handled in a single routine.
+// It will block until the server is stopped.
+func (srv *Server) Start() (err error) {
+	var logp = `Start`
+
+	err = srv.init()
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	srv.listener, err = net.Listen(`tcp`, srv.Address)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var conn net.Conn
+	for {
+		conn, err = srv.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				break
+			}
+			log.Printf(`imap: %s: %s`, logp, err)
+			continue
+		}
+		srv.wg.Add(1)
+		go func() {
+			srv.serve(conn)
+			srv.wg.Done()
+		}()
+	}
+	srv.wg.Wait()
+	return nil
+}
+
+// Stop the server from accepting new connections.
+func (srv *Server) Stop() {
+	if srv.listener == nil {
+		return
+	}
+	var err = srv.listener.Close()
+	if err != nil {
+		log.Printf(`imap: Stop: %s`, err)
+	}
+}
+
+// init check the required fields and set the default values.
+func (srv *Server) init() error {
+	if srv.Env == nil || srv.Env.PrimaryDomain == nil {
+		return errors.New(`missing environment or primary domain`)
+	}
+	if len(srv.DirMaildir) == 0 {
+		return errors.New(`empty DirMaildir`)
+	}
+	if len(srv.Address) == 0 {
+		srv.Address = defAddress
+	}
+	if srv.IdleInterval <= 0 {
+		srv.IdleInterval = defIdleInterval
+	}
+	return nil
+}
+
+// serve the client connection until LOGOUT or connection closed.
+func (srv *Server) serve(conn net.Conn) {
+	var (
+		sess = newSession(srv, conn)
+		err  = sess.run()
+	)
+	if err != nil {
+		log.Printf(`imap: %s: %s`, conn.RemoteAddr(), err)
+	}
+	_ = conn.Close()
+}
+
+// authenticate the username and password using the account in
+// Environment.
+// System account, an account without password, cannot login.
+func (srv *Server) authenticate(username, password string) (acc *smtp.Account, err error) {
+	acc = srv.Env.LookupAccount(username)
+	if acc == nil || len(acc.HashPass) == 0 {
+		return nil, smtp.ErrInvalidCredential
+	}
+	err = acc.Authenticate(password)
+	if err != nil {
+		return nil, smtp.ErrInvalidCredential
+	}
+	return acc, nil
+}
+
+// openMaildir open the maildir of account.
+func (srv *Server) openMaildir(acc *smtp.Account) (*maildir.Manager, error) {
+	var dir = filepath.Join(srv.DirMaildir, acc.Domain, acc.Local)
+	return maildir.NewManager(dir)
+}
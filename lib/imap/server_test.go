@@ -0,0 +1,446 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/email/maildir"
+	"github.com/shuLhan/share/lib/smtp"
+	"github.com/shuLhan/share/lib/test"
+)
+
+const (
+	testMessage1 = "Date: Mon, 1 Jan 2024 10:00:00 +0000\n" +
+		"From: Bob <bob@example.org>\n" +
+		"To: alice@example.com\n" +
+		"Subject: Hello\n" +
+		"Message-ID: <m1@example.org>\n" +
+		"\n" +
+		"Hi Alice.\n"
+
+	testMessage2 = "Date: Tue, 2 Jan 2024 10:00:00 +0000\r\n" +
+		"From: carol@example.net\r\n" +
+		"To: alice@example.com\r\n" +
+		"Subject: Report\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"b1\"\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"See attached.\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/csv; name=\"r.csv\"\r\n" +
+		"Content-Disposition: attachment; filename=\"r.csv\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"YSxiCg==\r\n" +
+		"--b1--\r\n"
+)
+
+// testClient is the scripted IMAP client over net.Pipe.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newTestClient(t *testing.T, srv *Server) (cl *testClient) {
+	var clientConn, serverConn = net.Pipe()
+
+	go srv.serve(serverConn)
+
+	cl = &testClient{
+		t:      t,
+		conn:   clientConn,
+		reader: bufio.NewReader(clientConn),
+	}
+	t.Cleanup(func() {
+		_ = clientConn.Close()
+	})
+	return cl
+}
+
+func (cl *testClient) setConn(conn net.Conn) {
+	cl.conn = conn
+	cl.reader = bufio.NewReader(conn)
+}
+
+func (cl *testClient) send(line string) {
+	_ = cl.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	var _, err = cl.conn.Write([]byte(line + "\r\n"))
+	if err != nil {
+		cl.t.Fatal(err)
+	}
+}
+
+// readLine read single response line, including its literals.
+func (cl *testClient) readLine() (line string) {
+	_ = cl.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var part, err = cl.reader.ReadString('\n')
+		if err != nil {
+			cl.t.Fatal(err)
+		}
+		line += part
+
+		var x = strings.LastIndexByte(part, '{')
+		if x < 0 || !strings.HasSuffix(part, "}\r\n") {
+			break
+		}
+		var size int
+		size, err = strconv.Atoi(part[x+1 : len(part)-3])
+		if err != nil {
+			break
+		}
+		var literal = make([]byte, size)
+		_, err = io.ReadFull(cl.reader, literal)
+		if err != nil {
+			cl.t.Fatal(err)
+		}
+		line += string(literal)
+	}
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+// readUntil read the response lines until the tagged response.
+func (cl *testClient) readUntil(tag string) (lines []string) {
+	for {
+		var line = cl.readLine()
+		lines = append(lines, line)
+		if strings.HasPrefix(line, tag+` `) {
+			return lines
+		}
+	}
+}
+
+func newTestServer(t *testing.T) (srv *Server, mg *maildir.Manager) {
+	var (
+		dir      = t.TempDir()
+		domain   = smtp.NewDomain(`example.com`, nil)
+		acc, err = smtp.NewAccount(`Alice`, `alice`, `example.com`, `secret`)
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain.Accounts[acc.Local] = acc
+
+	srv = &Server{
+		Env: &smtp.Environment{
+			PrimaryDomain: domain,
+		},
+		DirMaildir:        dir,
+		IdleInterval:      50 * time.Millisecond,
+		AllowInsecureAuth: true,
+	}
+	err = srv.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dirUser = filepath.Join(dir, `example.com`, `alice`)
+
+	mg, err = maildir.NewManager(dirUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = maildir.CreateFolder(dirUser, `.Archive`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files = map[string]string{
+		`cur/1704103200.M1P1Q1.test:2,S`: testMessage1,
+		`new/1704189600.M2P1Q2.test`:     testMessage2,
+		// Fix the UID validity.
+		fileUIDList: "1 1\n",
+	}
+	for name, content := range files {
+		err = os.WriteFile(filepath.Join(dirUser, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return srv, mg
+}
+
+func TestServer(t *testing.T) {
+	type testCase struct {
+		desc string
+		cmd  string
+		exp  []string
+	}
+
+	var (
+		srv, _ = newTestServer(t)
+		cl     = newTestClient(t, srv)
+	)
+
+	test.Assert(t, `greeting`,
+		`* OK [CAPABILITY IMAP4rev1 IMAP4rev2 LITERAL+ ENABLE IDLE MOVE UNSELECT SASL-IR AUTH=PLAIN] IMAP server ready`,
+		cl.readLine())
+
+	var cases = []testCase{{
+		desc: `SELECT before login`,
+		cmd:  `a0 SELECT INBOX`,
+		exp:  []string{`a0 BAD SELECT not allowed in current state`},
+	}, {
+		desc: `LOGIN with invalid password`,
+		cmd:  `a1 LOGIN alice@example.com wrong`,
+		exp:  []string{`a1 NO [AUTHENTICATIONFAILED] invalid credentials`},
+	}, {
+		desc: `LOGIN`,
+		cmd:  `a2 LOGIN "alice@example.com" "secret"`,
+		exp: []string{
+			`a2 OK [CAPABILITY IMAP4rev1 IMAP4rev2 LITERAL+ ENABLE IDLE MOVE UNSELECT SASL-IR] LOGIN completed`,
+		},
+	}, {
+		desc: `LIST`,
+		cmd:  `a3 LIST "" "*"`,
+		exp: []string{
+			`* LIST () "." "INBOX"`,
+			`* LIST () "." "Archive"`,
+			`a3 OK LIST completed`,
+		},
+	}, {
+		desc: `SELECT`,
+		cmd:  `a4 SELECT inbox`,
+		exp: []string{
+			`* 2 EXISTS`,
+			`* FLAGS (\Answered \Flagged \Deleted \Seen \Draft)`,
+			`* OK [PERMANENTFLAGS (\Answered \Deleted \Draft \Flagged \Seen)] flags permitted`,
+			`* OK [UIDVALIDITY 1] UIDs valid`,
+			`* OK [UIDNEXT 3] predicted next UID`,
+			`* 1 RECENT`,
+			`* OK [UNSEEN 2] first unseen`,
+			`a4 OK [READ-WRITE] SELECT completed`,
+		},
+	}, {
+		desc: `FETCH flags and size`,
+		cmd:  `a5 FETCH 1:* (UID FLAGS RFC822.SIZE)`,
+		exp: []string{
+			`* 1 FETCH (UID 1 FLAGS (\Seen) RFC822.SIZE 149)`,
+			`* 2 FETCH (UID 2 FLAGS (\Recent) RFC822.SIZE 384)`,
+			`a5 OK FETCH completed`,
+		},
+	}, {
+		desc: `FETCH BODYSTRUCTURE`,
+		cmd:  `a6 FETCH 2 BODYSTRUCTURE`,
+		exp: []string{
+			`* 2 FETCH (BODYSTRUCTURE (("TEXT" "PLAIN" ("CHARSET" "utf-8") NIL NIL "7BIT" 13 1 NIL NIL NIL NIL)` +
+				`("TEXT" "CSV" ("NAME" "r.csv") NIL NIL "BASE64" 8 1 NIL ("ATTACHMENT" ("FILENAME" "r.csv")) NIL NIL)` +
+				` "MIXED" ("BOUNDARY" "b1") NIL NIL NIL))`,
+			`a6 OK FETCH completed`,
+		},
+	}, {
+		desc: `FETCH ENVELOPE`,
+		cmd:  `a7 FETCH 1 ENVELOPE`,
+		exp: []string{
+			`* 1 FETCH (ENVELOPE ("Mon, 1 Jan 2024 10:00:00 +0000" "Hello"` +
+				` (("Bob" NIL "bob" "example.org")) (("Bob" NIL "bob" "example.org"))` +
+				` (("Bob" NIL "bob" "example.org")) ((NIL NIL "alice" "example.com"))` +
+				` NIL NIL NIL "<m1@example.org>"))`,
+			`a7 OK FETCH completed`,
+		},
+	}, {
+		desc: `UID FETCH body sections`,
+		cmd:  `a8 UID FETCH 2 (BODY.PEEK[2] BODY.PEEK[HEADER.FIELDS (subject)] BODY.PEEK[1]<0.3>)`,
+		exp: []string{
+			"* 2 FETCH (UID 2 BODY[2] {8}\r\nYSxiCg==" +
+				" BODY[HEADER.FIELDS (SUBJECT)] {19}\r\nSubject: Report\r\n\r\n" +
+				" BODY[1]<0> {3}\r\nSee)",
+			`a8 OK UID FETCH completed`,
+		},
+	}, {
+		desc: `SEARCH`,
+		cmd:  `a9 SEARCH UNSEEN FROM carol SINCE 1-Jan-2024`,
+		exp:  []string{`* SEARCH 2`, `a9 OK SEARCH completed`},
+	}, {
+		desc: `UID SEARCH with OR and NOT`,
+		cmd:  `a10 UID SEARCH OR SUBJECT hello NOT (TEXT attached)`,
+		exp:  []string{`* SEARCH 1`, `a10 OK UID SEARCH completed`},
+	}, {
+		desc: `STORE`,
+		cmd:  `a11 STORE 2 +FLAGS (\Flagged \Answered)`,
+		exp: []string{
+			`* 2 FETCH (FLAGS (\Flagged \Answered \Recent))`,
+			`a11 OK STORE completed`,
+		},
+	}, {
+		desc: `FETCH BODY[TEXT] set \Seen`,
+		cmd:  `a12 FETCH 2 BODY[1]`,
+		exp: []string{
+			"* 2 FETCH (BODY[1] {13}\r\nSee attached. FLAGS (\\Flagged \\Answered \\Seen \\Recent))",
+			`a12 OK FETCH completed`,
+		},
+	}, {
+		desc: `COPY`,
+		cmd:  `a13 COPY 2 Archive`,
+		exp:  []string{`a13 OK COPY completed`},
+	}, {
+		desc: `COPY to unknown mailbox`,
+		cmd:  `a14 COPY 2 Unknown`,
+		exp:  []string{`a14 NO [TRYCREATE] mailbox does not exist`},
+	}, {
+		desc: `UID MOVE`,
+		cmd:  `a15 UID MOVE 1 Archive`,
+		exp:  []string{`* 1 EXPUNGE`, `a15 OK UID MOVE completed`},
+	}, {
+		desc: `STATUS`,
+		cmd:  `a16 STATUS Archive (MESSAGES UIDNEXT UNSEEN)`,
+		exp: []string{
+			`* STATUS "Archive" (MESSAGES 2 UIDNEXT 3 UNSEEN 0)`,
+			`a16 OK STATUS completed`,
+		},
+	}, {
+		desc: `STORE \Deleted silently`,
+		cmd:  `a17 STORE 1 +FLAGS.SILENT (\Deleted)`,
+		exp:  []string{`a17 OK STORE completed`},
+	}, {
+		desc: `EXPUNGE`,
+		cmd:  `a18 EXPUNGE`,
+		exp:  []string{`* 1 EXPUNGE`, `a18 OK EXPUNGE completed`},
+	}, {
+		desc: `CREATE`,
+		cmd:  `a19 CREATE Sent`,
+		exp:  []string{`a19 OK CREATE completed`},
+	}, {
+		desc: `APPEND with non-synchronizing literal`,
+		cmd:  "a20 APPEND Sent (\\Seen) {12+}\r\nSubject: a\r\n",
+		exp:  []string{`a20 OK APPEND completed`},
+	}, {
+		desc: `EXAMINE`,
+		cmd:  `a21 EXAMINE Sent`,
+		exp: []string{
+			`* 1 EXISTS`,
+			`* FLAGS (\Answered \Flagged \Deleted \Seen \Draft)`,
+			`* OK [PERMANENTFLAGS (\Answered \Deleted \Draft \Flagged \Seen)] flags permitted`,
+			`* OK [UIDVALIDITY ?] UIDs valid`,
+			`* OK [UIDNEXT 2] predicted next UID`,
+			`* 0 RECENT`,
+			`a21 OK [READ-ONLY] EXAMINE completed`,
+		},
+	}, {
+		desc: `STORE on read-only mailbox`,
+		cmd:  `a22 STORE 1 FLAGS ()`,
+		exp:  []string{`a22 NO [READ-ONLY] mailbox is read-only`},
+	}, {
+		desc: `LOGOUT`,
+		cmd:  `a23 LOGOUT`,
+		exp:  []string{`* BYE logging out`, `a23 OK LOGOUT completed`},
+	}}
+
+	for _, c := range cases {
+		cl.send(c.cmd)
+
+		var (
+			tag, _, _ = strings.Cut(c.cmd, ` `)
+			got       = cl.readUntil(tag)
+		)
+		for x, line := range got {
+			// The UID validity of new mailbox is the current
+			// time.
+			if strings.HasPrefix(line, `* OK [UIDVALIDITY `) && line != `* OK [UIDVALIDITY 1] UIDs valid` {
+				got[x] = `* OK [UIDVALIDITY ?] UIDs valid`
+			}
+		}
+		test.Assert(t, c.desc, c.exp, got)
+	}
+}
+
+func TestServer_idle(t *testing.T) {
+	var (
+		srv, mg = newTestServer(t)
+		cl      = newTestClient(t, srv)
+	)
+
+	cl.readLine()
+	cl.send(`a1 LOGIN alice@example.com secret`)
+	cl.readUntil(`a1`)
+	cl.send(`a2 ENABLE IMAP4rev2`)
+	test.Assert(t, `ENABLE`, []string{`* ENABLED IMAP4rev2`, `a2 OK ENABLE completed`},
+		cl.readUntil(`a2`))
+	cl.send(`a3 SELECT INBOX`)
+	cl.readUntil(`a3`)
+
+	cl.send(`a4 IDLE`)
+	test.Assert(t, `IDLE`, `+ idling`, cl.readLine())
+
+	var _, err = mg.Incoming([]byte("Subject: new\r\n\r\nbody\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `IDLE EXISTS`, `* 3 EXISTS`, cl.readLine())
+
+	cl.send(`DONE`)
+	test.Assert(t, `IDLE DONE`, []string{`a4 OK IDLE completed`}, cl.readUntil(`a4`))
+
+	cl.send(`a5 SEARCH UNSEEN`)
+	test.Assert(t, `ESEARCH`,
+		[]string{`* ESEARCH (TAG "a5") ALL 2:3`, `a5 OK SEARCH completed`},
+		cl.readUntil(`a5`))
+}
+
+func TestServer_startTLS(t *testing.T) {
+	var (
+		srv, _    = newTestServer(t)
+		cert, err = tls.LoadX509KeyPair(`../smtp/testdata/mail.kilabit.local.cert.pem`,
+			`../smtp/testdata/mail.kilabit.local.key.pem`)
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.AllowInsecureAuth = false
+	srv.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	var cl = newTestClient(t, srv)
+
+	test.Assert(t, `greeting`,
+		`* OK [CAPABILITY IMAP4rev1 IMAP4rev2 LITERAL+ ENABLE IDLE MOVE UNSELECT SASL-IR STARTTLS LOGINDISABLED] IMAP server ready`,
+		cl.readLine())
+
+	cl.send(`a1 LOGIN alice@example.com secret`)
+	test.Assert(t, `LOGIN without TLS`,
+		[]string{`a1 NO [PRIVACYREQUIRED] use STARTTLS before login`},
+		cl.readUntil(`a1`))
+
+	cl.send(`a2 STARTTLS`)
+	test.Assert(t, `STARTTLS`, []string{`a2 OK begin TLS negotiation now`},
+		cl.readUntil(`a2`))
+
+	var tlsConn = tls.Client(cl.conn, &tls.Config{
+		InsecureSkipVerify: true, //nolint:gosec
+	})
+	err = tlsConn.Handshake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl.setConn(tlsConn)
+
+	cl.send(`a3 CAPABILITY`)
+	test.Assert(t, `CAPABILITY after STARTTLS`, []string{
+		`* CAPABILITY IMAP4rev1 IMAP4rev2 LITERAL+ ENABLE IDLE MOVE UNSELECT SASL-IR AUTH=PLAIN`,
+		`a3 OK CAPABILITY completed`,
+	}, cl.readUntil(`a3`))
+
+	// AUTHENTICATE PLAIN with initial response of
+	// "\x00alice@example.com\x00secret".
+	cl.send(`a4 AUTHENTICATE PLAIN AGFsaWNlQGV4YW1wbGUuY29tAHNlY3JldA==`)
+	test.Assert(t, `AUTHENTICATE PLAIN`, []string{
+		`a4 OK [CAPABILITY IMAP4rev1 IMAP4rev2 LITERAL+ ENABLE IDLE MOVE UNSELECT SASL-IR] AUTHENTICATE completed`,
+	}, cl.readUntil(`a4`))
+}
@@ -0,0 +1,1190 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/shuLhan/share/lib/email/maildir"
+	"github.com/shuLhan/share/lib/smtp"
+)
+
+// List of session states, RFC 9051 section 3.
+const (
+	stateNotAuthenticated = iota
+	stateAuthenticated
+	stateSelected
+	stateLogout
+)
+
+// List of status responses.
+const (
+	statusBad = `BAD`
+	statusNo  = `NO`
+)
+
+// statusError is the error returned by command handler that will be sent
+// to client as tagged NO or BAD response.
+type statusError struct {
+	status string
+	text   string
+}
+
+func (se *statusError) Error() string {
+	return se.status + ` ` + se.text
+}
+
+func errBad(format string, args ...any) error {
+	return &statusError{status: statusBad, text: fmt.Sprintf(format, args...)}
+}
+
+func errNo(format string, args ...any) error {
+	return &statusError{status: statusNo, text: fmt.Sprintf(format, args...)}
+}
+
+// errResponded returned by command handler that has sent the tagged
+// response by itself.
+var errResponded = errors.New(`tagged response has been sent`)
+
+// session contains the state of single client connection.
+type session struct {
+	srv  *Server
+	conn net.Conn
+	cr   *commandReader
+	w    *bufio.Writer
+
+	acc  *smtp.Account
+	mg   *maildir.Manager
+	mbox *mailbox
+
+	state int
+
+	isTLS bool
+
+	// isRev2 is true if client enable the IMAP4rev2.
+	isRev2 bool
+}
+
+func newSession(srv *Server, conn net.Conn) (sess *session) {
+	sess = &session{
+		srv: srv,
+	}
+	sess.setConn(conn)
+	return sess
+}
+
+// setConn set or replace the connection, for example after STARTTLS.
+func (sess *session) setConn(conn net.Conn) {
+	sess.conn = conn
+	sess.w = bufio.NewWriter(conn)
+	sess.cr = &commandReader{
+		reader: bufio.NewReader(conn),
+		cont: func() error {
+			return sess.writeLine(`+ Ready for literal data`)
+		},
+	}
+	_, sess.isTLS = conn.(*tls.Conn)
+}
+
+// run greet the client and handle its commands until LOGOUT.
+func (sess *session) run() (err error) {
+	err = sess.writeLine(`* OK [CAPABILITY %s] IMAP server ready`, sess.capabilities())
+	if err != nil {
+		return err
+	}
+
+	var cmd *command
+	for sess.state != stateLogout {
+		cmd, err = sess.cr.readCommand()
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+				errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			var tag = `*`
+			if cmd != nil {
+				tag = cmd.tag
+			}
+			err = sess.writeLine(`%s BAD %s`, tag, err)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = sess.handle(cmd)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle the command and send the tagged response.
+func (sess *session) handle(cmd *command) (err error) {
+	var (
+		handler func(cmd *command, isUID bool) (string, error)
+		state   int
+		isUID   bool
+		code    string
+	)
+
+	if cmd.name == `UID` {
+		if len(cmd.args) == 0 {
+			return sess.writeLine(`%s BAD missing UID command`, cmd.tag)
+		}
+		isUID = true
+		cmd.name = cmd.args[0].upper()
+		cmd.args = cmd.args[1:]
+		switch cmd.name {
+		case `COPY`, `FETCH`, `MOVE`, `SEARCH`, `STORE`:
+		default:
+			return sess.writeLine(`%s BAD unknown UID command`, cmd.tag)
+		}
+	}
+
+	switch cmd.name {
+	case `CAPABILITY`:
+		handler, state = sess.handleCapability, stateNotAuthenticated
+	case `NOOP`, `CHECK`:
+		handler, state = sess.handleNoop, stateNotAuthenticated
+	case `LOGOUT`:
+		handler, state = sess.handleLogout, stateNotAuthenticated
+	case `STARTTLS`:
+		handler, state = sess.handleStartTLS, stateNotAuthenticated
+	case `LOGIN`:
+		handler, state = sess.handleLogin, stateNotAuthenticated
+	case `AUTHENTICATE`:
+		handler, state = sess.handleAuthenticate, stateNotAuthenticated
+	case `ENABLE`:
+		handler, state = sess.handleEnable, stateAuthenticated
+	case `SELECT`, `EXAMINE`:
+		handler, state = sess.handleSelect, stateAuthenticated
+	case `CREATE`:
+		handler, state = sess.handleCreate, stateAuthenticated
+	case `LIST`, `LSUB`:
+		handler, state = sess.handleList, stateAuthenticated
+	case `SUBSCRIBE`, `UNSUBSCRIBE`:
+		handler, state = sess.handleSubscribe, stateAuthenticated
+	case `STATUS`:
+		handler, state = sess.handleStatus, stateAuthenticated
+	case `APPEND`:
+		handler, state = sess.handleAppend, stateAuthenticated
+	case `IDLE`:
+		handler, state = sess.handleIdle, stateAuthenticated
+	case `CLOSE`, `UNSELECT`:
+		handler, state = sess.handleClose, stateSelected
+	case `EXPUNGE`:
+		handler, state = sess.handleExpunge, stateSelected
+	case `SEARCH`:
+		handler, state = sess.handleSearch, stateSelected
+	case `FETCH`:
+		handler, state = sess.handleFetch, stateSelected
+	case `STORE`:
+		handler, state = sess.handleStore, stateSelected
+	case `COPY`, `MOVE`:
+		handler, state = sess.handleCopy, stateSelected
+	default:
+		return sess.writeLine(`%s BAD unknown command`, cmd.tag)
+	}
+
+	switch {
+	case state == stateNotAuthenticated:
+	case sess.state < state:
+		return sess.writeLine(`%s BAD %s not allowed in current state`, cmd.tag, cmd.name)
+	}
+
+	code, err = handler(cmd, isUID)
+	if err != nil {
+		if errors.Is(err, errResponded) {
+			return nil
+		}
+		var se *statusError
+		if errors.As(err, &se) {
+			return sess.writeLine(`%s %s %s`, cmd.tag, se.status, se.text)
+		}
+		return err
+	}
+	if isUID {
+		cmd.name = `UID ` + cmd.name
+	}
+	if len(code) > 0 {
+		return sess.writeLine(`%s OK %s %s completed`, cmd.tag, code, cmd.name)
+	}
+	return sess.writeLine(`%s OK %s completed`, cmd.tag, cmd.name)
+}
+
+// capabilities return list of capability based on the session state.
+func (sess *session) capabilities() string {
+	var caps = []string{`IMAP4rev1`, `IMAP4rev2`, `LITERAL+`, `ENABLE`,
+		`IDLE`, `MOVE`, `UNSELECT`, `SASL-IR`}
+
+	if sess.state == stateNotAuthenticated {
+		if !sess.isTLS && sess.srv.TLSConfig != nil {
+			caps = append(caps, `STARTTLS`)
+		}
+		if sess.canAuth() {
+			caps = append(caps, `AUTH=PLAIN`)
+		} else {
+			caps = append(caps, `LOGINDISABLED`)
+		}
+	}
+	return strings.Join(caps, ` `)
+}
+
+// canAuth return true if client allowed to login in current connection.
+func (sess *session) canAuth() bool {
+	return sess.isTLS || sess.srv.AllowInsecureAuth
+}
+
+func (sess *session) handleCapability(_ *command, _ bool) (string, error) {
+	var err = sess.writeLine(`* CAPABILITY %s`, sess.capabilities())
+	if err != nil {
+		return ``, err
+	}
+	return ``, nil
+}
+
+func (sess *session) handleNoop(_ *command, _ bool) (string, error) {
+	var err = sess.refresh()
+	if err != nil {
+		return ``, err
+	}
+	return ``, nil
+}
+
+func (sess *session) handleLogout(_ *command, _ bool) (string, error) {
+	var err = sess.writeLine(`* BYE logging out`)
+	if err != nil {
+		return ``, err
+	}
+	sess.state = stateLogout
+	return ``, nil
+}
+
+func (sess *session) handleStartTLS(cmd *command, _ bool) (string, error) {
+	if sess.state != stateNotAuthenticated || sess.isTLS || sess.srv.TLSConfig == nil {
+		return ``, errBad(`STARTTLS not available`)
+	}
+
+	var err = sess.writeLine(`%s OK begin TLS negotiation now`, cmd.tag)
+	if err != nil {
+		return ``, err
+	}
+
+	var conn = tls.Server(sess.conn, sess.srv.TLSConfig)
+
+	err = conn.Handshake()
+	if err != nil {
+		return ``, fmt.Errorf(`STARTTLS: %w`, err)
+	}
+	sess.setConn(conn)
+	return ``, errResponded
+}
+
+func (sess *session) handleLogin(cmd *command, _ bool) (string, error) {
+	if len(cmd.args) != 2 || cmd.args[0].isList || cmd.args[1].isList {
+		return ``, errBad(`invalid arguments`)
+	}
+	return sess.login(cmd.args[0].value, cmd.args[1].value)
+}
+
+// handleAuthenticate handle the AUTHENTICATE command with PLAIN
+// mechanism, with or without the initial response (RFC 4959).
+func (sess *session) handleAuthenticate(cmd *command, _ bool) (code string, err error) {
+	if len(cmd.args) == 0 || len(cmd.args) > 2 {
+		return ``, errBad(`invalid arguments`)
+	}
+	if !strings.EqualFold(cmd.args[0].value, `PLAIN`) {
+		return ``, errNo(`unsupported authentication mechanism`)
+	}
+
+	var resp string
+	if len(cmd.args) == 2 {
+		resp = cmd.args[1].value
+	} else {
+		err = sess.writeLine(`+ `)
+		if err != nil {
+			return ``, err
+		}
+		resp, err = sess.cr.readLine()
+		if err != nil {
+			return ``, err
+		}
+	}
+	if resp == `*` {
+		return ``, errBad(`authentication canceled`)
+	}
+	if resp == `=` {
+		resp = ``
+	}
+
+	var plain []byte
+
+	plain, err = base64.StdEncoding.DecodeString(resp)
+	if err != nil {
+		return ``, errBad(`invalid base64 response`)
+	}
+
+	var fields = bytes.Split(plain, []byte{0})
+	if len(fields) != 3 {
+		return ``, errBad(`invalid PLAIN response`)
+	}
+	if len(fields[0]) > 0 && !bytes.Equal(fields[0], fields[1]) {
+		return ``, errNo(`[AUTHORIZATIONFAILED] cannot authorize as other user`)
+	}
+	return sess.login(string(fields[1]), string(fields[2]))
+}
+
+// login authenticate the user and open its maildir.
+func (sess *session) login(username, password string) (code string, err error) {
+	if sess.state != stateNotAuthenticated {
+		return ``, errBad(`already authenticated`)
+	}
+	if !sess.canAuth() {
+		return ``, errNo(`[PRIVACYREQUIRED] use STARTTLS before login`)
+	}
+
+	var acc *smtp.Account
+
+	acc, err = sess.srv.authenticate(username, password)
+	if err != nil {
+		return ``, errNo(`[AUTHENTICATIONFAILED] invalid credentials`)
+	}
+
+	sess.mg, err = sess.srv.openMaildir(acc)
+	if err != nil {
+		return ``, errNo(`[SERVERBUG] cannot open mailbox`)
+	}
+
+	sess.acc = acc
+	sess.state = stateAuthenticated
+
+	return `[CAPABILITY ` + sess.capabilities() + `]`, nil
+}
+
+func (sess *session) handleEnable(cmd *command, _ bool) (code string, err error) {
+	if len(cmd.args) == 0 {
+		return ``, errBad(`missing capability`)
+	}
+	var enabled []string
+	for _, arg := range cmd.args {
+		if arg.upper() == `IMAP4REV2` && !sess.isRev2 {
+			sess.isRev2 = true
+			enabled = append(enabled, `IMAP4rev2`)
+		}
+	}
+	err = sess.writeLine(`* ENABLED %s`, strings.Join(enabled, ` `))
+	if err != nil {
+		return ``, err
+	}
+	return ``, nil
+}
+
+func (sess *session) handleSelect(cmd *command, _ bool) (code string, err error) {
+	if len(cmd.args) != 1 || cmd.args[0].isList {
+		return ``, errBad(`invalid arguments`)
+	}
+
+	// Selecting a mailbox deselect the current one, even if the
+	// command failed.
+	sess.mbox = nil
+	sess.state = stateAuthenticated
+
+	var (
+		name   = cmd.args[0].value
+		folder = lookupFolder(sess.mg, name)
+		mbox   *mailbox
+	)
+	if folder == nil {
+		return ``, errNo(`[NONEXISTENT] mailbox does not exist`)
+	}
+
+	mbox, err = openMailbox(folder, name, cmd.name == `EXAMINE`)
+	if err != nil {
+		return ``, errNo(`[SERVERBUG] %s`, err)
+	}
+
+	var (
+		n, first = mbox.countUnseen()
+		lines    = []string{
+			fmt.Sprintf(`* %d EXISTS`, len(mbox.msgs)),
+			`* FLAGS (\Answered \Flagged \Deleted \Seen \Draft)`,
+			`* OK [PERMANENTFLAGS ` + permanentFlags + `] flags permitted`,
+			fmt.Sprintf(`* OK [UIDVALIDITY %d] UIDs valid`, mbox.uidValidity),
+			fmt.Sprintf(`* OK [UIDNEXT %d] predicted next UID`, mbox.uidNext),
+		}
+	)
+	if !sess.isRev2 {
+		lines = append(lines, fmt.Sprintf(`* %d RECENT`, mbox.countRecent()))
+		if n > 0 {
+			lines = append(lines, fmt.Sprintf(`* OK [UNSEEN %d] first unseen`, first))
+		}
+	} else {
+		lines = append(lines, `* LIST () "." `+quote(mailboxName(folderName(name))))
+	}
+	for _, line := range lines {
+		err = sess.writeLine(`%s`, line)
+		if err != nil {
+			return ``, err
+		}
+	}
+
+	sess.mbox = mbox
+	sess.state = stateSelected
+
+	if mbox.isReadOnly {
+		return `[READ-ONLY]`, nil
+	}
+	return `[READ-WRITE]`, nil
+}
+
+func (sess *session) handleCreate(cmd *command, _ bool) (code string, err error) {
+	if len(cmd.args) != 1 || cmd.args[0].isList {
+		return ``, errBad(`invalid arguments`)
+	}
+
+	var name = strings.TrimSuffix(cmd.args[0].value, hierarchyDelimiter)
+	if strings.EqualFold(name, MailboxInbox) || lookupFolder(sess.mg, name) != nil {
+		return ``, errNo(`[ALREADYEXISTS] mailbox already exists`)
+	}
+
+	_, err = maildir.CreateFolder(sess.mg.Dir(), folderName(name))
+	if err != nil {
+		return ``, errNo(`[CANNOT] %s`, err)
+	}
+
+	// Re-open the maildir to scan the new folder.
+	sess.mg, err = sess.srv.openMaildir(sess.acc)
+	if err != nil {
+		return ``, errNo(`[SERVERBUG] %s`, err)
+	}
+	return ``, nil
+}
+
+func (sess *session) handleList(cmd *command, _ bool) (code string, err error) {
+	if len(cmd.args) != 2 || cmd.args[0].isList || cmd.args[1].isList {
+		return ``, errBad(`invalid arguments`)
+	}
+
+	var (
+		ref     = cmd.args[0].value
+		pattern = cmd.args[1].value
+	)
+	if len(pattern) == 0 {
+		return ``, sess.writeLine(`* %s (\Noselect) "." ""`, cmd.name)
+	}
+	pattern = ref + pattern
+
+	var names = []string{MailboxInbox}
+	for _, fname := range sess.mg.FolderNames() {
+		names = append(names, mailboxName(fname))
+	}
+	for _, name := range names {
+		// The INBOX name is case-insensitive.
+		var target = pattern
+		if name == MailboxInbox && len(pattern) >= len(name) &&
+			strings.EqualFold(pattern[:len(name)], name) {
+			target = name + pattern[len(name):]
+		}
+		if !matchPattern(target, name) {
+			continue
+		}
+		err = sess.writeLine(`* %s () "." %s`, cmd.name, quote(name))
+		if err != nil {
+			return ``, err
+		}
+	}
+	return ``, nil
+}
+
+// matchPattern return true if the mailbox name match with the LIST
+// pattern, where "*" match any characters and "%" match any characters
+// except the hierarchy delimiter.
+func matchPattern(pattern, name string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	switch pattern[0] {
+	case '*', '%':
+		for x := 0; x <= len(name); x++ {
+			if matchPattern(pattern[1:], name[x:]) {
+				return true
+			}
+			if x < len(name) && pattern[0] == '%' && name[x] == '.' {
+				return false
+			}
+		}
+		return false
+	}
+	if len(name) == 0 || pattern[0] != name[0] {
+		return false
+	}
+	return matchPattern(pattern[1:], name[1:])
+}
+
+func (sess *session) handleSubscribe(_ *command, _ bool) (string, error) {
+	// All mailboxes are subscribed.
+	return ``, nil
+}
+
+func (sess *session) handleStatus(cmd *command, _ bool) (code string, err error) {
+	if len(cmd.args) != 2 || cmd.args[0].isList || !cmd.args[1].isList {
+		return ``, errBad(`invalid arguments`)
+	}
+
+	var (
+		name   = cmd.args[0].value
+		folder = lookupFolder(sess.mg, name)
+		mbox   *mailbox
+	)
+	if folder == nil {
+		return ``, errNo(`[NONEXISTENT] mailbox does not exist`)
+	}
+
+	mbox, err = openMailbox(folder, name, true)
+	if err != nil {
+		return ``, errNo(`[SERVERBUG] %s`, err)
+	}
+
+	var list []string
+	for _, item := range cmd.args[1].list {
+		var n int64
+		switch item.upper() {
+		case `MESSAGES`:
+			n = int64(len(mbox.msgs))
+		case `RECENT`:
+			for _, msg := range mbox.msgs {
+				if msg.IsNew {
+					n++
+				}
+			}
+		case `UIDNEXT`:
+			n = int64(mbox.uidNext)
+		case `UIDVALIDITY`:
+			n = int64(mbox.uidValidity)
+		case `UNSEEN`:
+			var unseen, _ = mbox.countUnseen()
+			n = int64(unseen)
+		case `SIZE`:
+			for _, msg := range mbox.msgs {
+				n += msg.Size
+			}
+		default:
+			return ``, errBad(`unknown status item %q`, item.value)
+		}
+		list = append(list, fmt.Sprintf(`%s %d`, item.upper(), n))
+	}
+
+	err = sess.writeLine(`* STATUS %s (%s)`, quote(name), strings.Join(list, ` `))
+	if err != nil {
+		return ``, err
+	}
+	return ``, nil
+}
+
+func (sess *session) handleAppend(cmd *command, _ bool) (code string, err error) {
+	if len(cmd.args) < 2 {
+		return ``, errBad(`invalid arguments`)
+	}
+
+	var (
+		name   = cmd.args[0].value
+		msg    = cmd.args[len(cmd.args)-1]
+		folder = lookupFolder(sess.mg, name)
+		flags  string
+	)
+	if !msg.isString {
+		return ``, errBad(`missing message literal`)
+	}
+	if len(cmd.args) > 2 && cmd.args[1].isList {
+		flags = toMaildirFlags(cmd.args[1].list)
+	}
+	if folder == nil {
+		return ``, errNo(`[TRYCREATE] mailbox does not exist`)
+	}
+
+	_, err = sess.mg.Append(folder, []byte(msg.value), flags)
+	if err != nil {
+		return ``, errNo(`[SERVERBUG] %s`, err)
+	}
+
+	err = sess.refresh()
+	if err != nil {
+		return ``, err
+	}
+	return ``, nil
+}
+
+// handleIdle wait for "DONE" from client while sending the changes on
+// selected mailbox, RFC 2177.
+func (sess *session) handleIdle(_ *command, _ bool) (code string, err error) {
+	err = sess.writeLine(`+ idling`)
+	if err != nil {
+		return ``, err
+	}
+
+	type result struct {
+		err  error
+		line string
+	}
+
+	var (
+		donec  = make(chan result, 1)
+		ticker = time.NewTicker(sess.srv.IdleInterval)
+	)
+	defer ticker.Stop()
+
+	go func() {
+		var line, err = sess.cr.readLine()
+		donec <- result{line: line, err: err}
+	}()
+
+	for {
+		select {
+		case res := <-donec:
+			if res.err != nil {
+				return ``, res.err
+			}
+			if !strings.EqualFold(res.line, `DONE`) {
+				return ``, errBad(`expecting DONE`)
+			}
+			return ``, nil
+		case <-ticker.C:
+			err = sess.refresh()
+			if err != nil {
+				return ``, err
+			}
+		}
+	}
+}
+
+func (sess *session) handleClose(cmd *command, _ bool) (code string, err error) {
+	if cmd.name == `CLOSE` && !sess.mbox.isReadOnly {
+		_, err = sess.expunge()
+		if err != nil {
+			return ``, errNo(`[SERVERBUG] %s`, err)
+		}
+	}
+	sess.mbox = nil
+	sess.state = stateAuthenticated
+	return ``, nil
+}
+
+func (sess *session) handleExpunge(_ *command, _ bool) (code string, err error) {
+	if sess.mbox.isReadOnly {
+		return ``, errNo(`[READ-ONLY] mailbox is read-only`)
+	}
+
+	var expunged []uint32
+
+	expunged, err = sess.expunge()
+	if err != nil {
+		return ``, errNo(`[SERVERBUG] %s`, err)
+	}
+	err = sess.writeExpunged(expunged)
+	if err != nil {
+		return ``, err
+	}
+	return ``, nil
+}
+
+// expunge remove the messages with \Deleted flag from selected mailbox.
+func (sess *session) expunge() (expunged []uint32, err error) {
+	var deleted []*message
+	for _, msg := range sess.mbox.msgs {
+		if !msg.HasFlag(maildir.FlagTrashed) {
+			continue
+		}
+		err = sess.mbox.folder.Delete(msg.Name)
+		if err != nil {
+			break
+		}
+		deleted = append(deleted, msg)
+	}
+	return sess.mbox.remove(deleted), err
+}
+
+func (sess *session) handleSearch(cmd *command, isUID bool) (code string, err error) {
+	var (
+		args = cmd.args
+		keys []*searchKey
+	)
+	if len(args) >= 2 && args[0].upper() == `CHARSET` {
+		if !strings.EqualFold(args[1].value, `UTF-8`) &&
+			!strings.EqualFold(args[1].value, `US-ASCII`) {
+			return ``, errNo(`[BADCHARSET (UTF-8 US-ASCII)] unsupported charset`)
+		}
+		args = args[2:]
+	}
+	if len(args) >= 2 && args[0].upper() == `RETURN` && args[1].isList {
+		args = args[2:]
+	}
+
+	keys, err = parseSearchKeys(args)
+	if err != nil {
+		return ``, errBad(`%s`, err)
+	}
+
+	var (
+		result []uint32
+		sc     = &searchContext{mbox: sess.mbox}
+	)
+	for x, msg := range sess.mbox.msgs {
+		sc.msg = msg
+		sc.seq = uint32(x + 1)
+		sc.raw = nil
+		sc.err = nil
+		if !sc.matchAll(keys) {
+			continue
+		}
+		if isUID {
+			result = append(result, msg.uid)
+		} else {
+			result = append(result, sc.seq)
+		}
+	}
+
+	if sess.isRev2 {
+		var line = fmt.Sprintf(`* ESEARCH (TAG %s)`, quote(cmd.tag))
+		if isUID {
+			line += ` UID`
+		}
+		if len(result) > 0 {
+			line += ` ALL ` + formatSeqSet(result)
+		}
+		err = sess.writeLine(`%s`, line)
+	} else {
+		var sb strings.Builder
+		sb.WriteString(`* SEARCH`)
+		for _, n := range result {
+			fmt.Fprintf(&sb, ` %d`, n)
+		}
+		err = sess.writeLine(`%s`, sb.String())
+	}
+	if err != nil {
+		return ``, err
+	}
+	return ``, nil
+}
+
+func (sess *session) handleFetch(cmd *command, isUID bool) (code string, err error) {
+	if len(cmd.args) != 2 || cmd.args[0].isList {
+		return ``, errBad(`invalid arguments`)
+	}
+
+	var (
+		set   seqSet
+		items []*fetchItem
+	)
+
+	set, err = parseSeqSet(cmd.args[0].value)
+	if err != nil {
+		return ``, errBad(`%s`, err)
+	}
+	items, err = parseFetchItems(cmd.args[1], isUID)
+	if err != nil {
+		return ``, errBad(`%s`, err)
+	}
+
+	var seqs, msgs = sess.mbox.lookup(set, isUID)
+	for x, msg := range msgs {
+		err = sess.fetch(seqs[x], msg, items)
+		if err != nil {
+			return ``, err
+		}
+	}
+	return ``, nil
+}
+
+// fetch write the FETCH response of message.
+func (sess *session) fetch(seq uint32, msg *message, items []*fetchItem) (err error) {
+	var (
+		mbox = sess.mbox
+		buf  bytes.Buffer
+
+		raw      []byte
+		bp       *bodyPart
+		setSeen  bool
+		hasFlags bool
+	)
+
+	// load read the message content and parse its body only once.
+	var load = func(isParse bool) error {
+		if raw == nil {
+			var err error
+			raw, err = mbox.read(msg)
+			if err != nil {
+				return err
+			}
+		}
+		if isParse && bp == nil {
+			bp = parseBodyPart(raw)
+		}
+		return nil
+	}
+
+	fmt.Fprintf(&buf, `* %d FETCH (`, seq)
+	for x, item := range items {
+		if x > 0 {
+			buf.WriteByte(' ')
+		}
+		switch item.name {
+		case fetchUID:
+			fmt.Fprintf(&buf, `UID %d`, msg.uid)
+		case fetchFlags:
+			hasFlags = true
+			buf.WriteString(`FLAGS `)
+			buf.WriteString(sess.formatFlags(msg))
+		case fetchInternalDate:
+			fmt.Fprintf(&buf, `INTERNALDATE "%s"`, internalDate(msg).Format(layoutDateTime))
+		case fetchRFC822Size:
+			var size int64
+			size, err = mbox.rfc822Size(msg)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&buf, `RFC822.SIZE %d`, size)
+		case fetchEnvelope:
+			buf.WriteString(`ENVELOPE `)
+			buf.WriteString(envelope(msg.Header))
+		case fetchBody, fetchBodyStructure:
+			if item.section != nil {
+				break
+			}
+			err = load(true)
+			if err != nil {
+				return err
+			}
+			buf.WriteString(item.name)
+			buf.WriteByte(' ')
+			buf.WriteString(bp.structure(item.name == fetchBodyStructure))
+			continue
+		case fetchRFC822, fetchRFC822Text:
+			setSeen = true
+			fallthrough
+		case fetchRFC822Header:
+			err = load(false)
+			if err != nil {
+				return err
+			}
+			var header, body = splitMessage(raw)
+			var content = raw
+			switch item.name {
+			case fetchRFC822Header:
+				content = header
+			case fetchRFC822Text:
+				content = body
+			}
+			buf.WriteString(item.name)
+			buf.WriteByte(' ')
+			writeLiteral(&buf, content)
+		}
+		if item.section == nil {
+			continue
+		}
+
+		err = load(true)
+		if err != nil {
+			return err
+		}
+		if item.name == fetchBody {
+			setSeen = true
+		}
+
+		var content, ok = bp.section(item.section)
+
+		fmt.Fprintf(&buf, `BODY[%s]`, item.section)
+		if item.isPartial {
+			fmt.Fprintf(&buf, `<%d>`, item.start)
+			content = partial(content, item.start, item.length)
+		}
+		buf.WriteByte(' ')
+		if !ok {
+			buf.WriteString(`NIL`)
+			continue
+		}
+		writeLiteral(&buf, content)
+	}
+
+	if setSeen && !mbox.isReadOnly && !msg.HasFlag(maildir.FlagSeen) {
+		err = sess.storeFlags(msg, string(maildir.FlagSeen), '+')
+		if err != nil {
+			return err
+		}
+		if !hasFlags {
+			buf.WriteString(` FLAGS `)
+			buf.WriteString(sess.formatFlags(msg))
+		}
+	}
+	buf.WriteByte(')')
+
+	return sess.writeLine(`%s`, buf.String())
+}
+
+// partial return the content from start with maximum length.
+func partial(content []byte, start, length int) []byte {
+	if start >= len(content) {
+		return nil
+	}
+	content = content[start:]
+	if length < len(content) {
+		content = content[:length]
+	}
+	return content
+}
+
+// writeLiteral write the content as literal string.
+func writeLiteral(buf *bytes.Buffer, content []byte) {
+	fmt.Fprintf(buf, "{%d}\r\n", len(content))
+	buf.Write(content)
+}
+
+func (sess *session) handleStore(cmd *command, isUID bool) (code string, err error) {
+	if len(cmd.args) != 3 || cmd.args[0].isList || cmd.args[1].isList {
+		return ``, errBad(`invalid arguments`)
+	}
+	if sess.mbox.isReadOnly {
+		return ``, errNo(`[READ-ONLY] mailbox is read-only`)
+	}
+
+	var set seqSet
+
+	set, err = parseSeqSet(cmd.args[0].value)
+	if err != nil {
+		return ``, errBad(`%s`, err)
+	}
+
+	var (
+		item     = cmd.args[1].upper()
+		isSilent = strings.HasSuffix(item, `.SILENT`)
+		op       byte
+	)
+	switch strings.TrimSuffix(item, `.SILENT`) {
+	case `FLAGS`:
+		op = '='
+	case `+FLAGS`:
+		op = '+'
+	case `-FLAGS`:
+		op = '-'
+	default:
+		return ``, errBad(`invalid store item %q`, cmd.args[1].value)
+	}
+
+	var list = cmd.args[2].list
+	if !cmd.args[2].isList {
+		list = []*field{cmd.args[2]}
+	}
+
+	var (
+		flags      = toMaildirFlags(list)
+		seqs, msgs = sess.mbox.lookup(set, isUID)
+	)
+	for x, msg := range msgs {
+		err = sess.storeFlags(msg, flags, op)
+		if err != nil {
+			return ``, errNo(`[SERVERBUG] %s`, err)
+		}
+		if isSilent {
+			continue
+		}
+		var line = fmt.Sprintf(`* %d FETCH (FLAGS %s`, seqs[x], sess.formatFlags(msg))
+		if isUID {
+			line += fmt.Sprintf(` UID %d`, msg.uid)
+		}
+		err = sess.writeLine(`%s)`, line)
+		if err != nil {
+			return ``, err
+		}
+	}
+	return ``, nil
+}
+
+// storeFlags replace ('='), add ('+'), or remove ('-') the maildir flags
+// of message.
+func (sess *session) storeFlags(msg *message, flags string, op byte) (err error) {
+	var folder = sess.mbox.folder
+
+	switch op {
+	case '=':
+		// Keep the maildir flags that does not have IMAP system
+		// flag, for example "P" (passed).
+		for x := 0; x < len(msg.Flags); x++ {
+			var isSystem bool
+			for _, sf := range systemFlags {
+				if msg.Flags[x] == sf.maildir {
+					isSystem = true
+					break
+				}
+			}
+			if !isSystem {
+				flags += string(msg.Flags[x])
+			}
+		}
+		msg.Name, err = folder.SetFlags(msg.Name, flags)
+	case '+':
+		msg.Name, err = folder.AddFlags(msg.Name, flags)
+	case '-':
+		msg.Name, err = folder.RemoveFlags(msg.Name, flags)
+	}
+	if err != nil {
+		return err
+	}
+
+	var x = strings.LastIndex(msg.Name, `:2,`)
+	if x >= 0 {
+		msg.Flags = msg.Name[x+3:]
+	}
+	msg.IsNew = false
+	return nil
+}
+
+func (sess *session) handleCopy(cmd *command, isUID bool) (code string, err error) {
+	if len(cmd.args) != 2 || cmd.args[0].isList || cmd.args[1].isList {
+		return ``, errBad(`invalid arguments`)
+	}
+
+	var set seqSet
+
+	set, err = parseSeqSet(cmd.args[0].value)
+	if err != nil {
+		return ``, errBad(`%s`, err)
+	}
+
+	var (
+		isMove = cmd.name == `MOVE`
+		dst    = lookupFolder(sess.mg, cmd.args[1].value)
+	)
+	if dst == nil {
+		return ``, errNo(`[TRYCREATE] mailbox does not exist`)
+	}
+	if isMove && sess.mbox.isReadOnly {
+		return ``, errNo(`[READ-ONLY] mailbox is read-only`)
+	}
+	if isMove && dst.Dir() == sess.mbox.folder.Dir() {
+		return ``, errNo(`[CANNOT] cannot move into the same mailbox`)
+	}
+
+	var (
+		_, msgs = sess.mbox.lookup(set, isUID)
+		moved   []*message
+		content []byte
+	)
+	for _, msg := range msgs {
+		if isMove {
+			_, err = sess.mbox.folder.Move(msg.Name, dst)
+			if err != nil {
+				break
+			}
+			moved = append(moved, msg)
+			continue
+		}
+		content, err = sess.mbox.folder.Read(msg.Name)
+		if err != nil {
+			break
+		}
+		_, err = sess.mg.Append(dst, content, msg.Flags)
+		if err != nil {
+			break
+		}
+	}
+
+	var errw = sess.writeExpunged(sess.mbox.remove(moved))
+	if errw != nil {
+		return ``, errw
+	}
+	if err != nil {
+		return ``, errNo(`[SERVERBUG] %s`, err)
+	}
+	return ``, nil
+}
+
+// refresh check the changes in selected mailbox and send the untagged
+// EXPUNGE, EXISTS, RECENT, and FETCH responses.
+func (sess *session) refresh() (err error) {
+	var mbox = sess.mbox
+	if mbox == nil {
+		return nil
+	}
+
+	var (
+		prev    = len(mbox.msgs)
+		changed []*message
+		seqs    []uint32
+	)
+
+	seqs, changed, err = mbox.sync()
+	if err != nil {
+		return err
+	}
+
+	err = sess.writeExpunged(seqs)
+	if err != nil {
+		return err
+	}
+	if len(mbox.msgs) != prev-len(seqs) {
+		err = sess.writeLine(`* %d EXISTS`, len(mbox.msgs))
+		if err != nil {
+			return err
+		}
+		if !sess.isRev2 {
+			err = sess.writeLine(`* %d RECENT`, mbox.countRecent())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for x, msg := range mbox.msgs {
+		for _, c := range changed {
+			if c != msg {
+				continue
+			}
+			err = sess.writeLine(`* %d FETCH (FLAGS %s)`, x+1, sess.formatFlags(msg))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeExpunged send the EXPUNGE response for each sequence number.
+func (sess *session) writeExpunged(seqs []uint32) (err error) {
+	for _, seq := range seqs {
+		err = sess.writeLine(`* %d EXPUNGE`, seq)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatFlags return the IMAP flags of message.
+// The \Recent flag is not available in IMAP4rev2.
+func (sess *session) formatFlags(msg *message) string {
+	return formatFlags(msg.Flags, msg.isRecent && !sess.isRev2)
+}
+
+// writeLine write the formatted line terminated by CRLF and flush it.
+func (sess *session) writeLine(format string, args ...any) (err error) {
+	_, err = fmt.Fprintf(sess.w, format, args...)
+	if err != nil {
+		return err
+	}
+	_, err = sess.w.WriteString("\r\n")
+	if err != nil {
+		return err
+	}
+	return sess.w.Flush()
+}
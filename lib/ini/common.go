@@ -5,6 +5,9 @@
 package ini
 
 import (
+	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -44,6 +47,56 @@ func IsValueBoolTrue(v string) bool {
 	return false
 }
 
+// ParseSize parse the size in bytes with optional unit suffix.
+// The unit is case insensitive, one of "k", "m", "g", or "t", with optional
+// "b" or "ib" suffix.
+// Like in Git, each unit is a power of 1024, for example "1k", "1kb", and
+// "1KiB" are equal to 1024 bytes.
+// The number can be fractional, for example "1.5m".
+func ParseSize(v string) (size int64, err error) {
+	var (
+		logp = `ParseSize`
+		num  = strings.ToLower(strings.TrimSpace(v))
+		mult float64
+	)
+
+	if strings.HasSuffix(num, `ib`) {
+		num = num[:len(num)-2]
+	} else if strings.HasSuffix(num, `b`) {
+		num = num[:len(num)-1]
+	}
+
+	mult = 1
+	if len(num) > 0 {
+		switch num[len(num)-1] {
+		case 'k':
+			mult = 1 << 10
+		case 'm':
+			mult = 1 << 20
+		case 'g':
+			mult = 1 << 30
+		case 't':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			num = strings.TrimSpace(num[:len(num)-1])
+		}
+	}
+
+	var f64 float64
+
+	f64, err = strconv.ParseFloat(num, 64)
+	if err != nil || f64 < 0 || math.IsNaN(f64) {
+		return 0, fmt.Errorf(`%s: invalid size %q`, logp, v)
+	}
+
+	f64 *= mult
+	if f64 > math.MaxInt64 {
+		return 0, fmt.Errorf(`%s: size %q is out of range`, logp, v)
+	}
+	return int64(f64), nil
+}
+
 // ParseTag parse the ini field tag as used in the struct's field.
 // This returned slice always have 4 string element: section, subsection, key,
 // and default value.
@@ -124,3 +124,57 @@ func TestParseTag_fromStruct(t *testing.T) {
 		test.Assert(t, tag, exp[x], got)
 	}
 }
+
+func TestParseSize(t *testing.T) {
+	type testCase struct {
+		in     string
+		expErr string
+		exp    int64
+	}
+
+	var cases = []testCase{{
+		in:  `512`,
+		exp: 512,
+	}, {
+		in:  `512b`,
+		exp: 512,
+	}, {
+		in:  `1k`,
+		exp: 1024,
+	}, {
+		in:  `1 KB`,
+		exp: 1024,
+	}, {
+		in:  `1.5MiB`,
+		exp: 1572864,
+	}, {
+		in:  `2g`,
+		exp: 2 << 30,
+	}, {
+		in:  `1T`,
+		exp: 1 << 40,
+	}, {
+		in:     `-1k`,
+		expErr: `ParseSize: invalid size "-1k"`,
+	}, {
+		in:     `1x`,
+		expErr: `ParseSize: invalid size "1x"`,
+	}, {
+		in:     `9000000t`,
+		expErr: `ParseSize: size "9000000t" is out of range`,
+	}}
+
+	var (
+		c   testCase
+		got int64
+		err error
+	)
+	for _, c = range cases {
+		got, err = ParseSize(c.in)
+		if err != nil {
+			test.Assert(t, c.in, c.expErr, err.Error())
+			continue
+		}
+		test.Assert(t, c.in, c.exp, got)
+	}
+}
@@ -217,6 +217,58 @@ will be marshaled into
 
 The syntax and rules for unmarshaling is equal to the marshaling.
 
+Beside time.Time, the other standard types that supported are
+time.Duration, net.IP, url.URL, and *regexp.Regexp.
+If the value cannot be converted to those types, or to the primitive
+number type, the value is ignored, unless the field has "constraint"
+tag, where Unmarshal return an error.
+
+The default value of field can be defined as the fourth element in the
+"ini" tag, which is applied when the key is not exist,
+
+	Port int `ini:"server::port:8080"`
+
+The value of field can be validated using the "constraint" tag, which
+contains one or more of the following constraint, separated by comma,
+
+  - required: the key must be exist, if it does not have default value.
+  - min=N: the minimum value of number or time.Duration, or the minimum
+    length of string, slice, or map.
+  - max=N: the maximum value of number or time.Duration, or the maximum
+    length of string, slice, or map.
+  - enum=A|B|...: the value must be one of A, B, and so on.
+
+For example,
+
+	type Server struct {
+		Name    string        `ini:"server::name" constraint:"required"`
+		Mode    string        `ini:"server::mode:dev" constraint:"enum=dev|prod"`
+		Port    int           `ini:"server::port:8080" constraint:"min=1,max=65535"`
+		Timeout time.Duration `ini:"server::timeout:30s" constraint:"max=1m"`
+	}
+
+All errors are reported together, with each error contains the key and
+the line number and file name of its value.
+
+# Interpolation
+
+The reference to environment variable and other key in the value can be
+expanded by calling [Ini.Interpolate] after parsing and before
+unmarshaling.
+The "${NAME}" is replaced with the value of environment variable NAME and
+the "${section:sub:key}" is replaced with the value of other key,
+
+	[server]
+	host = ${HOSTNAME}
+	url = http://${server::host}:8080
+
+# Typed getters
+
+Beside Val and Vals, the value can be retrieved and converted using
+GetDuration, GetIP, GetRegexp, GetSize, and GetURL.
+The GetSize parse the value with unit K, M, G, or T, for example "10MB"
+or "1.5GiB", using [ParseSize].
+
 [Git configuration]: https://git-scm.com/docs/git-config#_configuration_file
 */
 package ini
@@ -0,0 +1,188 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ini
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldConstraint define the constraints of struct field, parsed from the
+// "constraint" tag.
+type fieldConstraint struct {
+	min  string
+	max  string
+	enum []string
+
+	required bool
+}
+
+// parseFieldConstraint parse the value of "constraint" tag, a list of
+// constraint separated by comma,
+//
+//	required
+//	min=<number|duration>
+//	max=<number|duration>
+//	enum=<value>|<value>|...
+func parseFieldConstraint(tag string) (fc *fieldConstraint, err error) {
+	fc = &fieldConstraint{}
+
+	var (
+		opt  string
+		name string
+		val  string
+	)
+	for _, opt = range strings.Split(tag, `,`) {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+		name, val, _ = strings.Cut(opt, `=`)
+		switch name {
+		case `required`:
+			fc.required = true
+		case `min`:
+			fc.min = val
+		case `max`:
+			fc.max = val
+		case `enum`:
+			fc.enum = strings.Split(val, `|`)
+		default:
+			return nil, fmt.Errorf(`unknown constraint %q`, opt)
+		}
+	}
+	return fc, nil
+}
+
+// validate set the field to its default value if its not set, and then
+// check the field value against its constraint.
+// The field that has invalid value is not checked, since the error has
+// been reported when setting its value.
+func (sfield *structField) validate() (err error) {
+	if sfield.isInvalid {
+		return nil
+	}
+
+	var fc *fieldConstraint
+
+	fc, err = parseFieldConstraint(sfield.constraint)
+	if err != nil {
+		return err
+	}
+
+	if !sfield.isSet {
+		if len(sfield.def) > 0 {
+			sfield.isSet = true
+			sfield.vals = append(sfield.vals, sfield.def)
+			if !sfield.set(sfield.def) && sfield.isStrict() &&
+				isParsableType(sfield.ftype) {
+				return fmt.Errorf(`invalid default value %q`, sfield.def)
+			}
+		} else if fc.required {
+			return fmt.Errorf(`missing required value`)
+		} else {
+			return nil
+		}
+	}
+
+	if len(fc.enum) > 0 {
+		var val string
+		for _, val = range sfield.vals {
+			if !isEnum(fc.enum, val) {
+				return fmt.Errorf(`value %q is not one of %s`, val,
+					strings.Join(fc.enum, `|`))
+			}
+		}
+	}
+	if len(fc.min) > 0 {
+		err = sfield.checkRange(fc.min, true)
+		if err != nil {
+			return err
+		}
+	}
+	if len(fc.max) > 0 {
+		err = sfield.checkRange(fc.max, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRange check the field value against the min or max bound.
+// For number and duration, the value is compared with the bound.
+// For string, slice, and map, the length of value is compared with the
+// bound.
+func (sfield *structField) checkRange(bound string, isMin bool) (err error) {
+	var (
+		fval  = sfield.fval
+		what  = `value`
+		got   float64
+		limit float64
+	)
+
+	for fval.Kind() == reflect.Ptr {
+		if fval.IsNil() {
+			return nil
+		}
+		fval = fval.Elem()
+	}
+
+	switch fval.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		got = float64(fval.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		got = float64(fval.Uint())
+	case reflect.Float32, reflect.Float64:
+		got = fval.Float()
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		what = `length`
+		got = float64(fval.Len())
+	default:
+		return fmt.Errorf(`min and max is not applicable to %s`, fval.Type())
+	}
+
+	if fval.Type() == typeDuration {
+		var dur time.Duration
+		dur, err = time.ParseDuration(bound)
+		limit = float64(dur)
+	} else {
+		limit, err = strconv.ParseFloat(bound, 64)
+	}
+	if err != nil {
+		return fmt.Errorf(`invalid constraint bound %q`, bound)
+	}
+
+	if isMin && got < limit {
+		return fmt.Errorf(`%s %s is less than min %s`, what, valueString(fval), bound)
+	}
+	if !isMin && got > limit {
+		return fmt.Errorf(`%s %s is greater than max %s`, what, valueString(fval), bound)
+	}
+	return nil
+}
+
+// valueString return the field value or its length as string for error
+// message.
+func valueString(fval reflect.Value) string {
+	switch fval.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return strconv.Itoa(fval.Len())
+	}
+	return fmt.Sprintf(`%v`, fval.Interface())
+}
+
+func isEnum(enum []string, val string) bool {
+	var v string
+	for _, v = range enum {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
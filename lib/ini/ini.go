@@ -10,8 +10,11 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"net"
+	"net/url"
 	"os"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -20,12 +23,17 @@ import (
 )
 
 const (
-	fieldTagName      = "ini"
-	fieldTagSeparator = ":"
+	fieldTagName       = "ini"
+	fieldTagConstraint = "constraint"
+	fieldTagSeparator  = ":"
 )
 
 // Ini contains the parsed file.
 type Ini struct {
+	// filename is the name of parsed file, used to report the
+	// location of invalid value.
+	filename string
+
 	secs []*Section
 }
 
@@ -341,7 +349,10 @@ func (in *Ini) Unmarshal(v interface{}) (err error) {
 
 	var tagField = unpackTagStructField(rtipe, rvalue)
 
-	in.unmarshal(tagField)
+	err = in.unmarshal(tagField)
+	if err != nil {
+		return fmt.Errorf(`ini: Unmarshal: %w`, err)
+	}
 
 	return nil
 }
@@ -583,6 +594,97 @@ func (in *Ini) GetBool(secName, subName, key string, def bool) bool {
 	return IsValueBoolTrue(out)
 }
 
+// GetDuration return the key's value as [time.Duration].
+//
+// If no key found it will return default value.
+// If the value is not valid duration, it will return default value with an
+// error.
+func (in *Ini) GetDuration(secName, subName, key string, def time.Duration) (val time.Duration, err error) {
+	var v = in.getVariable(secName, subName, key)
+	if v == nil {
+		return def, nil
+	}
+
+	val, err = time.ParseDuration(v.value)
+	if err != nil {
+		return def, in.errorAt(`GetDuration`, v, err)
+	}
+	return val, nil
+}
+
+// GetIP return the key's value as [net.IP].
+//
+// If no key found it will return default value.
+// If the value is not valid IP address, it will return default value with
+// an error.
+func (in *Ini) GetIP(secName, subName, key string, def net.IP) (val net.IP, err error) {
+	var v = in.getVariable(secName, subName, key)
+	if v == nil {
+		return def, nil
+	}
+
+	val = net.ParseIP(v.value)
+	if val == nil {
+		return def, in.errorAt(`GetIP`, v, fmt.Errorf(`invalid IP address %q`, v.value))
+	}
+	return val, nil
+}
+
+// GetRegexp return the key's value compiled as [regexp.Regexp].
+//
+// If no key found it will return default value.
+// If the value is not valid regular expression, it will return default
+// value with an error.
+func (in *Ini) GetRegexp(secName, subName, key string, def *regexp.Regexp) (val *regexp.Regexp, err error) {
+	var v = in.getVariable(secName, subName, key)
+	if v == nil {
+		return def, nil
+	}
+
+	val, err = regexp.Compile(v.value)
+	if err != nil {
+		return def, in.errorAt(`GetRegexp`, v, err)
+	}
+	return val, nil
+}
+
+// GetSize return the key's value as size in bytes.
+// See [ParseSize] for the format of value.
+//
+// If no key found it will return default value.
+// If the value is not valid size, it will return default value with an
+// error.
+func (in *Ini) GetSize(secName, subName, key string, def int64) (val int64, err error) {
+	var v = in.getVariable(secName, subName, key)
+	if v == nil {
+		return def, nil
+	}
+
+	val, err = ParseSize(v.value)
+	if err != nil {
+		return def, in.errorAt(`GetSize`, v, err)
+	}
+	return val, nil
+}
+
+// GetURL return the key's value as [url.URL].
+//
+// If no key found it will return default value.
+// If the value is not valid URL, it will return default value with an
+// error.
+func (in *Ini) GetURL(secName, subName, key string, def *url.URL) (val *url.URL, err error) {
+	var v = in.getVariable(secName, subName, key)
+	if v == nil {
+		return def, nil
+	}
+
+	val, err = url.Parse(v.value)
+	if err != nil {
+		return def, in.errorAt(`GetURL`, v, err)
+	}
+	return val, nil
+}
+
 // Gets key's values as slice of string in the same section and subsection.
 func (in *Ini) Gets(secName, subName, key string) (out []string) {
 	secName = strings.ToLower(secName)
@@ -839,6 +941,48 @@ func (in *Ini) Write(w io.Writer) (err error) {
 	return
 }
 
+// errorAt return an error that wrap err with the line number of variable v
+// and the file name.
+func (in *Ini) errorAt(logp string, v *variable, err error) error {
+	return fmt.Errorf(`%s: %s: %w`, logp, in.location(v.lineNum), err)
+}
+
+// location return the line number and file name, "line N at filename", or
+// "line N" if the Ini is not parsed from file.
+func (in *Ini) location(lineNum int) string {
+	if len(in.filename) == 0 {
+		return fmt.Sprintf(`line %d`, lineNum)
+	}
+	return fmt.Sprintf(`line %d at %s`, lineNum, in.filename)
+}
+
+// getVariable return the last variable with the key in section and/or
+// subsection, or nil if not found.
+func (in *Ini) getVariable(secName, subName, key string) (v *variable) {
+	if len(secName) == 0 || len(key) == 0 {
+		return nil
+	}
+
+	var (
+		sec = strings.ToLower(secName)
+		x   = len(in.secs) - 1
+	)
+	key = strings.ToLower(key)
+	for ; x >= 0; x-- {
+		if in.secs[x].nameLower != sec {
+			continue
+		}
+		if in.secs[x].sub != subName {
+			continue
+		}
+		_, v = in.secs[x].getVariable(key)
+		if v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
 // getSection return the last section that have the same name and/or with
 // subsection's name.
 // Section's name MUST have in lowercase.
@@ -0,0 +1,165 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ini
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// interpolator contains the state for expanding the values.
+type interpolator struct {
+	in      *Ini
+	lookup  func(name string) (string, bool)
+	done    map[*variable]struct{}
+	visited map[*variable]struct{}
+}
+
+// Interpolate expand the reference to environment variable or to other key
+// in all values.
+//
+// The "${NAME}" is replaced with the value of environment variable NAME.
+// The "${section:sub:key}" is replaced with the value of key in section
+// and subsection, using the same syntax as [Ini.Val].
+// The "$${" is replaced with literal "${".
+//
+// The referenced key is expanded first, so it can reference another key
+// or environment variable.
+// It will return an error if the environment variable or key is not
+// defined, or if the reference is cyclic.
+//
+// Only the values are changed, writing the Ini back using [Ini.Write] or
+// [Ini.Save] keep the original references.
+func (in *Ini) Interpolate() (err error) {
+	return in.InterpolateFunc(os.LookupEnv)
+}
+
+// InterpolateFunc expand the reference like [Ini.Interpolate], but using
+// function lookup to resolve the "${NAME}" reference instead of
+// environment variables.
+func (in *Ini) InterpolateFunc(lookup func(name string) (string, bool)) (err error) {
+	var (
+		ip = interpolator{
+			in:      in,
+			lookup:  lookup,
+			done:    make(map[*variable]struct{}),
+			visited: make(map[*variable]struct{}),
+		}
+
+		sec *Section
+		v   *variable
+	)
+	for _, sec = range in.secs {
+		for _, v = range sec.vars {
+			err = ip.expand(v)
+			if err != nil {
+				return fmt.Errorf(`Interpolate: %w`, err)
+			}
+		}
+	}
+	return nil
+}
+
+// expand the references in value of variable v.
+func (ip *interpolator) expand(v *variable) (err error) {
+	if v.mode&lineModeKeyValue == 0 {
+		return nil
+	}
+	if _, ok := ip.done[v]; ok {
+		return nil
+	}
+	if _, ok := ip.visited[v]; ok {
+		return fmt.Errorf(`cyclic reference on key %q, %s`, v.key,
+			ip.in.location(v.lineNum))
+	}
+	if !strings.Contains(v.value, `${`) {
+		ip.done[v] = struct{}{}
+		return nil
+	}
+
+	ip.visited[v] = struct{}{}
+
+	var (
+		value = v.value
+
+		sb    strings.Builder
+		name  string
+		start int
+		end   int
+	)
+	for {
+		start = strings.Index(value, `${`)
+		if start < 0 {
+			sb.WriteString(value)
+			break
+		}
+		if start > 0 && value[start-1] == '$' {
+			// Escaped "$${".
+			sb.WriteString(value[:start-1])
+			sb.WriteString(`${`)
+			value = value[start+2:]
+			continue
+		}
+		sb.WriteString(value[:start])
+
+		end = strings.IndexByte(value[start:], '}')
+		if end < 0 {
+			return fmt.Errorf(`unterminated reference in %q, %s`, v.value,
+				ip.in.location(v.lineNum))
+		}
+		end += start
+
+		name = value[start+2 : end]
+		if strings.Contains(name, `:`) {
+			var ref *variable
+
+			ref, err = ip.getKey(name)
+			if err != nil {
+				return fmt.Errorf(`%w, %s`, err, ip.in.location(v.lineNum))
+			}
+			err = ip.expand(ref)
+			if err != nil {
+				return err
+			}
+			sb.WriteString(ref.value)
+		} else {
+			var (
+				val string
+				ok  bool
+			)
+			val, ok = ip.lookup(name)
+			if !ok {
+				return fmt.Errorf(`undefined environment variable %q, %s`,
+					name, ip.in.location(v.lineNum))
+			}
+			sb.WriteString(val)
+		}
+
+		value = value[end+1:]
+	}
+
+	v.value = sb.String()
+
+	delete(ip.visited, v)
+	ip.done[v] = struct{}{}
+
+	return nil
+}
+
+// getKey return the variable referenced by name, in the format
+// "section:sub:key".
+func (ip *interpolator) getKey(name string) (ref *variable, err error) {
+	var keys = strings.Split(name, `:`)
+	if len(keys) != 3 {
+		return nil, fmt.Errorf(`invalid reference %q`, name)
+	}
+
+	ref = ip.in.getVariable(keys[0], keys[1], keys[2])
+	if ref == nil {
+		return nil, fmt.Errorf(`undefined key %q`, name)
+	}
+	return ref, nil
+}
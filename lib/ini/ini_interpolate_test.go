@@ -0,0 +1,107 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ini
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestIni_Interpolate(t *testing.T) {
+	var (
+		text = `[server]
+host = ${HOST}
+port = 8080
+address = ${server::host}:${server::port}
+
+[client]
+url = http://${server::address}/
+literal = $${HOME}
+`
+		cfg *Ini
+		err error
+	)
+
+	t.Setenv(`HOST`, `127.0.0.1`)
+
+	cfg, err = Parse([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cfg.Interpolate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `client:url`, `http://127.0.0.1:8080/`, cfg.Val(`client::url`))
+	test.Assert(t, `client:literal`, `${HOME}`, cfg.Val(`client::literal`))
+
+	// Writing the Ini keep the original references.
+	var buf bytes.Buffer
+
+	err = cfg.Write(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Write`, text, buf.String())
+}
+
+func TestIni_InterpolateFunc(t *testing.T) {
+	type testCase struct {
+		desc   string
+		text   string
+		expErr string
+	}
+
+	var (
+		vars = map[string]string{
+			`NAME`: `value`,
+		}
+		lookup = func(name string) (val string, ok bool) {
+			val, ok = vars[name]
+			return val, ok
+		}
+	)
+
+	var cases = []testCase{{
+		desc:   `undefined environment variable`,
+		text:   "[a]\nb = ${NOTEXIST}\n",
+		expErr: `Interpolate: undefined environment variable "NOTEXIST", line 2`,
+	}, {
+		desc:   `undefined key`,
+		text:   "[a]\nb = ${NAME}\nc = ${a::d}\n",
+		expErr: `Interpolate: undefined key "a::d", line 3`,
+	}, {
+		desc:   `invalid reference`,
+		text:   "[a]\nb = ${a:b}\n",
+		expErr: `Interpolate: invalid reference "a:b", line 2`,
+	}, {
+		desc:   `unterminated reference`,
+		text:   "[a]\nb = ${NAME\n",
+		expErr: `Interpolate: unterminated reference in "${NAME", line 2`,
+	}, {
+		desc:   `cyclic reference`,
+		text:   "[a]\nb = ${a::c}\nc = ${a::b}\n",
+		expErr: `Interpolate: cyclic reference on key "b", line 2`,
+	}}
+
+	var (
+		c   testCase
+		cfg *Ini
+		err error
+	)
+	for _, c = range cases {
+		cfg, err = Parse([]byte(c.text))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = cfg.InterpolateFunc(lookup)
+		test.Assert(t, c.desc, c.expErr, err.Error())
+	}
+}
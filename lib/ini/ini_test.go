@@ -7,6 +7,9 @@ package ini
 import (
 	"bytes"
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
 	"testing"
 	"time"
 
@@ -360,3 +363,79 @@ func TestIni_Set(t *testing.T) {
 		test.Assert(t, c.desc, c.exp, gotWrite.String())
 	}
 }
+
+func TestIni_GetTyped(t *testing.T) {
+	var (
+		text = `[server]
+timeout = 1m30s
+max-body = 1.5MiB
+address = 127.0.0.1
+upstream = https://example.com/api
+allow = ^/api/.*$
+
+[invalid]
+timeout = 90
+max-body = 1 PB
+address = localhost
+upstream = "http://[::1"
+allow = (
+`
+		cfg *Ini
+		err error
+	)
+
+	cfg, err = Parse([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dur time.Duration
+
+	dur, err = cfg.GetDuration(`server`, ``, `timeout`, time.Second)
+	test.Assert(t, `GetDuration`, 90*time.Second, dur)
+	test.Assert(t, `GetDuration: error`, nil, err)
+
+	dur, _ = cfg.GetDuration(`server`, ``, `notexist`, time.Second)
+	test.Assert(t, `GetDuration: default`, time.Second, dur)
+
+	dur, err = cfg.GetDuration(`invalid`, ``, `timeout`, time.Second)
+	test.Assert(t, `GetDuration: invalid`, time.Second, dur)
+	test.Assert(t, `GetDuration: invalid error`,
+		`GetDuration: line 9: time: missing unit in duration "90"`, err.Error())
+
+	var size int64
+
+	size, _ = cfg.GetSize(`server`, ``, `max-body`, 0)
+	test.Assert(t, `GetSize`, int64(1572864), size)
+
+	_, err = cfg.GetSize(`invalid`, ``, `max-body`, 0)
+	test.Assert(t, `GetSize: invalid error`,
+		`GetSize: line 10: ParseSize: invalid size "1 PB"`, err.Error())
+
+	var ip net.IP
+
+	ip, _ = cfg.GetIP(`server`, ``, `address`, nil)
+	test.Assert(t, `GetIP`, `127.0.0.1`, ip.String())
+
+	_, err = cfg.GetIP(`invalid`, ``, `address`, nil)
+	test.Assert(t, `GetIP: invalid error`,
+		`GetIP: line 11: invalid IP address "localhost"`, err.Error())
+
+	var u *url.URL
+
+	u, _ = cfg.GetURL(`server`, ``, `upstream`, nil)
+	test.Assert(t, `GetURL`, `/api`, u.Path)
+
+	_, err = cfg.GetURL(`invalid`, ``, `upstream`, nil)
+	test.Assert(t, `GetURL: invalid error`,
+		`GetURL: line 12: parse "http://[::1": missing ']' in host`, err.Error())
+
+	var re *regexp.Regexp
+
+	re, _ = cfg.GetRegexp(`server`, ``, `allow`, nil)
+	test.Assert(t, `GetRegexp`, true, re.MatchString(`/api/v1`))
+
+	_, err = cfg.GetRegexp(`invalid`, ``, `allow`, nil)
+	test.Assert(t, `GetRegexp: invalid error`,
+		"GetRegexp: line 13: error parsing regexp: missing closing ): `(`", err.Error())
+}
@@ -5,6 +5,7 @@
 package ini
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -13,11 +14,14 @@ import (
 
 // unmarshal set each section-subsection variables into the struct
 // fields.
-func (in *Ini) unmarshal(tagField *tagStructField) {
+// After all variables has been set, the default value and constraint of
+// each field is applied.
+func (in *Ini) unmarshal(tagField *tagStructField) (err error) {
 	var (
 		sec    *Section
 		sfield *structField
 		v      *variable
+		errs   []error
 		tag    string
 		ok     bool
 	)
@@ -38,14 +42,19 @@ func (in *Ini) unmarshal(tagField *tagStructField) {
 					if !ok {
 						continue
 					}
-					sfield.set(v.value)
+					err = sfield.setVar(v)
+					if err != nil {
+						errs = append(errs, in.fieldError(tag, sfield, err))
+					}
 				}
 				continue
 			}
 		}
+		sfield.isSet = true
+
 		switch sfield.fkind {
 		case reflect.Map:
-			unmarshalToMap(sec, sfield.ftype, sfield.fval)
+			err = in.unmarshalToMap(sec, sfield.ftype, sfield.fval)
 
 		case reflect.Ptr:
 			for sfield.fkind == reflect.Ptr {
@@ -61,7 +70,7 @@ func (in *Ini) unmarshal(tagField *tagStructField) {
 				} else {
 					sfield.fval = sfield.fval.Elem()
 				}
-				unmarshalToStruct(sec, sfield.ftype, sfield.fval)
+				err = in.unmarshalToStruct(sec, sfield.ftype, sfield.fval)
 			}
 
 		case reflect.Slice:
@@ -69,7 +78,7 @@ func (in *Ini) unmarshal(tagField *tagStructField) {
 			switch sliceElem.Kind() {
 			case reflect.Struct:
 				newStruct := reflect.New(sliceElem)
-				unmarshalToStruct(sec, sliceElem, newStruct.Elem())
+				err = in.unmarshalToStruct(sec, sliceElem, newStruct.Elem())
 				newSlice := reflect.Append(sfield.fval, newStruct.Elem())
 				sfield.fval.Set(newSlice)
 
@@ -81,17 +90,54 @@ func (in *Ini) unmarshal(tagField *tagStructField) {
 
 				if sliceElem.Kind() == reflect.Struct {
 					ptrfval := reflect.New(sliceElem)
-					unmarshalToStruct(sec, sliceElem, ptrfval.Elem())
+					err = in.unmarshalToStruct(sec, sliceElem, ptrfval.Elem())
 					newSlice := reflect.Append(sfield.fval, ptrfval)
 					sfield.fval.Set(newSlice)
 				}
 			}
 
 		case reflect.Struct:
-			unmarshalToStruct(sec, sfield.ftype, sfield.fval)
+			err = in.unmarshalToStruct(sec, sfield.ftype, sfield.fval)
+		}
+		if err != nil {
+			errs = append(errs, err)
 		}
+	}
 
+	for _, tag = range tagField.keys() {
+		sfield = tagField.v[tag]
+		if !sfield.isSection {
+			err = sfield.validate()
+			if err != nil {
+				errs = append(errs, in.fieldError(tag, sfield, err))
+			}
+			continue
+		}
+		if sfield.isSet || sfield.fkind != reflect.Struct || sfield.ftype == typeTime {
+			continue
+		}
+		// Apply the default value and check the constraint on
+		// struct whose section is not exist.
+		sec = newSection(sfield.sec, sfield.sub)
+		err = in.unmarshalToStruct(sec, sfield.ftype, sfield.fval)
+		if err != nil {
+			errs = append(errs, err)
+		}
 	}
+
+	return errors.Join(errs...)
+}
+
+// fieldError return an error on struct field for key path, with the
+// location of its value.
+func (in *Ini) fieldError(keyPath string, sfield *structField, err error) error {
+	if sfield.lineNum > 0 {
+		return fmt.Errorf(`%s: %w, %s`, keyPath, err, in.location(sfield.lineNum))
+	}
+	if len(in.filename) > 0 {
+		return fmt.Errorf(`%s: %w, at %s`, keyPath, err, in.filename)
+	}
+	return fmt.Errorf(`%s: %w`, keyPath, err)
 }
 
 // unmarshalToMap unmarshal the Section into a map.
@@ -105,9 +151,9 @@ func (in *Ini) unmarshal(tagField *tagStructField) {
 //	V map[S]T `ini:"section"`
 //
 // for map of struct.
-func unmarshalToMap(sec *Section, rtype reflect.Type, rval reflect.Value) bool {
+func (in *Ini) unmarshalToMap(sec *Section, rtype reflect.Type, rval reflect.Value) (err error) {
 	if rtype.Key().Kind() != reflect.String {
-		return false
+		return nil
 	}
 
 	var (
@@ -136,13 +182,13 @@ func unmarshalToMap(sec *Section, rtype reflect.Type, rval reflect.Value) bool {
 	if elKind == reflect.Struct {
 		astruct = reflect.New(elType)
 
-		unmarshalToStruct(sec, elType, astruct.Elem())
+		err = in.unmarshalToStruct(sec, elType, astruct.Elem())
 		if isPtr {
 			amap.SetMapIndex(reflect.ValueOf(sec.sub), astruct)
 		} else {
 			amap.SetMapIndex(reflect.ValueOf(sec.sub), astruct.Elem())
 		}
-		return true
+		return err
 	}
 
 	for _, v = range sec.vars {
@@ -156,15 +202,19 @@ func unmarshalToMap(sec *Section, rtype reflect.Type, rval reflect.Value) bool {
 		}
 	}
 	rval.Set(amap)
-	return true
+	return nil
 }
 
-func unmarshalToStruct(sec *Section, rtype reflect.Type, rval reflect.Value) {
+// unmarshalToStruct set the variables in section into the struct fields,
+// and then apply the default value and constraint of each field.
+func (in *Ini) unmarshalToStruct(sec *Section, rtype reflect.Type, rval reflect.Value) (err error) {
 	var (
 		tagField = unpackTagStructField(rtype, rval)
 
-		v      *variable
-		sfield *structField
+		v       *variable
+		sfield  *structField
+		errs    []error
+		keyPath string
 	)
 
 	for _, v = range sec.vars {
@@ -172,8 +222,24 @@ func unmarshalToStruct(sec *Section, rtype reflect.Type, rval reflect.Value) {
 		if sfield == nil {
 			continue
 		}
-		sfield.set(v.value)
+		err = sfield.setVar(v)
+		if err != nil {
+			keyPath = fmt.Sprintf(`%s:%s:%s`, sec.nameLower, sec.sub, sfield.key)
+			errs = append(errs, in.fieldError(keyPath, sfield, err))
+		}
+	}
+
+	var key string
+	for _, key = range tagField.keys() {
+		sfield = tagField.v[key]
+		err = sfield.validate()
+		if err != nil {
+			keyPath = fmt.Sprintf(`%s:%s:%s`, sec.nameLower, sec.sub, sfield.key)
+			errs = append(errs, in.fieldError(keyPath, sfield, err))
+		}
 	}
+
+	return errors.Join(errs...)
 }
 
 // unmarshalValue convert the value from string to primitive type based on its
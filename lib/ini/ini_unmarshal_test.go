@@ -0,0 +1,152 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ini
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+type testConstraintLog struct {
+	Level string   `ini:"::level:info" constraint:"enum=debug|info|error"`
+	Files []string `ini:"::file" constraint:"max=2"`
+}
+
+type testConstraint struct {
+	Log     testConstraintLog `ini:"log"`
+	Allow   *regexp.Regexp    `ini:"server::allow"`
+	Name    string            `ini:"server::name" constraint:"required,min=3"`
+	Listen  net.IP            `ini:"server::listen:0.0.0.0" constraint:"required"`
+	Proxy   url.URL           `ini:"server::proxy"`
+	Timeout time.Duration     `ini:"server::timeout:30s" constraint:"min=1s,max=1m"`
+	Port    int               `ini:"server::port:8080" constraint:"min=1,max=65535"`
+}
+
+func TestIni_Unmarshal_constraint(t *testing.T) {
+	var (
+		text = `[server]
+name = web
+allow = ^/api/
+proxy = http://127.0.0.1:3128
+
+[log]
+file = a.log
+`
+		got testConstraint
+		err error
+	)
+
+	err = Unmarshal([]byte(text), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `Name`, `web`, got.Name)
+	test.Assert(t, `Listen default`, `0.0.0.0`, got.Listen.String())
+	test.Assert(t, `Port default`, 8080, got.Port)
+	test.Assert(t, `Timeout default`, 30*time.Second, got.Timeout)
+	test.Assert(t, `Allow`, true, got.Allow.MatchString(`/api/v1`))
+	test.Assert(t, `Proxy`, `127.0.0.1:3128`, got.Proxy.Host)
+	test.Assert(t, `Log.Level default`, `info`, got.Log.Level)
+	test.Assert(t, `Log.Files`, []string{`a.log`}, got.Log.Files)
+}
+
+func TestIni_Unmarshal_lenient(t *testing.T) {
+	type lenient struct {
+		Listen  net.IP        `ini:"server::listen"`
+		Timeout time.Duration `ini:"server::timeout"`
+		Port    int           `ini:"server::port:8080"`
+	}
+
+	var (
+		text = `[server]
+listen = localhost
+timeout = soon
+port = http
+`
+		got lenient
+		err error
+	)
+
+	// Without constraint, the invalid value is ignored.
+	err = Unmarshal([]byte(text), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exp lenient
+	test.Assert(t, `Unmarshal`, exp, got)
+}
+
+func TestIni_Unmarshal_constraintError(t *testing.T) {
+	type testCase struct {
+		desc   string
+		text   string
+		expErr string
+	}
+
+	var cases = []testCase{{
+		desc:   `missing required`,
+		text:   "[server]\nport = 80\n",
+		expErr: `ini: Unmarshal: server::name: missing required value, at config.ini`,
+	}, {
+		desc:   `invalid value`,
+		text:   "[server]\nname = web\nport = http\n",
+		expErr: `ini: Unmarshal: server::port: invalid value "http", line 3 at config.ini`,
+	}, {
+		desc:   `less than min`,
+		text:   "[server]\nname = ab\n",
+		expErr: `ini: Unmarshal: server::name: length 2 is less than min 3, line 2 at config.ini`,
+	}, {
+		desc:   `greater than max`,
+		text:   "[server]\nname = web\ntimeout = 2m\n",
+		expErr: `ini: Unmarshal: server::timeout: value 2m0s is greater than max 1m, line 3 at config.ini`,
+	}, {
+		desc:   `not in enum`,
+		text:   "[server]\nname = web\n[log]\nlevel = trace\n",
+		expErr: `ini: Unmarshal: log::level: value "trace" is not one of debug|info|error, line 4 at config.ini`,
+	}, {
+		desc:   `too many values`,
+		text:   "[server]\nname = web\n[log]\nfile = a\nfile = b\nfile = c\n",
+		expErr: `ini: Unmarshal: log::file: length 3 is greater than max 2, line 6 at config.ini`,
+	}, {
+		desc: `multiple errors`,
+		text: "[server]\nport = 0\nlisten = localhost\n",
+		expErr: "ini: Unmarshal: server::listen: invalid value \"localhost\", line 3 at config.ini\n" +
+			"server::name: missing required value, at config.ini\n" +
+			"server::port: value 0 is less than min 1, line 2 at config.ini",
+	}}
+
+	var (
+		file = filepath.Join(t.TempDir(), `config.ini`)
+
+		c   testCase
+		cfg *Ini
+		err error
+	)
+	for _, c = range cases {
+		err = os.WriteFile(file, []byte(c.text), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err = Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Use the base name for predictable error message.
+		cfg.filename = `config.ini`
+
+		err = cfg.Unmarshal(&testConstraint{})
+		test.Assert(t, c.desc, c.expErr, err.Error())
+	}
+}
@@ -105,7 +105,9 @@ func (reader *reader) parseFile(filename string) (in *Ini, err error) {
 
 // Parse will parse INI config from slice of bytes `src` into `in`.
 func (reader *reader) Parse(src []byte) (in *Ini, err error) {
-	in = &Ini{}
+	in = &Ini{
+		filename: reader.filename,
+	}
 	reader.reset(src)
 
 	for {
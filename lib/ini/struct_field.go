@@ -6,10 +6,21 @@ package ini
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"reflect"
+	"regexp"
 	"time"
 )
 
+var (
+	typeDuration = reflect.TypeOf(time.Duration(0))
+	typeIP       = reflect.TypeOf(net.IP{})
+	typeRegexp   = reflect.TypeOf(regexp.Regexp{})
+	typeTime     = reflect.TypeOf(time.Time{})
+	typeURL      = reflect.TypeOf(url.URL{})
+)
+
 type structField struct {
 	ftype reflect.Type
 	fval  reflect.Value
@@ -20,10 +31,70 @@ type structField struct {
 	layout string
 	fname  string
 
+	// def is the default value from the fourth element in tag.
+	def string
+
+	// constraint is the value of "constraint" tag.
+	constraint string
+
+	// vals contains the values that has been set to field, used to
+	// check the "enum" constraint.
+	vals []string
+
 	fkind reflect.Kind
+
+	// lineNum is the line number of the last value that has been set
+	// to field.
+	lineNum int
+
+	// isInvalid is true if one of the value cannot be converted to
+	// the field type.
+	isInvalid bool
+
+	// isSection is true if the field tag does not have key, which
+	// means the field is mapped to section and/or subsection.
+	isSection bool
+
+	// isSet is true if the field has been set from INI or from default
+	// value.
+	isSet bool
+}
+
+// setVar set the field value from variable v.
+// If the field has constraint, it will return an error if the value
+// cannot be converted to the field type; otherwise the invalid value is
+// ignored.
+func (sfield *structField) setVar(v *variable) (err error) {
+	sfield.isSet = true
+	sfield.lineNum = v.lineNum
+	sfield.vals = append(sfield.vals, v.value)
+
+	if sfield.set(v.value) {
+		return nil
+	}
+	if sfield.isStrict() && isParsableType(sfield.ftype) {
+		sfield.isInvalid = true
+		return fmt.Errorf(`invalid value %q`, v.value)
+	}
+	return nil
+}
+
+// isStrict return true if the field has "constraint" tag, which means
+// the invalid value of field should be reported.
+func (sfield *structField) isStrict() bool {
+	return len(sfield.constraint) > 0
 }
 
 func (sfield *structField) set(val string) bool {
+	if sfield.ftype == typeIP {
+		var ip = net.ParseIP(val)
+		if ip == nil {
+			return false
+		}
+		sfield.fval.Set(reflect.ValueOf(ip))
+		return true
+	}
+
 	rval, ok := unmarshalValue(sfield.ftype, val)
 	if ok {
 		sfield.fval.Set(rval)
@@ -66,6 +137,24 @@ func (sfield *structField) set(val string) bool {
 			sfield.fval.Set(reflect.ValueOf(t))
 			return true
 		}
+
+		switch sfield.ftype {
+		case typeURL:
+			u, err := url.Parse(val)
+			if err != nil {
+				return false
+			}
+			sfield.fval.Set(reflect.ValueOf(*u))
+			return true
+
+		case typeRegexp:
+			re, err := regexp.Compile(val)
+			if err != nil {
+				return false
+			}
+			sfield.fval.Set(reflect.ValueOf(re).Elem())
+			return true
+		}
 	}
 	return false
 }
@@ -86,16 +175,18 @@ func (sfield *structField) append(val string) (slice reflect.Value, ok bool) {
 	}
 
 	switch ftype.Kind() {
-	case reflect.Struct:
-		vi := reflect.Zero(ftype).Interface()
-		_, ok = vi.(time.Time)
-		if ok {
-			t, err := time.Parse(sfield.layout, val)
-			if err != nil {
-				return slice, false
-			}
-			slice = reflect.Append(slice, reflect.ValueOf(t))
+	case reflect.Slice, reflect.Struct:
+		// Slice of net.IP, time.Time, url.URL, or regexp.Regexp.
+		sliceItem := &structField{
+			layout: sfield.layout,
+			fkind:  ftype.Kind(),
+			ftype:  ftype,
+			fval:   reflect.New(ftype).Elem(),
+		}
+		if !sliceItem.set(val) {
+			return slice, false
 		}
+		slice = reflect.Append(slice, sliceItem.fval)
 
 	case reflect.Ptr:
 		for ftype.Kind() == reflect.Ptr {
@@ -123,3 +214,24 @@ func (sfield *structField) append(val string) (slice reflect.Value, ok bool) {
 	}
 	return slice, true
 }
+
+// isParsableType return true if the value of rtype is parsed from string,
+// so an invalid value should be reported.
+func isParsableType(rtype reflect.Type) bool {
+	for rtype.Kind() == reflect.Ptr {
+		rtype = rtype.Elem()
+	}
+	switch rtype {
+	case typeIP, typeRegexp, typeTime, typeURL:
+		return true
+	}
+	switch rtype.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Array, reflect.Slice:
+		return isParsableType(rtype.Elem())
+	}
+	return false
+}
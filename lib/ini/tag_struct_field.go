@@ -86,12 +86,18 @@ func unpackTagStructField(rtype reflect.Type, rval reflect.Value) (out *tagStruc
 		sfield.sec = tags[0]
 		sfield.sub = tags[1]
 		sfield.key = strings.ToLower(tags[2])
+		sfield.def = tags[3]
+		sfield.isSection = len(sfield.key) == 0
+		sfield.constraint = field.Tag.Get(fieldTagConstraint)
 
 		if len(sfield.key) == 0 {
 			sfield.sec = tags[0]
 			sfield.key = sfield.fname
 		}
 
+		if len(sfield.def) > 0 {
+			tag = strings.TrimSuffix(tag, fieldTagSeparator+sfield.def)
+		}
 		out.v[tag] = sfield
 	}
 	return out
@@ -19,6 +19,11 @@ const (
 )
 
 // DirWatcher is a naive implementation of directory change notification.
+//
+// On Linux, the changes are received from inotify and the bursts of events
+// are coalesced before being processed.
+// If the inotify cannot be used, or the Polling field is set to true, the
+// changes are fetched from system every Delay.
 type DirWatcher struct {
 	// C channel on which the changes are delivered to user.
 	C <-chan NodeState
@@ -38,6 +43,10 @@ type DirWatcher struct {
 	// The root Node in fs.
 	root *Node
 
+	// notify is the file system notification backend.
+	// If its nil, the DirWatcher use polling.
+	notify *notifier
+
 	// dirs contains list of directory and their sub-directories that is
 	// being watched for changes.
 	// The map key is relative path to Root and its value is a Node
@@ -64,6 +73,9 @@ type DirWatcher struct {
 	// system.
 	// This field is optional, minimum is 100 milli second and default
 	// is 5 seconds.
+	//
+	// When using inotify, the Delay is used to check for the Root
+	// directory re-created after being deleted.
 	Delay time.Duration
 
 	// dirsLocker protect adding and removing key in [dirs].
@@ -71,6 +83,10 @@ type DirWatcher struct {
 
 	// mtxFileWatcher protect adding and removing key in [fileWatcher].
 	mtxFileWatcher sync.Mutex
+
+	// Polling force the DirWatcher to fetch the changes every Delay
+	// instead of using the file system notification.
+	Polling bool
 }
 
 // init validate and initialized all fields.
@@ -112,6 +128,20 @@ func (dw *DirWatcher) init() (err error) {
 	dw.dirs = make(map[string]*Node)
 	dw.fileWatcher = make(map[string]*Watcher)
 
+	if !dw.Polling {
+		dw.notify, err = newNotifier()
+		if err == nil {
+			err = dw.notify.watch(dw.root.SysPath, dw.root.Path)
+			if err != nil {
+				dw.notify.close()
+			}
+		}
+		if err != nil {
+			// Fallback to polling.
+			dw.notify = nil
+		}
+	}
+
 	dw.mapSubdirs(dw.root)
 
 	return nil
@@ -158,7 +188,8 @@ func (dw *DirWatcher) dirsKeys() (keys []string) {
 
 // mapSubdirs iterate each child node recursively and map directories into
 // [DirWatcher.dirs].
-// If its a regular file, start a new file [Watcher].
+// If its a regular file, start a new file [Watcher], or watch it using
+// notify if its a symbolic link.
 func (dw *DirWatcher) mapSubdirs(node *Node) {
 	var (
 		logp = `mapSubdirs`
@@ -172,10 +203,18 @@ func (dw *DirWatcher) mapSubdirs(node *Node) {
 			dw.dirsLocker.Lock()
 			dw.dirs[child.Path] = child
 			dw.dirsLocker.Unlock()
+			err = dw.watchNode(child)
+			if err != nil {
+				log.Printf(`%s: %s`, logp, err)
+			}
 			dw.mapSubdirs(child)
 			continue
 		}
-		err = dw.startWatchingFile(node, child)
+		if dw.notify != nil {
+			err = dw.watchNode(child)
+		} else {
+			err = dw.startWatchingFile(node, child)
+		}
 		if err != nil {
 			log.Printf(`%s: %s`, logp, err)
 		}
@@ -189,7 +228,14 @@ func (dw *DirWatcher) onCreated(parent, child *Node) (err error) {
 		dw.dirs[child.Path] = child
 		dw.dirsLocker.Unlock()
 	} else {
-		dw.startWatchingFile(parent, child)
+		dw.fs.indexNode(child)
+		dw.fs.fingerprintNode(child)
+		if dw.notify == nil {
+			dw.startWatchingFile(parent, child)
+		}
+	}
+	if dw.notify != nil {
+		err = dw.watchNode(child)
 	}
 
 	var ns = NodeState{
@@ -201,21 +247,39 @@ func (dw *DirWatcher) onCreated(parent, child *Node) (err error) {
 	case dw.qchanges <- ns:
 	default:
 	}
-	return nil
+	return err
 }
 
 // onDirDeleted remove the node from being watched and from memfs, including
 // its childs if its a directory.
 func (dw *DirWatcher) onDirDeleted(node *Node) {
-	var child *Node
+	var (
+		// Iterate on copy of childs, since removing the child
+		// modify the node Childs.
+		childs = make([]*Node, len(node.Childs))
+		child  *Node
+	)
 
-	for _, child = range node.Childs {
+	copy(childs, node.Childs)
+
+	for _, child = range childs {
 		if child.IsDir() {
 			dw.onDirDeleted(child)
+		} else if dw.notify != nil {
+			// Without file watcher, the file deletion is
+			// published here.
+			dw.onFileDeleted(child)
+		} else {
+			dw.fs.unindexNode(child)
+			dw.fs.unfingerprintNode(child)
 		}
 		dw.fs.RemoveChild(node, child)
 	}
 
+	if dw.notify != nil {
+		dw.notify.unwatch(node.Path)
+	}
+
 	dw.dirsLocker.Lock()
 	delete(dw.dirs, node.Path)
 	dw.dirsLocker.Unlock()
@@ -233,7 +297,18 @@ func (dw *DirWatcher) onDirDeleted(node *Node) {
 }
 
 func (dw *DirWatcher) onFileDeleted(node *Node) {
-	dw.stopWatchingFile(node)
+	dw.fs.unindexNode(node)
+	dw.fs.unfingerprintNode(node)
+
+	if dw.notify != nil {
+		dw.notify.unwatch(node.Path)
+		dw.fs.RemoveChild(node.Parent, node)
+		// The node may have been removed from its parent by
+		// onUpdateDir.
+		dw.fs.PathNodes.Delete(node.Path)
+	} else {
+		dw.stopWatchingFile(node)
+	}
 
 	var ns = NodeState{
 		State: FileStateDeleted,
@@ -319,10 +394,12 @@ func (dw *DirWatcher) onUpdateDir(node *Node) {
 	// The rest of the mapChild now contains the deleted nodes.
 	for _, child = range mapChild {
 		if child.IsDir() {
-			// Only process directory, files is processed by
-			// qFileChanges.
 			dw.onDirDeleted(child)
+		} else if dw.notify != nil {
+			dw.onFileDeleted(child)
 		}
+		// Without notify, the files is processed by
+		// qFileChanges.
 	}
 }
 
@@ -368,6 +445,9 @@ func (dw *DirWatcher) onRootDeleted() {
 	}
 
 	dw.stopAllFileWatcher()
+	if dw.notify != nil {
+		dw.notify.unwatchAll()
+	}
 
 	dw.fs = nil
 	dw.root = nil
@@ -405,6 +485,8 @@ func (dw *DirWatcher) onUpdateContent(node *Node, newInfo os.FileInfo) {
 		if err != nil {
 			log.Printf(`%s %q: %s`, logp, node.Path, err)
 		}
+		dw.fs.indexNode(node)
+		dw.fs.fingerprintNode(node)
 	}
 
 	var ns = NodeState{
@@ -444,6 +526,11 @@ func (dw *DirWatcher) onUpdateMode(node *Node, newInfo os.FileInfo) {
 }
 
 func (dw *DirWatcher) start() {
+	if dw.notify != nil {
+		dw.startNotify()
+		return
+	}
+
 	var (
 		logp   = `DirWatcher`
 		ticker = time.NewTicker(dw.Delay)
@@ -515,7 +602,7 @@ func (dw *DirWatcher) startWatchingFile(parent, child *Node) (err error) {
 		watcher *Watcher
 	)
 
-	watcher, err = newWatcher(parent, child, dw.Delay, dw.qFileChanges)
+	watcher, err = newWatcher(parent, child, dw.Delay, dw.qFileChanges, nil)
 	if err != nil {
 		return fmt.Errorf(`%s %q: %s`, logp, child.SysPath, err)
 	}
@@ -0,0 +1,181 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+// waitNodeStates wait for n events from DirWatcher and return them as list
+// of "State Path".
+func waitNodeStates(t *testing.T, dw *DirWatcher, n int) (got []string) {
+	var ns NodeState
+	for len(got) < n {
+		select {
+		case ns = <-dw.C:
+			got = append(got, fmt.Sprintf(`%s %s`, ns.State, ns.Node.Path))
+		case <-time.After(3 * time.Second):
+			t.Fatalf(`timeout waiting for events, got %v`, got)
+		}
+	}
+	return got
+}
+
+func TestDirWatcher_notify(t *testing.T) {
+	var (
+		rootDir = t.TempDir()
+		dw      = DirWatcher{
+			Options: Options{
+				Root: rootDir,
+			},
+			// Set the Delay longer than the test timeout, to
+			// make sure the changes are not fetched by polling.
+			Delay: time.Minute,
+		}
+
+		err error
+	)
+
+	err = dw.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(dw.Stop)
+
+	if dw.notify == nil {
+		t.Skip(`inotify is not available`)
+	}
+
+	// Create the nested directories with file at once.
+
+	var dirC = filepath.Join(rootDir, `a`, `b`, `c`)
+
+	err = os.MkdirAll(dirC, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dirC, `file`), []byte(`content`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exp = []string{
+		`FileStateCreated /a`,
+		`FileStateCreated /a/b`,
+		`FileStateCreated /a/b/c`,
+		`FileStateCreated /a/b/c/file`,
+	}
+	test.Assert(t, `recursive create`, exp, waitNodeStates(t, &dw, len(exp)))
+
+	// The bursts of writes should be coalesced into one event.
+
+	var (
+		file = filepath.Join(dirC, `file`)
+		x    int
+	)
+	for x = 0; x < 10; x++ {
+		err = os.WriteFile(file, []byte(fmt.Sprintf(`content %d`, x)), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exp = []string{
+		`FileStateUpdateContent /a/b/c/file`,
+	}
+	test.Assert(t, `coalesce writes`, exp, waitNodeStates(t, &dw, len(exp)))
+
+	select {
+	case ns := <-dw.C:
+		t.Fatalf(`expecting no more event, got %s %s`, ns.State, ns.Node.Path)
+	case <-time.After(3 * notifyDelay):
+	}
+
+	// Rename the directory.
+
+	err = os.Rename(filepath.Join(rootDir, `a`), filepath.Join(rootDir, `x`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp = []string{
+		`FileStateCreated /x`,
+		`FileStateCreated /x/b`,
+		`FileStateCreated /x/b/c`,
+		`FileStateCreated /x/b/c/file`,
+		`FileStateDeleted /a/b/c/file`,
+		`FileStateDeleted /a/b/c`,
+		`FileStateDeleted /a/b`,
+		`FileStateDeleted /a`,
+	}
+	test.Assert(t, `rename directory`, exp, waitNodeStates(t, &dw, len(exp)))
+	test.Assert(t, `dirs`, []string{`/x`, `/x/b`, `/x/b/c`}, dw.dirsKeys())
+
+	// The renamed directory should still be watched.
+
+	err = os.Chmod(filepath.Join(rootDir, `x`, `b`, `c`, `file`), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp = []string{
+		`FileStateUpdateMode /x/b/c/file`,
+	}
+	test.Assert(t, `chmod on renamed directory`, exp, waitNodeStates(t, &dw, len(exp)))
+
+	// Remove the file.
+
+	err = os.Remove(filepath.Join(rootDir, `x`, `b`, `c`, `file`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp = []string{
+		`FileStateDeleted /x/b/c/file`,
+	}
+	test.Assert(t, `remove file`, exp, waitNodeStates(t, &dw, len(exp)))
+}
+
+func TestDirWatcher_polling(t *testing.T) {
+	var (
+		rootDir = t.TempDir()
+		dw      = DirWatcher{
+			Options: Options{
+				Root: rootDir,
+			},
+			Delay:   100 * time.Millisecond,
+			Polling: true,
+		}
+
+		err error
+	)
+
+	err = dw.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(dw.Stop)
+
+	test.Assert(t, `notify`, true, dw.notify == nil)
+
+	// Add delay for modtime to changes.
+	time.Sleep(100 * time.Millisecond)
+
+	err = os.Mkdir(filepath.Join(rootDir, `sub`), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exp = []string{
+		`FileStateCreated /sub`,
+	}
+	test.Assert(t, `mkdir`, exp, waitNodeStates(t, &dw, len(exp)))
+}
@@ -0,0 +1,252 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// startNotify wait for the events from notify, collect them for
+// notifyDelay, and process them at once.
+//
+// The Root directory cannot be watched after its deleted, so its existence
+// is checked every Delay until its re-created.
+func (dw *DirWatcher) startNotify() {
+	var (
+		logp   = `DirWatcher`
+		events = dw.notify.C
+		ticker = time.NewTicker(dw.Delay)
+		timer  = time.NewTimer(notifyDelay)
+		batch  = newNotifyBatch()
+
+		timerC <-chan time.Time
+		ev     notifyEvent
+		ok     bool
+	)
+
+	timer.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if dw.fs == nil {
+				dw.checkRoot()
+			}
+
+		case ev, ok = <-events:
+			if !ok {
+				log.Printf(`%s: notification stopped`, logp)
+				events = nil
+				continue
+			}
+			batch.add(ev)
+			if timerC == nil {
+				timer.Reset(notifyDelay)
+				timerC = timer.C
+			}
+
+		case <-timerC:
+			timerC = nil
+			dw.processBatch(batch)
+			batch = newNotifyBatch()
+
+		case <-dw.qrun:
+			ticker.Stop()
+			timer.Stop()
+			dw.notify.close()
+			// Signal back to the Stop caller.
+			dw.qrun <- struct{}{}
+			return
+		}
+	}
+}
+
+// processBatch process the collected events.
+// The directories where its childs changes are processed first, followed
+// by checking each changed node.
+func (dw *DirWatcher) processBatch(batch *notifyBatch) {
+	if dw.fs == nil {
+		// The Root has been deleted.
+		return
+	}
+	if batch.overflow {
+		dw.rescan()
+		return
+	}
+
+	var (
+		node *Node
+		key  string
+	)
+
+	for _, key = range sortedKeys(batch.updates) {
+		node = dw.dirNode(key)
+		if node != nil {
+			dw.onUpdateDir(node)
+		}
+	}
+	for _, key = range sortedKeys(batch.checks) {
+		dw.checkNode(key)
+		if dw.fs == nil {
+			return
+		}
+	}
+}
+
+// checkRoot check the Root directory for deletion, re-creation, or
+// mode changes.
+func (dw *DirWatcher) checkRoot() {
+	var fi, err = os.Stat(dw.Root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if dw.fs != nil {
+				dw.onRootDeleted()
+			}
+		} else {
+			log.Printf(`checkRoot: %s`, err)
+		}
+		return
+	}
+	if dw.fs == nil {
+		dw.onRootCreated()
+		dw.onUpdateDir(dw.root)
+		return
+	}
+	if dw.root.Mode() != fi.Mode() {
+		dw.onUpdateMode(dw.root, fi)
+	}
+}
+
+// checkNode check the node in nodePath for deletion, mode changes, or
+// content changes.
+func (dw *DirWatcher) checkNode(nodePath string) {
+	var (
+		logp = `checkNode`
+
+		node *Node
+		fi   os.FileInfo
+		err  error
+	)
+
+	if nodePath == dw.root.Path {
+		dw.checkRoot()
+		return
+	}
+
+	node = dw.dirNode(nodePath)
+	if node != nil {
+		fi, err = os.Stat(node.SysPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				dw.onDirDeleted(node)
+			} else {
+				log.Printf(`%s: %q: %s`, logp, node.SysPath, err)
+			}
+			return
+		}
+		if node.Mode() != fi.Mode() {
+			dw.onUpdateMode(node, fi)
+		}
+		return
+	}
+
+	node, err = dw.fs.Get(nodePath)
+	if err != nil {
+		// The node is excluded or has been deleted.
+		return
+	}
+	if node.IsDir() {
+		return
+	}
+
+	fi, err = os.Stat(node.SysPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			dw.onFileDeleted(node)
+		} else {
+			log.Printf(`%s: %q: %s`, logp, node.SysPath, err)
+		}
+		return
+	}
+	if node.Mode() != fi.Mode() {
+		dw.onUpdateMode(node, fi)
+	}
+	if !node.ModTime().Equal(fi.ModTime()) {
+		dw.onUpdateContent(node, fi)
+	}
+}
+
+// dirNode return the watched directory by its path.
+func (dw *DirWatcher) dirNode(nodePath string) (node *Node) {
+	if nodePath == dw.root.Path {
+		return dw.root
+	}
+	dw.dirsLocker.Lock()
+	node = dw.dirs[nodePath]
+	dw.dirsLocker.Unlock()
+	return node
+}
+
+// rescan check all directories and files, in case some events are lost.
+func (dw *DirWatcher) rescan() {
+	var (
+		dirs = []*Node{dw.root}
+
+		files []*Node
+		node  *Node
+		child *Node
+	)
+
+	dw.dirsLocker.Lock()
+	for _, node = range dw.dirs {
+		dirs = append(dirs, node)
+	}
+	dw.dirsLocker.Unlock()
+
+	for _, node = range dirs {
+		dw.checkNode(node.Path)
+		if dw.fs == nil {
+			return
+		}
+		if dw.dirNode(node.Path) == nil {
+			// The directory has been deleted.
+			continue
+		}
+		dw.onUpdateDir(node)
+
+		// Collect the files first, since checking the file may
+		// modify the node Childs.
+		files = files[:0]
+		for _, child = range node.Childs {
+			if !child.IsDir() {
+				files = append(files, child)
+			}
+		}
+		for _, child = range files {
+			dw.checkNode(child.Path)
+		}
+	}
+}
+
+// watchNode watch the directory node using notify.
+// The regular file is not watched, since its changes are reported on the
+// directory, but the symbolic link to file is watched directly because
+// changes on the target file is not reported.
+func (dw *DirWatcher) watchNode(node *Node) (err error) {
+	if dw.notify == nil {
+		return nil
+	}
+	if !node.IsDir() {
+		var fi os.FileInfo
+
+		fi, err = os.Lstat(node.SysPath)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+	}
+	return dw.notify.watch(node.SysPath, node.Path)
+}
@@ -49,6 +49,70 @@
 //	}
 //	// Do something with content of file system.
 //
+// # io/fs
+//
+// The MemFS implement [fs.FS], [fs.ReadDirFS], [fs.ReadFileFS],
+// [fs.StatFS], and [fs.SubFS], so it can be used with [fs.WalkDir],
+// "html/template.ParseFS", or [http.FS].
+// Unlike Get, the path passed to the fs.FS methods must be unrooted,
+// for example "dir/file.txt".
+// Each call to Open return new file handle with its own offset.
+//
+// The MemFS can also be created from any fs.FS, for example [embed.FS],
+// using [NewFromFS].
+//
+// # Search
+//
+// By default, Search scan the content of all text files for each query.
+// To search using inverted index, set the Options.Search,
+//
+//	opts.Search = &memfs.SearchOptions{}
+//
+// The index is created on Init and Remount, and kept up to date by the
+// DirWatcher returned from Watch.
+// The results is ranked using BM25, and each element of query that contains
+// more than one word is searched as phrase.
+// The matched words in the snippets can be highlighted using
+// [SearchResult.Highlight].
+//
+// The index can be embedded into the generated Go code by setting
+// EmbedOptions.WithSearchIndex.
+//
+// # Writable
+//
+// The MemFS can be modified in memory using Mkdir, MkdirAll, WriteFile,
+// Remove, RemoveAll, and Rename.
+// A zero MemFS can be used to build the tree of files entirely in memory,
+//
+//	mfs := &memfs.MemFS{}
+//	mfs.MkdirAll(`/a/b`, 0750)
+//	mfs.WriteFile(`/a/b/c.txt`, []byte(`hello`), 0600)
+//
+// To modify the files without changing the original one, create an overlay
+// using [NewOverlay].
+// The overlay share the content with the base until the file is written.
+//
+// The changes are kept in memory until Flush is called, which write them
+// back into Options.Root.
+//
+// # Fingerprint
+//
+// Each file whose content is mapped in memory has the SHA-256 hash of its
+// content, computed on Init, including the files from GoEmbed.
+// The hash is used as the strong ETag by the lib/http Server and exposed
+// as Subresource Integrity using [Node.Integrity].
+//
+// If the Options.Fingerprint is set, each of those files can be accessed
+// using the fingerprinted path, for example "/app.js" as
+// "/app.3f9a2c1b.js".
+// The fingerprinted path can be resolved using [MemFS.Fingerprint] or
+// [MemFS.Manifest], for example by registering the Fingerprint as function
+// in html/template,
+//
+//	tmpl.Funcs(template.FuncMap{
+//		"fingerprint": mfs.Fingerprint,
+//	})
+//
 // # Go embed
 //
 // The memfs package also support embedding the files into Go generated source
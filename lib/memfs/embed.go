@@ -23,6 +23,10 @@ type generateData struct {
 	Opts     *Options
 	Node     *Node
 	PathNode *PathNode
+
+	// SearchIndex contains the serialized search index, if
+	// EmbedOptions.WithSearchIndex is true.
+	SearchIndex []byte
 }
 
 // GoEmbed write the tree nodes as Go generated source file.
@@ -52,6 +56,12 @@ func (mfs *MemFS) GoEmbed() (err error) {
 		Opts:     mfs.Opts,
 		PathNode: mfs.PathNodes,
 	}
+	if mfs.Opts.Embed.WithSearchIndex && mfs.Opts.Search != nil {
+		genData.SearchIndex, err = mfs.MarshalSearchIndex()
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+	}
 
 	tmpl, err := generateTemplate()
 	if err != nil {
@@ -34,4 +34,9 @@ type EmbedOptions struct {
 	// files and directories are not stored inside generated code, instead
 	// all files will use the current time when the program is running.
 	WithoutModTime bool
+
+	// WithSearchIndex if its true and the Options Search is set, the
+	// search index is serialized inside generated code, so the files
+	// does not need to be re-indexed on init.
+	WithSearchIndex bool
 }
@@ -4,7 +4,16 @@
 
 package memfs
 
-import "testing"
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
 
 func TestMemFS_GoEmbed(t *testing.T) {
 	opts := &Options{
@@ -56,3 +65,49 @@ func TestMemFS_GoEmbed_DisableModTime(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestMemFS_GoEmbed_WithSearchIndex(t *testing.T) {
+	var (
+		goFile = filepath.Join(t.TempDir(), `embed_test.go`)
+		opts   = &Options{
+			Root: `testdata`,
+			Excludes: []string{
+				`^\..*`,
+				`.*/node_save$`,
+			},
+			Embed: EmbedOptions{
+				PackageName:     `embed`,
+				GoFileName:      goFile,
+				WithSearchIndex: true,
+			},
+			Search: &SearchOptions{},
+		}
+	)
+
+	mfs, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mfs.GoEmbed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var src []byte
+
+	src, err = os.ReadFile(goFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = parser.ParseFile(token.NewFileSet(), goFile, src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `has Search`, true,
+		bytes.Contains(src, []byte(`Search: &memfs.SearchOptions{},`)))
+	test.Assert(t, `has UnmarshalSearchIndex`, true,
+		bytes.Contains(src, []byte(`memFS.UnmarshalSearchIndex([]byte("`)))
+}
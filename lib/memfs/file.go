@@ -0,0 +1,223 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// file is the handle of opened Node, returned by [MemFS.Open].
+//
+// Each file has its own offset, so the same Node can be read by multiple
+// readers concurrently.
+// The file implement [fs.File], [fs.ReadDirFile], [io.Seeker], and
+// [io.ReaderAt].
+type file struct {
+	node *Node
+
+	// content contains the snapshot of Node content when the file is
+	// opened.
+	content []byte
+
+	// src is the file in the storage, opened only if the content of
+	// Node is not mapped to memory.
+	src fs.File
+
+	// childs contains the sorted childs of directory, to be read by
+	// ReadDir.
+	childs []*Node
+
+	off    int64
+	dirOff int
+
+	// ondisk is true if the content is not mapped to memory.
+	ondisk bool
+	closed bool
+}
+
+func newFile(node *Node) (f *file) {
+	f = &file{
+		node:    node,
+		content: node.Content,
+	}
+	if node.IsDir() {
+		f.childs = make([]*Node, len(node.Childs))
+		copy(f.childs, node.Childs)
+		sort.Slice(f.childs, func(x, y int) bool {
+			return f.childs[x].name < f.childs[y].name
+		})
+		return f
+	}
+	f.ondisk = f.content == nil && node.size > 0
+	return f
+}
+
+// Close the file.
+// Calling Close more than once will return [fs.ErrClosed].
+func (f *file) Close() (err error) {
+	if f.closed {
+		return f.pathError(`close`, fs.ErrClosed)
+	}
+	f.closed = true
+	if f.src != nil {
+		err = f.src.Close()
+		f.src = nil
+	}
+	return err
+}
+
+// Read the content of file into p.
+func (f *file) Read(p []byte) (n int, err error) {
+	if f.closed {
+		return 0, f.pathError(`read`, fs.ErrClosed)
+	}
+	if f.node.IsDir() {
+		return 0, f.pathError(`read`, errIsDir)
+	}
+	if f.ondisk {
+		var src fs.File
+		src, err = f.source()
+		if err != nil {
+			return 0, err
+		}
+		return src.Read(p)
+	}
+	if f.off >= int64(len(f.content)) {
+		return 0, io.EOF
+	}
+	n = copy(p, f.content[f.off:])
+	f.off += int64(n)
+	return n, nil
+}
+
+// ReadAt read len(p) bytes from content of file start at offset off.
+func (f *file) ReadAt(p []byte, off int64) (n int, err error) {
+	if f.closed {
+		return 0, f.pathError(`read`, fs.ErrClosed)
+	}
+	if f.node.IsDir() {
+		return 0, f.pathError(`read`, errIsDir)
+	}
+	if off < 0 {
+		return 0, f.pathError(`read`, fs.ErrInvalid)
+	}
+	if f.ondisk {
+		var src fs.File
+		src, err = f.source()
+		if err != nil {
+			return 0, err
+		}
+		var ra, ok = src.(io.ReaderAt)
+		if !ok {
+			return 0, f.pathError(`read`, errNotSupported)
+		}
+		return ra.ReadAt(p, off)
+	}
+	if off >= int64(len(f.content)) {
+		return 0, io.EOF
+	}
+	n = copy(p, f.content[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// ReadDir read the contents of the directory and returns a slice of up to
+// n DirEntry values in directory order, as defined in
+// [fs.ReadDirFile].
+func (f *file) ReadDir(n int) (list []fs.DirEntry, err error) {
+	if f.closed {
+		return nil, f.pathError(`readdir`, fs.ErrClosed)
+	}
+	if !f.node.IsDir() {
+		return nil, f.pathError(`readdir`, errNotDir)
+	}
+
+	var rest = f.childs[f.dirOff:]
+	if n > 0 {
+		if len(rest) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(rest) {
+			rest = rest[:n]
+		}
+	}
+
+	list = make([]fs.DirEntry, 0, len(rest))
+	for _, child := range rest {
+		list = append(list, child)
+	}
+	f.dirOff += len(rest)
+
+	return list, nil
+}
+
+// Seek set the offset for the next Read.
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, f.pathError(`seek`, fs.ErrClosed)
+	}
+	if f.ondisk {
+		var src, err = f.source()
+		if err != nil {
+			return 0, err
+		}
+		var seeker, ok = src.(io.Seeker)
+		if !ok {
+			return 0, f.pathError(`seek`, errNotSupported)
+		}
+		return seeker.Seek(offset, whence)
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += int64(len(f.content))
+	default:
+		return 0, f.pathError(`seek`, errWhence)
+	}
+	if offset < 0 {
+		return 0, f.pathError(`seek`, errOffset)
+	}
+	f.off = offset
+	return f.off, nil
+}
+
+// Stat return the Node as file information.
+func (f *file) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, f.pathError(`stat`, fs.ErrClosed)
+	}
+	return f.node, nil
+}
+
+func (f *file) pathError(op string, err error) error {
+	return &fs.PathError{Op: op, Path: f.node.Path, Err: err}
+}
+
+// source open the file in the storage, the file system where the node
+// is created or the file in system path.
+func (f *file) source() (src fs.File, err error) {
+	if f.src != nil {
+		return f.src, nil
+	}
+	if f.node.fsys != nil {
+		src, err = f.node.fsys.Open(f.node.SysPath)
+	} else {
+		src, err = os.Open(f.node.SysPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, f.node.Path, err)
+	}
+	f.src = src
+	return src, nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"encoding/hex"
+	"path"
+)
+
+// fingerprintLen define the number of bytes from hash that is used in the
+// fingerprinted name.
+const fingerprintLen = 4
+
+// fingerprintName return the node path with the hash inserted before the
+// file extension, for example "/js/app.js" become "/js/app.3f9a2c1b.js".
+func fingerprintName(nodePath string, hash []byte) string {
+	var (
+		ext  = path.Ext(nodePath)
+		base = nodePath[:len(nodePath)-len(ext)]
+	)
+	return base + `.` + hex.EncodeToString(hash[:fingerprintLen]) + ext
+}
+
+// Fingerprint return the fingerprinted path of file with the Node path
+// name, to be used as reference in HTML or templates.
+// If the Options.Fingerprint is not set or the file content is not mapped
+// in memory, it will return the name as is.
+func (mfs *MemFS) Fingerprint(name string) string {
+	if mfs == nil {
+		return name
+	}
+
+	mfs.mtxFingerprint.RLock()
+	var alias, ok = mfs.manifest[name]
+	mfs.mtxFingerprint.RUnlock()
+	if ok {
+		return alias
+	}
+
+	var sub *MemFS
+	for _, sub = range mfs.subfs {
+		alias = sub.Fingerprint(name)
+		if alias != name {
+			return alias
+		}
+	}
+	return name
+}
+
+// IsFingerprint return true if the name is fingerprinted path of file.
+// The content of fingerprinted path never changes, so it can be cached
+// forever by the client.
+func (mfs *MemFS) IsFingerprint(name string) bool {
+	if mfs == nil {
+		return false
+	}
+
+	var _, ok = mfs.fingerprintPath(name)
+	if ok {
+		return true
+	}
+
+	var sub *MemFS
+	for _, sub = range mfs.subfs {
+		if sub.IsFingerprint(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Manifest return the map of Node path to its fingerprinted path, for all
+// files in memory.
+// It return nil if the Options.Fingerprint is not set.
+func (mfs *MemFS) Manifest() (manifest map[string]string) {
+	mfs.mtxFingerprint.RLock()
+	defer mfs.mtxFingerprint.RUnlock()
+
+	if mfs.manifest == nil {
+		return nil
+	}
+
+	manifest = make(map[string]string, len(mfs.manifest))
+
+	var name, alias string
+	for name, alias = range mfs.manifest {
+		manifest[name] = alias
+	}
+	return manifest
+}
+
+// initFingerprint compute the hash of all nodes whose content has not been
+// hashed, for example the nodes from GoEmbed, and create the fingerprinted
+// paths if the Opts.Fingerprint is set.
+func (mfs *MemFS) initFingerprint() {
+	if mfs.PathNodes == nil {
+		return
+	}
+
+	var node *Node
+	for _, node = range mfs.PathNodes.Nodes() {
+		if len(node.hash) == 0 {
+			node.updateHash()
+		}
+	}
+
+	if mfs.Opts == nil || !mfs.Opts.Fingerprint {
+		return
+	}
+
+	mfs.mtxFingerprint.Lock()
+	mfs.fingerprints = make(map[string]string)
+	mfs.manifest = make(map[string]string)
+	mfs.mtxFingerprint.Unlock()
+
+	for _, node = range mfs.PathNodes.Nodes() {
+		mfs.fingerprintNode(node)
+	}
+}
+
+// fingerprintNode add or update the fingerprinted path of node.
+func (mfs *MemFS) fingerprintNode(node *Node) {
+	if mfs.Opts == nil || !mfs.Opts.Fingerprint || node == nil {
+		return
+	}
+	if node.IsDir() || len(node.hash) == 0 {
+		mfs.unfingerprintNode(node)
+		return
+	}
+
+	var alias = fingerprintName(node.Path, node.hash)
+
+	mfs.mtxFingerprint.RLock()
+	var curr, ok = mfs.manifest[node.Path]
+	mfs.mtxFingerprint.RUnlock()
+	if ok && curr == alias {
+		return
+	}
+
+	mfs.mtxFingerprint.Lock()
+	if mfs.manifest == nil {
+		mfs.fingerprints = make(map[string]string)
+		mfs.manifest = make(map[string]string)
+	}
+	delete(mfs.fingerprints, curr)
+	mfs.fingerprints[alias] = node.Path
+	mfs.manifest[node.Path] = alias
+	mfs.mtxFingerprint.Unlock()
+}
+
+// unfingerprintNode remove the fingerprinted path of node.
+func (mfs *MemFS) unfingerprintNode(node *Node) {
+	if mfs.Opts == nil || !mfs.Opts.Fingerprint || node == nil {
+		return
+	}
+
+	mfs.mtxFingerprint.Lock()
+	var alias, ok = mfs.manifest[node.Path]
+	if ok {
+		delete(mfs.fingerprints, alias)
+		delete(mfs.manifest, node.Path)
+	}
+	mfs.mtxFingerprint.Unlock()
+}
+
+// fingerprintPath return the Node path of fingerprinted name.
+// The name is valid only if its equal to the fingerprinted path of the
+// Node current content, so the stale name, for example after the Node
+// content changes, never resolved to the new content.
+func (mfs *MemFS) fingerprintPath(name string) (nodePath string, ok bool) {
+	mfs.mtxFingerprint.RLock()
+	nodePath, ok = mfs.fingerprints[name]
+	mfs.mtxFingerprint.RUnlock()
+	if !ok || mfs.PathNodes == nil {
+		return ``, false
+	}
+
+	var node = mfs.PathNodes.Get(nodePath)
+	if node == nil || node.Fingerprint() != name {
+		return ``, false
+	}
+	return nodePath, true
+}
@@ -0,0 +1,143 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+// sha256Sum return the SHA-256 of content.
+func sha256Sum(content string) []byte {
+	var sum = sha256.Sum256([]byte(content))
+	return sum[:]
+}
+
+func TestMemFS_Fingerprint(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		err error
+	)
+
+	err = os.MkdirAll(filepath.Join(dir, `js`), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, `js`, `app.js`), []byte(`app`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, `LICENSE`), []byte(`BSD`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mfs *MemFS
+
+	mfs, err = New(&Options{
+		Root:        dir,
+		Fingerprint: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var node = mfs.MustGet(`/js/app.js`)
+
+	// The expected values is from "printf app | sha256sum".
+	test.Assert(t, `ETag`,
+		`"a172cedcae47474b615c54d510a5d84a8dea3032e958587430b413538be3f333"`,
+		node.ETag())
+	test.Assert(t, `Integrity`,
+		`sha256-oXLO3K5HR0thXFTVEKXYSo3qMDLpWFh0MLQTU4vj8zM=`,
+		node.Integrity())
+	test.Assert(t, `ETag on directory`, ``, mfs.MustGet(`/js`).ETag())
+
+	var expManifest = map[string]string{
+		`/LICENSE`:   `/LICENSE.49d9777d`,
+		`/js/app.js`: `/js/app.a172cedc.js`,
+	}
+	test.Assert(t, `Manifest`, expManifest, mfs.Manifest())
+
+	type testCase struct {
+		desc    string
+		name    string
+		expName string
+		expPath string
+		expIs   bool
+	}
+
+	var cases = []testCase{{
+		desc:    `file`,
+		name:    `/js/app.js`,
+		expName: `/js/app.a172cedc.js`,
+		expPath: `/js/app.js`,
+	}, {
+		desc:    `fingerprinted`,
+		name:    `/js/app.a172cedc.js`,
+		expName: `/js/app.a172cedc.js`,
+		expPath: `/js/app.js`,
+		expIs:   true,
+	}, {
+		desc:    `directory`,
+		name:    `/js`,
+		expName: `/js`,
+		expPath: `/js`,
+	}}
+
+	var c testCase
+	for _, c = range cases {
+		test.Assert(t, c.desc+`: Fingerprint`, c.expName, mfs.Fingerprint(c.name))
+		test.Assert(t, c.desc+`: IsFingerprint`, c.expIs, mfs.IsFingerprint(c.name))
+
+		node, err = mfs.Get(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Assert(t, c.desc+`: Get`, c.expPath, node.Path)
+	}
+
+	// The fingerprinted path is not listed.
+	test.Assert(t, `ListNames`,
+		[]string{`/`, `/LICENSE`, `/js`, `/js/app.js`},
+		mfs.ListNames())
+
+	// The fingerprinted path is stale once the Node content changes,
+	// even if the fingerprint has not been updated.
+
+	err = os.WriteFile(filepath.Join(dir, `LICENSE`), []byte(`GPL-3`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mfs.MustGet(`/LICENSE`).Update(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `IsFingerprint on stale path`, false,
+		mfs.IsFingerprint(`/LICENSE.49d9777d`))
+	_, err = mfs.Get(`/LICENSE.49d9777d`)
+	test.Assert(t, `Get on stale path`, true, err != nil)
+
+	// Changing the content changes the fingerprinted path.
+
+	_, err = mfs.WriteFile(`/js/app.js`, []byte(`new app`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var alias = mfs.Fingerprint(`/js/app.js`)
+	test.Assert(t, `IsFingerprint on old path`, false,
+		mfs.IsFingerprint(`/js/app.a172cedc.js`))
+	test.Assert(t, `IsFingerprint on new path`, true, mfs.IsFingerprint(alias))
+
+	err = mfs.Remove(`/js/app.js`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `IsFingerprint after removed`, false, mfs.IsFingerprint(alias))
+}
@@ -0,0 +1,226 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// toNodePath convert the name in [fs.FS] format, unrooted slash-separated
+// path, into Node Path.
+func toNodePath(op, name string) (nodePath string, err error) {
+	if !fs.ValidPath(name) {
+		return ``, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == `.` {
+		return `/`, nil
+	}
+	return `/` + name, nil
+}
+
+// getNode get the Node by its name in [fs.FS] format.
+// Any error is returned as [*fs.PathError].
+func (mfs *MemFS) getNode(op, name string) (node *Node, err error) {
+	var nodePath string
+
+	nodePath, err = toNodePath(op, name)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err = mfs.Get(nodePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = fs.ErrNotExist
+		}
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return node, nil
+}
+
+// Open the named file for reading, as defined in [fs.FS].
+// The name must be unrooted, slash-separated path, for example
+// "dir/file.txt" not "/dir/file.txt"; use "." to open the root directory.
+//
+// Each call to Open return new file handle with its own offset, so the
+// same file can be read by multiple readers concurrently.
+// The returned file also implement [fs.ReadDirFile], [io.Seeker], and
+// [io.ReaderAt].
+//
+// To use MemFS as [http.FileSystem], wrap it with [http.FS].
+func (mfs *MemFS) Open(name string) (fs.File, error) {
+	var node, err = mfs.getNode(`open`, name)
+	if err != nil {
+		return nil, err
+	}
+	return newFile(node), nil
+}
+
+// ReadDir read the named directory and return list of directory entries
+// sorted by file name, as defined in [fs.ReadDirFS].
+func (mfs *MemFS) ReadDir(name string) (list []fs.DirEntry, err error) {
+	var node *Node
+
+	node, err = mfs.getNode(`readdir`, name)
+	if err != nil {
+		return nil, err
+	}
+	if !node.IsDir() {
+		return nil, &fs.PathError{Op: `readdir`, Path: name, Err: errNotDir}
+	}
+
+	list = make([]fs.DirEntry, 0, len(node.Childs))
+	for _, child := range node.Childs {
+		list = append(list, child)
+	}
+	sort.Slice(list, func(x, y int) bool {
+		return list[x].Name() < list[y].Name()
+	})
+	return list, nil
+}
+
+// ReadFile read the named file and return its content, as defined in
+// [fs.ReadFileFS].
+// The returned content is a copy, the caller can modify it.
+func (mfs *MemFS) ReadFile(name string) (content []byte, err error) {
+	var node *Node
+
+	node, err = mfs.getNode(`read`, name)
+	if err != nil {
+		return nil, err
+	}
+	if node.IsDir() {
+		return nil, &fs.PathError{Op: `read`, Path: name, Err: errIsDir}
+	}
+
+	var f = newFile(node)
+	if !f.ondisk {
+		content = make([]byte, len(f.content))
+		copy(content, f.content)
+		return content, nil
+	}
+
+	content, err = io.ReadAll(f)
+	errClose := f.Close()
+	if err != nil {
+		return nil, &fs.PathError{Op: `read`, Path: name, Err: err}
+	}
+	if errClose != nil {
+		return nil, &fs.PathError{Op: `read`, Path: name, Err: errClose}
+	}
+	return content, nil
+}
+
+// Stat return the file information of the named file, as defined in
+// [fs.StatFS].
+func (mfs *MemFS) Stat(name string) (fs.FileInfo, error) {
+	var node, err = mfs.getNode(`stat`, name)
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// Sub return the file system rooted at dir, as defined in [fs.SubFS].
+// The returned file system share the same nodes with mfs.
+func (mfs *MemFS) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: `sub`, Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == `.` {
+		return mfs, nil
+	}
+	return &subFS{mfs: mfs, dir: dir}, nil
+}
+
+// subFS is the file system rooted at sub directory of MemFS.
+type subFS struct {
+	mfs *MemFS
+	dir string
+}
+
+// fullName return the name relative to the MemFS root.
+func (sub *subFS) fullName(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return ``, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(sub.dir, name), nil
+}
+
+// fixErr replace the path in the error with the name relative to sub
+// directory.
+func (sub *subFS) fixErr(err error, name string) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		pathErr.Path = name
+	}
+	return err
+}
+
+func (sub *subFS) Open(name string) (fs.File, error) {
+	var full, err = sub.fullName(`open`, name)
+	if err != nil {
+		return nil, err
+	}
+	var f fs.File
+	f, err = sub.mfs.Open(full)
+	if err != nil {
+		return nil, sub.fixErr(err, name)
+	}
+	return f, nil
+}
+
+func (sub *subFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	var full, err = sub.fullName(`readdir`, name)
+	if err != nil {
+		return nil, err
+	}
+	var list []fs.DirEntry
+	list, err = sub.mfs.ReadDir(full)
+	if err != nil {
+		return nil, sub.fixErr(err, name)
+	}
+	return list, nil
+}
+
+func (sub *subFS) ReadFile(name string) ([]byte, error) {
+	var full, err = sub.fullName(`read`, name)
+	if err != nil {
+		return nil, err
+	}
+	var content []byte
+	content, err = sub.mfs.ReadFile(full)
+	if err != nil {
+		return nil, sub.fixErr(err, name)
+	}
+	return content, nil
+}
+
+func (sub *subFS) Stat(name string) (fs.FileInfo, error) {
+	var full, err = sub.fullName(`stat`, name)
+	if err != nil {
+		return nil, err
+	}
+	var fi fs.FileInfo
+	fi, err = sub.mfs.Stat(full)
+	if err != nil {
+		return nil, sub.fixErr(err, name)
+	}
+	return fi, nil
+}
+
+func (sub *subFS) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: `sub`, Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == `.` {
+		return sub, nil
+	}
+	return &subFS{mfs: sub.mfs, dir: path.Join(sub.dir, dir)}, nil
+}
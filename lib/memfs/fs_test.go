@@ -0,0 +1,187 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestMemFS_fstest(t *testing.T) {
+	var (
+		opts = &Options{
+			Root: `testdata`,
+		}
+		mfs *MemFS
+		err error
+	)
+
+	mfs, err = New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fstest.TestFS(mfs, `index.html`, `direct/add/file`, `exclude/index-link.css`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sub fs.FS
+
+	sub, err = fs.Sub(mfs, `direct`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fstest.TestFS(sub, `add/file`, `add/file2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemFS_Open(t *testing.T) {
+	var (
+		opts = &Options{
+			Root: `testdata`,
+		}
+		mfs *MemFS
+		err error
+	)
+
+	mfs, err = New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		name   string
+		expErr string
+	}
+
+	var cases = []testCase{{
+		name:   `/index.html`,
+		expErr: `open /index.html: invalid argument`,
+	}, {
+		name:   `notexist`,
+		expErr: `open notexist: file does not exist`,
+	}, {
+		name: `index.html`,
+	}}
+
+	var (
+		c testCase
+		f fs.File
+	)
+	for _, c = range cases {
+		f, err = mfs.Open(c.name)
+		if err != nil {
+			test.Assert(t, c.name, c.expErr, err.Error())
+			continue
+		}
+		err = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each file has their own offset.
+
+	var (
+		f1, f2 fs.File
+		exp    []byte
+		got    []byte
+		buf    = make([]byte, 4)
+	)
+
+	exp, err = mfs.ReadFile(`index.html`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f1, err = mfs.Open(`index.html`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, err = mfs.Open(`index.html`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = io.ReadFull(f1, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = io.ReadAll(f2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `f2 content`, string(exp), string(got))
+
+	got, err = io.ReadAll(f1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `f1 content`, string(exp[4:]), string(got))
+}
+
+func TestNewFromFS(t *testing.T) {
+	var (
+		fsys = fstest.MapFS{
+			`index.html`:        {Data: []byte(`<html></html>`)},
+			`big.txt`:           {Data: []byte(`content larger than max file size`)},
+			`dir/a.js`:          {Data: []byte(`var a = 1;`)},
+			`exclude/secret.js`: {Data: []byte(`secret`)},
+		}
+		opts = &Options{
+			Excludes: []string{
+				`^exclude`,
+			},
+			MaxFileSize: 16,
+		}
+
+		mfs *MemFS
+		err error
+	)
+
+	mfs, err = NewFromFS(fsys, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exp = []string{
+		`/`,
+		`/big.txt`,
+		`/dir`,
+		`/dir/a.js`,
+		`/index.html`,
+	}
+	test.Assert(t, `ListNames`, exp, mfs.ListNames())
+
+	var node *Node
+
+	node, err = mfs.Get(`/big.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `big.txt Content`, []byte(nil), node.Content)
+	test.Assert(t, `big.txt ContentType`, `text/plain; charset=utf-8`, node.ContentType)
+
+	var got []byte
+
+	got, err = mfs.ReadFile(`big.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `ReadFile big.txt`, `content larger than max file size`, string(got))
+
+	err = fstest.TestFS(mfs, `index.html`, `big.txt`, `dir/a.js`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -72,6 +72,7 @@ func generate_testdata_direct_add_file() *memfs.Node {
 	node.SetModTimeUnix(1569586540, 0)
 	node.SetName("file")
 	node.SetSize(22)
+	node.SetHash([]byte("\xF6\xE1\x04\x4E\x57\x34\xF7\xA3\x1D\x42\x3E\x48\x90\xEC\x91\x8A\xB5\x8B\xFD\x17\x48\xA2\x9B\xD2\xA5\x6C\x94\x73\xE3\x2B\xFB\x2E"))
 	return node
 }
 
@@ -87,6 +88,7 @@ func generate_testdata_direct_add_file2() *memfs.Node {
 	node.SetModTimeUnix(1569586540, 0)
 	node.SetName("file2")
 	node.SetSize(24)
+	node.SetHash([]byte("\xFD\x31\xD1\x58\x4D\x6E\x93\x98\x89\x28\xBE\x31\xB9\x55\x12\x8D\x2A\x8C\x57\x36\xBC\x79\xAE\xFA\xE9\x4D\x3A\xCB\x1A\x34\x7D\x66"))
 	return node
 }
 
@@ -134,6 +136,7 @@ func generate_testdata_exclude_index_link_css() *memfs.Node {
 	node.SetModTimeUnix(1562038157, 0)
 	node.SetName("index-link.css")
 	node.SetSize(9)
+	node.SetHash([]byte("\x9A\x6F\xEA\x7C\xF5\x64\xE6\xA9\x5B\xF7\x99\x07\xB3\x93\xC7\x1E\x16\x36\x9E\x99\x6E\x41\x44\x3D\x50\x25\x32\x64\x10\x89\x79\x86"))
 	return node
 }
 
@@ -149,6 +152,7 @@ func generate_testdata_exclude_index_link_html() *memfs.Node {
 	node.SetModTimeUnix(1588592347, 0)
 	node.SetName("index-link.html")
 	node.SetSize(14)
+	node.SetHash([]byte("\xB0\x69\x3D\xC9\x2F\x76\xE0\x8B\xF1\x48\x5B\x3D\xD9\xB5\x14\xA2\xE3\x1D\xFD\x6F\x39\x42\x2A\x6B\x60\xED\xB7\x22\x67\x1D\xC9\x8F"))
 	return node
 }
 
@@ -164,6 +168,7 @@ func generate_testdata_exclude_index_link_js() *memfs.Node {
 	node.SetModTimeUnix(1562038157, 0)
 	node.SetName("index-link.js")
 	node.SetSize(16)
+	node.SetHash([]byte("\xBC\x74\xE9\xEE\x74\x21\xFB\x74\x49\xD8\x03\xD9\xA1\x0D\xC2\x6D\x0C\x9B\x5E\x2A\xE8\xEF\xDC\x68\xB8\xC8\x0E\x79\xCD\x94\x79\x02"))
 	return node
 }
 
@@ -211,6 +216,7 @@ func generate_testdata_include_index_css() *memfs.Node {
 	node.SetModTimeUnix(1562038157, 0)
 	node.SetName("index.css")
 	node.SetSize(9)
+	node.SetHash([]byte("\x9A\x6F\xEA\x7C\xF5\x64\xE6\xA9\x5B\xF7\x99\x07\xB3\x93\xC7\x1E\x16\x36\x9E\x99\x6E\x41\x44\x3D\x50\x25\x32\x64\x10\x89\x79\x86"))
 	return node
 }
 
@@ -226,6 +232,7 @@ func generate_testdata_include_index_html() *memfs.Node {
 	node.SetModTimeUnix(1588592347, 0)
 	node.SetName("index.html")
 	node.SetSize(14)
+	node.SetHash([]byte("\xB0\x69\x3D\xC9\x2F\x76\xE0\x8B\xF1\x48\x5B\x3D\xD9\xB5\x14\xA2\xE3\x1D\xFD\x6F\x39\x42\x2A\x6B\x60\xED\xB7\x22\x67\x1D\xC9\x8F"))
 	return node
 }
 
@@ -241,6 +248,7 @@ func generate_testdata_include_index_js() *memfs.Node {
 	node.SetModTimeUnix(1562038157, 0)
 	node.SetName("index.js")
 	node.SetSize(16)
+	node.SetHash([]byte("\xBC\x74\xE9\xEE\x74\x21\xFB\x74\x49\xD8\x03\xD9\xA1\x0D\xC2\x6D\x0C\x9B\x5E\x2A\xE8\xEF\xDC\x68\xB8\xC8\x0E\x79\xCD\x94\x79\x02"))
 	return node
 }
 
@@ -256,6 +264,7 @@ func generate_testdata_index_css() *memfs.Node {
 	node.SetModTimeUnix(1562038157, 0)
 	node.SetName("index.css")
 	node.SetSize(9)
+	node.SetHash([]byte("\x9A\x6F\xEA\x7C\xF5\x64\xE6\xA9\x5B\xF7\x99\x07\xB3\x93\xC7\x1E\x16\x36\x9E\x99\x6E\x41\x44\x3D\x50\x25\x32\x64\x10\x89\x79\x86"))
 	return node
 }
 
@@ -271,6 +280,7 @@ func generate_testdata_index_html() *memfs.Node {
 	node.SetModTimeUnix(1588592347, 0)
 	node.SetName("index.html")
 	node.SetSize(14)
+	node.SetHash([]byte("\xB0\x69\x3D\xC9\x2F\x76\xE0\x8B\xF1\x48\x5B\x3D\xD9\xB5\x14\xA2\xE3\x1D\xFD\x6F\x39\x42\x2A\x6B\x60\xED\xB7\x22\x67\x1D\xC9\x8F"))
 	return node
 }
 
@@ -286,6 +296,7 @@ func generate_testdata_index_js() *memfs.Node {
 	node.SetModTimeUnix(1562038157, 0)
 	node.SetName("index.js")
 	node.SetSize(16)
+	node.SetHash([]byte("\xBC\x74\xE9\xEE\x74\x21\xFB\x74\x49\xD8\x03\xD9\xA1\x0D\xC2\x6D\x0C\x9B\x5E\x2A\xE8\xEF\xDC\x68\xB8\xC8\x0E\x79\xCD\x94\x79\x02"))
 	return node
 }
 
@@ -301,6 +312,7 @@ func generate_testdata_plain() *memfs.Node {
 	node.SetModTimeUnix(1562038157, 0)
 	node.SetName("plain")
 	node.SetSize(22)
+	node.SetHash([]byte("\x65\x97\x68\x68\xBE\xC4\xC1\x68\xC0\xF0\x0B\x63\x92\x3A\x7E\x4A\x37\x65\xB3\xD7\xA9\x49\x9F\xE0\x02\xAB\x58\xC5\x7F\xF5\xCE\xE5"))
 	return node
 }
 
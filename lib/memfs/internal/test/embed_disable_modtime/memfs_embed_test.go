@@ -5,6 +5,7 @@
 package embed
 
 import (
+	"crypto/sha256"
 	"path/filepath"
 	"sort"
 	"testing"
@@ -40,6 +41,9 @@ func TestGeneratePathNode(t *testing.T) {
 	expExcludeIndexHTML.SetName("index-link.html")
 	expExcludeIndexHTML.SetSize(14)
 
+	var hash = sha256.Sum256(expExcludeIndexHTML.Content)
+	expExcludeIndexHTML.SetHash(hash[:])
+
 	cases := []struct {
 		path     string
 		exp      *memfs.Node
@@ -119,7 +123,7 @@ func TestNode_Readdir(t *testing.T) {
 	for _, c := range cases {
 		t.Logf(c.path)
 
-		file, err := memFS.Open(c.path)
+		file, err := memFS.Get(c.path)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -10,12 +10,13 @@ import (
 	"fmt"
 	"io/fs"
 	"log"
-	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	libbytes "github.com/shuLhan/share/lib/bytes"
@@ -30,13 +31,15 @@ const (
 
 // MemFS contains directory tree of file system in memory.
 type MemFS struct {
-	http.FileSystem
-
 	PathNodes *PathNode
 	Root      *Node
 	Opts      *Options
 	dw        *DirWatcher
 
+	// searchIdx is the index of files content, created if the
+	// Opts.Search is set.
+	searchIdx *searchIndex
+
 	watchRE []*regexp.Regexp
 	incRE   []*regexp.Regexp
 	excRE   []*regexp.Regexp
@@ -44,6 +47,21 @@ type MemFS struct {
 	// subfs contains another MemFS instances.
 	// During Get, it will evaluated in order.
 	subfs []*MemFS
+
+	// changes contains the path of nodes that has been created,
+	// modified, or deleted in memory, to be written by Flush.
+	changes map[string]struct{}
+
+	// mtxWrite serialize the methods that modify the tree.
+	mtxWrite sync.Mutex
+
+	// fingerprints map the fingerprinted path to the Node path, and
+	// manifest map the Node path to its fingerprinted path.
+	// Both are created if the Opts.Fingerprint is set.
+	fingerprints map[string]string
+	manifest     map[string]string
+
+	mtxFingerprint sync.RWMutex
 }
 
 // Merge one or more instances of MemFS into single hierarchy.
@@ -117,6 +135,134 @@ func New(opts *Options) (mfs *MemFS, err error) {
 	return mfs, nil
 }
 
+// NewFromFS create new memory file system from the fsys, for example
+// [embed.FS] or [os.DirFS].
+//
+// The Includes, Excludes, and MaxFileSize in opts are applied to the path
+// in fsys, and the Search in opts is used to index the files.
+// The Root and TryDirect options are ignored.
+// The content of file that is larger than MaxFileSize is not mapped to
+// memory, but read from fsys on Open.
+func NewFromFS(fsys fs.FS, opts *Options) (mfs *MemFS, err error) {
+	var logp = `NewFromFS`
+
+	if opts == nil {
+		opts = &Options{}
+	}
+	mfs = &MemFS{
+		Opts: &Options{
+			Includes:    opts.Includes,
+			Excludes:    opts.Excludes,
+			MaxFileSize: opts.MaxFileSize,
+			Embed:       opts.Embed,
+		},
+	}
+
+	err = mfs.Init()
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var fi fs.FileInfo
+
+	fi, err = fs.Stat(fsys, `.`)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	mfs.Root = &Node{
+		fsys:    fsys,
+		SysPath: `.`,
+		Path:    `/`,
+		name:    `/`,
+		modTime: fi.ModTime(),
+		mode:    fi.Mode() | fs.ModeDir,
+	}
+	mfs.Root.generateFuncName(`.`)
+	mfs.PathNodes.Set(mfs.Root.Path, mfs.Root)
+
+	err = fs.WalkDir(fsys, `.`, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == `.` {
+			return nil
+		}
+		return mfs.addFromFS(fsys, name, d)
+	})
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	mfs.Opts.Search = opts.Search
+	mfs.initSearchIndex()
+
+	mfs.Opts.Fingerprint = opts.Fingerprint
+	mfs.initFingerprint()
+
+	return mfs, nil
+}
+
+// addFromFS add the file name from fsys as new Node.
+func (mfs *MemFS) addFromFS(fsys fs.FS, name string, d fs.DirEntry) (err error) {
+	var parent = mfs.PathNodes.Get(path.Join(`/`, path.Dir(name)))
+	if parent == nil {
+		// The parent directory is excluded.
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	}
+
+	var fi fs.FileInfo
+
+	fi, err = d.Info()
+	if err != nil {
+		return err
+	}
+
+	if mfs.isExcluded(name) {
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	}
+	if !d.IsDir() && !mfs.isIncluded(name, fi) {
+		return nil
+	}
+
+	var node = &Node{
+		fsys:    fsys,
+		SysPath: name,
+		Path:    path.Join(`/`, name),
+		name:    d.Name(),
+		modTime: fi.ModTime(),
+		mode:    fi.Mode(),
+		Parent:  parent,
+	}
+	node.generateFuncName(name)
+
+	if !d.IsDir() {
+		node.size = fi.Size()
+		if mfs.Opts.MaxFileSize > 0 && node.size > 0 && node.size <= mfs.Opts.MaxFileSize {
+			node.Content, err = fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			node.updateHash()
+		}
+		err = node.updateContentType()
+		if err != nil {
+			return err
+		}
+	}
+
+	parent.Childs = append(parent.Childs, node)
+	mfs.PathNodes.Set(node.Path, node)
+
+	return nil
+}
+
 // AddChild add FileInfo fi as new child of parent node.
 //
 // It will return nil without an error if,
@@ -242,6 +388,7 @@ func (mfs *MemFS) AddFile(internalPath, externalPath string) (node *Node, err er
 
 	parent.Childs = append(parent.Childs, node)
 	mfs.PathNodes.Set(node.Path, node)
+	mfs.fingerprintNode(node)
 
 	return node, nil
 }
@@ -261,16 +408,31 @@ func (mfs *MemFS) Get(path string) (node *Node, err error) {
 
 	node = mfs.PathNodes.Get(path)
 	if node != nil {
-		if mfs.Opts.TryDirect {
+		if mfs.Opts.TryDirect && !mfs.isChanged(path) {
 			_ = node.Update(nil, mfs.Opts.MaxFileSize)
 
 			// Ignore error if the file is not exist in storage.
 			// Use case: the node maybe have been result of embed and the
 			// merged with other MemFS instance that use TryDirect flag.
+
+			mfs.fingerprintNode(node)
 		}
 		return node, nil
 	}
 
+	// Get node from fingerprinted path.
+	var (
+		nodePath string
+		ok       bool
+	)
+	nodePath, ok = mfs.fingerprintPath(path)
+	if ok {
+		node = mfs.PathNodes.Get(nodePath)
+		if node != nil {
+			return node, nil
+		}
+	}
+
 	// Get node from sub fs.
 	var sub *MemFS
 
@@ -281,9 +443,10 @@ func (mfs *MemFS) Get(path string) (node *Node, err error) {
 		}
 	}
 
-	// Refresh the root FS first.
+	// Refresh the root FS first, unless the path has been removed in
+	// memory.
 
-	if mfs.Opts.TryDirect {
+	if mfs.Opts.TryDirect && !mfs.isChanged(path) {
 		node, err = mfs.refresh(path)
 		if err != nil {
 			if !errors.Is(err, fs.ErrNotExist) {
@@ -354,6 +517,9 @@ func (mfs *MemFS) Init() (err error) {
 		return fmt.Errorf("%s: %w", logp, err)
 	}
 
+	mfs.initSearchIndex()
+	mfs.initFingerprint()
+
 	return nil
 }
 
@@ -389,12 +555,6 @@ func (mfs *MemFS) MustGet(path string) (node *Node) {
 	return node
 }
 
-// Open the named file for reading.
-// This is an alias to Get() method, to make it implement http.FileSystem.
-func (mfs *MemFS) Open(path string) (http.File, error) {
-	return mfs.Get(path)
-}
-
 // RemoveChild remove a child on parent, including its map on PathNode.
 // If child is not part if node's childrens it will return nil.
 func (mfs *MemFS) RemoveChild(parent *Node, child *Node) (removed *Node) {
@@ -408,6 +568,16 @@ func (mfs *MemFS) RemoveChild(parent *Node, child *Node) (removed *Node) {
 }
 
 // Search one or more strings in each content of files.
+//
+// If the Options Search is set, the words are searched using the index,
+// where each element of words is tokenized into words; an element that
+// contains more than one word, for example "memory file", is searched as
+// phrase.
+// The results is sorted by its Score, and each snippet has its
+// Highlights.
+//
+// Without index, each element of words is searched as sub-string in the
+// lower cases content of files.
 func (mfs *MemFS) Search(words []string, snippetLen int) (results []SearchResult) {
 	if len(words) == 0 {
 		return nil
@@ -415,6 +585,9 @@ func (mfs *MemFS) Search(words []string, snippetLen int) (results []SearchResult
 	if snippetLen <= 0 {
 		snippetLen = 60
 	}
+	if mfs.searchIdx != nil {
+		return mfs.searchIndexed(words, snippetLen)
+	}
 
 	tokens := libstrings.ToBytes(words)
 	for x := 0; x < len(tokens); x++ {
@@ -617,7 +790,22 @@ func (mfs *MemFS) mount() (err error) {
 func (mfs *MemFS) Remount() (err error) {
 	mfs.Root = nil
 	mfs.PathNodes = nil
-	return mfs.mount()
+	mfs.searchIdx = nil
+
+	mfs.mtxFingerprint.Lock()
+	mfs.fingerprints = nil
+	mfs.manifest = nil
+	mfs.mtxFingerprint.Unlock()
+
+	err = mfs.mount()
+	if err != nil {
+		return err
+	}
+
+	mfs.initSearchIndex()
+	mfs.initFingerprint()
+
+	return nil
 }
 
 // scanDir scan the content of node directory and add them to mfs.
@@ -763,6 +951,7 @@ func (mfs *MemFS) Watch(opts WatchOptions) (dw *DirWatcher, err error) {
 		fs:      mfs,
 		Delay:   opts.Delay,
 		Options: *mfs.Opts,
+		Polling: opts.Polling,
 	}
 
 	_, err = mfs.scanDir(mfs.Root)
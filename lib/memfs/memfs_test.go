@@ -188,6 +188,7 @@ func TestMemFS_AddFile(t *testing.T) {
 			ContentType: "text/plain; charset=utf-8",
 			size:        22,
 			Content:     []byte("Test direct add file.\n"),
+			hash:        sha256Sum("Test direct add file.\n"),
 			GenFuncName: "generate_internal_file",
 		},
 	}, {
@@ -201,6 +202,7 @@ func TestMemFS_AddFile(t *testing.T) {
 			ContentType: "text/plain; charset=utf-8",
 			size:        24,
 			Content:     []byte("Test direct add file 2.\n"),
+			hash:        sha256Sum("Test direct add file 2.\n"),
 			GenFuncName: "generate_internal_file2",
 		},
 	}}
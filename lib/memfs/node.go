@@ -6,7 +6,9 @@ package memfs
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -37,14 +39,25 @@ body{font-family:monospace; white-space:pre;}
 var (
 	errOffset = errors.New("Seek: invalid offset")
 	errWhence = errors.New("Seek: invalid whence")
+
+	errIsDir        = errors.New(`is a directory`)
+	errNotDir       = errors.New(`not a directory`)
+	errNotSupported = errors.New(`operation not supported`)
+	errNotIndexed   = errors.New(`search index is not enabled`)
+	errNotEmpty     = errors.New(`directory not empty`)
 )
 
 // Node represent a single file.
 //
-// This Node implement os.FileInfo and http.File.
+// This Node implement os.FileInfo, fs.DirEntry, and http.File.
 type Node struct {
 	modTime time.Time // ModTime contains file modification time.
 
+	// fsys is the file system where the node is created, set if the
+	// node is created using NewFromFS.
+	// If its not nil, the SysPath is the path of file in fsys.
+	fsys fs.FS
+
 	Parent *Node // Pointer to parent directory.
 
 	SysPath     string // The original file path in system.
@@ -56,6 +69,7 @@ type Node struct {
 	Childs []*Node // List of files in directory.
 
 	Content []byte // Content of file.
+	hash    []byte // SHA-256 of Content.
 	plainv  []byte // Content of file in plain text.
 	lowerv  []byte // Content of file in lower cases.
 
@@ -191,6 +205,53 @@ func (node *Node) GenerateIndexHtml() {
 	node.ContentType = `text/html; charset=utf-8`
 	node.size = int64(buf.Len())
 	node.Content = libbytes.Copy(buf.Bytes())
+	node.updateHash()
+}
+
+// ETag return the strong entity tag of node, the quoted hex of SHA-256 of
+// its Content.
+// It return empty string if the node content is not mapped in memory.
+func (node *Node) ETag() string {
+	if len(node.hash) == 0 {
+		return ``
+	}
+	return `"` + hex.EncodeToString(node.hash) + `"`
+}
+
+// Fingerprint return the node path with the hash of its content inserted
+// before the file extension, for example "/js/app.js" become
+// "/js/app.3f9a2c1b.js".
+// It return the node path as is if the node content is not mapped in
+// memory.
+func (node *Node) Fingerprint() string {
+	if len(node.hash) == 0 {
+		return node.Path
+	}
+	return fingerprintName(node.Path, node.hash)
+}
+
+// Hash return the SHA-256 hash of node content.
+// It return nil if the node content is not mapped in memory.
+func (node *Node) Hash() []byte {
+	return node.hash
+}
+
+// Info return the node as file information.
+// This method is provided to implement [fs.DirEntry].
+func (node *Node) Info() (fs.FileInfo, error) {
+	return node, nil
+}
+
+// Integrity return the Subresource Integrity (SRI) value of node content,
+// in the format "sha256-<base64>".
+// It return empty string if the node content is not mapped in memory.
+//
+// See https://www.w3.org/TR/SRI/.
+func (node *Node) Integrity() string {
+	if len(node.hash) == 0 {
+		return ``
+	}
+	return `sha256-` + base64.StdEncoding.EncodeToString(node.hash)
 }
 
 // IsDir return true if the node is a directory.
@@ -313,6 +374,7 @@ func (node *Node) Save(content []byte) (err error) {
 	node.Content = content
 	node.modTime = time.Now()
 	node.size = int64(len(content))
+	node.updateHash()
 	return nil
 }
 
@@ -338,6 +400,13 @@ func (node *Node) Seek(offset int64, whence int) (int64, error) {
 	return node.off, nil
 }
 
+// SetHash set the SHA-256 hash of node content.
+// This method is used by the code generated by GoEmbed, so the content
+// does not need to be hashed again on Init.
+func (node *Node) SetHash(hash []byte) {
+	node.hash = hash
+}
+
 // SetModTime set the file modification time.
 func (node *Node) SetModTime(modTime time.Time) {
 	node.modTime = modTime
@@ -487,6 +556,12 @@ func (node *Node) resetAllModTime(t time.Time) {
 	}
 }
 
+// Type return the type bits of node file mode.
+// This method is provided to implement [fs.DirEntry].
+func (node *Node) Type() fs.FileMode {
+	return node.mode.Type()
+}
+
 // Update the node metadata or content based on new file information.
 //
 // The newInfo parameter is optional, if its nil, it will read the file
@@ -557,6 +632,7 @@ func (node *Node) updateContent(maxFileSize int64) (err error) {
 	}
 	if node.size == 0 {
 		node.Content = nil
+		node.hash = nil
 		return nil
 	}
 
@@ -567,10 +643,21 @@ func (node *Node) updateContent(maxFileSize int64) (err error) {
 		}
 		return fmt.Errorf("updateContent: %w", err)
 	}
+	node.updateHash()
 
 	return nil
 }
 
+// updateHash compute the SHA-256 of node Content.
+func (node *Node) updateHash() {
+	if len(node.Content) == 0 {
+		node.hash = nil
+		return
+	}
+	var sum = sha256.Sum256(node.Content)
+	node.hash = sum[:]
+}
+
 // updateDir update the childs node by reading content of directory.
 func (node *Node) updateDir(maxFileSize int64) (err error) {
 	var (
@@ -667,10 +754,19 @@ func (node *Node) updateContentType() error {
 		return nil
 	}
 
-	logp := "updateContentType"
-	data := make([]byte, 512)
+	var (
+		logp = "updateContentType"
+		data = make([]byte, 512)
 
-	f, err := os.Open(node.SysPath)
+		f   fs.File
+		err error
+	)
+
+	if node.fsys != nil {
+		f, err = node.fsys.Open(node.SysPath)
+	} else {
+		f, err = os.Open(node.SysPath)
+	}
 	if err != nil {
 		if errors.Is(err, io.EOF) {
 			// File is empty.
@@ -76,7 +76,7 @@ func TestNode_Readdir(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	f, err := mfs.Open("/")
+	f, err := mfs.Get("/")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,82 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"path"
+	"sort"
+	"time"
+)
+
+// notifyDelay define the duration to collect the bursts of events from
+// the notification backend before processing them at once.
+const notifyDelay = 100 * time.Millisecond
+
+// notifyEvent define the event received from the file system notification
+// backend.
+type notifyEvent struct {
+	// path is the Node.Path of watched directory or file.
+	path string
+
+	// name is the name of child inside the watched directory, or empty
+	// if the event is on the watched path itself.
+	name string
+
+	// isEntry is true if the child is created, deleted, or renamed.
+	isEntry bool
+
+	// overflow is true if the backend queue is full and some events
+	// has been lost.
+	overflow bool
+}
+
+// notifyBatch collect the events to be processed at once.
+type notifyBatch struct {
+	// updates contains the path of directories where its childs has
+	// been created, deleted, or renamed.
+	updates map[string]struct{}
+
+	// checks contains the path of node where its mode, content, or
+	// existence need to be checked.
+	checks map[string]struct{}
+
+	overflow bool
+}
+
+func newNotifyBatch() (batch *notifyBatch) {
+	return &notifyBatch{
+		updates: make(map[string]struct{}),
+		checks:  make(map[string]struct{}),
+	}
+}
+
+// add the event into batch.
+func (batch *notifyBatch) add(ev notifyEvent) {
+	if ev.overflow {
+		batch.overflow = true
+		return
+	}
+	if len(ev.name) == 0 {
+		batch.checks[ev.path] = struct{}{}
+		return
+	}
+	if ev.isEntry {
+		batch.updates[ev.path] = struct{}{}
+	}
+	// The child is also checked, in case the file is replaced by
+	// renaming other file into it.
+	batch.checks[path.Join(ev.path, ev.name)] = struct{}{}
+}
+
+// sortedKeys return the keys in the map sorted in ascending order, so the
+// parent directory is processed before its childs.
+func sortedKeys(set map[string]struct{}) (keys []string) {
+	var key string
+	for key = range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,208 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package memfs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	inotifyMask = unix.IN_ATTRIB | unix.IN_CLOSE_WRITE | unix.IN_CREATE |
+		unix.IN_DELETE | unix.IN_DELETE_SELF | unix.IN_MODIFY |
+		unix.IN_MOVE_SELF | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+	inotifyEntryMask = unix.IN_CREATE | unix.IN_DELETE |
+		unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+	// inotifyBufferSize define the size of buffer to read the events,
+	// enough to read 64 events with maximum file name length.
+	inotifyBufferSize = 64 * (unix.SizeofInotifyEvent + unix.NAME_MAX + 1)
+)
+
+// notifier is the file system notification backend using Linux inotify.
+type notifier struct {
+	// C channel on which the events are delivered.
+	C <-chan notifyEvent
+
+	events chan notifyEvent
+	done   chan struct{}
+
+	f *os.File
+
+	// wdPath map the watch descriptor to the Node.Path.
+	wdPath map[int]string
+
+	// pathWd map the Node.Path to the watch descriptor.
+	pathWd map[string]int
+
+	fd int
+
+	sync.Mutex
+}
+
+// newNotifier create and start reading the inotify events.
+func newNotifier() (n *notifier, err error) {
+	var fd int
+
+	fd, err = unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf(`newNotifier: %w`, err)
+	}
+
+	n = &notifier{
+		events: make(chan notifyEvent, dirWatcherQueueSize),
+		done:   make(chan struct{}),
+		// The non-blocking file descriptor is registered to Go
+		// runtime poller, so Read can be interrupted by Close.
+		f:      os.NewFile(uintptr(fd), `inotify`),
+		wdPath: make(map[int]string),
+		pathWd: make(map[string]int),
+		fd:     fd,
+	}
+	n.C = n.events
+
+	go n.read()
+
+	return n, nil
+}
+
+// watch the directory or file in sysPath.
+// The events on sysPath will be delivered using nodePath.
+//
+// If the same file is being watched using different path, for example
+// when the directory is renamed, the old path will be replaced.
+func (n *notifier) watch(sysPath, nodePath string) (err error) {
+	var wd int
+
+	wd, err = unix.InotifyAddWatch(n.fd, sysPath, inotifyMask)
+	if err != nil {
+		return fmt.Errorf(`watch %q: %w`, sysPath, err)
+	}
+
+	n.Lock()
+	var oldPath, ok = n.wdPath[wd]
+	if ok && oldPath != nodePath {
+		delete(n.pathWd, oldPath)
+	}
+	n.wdPath[wd] = nodePath
+	n.pathWd[nodePath] = wd
+	n.Unlock()
+
+	return nil
+}
+
+// unwatch remove the watch on nodePath.
+func (n *notifier) unwatch(nodePath string) {
+	n.Lock()
+	var wd, ok = n.pathWd[nodePath]
+	if ok {
+		delete(n.pathWd, nodePath)
+		delete(n.wdPath, wd)
+		// The error is ignored, the watch may have been removed
+		// by kernel when the file is deleted.
+		_, _ = unix.InotifyRmWatch(n.fd, uint32(wd))
+	}
+	n.Unlock()
+}
+
+// unwatchAll remove all watches.
+func (n *notifier) unwatchAll() {
+	var wd int
+
+	n.Lock()
+	for wd = range n.wdPath {
+		_, _ = unix.InotifyRmWatch(n.fd, uint32(wd))
+	}
+	n.wdPath = make(map[int]string)
+	n.pathWd = make(map[string]int)
+	n.Unlock()
+}
+
+// close stop reading the events and release the inotify instance.
+func (n *notifier) close() {
+	close(n.done)
+	_ = n.f.Close()
+}
+
+// read the inotify events until the notifier is closed.
+func (n *notifier) read() {
+	var (
+		buf = make([]byte, inotifyBufferSize)
+
+		raw   *unix.InotifyEvent
+		name  []byte
+		nread int
+		off   int
+		err   error
+	)
+
+	defer close(n.events)
+
+	for {
+		nread, err = n.f.Read(buf)
+		if err != nil {
+			return
+		}
+
+		off = 0
+		for off+unix.SizeofInotifyEvent <= nread {
+			raw = (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			off += unix.SizeofInotifyEvent
+
+			name = buf[off : off+int(raw.Len)]
+			name = bytes.TrimRight(name, "\x00")
+			off += int(raw.Len)
+
+			if !n.dispatch(int(raw.Wd), raw.Mask, string(name)) {
+				return
+			}
+		}
+	}
+}
+
+// dispatch convert the raw event into notifyEvent and send it to channel
+// C.
+// It will return false if the notifier has been closed.
+func (n *notifier) dispatch(wd int, mask uint32, name string) bool {
+	var ev notifyEvent
+
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		ev.overflow = true
+	} else {
+		var ok bool
+
+		n.Lock()
+		ev.path, ok = n.wdPath[wd]
+		if ok && mask&unix.IN_IGNORED != 0 {
+			// The watch has been removed by kernel.
+			delete(n.wdPath, wd)
+			if n.pathWd[ev.path] == wd {
+				delete(n.pathWd, ev.path)
+			}
+		}
+		n.Unlock()
+
+		if !ok {
+			return true
+		}
+		ev.name = name
+		ev.isEntry = mask&inotifyEntryMask != 0
+	}
+
+	select {
+	case n.events <- ev:
+	case <-n.done:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package memfs
+
+import "errors"
+
+var errNotifyUnsupported = errors.New(`file system notification is not supported`)
+
+// notifier is the placeholder for file system notification backend on
+// system that is not supported yet.
+// The watcher will fallback to polling.
+type notifier struct {
+	C <-chan notifyEvent
+}
+
+func newNotifier() (n *notifier, err error) {
+	return nil, errNotifyUnsupported
+}
+
+func (n *notifier) watch(sysPath, nodePath string) error {
+	return errNotifyUnsupported
+}
+
+func (n *notifier) unwatch(nodePath string) {}
+
+func (n *notifier) unwatchAll() {}
+
+func (n *notifier) close() {}
@@ -41,6 +41,25 @@ type Options struct {
 	// One is reading content from memory, one is reading content from
 	// disk directly.
 	TryDirect bool
+
+	// Search define the options to index the content of files, so
+	// Search can use the index instead of scanning all files.
+	// If its nil, the index will not be created.
+	//
+	// The index is created on Init and Remount, and updated by
+	// DirWatcher from Watch.
+	Search *SearchOptions
+
+	// Fingerprint if its true, each file whose content is mapped in
+	// memory is also accessible using fingerprinted path, the path with
+	// the first 8 hex characters of its content hash inserted before the
+	// file extension.
+	// For example, "/js/app.js" is accessible as
+	// "/js/app.3f9a2c1b.js".
+	//
+	// Use [MemFS.Fingerprint] or [MemFS.Manifest] to resolve the
+	// fingerprinted path.
+	Fingerprint bool
 }
 
 // init initialize the options with default value.
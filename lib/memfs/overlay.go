@@ -0,0 +1,664 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NewOverlay create new writable MemFS on top of the read-only base.
+//
+// The tree of base is copied into the new MemFS, while the content of
+// files is shared until its modified (copy-on-write), so any changes on
+// the returned MemFS does not affect the base.
+// The base can be MemFS from directory, from [NewFromFS], or from
+// GoEmbed.
+//
+// The Options of base is copied, so the changes can be flushed back to
+// the same Options.Root using [MemFS.Flush].
+func NewOverlay(base *MemFS) (mfs *MemFS, err error) {
+	var logp = `NewOverlay`
+
+	if base == nil || base.Root == nil {
+		return nil, fmt.Errorf(`%s: empty base`, logp)
+	}
+
+	var opts = Options{}
+	if base.Opts != nil {
+		opts = *base.Opts
+	}
+
+	mfs = &MemFS{
+		PathNodes: NewPathNode(),
+		Opts:      &opts,
+		subfs:     base.subfs,
+	}
+	mfs.Root = cloneNode(nil, base.Root, mfs.PathNodes)
+
+	err = mfs.Init()
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return mfs, nil
+}
+
+// cloneNode copy the node and its childs recursively, without copying the
+// content.
+// If pn is not nil, each cloned node is set into it.
+func cloneNode(parent, node *Node, pn *PathNode) (clone *Node) {
+	var v = *node
+
+	clone = &v
+	clone.Parent = parent
+	clone.Childs = make([]*Node, 0, len(node.Childs))
+	clone.off = 0
+
+	var child *Node
+	for _, child = range node.Childs {
+		clone.Childs = append(clone.Childs, cloneNode(clone, child, pn))
+	}
+	if pn != nil {
+		pn.Set(clone.Path, clone)
+	}
+	return clone
+}
+
+// Flush write all changes from Mkdir, MkdirAll, WriteFile, Remove,
+// RemoveAll, and Rename into the Options.Root directory.
+func (mfs *MemFS) Flush() (err error) {
+	var logp = `Flush`
+
+	mfs.mtxWrite.Lock()
+	defer mfs.mtxWrite.Unlock()
+
+	if mfs.Opts == nil || len(mfs.Opts.Root) == 0 {
+		return fmt.Errorf(`%s: empty Options.Root`, logp)
+	}
+
+	var (
+		paths = make([]string, 0, len(mfs.changes))
+
+		name    string
+		sysPath string
+		node    *Node
+		x       int
+	)
+	for name = range mfs.changes {
+		paths = append(paths, name)
+	}
+	sort.Strings(paths)
+
+	// Remove the deleted files first, start from the deepest path.
+	for x = len(paths) - 1; x >= 0; x-- {
+		name = paths[x]
+		if mfs.PathNodes.Get(name) != nil {
+			continue
+		}
+		err = os.RemoveAll(mfs.flushPath(name))
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+		delete(mfs.changes, name)
+	}
+
+	// Create or update the rest, start from the parent.
+	for _, name = range paths {
+		node = mfs.PathNodes.Get(name)
+		if node == nil {
+			continue
+		}
+		sysPath = mfs.flushPath(name)
+
+		err = flushNode(node, sysPath)
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+		node.SysPath = sysPath
+		node.fsys = nil
+		delete(mfs.changes, name)
+	}
+	return nil
+}
+
+// flushPath return the path in system for the node path.
+func (mfs *MemFS) flushPath(nodePath string) string {
+	return filepath.Join(mfs.Opts.Root, filepath.FromSlash(nodePath))
+}
+
+// flushNode write the directory or file node into sysPath.
+// If the sysPath exist with different type, it will be removed first.
+func flushNode(node *Node, sysPath string) (err error) {
+	var (
+		perm = node.mode.Perm()
+		fi   os.FileInfo
+	)
+
+	fi, err = os.Lstat(sysPath)
+	if err == nil && fi.IsDir() != node.IsDir() {
+		err = os.RemoveAll(sysPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if node.IsDir() {
+		err = os.MkdirAll(sysPath, perm)
+	} else {
+		err = os.WriteFile(sysPath, node.Content, perm)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Chmod(sysPath, perm)
+}
+
+// Mkdir create new directory with the Node path name and permission perm
+// in memory.
+// The parent directory must already exist.
+func (mfs *MemFS) Mkdir(name string, perm fs.FileMode) (node *Node, err error) {
+	mfs.mtxWrite.Lock()
+	defer mfs.mtxWrite.Unlock()
+
+	return mfs.mkdir(name, perm)
+}
+
+// MkdirAll create directory with the Node path name and permission perm
+// in memory, along with any parents that does not exist.
+// If the directory already exist, it will return the directory node.
+func (mfs *MemFS) MkdirAll(name string, perm fs.FileMode) (node *Node, err error) {
+	mfs.mtxWrite.Lock()
+	defer mfs.mtxWrite.Unlock()
+
+	name = cleanNodePath(name)
+
+	node = mfs.writeRoot()
+	if name == `/` {
+		return node, nil
+	}
+
+	var (
+		names = strings.Split(name[1:], `/`)
+		sub   string
+		child *Node
+	)
+	for _, sub = range names {
+		child = node.Child(sub)
+		if child == nil {
+			child, err = mfs.mkdir(path.Join(node.Path, sub), perm)
+			if err != nil {
+				return nil, err
+			}
+		} else if !child.IsDir() {
+			return nil, &fs.PathError{Op: `mkdir`, Path: child.Path, Err: errNotDir}
+		}
+		node = child
+	}
+	return node, nil
+}
+
+func (mfs *MemFS) mkdir(name string, perm fs.FileMode) (node *Node, err error) {
+	var parent *Node
+
+	parent, name, err = mfs.writeParent(`mkdir`, name)
+	if err != nil {
+		return nil, err
+	}
+
+	node = mfs.newWriteNode(parent, path.Base(name), fs.ModeDir|perm.Perm(), nil)
+
+	return node, nil
+}
+
+// WriteFile write the content into file with the Node path name in
+// memory.
+// If the file does not exist, it will be created with permission perm;
+// otherwise the content is replaced, without changing the permission.
+// The parent directory must already exist.
+//
+// The existing file is replaced with new Node, so the Node that is
+// returned previously by Get or being read by opened file is not changed.
+//
+// The content is copied, so the caller can reuse it.
+func (mfs *MemFS) WriteFile(name string, content []byte, perm fs.FileMode) (node *Node, err error) {
+	var logp = `write`
+
+	mfs.mtxWrite.Lock()
+	defer mfs.mtxWrite.Unlock()
+
+	var v []byte
+	if len(content) > 0 {
+		v = make([]byte, len(content))
+		copy(v, content)
+	}
+
+	name = cleanNodePath(name)
+
+	mfs.writeRoot()
+
+	node = mfs.PathNodes.Get(name)
+	if node != nil {
+		if node.IsDir() {
+			return nil, &fs.PathError{Op: logp, Path: name, Err: errIsDir}
+		}
+		// Replace the node instead of modifying it, so the node
+		// that is being read concurrently, for example by opened
+		// file or HTTP handler, is not changed.
+		var old = node
+
+		node = &Node{
+			Parent:      old.Parent,
+			SysPath:     old.SysPath,
+			Path:        old.Path,
+			name:        old.name,
+			GenFuncName: old.GenFuncName,
+			Content:     v,
+			size:        int64(len(v)),
+			mode:        old.mode,
+			modTime:     time.Now(),
+		}
+		_ = node.updateContentType()
+		node.updateHash()
+
+		replaceChild(old.Parent, old, node)
+		mfs.registerNode(node)
+		return node, nil
+	}
+
+	var parent *Node
+
+	parent, name, err = mfs.writeParent(logp, name)
+	if err != nil {
+		return nil, err
+	}
+
+	node = mfs.newWriteNode(parent, path.Base(name), perm.Perm(), v)
+
+	return node, nil
+}
+
+// Remove the file or empty directory with the Node path name from
+// memory.
+func (mfs *MemFS) Remove(name string) (err error) {
+	var logp = `remove`
+
+	mfs.mtxWrite.Lock()
+	defer mfs.mtxWrite.Unlock()
+
+	var node *Node
+
+	node, err = mfs.writeNode(logp, name)
+	if err != nil {
+		return err
+	}
+	if node.IsDir() && len(node.Childs) > 0 {
+		return &fs.PathError{Op: logp, Path: node.Path, Err: errNotEmpty}
+	}
+
+	mfs.removeNode(node)
+
+	return nil
+}
+
+// RemoveAll remove the file or directory with the Node path name and its
+// childs from memory.
+// If the name does not exist, it will return nil.
+func (mfs *MemFS) RemoveAll(name string) (err error) {
+	var logp = `remove`
+
+	mfs.mtxWrite.Lock()
+	defer mfs.mtxWrite.Unlock()
+
+	var node *Node
+
+	node, err = mfs.writeNode(logp, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	mfs.removeNode(node)
+
+	return nil
+}
+
+// Rename move the file or directory with the Node path oldname to newname
+// in memory.
+// If the newname already exist and its a file, it will be replaced.
+// If the newname already exist and its a directory, it must be empty and
+// the oldname must be a directory too.
+//
+// The node and its childs are moved as new Nodes, so the Node that is
+// returned previously by Get is not changed.
+func (mfs *MemFS) Rename(oldname, newname string) (err error) {
+	var logp = `rename`
+
+	mfs.mtxWrite.Lock()
+	defer mfs.mtxWrite.Unlock()
+
+	oldname = cleanNodePath(oldname)
+	newname = cleanNodePath(newname)
+
+	var (
+		node   *Node
+		parent *Node
+	)
+
+	node, err = mfs.writeNode(logp, oldname)
+	if err == nil {
+		if newname == oldname {
+			return nil
+		}
+		if strings.HasPrefix(newname, oldname+`/`) {
+			err = fs.ErrInvalid
+		} else {
+			parent, newname, err = mfs.writeParent(logp, newname)
+		}
+	}
+	if err != nil {
+		return &os.LinkError{Op: logp, Old: oldname, New: newname, Err: unwrapPathError(err)}
+	}
+
+	var exist = mfs.PathNodes.Get(newname)
+	if exist != nil {
+		if exist.IsDir() && (!node.IsDir() || len(exist.Childs) > 0) {
+			return &os.LinkError{Op: logp, Old: oldname, New: newname, Err: fs.ErrExist}
+		}
+		if !exist.IsDir() && node.IsDir() {
+			return &os.LinkError{Op: logp, Old: oldname, New: newname, Err: errNotDir}
+		}
+	}
+
+	// Move the copy of node and its childs, so the node that is being
+	// read concurrently is not changed.
+	var clone = cloneNode(nil, node, nil)
+
+	// The content that is not mapped to memory is read before the
+	// node moved, since the old file will be removed on Flush.
+	err = loadContent(clone)
+	if err != nil {
+		return &os.LinkError{Op: logp, Old: oldname, New: newname, Err: err}
+	}
+
+	if exist != nil {
+		mfs.removeNode(exist)
+	}
+
+	mfs.unregisterNode(node)
+	mfs.detachNode(node)
+
+	clone.name = path.Base(newname)
+	clone.modTime = time.Now()
+	moveNode(clone, parent)
+	addChild(parent, clone)
+
+	mfs.registerNode(clone)
+
+	return nil
+}
+
+// cleanNodePath clean the name into absolute Node path.
+func cleanNodePath(name string) string {
+	return path.Join(`/`, name)
+}
+
+// unwrapPathError return the underlying error of fs.PathError.
+func unwrapPathError(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+// writeRoot return the Root node, create it in memory if its not exist.
+func (mfs *MemFS) writeRoot() *Node {
+	if mfs.Opts == nil {
+		mfs.Opts = &Options{}
+		mfs.Opts.init()
+	}
+	if mfs.PathNodes == nil {
+		mfs.PathNodes = NewPathNode()
+	}
+	if mfs.Root != nil {
+		return mfs.Root
+	}
+
+	mfs.Root = &Node{
+		SysPath: mfs.Opts.Root,
+		Path:    `/`,
+		name:    `/`,
+		modTime: time.Now(),
+		mode:    fs.ModeDir | 0700,
+	}
+	mfs.Root.generateFuncName(mfs.Opts.Root)
+	mfs.PathNodes.Set(mfs.Root.Path, mfs.Root)
+
+	return mfs.Root
+}
+
+// writeNode return the existing node, except root, to be modified.
+func (mfs *MemFS) writeNode(op, name string) (node *Node, err error) {
+	name = cleanNodePath(name)
+	mfs.writeRoot()
+
+	if name == `/` {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	node = mfs.PathNodes.Get(name)
+	if node == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return node, nil
+}
+
+// writeParent return the parent directory of name, where name must not
+// exist.
+func (mfs *MemFS) writeParent(op, name string) (parent *Node, cleanName string, err error) {
+	cleanName = cleanNodePath(name)
+	mfs.writeRoot()
+
+	if cleanName == `/` {
+		return nil, cleanName, &fs.PathError{Op: op, Path: cleanName, Err: fs.ErrExist}
+	}
+
+	parent = mfs.PathNodes.Get(path.Dir(cleanName))
+	if parent == nil {
+		return nil, cleanName, &fs.PathError{Op: op, Path: cleanName, Err: fs.ErrNotExist}
+	}
+	if !parent.IsDir() {
+		return nil, cleanName, &fs.PathError{Op: op, Path: cleanName, Err: errNotDir}
+	}
+	if op != `rename` && mfs.PathNodes.Get(cleanName) != nil {
+		return nil, cleanName, &fs.PathError{Op: op, Path: cleanName, Err: fs.ErrExist}
+	}
+	return parent, cleanName, nil
+}
+
+// newWriteNode create new node in parent and register it.
+func (mfs *MemFS) newWriteNode(parent *Node, name string, mode fs.FileMode, content []byte) (node *Node) {
+	node = &Node{
+		SysPath: filepath.Join(parent.SysPath, name),
+		Path:    path.Join(parent.Path, name),
+		name:    name,
+		mode:    mode,
+		modTime: time.Now(),
+		size:    int64(len(content)),
+		Content: content,
+	}
+	node.generateFuncName(node.SysPath)
+	if !node.IsDir() {
+		_ = node.updateContentType()
+		node.updateHash()
+	}
+
+	addChild(parent, node)
+	mfs.registerNode(node)
+
+	return node
+}
+
+// registerNode add the node and its childs into PathNodes and search
+// index, and mark them as changed.
+func (mfs *MemFS) registerNode(node *Node) {
+	mfs.PathNodes.Set(node.Path, node)
+	mfs.markChanged(node.Path)
+	mfs.indexNode(node)
+	mfs.fingerprintNode(node)
+
+	var child *Node
+	for _, child = range node.Childs {
+		mfs.registerNode(child)
+	}
+}
+
+// unregisterNode remove the node and its childs from PathNodes and search
+// index, and mark them as changed.
+func (mfs *MemFS) unregisterNode(node *Node) {
+	var child *Node
+	for _, child = range node.Childs {
+		mfs.unregisterNode(child)
+	}
+
+	mfs.PathNodes.Delete(node.Path)
+	mfs.markChanged(node.Path)
+	mfs.unindexNode(node)
+	mfs.unfingerprintNode(node)
+}
+
+// removeNode remove the node and its childs from memory.
+func (mfs *MemFS) removeNode(node *Node) {
+	mfs.unregisterNode(node)
+	mfs.detachNode(node)
+}
+
+// detachNode remove the node from its parent, without removing its
+// childs.
+func (mfs *MemFS) detachNode(node *Node) {
+	var (
+		parent = node.Parent
+		child  *Node
+		x      int
+	)
+	if parent == nil {
+		return
+	}
+	for x, child = range parent.Childs {
+		if child == node {
+			parent.Childs = append(parent.Childs[:x:x], parent.Childs[x+1:]...)
+			break
+		}
+	}
+	parent.modTime = time.Now()
+	node.Parent = nil
+}
+
+// addChild append the node into the copy of parent Childs, so the list
+// of childs that is being read concurrently is not changed.
+func addChild(parent, node *Node) {
+	var childs = make([]*Node, 0, len(parent.Childs)+1)
+
+	childs = append(childs, parent.Childs...)
+	parent.Childs = append(childs, node)
+	node.Parent = parent
+}
+
+// replaceChild replace the child old in parent with node.
+// The Childs of parent is copied, so the list of childs that is being
+// read concurrently is not changed.
+func replaceChild(parent, old, node *Node) {
+	if parent == nil {
+		return
+	}
+
+	var (
+		childs = make([]*Node, len(parent.Childs))
+		child  *Node
+		x      int
+	)
+	copy(childs, parent.Childs)
+	for x, child = range childs {
+		if child == old {
+			childs[x] = node
+			break
+		}
+	}
+	parent.Childs = childs
+}
+
+// moveNode update the Path and SysPath of node and its childs, as the
+// child of parent.
+func moveNode(node, parent *Node) {
+	node.Path = path.Join(parent.Path, node.name)
+	node.SysPath = filepath.Join(parent.SysPath, node.name)
+	node.generateFuncName(node.SysPath)
+
+	var child *Node
+	for _, child = range node.Childs {
+		moveNode(child, node)
+	}
+}
+
+// loadContent read the content of node and its childs that is not mapped
+// to memory.
+func loadContent(node *Node) (err error) {
+	if node.IsDir() {
+		var child *Node
+		for _, child = range node.Childs {
+			err = loadContent(child)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var f = newFile(node)
+	if !f.ondisk {
+		return nil
+	}
+
+	var content []byte
+
+	content, err = io.ReadAll(f)
+	errClose := f.Close()
+	if err != nil {
+		return err
+	}
+	if errClose != nil {
+		return errClose
+	}
+
+	node.Content = content
+	node.fsys = nil
+	return nil
+}
+
+// markChanged mark the node path as changed, to be written by Flush.
+func (mfs *MemFS) markChanged(nodePath string) {
+	if mfs.changes == nil {
+		mfs.changes = make(map[string]struct{})
+	}
+	mfs.changes[nodePath] = struct{}{}
+}
+
+// isChanged return true if the node path has been changed in memory but
+// has not been flushed.
+func (mfs *MemFS) isChanged(nodePath string) (ok bool) {
+	mfs.mtxWrite.Lock()
+	_, ok = mfs.changes[nodePath]
+	mfs.mtxWrite.Unlock()
+	return ok
+}
@@ -0,0 +1,433 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestMemFS_write(t *testing.T) {
+	var (
+		mfs = &MemFS{}
+
+		node *Node
+		err  error
+	)
+
+	// Build the fixture tree in memory.
+
+	_, err = mfs.MkdirAll(`/a/b`, 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mfs.WriteFile(`/a/b/c.txt`, []byte(`hello`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err = mfs.Mkdir(`/d`, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Mkdir mode`, fs.ModeDir|0700, node.Mode())
+
+	test.Assert(t, `ListNames`,
+		[]string{`/`, `/a`, `/a/b`, `/a/b/c.txt`, `/d`},
+		mfs.ListNames())
+
+	err = fstest.TestFS(mfs, `a/b/c.txt`, `d`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		desc   string
+		op     func() error
+		expErr error
+	}
+
+	var cases = []testCase{{
+		desc: `Mkdir on existing`,
+		op: func() (err error) {
+			_, err = mfs.Mkdir(`/a`, 0700)
+			return err
+		},
+		expErr: fs.ErrExist,
+	}, {
+		desc: `Mkdir without parent`,
+		op: func() (err error) {
+			_, err = mfs.Mkdir(`/x/y`, 0700)
+			return err
+		},
+		expErr: fs.ErrNotExist,
+	}, {
+		desc: `MkdirAll on file`,
+		op: func() (err error) {
+			_, err = mfs.MkdirAll(`/a/b/c.txt/d`, 0700)
+			return err
+		},
+		expErr: errNotDir,
+	}, {
+		desc: `WriteFile on directory`,
+		op: func() (err error) {
+			_, err = mfs.WriteFile(`/a`, nil, 0600)
+			return err
+		},
+		expErr: errIsDir,
+	}, {
+		desc: `WriteFile without parent`,
+		op: func() (err error) {
+			_, err = mfs.WriteFile(`/x/y.txt`, nil, 0600)
+			return err
+		},
+		expErr: fs.ErrNotExist,
+	}, {
+		desc: `Remove not empty`,
+		op: func() error {
+			return mfs.Remove(`/a`)
+		},
+		expErr: errNotEmpty,
+	}, {
+		desc: `Remove root`,
+		op: func() error {
+			return mfs.Remove(`/`)
+		},
+		expErr: fs.ErrInvalid,
+	}, {
+		desc: `Rename into its child`,
+		op: func() error {
+			return mfs.Rename(`/a`, `/a/b/a`)
+		},
+		expErr: fs.ErrInvalid,
+	}, {
+		desc: `Rename into not empty directory`,
+		op: func() error {
+			return mfs.Rename(`/d`, `/a`)
+		},
+		expErr: fs.ErrExist,
+	}}
+
+	var c testCase
+	for _, c = range cases {
+		err = c.op()
+		test.Assert(t, c.desc, true, errors.Is(err, c.expErr))
+	}
+
+	// Rename the directory.
+
+	err = mfs.Rename(`/a/b`, `/d/e`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Rename`,
+		[]string{`/`, `/a`, `/d`, `/d/e`, `/d/e/c.txt`},
+		mfs.ListNames())
+
+	var content []byte
+
+	content, err = mfs.ReadFile(`d/e/c.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `ReadFile after Rename`, `hello`, string(content))
+
+	// Replace the file using Rename.
+
+	_, err = mfs.WriteFile(`/a/new.txt`, []byte(`new`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mfs.Rename(`/a/new.txt`, `/d/e/c.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err = mfs.ReadFile(`d/e/c.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `ReadFile after replaced`, `new`, string(content))
+
+	// Remove.
+
+	err = mfs.Remove(`/a`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mfs.RemoveAll(`/d`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mfs.RemoveAll(`/d`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Remove`, []string{`/`}, mfs.ListNames())
+
+	err = mfs.Flush()
+	test.Assert(t, `Flush without Root`, `Flush: empty Options.Root`, err.Error())
+}
+
+func TestNewOverlay(t *testing.T) {
+	var (
+		dir = t.TempDir()
+
+		base *MemFS
+		err  error
+	)
+
+	err = os.MkdirAll(filepath.Join(dir, `sub`), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, `sub`, `a.txt`), []byte(`a`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The content of large.txt is not mapped to memory.
+	err = os.WriteFile(filepath.Join(dir, `large.txt`), []byte(`large content`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, err = New(&Options{
+		Root:        dir,
+		MaxFileSize: 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mfs *MemFS
+
+	mfs, err = NewOverlay(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = mfs.WriteFile(`/sub/a.txt`, []byte(`modified`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mfs.WriteFile(`/b.txt`, []byte(`b`), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mfs.Rename(`/large.txt`, `/sub/large.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mfs.Rename(`/sub`, `/renamed`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The base should not changes.
+
+	test.Assert(t, `base`,
+		[]string{`/`, `/large.txt`, `/sub`, `/sub/a.txt`},
+		base.ListNames())
+	test.Assert(t, `base content`, `a`,
+		string(base.MustGet(`/sub/a.txt`).Content))
+	test.Assert(t, `overlay`,
+		[]string{`/`, `/b.txt`, `/renamed`, `/renamed/a.txt`, `/renamed/large.txt`},
+		mfs.ListNames())
+
+	// Flush the changes to disk.
+
+	err = mfs.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		exp = map[string]string{
+			`b.txt`:                               `b`,
+			filepath.Join(`renamed`, `a.txt`):     `modified`,
+			filepath.Join(`renamed`, `large.txt`): `large content`,
+		}
+		got = map[string]string{}
+	)
+	err = filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		var content []byte
+		content, err = os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		name, _ = filepath.Rel(dir, name)
+		got[name] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Flush`, exp, got)
+
+	var fi os.FileInfo
+
+	fi, err = os.Stat(filepath.Join(dir, `b.txt`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Flush mode`, fs.FileMode(0640), fi.Mode())
+	test.Assert(t, `changes after Flush`, 0, len(mfs.changes))
+}
+
+func TestMemFS_WriteFile_concurrent(t *testing.T) {
+	var (
+		mfs = &MemFS{}
+		exp = map[string]bool{
+			`first`:  true,
+			`second`: true,
+		}
+
+		wg  sync.WaitGroup
+		err error
+	)
+
+	_, err = mfs.WriteFile(`/a.txt`, []byte(`first`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		var (
+			content = [][]byte{[]byte(`second`), []byte(`first`)}
+			errw    error
+			x       int
+		)
+		for x = 0; x < 100; x++ {
+			_, errw = mfs.WriteFile(`/a.txt`, content[x%2], 0600)
+			if errw != nil {
+				t.Error(errw)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var (
+			got  []byte
+			errr error
+			x    int
+		)
+		for x = 0; x < 100; x++ {
+			got, errr = fs.ReadFile(mfs, `a.txt`)
+			if errr != nil {
+				t.Error(errr)
+				return
+			}
+			if !exp[string(got)] {
+				t.Errorf(`ReadFile: got %q`, got)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var (
+			node *Node
+			errg error
+			x    int
+		)
+		for x = 0; x < 100; x++ {
+			node, errg = mfs.Get(`/a.txt`)
+			if errg != nil {
+				t.Error(errg)
+				return
+			}
+			if int64(len(node.Content)) != node.Size() || !exp[string(node.Content)] {
+				t.Errorf(`Get: got %q with size %d`, node.Content, node.Size())
+				return
+			}
+			_ = node.ContentType
+			_ = node.ModTime()
+		}
+	}()
+	wg.Wait()
+
+	var node = mfs.MustGet(`/a.txt`)
+	test.Assert(t, `Content`, `first`, string(node.Content))
+	test.Assert(t, `Parent child`, node, mfs.Root.Child(`a.txt`))
+}
+
+func TestMemFS_Rename_concurrent(t *testing.T) {
+	var (
+		mfs = &MemFS{}
+
+		wg  sync.WaitGroup
+		err error
+	)
+
+	_, err = mfs.Mkdir(`/d`, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mfs.WriteFile(`/d/a.txt`, []byte(`a`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		dir  = mfs.MustGet(`/d`)
+		file = mfs.MustGet(`/d/a.txt`)
+	)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		var (
+			errw error
+			x    int
+		)
+		for x = 0; x < 100; x++ {
+			errw = mfs.Rename(`/d`, `/e`)
+			if errw == nil {
+				errw = mfs.Rename(`/e`, `/d`)
+			}
+			if errw != nil {
+				t.Error(errw)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var x int
+		for x = 0; x < 100; x++ {
+			if dir.Path != `/d` || dir.Name() != `d` || len(dir.Childs) != 1 {
+				t.Errorf(`dir: got %s with %d childs`, dir.Path, len(dir.Childs))
+				return
+			}
+			if file.Path != `/d/a.txt` || dir.Childs[0] != file {
+				t.Errorf(`file: got %s`, file.Path)
+				return
+			}
+			_ = dir.ModTime()
+			_ = file.SysPath
+		}
+	}()
+	wg.Wait()
+
+	var got = mfs.MustGet(`/d/a.txt`)
+	test.Assert(t, `Content`, `a`, string(got.Content))
+	test.Assert(t, `Renamed node`, false, got == file)
+	test.Assert(t, `Parent child`, got, mfs.MustGet(`/d`).Child(`a.txt`))
+	test.Assert(t, `Root childs`, 1, len(mfs.Root.Childs))
+}
@@ -0,0 +1,147 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// MarshalSearchIndex return the search index as JSON.
+// It will return an error if the MemFS is not indexed, the Options Search
+// is not set.
+func (mfs *MemFS) MarshalSearchIndex() (data []byte, err error) {
+	var logp = `MarshalSearchIndex`
+
+	mfs.initSearchIndex()
+	if mfs.searchIdx == nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, errNotIndexed)
+	}
+
+	data, err = mfs.searchIdx.marshal()
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return data, nil
+}
+
+// UnmarshalSearchIndex load the search index from data, the output of
+// MarshalSearchIndex.
+// If the Options Search is nil, it will be set with default value.
+//
+// This method is used by GoEmbed to load the embedded index without
+// re-indexing all files on Init.
+func (mfs *MemFS) UnmarshalSearchIndex(data []byte) (err error) {
+	var (
+		logp = `UnmarshalSearchIndex`
+		sid  searchIndexData
+	)
+
+	err = json.Unmarshal(data, &sid)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	if mfs.Opts == nil {
+		mfs.Opts = &Options{}
+	}
+	if mfs.Opts.Search == nil {
+		mfs.Opts.Search = &SearchOptions{}
+	}
+
+	mfs.searchIdx = newSearchIndexData(mfs.Opts.Search, &sid)
+
+	return nil
+}
+
+// initSearchIndex create the search index from all nodes, only if the
+// Options Search is set and the index has not been created.
+func (mfs *MemFS) initSearchIndex() {
+	if mfs.Opts == nil || mfs.Opts.Search == nil || mfs.searchIdx != nil {
+		return
+	}
+
+	mfs.searchIdx = newSearchIndex(mfs.Opts.Search)
+	if mfs.PathNodes == nil {
+		return
+	}
+
+	var node *Node
+	for _, node = range mfs.PathNodes.Nodes() {
+		mfs.searchIdx.add(node)
+	}
+}
+
+// indexNode add or update the node in the search index.
+func (mfs *MemFS) indexNode(node *Node) {
+	if mfs.searchIdx == nil || node == nil {
+		return
+	}
+	mfs.searchIdx.add(node)
+}
+
+// unindexNode remove the node from the search index.
+func (mfs *MemFS) unindexNode(node *Node) {
+	if mfs.searchIdx == nil || node == nil {
+		return
+	}
+	mfs.searchIdx.delete(node.Path)
+}
+
+// searchIndexed search the words using index.
+func (mfs *MemFS) searchIndexed(words []string, snippetLen int) (results []SearchResult) {
+	var (
+		idx     = mfs.searchIdx
+		queries [][]string
+
+		word  string
+		token searchToken
+	)
+
+	for _, word = range words {
+		var terms []string
+		for _, token = range idx.tokenize([]byte(word)) {
+			terms = append(terms, token.term)
+		}
+		if len(terms) > 0 {
+			queries = append(queries, terms)
+		}
+	}
+	if len(queries) == 0 {
+		return nil
+	}
+
+	var (
+		scores, matches = idx.search(queries)
+
+		path  string
+		score float64
+		node  *Node
+	)
+
+	for path, score = range scores {
+		var result = SearchResult{
+			Path:  path,
+			Score: score,
+		}
+
+		node = mfs.PathNodes.Get(path)
+		if node != nil {
+			result.Snippets, result.Highlights = idx.snippets(searchText(node), matches[path], snippetLen)
+		}
+
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(x, y int) bool {
+		if results[x].Score == results[y].Score {
+			return results[x].Path < results[y].Path
+		}
+		return results[x].Score > results[y].Score
+	})
+
+	return results
+}
@@ -0,0 +1,429 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	libhtml "github.com/shuLhan/share/lib/net/html"
+)
+
+// List of BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// searchToken define the word in text, after converted to lower cases and
+// stemmed, and its position in text.
+type searchToken struct {
+	term  string
+	start int
+	end   int
+}
+
+// searchMatch define the first and last token position of matched
+// query in document.
+type searchMatch struct {
+	first int
+	last  int
+}
+
+// searchIndexData is the serialized form of searchIndex.
+type searchIndexData struct {
+	// Docs contains the number of tokens in each document, with
+	// Node.Path as key.
+	Docs map[string]int `json:"docs"`
+
+	// Postings contains the positions of term in each document.
+	Postings map[string]map[string][]int `json:"postings"`
+}
+
+// searchIndex is an inverted index of words in the content of files.
+type searchIndex struct {
+	opts *SearchOptions
+
+	// docs contains the number of tokens in each document.
+	docs map[string]int
+
+	// postings contains the positions of term in each document.
+	postings map[string]map[string][]int
+
+	// terms contains the list of unique terms in each document, to
+	// remove the document from postings.
+	terms map[string][]string
+
+	// totalLen is the total number of tokens in all documents.
+	totalLen int
+
+	sync.RWMutex
+}
+
+func newSearchIndex(opts *SearchOptions) (idx *searchIndex) {
+	if opts == nil {
+		opts = &SearchOptions{}
+	}
+	idx = &searchIndex{
+		opts:     opts,
+		docs:     make(map[string]int),
+		postings: make(map[string]map[string][]int),
+		terms:    make(map[string][]string),
+	}
+	return idx
+}
+
+// newSearchIndexData create searchIndex from its serialized form.
+func newSearchIndexData(opts *SearchOptions, data *searchIndexData) (idx *searchIndex) {
+	idx = newSearchIndex(opts)
+
+	var (
+		term   string
+		path   string
+		n      int
+		postin map[string][]int
+	)
+	for path, n = range data.Docs {
+		idx.docs[path] = n
+		idx.totalLen += n
+	}
+	for term, postin = range data.Postings {
+		idx.postings[term] = postin
+		for path = range postin {
+			idx.terms[path] = append(idx.terms[path], term)
+		}
+	}
+	return idx
+}
+
+// searchText return the plain text of node content to be indexed.
+// It will return nil if the node is not indexable.
+func searchText(node *Node) (text []byte) {
+	if node.IsDir() || len(node.Content) == 0 {
+		return nil
+	}
+	if !strings.HasPrefix(node.ContentType, `text/`) {
+		return nil
+	}
+	if strings.HasPrefix(node.ContentType, `text/html`) {
+		return libhtml.Sanitize(node.Content)
+	}
+	return node.Content
+}
+
+// tokenize split the text into words, the sequence of letters and digits.
+func (idx *searchIndex) tokenize(text []byte) (tokens []searchToken) {
+	var (
+		start = -1
+
+		r    rune
+		x    int
+		size int
+	)
+	for x < len(text) {
+		r, size = utf8.DecodeRune(text[x:])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = x
+			}
+		} else if start >= 0 {
+			tokens = append(tokens, idx.newToken(text, start, x))
+			start = -1
+		}
+		x += size
+	}
+	if start >= 0 {
+		tokens = append(tokens, idx.newToken(text, start, x))
+	}
+	return tokens
+}
+
+func (idx *searchIndex) newToken(text []byte, start, end int) (token searchToken) {
+	token = searchToken{
+		term:  strings.ToLower(string(text[start:end])),
+		start: start,
+		end:   end,
+	}
+	if idx.opts.Stem != nil {
+		var stem = idx.opts.Stem(token.term)
+		if len(stem) > 0 {
+			token.term = stem
+		}
+	}
+	return token
+}
+
+// add or replace the node in the index.
+func (idx *searchIndex) add(node *Node) {
+	var tokens = idx.tokenize(searchText(node))
+
+	idx.Lock()
+	defer idx.Unlock()
+
+	idx.remove(node.Path)
+	if len(tokens) == 0 {
+		return
+	}
+
+	var (
+		token   searchToken
+		postin  map[string][]int
+		pos     int
+		isExist bool
+	)
+	for pos, token = range tokens {
+		postin = idx.postings[token.term]
+		if postin == nil {
+			postin = make(map[string][]int)
+			idx.postings[token.term] = postin
+		}
+		_, isExist = postin[node.Path]
+		if !isExist {
+			idx.terms[node.Path] = append(idx.terms[node.Path], token.term)
+		}
+		postin[node.Path] = append(postin[node.Path], pos)
+	}
+	idx.docs[node.Path] = len(tokens)
+	idx.totalLen += len(tokens)
+}
+
+// delete the document from the index.
+func (idx *searchIndex) delete(path string) {
+	idx.Lock()
+	idx.remove(path)
+	idx.Unlock()
+}
+
+// remove the document from the index, without lock.
+func (idx *searchIndex) remove(path string) {
+	var n, ok = idx.docs[path]
+	if !ok {
+		return
+	}
+
+	var (
+		term   string
+		postin map[string][]int
+	)
+	for _, term = range idx.terms[path] {
+		postin = idx.postings[term]
+		delete(postin, path)
+		if len(postin) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.terms, path)
+	delete(idx.docs, path)
+	idx.totalLen -= n
+}
+
+// marshal the index into JSON.
+func (idx *searchIndex) marshal() (out []byte, err error) {
+	idx.RLock()
+	var data = searchIndexData{
+		Docs:     idx.docs,
+		Postings: idx.postings,
+	}
+	out, err = json.Marshal(&data)
+	idx.RUnlock()
+
+	return out, err
+}
+
+// occurrences return the start position of terms in each document.
+// If terms contains more than one term, its treated as phrase, where the
+// terms must appear consecutively.
+func (idx *searchIndex) occurrences(terms []string) (occs map[string][]int) {
+	var first = idx.postings[terms[0]]
+	if len(terms) == 1 {
+		return first
+	}
+
+	var (
+		path   string
+		starts []int
+		start  int
+		x      int
+		found  bool
+	)
+
+	occs = make(map[string][]int)
+	for path, starts = range first {
+		for _, start = range starts {
+			found = true
+			for x = 1; x < len(terms); x++ {
+				if !hasPosition(idx.postings[terms[x]][path], start+x) {
+					found = false
+					break
+				}
+			}
+			if found {
+				occs[path] = append(occs[path], start)
+			}
+		}
+	}
+	return occs
+}
+
+// hasPosition return true if the sorted list of positions contains pos.
+func hasPosition(positions []int, pos int) bool {
+	var x = sort.SearchInts(positions, pos)
+	return x < len(positions) && positions[x] == pos
+}
+
+// search the queries in the index and rank the documents using BM25.
+// Each query is a list of terms, where more than one term is treated as
+// phrase.
+// It will return the score and the position of matched tokens in each
+// document.
+func (idx *searchIndex) search(queries [][]string) (scores map[string]float64, matches map[string][]searchMatch) {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	var ndocs = float64(len(idx.docs))
+	if ndocs == 0 {
+		return nil, nil
+	}
+
+	var (
+		avgLen = float64(idx.totalLen) / ndocs
+
+		terms  []string
+		occs   map[string][]int
+		path   string
+		starts []int
+		start  int
+		idf    float64
+		tf     float64
+		docLen float64
+	)
+
+	scores = make(map[string]float64)
+	matches = make(map[string][]searchMatch)
+
+	for _, terms = range queries {
+		occs = idx.occurrences(terms)
+		if len(occs) == 0 {
+			continue
+		}
+
+		idf = math.Log(1 + (ndocs-float64(len(occs))+0.5)/(float64(len(occs))+0.5))
+
+		for path, starts = range occs {
+			tf = float64(len(starts))
+			docLen = float64(idx.docs[path])
+
+			scores[path] += idf * (tf * (bm25K1 + 1)) /
+				(tf + bm25K1*(1-bm25B+bm25B*docLen/avgLen))
+
+			for _, start = range starts {
+				matches[path] = append(matches[path], searchMatch{
+					first: start,
+					last:  start + len(terms) - 1,
+				})
+			}
+		}
+	}
+	return scores, matches
+}
+
+// snippets create the list of text around the matches, with length of
+// snippetLen before and after the matches.
+// The nearby matches are merged into one snippet.
+func (idx *searchIndex) snippets(text []byte, matches []searchMatch, snippetLen int) (snippets []string, highlights [][][]int) {
+	var (
+		tokens = idx.tokenize(text)
+		ranges = make([][]int, 0, len(matches))
+
+		m searchMatch
+	)
+	for _, m = range matches {
+		if m.last >= len(tokens) {
+			// The content has been changed after indexed.
+			continue
+		}
+		ranges = append(ranges, []int{tokens[m.first].start, tokens[m.last].end})
+	}
+	if len(ranges) == 0 {
+		return nil, nil
+	}
+	sort.Slice(ranges, func(x, y int) bool {
+		return ranges[x][0] < ranges[y][0]
+	})
+
+	var (
+		start     = -1
+		end       int
+		hl        [][]int
+		rangeText []int
+	)
+	for _, rangeText = range ranges {
+		var (
+			rstart = snippetStart(text, rangeText[0]-snippetLen)
+			rend   = snippetEnd(text, rangeText[1]+snippetLen)
+		)
+		if start >= 0 && rstart <= end {
+			// Merge with the previous snippet.
+			if rend > end {
+				end = rend
+			}
+			hl = appendHighlight(hl, rangeText[0]-start, rangeText[1]-start)
+			continue
+		}
+		if start >= 0 {
+			snippets = append(snippets, string(text[start:end]))
+			highlights = append(highlights, hl)
+		}
+		start = rstart
+		end = rend
+		hl = [][]int{{rangeText[0] - start, rangeText[1] - start}}
+	}
+	snippets = append(snippets, string(text[start:end]))
+	highlights = append(highlights, hl)
+
+	return snippets, highlights
+}
+
+// appendHighlight append the highlight range into list, merging it with
+// the last one if they are overlap.
+func appendHighlight(list [][]int, start, end int) [][]int {
+	var last = list[len(list)-1]
+	if start <= last[1] {
+		if end > last[1] {
+			last[1] = end
+		}
+		return list
+	}
+	return append(list, []int{start, end})
+}
+
+// snippetStart return the start of snippet at x, moved forward to the
+// start of UTF-8 character.
+func snippetStart(text []byte, x int) int {
+	if x <= 0 {
+		return 0
+	}
+	for x < len(text) && !utf8.RuneStart(text[x]) {
+		x++
+	}
+	return x
+}
+
+// snippetEnd return the end of snippet at x, moved backward to the start
+// of UTF-8 character.
+func snippetEnd(text []byte, x int) int {
+	if x >= len(text) {
+		return len(text)
+	}
+	for x > 0 && !utf8.RuneStart(text[x]) {
+		x--
+	}
+	return x
+}
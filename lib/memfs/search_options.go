@@ -0,0 +1,31 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+// SearchOptions define the options for indexing the content of files for
+// MemFS Search method.
+//
+// Only file with content type "text/*" and its content mapped in memory
+// are indexed.
+// The HTML file is indexed as plain text, without tags.
+type SearchOptions struct {
+	// Stem define an optional function that return the stem of word.
+	// The word passed to Stem is in lower cases.
+	// If Stem return empty string, the word is indexed as is.
+	//
+	// For example, using the lib/hunspell,
+	//
+	//	Stem: func(word string) string {
+	//		var stem = spell.Stem(word)
+	//		if stem == nil {
+	//			return ``
+	//		}
+	//		return stem.Word
+	//	}
+	//
+	// The same Stem function must be set when the index is loaded
+	// from GoEmbed, since the function cannot be embedded.
+	Stem func(word string) string `json:"-"`
+}
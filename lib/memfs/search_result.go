@@ -1,9 +1,53 @@
 package memfs
 
+import "strings"
+
 // SearchResult contains the result of searching where the Path will be
 // filled with absolute path of file system in memory and the Snippet will
 // filled with part of the text before and after the search string.
 type SearchResult struct {
 	Path     string
 	Snippets []string
+
+	// Highlights contains the start and end index of matched words in
+	// each Snippets, in the same format as
+	// [regexp.Regexp.FindAllStringIndex].
+	// The Highlights[x] is for Snippets[x].
+	// This field only set if the MemFS is indexed.
+	Highlights [][][]int
+
+	// Score of the result ranked using BM25.
+	// This field only set if the MemFS is indexed.
+	Score float64
+}
+
+// Highlight return the Snippets with each matched words enclosed by pre
+// and post, for example "<mark>" and "</mark>".
+// If the Highlights is empty, it will return the Snippets as is.
+func (result *SearchResult) Highlight(pre, post string) (snippets []string) {
+	if len(result.Highlights) != len(result.Snippets) {
+		return result.Snippets
+	}
+
+	var (
+		sb      strings.Builder
+		snippet string
+		hl      []int
+		x       int
+		last    int
+	)
+	for x, snippet = range result.Snippets {
+		sb.Reset()
+		last = 0
+		for _, hl = range result.Highlights[x] {
+			sb.WriteString(snippet[last:hl[0]])
+			sb.WriteString(pre)
+			sb.WriteString(snippet[hl[0]:hl[1]])
+			sb.WriteString(post)
+			last = hl[1]
+		}
+		sb.WriteString(snippet[last:])
+		snippets = append(snippets, sb.String())
+	}
+	return snippets
 }
@@ -0,0 +1,245 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package memfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+// newTestSearchFS create MemFS with search index on temporary directory.
+func newTestSearchFS(t *testing.T) (mfs *MemFS) {
+	var (
+		dir   = t.TempDir()
+		files = map[string]string{
+			`a.txt`:     `The quick brown fox jumps over the lazy dog.`,
+			`b.txt`:     `A fox, a fox, and another fox in the forest.`,
+			`c.html`:    `<html><body><p>The brown dogs sleep.</p></body></html>`,
+			`image.png`: "\x89PNG fox",
+		}
+
+		name    string
+		content string
+		err     error
+	)
+	for name, content = range files {
+		err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mfs, err = New(&Options{
+		Root: dir,
+		Search: &SearchOptions{
+			// Simple stemmer that remove the plural suffix.
+			Stem: func(word string) string {
+				return strings.TrimSuffix(word, `s`)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mfs
+}
+
+// searchPaths return the path of each result, in order.
+func searchPaths(results []SearchResult) (paths []string) {
+	var result SearchResult
+	for _, result = range results {
+		paths = append(paths, result.Path)
+	}
+	return paths
+}
+
+func TestMemFS_Search_index(t *testing.T) {
+	type testCase struct {
+		desc      string
+		words     []string
+		expPaths  []string
+		expHlight []string
+	}
+
+	var mfs = newTestSearchFS(t)
+
+	var cases = []testCase{{
+		desc:     `ranked by BM25`,
+		words:    []string{`fox`},
+		expPaths: []string{`/b.txt`, `/a.txt`},
+		expHlight: []string{
+			`A [fox], a [fox], and another [fox] in the forest.`,
+		},
+	}, {
+		desc:     `with stemming`,
+		words:    []string{`DOG`},
+		expPaths: []string{`/c.html`, `/a.txt`},
+		expHlight: []string{
+			`The brown [dogs] sleep.`,
+		},
+	}, {
+		desc:     `phrase`,
+		words:    []string{`brown fox`},
+		expPaths: []string{`/a.txt`},
+		expHlight: []string{
+			`The quick [brown fox] jumps over the lazy dog.`,
+		},
+	}, {
+		desc:  `phrase not found`,
+		words: []string{`fox brown`},
+	}, {
+		desc:  `only punctuation`,
+		words: []string{`...`},
+	}}
+
+	var (
+		c       testCase
+		results []SearchResult
+	)
+	for _, c = range cases {
+		results = mfs.Search(c.words, 0)
+		test.Assert(t, c.desc, c.expPaths, searchPaths(results))
+		if len(results) > 0 {
+			test.Assert(t, c.desc+`: highlight`, c.expHlight,
+				results[0].Highlight(`[`, `]`))
+		}
+	}
+}
+
+func TestMemFS_Search_snippets(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		content = `fox ` + strings.Repeat(`word `, 20) + `fox fox`
+		err     error
+	)
+
+	err = os.WriteFile(filepath.Join(dir, `long.txt`), []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mfs *MemFS
+
+	mfs, err = New(&Options{
+		Root:   dir,
+		Search: &SearchOptions{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results = mfs.Search([]string{`fox`}, 10)
+
+	var exp = []SearchResult{{
+		Path:     `/long.txt`,
+		Snippets: []string{`fox word word`, `word word fox fox`},
+		Highlights: [][][]int{
+			{{0, 3}},
+			{{10, 13}, {14, 17}},
+		},
+		Score: results[0].Score,
+	}}
+	test.Assert(t, `Search`, exp, results)
+}
+
+func TestMemFS_SearchIndex_marshal(t *testing.T) {
+	var (
+		mfs = newTestSearchFS(t)
+
+		data []byte
+		err  error
+	)
+
+	data, err = mfs.MarshalSearchIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exp = mfs.Search([]string{`fox`, `brown dog`}, 0)
+
+	// Load the index into MemFS without index, using the same Stem.
+	var got = &MemFS{
+		PathNodes: mfs.PathNodes,
+		Root:      mfs.Root,
+		Opts: &Options{
+			Root: mfs.Opts.Root,
+		},
+	}
+
+	err = got.UnmarshalSearchIndex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Opts.Search.Stem = mfs.Opts.Search.Stem
+
+	err = got.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `Search`, exp, got.Search([]string{`fox`, `brown dog`}, 0))
+
+	_, err = (&MemFS{}).MarshalSearchIndex()
+	test.Assert(t, `MarshalSearchIndex: not indexed`,
+		`MarshalSearchIndex: search index is not enabled`, err.Error())
+}
+
+func TestMemFS_Search_watch(t *testing.T) {
+	var (
+		mfs = newTestSearchFS(t)
+
+		dw  *DirWatcher
+		ns  NodeState
+		err error
+	)
+
+	dw, err = mfs.Watch(WatchOptions{
+		Delay: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mfs.StopWatch)
+
+	// Add delay for modtime to changes.
+	time.Sleep(100 * time.Millisecond)
+
+	var fileNew = filepath.Join(mfs.Opts.Root, `d.txt`)
+
+	err = os.WriteFile(fileNew, []byte(`A lazy fox.`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns = <-dw.C
+	test.Assert(t, `created`, `/d.txt`, ns.Node.Path)
+	test.Assert(t, `Search after created`, []string{`/d.txt`, `/a.txt`},
+		searchPaths(mfs.Search([]string{`lazy`}, 0)))
+
+	// Add delay for modtime to changes.
+	time.Sleep(100 * time.Millisecond)
+
+	err = os.WriteFile(fileNew, []byte(`A sleepy cat.`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns = <-dw.C
+	test.Assert(t, `updated`, FileStateUpdateContent, ns.State)
+	test.Assert(t, `Search after updated`, []string{`/a.txt`},
+		searchPaths(mfs.Search([]string{`lazy`}, 0)))
+
+	err = os.Remove(fileNew)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns = <-dw.C
+	test.Assert(t, `deleted`, FileStateDeleted, ns.State)
+	test.Assert(t, `Search after deleted`, []string(nil),
+		searchPaths(mfs.Search([]string{`cat`}, 0)))
+}
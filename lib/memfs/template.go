@@ -65,6 +65,9 @@ func {{ .Node.GenFuncName}}() *memfs.Node {
 {{- end }}
 	node.SetName("{{.Node.Name}}")
 	node.SetSize({{.Node.Size}})
+{{- if .Node.Content }}
+	node.SetHash([]byte("{{range $x, $c := .Node.Hash}}{{ printf "\\x%02X" $c }}{{end}}"))
+{{- end }}
 	{{- range $x, $child := .Node.Childs}}
 		{{- if $child.GenFuncName}}
 	node.AddChild(_{{$varname}}_getNode({{$varname}}, "{{.Path}}", {{$child.GenFuncName}}))
@@ -107,7 +110,16 @@ func init() {
 				VarName:        "{{.Opts.Embed.VarName}}",
 				GoFileName:     "{{.Opts.Embed.GoFileName}}",
 				WithoutModTime: {{.Opts.Embed.WithoutModTime}},
+{{- if .Opts.Embed.WithSearchIndex}}
+				WithSearchIndex: true,
+{{- end}}
 			},
+{{- if .Opts.Search}}
+			Search: &memfs.SearchOptions{},
+{{- end}}
+{{- if .Opts.Fingerprint}}
+			Fingerprint: true,
+{{- end}}
 		},
 	}
 
@@ -120,6 +132,13 @@ func init() {
 {{- end}}
 
 	{{$varname}}.Root = {{$varname}}.PathNodes.Get("/")
+{{- if .SearchIndex}}
+
+	var errIndex = {{$varname}}.UnmarshalSearchIndex([]byte("{{range $x, $c := .SearchIndex}}{{ printf "\\x%02X" $c }}{{end}}"))
+	if errIndex != nil {
+		panic("{{$varname}}: " + errIndex.Error())
+	}
+{{- end}}
 
 	var err = {{$varname}}.Init()
 	if err != nil {
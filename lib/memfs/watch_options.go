@@ -19,4 +19,9 @@ type WatchOptions struct {
 	// This field set the DirWatcher.Delay returned from Watch().
 	// This field is optional, default is 5 seconds.
 	Delay time.Duration
+
+	// Polling force the DirWatcher to fetch the changes every Delay
+	// instead of using the file system notification.
+	// This field set the DirWatcher.Polling returned from Watch().
+	Polling bool
 }
@@ -19,6 +19,9 @@ const (
 )
 
 // Watcher is a naive implementation of file event change notification.
+//
+// On Linux, the changes are received from inotify, otherwise or if the
+// inotify cannot be used, the file information is fetched every Delay.
 type Watcher struct {
 	C        <-chan NodeState // The channel on which the changes are delivered.
 	qchanges chan NodeState
@@ -28,6 +31,10 @@ type Watcher struct {
 	node   *Node
 	ticker *time.Ticker
 
+	// notify is the file system notification backend.
+	// If its nil, the Watcher use polling.
+	notify *notifier
+
 	// Delay define a duration when the new changes will be fetched from
 	// system.
 	// This field is optional, minimum is 100 millisecond and default is
@@ -68,12 +75,22 @@ func NewWatcher(path string, d time.Duration) (w *Watcher, err error) {
 	}
 	dummyParent.Path = dummyParent.SysPath
 
-	return newWatcher(dummyParent, fi, d, nil)
+	var notify *notifier
+
+	notify, err = newNotifier()
+	if err != nil {
+		// Fallback to polling.
+		notify = nil
+	}
+
+	return newWatcher(dummyParent, fi, d, nil, notify)
 }
 
 // newWatcher create and initialize new Watcher like NewWatcher but using
 // parent node.
-func newWatcher(parent *Node, fi os.FileInfo, d time.Duration, qchanges chan NodeState) (
+// If notify is nil or the file cannot be watched by notify, the Watcher
+// will use polling.
+func newWatcher(parent *Node, fi os.FileInfo, d time.Duration, qchanges chan NodeState, notify *notifier) (
 	w *Watcher, err error,
 ) {
 	var (
@@ -95,7 +112,6 @@ func newWatcher(parent *Node, fi os.FileInfo, d time.Duration, qchanges chan Nod
 	w = &Watcher{
 		qchanges: qchanges,
 		delay:    d,
-		ticker:   time.NewTicker(d),
 		done:     make(chan struct{}),
 		node:     node,
 	}
@@ -103,6 +119,17 @@ func newWatcher(parent *Node, fi os.FileInfo, d time.Duration, qchanges chan Nod
 		w.qchanges = make(chan NodeState, watcherQueueSize)
 		w.C = w.qchanges
 	}
+	if notify != nil {
+		err = notify.watch(node.SysPath, node.Path)
+		if err != nil {
+			notify.close()
+		} else {
+			w.notify = notify
+		}
+	}
+	if w.notify == nil {
+		w.ticker = time.NewTicker(d)
+	}
 
 	go w.start()
 
@@ -112,76 +139,136 @@ func newWatcher(parent *Node, fi os.FileInfo, d time.Duration, qchanges chan Nod
 // start fetching new file information every tick.
 // This method run as goroutine and will finish when the file is deleted.
 func (w *Watcher) start() {
-	var (
-		logp = "Watcher"
-		ever = true
+	if w.notify != nil {
+		w.startNotify()
+		return
+	}
+
+	var ever = true
 
-		newInfo fs.FileInfo
-		ns      NodeState
-		err     error
-	)
 	for ever {
 		select {
 		case <-w.ticker.C:
-			newInfo, err = os.Stat(w.node.SysPath)
-			if err != nil {
-				if !os.IsNotExist(err) {
-					log.Printf("%s: %s: %s", logp, w.node.SysPath, err)
-					continue
-				}
-
-				ns.Node = *w.node
-				ns.State = FileStateDeleted
-
-				select {
-				case w.qchanges <- ns:
-				default:
-				}
+			if w.check() {
 				ever = false
 				w.ticker.Stop()
-				continue
 			}
+		case <-w.done:
+			ever = false
+			w.ticker.Stop()
+			w.done <- struct{}{}
+		}
+	}
+}
 
-			if w.node.Mode() != newInfo.Mode() {
-				w.node.SetMode(newInfo.Mode())
+// startNotify wait for the events from notify and check the file
+// information once the bursts of events has been collected.
+// This method run as goroutine and will finish when the file is deleted.
+func (w *Watcher) startNotify() {
+	var (
+		events = w.notify.C
+		timer  = time.NewTimer(notifyDelay)
+
+		timerC <-chan time.Time
+		ok     bool
+	)
 
-				ns.Node = *w.node
-				ns.State = FileStateUpdateMode
+	timer.Stop()
 
-				select {
-				case w.qchanges <- ns:
-				default:
-				}
+	for {
+		select {
+		case _, ok = <-events:
+			if !ok {
+				events = nil
 				continue
 			}
-			if w.node.ModTime().Equal(newInfo.ModTime()) {
-				continue
+			if timerC == nil {
+				timer.Reset(notifyDelay)
+				timerC = timer.C
 			}
 
-			w.node.SetModTime(newInfo.ModTime())
-			w.node.SetSize(newInfo.Size())
-
-			ns.Node = *w.node
-			ns.State = FileStateUpdateContent
-
-			select {
-			case w.qchanges <- ns:
-			default:
+		case <-timerC:
+			timerC = nil
+			if w.check() {
+				w.notify.close()
+				return
 			}
+			// The file may have been replaced with new one, for
+			// example by renaming other file into it, so we
+			// re-watch the path to follow the new file.
+			_ = w.notify.watch(w.node.SysPath, w.node.Path)
+
 		case <-w.done:
-			ever = false
-			w.ticker.Stop()
+			timer.Stop()
+			w.notify.close()
 			w.done <- struct{}{}
+			return
 		}
 	}
 }
 
+// check fetch the new file information and publish the changes.
+// It will return true if the file has been deleted.
+func (w *Watcher) check() (deleted bool) {
+	var (
+		logp = "Watcher"
+
+		newInfo fs.FileInfo
+		ns      NodeState
+		err     error
+	)
+
+	newInfo, err = os.Stat(w.node.SysPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("%s: %s: %s", logp, w.node.SysPath, err)
+			return false
+		}
+
+		ns.Node = *w.node
+		ns.State = FileStateDeleted
+
+		select {
+		case w.qchanges <- ns:
+		default:
+		}
+		return true
+	}
+
+	if w.node.Mode() != newInfo.Mode() {
+		w.node.SetMode(newInfo.Mode())
+
+		ns.Node = *w.node
+		ns.State = FileStateUpdateMode
+
+		select {
+		case w.qchanges <- ns:
+		default:
+		}
+	}
+	if w.node.ModTime().Equal(newInfo.ModTime()) {
+		return false
+	}
+
+	w.node.SetModTime(newInfo.ModTime())
+	w.node.SetSize(newInfo.Size())
+
+	ns.Node = *w.node
+	ns.State = FileStateUpdateContent
+
+	select {
+	case w.qchanges <- ns:
+	default:
+	}
+	return false
+}
+
 // Stop watching the file.
 func (w *Watcher) Stop() {
 	select {
 	case w.done <- struct{}{}:
 		<-w.done
 	default:
-		// Ticker has been stopped due to file being deleted.
+		// The watcher has been stopped due to file being deleted.
 	}
 }
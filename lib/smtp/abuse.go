@@ -0,0 +1,194 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	liberrors "github.com/shuLhan/share/lib/errors"
+)
+
+const (
+	// defMaxRecipients define the default maximum recipients per mail
+	// transaction, as recommended by RFC 5321 section 4.5.3.1.8.
+	defMaxRecipients = 100
+)
+
+// AbusePolicy define the limits and checks to protect the server from
+// abusive clients.
+//
+// Each field is optional, the zero value disable the rule.
+type AbusePolicy struct {
+	// Greylist define the triplet based greylisting for mail on port
+	// 25.
+	Greylist *Greylist
+
+	// DNSBL contains list of DNS blocklist zones, for example
+	// "zen.spamhaus.org".
+	// Client on port 25 that listed in one of the zones is rejected
+	// before the greeting.
+	DNSBL []string
+
+	// MaxConnPerIP define the maximum concurrent connections from the
+	// same IP address.
+	MaxConnPerIP int
+
+	// MaxCommandsPerMinute define the maximum commands that client can
+	// send in one minute.
+	MaxCommandsPerMinute int
+
+	// MaxRecipients define the maximum recipients per mail
+	// transaction.
+	// If its zero, it will default to 100.
+	MaxRecipients int
+
+	// EarlyTalkerDelay define the duration to wait before sending the
+	// greeting to client on port 25.
+	// Client that send any data before the greeting is rejected.
+	EarlyTalkerDelay time.Duration
+}
+
+// maxRecipients return the maximum recipients per mail transaction.
+func (abuse *AbusePolicy) maxRecipients() int {
+	if abuse == nil || abuse.MaxRecipients <= 0 {
+		return defMaxRecipients
+	}
+	return abuse.MaxRecipients
+}
+
+// acquireConn increment the number of connections from the client IP
+// address.
+// It will return false if the number of connections has reached the
+// limit.
+func (srv *Server) acquireConn(recv *receiver) bool {
+	if srv.Abuse == nil || srv.Abuse.MaxConnPerIP <= 0 {
+		return true
+	}
+
+	var ip = recv.clientIP().String()
+
+	srv.connMtx.Lock()
+	defer srv.connMtx.Unlock()
+
+	if srv.conns == nil {
+		srv.conns = make(map[string]int)
+	}
+	if srv.conns[ip] >= srv.Abuse.MaxConnPerIP {
+		return false
+	}
+	srv.conns[ip]++
+	return true
+}
+
+// releaseConn decrement the number of connections from the client IP
+// address.
+func (srv *Server) releaseConn(recv *receiver) {
+	if srv.Abuse == nil || srv.Abuse.MaxConnPerIP <= 0 {
+		return
+	}
+
+	var ip = recv.clientIP().String()
+
+	srv.connMtx.Lock()
+	srv.conns[ip]--
+	if srv.conns[ip] <= 0 {
+		delete(srv.conns, ip)
+	}
+	srv.connMtx.Unlock()
+}
+
+// checkClient check the new client on port 25 before the greeting, using
+// the DNSBL and early talker detection.
+func (srv *Server) checkClient(recv *receiver) (err error) {
+	if srv.Abuse == nil || recv.mode != receiverModeServer {
+		return nil
+	}
+
+	var ip = recv.clientIP()
+	for _, zone := range srv.Abuse.DNSBL {
+		if srv.isBlocklisted(ip, zone) {
+			return &liberrors.E{
+				Code:    StatusTransactionFailed,
+				Message: fmt.Sprintf(`5.7.1 Client host [%s] blocked using %s`, ip, zone),
+			}
+		}
+	}
+
+	if srv.Abuse.EarlyTalkerDelay > 0 && recv.isEarlyTalker(srv.Abuse.EarlyTalkerDelay) {
+		return errEarlyTalker
+	}
+	return nil
+}
+
+// checkGreylist check the mail triplet using Greylist.
+// It will return errGreylisted if the triplet is not accepted yet.
+func (srv *Server) checkGreylist(recv *receiver, rcpt string) error {
+	if srv.Abuse == nil || srv.Abuse.Greylist == nil || recv.mode != receiverModeServer {
+		return nil
+	}
+
+	var ok, err = srv.Abuse.Greylist.Check(recv.clientIP(), recv.mail.From, rcpt)
+	if err != nil {
+		log.Printf(`checkGreylist: %s`, err)
+	}
+	if !ok {
+		return errGreylisted
+	}
+	return nil
+}
+
+// isBlocklisted return true if the IP address listed in DNSBL zone (RFC
+// 5782).
+// Any error other than non-existent domain is logged and the IP address
+// is considered not listed.
+func (srv *Server) isBlocklisted(ip net.IP, zone string) bool {
+	var name = reverseIP(ip)
+	if len(name) == 0 {
+		return false
+	}
+
+	var ips, err = srv.Resolver.LookupIP(name + `.` + zone)
+	if err != nil {
+		if !errors.Is(err, errDomainNotFound) {
+			log.Printf(`isBlocklisted: %s: %s`, zone, err)
+		}
+		return false
+	}
+	for _, listed := range ips {
+		// The DNSBL return address in 127.0.0.0/8 for listed IP.
+		if listed.To4() != nil && listed.To4()[0] == 127 {
+			return true
+		}
+	}
+	return false
+}
+
+// reverseIP return the IP address in reversed format, as used in
+// in-addr.arpa and ip6.arpa domain.
+func reverseIP(ip net.IP) string {
+	var (
+		ip4   = ip.To4()
+		parts []string
+	)
+	if ip4 != nil {
+		for x := len(ip4) - 1; x >= 0; x-- {
+			parts = append(parts, strconv.Itoa(int(ip4[x])))
+		}
+		return strings.Join(parts, `.`)
+	}
+	if len(ip) != net.IPv6len {
+		return ``
+	}
+	for x := len(ip) - 1; x >= 0; x-- {
+		parts = append(parts, fmt.Sprintf(`%x.%x`, ip[x]&0x0f, ip[x]>>4))
+	}
+	return strings.Join(parts, `.`)
+}
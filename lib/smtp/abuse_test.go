@@ -0,0 +1,191 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+// testServeAbuse run the server on random port on port 25 mode, using
+// the abuse policy.
+func testServeAbuse(t *testing.T, abuse *AbusePolicy) (addr string) {
+	var (
+		env = &Environment{
+			PrimaryDomain: NewDomain(testDomain, nil),
+		}
+		srv = &Server{
+			Env:     env,
+			Handler: NewLocalHandler(env),
+			Abuse:   abuse,
+			Resolver: &mockResolver{
+				ip: map[string][]net.IP{
+					`1.0.0.127.dnsbl.test`: {net.ParseIP(`127.0.0.2`)},
+				},
+			},
+			running: true,
+		}
+
+		ln  net.Listener
+		err error
+	)
+
+	ln, err = net.Listen(`tcp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	go func() {
+		for {
+			var conn, errAccept = ln.Accept()
+			if errAccept != nil {
+				return
+			}
+			go srv.handle(newReceiver(conn, receiverModeServer))
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestServer_abuse(t *testing.T) {
+	var greylist, err = NewGreylist(``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	greylist.Delay = 0
+
+	type testCase struct {
+		abuse *AbusePolicy
+		desc  string
+
+		// cmds contains the commands to be send to server.
+		// The first command is sent immediately after connected,
+		// before reading the greeting.
+		cmds []string
+		exp  []string
+	}
+
+	var cases = []testCase{{
+		desc: `With client listed in DNSBL`,
+		abuse: &AbusePolicy{
+			DNSBL: []string{`dnsbl.test`},
+		},
+		cmds: []string{``},
+		exp:  []string{`554 5.7.1 Client host [127.0.0.1] blocked using dnsbl.test`},
+	}, {
+		desc: `With early talker`,
+		abuse: &AbusePolicy{
+			EarlyTalkerDelay: 200 * time.Millisecond,
+		},
+		cmds: []string{"EHLO early.test\r\n"},
+		exp:  []string{`554 5.5.0 Protocol violation, data sent before greeting`},
+	}, {
+		desc: `With patient client`,
+		abuse: &AbusePolicy{
+			EarlyTalkerDelay: 200 * time.Millisecond,
+		},
+		cmds: []string{``, "NOOP\r\n"},
+		exp:  []string{`220 ` + testDomain, `250 OK`},
+	}, {
+		desc: `With too many commands`,
+		abuse: &AbusePolicy{
+			MaxCommandsPerMinute: 2,
+		},
+		cmds: []string{``, "NOOP\r\n", "NOOP\r\n", "NOOP\r\n"},
+		exp: []string{
+			`220 ` + testDomain,
+			`250 OK`,
+			`250 OK`,
+			`421 4.7.0 Too many commands, try again later`,
+		},
+	}, {
+		desc: `With greylist and recipients limit`,
+		abuse: &AbusePolicy{
+			Greylist:      greylist,
+			MaxRecipients: 1,
+		},
+		cmds: []string{
+			``,
+			"MAIL FROM:<sender@example.org>\r\n",
+			"RCPT TO:<first@" + testDomain + ">\r\n",
+			"RCPT TO:<first@" + testDomain + ">\r\n",
+			"RCPT TO:<second@" + testDomain + ">\r\n",
+		},
+		exp: []string{
+			`220 ` + testDomain,
+			`250 OK`,
+			`450 4.7.1 Greylisted, please try again later`,
+			`250 OK`,
+			`452 4.5.3 Too many recipients`,
+		},
+	}}
+
+	var (
+		c    testCase
+		conn net.Conn
+		line string
+	)
+	for _, c = range cases {
+		t.Log(c.desc)
+
+		conn, err = net.Dial(`tcp`, testServeAbuse(t, c.abuse))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var (
+			reader = bufio.NewReader(conn)
+			got    []string
+		)
+		for _, cmd := range c.cmds {
+			if len(cmd) > 0 {
+				_, err = conn.Write([]byte(cmd))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			line, err = reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, strings.TrimSpace(line))
+		}
+		_ = conn.Close()
+
+		test.Assert(t, `replies`, c.exp, got)
+	}
+}
+
+func TestServer_acquireConn(t *testing.T) {
+	var (
+		srv = &Server{
+			Abuse: &AbusePolicy{
+				MaxConnPerIP: 2,
+			},
+		}
+		first  = &receiver{clientAddress: `192.0.2.1:1025`}
+		second = &receiver{clientAddress: `192.0.2.1:1026`}
+		third  = &receiver{clientAddress: `192.0.2.1:1027`}
+		other  = &receiver{clientAddress: `192.0.2.2:1025`}
+	)
+
+	test.Assert(t, `first`, true, srv.acquireConn(first))
+	test.Assert(t, `second`, true, srv.acquireConn(second))
+	test.Assert(t, `third`, false, srv.acquireConn(third))
+	test.Assert(t, `other`, true, srv.acquireConn(other))
+
+	srv.releaseConn(first)
+
+	test.Assert(t, `third after release`, true, srv.acquireConn(third))
+}
@@ -5,6 +5,7 @@
 package smtp
 
 import (
+	"crypto/rand"
 	"fmt"
 	"strings"
 
@@ -15,16 +16,23 @@ import (
 // email.
 type Account struct {
 	Mailbox
+	// Scram contains the salted password for SCRAM-SHA-256 mechanism.
+	// This field is nil for system account.
+	Scram *ScramCredential
+
 	// HashPass user password that has been hashed using bcrypt.
 	HashPass string
 }
 
 // NewAccount create new account.
-// Password will be hashed using bcrypt.
+// Password will be hashed using bcrypt and salted for SCRAM-SHA-256.
 // An account with empty password is system account, which mean it will not
 // allowed in SMTP AUTH.
 func NewAccount(name, local, domain, pass string) (acc *Account, err error) {
-	var hpass []byte
+	var (
+		hpass []byte
+		scram *ScramCredential
+	)
 	local = strings.ToLower(local)
 
 	if len(pass) > 0 {
@@ -38,6 +46,13 @@ func NewAccount(name, local, domain, pass string) (acc *Account, err error) {
 			err = fmt.Errorf("smtp: NewAccount: %s", err.Error())
 			return nil, err
 		}
+
+		var salt = make([]byte, 16)
+		_, err = rand.Read(salt)
+		if err != nil {
+			return nil, fmt.Errorf(`smtp: NewAccount: %w`, err)
+		}
+		scram = NewScramCredential(pass, salt, 0)
 	}
 
 	acc = &Account{
@@ -46,6 +61,7 @@ func NewAccount(name, local, domain, pass string) (acc *Account, err error) {
 			Local:  local,
 			Domain: domain,
 		},
+		Scram:    scram,
 		HashPass: string(hpass),
 	}
 
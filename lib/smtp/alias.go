@@ -0,0 +1,239 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/shuLhan/share/lib/ini"
+)
+
+// maxExpandDepth define the maximum depth of nested aliases and mailing
+// lists.
+const maxExpandDepth = 10
+
+// MailingList define the list of members that receive the mail sent to
+// the list Address.
+type MailingList struct {
+	// Address of the mailing list, for example "team@example.com".
+	Address string
+
+	// Owner is the envelope sender of mail distributed to the members,
+	// which receive the bounces.
+	// This field is optional, default to postmaster in the domain of
+	// list Address.
+	Owner string
+
+	// Members contains the address of list members.
+	Members []string
+}
+
+// delivery define the final recipient of mail and its envelope sender,
+// the result of expanding the recipient.
+type delivery struct {
+	from string
+	rcpt string
+}
+
+// LoadAliases load the aliases, catch-alls, mailing lists,
+// plus-addressing, and SRS from ini file.
+// The loaded values are merged into the current environment.
+//
+// The file has the following format,
+//
+//	[smtp]
+//	recipient-delimiter = +
+//
+//	[srs]
+//	domain = example.com
+//	secret = <secret>
+//	max-age = 21
+//
+//	[alias "info@example.com"]
+//	to = alice@example.com
+//	to = bob@example.org
+//
+//	[catch-all "example.com"]
+//	to = alice@example.com
+//
+//	[list "team@example.com"]
+//	owner = alice@example.com
+//	member = alice@example.com
+//	member = bob@example.org
+func (env *Environment) LoadAliases(file string) (err error) {
+	var (
+		logp = `LoadAliases`
+
+		cfg     *ini.Ini
+		content []byte
+	)
+
+	content, err = os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	cfg, err = ini.Parse(content)
+	if err != nil {
+		return fmt.Errorf(`%s: %s: %w`, logp, file, err)
+	}
+
+	var v string
+
+	v, _ = cfg.Get(`smtp`, ``, `recipient-delimiter`, ``)
+	if len(v) > 0 {
+		env.RecipientDelimiter = v
+	}
+
+	v, _ = cfg.Get(`srs`, ``, `secret`, ``)
+	if len(v) > 0 {
+		env.SRS = &SRS{
+			Secret: []byte(v),
+			Domain: cfg.Val(`srs::domain`),
+		}
+		if len(env.SRS.Domain) == 0 && env.PrimaryDomain != nil {
+			env.SRS.Domain = env.PrimaryDomain.Name
+		}
+		v = cfg.Val(`srs::max-age`)
+		if len(v) > 0 {
+			env.SRS.MaxAge, err = strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf(`%s: srs max-age: %w`, logp, err)
+			}
+		}
+	}
+
+	var name string
+
+	for _, sec := range cfg.Subs(`alias`) {
+		if env.Aliases == nil {
+			env.Aliases = map[string][]string{}
+		}
+		name = strings.ToLower(sec.SubName())
+		env.Aliases[name] = append(env.Aliases[name], sec.Vals(`to`)...)
+	}
+	for _, sec := range cfg.Subs(`catch-all`) {
+		if env.CatchAll == nil {
+			env.CatchAll = map[string]string{}
+		}
+		env.CatchAll[strings.ToLower(sec.SubName())] = sec.Val(`to`)
+	}
+	for _, sec := range cfg.Subs(`list`) {
+		if env.Lists == nil {
+			env.Lists = map[string]*MailingList{}
+		}
+		name = strings.ToLower(sec.SubName())
+		env.Lists[name] = &MailingList{
+			Address: name,
+			Owner:   sec.Val(`owner`),
+			Members: sec.Vals(`member`),
+		}
+	}
+	return nil
+}
+
+// expand the recipients of mail into the list of final recipients, by
+// resolving the mailing lists, aliases, plus-addressing, catch-alls,
+// and reversing the SRS address.
+//
+// The recipient that is not in our domains is returned as is.
+// The envelope sender of mail that is forwarded to other domain is
+// rewritten using SRS, if its set.
+// The mail to mailing list is sent using the list Owner as envelope
+// sender.
+func (env *Environment) expand(from string, rcpts []string) (list []delivery) {
+	var seen = map[string]bool{}
+	for _, rcpt := range rcpts {
+		list = env.expandRecipient(list, seen, from, rcpt, 0)
+	}
+	return list
+}
+
+func (env *Environment) expandRecipient(list []delivery, seen map[string]bool, from, rcpt string, depth int) []delivery {
+	rcpt = strings.ToLower(strings.TrimSpace(rcpt))
+	if seen[rcpt] || depth > maxExpandDepth {
+		return list
+	}
+	seen[rcpt] = true
+
+	var at = strings.LastIndexByte(rcpt, '@')
+	if at < 0 {
+		return append(list, delivery{from: from, rcpt: rcpt})
+	}
+
+	var (
+		local  = rcpt[:at]
+		domain = env.lookupDomain(rcpt[at+1:])
+	)
+	if domain == nil {
+		if depth > 0 {
+			from = env.forwardSender(from)
+		}
+		return append(list, delivery{from: from, rcpt: rcpt})
+	}
+
+	if env.SRS != nil && env.SRS.isSRS(local) {
+		var sender, err = env.SRS.Reverse(rcpt)
+		if err == nil {
+			// The bounce is forwarded to original sender
+			// with null reverse-path.
+			return env.expandRecipient(list, seen, ``, sender, depth+1)
+		}
+	}
+
+	var mlist = env.Lists[rcpt]
+	if mlist != nil {
+		var owner = mlist.Owner
+		if len(owner) == 0 {
+			owner = localPostmaster + `@` + domain.Name
+		}
+		for _, member := range mlist.Members {
+			list = env.expandRecipient(list, seen, owner, member, depth+1)
+		}
+		return list
+	}
+
+	var targets, ok = env.Aliases[rcpt]
+	if ok {
+		for _, target := range targets {
+			list = env.expandRecipient(list, seen, from, target, depth+1)
+		}
+		return list
+	}
+
+	if domain.Accounts[local] != nil {
+		return append(list, delivery{from: from, rcpt: rcpt})
+	}
+
+	if len(env.RecipientDelimiter) > 0 {
+		var base, _, found = strings.Cut(local, env.RecipientDelimiter)
+		if found && len(base) > 0 {
+			return env.expandRecipient(list, seen, from, base+`@`+domain.Name, depth+1)
+		}
+	}
+
+	var catchAll = env.CatchAll[domain.Name]
+	if len(catchAll) > 0 {
+		return env.expandRecipient(list, seen, from, catchAll, depth+1)
+	}
+
+	return append(list, delivery{from: from, rcpt: rcpt})
+}
+
+// forwardSender rewrite the envelope sender of forwarded mail using SRS.
+// The empty sender and sender in our domain is not rewritten.
+func (env *Environment) forwardSender(from string) string {
+	if env.SRS == nil || len(from) == 0 {
+		return from
+	}
+	var at = strings.LastIndexByte(from, '@')
+	if at >= 0 && env.lookupDomain(from[at+1:]) != nil {
+		return from
+	}
+	return env.SRS.Forward(from)
+}
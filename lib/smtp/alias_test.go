@@ -0,0 +1,146 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestEnvironment_LoadAliases(t *testing.T) {
+	var (
+		env = &Environment{
+			PrimaryDomain: NewDomain(testDomain, nil),
+		}
+		err error
+	)
+
+	err = env.LoadAliases(`testdata/aliases.ini`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `RecipientDelimiter`, `+`, env.RecipientDelimiter)
+	test.Assert(t, `SRS`, &SRS{
+		Domain: testDomain,
+		Secret: []byte(`s3cr3t`),
+		MaxAge: 7,
+	}, env.SRS)
+	test.Assert(t, `Aliases`, map[string][]string{
+		`info@mail.kilabit.local`: {`first@mail.kilabit.local`, `user@remote.local`},
+		`loop@mail.kilabit.local`: {`loop@mail.kilabit.local`, `second@mail.kilabit.local`},
+	}, env.Aliases)
+	test.Assert(t, `CatchAll`, map[string]string{
+		testDomain: `second@mail.kilabit.local`,
+	}, env.CatchAll)
+	test.Assert(t, `Lists`, map[string]*MailingList{
+		`team@mail.kilabit.local`: {
+			Address: `team@mail.kilabit.local`,
+			Owner:   `first@mail.kilabit.local`,
+			Members: []string{`second@mail.kilabit.local`, `info@mail.kilabit.local`},
+		},
+	}, env.Lists)
+
+	err = env.LoadAliases(`testdata/notexist.ini`)
+	test.Assert(t, `LoadAliases: not exist`, true, err != nil)
+}
+
+func TestEnvironment_expand(t *testing.T) {
+	type testCase struct {
+		desc  string
+		from  string
+		rcpts []string
+		exp   []delivery
+	}
+
+	var (
+		domain = NewDomain(testDomain, nil)
+		env    = &Environment{
+			PrimaryDomain: domain,
+		}
+		err error
+	)
+
+	domain.Accounts[`first`] = &Account{Mailbox: Mailbox{Local: `first`, Domain: testDomain}}
+	domain.Accounts[`second`] = &Account{Mailbox: Mailbox{Local: `second`, Domain: testDomain}}
+
+	err = env.LoadAliases(`testdata/aliases.ini`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		sender    = `sender@example.org`
+		srsSender = env.SRS.Forward(sender)
+	)
+
+	var cases = []testCase{{
+		desc:  `With account`,
+		from:  sender,
+		rcpts: []string{`First@Mail.Kilabit.Local`},
+		exp:   []delivery{{from: sender, rcpt: `first@mail.kilabit.local`}},
+	}, {
+		desc:  `With remote recipient`,
+		from:  sender,
+		rcpts: []string{`user@remote.local`},
+		exp:   []delivery{{from: sender, rcpt: `user@remote.local`}},
+	}, {
+		desc:  `With alias forwarded to remote`,
+		from:  sender,
+		rcpts: []string{`info@mail.kilabit.local`},
+		exp: []delivery{
+			{from: sender, rcpt: `first@mail.kilabit.local`},
+			{from: srsSender, rcpt: `user@remote.local`},
+		},
+	}, {
+		desc:  `With alias forwarded from local sender`,
+		from:  `second@mail.kilabit.local`,
+		rcpts: []string{`info@mail.kilabit.local`},
+		exp: []delivery{
+			{from: `second@mail.kilabit.local`, rcpt: `first@mail.kilabit.local`},
+			{from: `second@mail.kilabit.local`, rcpt: `user@remote.local`},
+		},
+	}, {
+		desc:  `With alias loop`,
+		from:  sender,
+		rcpts: []string{`loop@mail.kilabit.local`},
+		exp:   []delivery{{from: sender, rcpt: `second@mail.kilabit.local`}},
+	}, {
+		desc:  `With mailing list`,
+		from:  sender,
+		rcpts: []string{`team@mail.kilabit.local`},
+		exp: []delivery{
+			{from: `first@mail.kilabit.local`, rcpt: `second@mail.kilabit.local`},
+			{from: `first@mail.kilabit.local`, rcpt: `first@mail.kilabit.local`},
+			{from: `first@mail.kilabit.local`, rcpt: `user@remote.local`},
+		},
+	}, {
+		desc:  `With plus-addressing`,
+		from:  sender,
+		rcpts: []string{`first+news@mail.kilabit.local`},
+		exp:   []delivery{{from: sender, rcpt: `first@mail.kilabit.local`}},
+	}, {
+		desc:  `With catch-all`,
+		from:  sender,
+		rcpts: []string{`unknown@mail.kilabit.local`},
+		exp:   []delivery{{from: sender, rcpt: `second@mail.kilabit.local`}},
+	}, {
+		desc:  `With duplicate recipients`,
+		from:  sender,
+		rcpts: []string{`first@mail.kilabit.local`, `first+a@mail.kilabit.local`},
+		exp:   []delivery{{from: sender, rcpt: `first@mail.kilabit.local`}},
+	}, {
+		desc:  `With bounce to SRS address`,
+		rcpts: []string{srsSender},
+		exp:   []delivery{{rcpt: sender}},
+	}}
+
+	var got []delivery
+	for _, c := range cases {
+		got = env.expand(c.from, c.rcpts)
+		test.Assert(t, c.desc, c.exp, got)
+	}
+}
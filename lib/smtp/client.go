@@ -5,6 +5,7 @@
 package smtp
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/tls"
 	"encoding/base64"
@@ -14,6 +15,7 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/shuLhan/share/lib/email"
@@ -29,11 +31,11 @@ type Client struct {
 	ServerInfo *ServerInfo
 
 	conn       net.Conn
+	reader     *bufio.Reader
 	raddr      net.TCPAddr
 	serverName string
 
-	data []byte
-	buf  bytes.Buffer
+	buf bytes.Buffer
 
 	isTLS      bool
 	isStartTLS bool
@@ -93,7 +95,6 @@ func NewClient(opts ClientOptions) (cl *Client, err error) {
 		}
 	}
 
-	cl.data = make([]byte, 4096)
 	cl.raddr.Port = int(port)
 
 	_, err = cl.connect(opts.LocalName)
@@ -118,22 +119,57 @@ func NewClient(opts ClientOptions) (cl *Client, err error) {
 }
 
 // Authenticate to server using one of SASL mechanism.
-// Currently, the only mechanism available is PLAIN.
+// For XOAUTH2 mechanism, the password is the OAuth 2.0 access token.
+//
+// On success, it will return response with Code 235,
+// StatusAuthenticated.
 func (cl *Client) Authenticate(mech SaslMechanism, username, password string) (
 	res *Response, err error,
 ) {
-	var cmd []byte
+	var (
+		logp = `client.Authenticate`
+		sasl = newSaslClient(mech, username, password)
 
-	switch mech {
-	case SaslMechanismPlain:
-		b := []byte("\x00" + username + "\x00" + password)
-		initialResponse := base64.StdEncoding.EncodeToString(b)
-		cmd = []byte("AUTH PLAIN " + initialResponse + "\r\n")
-	default:
-		return nil, fmt.Errorf("client.Authenticate: unknown mechanism")
+		resp []byte
+	)
+	if sasl == nil {
+		return nil, fmt.Errorf(`%s: unknown mechanism`, logp)
 	}
 
-	return cl.SendCommand(cmd)
+	resp, err = sasl.start()
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var cmd = `AUTH ` + mech.String()
+	if resp != nil {
+		cmd += ` ` + encodeSaslResponse(resp)
+	}
+
+	res, err = cl.SendCommand([]byte(cmd + "\r\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	var challenge []byte
+	for res.Code == StatusAuthReady {
+		challenge, err = base64.StdEncoding.DecodeString(res.Message)
+		if err == nil {
+			resp, err = sasl.next(challenge)
+		}
+		if err != nil {
+			// Cancel the authentication exchange.
+			_, _ = cl.SendCommand([]byte("*\r\n"))
+			return nil, fmt.Errorf(`%s: %w`, logp, err)
+		}
+
+		res, err = cl.SendCommand([]byte(base64.StdEncoding.EncodeToString(resp) + "\r\n"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
 }
 
 // connect open a connection to server and issue EHLO command immediately.
@@ -156,6 +192,7 @@ func (cl *Client) connect(localName string) (res *Response, err error) {
 
 		cl.conn = tls.Client(cl.conn, tlsConfig)
 	}
+	cl.reader = bufio.NewReader(cl.conn)
 
 	res, err = cl.recv()
 	if err != nil {
@@ -269,34 +306,66 @@ func (cl *Client) MailTx(mail *MailTx) (res *Response, err error) {
 		return nil, errors.New("SendMailTx: empty mail 'Recipients' parameter")
 	}
 
-	cl.buf.Reset()
-	fmt.Fprintf(&cl.buf, "MAIL FROM:<%s>\r\n", mail.From)
+	return cl.sendMailTx(mail)
+}
 
-	res, err = cl.SendCommand(cl.buf.Bytes())
+// maxChunkSize define the maximum size of each BDAT chunk sent by client.
+const maxChunkSize = 1 << 20
+
+// sendMailTx send the mail transaction without validating the mail
+// originator.
+// Empty From is sent as null reverse-path "<>", which is required when
+// sending delivery status notification (RFC 5321, section 4.5.5).
+//
+// If server support PIPELINING (RFC 2920), the MAIL and RCPT commands are
+// sent at once.
+// If server support CHUNKING (RFC 3030), the mail data is sent using BDAT
+// command instead of DATA.
+func (cl *Client) sendMailTx(mail *MailTx) (res *Response, err error) {
+	var (
+		logp = `client.MailTx`
+
+		params string
+	)
+
+	params, err = cl.mailParams(mail)
 	if err != nil {
-		return nil, err
-	}
-	if res.Code != StatusOK {
-		err = fmt.Errorf("client.MailTx: MAIL FROM: %d - %s", res.Code, res.Message)
-		return nil, err
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
 	}
 
+	var cmds = make([]string, 0, len(mail.Recipients)+1)
+
+	cmds = append(cmds, fmt.Sprintf("MAIL FROM:<%s>%s\r\n", mail.From, params))
 	for _, to := range mail.Recipients {
-		cl.buf.Reset()
-		fmt.Fprintf(&cl.buf, "RCPT TO:<%s>\r\n", to)
+		cmds = append(cmds, fmt.Sprintf("RCPT TO:<%s>\r\n", to))
+	}
 
-		res, err = cl.SendCommand(cl.buf.Bytes())
-		if err != nil || res.Code != StatusOK {
-			err = fmt.Errorf("client.MailTx: RCPT TO: %d - %s", res.Code, res.Message)
-			return res, err
+	var x int
+
+	res, x, err = cl.sendEnvelope(cmds)
+	if err != nil {
+		return nil, err
+	}
+	if res.Code != StatusOK {
+		var cmdName = `MAIL FROM`
+		if x > 0 {
+			cmdName = `RCPT TO`
 		}
+		return res, fmt.Errorf(`%s: %s: %d - %s`, logp, cmdName, res.Code, res.Message)
+	}
+
+	if cl.hasExtension(`chunking`) {
+		return cl.sendChunks(mail.Data)
 	}
 
 	cl.buf.Reset()
 	cl.buf.WriteString("DATA\r\n")
 
 	res, err = cl.SendCommand(cl.buf.Bytes())
-	if err != nil || res.Code != StatusDataReady {
+	if err != nil {
+		return nil, err
+	}
+	if res.Code != StatusDataReady {
 		err = fmt.Errorf("client.MailTx: DATA: %d - %s", res.Code, res.Message)
 		return res, err
 	}
@@ -315,12 +384,140 @@ func (cl *Client) MailTx(mail *MailTx) (res *Response, err error) {
 		return nil, err
 	}
 	if res.Code != StatusOK {
-		return nil, fmt.Errorf("client.MailTx: Message: %d - %s", res.Code, res.Message)
+		return res, fmt.Errorf("client.MailTx: Message: %d - %s", res.Code, res.Message)
 	}
 
 	return res, err
 }
 
+// hasExtension return true if server advertise the extension name, in
+// lower case, on EHLO response.
+func (cl *Client) hasExtension(name string) (ok bool) {
+	if cl.ServerInfo == nil {
+		return false
+	}
+	_, ok = cl.ServerInfo.Exts[name]
+	return ok
+}
+
+// mailParams return the MAIL command parameters based on the mail and
+// extensions supported by server.
+func (cl *Client) mailParams(mail *MailTx) (params string, err error) {
+	if cl.hasExtension(`size`) {
+		params += ` SIZE=` + strconv.Itoa(len(mail.Data))
+	}
+	if cl.hasExtension(`8bitmime`) && !isASCII(string(mail.Data)) {
+		params += ` BODY=8BITMIME`
+	}
+
+	var isUTF8 = !isASCII(mail.From)
+	for _, rcpt := range mail.Recipients {
+		if !isASCII(rcpt) {
+			isUTF8 = true
+		}
+	}
+	if isUTF8 {
+		if !cl.hasExtension(`smtputf8`) {
+			return ``, errors.New(`server does not support SMTPUTF8`)
+		}
+		params += ` SMTPUTF8`
+	}
+	return params, nil
+}
+
+// sendEnvelope send the MAIL and RCPT commands.
+// If server support PIPELINING, all commands are sent at once and all of
+// their responses are read.
+// It will return the first non-success response and the index of its
+// command, or the last response if all of them success.
+func (cl *Client) sendEnvelope(cmds []string) (res *Response, x int, err error) {
+	if !cl.hasExtension(`pipelining`) {
+		for x = range cmds {
+			res, err = cl.SendCommand([]byte(cmds[x]))
+			if err != nil {
+				return nil, x, err
+			}
+			if res.Code != StatusOK {
+				return res, x, nil
+			}
+		}
+		return res, x, nil
+	}
+
+	cl.buf.Reset()
+	for _, cmd := range cmds {
+		cl.buf.WriteString(cmd)
+	}
+
+	_, err = cl.conn.Write(cl.buf.Bytes())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		failed  *Response
+		got     *Response
+		xfailed int
+	)
+	for x = range cmds {
+		got, err = cl.recv()
+		if err != nil {
+			return nil, x, err
+		}
+		if got.Code != StatusOK && failed == nil {
+			failed = got
+			xfailed = x
+		}
+		res = got
+	}
+	if failed != nil {
+		return failed, xfailed, nil
+	}
+	return res, x, nil
+}
+
+// sendChunks send the mail data using BDAT command.
+// The data is converted back from dot-stuffed format and sent in chunks
+// of maxChunkSize.
+func (cl *Client) sendChunks(data []byte) (res *Response, err error) {
+	var (
+		logp  = `client.MailTx: BDAT`
+		chunk []byte
+		last  string
+	)
+
+	data = append(unstuff(data), "\r\n"...)
+
+	for len(data) > 0 || last == `` {
+		chunk = data
+		if len(chunk) > maxChunkSize {
+			chunk = chunk[:maxChunkSize]
+		}
+		data = data[len(chunk):]
+		if len(data) == 0 {
+			last = ` LAST`
+		}
+
+		cl.buf.Reset()
+		fmt.Fprintf(&cl.buf, "BDAT %d%s\r\n", len(chunk), last)
+		cl.buf.Write(chunk)
+
+		_, err = cl.conn.Write(cl.buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		res, err = cl.recv()
+		if err != nil {
+			return nil, err
+		}
+		if res.Code != StatusOK {
+			return res, fmt.Errorf(`%s: %d - %s`, logp, res.Code, res.Message)
+		}
+	}
+	return res, nil
+}
+
 // Noop send the NOOP command to server with optional message.
 //
 // On success, it will return response with Code 250, StatusOK.
@@ -446,31 +643,24 @@ func getUnicastAddress() (saddr string) {
 }
 
 // recv read and parse the response from server.
+// The response can be single or multiple lines, where each line except
+// the last one has hyphen after the code.
 func (cl *Client) recv() (res *Response, err error) {
 	cl.buf.Reset()
 
-	var n int
+	var line []byte
 	for {
-		n, err = cl.conn.Read(cl.data)
-		if n > 0 {
-			_, err = cl.buf.Write(cl.data[:n])
-			if err != nil {
-				break
-			}
-		}
+		line, err = cl.reader.ReadBytes('\n')
+		cl.buf.Write(line)
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				if cl.buf.Len() == 0 {
-					return nil, err
-				}
+			if errors.Is(err, io.EOF) && cl.buf.Len() > 0 {
 				break
 			}
 			return nil, err
 		}
-		if n == cap(cl.data) {
-			continue
+		if len(line) < 4 || line[3] != '-' {
+			break
 		}
-		break
 	}
 
 	res, err = NewResponse(cl.buf.Bytes())
@@ -505,6 +695,16 @@ func (cl *Client) StartTLS() (res *Response, err error) {
 	}
 
 	cl.conn = tls.Client(cl.conn, tlsConfig)
+	cl.reader = bufio.NewReader(cl.conn)
 
 	return res, nil
 }
+
+// encodeSaslResponse encode the SASL response into base64.
+// The empty response is encoded as "=" (RFC 4954 section 4).
+func encodeSaslResponse(resp []byte) string {
+	if len(resp) == 0 {
+		return `=`
+	}
+	return base64.StdEncoding.EncodeToString(resp)
+}
@@ -70,16 +70,30 @@ func TestEhlo(t *testing.T) {
 			Message: "mail.kilabit.local",
 			Body: []string{
 				"DSN",
-				"AUTH PLAIN",
+				"PIPELINING",
+				"SIZE 10485760",
+				"8BITMIME",
+				"SMTPUTF8",
+				"ENHANCEDSTATUSCODES",
+				"CHUNKING",
+				"AUTH PLAIN LOGIN SCRAM-SHA-256",
 			},
 		},
 		expServerInfo: &ServerInfo{
 			Domain: "mail.kilabit.local",
 			Info:   "mail.kilabit.local",
 			Exts: map[string][]string{
-				"dsn": nil,
+				"dsn":                 {},
+				"pipelining":          {},
+				"size":                {"10485760"},
+				"8bitmime":            {},
+				"smtputf8":            {},
+				"enhancedstatuscodes": {},
+				"chunking":            {},
 				"auth": {
 					"PLAIN",
+					"LOGIN",
+					"SCRAM-SHA-256",
 				},
 			},
 		},
@@ -103,6 +117,7 @@ func TestEhlo(t *testing.T) {
 		test.Assert(t, "Ehlo", c.exp, got)
 		test.Assert(t, "ServerInfo.Domain", c.expServerInfo.Domain, cl.ServerInfo.Domain)
 		test.Assert(t, "ServerInfo.Info", c.expServerInfo.Info, cl.ServerInfo.Info)
+		test.Assert(t, "ServerInfo.Exts", c.expServerInfo.Exts, cl.ServerInfo.Exts)
 	}
 }
 
@@ -165,6 +180,70 @@ func TestAuth(t *testing.T) {
 	}
 }
 
+func TestAuth_mechanisms(t *testing.T) {
+	type testCase struct {
+		exp      *Response
+		desc     string
+		password string
+		mech     SaslMechanism
+	}
+
+	var cases = []testCase{{
+		desc:     `With LOGIN`,
+		mech:     SaslMechanismLogin,
+		password: testPassword,
+		exp: &Response{
+			Code:    StatusAuthenticated,
+			Message: `2.7.0 Authentication successful`,
+		},
+	}, {
+		desc:     `With SCRAM-SHA-256`,
+		mech:     SaslMechanismScramSHA256,
+		password: testPassword,
+		exp: &Response{
+			Code:    StatusAuthenticated,
+			Message: `2.7.0 Authentication successful`,
+		},
+	}, {
+		desc:     `With SCRAM-SHA-256 and invalid password`,
+		mech:     SaslMechanismScramSHA256,
+		password: `invalid`,
+		exp: &Response{
+			Code:    StatusInvalidCredential,
+			Message: `5.7.8 Authentication credentials invalid`,
+		},
+	}, {
+		desc:     `With mechanism not enabled`,
+		mech:     SaslMechanismCramMD5,
+		password: testPassword,
+		exp: &Response{
+			Code:    StatusParamUnimplemented,
+			Message: `5.5.4 Command parameter not implemented`,
+		},
+	}}
+
+	var (
+		c   testCase
+		cl  *Client
+		got *Response
+		err error
+	)
+	for _, c = range cases {
+		t.Log(c.desc)
+
+		cl = testNewClient(false)
+
+		got, err = cl.Authenticate(c.mech, testAccountFirst.Short(), c.password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		test.Assert(t, `Response`, c.exp, got)
+
+		_, _ = cl.Quit()
+	}
+}
+
 func TestAuth2(t *testing.T) {
 	var (
 		opts = ClientOptions{
@@ -194,7 +273,7 @@ func TestAuth2(t *testing.T) {
 	test.Assert(t, "Response", exp, res)
 
 	cred := []byte("\x00" + testAccountFirst.Short() + "\x00" + testPassword)
-	cmd = base64.StdEncoding.EncodeToString(cred)
+	cmd = base64.StdEncoding.EncodeToString(cred) + "\r\n"
 
 	res, err = cl.SendCommand([]byte(cmd))
 	if err != nil {
@@ -214,11 +293,19 @@ func TestExpand(t *testing.T) {
 		desc  string
 		mlist string
 	}{{
-		desc:  "With mailing-list",
+		desc:  "With unknown mailing-list",
 		mlist: "mailing-list@test",
 		exp: &Response{
-			Code:    StatusCmdNotImplemented,
-			Message: "Command not implemented",
+			Code:    StatusMailboxNotFound,
+			Message: "5.1.1 Mailing list not found",
+		},
+	}, {
+		desc:  "With mailing-list",
+		mlist: "team@" + testDomain,
+		exp: &Response{
+			Code:    StatusOK,
+			Message: "<" + testAccountFirst.Short() + ">",
+			Body:    []string{"<" + testAccountSecond.Short() + ">"},
 		},
 	}}
 
@@ -236,6 +323,40 @@ func TestExpand(t *testing.T) {
 	}
 }
 
+// TestExpand_notAuthenticated test the EXPN and VRFY commands without AUTH
+// on submission port.
+func TestExpand_notAuthenticated(t *testing.T) {
+	var (
+		cl  = testNewClient(false)
+		exp = &Response{
+			Code:    StatusNotAuthenticated,
+			Message: `5.7.0 Authentication required`,
+		}
+
+		got *Response
+		err error
+	)
+
+	got, err = cl.Expand(`team@` + testDomain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Expand`, exp, got)
+
+	// The next response should not be the mailing-list members.
+	got, err = cl.Verify(testAccountFirst.Short())
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Verify`, exp, got)
+
+	got, err = cl.Expand(`team@` + testDomain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Expand after Verify`, exp, got)
+}
+
 func TestHelp(t *testing.T) {
 	cases := []struct {
 		exp  *Response
@@ -6,6 +6,9 @@ package smtp
 
 import (
 	"bytes"
+	"strings"
+
+	"github.com/shuLhan/share/lib/ascii"
 )
 
 // CommandKind represent the numeric value of SMTP command.
@@ -26,6 +29,7 @@ const (
 	CommandHELP
 	CommandNOOP
 	CommandQUIT
+	CommandBDAT // RFC 3030.
 )
 
 // Command represent a single SMTP command with its parsed argument and
@@ -81,10 +85,23 @@ func (cmd *Command) parsePath(b []byte) error {
 	return nil
 }
 
+// param return the value of parameter by its key, case insensitive.
+func (cmd *Command) param(key string) (value string, ok bool) {
+	for k, v := range cmd.Params {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return ``, false
+}
+
 // parseParams parse parameters in MAIL or RCPT argument.  The parameters have
 // the following syntax,
 //
-//	key=value [ SP key=value ]
+//	( key=value / keyword ) [ SP ( key=value / keyword ) ]
+//
+// The keyword without value, for example "SMTPUTF8", is stored with empty
+// value.
 func (cmd *Command) parseParams(line []byte) {
 	var x int
 	var k, v []byte
@@ -95,13 +112,28 @@ func (cmd *Command) parseParams(line []byte) {
 				break
 			}
 		}
+		var isKeyword = true
 		for ; x < len(line); x++ {
+			if line[x] == ' ' {
+				break
+			}
 			if line[x] == '=' {
+				isKeyword = false
 				x++
 				break
 			}
 			k = append(k, line[x])
 		}
+		if isKeyword {
+			if len(k) > 0 {
+				if cmd.Params == nil {
+					cmd.Params = make(map[string]string)
+				}
+				cmd.Params[string(k)] = ``
+			}
+			k = nil
+			continue
+		}
 		if x == len(line) {
 			break
 		}
@@ -125,7 +157,9 @@ func (cmd *Command) parseParams(line []byte) {
 
 // reset command fields to its zero value for re-use.
 func (cmd *Command) reset() {
+	cmd.Kind = CommandZERO
 	cmd.Arg = ""
+	cmd.Param = ""
 	cmd.Params = nil
 }
 
@@ -157,6 +191,33 @@ func (cmd *Command) unpack(b []byte) (err error) {
 	}
 
 	switch cmdName[0] {
+	case 'b':
+		if bytes.Equal([]byte("bdat"), cmdName) {
+			// BDAT SP chunk-size [ SP end-marker ]
+			if len(cmds) < 2 || len(cmds) > 3 {
+				return errCmdSyntaxError
+			}
+			if len(arg) == 0 || len(arg) > 18 {
+				return errCmdSyntaxError
+			}
+			for _, c := range arg {
+				if !ascii.IsDigit(c) {
+					return errCmdSyntaxError
+				}
+			}
+			cmd.Arg = string(arg)
+
+			if len(cmds) == 3 {
+				if !bytes.EqualFold([]byte("last"), cmds[2]) {
+					return errCmdSyntaxError
+				}
+				cmd.Param = "LAST"
+			}
+
+			cmd.Kind = CommandBDAT
+			return nil
+		}
+
 	case 'a':
 		if bytes.Equal([]byte("auth"), cmdName) {
 			if len(cmds) == 1 || len(cmds) > 3 {
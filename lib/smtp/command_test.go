@@ -176,6 +176,36 @@ func TestUnpack(t *testing.T) {
 				"key": "value",
 			},
 		},
+	}, {
+		desc: "MAIL with keyword param",
+		b:    "MAIL FROM:<local@domain.com> SMTPUTF8 BODY=8BITMIME\r\n",
+		expCmd: &Command{
+			Kind: CommandMAIL,
+			Arg:  "local@domain.com",
+			Params: map[string]string{
+				"SMTPUTF8": "",
+				"BODY":     "8BITMIME",
+			},
+		},
+	}, {
+		desc: "BDAT",
+		b:    "BDAT 1024\r\n",
+		expCmd: &Command{
+			Kind: CommandBDAT,
+			Arg:  "1024",
+		},
+	}, {
+		desc: "BDAT with LAST",
+		b:    "BDAT 0 last\r\n",
+		expCmd: &Command{
+			Kind:  CommandBDAT,
+			Arg:   "0",
+			Param: "LAST",
+		},
+	}, {
+		desc:   "BDAT with invalid size",
+		b:      "BDAT 1x\r\n",
+		expErr: errCmdSyntaxError,
 	}, {
 		desc: "NOOP",
 		b:    "NOOP\r\n",
@@ -304,6 +334,7 @@ func TestUnpack(t *testing.T) {
 
 		test.Assert(t, "Command.Kind", c.expCmd.Kind, cmd.Kind)
 		test.Assert(t, "Command.Arg", c.expCmd.Arg, cmd.Arg)
+		test.Assert(t, "Command.Param", c.expCmd.Param, cmd.Param)
 		test.Assert(t, "Command.Params", c.expCmd.Params, cmd.Params)
 	}
 }
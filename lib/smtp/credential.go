@@ -0,0 +1,124 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// defScramIteration define the default iteration count for SCRAM
+// credential, as recommended by RFC 7677.
+const defScramIteration = 4096
+
+// CredentialStore define an interface for the backend that store the
+// account credentials, used by server to authenticate the client on AUTH
+// command.
+//
+// Each method must return [ErrInvalidCredential] if the username does not
+// exist or the backend does not support the credential.
+type CredentialStore interface {
+	// Authenticate verify the plain text password of username.
+	// This method is used by the PLAIN and LOGIN mechanisms.
+	Authenticate(username, password string) error
+
+	// Password return the plain text password of username.
+	// This method is used by the CRAM-MD5 mechanism.
+	Password(username string) (string, error)
+
+	// ScramCredential return the SCRAM-SHA-256 credential of username.
+	// This method is used by the SCRAM-SHA-256 mechanism.
+	ScramCredential(username string) (*ScramCredential, error)
+
+	// AuthenticateToken verify the OAuth 2.0 bearer token of username.
+	// This method is used by the XOAUTH2 mechanism.
+	AuthenticateToken(username, token string) error
+}
+
+// ScramCredential contains the salted credential for SCRAM-SHA-256
+// mechanism (RFC 5802 section 3).
+type ScramCredential struct {
+	Salt      []byte
+	StoredKey []byte
+	ServerKey []byte
+	Iteration int
+}
+
+// NewScramCredential create the SCRAM-SHA-256 credential from plain text
+// password, salt, and iteration count.
+// If iteration is less than or equal to zero, it will set to 4096.
+func NewScramCredential(password string, salt []byte, iteration int) (cred *ScramCredential) {
+	if iteration <= 0 {
+		iteration = defScramIteration
+	}
+
+	var (
+		saltedPass = pbkdf2.Key([]byte(password), salt, iteration, sha256.Size, sha256.New)
+		clientKey  = hmacSHA256(saltedPass, []byte(`Client Key`))
+		storedKey  = sha256.Sum256(clientKey)
+	)
+
+	cred = &ScramCredential{
+		Salt:      salt,
+		StoredKey: storedKey[:],
+		ServerKey: hmacSHA256(saltedPass, []byte(`Server Key`)),
+		Iteration: iteration,
+	}
+	return cred
+}
+
+// localCredential implement the CredentialStore using the Handler
+// ServeAuth and the accounts in Environment.
+type localCredential struct {
+	handler Handler
+	env     *Environment
+}
+
+// Authenticate the username and password using the Handler ServeAuth.
+func (lc *localCredential) Authenticate(username, password string) (err error) {
+	_, err = lc.handler.ServeAuth(username, password)
+	return err
+}
+
+// Password always return ErrInvalidCredential, since the Account only store
+// the hashed password.
+// That is why the Server cannot accept CRAM-MD5 mechanism without custom
+// CredentialStore.
+func (lc *localCredential) Password(_ string) (string, error) {
+	return ``, ErrInvalidCredential
+}
+
+// ScramCredential return the SCRAM credential of account in Environment.
+func (lc *localCredential) ScramCredential(username string) (cred *ScramCredential, err error) {
+	var at = strings.LastIndexByte(username, '@')
+	if at < 0 {
+		return nil, ErrInvalidCredential
+	}
+
+	var domain = lc.env.lookupDomain(username[at+1:])
+	if domain == nil {
+		return nil, ErrInvalidCredential
+	}
+
+	var acc = domain.Accounts[strings.ToLower(username[:at])]
+	if acc == nil || acc.Scram == nil {
+		return nil, ErrInvalidCredential
+	}
+	return acc.Scram, nil
+}
+
+// AuthenticateToken always return ErrInvalidCredential.
+func (lc *localCredential) AuthenticateToken(_, _ string) error {
+	return ErrInvalidCredential
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	var mac = hmac.New(sha256.New, key)
+	_, _ = mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -5,14 +5,75 @@
 package smtp
 
 import (
-	"crypto/rsa"
+	"crypto"
+	"time"
 
 	"github.com/shuLhan/share/lib/email/dkim"
 )
 
+// defDKIMHeaders define the default list of header fields that are signed
+// if the DKIMOptions Signature does not set the Headers.
+var defDKIMHeaders = []string{
+	`from`,
+	`reply-to`,
+	`subject`,
+	`date`,
+	`to`,
+	`cc`,
+	`message-id`,
+	`in-reply-to`,
+	`references`,
+	`mime-version`,
+	`content-type`,
+	`content-transfer-encoding`,
+}
+
 // DKIMOptions contains the DKIM signature fields and private key to sign the
-// incoming message.
+// outgoing message from authenticated account in the domain.
+//
+// The Signature act as template for each signed message.
+// The SDID ("d=") and Selector ("s=") is required.
+// The signing algorithm ("a="), canonicalization ("c="), and list of signed
+// header fields ("h=") is optional.
+// If the Headers is empty, it will default to common header fields: From,
+// Reply-To, Subject, Date, To, Cc, Message-ID, In-Reply-To, References,
+// MIME-Version, Content-Type, and Content-Transfer-Encoding.
+//
+// The PrivateKey can be *rsa.PrivateKey or ed25519.PrivateKey.
+// For ed25519.PrivateKey, the signing algorithm is set to
+// "ed25519-sha256" (RFC 8463).
 type DKIMOptions struct {
 	Signature  *dkim.Signature
-	PrivateKey *rsa.PrivateKey
+	PrivateKey crypto.Signer
+}
+
+// newSignature create new signature for single message from the
+// Signature template.
+func (opts *DKIMOptions) newSignature() (sig *dkim.Signature) {
+	var tmpl = opts.Signature
+
+	sig = &dkim.Signature{
+		Alg:         tmpl.Alg,
+		CanonHeader: tmpl.CanonHeader,
+		CanonBody:   tmpl.CanonBody,
+		QMethod:     tmpl.QMethod,
+		SDID:        tmpl.SDID,
+		Selector:    tmpl.Selector,
+		AUID:        tmpl.AUID,
+		CreatedAt:   uint64(time.Now().Unix()),
+	}
+
+	if len(tmpl.Headers) > 0 {
+		sig.Headers = make([][]byte, 0, len(tmpl.Headers))
+		for _, name := range tmpl.Headers {
+			sig.Headers = append(sig.Headers, name)
+		}
+	} else {
+		sig.Headers = make([][]byte, 0, len(defDKIMHeaders))
+		for _, name := range defDKIMHeaders {
+			sig.Headers = append(sig.Headers, []byte(name))
+		}
+	}
+
+	return sig
 }
@@ -0,0 +1,146 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	libcrypto "github.com/shuLhan/share/lib/crypto"
+	"github.com/shuLhan/share/lib/email"
+	"github.com/shuLhan/share/lib/email/dkim"
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestServer_signMail(t *testing.T) {
+	var (
+		pkey, err = libcrypto.LoadPrivateKey(testFilePrivateKey, nil)
+
+		edPub, edKey, _ = ed25519.GenerateKey(nil)
+		canonSimple     = dkim.CanonSimple
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		rsakey = pkey.(*rsa.PrivateKey)
+		vdom   = NewDomain(`virtual.local`, &DKIMOptions{
+			Signature: &dkim.Signature{
+				SDID:        []byte(`virtual.local`),
+				Selector:    []byte(`ed`),
+				CanonHeader: &canonSimple,
+				CanonBody:   &canonSimple,
+				Headers:     [][]byte{[]byte(`from`), []byte(`subject`)},
+			},
+			PrivateKey: edKey,
+		})
+		srv = &Server{
+			Env: &Environment{
+				PrimaryDomain: NewDomain(testDomain, &DKIMOptions{
+					Signature:  dkim.NewSignature([]byte(testDomain), []byte(`default`)),
+					PrivateKey: rsakey,
+				}),
+				VirtualDomains: map[string]*Domain{
+					`virtual.local`: vdom,
+				},
+			},
+		}
+		keys = dkim.NewKeyPool(func(dname string) (*dkim.Key, error) {
+			var key = &dkim.Key{
+				ExpiredAt: time.Now().Add(time.Hour).Unix(),
+			}
+			switch dname {
+			case `default._domainkey.` + testDomain:
+				key.RSA = &rsakey.PublicKey
+			case `ed._domainkey.virtual.local`:
+				key.Ed25519 = edPub
+			}
+			return key, nil
+		})
+	)
+
+	type testCase struct {
+		desc     string
+		username string
+		expTags  []string
+		isSigned bool
+	}
+
+	var cases = []testCase{{
+		desc:     `With RSA key`,
+		username: `first@` + testDomain,
+		isSigned: true,
+		expTags: []string{
+			`a=rsa-sha256;`,
+			`d=` + testDomain + `;`,
+			`c=relaxed/relaxed;`,
+		},
+	}, {
+		desc:     `With Ed25519 key and custom headers`,
+		username: `user@virtual.local`,
+		isSigned: true,
+		expTags: []string{
+			`a=ed25519-sha256;`,
+			`h=from:subject;`,
+			`c=simple/simple;`,
+		},
+	}, {
+		desc:     `With unknown domain`,
+		username: `user@unknown.local`,
+	}}
+
+	var (
+		data = "From: <sender@example.local>\r\n" +
+			"To: <rcpt@example.local>\r\n" +
+			"Subject: Signed\r\n" +
+			"\r\n" +
+			"Hello.\r\n" +
+			"..dot line\r\n"
+
+		c    testCase
+		recv *receiver
+		msg  *email.Message
+		st   *dkim.Status
+	)
+	for _, c = range cases {
+		recv = &receiver{
+			username: c.username,
+			mail: &MailTx{
+				Data: []byte(data),
+			},
+		}
+
+		err = srv.signMail(recv)
+		if err != nil {
+			t.Fatalf(`%s: %s`, c.desc, err)
+		}
+
+		var isSigned = bytes.HasPrefix(recv.mail.Data, []byte(`DKIM-Signature:`))
+		test.Assert(t, c.desc+`: signed`, c.isSigned, isSigned)
+		if !isSigned {
+			continue
+		}
+
+		for _, tag := range c.expTags {
+			test.Assert(t, c.desc+`: `+tag, true,
+				bytes.Contains(recv.mail.Data, []byte(tag)))
+		}
+
+		msg, _, err = email.ParseMessage(unstuff(recv.mail.Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		st, err = msg.DKIMVerifyWith(keys)
+		if err != nil {
+			t.Fatalf(`%s: %s`, c.desc, err)
+		}
+		test.Assert(t, c.desc+`: status`, dkim.StatusOK, st.Type)
+	}
+}
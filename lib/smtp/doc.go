@@ -14,11 +14,87 @@
 // Port 465 is used to receive message submission from SMTP accounts with
 // authentication.
 //
+// # Extensions
+//
+// The server and client support the following extensions: DSN,
+// PIPELINING (RFC 2920), SIZE (RFC 1870), 8BITMIME (RFC 6152), SMTPUTF8
+// (RFC 6531), ENHANCEDSTATUSCODES (RFC 2034), and CHUNKING (RFC 3030).
+// The client send the MAIL and RCPT commands at once if server support
+// PIPELINING, and send the message using BDAT if server support CHUNKING.
+//
+// # Authentication
+//
+// The server accept AUTH command with PLAIN, LOGIN, CRAM-MD5,
+// SCRAM-SHA-256, and XOAUTH2 mechanisms, as long as the mechanism is
+// listed in Server AuthMechanisms.
+// The account credentials are retrieved from the CredentialStore, which
+// can be implemented by application to use their own backend.
+// The CRAM-MD5 mechanism require the CredentialStore that can return the
+// plain text password, so the default CredentialStore does not support it.
+//
+// # Relay
+//
+// Mail from authenticated account with recipient domain that is not
+// managed by server is relayed to the mail exchanger (MX) of recipient
+// domain.
+// If the relay is failed temporarily, it will be retried with exponential
+// backoff until its expired.
+// If the relay is failed permanently or expired, the server send the
+// delivery status notification (RFC 3464) back to the sender.
+//
+// If Server Storage is set, each accepted mail is saved into the spool
+// before the server reply with "250 OK", and the relayed mail is saved
+// with its delivery state, number of retries, next retry time, and last
+// error.
+// The mail is removed from the spool once its delivered.
+// The mails in the spool are loaded back into the queue when the server
+// Start, and the queue can be inspected and managed using Server
+// QueueList, QueueFlush, and QueueDelete.
+//
+// # Inbound Policy
+//
+// Incoming mail on port 25 can be checked using SPF (RFC 7208), DKIM
+// (RFC 6376), and DMARC (RFC 7489) by setting the Server Inbound field.
+// The result of each check is recorded in the "Authentication-Results"
+// header field (RFC 8601).
+// Depends on the policy, mail that fail the checks can be rejected or
+// marked for quarantine.
+//
+// # Abuse Policy
+//
+// The Server Abuse field define the limits for maximum concurrent
+// connections per IP address, commands per minute, and recipients per
+// mail transaction.
+// On port 25, the client can be checked using DNS blocklist (RFC 5782),
+// early talker detection before greeting, and triplet based greylisting
+// with state persisted in file.
+//
+// # Filters
+//
+// The Server Filters are called in order on each stage of SMTP session:
+// connect, HELO/EHLO, MAIL, RCPT, and the end of DATA, similar to milter.
+// Each filter can reject the command, add header fields, or mark the
+// message for quarantine.
+// The package provide HeaderFilter to check the sanity of message header,
+// AttachmentFilter to block attachment by their type, and BayesFilter to
+// score the message using naive Bayesian classifier trained from maildir
+// folders.
+//
 // # Server Environment
 //
 // The server require one primary domain with one primary account called
 // "postmaster".  Domain can have two or more accounts.  Domain can have
-// their own DKIM certificate.
+// their own DKIM key, which is used to sign the message submitted by
+// authenticated account in that domain, using RSA or Ed25519 (RFC 8463).
+//
+// # Aliases and Mailing Lists
+//
+// The recipients of mail are expanded using the aliases, mailing lists,
+// plus-addressing, and catch-alls in Environment, which can be loaded
+// from ini file using Environment LoadAliases.
+// Mail that is forwarded to other domain has its envelope sender
+// rewritten using Sender Rewriting Scheme (SRS), so the SPF check on the
+// next hop pass.
 //
 // # Limitations
 //
@@ -0,0 +1,133 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shuLhan/share/lib/ascii"
+)
+
+// newDSN create delivery status notification (DSN) for failed mail, as
+// defined in RFC 3464.
+//
+// The DSN is sent from null reverse-path to the originator of mail, using
+// the "multipart/report" content type (RFC 6522) with three parts:
+// human readable explanation, the "message/delivery-status", and the
+// headers of original message.
+func (srv *Server) newDSN(mail *MailTx) (dsn *MailTx) {
+	var (
+		reportingMTA = srv.Env.PrimaryDomain.Name
+		timeNow      = time.Now()
+		boundary     = strconv.FormatInt(timeNow.UnixNano(), 36) + `/` + reportingMTA
+		rcpt         = mail.Recipients[0]
+		status       = dsnStatus(mail.LastError)
+
+		buf bytes.Buffer
+	)
+
+	fmt.Fprintf(&buf, "From: Mail Delivery System <MAILER-DAEMON@%s>\r\n", reportingMTA)
+	fmt.Fprintf(&buf, "To: <%s>\r\n", mail.From)
+	buf.WriteString("Subject: Undelivered Mail Returned to Sender\r\n")
+	fmt.Fprintf(&buf, "Date: %s\r\n", timeNow.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%d.dsn@%s>\r\n", timeNow.UnixNano(), reportingMTA)
+	buf.WriteString("Auto-Submitted: auto-replied\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/report; report-type=delivery-status;\r\n\tboundary=\"%s\"\r\n", boundary)
+	buf.WriteString("\r\n")
+	buf.WriteString("This is a MIME-encapsulated message.\r\n")
+	buf.WriteString("\r\n")
+
+	// The human readable part.
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.WriteString("Content-Type: text/plain; charset=us-ascii\r\n")
+	buf.WriteString("\r\n")
+	fmt.Fprintf(&buf, "This is the mail system at host %s.\r\n", reportingMTA)
+	buf.WriteString("\r\n")
+	buf.WriteString("Your message could not be delivered to one or more recipients.\r\n")
+	buf.WriteString("\r\n")
+	fmt.Fprintf(&buf, "<%s>: %s\r\n", rcpt, mail.LastError)
+	buf.WriteString("\r\n")
+
+	// The machine readable part.
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.WriteString("Content-Type: message/delivery-status\r\n")
+	buf.WriteString("\r\n")
+	fmt.Fprintf(&buf, "Reporting-MTA: dns; %s\r\n", reportingMTA)
+	if !mail.Received.IsZero() {
+		fmt.Fprintf(&buf, "Arrival-Date: %s\r\n", mail.Received.Format(time.RFC1123Z))
+	}
+	buf.WriteString("\r\n")
+	fmt.Fprintf(&buf, "Final-Recipient: rfc822; %s\r\n", rcpt)
+	buf.WriteString("Action: failed\r\n")
+	fmt.Fprintf(&buf, "Status: %s\r\n", status)
+	if isSMTPReply(mail.LastError) {
+		fmt.Fprintf(&buf, "Diagnostic-Code: smtp; %s\r\n", mail.LastError)
+	}
+	fmt.Fprintf(&buf, "Last-Attempt-Date: %s\r\n", timeNow.Format(time.RFC1123Z))
+	buf.WriteString("\r\n")
+
+	// The original message headers.
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	buf.WriteString("Content-Type: text/rfc822-headers\r\n")
+	buf.WriteString("\r\n")
+
+	var (
+		hdr    = mail.Data
+		endHdr = bytes.Index(hdr, []byte("\r\n\r\n"))
+	)
+	if endHdr >= 0 {
+		hdr = hdr[:endHdr+2]
+	}
+	buf.Write(hdr)
+	if !bytes.HasSuffix(hdr, []byte("\r\n")) {
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	dsn = NewMailTx(``, []string{mail.From}, buf.Bytes())
+
+	return dsn
+}
+
+// dsnStatus return the status code for DSN (RFC 3463) from the error
+// message.
+// The error message may start with SMTP reply code, followed by enhanced
+// status code.
+// If no enhanced status code found, it will return "5.0.0".
+func dsnStatus(msg string) string {
+	var fields = strings.Fields(msg)
+	for x, f := range fields {
+		if x > 1 {
+			break
+		}
+		var codes = strings.Split(f, `.`)
+		if len(codes) != 3 {
+			continue
+		}
+		if codes[0] != `2` && codes[0] != `4` && codes[0] != `5` {
+			continue
+		}
+		return f
+	}
+	return `5.0.0`
+}
+
+// isSMTPReply return true if msg start with three digits SMTP reply code.
+func isSMTPReply(msg string) bool {
+	if len(msg) < 4 {
+		return false
+	}
+	for x := 0; x < 3; x++ {
+		if !ascii.IsDigit(msg[x]) {
+			return false
+		}
+	}
+	return msg[3] == ' '
+}
@@ -4,6 +4,8 @@
 
 package smtp
 
+import "strings"
+
 // Environment contains SMTP server environment.
 type Environment struct {
 	// PrimaryDomain of the SMTP server.
@@ -13,4 +15,60 @@ type Environment struct {
 	// VirtualDomains contains list of virtual domain handled by server.
 	// This field is optional.
 	VirtualDomains map[string]*Domain
+
+	// Aliases map the address in our domains into one or more
+	// recipient addresses.
+	// This field is optional.
+	Aliases map[string][]string
+
+	// CatchAll map the domain name into the address that receive the
+	// mail for unknown recipient in that domain.
+	// This field is optional.
+	CatchAll map[string]string
+
+	// Lists contains the mailing lists, indexed by list address.
+	// This field is optional.
+	Lists map[string]*MailingList
+
+	// SRS define the Sender Rewriting Scheme for mail that forwarded
+	// to other domain.
+	// This field is optional, if its nil the envelope sender of
+	// forwarded mail is not rewritten.
+	SRS *SRS
+
+	// RecipientDelimiter define the character that separate the local
+	// part and its extension, for example "+" in "alice+news@example.com"
+	// which is delivered to "alice@example.com".
+	// This field is optional, if its empty the plus-addressing is
+	// disabled.
+	RecipientDelimiter string
+}
+
+// lookupDomain return the primary or virtual domain by its name, or nil if
+// the domain is not handled by server.
+func (env *Environment) lookupDomain(name string) *Domain {
+	name = strings.ToLower(name)
+	if env.PrimaryDomain != nil && env.PrimaryDomain.Name == name {
+		return env.PrimaryDomain
+	}
+	for _, domain := range env.VirtualDomains {
+		if domain.Name == name {
+			return domain
+		}
+	}
+	return nil
+}
+
+// LookupAccount return the account by its address "local@domain" in the
+// primary or virtual domains, or nil if the account does not exist.
+func (env *Environment) LookupAccount(address string) *Account {
+	var at = strings.LastIndexByte(address, '@')
+	if at < 0 {
+		return nil
+	}
+	var domain = env.lookupDomain(address[at+1:])
+	if domain == nil {
+		return nil
+	}
+	return domain.Accounts[strings.ToLower(address[:at])]
 }
@@ -30,10 +30,10 @@ var (
 		Message: "Syntax error, command unknown",
 	}
 	// TODO:
-	//	errInProcessing = &errors.E{
-	//		Code:    StatusLocalError,
-	//		Message: "Local error in processing",
-	//	}
+	errInProcessing = &errors.E{
+		Code:    StatusLocalError,
+		Message: "4.3.0 Local error in processing",
+	}
 
 	errAuthMechanism = &errors.E{
 		Code:    StatusParamUnimplemented,
@@ -43,8 +43,58 @@ var (
 		Code:    StatusNotAuthenticated,
 		Message: "5.7.0 Authentication required",
 	}
+	errRelayDenied = &errors.E{
+		Code:    StatusMailboxNotFound,
+		Message: "5.7.1 Relaying denied",
+	}
 	errBadSequence = &errors.E{
 		Code:    StatusCmdBadSequence,
 		Message: "Bad sequence of commands",
 	}
+
+	// See RFC 1870, section 6.
+	errMessageTooBig = &errors.E{
+		Code:    StatusMailNoStorage,
+		Message: "5.3.4 Message size exceeds fixed maximum message size",
+	}
+
+	// See RFC 6152, section 3.
+	errBodyInvalid = &errors.E{
+		Code:    StatusCmdSyntaxError,
+		Message: "5.5.4 Invalid BODY parameter",
+	}
+
+	// See RFC 6531, section 3.7.4.
+	errUTF8Required = &errors.E{
+		Code:    StatusMailboxIncorrect,
+		Message: "5.6.7 Non-ASCII address require SMTPUTF8",
+	}
+
+	errTooManyConnections = &errors.E{
+		Code:    StatusShuttingDown,
+		Message: "4.7.0 Too many connections, try again later",
+	}
+	errTooManyCommands = &errors.E{
+		Code:    StatusShuttingDown,
+		Message: "4.7.0 Too many commands, try again later",
+	}
+
+	// See RFC 5321, section 4.5.3.1.10.
+	errTooManyRecipients = &errors.E{
+		Code:    StatusNoStorage,
+		Message: "4.5.3 Too many recipients",
+	}
+	errGreylisted = &errors.E{
+		Code:    StatusMailboxUnavailable,
+		Message: "4.7.1 Greylisted, please try again later",
+	}
+	errEarlyTalker = &errors.E{
+		Code:    StatusTransactionFailed,
+		Message: "5.5.0 Protocol violation, data sent before greeting",
+	}
+
+	errAuthCancelled = &errors.E{
+		Code:    StatusCmdSyntaxError,
+		Message: "Authentication cancelled",
+	}
 )
@@ -23,6 +23,38 @@ type Extension interface {
 	ValidateCommand(cmd *Command) error
 }
 
-var defaultExts = []Extension{
-	&extDSN{},
+// defaultExts return the list of extensions that enabled by default on
+// server.
+func (srv *Server) defaultExts() []Extension {
+	return []Extension{
+		&extDSN{},
+		&extKeyword{name: `PIPELINING`},
+		&extSize{max: srv.MaxMessageSize},
+		&ext8BitMIME{},
+		&extSMTPUTF8{},
+		&extKeyword{name: `ENHANCEDSTATUSCODES`},
+		&extKeyword{name: `CHUNKING`},
+	}
+}
+
+// extKeyword is an extension that does not have parameters, for example
+// PIPELINING (RFC 2920), ENHANCEDSTATUSCODES (RFC 2034), and CHUNKING
+// (RFC 3030).
+type extKeyword struct {
+	name string
+}
+
+// Name return the extension name.
+func (ext *extKeyword) Name() string {
+	return ext.name
+}
+
+// Params return empty string.
+func (ext *extKeyword) Params() string {
+	return ``
+}
+
+// ValidateCommand always return nil.
+func (ext *extKeyword) ValidateCommand(_ *Command) error {
+	return nil
 }
@@ -0,0 +1,38 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import "strings"
+
+// ext8BitMIME implement the 8BITMIME extension (RFC 6152).
+type ext8BitMIME struct {
+}
+
+// Name return the name of extension, which is "8BITMIME".
+func (ext *ext8BitMIME) Name() string {
+	return `8BITMIME`
+}
+
+// Params return the SMTP extension parameters.
+func (ext *ext8BitMIME) Params() string {
+	return ``
+}
+
+// ValidateCommand validate the BODY parameter in MAIL command, which
+// must be either "7BIT" or "8BITMIME".
+func (ext *ext8BitMIME) ValidateCommand(cmd *Command) error {
+	if cmd == nil || cmd.Kind != CommandMAIL {
+		return nil
+	}
+
+	var v, ok = cmd.param(`BODY`)
+	if !ok {
+		return nil
+	}
+	if strings.EqualFold(v, `7BIT`) || strings.EqualFold(v, `8BITMIME`) {
+		return nil
+	}
+	return errBodyInvalid
+}
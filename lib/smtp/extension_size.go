@@ -0,0 +1,47 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import "strconv"
+
+// extSize implement the SIZE extension (RFC 1870).
+type extSize struct {
+	max int64
+}
+
+// Name return the name of extension, which is "SIZE".
+func (ext *extSize) Name() string {
+	return `SIZE`
+}
+
+// Params return the maximum message size.
+func (ext *extSize) Params() string {
+	return strconv.FormatInt(ext.max, 10)
+}
+
+// ValidateCommand validate the SIZE parameter in MAIL command.
+// It will return errMessageTooBig if the declared size is larger than
+// maximum message size.
+func (ext *extSize) ValidateCommand(cmd *Command) (err error) {
+	if cmd == nil || cmd.Kind != CommandMAIL {
+		return nil
+	}
+
+	var v, ok = cmd.param(`SIZE`)
+	if !ok {
+		return nil
+	}
+
+	var size int64
+
+	size, err = strconv.ParseInt(v, 10, 64)
+	if err != nil || size < 0 {
+		return errCmdSyntaxError
+	}
+	if ext.max > 0 && size > ext.max {
+		return errMessageTooBig
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+// extSMTPUTF8 implement the SMTPUTF8 extension (RFC 6531).
+type extSMTPUTF8 struct {
+}
+
+// Name return the name of extension, which is "SMTPUTF8".
+func (ext *extSMTPUTF8) Name() string {
+	return `SMTPUTF8`
+}
+
+// Params return the SMTP extension parameters.
+func (ext *extSMTPUTF8) Params() string {
+	return ``
+}
+
+// ValidateCommand validate the MAIL command.
+// The SMTPUTF8 parameter must not have value and the non-ASCII
+// reverse-path require the SMTPUTF8 parameter.
+func (ext *extSMTPUTF8) ValidateCommand(cmd *Command) error {
+	if cmd == nil || cmd.Kind != CommandMAIL {
+		return nil
+	}
+
+	var v, ok = cmd.param(`SMTPUTF8`)
+	if ok {
+		if len(v) != 0 {
+			return errCmdSyntaxError
+		}
+		return nil
+	}
+	if !isASCII(cmd.Arg) {
+		return errUTF8Required
+	}
+	return nil
+}
+
+// isASCII return true if all characters in s is ASCII.
+func isASCII(s string) bool {
+	for x := 0; x < len(s); x++ {
+		if s[x] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
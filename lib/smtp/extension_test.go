@@ -0,0 +1,196 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestServer_extensions(t *testing.T) {
+	type testCase struct {
+		desc string
+		cmd  string
+		exp  []string
+	}
+
+	var cases = []testCase{{
+		desc: `With SIZE exceeding limit`,
+		cmd:  "MAIL FROM:<first@mail.kilabit.local> SIZE=99999999999\r\n",
+		exp:  []string{`552 5.3.4 Message size exceeds fixed maximum message size`},
+	}, {
+		desc: `With invalid BODY`,
+		cmd:  "MAIL FROM:<first@mail.kilabit.local> BODY=BINARYMIME\r\n",
+		exp:  []string{`501 5.5.4 Invalid BODY parameter`},
+	}, {
+		desc: `With non-ASCII address without SMTPUTF8`,
+		cmd:  "MAIL FROM:<pelé@mail.kilabit.local>\r\n",
+		exp:  []string{`553 5.6.7 Non-ASCII address require SMTPUTF8`},
+	}, {
+		desc: `With pipelining and SMTPUTF8`,
+		cmd: "MAIL FROM:<pelé@mail.kilabit.local> SMTPUTF8 BODY=8BITMIME\r\n" +
+			"RCPT TO:<first@mail.kilabit.local>\r\n" +
+			"RSET\r\n",
+		exp: []string{`250 OK`, `250 OK`, `250 OK`},
+	}, {
+		desc: `With BDAT before RCPT`,
+		cmd:  "BDAT 5 LAST\r\nHello",
+		exp:  []string{`503 Bad sequence of commands`},
+	}, {
+		desc: `With BDAT`,
+		cmd: "MAIL FROM:<first@mail.kilabit.local>\r\n" +
+			"RCPT TO:<second@mail.kilabit.local>\r\n" +
+			"BDAT 27\r\nSubject: chunking\r\n\r\n.Hello" +
+			"BDAT 2\r\n\r\n" +
+			"DATA\r\n" +
+			"MAIL FROM:<first@mail.kilabit.local>\r\n" +
+			"RCPT TO:<second@mail.kilabit.local>\r\n" +
+			"BDAT 27\r\nSubject: chunking\r\n\r\n.Hello" +
+			"BDAT 0 LAST\r\n",
+		exp: []string{
+			`250 OK`,
+			`250 OK`,
+			`250 2.0.0 27 octets received`,
+			`250 2.0.0 2 octets received`,
+			`503 Bad sequences of commands`,
+			`250 OK`,
+			`250 OK`,
+			`250 2.0.0 27 octets received`,
+			`250 OK`,
+		},
+	}}
+
+	var (
+		cl = testNewClient(true)
+
+		c    testCase
+		line string
+		err  error
+	)
+	for _, c = range cases {
+		t.Log(c.desc)
+
+		_, err = cl.conn.Write([]byte(c.cmd))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for range c.exp {
+			line, err = cl.reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, strings.TrimSpace(line))
+		}
+		test.Assert(t, `replies`, c.exp, got)
+	}
+
+	var mail = testHandler.waitMail(5 * time.Second)
+	if mail == nil {
+		t.Fatal(`timeout waiting for mail`)
+	}
+
+	test.Assert(t, `Data has suffix`, true,
+		strings.HasSuffix(string(mail.Data), "Subject: chunking\r\n\r\n..Hello"))
+}
+
+// TestServer_extensions_reset test that the rejected DATA and BDAT
+// end the mail transaction.
+func TestServer_extensions_reset(t *testing.T) {
+	var (
+		env = &Environment{
+			PrimaryDomain: NewDomain(testDomain, nil),
+		}
+		srv = &Server{
+			Env:            env,
+			Handler:        NewLocalHandler(env),
+			MaxMessageSize: 32,
+			running:        true,
+		}
+
+		ln, err = net.Listen(`tcp`, `127.0.0.1:0`)
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	go func() {
+		for {
+			var conn, errAccept = ln.Accept()
+			if errAccept != nil {
+				return
+			}
+			go srv.handle(newReceiver(conn, receiverModeServer))
+		}
+	}()
+
+	var conn net.Conn
+
+	conn, err = net.Dial(`tcp`, ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var (
+		body = strings.Repeat(`x`, 40)
+		cmds = []string{
+			``,
+			"HELO mx.other.test\r\n",
+			"MAIL FROM:<alice@other.test>\r\n",
+			"RCPT TO:<bob@" + testDomain + ">\r\n",
+			"DATA\r\n",
+			body + "\r\n.\r\n",
+			"DATA\r\n",
+			"MAIL FROM:<alice@other.test>\r\n",
+			"RCPT TO:<bob@" + testDomain + ">\r\n",
+			"BDAT 5\r\nHello",
+			"BDAT 40\r\n" + body,
+			"BDAT 6 LAST\r\n World",
+		}
+		exp = []string{
+			`220 ` + testDomain,
+			`250 ` + testDomain,
+			`250 OK`,
+			`250 OK`,
+			`354 Start mail input.`,
+			`552 5.3.4 Message size exceeds fixed maximum message size`,
+			`503 Bad sequences of commands`,
+			`250 OK`,
+			`250 OK`,
+			`250 2.0.0 5 octets received`,
+			`552 5.3.4 Message size exceeds fixed maximum message size`,
+			`503 Bad sequence of commands`,
+		}
+
+		reader = bufio.NewReader(conn)
+		got    []string
+		line   string
+	)
+	for _, cmd := range cmds {
+		if len(cmd) > 0 {
+			_, err = conn.Write([]byte(cmd))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.TrimSpace(line))
+	}
+
+	test.Assert(t, `replies`, exp, got)
+}
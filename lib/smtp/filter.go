@@ -0,0 +1,152 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"errors"
+
+	liberrors "github.com/shuLhan/share/lib/errors"
+)
+
+// FilterStage define the stage of SMTP transaction where the Filter is
+// called.
+type FilterStage int
+
+// List of filter stages.
+const (
+	// FilterStageConnect is called after client connected, before the
+	// server send the greeting.
+	FilterStageConnect FilterStage = iota
+
+	// FilterStageHelo is called on HELO or EHLO command, before the
+	// server send the reply.
+	FilterStageHelo
+
+	// FilterStageMail is called on MAIL command, after the reverse-path
+	// is set in FilterTx Mail.
+	FilterStageMail
+
+	// FilterStageRcpt is called on each RCPT command, with the
+	// recipient in FilterTx Rcpt.
+	FilterStageRcpt
+
+	// FilterStageData is called at the end of DATA or the last BDAT,
+	// after the message is received and checked by Inbound policy.
+	FilterStageData
+)
+
+// String return the name of stage.
+func (stage FilterStage) String() string {
+	switch stage {
+	case FilterStageConnect:
+		return `connect`
+	case FilterStageHelo:
+		return `helo`
+	case FilterStageMail:
+		return `mail`
+	case FilterStageRcpt:
+		return `rcpt`
+	case FilterStageData:
+		return `data`
+	}
+	return `unknown`
+}
+
+// Filter define an interface to inspect and modify the mail transaction on
+// each stage of SMTP session, similar to milter.
+//
+// The filters are called in the order of Server Filters.
+// If the filter return an error, the command is rejected and the next
+// filters are not called.
+// If the error is *errors.E, its Code and Message is used as the reply;
+// otherwise the command is rejected with 451 (local error).
+//
+// Filter can add header field to the message using FilterTx AddHeader,
+// or mark the message for quarantine by setting the Quarantine field in
+// FilterTx Mail.
+type Filter interface {
+	Filter(stage FilterStage, ftx *FilterTx) error
+}
+
+// FilterTx contains the state of SMTP session passed to Filter.
+type FilterTx struct {
+	// Mail contains the current mail transaction.
+	// The Data is available only on FilterStageData.
+	Mail *MailTx
+
+	// ClientAddress contains the IP address and port of client.
+	ClientAddress string
+
+	// ClientDomain contains the domain name from HELO or EHLO command.
+	ClientDomain string
+
+	// Username contains the authenticated account on message
+	// submission, or empty on port 25.
+	Username string
+
+	// Rcpt contains the recipient of current RCPT command.
+	// It is set only on FilterStageRcpt.
+	Rcpt string
+
+	// headers contains the header fields that will be inserted at the
+	// top of message.
+	headers []byte
+}
+
+// AddHeader add the header field that will be inserted at the top of
+// message, in the same order as its added.
+// The header fields added before FilterStageData are kept until the mail
+// transaction is reset.
+func (ftx *FilterTx) AddHeader(name, value string) {
+	ftx.headers = append(ftx.headers, name...)
+	ftx.headers = append(ftx.headers, `: `...)
+	ftx.headers = append(ftx.headers, value...)
+	ftx.headers = append(ftx.headers, "\r\n"...)
+}
+
+// reset clear the state of current mail transaction.
+func (ftx *FilterTx) reset() {
+	ftx.Rcpt = ``
+	ftx.headers = nil
+}
+
+// runFilters call each Filter on specific stage.
+// At FilterStageData, the header fields added by filters are inserted
+// into the message.
+func (srv *Server) runFilters(recv *receiver, stage FilterStage) (err error) {
+	if len(srv.Filters) == 0 {
+		return nil
+	}
+
+	var ftx = recv.ftx
+
+	ftx.Mail = recv.mail
+	ftx.ClientDomain = recv.clientDomain
+	ftx.Username = recv.username
+
+	for _, filter := range srv.Filters {
+		err = filter.Filter(stage, ftx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if stage == FilterStageData && len(ftx.headers) > 0 {
+		recv.mail.Data = append(ftx.headers, recv.mail.Data...)
+		ftx.headers = nil
+	}
+	return nil
+}
+
+// filterReply convert the error from Filter into reply.
+func filterReply(err error) (reply *liberrors.E) {
+	if errors.As(err, &reply) && reply.Code != 0 {
+		return reply
+	}
+	return &liberrors.E{
+		Code:    StatusLocalError,
+		Message: err.Error(),
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/shuLhan/share/lib/email"
+	liberrors "github.com/shuLhan/share/lib/errors"
+)
+
+// DefaultBlockedExtensions contains list of file name extensions of
+// executable files that are blocked by AttachmentFilter by default.
+var DefaultBlockedExtensions = []string{
+	`.bat`, `.cmd`, `.com`, `.cpl`, `.exe`, `.hta`, `.jar`, `.js`,
+	`.jse`, `.lnk`, `.msi`, `.pif`, `.ps1`, `.scr`, `.vbe`, `.vbs`,
+	`.wsf`,
+}
+
+// AttachmentFilter implement the Filter that block the message with
+// attachment based on their file name extension or content type, at the
+// end of DATA.
+type AttachmentFilter struct {
+	// Extensions contains list of blocked file name extensions, for
+	// example ".exe".
+	// This field is optional, if its empty and ContentTypes is empty,
+	// it will default to DefaultBlockedExtensions.
+	Extensions []string
+
+	// ContentTypes contains list of blocked content types, for example
+	// "application/x-msdownload".
+	// This field is optional.
+	ContentTypes []string
+
+	// Quarantine mark the message for quarantine, with header field
+	// "X-Attachment-Warning", instead of rejecting it.
+	Quarantine bool
+}
+
+// Filter check the message attachments at FilterStageData.
+func (af *AttachmentFilter) Filter(stage FilterStage, ftx *FilterTx) (err error) {
+	if stage != FilterStageData {
+		return nil
+	}
+
+	var msg *email.Message
+
+	msg, _, err = email.ParseMessage(unstuff(ftx.Mail.Data))
+	if err != nil || msg == nil {
+		// Malformed message is handled by HeaderFilter.
+		return nil
+	}
+
+	var name string
+	for _, mime := range msg.Attachments() {
+		if af.isBlocked(mime) {
+			name = mime.Filename()
+			break
+		}
+	}
+	if len(name) == 0 {
+		return nil
+	}
+	if af.Quarantine {
+		ftx.Mail.Quarantine = true
+		ftx.AddHeader(`X-Attachment-Warning`, `blocked attachment "`+name+`"`)
+		return nil
+	}
+	return &liberrors.E{
+		Code:    StatusTransactionFailed,
+		Message: `5.7.1 Attachment type is not allowed: ` + name,
+	}
+}
+
+// isBlocked return true if the MIME file name extension or content type
+// is blocked.
+func (af *AttachmentFilter) isBlocked(mime *email.MIME) bool {
+	var exts = af.Extensions
+	if len(exts) == 0 && len(af.ContentTypes) == 0 {
+		exts = DefaultBlockedExtensions
+	}
+
+	var ext = filepath.Ext(mime.Filename())
+	for _, blocked := range exts {
+		if strings.EqualFold(ext, blocked) {
+			return true
+		}
+	}
+
+	var ct = mime.ContentType()
+	if ct == nil {
+		return false
+	}
+	var mimeType = ct.Top + `/` + ct.Sub
+	for _, blocked := range af.ContentTypes {
+		if strings.EqualFold(mimeType, blocked) {
+			return true
+		}
+	}
+	return false
+}
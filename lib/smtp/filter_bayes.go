@@ -0,0 +1,316 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/shuLhan/share/lib/email"
+	"github.com/shuLhan/share/lib/email/maildir"
+	liberrors "github.com/shuLhan/share/lib/errors"
+	"github.com/shuLhan/share/lib/mining/classifier"
+)
+
+const (
+	defBayesQuarantineScore = 0.9
+
+	// bayesMaxTokens is the maximum number of the most interesting
+	// tokens used to compute the score.
+	bayesMaxTokens = 15
+
+	// bayesStrength and bayesPrior is the weight and probability of
+	// token that has not been seen before, using the Robinson method.
+	bayesStrength = 1.0
+	bayesPrior    = 0.5
+
+	bayesMinTokenLength = 3
+	bayesMaxTokenLength = 40
+
+	// bayesClassSpam and bayesClassHam is the value space of class
+	// used in the confusion matrix, with spam as positive class "1".
+	bayesClassSpam = `1`
+	bayesClassHam  = `0`
+)
+
+// BayesFilter implement the Filter that score the message using naive
+// Bayesian classifier at the end of DATA.
+//
+// The classifier is trained using the messages that are known as spam
+// and ham (non-spam), for example from the "Junk" and "INBOX" maildir
+// folders.
+// The score is the probability of message as spam, between 0 and 1,
+// computed from the most interesting tokens in the message.
+//
+// Each scored message has the header field "X-Spam-Score".
+// The message with score greater or equal to QuarantineScore is marked
+// for quarantine, with header field "X-Spam-Flag: YES".
+//
+// The accuracy of the trained filter can be measured using Evaluate,
+// which return the confusion matrix and statistic from package
+// lib/mining/classifier.
+type BayesFilter struct {
+	// spam and ham contains the number of messages that contains the
+	// token.
+	spam map[string]int
+	ham  map[string]int
+
+	// QuarantineScore define the minimum score to mark the message for
+	// quarantine.
+	// This field is optional, default to 0.9.
+	QuarantineScore float64
+
+	// RejectScore define the minimum score to reject the message.
+	// This field is optional, if its zero the message is never
+	// rejected.
+	RejectScore float64
+
+	nspam int
+	nham  int
+
+	mtx sync.RWMutex
+}
+
+// NewBayesFilter create new BayesFilter without training data.
+func NewBayesFilter() (bf *BayesFilter) {
+	bf = &BayesFilter{
+		spam:            map[string]int{},
+		ham:             map[string]int{},
+		QuarantineScore: defBayesQuarantineScore,
+	}
+	return bf
+}
+
+// Filter score the message at FilterStageData.
+// The message is not scored if the filter has not been trained with both
+// spam and ham messages.
+func (bf *BayesFilter) Filter(stage FilterStage, ftx *FilterTx) error {
+	if stage != FilterStageData {
+		return nil
+	}
+
+	bf.mtx.RLock()
+	var isTrained = bf.nspam > 0 && bf.nham > 0
+	bf.mtx.RUnlock()
+	if !isTrained {
+		return nil
+	}
+
+	var score = bf.Score(unstuff(ftx.Mail.Data))
+
+	if bf.RejectScore > 0 && score >= bf.RejectScore {
+		return &liberrors.E{
+			Code:    StatusTransactionFailed,
+			Message: `5.7.1 Message content rejected as spam`,
+		}
+	}
+
+	ftx.AddHeader(`X-Spam-Score`, strconv.FormatFloat(score, 'f', 3, 64))
+
+	var quarantineScore = bf.QuarantineScore
+	if quarantineScore <= 0 {
+		quarantineScore = defBayesQuarantineScore
+	}
+	if score >= quarantineScore {
+		ftx.Mail.Quarantine = true
+		ftx.AddHeader(`X-Spam-Flag`, `YES`)
+	}
+	return nil
+}
+
+// Score return the probability of raw message as spam, between 0 and 1.
+func (bf *BayesFilter) Score(msg []byte) float64 {
+	var tokens = bayesTokenize(msg)
+
+	bf.mtx.RLock()
+	var probs = make([]float64, 0, len(tokens))
+	for token := range tokens {
+		probs = append(probs, bf.tokenProbability(token))
+	}
+	bf.mtx.RUnlock()
+
+	// Use the tokens that are far from neutral.
+	sort.Slice(probs, func(x, y int) bool {
+		return math.Abs(probs[x]-0.5) > math.Abs(probs[y]-0.5)
+	})
+	if len(probs) > bayesMaxTokens {
+		probs = probs[:bayesMaxTokens]
+	}
+
+	// Combine the probabilities in log space to avoid underflow,
+	// p = 1 / (1 + e^eta), where eta = sum(ln(1-p_i) - ln(p_i)).
+	var eta float64
+	for _, p := range probs {
+		eta += math.Log(1-p) - math.Log(p)
+	}
+	return 1 / (1 + math.Exp(eta))
+}
+
+// Train the filter using raw message as spam or ham.
+func (bf *BayesFilter) Train(msg []byte, isSpam bool) {
+	var tokens = bayesTokenize(msg)
+
+	bf.mtx.Lock()
+	if isSpam {
+		bf.nspam++
+	} else {
+		bf.nham++
+	}
+	for token := range tokens {
+		if isSpam {
+			bf.spam[token]++
+		} else {
+			bf.ham[token]++
+		}
+	}
+	bf.mtx.Unlock()
+}
+
+// Evaluate classify the raw messages that are known as spam and ham
+// using QuarantineScore, and return the confusion matrix and statistic of
+// the classification, with spam as the positive class.
+// The index of sample in the confusion matrix is the index of message in
+// spam followed by the index of message in ham.
+func (bf *BayesFilter) Evaluate(spam, ham [][]byte) (cm *classifier.CM, stat *classifier.Stat) {
+	var (
+		vs       = []string{bayesClassSpam, bayesClassHam}
+		n        = len(spam) + len(ham)
+		listID   = make([]int, 0, n)
+		actuals  = make([]string, 0, n)
+		predicts = make([]string, 0, n)
+
+		quarantineScore = bf.QuarantineScore
+		rt              classifier.Runtime
+		msg             []byte
+		x               int
+	)
+	if quarantineScore <= 0 {
+		quarantineScore = defBayesQuarantineScore
+	}
+
+	for x = 0; x < n; x++ {
+		if x < len(spam) {
+			msg = spam[x]
+			actuals = append(actuals, bayesClassSpam)
+		} else {
+			msg = ham[x-len(spam)]
+			actuals = append(actuals, bayesClassHam)
+		}
+		listID = append(listID, x)
+		if bf.Score(msg) >= quarantineScore {
+			predicts = append(predicts, bayesClassSpam)
+		} else {
+			predicts = append(predicts, bayesClassHam)
+		}
+	}
+
+	stat = &classifier.Stat{}
+	cm = rt.ComputeCM(listID, vs, actuals, predicts)
+	rt.ComputeStatFromCM(stat, cm)
+
+	return cm, stat
+}
+
+// TrainFolder train the filter using all messages in maildir folder as
+// spam or ham.
+func (bf *BayesFilter) TrainFolder(folder *maildir.Folder, isSpam bool) (err error) {
+	var (
+		logp = `TrainFolder`
+
+		list []*maildir.Message
+		raw  []byte
+	)
+
+	list, err = folder.List()
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	for _, msg := range list {
+		raw, err = folder.Read(msg.Name)
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+		bf.Train(raw, isSpam)
+	}
+	return nil
+}
+
+// tokenProbability return the probability of message that contains the
+// token is spam, adjusted using the Robinson method for rare tokens.
+func (bf *BayesFilter) tokenProbability(token string) float64 {
+	var (
+		nspam = float64(bf.spam[token])
+		nham  = float64(bf.ham[token])
+		n     = nspam + nham
+	)
+	if n == 0 {
+		return bayesPrior
+	}
+
+	var spamRatio, hamRatio float64
+	if bf.nspam > 0 {
+		spamRatio = nspam / float64(bf.nspam)
+	}
+	if bf.nham > 0 {
+		hamRatio = nham / float64(bf.nham)
+	}
+
+	var p = spamRatio / (spamRatio + hamRatio)
+	p = (bayesStrength*bayesPrior + n*p) / (bayesStrength + n)
+
+	// Keep the probability away from 0 and 1, so single token cannot
+	// decide the score.
+	return math.Min(math.Max(p, 0.01), 0.99)
+}
+
+// bayesTokenize return the unique tokens from the message subject and
+// text body parts.
+// The subject tokens is prefixed with "subject:" to differentiate them
+// from body tokens.
+func bayesTokenize(raw []byte) (tokens map[string]struct{}) {
+	tokens = map[string]struct{}{}
+
+	var msg, _, err = email.ParseMessage(raw)
+	if err != nil || msg == nil {
+		addTokens(tokens, ``, string(raw))
+		return tokens
+	}
+
+	addTokens(tokens, `subject:`, msg.Subject())
+
+	_ = msg.Walk(func(mime *email.MIME) error {
+		var ct = mime.ContentType()
+		if ct != nil && !strings.EqualFold(ct.Top, `text`) {
+			return nil
+		}
+		var content, errDecode = mime.Decode()
+		if errDecode != nil {
+			content = mime.Content
+		}
+		addTokens(tokens, ``, string(content))
+		return nil
+	})
+	return tokens
+}
+
+// addTokens split the text into lower case words and add it with prefix
+// into tokens.
+func addTokens(tokens map[string]struct{}, prefix, text string) {
+	var words = strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) &&
+			r != '$' && r != '\'' && r != '-'
+	})
+	for _, word := range words {
+		if len(word) < bayesMinTokenLength || len(word) > bayesMaxTokenLength {
+			continue
+		}
+		tokens[prefix+word] = struct{}{}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"fmt"
+
+	"github.com/shuLhan/share/lib/email"
+	liberrors "github.com/shuLhan/share/lib/errors"
+)
+
+// headerFieldsOnce contains list of header fields that must occur at most
+// once, based on RFC 5322 section 3.6.
+var headerFieldsOnce = []email.FieldType{
+	email.FieldTypeDate,
+	email.FieldTypeFrom,
+	email.FieldTypeSender,
+	email.FieldTypeReplyTo,
+	email.FieldTypeTo,
+	email.FieldTypeCC,
+	email.FieldTypeBCC,
+	email.FieldTypeMessageID,
+	email.FieldTypeInReplyTo,
+	email.FieldTypeReferences,
+	email.FieldTypeSubject,
+}
+
+// HeaderFilter implement the Filter that check the sanity of message
+// header at the end of DATA.
+//
+// The message is rejected if its header cannot be parsed, does not have
+// the "Date" or "From" field, or has more than one field that must occur
+// at most once (RFC 5322 section 3.6).
+type HeaderFilter struct {
+	// Quarantine mark the invalid message for quarantine, with header
+	// field "X-Header-Warning", instead of rejecting it.
+	Quarantine bool
+}
+
+// Filter check the message header at FilterStageData.
+func (hf *HeaderFilter) Filter(stage FilterStage, ftx *FilterTx) error {
+	if stage != FilterStageData {
+		return nil
+	}
+
+	var reason = checkHeader(unstuff(ftx.Mail.Data))
+	if len(reason) == 0 {
+		return nil
+	}
+	if hf.Quarantine {
+		ftx.Mail.Quarantine = true
+		ftx.AddHeader(`X-Header-Warning`, reason)
+		return nil
+	}
+	return &liberrors.E{
+		Code:    StatusTransactionFailed,
+		Message: `5.6.0 Invalid message header: ` + reason,
+	}
+}
+
+// checkHeader return the reason if the header of message is invalid.
+func checkHeader(raw []byte) (reason string) {
+	var hdr, _, err = email.ParseHeader(raw)
+	if err != nil || hdr == nil {
+		return `malformed header`
+	}
+
+	if len(hdr.Filter(email.FieldTypeDate)) == 0 {
+		return `missing field "Date"`
+	}
+	if len(hdr.Filter(email.FieldTypeFrom)) == 0 {
+		return `missing field "From"`
+	}
+
+	var fields []*email.Field
+	for _, ft := range headerFieldsOnce {
+		fields = hdr.Filter(ft)
+		if len(fields) > 1 {
+			return fmt.Sprintf(`duplicate field %q`, fields[0].Name)
+		}
+	}
+	return ``
+}
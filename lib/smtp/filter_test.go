@@ -0,0 +1,319 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shuLhan/share/lib/email/maildir"
+	liberrors "github.com/shuLhan/share/lib/errors"
+	"github.com/shuLhan/share/lib/test"
+)
+
+// testStageFilter reject the command on each stage based on the value
+// in FilterTx.
+type testStageFilter struct{}
+
+func (testStageFilter) Filter(stage FilterStage, ftx *FilterTx) error {
+	switch stage {
+	case FilterStageHelo:
+		if ftx.ClientDomain == `bad.test` {
+			return &liberrors.E{Code: StatusTransactionFailed, Message: `5.7.1 Bad HELO`}
+		}
+	case FilterStageMail:
+		if ftx.Mail.From == `spammer@bad.test` {
+			return &liberrors.E{Code: StatusMailboxNotFound, Message: `5.7.1 Sender rejected`}
+		}
+	case FilterStageRcpt:
+		if strings.HasPrefix(ftx.Rcpt, `second@`) {
+			return &liberrors.E{Code: StatusMailboxNotFound, Message: `5.7.1 Recipient rejected`}
+		}
+	case FilterStageData:
+		return &liberrors.E{
+			Code:    StatusTransactionFailed,
+			Message: `5.7.1 Rejected ` + ftx.Mail.Recipients[0],
+		}
+	}
+	return nil
+}
+
+func TestServer_runFilters(t *testing.T) {
+	var (
+		env = &Environment{
+			PrimaryDomain: NewDomain(testDomain, nil),
+		}
+		srv = &Server{
+			Env:     env,
+			Handler: NewLocalHandler(env),
+			Filters: []Filter{testStageFilter{}},
+			running: true,
+		}
+
+		ln, err = net.Listen(`tcp`, `127.0.0.1:0`)
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	go func() {
+		for {
+			var conn, errAccept = ln.Accept()
+			if errAccept != nil {
+				return
+			}
+			go srv.handle(newReceiver(conn, receiverModeServer))
+		}
+	}()
+
+	var conn net.Conn
+
+	conn, err = net.Dial(`tcp`, ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var (
+		cmds = []string{
+			``,
+			"HELO bad.test\r\n",
+			"HELO good.test\r\n",
+			"MAIL FROM:<spammer@bad.test>\r\n",
+			"MAIL FROM:<sender@example.org>\r\n",
+			"RCPT TO:<second@" + testDomain + ">\r\n",
+			"RCPT TO:<first@" + testDomain + ">\r\n",
+			"DATA\r\n",
+			"Subject: test\r\n\r\nHello\r\n.\r\n",
+			"DATA\r\n",
+		}
+		exp = []string{
+			`220 ` + testDomain,
+			`554 5.7.1 Bad HELO`,
+			`250 ` + testDomain,
+			`550 5.7.1 Sender rejected`,
+			`250 OK`,
+			`550 5.7.1 Recipient rejected`,
+			`250 OK`,
+			`354 Start mail input.`,
+			`554 5.7.1 Rejected first@` + testDomain,
+			`503 Bad sequences of commands`,
+		}
+
+		reader = bufio.NewReader(conn)
+		got    []string
+		line   string
+	)
+	for _, cmd := range cmds {
+		if len(cmd) > 0 {
+			_, err = conn.Write([]byte(cmd))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.TrimSpace(line))
+	}
+
+	test.Assert(t, `replies`, exp, got)
+}
+
+func TestHeaderFilter(t *testing.T) {
+	type testCase struct {
+		expErr        error
+		desc          string
+		data          string
+		expHeaders    string
+		isQuarantine  bool
+		expQuarantine bool
+	}
+
+	var cases = []testCase{{
+		desc: `With valid header`,
+		data: "Date: Mon, 1 Jan 2024 00:00:00 +0000\r\nFrom: a@b.c\r\nSubject: hi\r\n\r\nbody\r\n",
+	}, {
+		desc: `Without Date`,
+		data: "From: a@b.c\r\n\r\nbody\r\n",
+		expErr: &liberrors.E{
+			Code:    StatusTransactionFailed,
+			Message: `5.6.0 Invalid message header: missing field "Date"`,
+		},
+	}, {
+		desc: `With duplicate Subject`,
+		data: "Date: Mon, 1 Jan 2024 00:00:00 +0000\r\nFrom: a@b.c\r\nSubject: a\r\nSubject: b\r\n\r\nbody\r\n",
+		expErr: &liberrors.E{
+			Code:    StatusTransactionFailed,
+			Message: `5.6.0 Invalid message header: duplicate field "subject"`,
+		},
+	}, {
+		desc:          `Without From and quarantine`,
+		data:          "Date: Mon, 1 Jan 2024 00:00:00 +0000\r\n\r\nbody\r\n",
+		isQuarantine:  true,
+		expQuarantine: true,
+		expHeaders:    "X-Header-Warning: missing field \"From\"\r\n",
+	}}
+
+	var (
+		ftx *FilterTx
+		err error
+	)
+	for _, c := range cases {
+		var hf = &HeaderFilter{Quarantine: c.isQuarantine}
+
+		ftx = &FilterTx{Mail: &MailTx{Data: []byte(c.data)}}
+
+		err = hf.Filter(FilterStageData, ftx)
+		test.Assert(t, c.desc+`: error`, c.expErr, err)
+		test.Assert(t, c.desc+`: Quarantine`, c.expQuarantine, ftx.Mail.Quarantine)
+		test.Assert(t, c.desc+`: headers`, c.expHeaders, string(ftx.headers))
+	}
+}
+
+func TestAttachmentFilter(t *testing.T) {
+	type testCase struct {
+		expErr error
+		filter *AttachmentFilter
+		desc   string
+		file   string
+		ctype  string
+	}
+
+	var cases = []testCase{{
+		desc:   `With allowed attachment`,
+		filter: &AttachmentFilter{},
+		file:   `report.pdf`,
+		ctype:  `application/pdf`,
+	}, {
+		desc:   `With default blocked extension`,
+		filter: &AttachmentFilter{},
+		file:   `invoice.EXE`,
+		ctype:  `application/octet-stream`,
+		expErr: &liberrors.E{
+			Code:    StatusTransactionFailed,
+			Message: `5.7.1 Attachment type is not allowed: invoice.EXE`,
+		},
+	}, {
+		desc: `With blocked content type`,
+		filter: &AttachmentFilter{
+			ContentTypes: []string{`application/pdf`},
+		},
+		file:  `report.pdf`,
+		ctype: `application/pdf`,
+		expErr: &liberrors.E{
+			Code:    StatusTransactionFailed,
+			Message: `5.7.1 Attachment type is not allowed: report.pdf`,
+		},
+	}}
+
+	var (
+		ftx *FilterTx
+		err error
+	)
+	for _, c := range cases {
+		var data = "From: a@b.c\r\n" +
+			"Content-Type: multipart/mixed; boundary=\"b\"\r\n" +
+			"\r\n" +
+			"--b\r\n" +
+			"Content-Type: text/plain\r\n" +
+			"\r\n" +
+			"Hello\r\n" +
+			"--b\r\n" +
+			"Content-Type: " + c.ctype + "\r\n" +
+			"Content-Disposition: attachment; filename=\"" + c.file + "\"\r\n" +
+			"\r\n" +
+			"content\r\n" +
+			"--b--\r\n"
+
+		ftx = &FilterTx{Mail: &MailTx{Data: []byte(data)}}
+
+		err = c.filter.Filter(FilterStageData, ftx)
+		test.Assert(t, c.desc, c.expErr, err)
+	}
+}
+
+func TestBayesFilter(t *testing.T) {
+	var (
+		bf      = NewBayesFilter()
+		dir     = t.TempDir()
+		spamMsg = "Subject: cheap pills\r\n\r\nBuy cheap pills now, limited offer, click here\r\n"
+		hamMsgs = []string{
+			"Subject: meeting notes\r\n\r\nThe meeting notes from yesterday are attached\r\n",
+			"Subject: lunch\r\n\r\nShall we have lunch tomorrow after the meeting?\r\n",
+		}
+
+		folder *maildir.Folder
+		err    error
+	)
+
+	folder, err = maildir.CreateFolder(dir, `.Junk`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(folder.Dir(), `cur`, `1.1.local:2,S`), []byte(spamMsg), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ftx = &FilterTx{Mail: &MailTx{Data: []byte(spamMsg)}}
+
+	err = bf.Filter(FilterStageData, ftx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Filter: not trained`, 0, len(ftx.headers))
+
+	err = bf.TrainFolder(folder, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range hamMsgs {
+		bf.Train([]byte(msg), false)
+	}
+
+	var (
+		spamScore = bf.Score([]byte("Subject: pills\r\n\r\nCheap pills, click here now\r\n"))
+		hamScore  = bf.Score([]byte("Subject: meeting\r\n\r\nThe meeting notes for tomorrow\r\n"))
+	)
+	test.Assert(t, `Score: spam`, true, spamScore >= bf.QuarantineScore)
+	test.Assert(t, `Score: ham`, true, hamScore < 0.5)
+
+	ftx = &FilterTx{Mail: &MailTx{Data: []byte(spamMsg)}}
+	err = bf.Filter(FilterStageData, ftx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Filter: Quarantine`, true, ftx.Mail.Quarantine)
+	test.Assert(t, `Filter: X-Spam-Flag`, true,
+		bytes.Contains(ftx.headers, []byte("X-Spam-Flag: YES\r\n")))
+
+	var cm, stat = bf.Evaluate(
+		[][]byte{[]byte(spamMsg)},
+		[][]byte{[]byte(hamMsgs[0]), []byte(hamMsgs[1])},
+	)
+	test.Assert(t, `Evaluate: TP`, 1, cm.TP())
+	test.Assert(t, `Evaluate: TN`, 2, cm.TN())
+	test.Assert(t, `Evaluate: FP`, 0, cm.FP())
+	test.Assert(t, `Evaluate: FN`, 0, cm.FN())
+	test.Assert(t, `Evaluate: TN indices`, []int{1, 2}, cm.TNIndices())
+	test.Assert(t, `Evaluate: Accuracy`, 1.0, stat.Accuracy)
+
+	bf.RejectScore = 0.95
+	err = bf.Filter(FilterStageData, &FilterTx{Mail: &MailTx{Data: []byte(spamMsg)}})
+	test.Assert(t, `Filter: reject`, &liberrors.E{
+		Code:    StatusTransactionFailed,
+		Message: `5.7.1 Message content rejected as spam`,
+	}, err)
+}
@@ -0,0 +1,290 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defGreylistDelay    = 5 * time.Minute
+	defGreylistExpiry   = 4 * time.Hour
+	defGreylistLifetime = 36 * 24 * time.Hour
+)
+
+// Greylist implement the triplet based greylisting, where the triplet is
+// the client network, the sender address, and the recipient address.
+//
+// The first delivery attempt for unknown triplet is rejected temporarily.
+// If the client retry after Delay and before Expiry, the triplet is
+// passed and any further delivery with the same triplet is accepted until
+// Lifetime.
+//
+// The state of triplets is persisted into file, so it survive the server
+// restart.
+// The file is written in the background only when new triplet is created
+// or the triplet is passed, so the Check does not wait for it.
+// Use [Greylist.Flush] to wait until the state has been written.
+type Greylist struct {
+	entries map[string]*greylistEntry
+
+	// errSave store the last error from writing the file.
+	errSave error
+
+	// file where the triplets stored.
+	file string
+
+	// Delay define the minimum duration before the client retry
+	// accepted.
+	// Default to 5 minutes.
+	Delay time.Duration
+
+	// Expiry define the maximum duration of unconfirmed triplet.
+	// Default to 4 hours.
+	Expiry time.Duration
+
+	// Lifetime define the duration of passed triplet since it last
+	// seen.
+	// Default to 36 days.
+	Lifetime time.Duration
+
+	wg  sync.WaitGroup
+	mtx sync.Mutex
+
+	// isDirty is true if the entries has been changed and not written
+	// into file yet.
+	isDirty bool
+
+	// isSaving is true if the background writer is running.
+	isSaving bool
+}
+
+// greylistEntry contains the state of single triplet.
+type greylistEntry struct {
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Passed    bool
+}
+
+// NewGreylist create new Greylist with the state stored in file.
+// If the file exist, the previous state is loaded from it.
+// If the file is empty, the state is only kept in memory.
+func NewGreylist(file string) (gl *Greylist, err error) {
+	var logp = `NewGreylist`
+
+	gl = &Greylist{
+		entries:  make(map[string]*greylistEntry),
+		file:     file,
+		Delay:    defGreylistDelay,
+		Expiry:   defGreylistExpiry,
+		Lifetime: defGreylistLifetime,
+	}
+
+	if len(file) == 0 {
+		return gl, nil
+	}
+
+	var content []byte
+
+	content, err = os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return gl, nil
+		}
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if len(content) == 0 {
+		return gl, nil
+	}
+
+	var dec = gob.NewDecoder(bytes.NewReader(content))
+
+	err = dec.Decode(&gl.entries)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %s: %w`, logp, file, err)
+	}
+
+	return gl, nil
+}
+
+// Check the triplet and return true if the mail is accepted.
+// If the triplet is created or passed, the state is written into file in
+// the background.
+// The returned error is the error from the previous write, if any.
+func (gl *Greylist) Check(ip net.IP, from, rcpt string) (ok bool, err error) {
+	var (
+		key = greylistKey(ip, from, rcpt)
+		now = time.Now()
+	)
+
+	gl.mtx.Lock()
+	defer gl.mtx.Unlock()
+
+	var isChanged = gl.prune(now)
+
+	var entry = gl.entries[key]
+	switch {
+	case entry == nil:
+		entry = &greylistEntry{
+			FirstSeen: now,
+		}
+		gl.entries[key] = entry
+		isChanged = true
+	case entry.Passed:
+		ok = true
+	case now.Sub(entry.FirstSeen) >= gl.Delay:
+		entry.Passed = true
+		ok = true
+		isChanged = true
+	}
+	entry.LastSeen = now
+
+	if isChanged && len(gl.file) != 0 {
+		gl.isDirty = true
+		if !gl.isSaving {
+			gl.isSaving = true
+			gl.wg.Add(1)
+			go gl.saveLoop()
+		}
+	}
+
+	if gl.errSave != nil {
+		err = fmt.Errorf(`Greylist.Check: %w`, gl.errSave)
+		gl.errSave = nil
+	}
+	return ok, err
+}
+
+// Flush wait until the pending state has been written into file.
+// It return the error from the last write, if any.
+func (gl *Greylist) Flush() (err error) {
+	gl.wg.Wait()
+
+	gl.mtx.Lock()
+	err = gl.errSave
+	gl.errSave = nil
+	gl.mtx.Unlock()
+
+	if err != nil {
+		return fmt.Errorf(`Greylist.Flush: %w`, err)
+	}
+	return nil
+}
+
+// prune remove the expired triplets.
+// It return true if one of the triplet is removed.
+func (gl *Greylist) prune(now time.Time) (isChanged bool) {
+	for key, entry := range gl.entries {
+		if entry.Passed {
+			if now.Sub(entry.LastSeen) > gl.Lifetime {
+				delete(gl.entries, key)
+				isChanged = true
+			}
+			continue
+		}
+		if now.Sub(entry.FirstSeen) > gl.Expiry {
+			delete(gl.entries, key)
+			isChanged = true
+		}
+	}
+	return isChanged
+}
+
+// saveLoop write the entries into file until there is no more changes.
+func (gl *Greylist) saveLoop() {
+	defer gl.wg.Done()
+
+	var (
+		entries map[string]greylistEntry
+		err     error
+	)
+	for {
+		gl.mtx.Lock()
+		if !gl.isDirty {
+			gl.isSaving = false
+			gl.mtx.Unlock()
+			return
+		}
+		gl.isDirty = false
+
+		// Copy the entries, so the encoding does not block the
+		// Check.
+		entries = make(map[string]greylistEntry, len(gl.entries))
+		for key, entry := range gl.entries {
+			entries[key] = *entry
+		}
+		gl.mtx.Unlock()
+
+		err = gl.save(entries)
+		if err != nil {
+			log.Printf(`Greylist: %s`, err)
+		}
+
+		gl.mtx.Lock()
+		gl.errSave = err
+		gl.mtx.Unlock()
+	}
+}
+
+// save the triplets into file atomically.
+func (gl *Greylist) save(entries map[string]greylistEntry) (err error) {
+	var (
+		buf bytes.Buffer
+		enc = gob.NewEncoder(&buf)
+	)
+
+	err = enc.Encode(entries)
+	if err != nil {
+		return err
+	}
+
+	var (
+		tmp = filepath.Join(filepath.Dir(gl.file), `.`+filepath.Base(gl.file)+`.tmp`)
+		f   *os.File
+	)
+
+	f, err = os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf.Bytes())
+	if err == nil {
+		err = f.Sync()
+	}
+	var errClose = f.Close()
+	if err == nil {
+		err = errClose
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, gl.file)
+}
+
+// greylistKey return the triplet key.
+// The IPv4 address is masked to /24 and IPv6 address to /64, so the
+// client that retry using different address in the same network is
+// still accepted.
+func greylistKey(ip net.IP, from, rcpt string) string {
+	var network string
+	if ip4 := ip.To4(); ip4 != nil {
+		network = ip4.Mask(net.CIDRMask(24, 32)).String()
+	} else {
+		network = ip.Mask(net.CIDRMask(64, 128)).String()
+	}
+	return network + "\x00" + strings.ToLower(from) + "\x00" + strings.ToLower(rcpt)
+}
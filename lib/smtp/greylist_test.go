@@ -0,0 +1,153 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestGreylist_Check(t *testing.T) {
+	var (
+		file = filepath.Join(t.TempDir(), `greylist`)
+
+		gl  *Greylist
+		err error
+	)
+
+	gl, err = NewGreylist(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gl.Delay = 0
+
+	type testCase struct {
+		desc   string
+		ip     string
+		from   string
+		rcpt   string
+		reload bool
+		exp    bool
+	}
+
+	var cases = []testCase{{
+		desc: `With new triplet`,
+		ip:   `192.0.2.1`,
+		from: `sender@example.org`,
+		rcpt: `first@mail.kilabit.local`,
+	}, {
+		desc: `With retry from the same network`,
+		ip:   `192.0.2.99`,
+		from: `Sender@example.org`,
+		rcpt: `first@mail.kilabit.local`,
+		exp:  true,
+	}, {
+		desc:   `With passed triplet after reload`,
+		ip:     `192.0.2.1`,
+		from:   `sender@example.org`,
+		rcpt:   `first@mail.kilabit.local`,
+		reload: true,
+		exp:    true,
+	}, {
+		desc: `With different recipient`,
+		ip:   `192.0.2.1`,
+		from: `sender@example.org`,
+		rcpt: `second@mail.kilabit.local`,
+	}, {
+		desc: `With different network`,
+		ip:   `198.51.100.1`,
+		from: `sender@example.org`,
+		rcpt: `first@mail.kilabit.local`,
+	}}
+
+	var (
+		c  testCase
+		ok bool
+	)
+	for _, c = range cases {
+		t.Log(c.desc)
+
+		if c.reload {
+			err = gl.Flush()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gl, err = NewGreylist(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gl.Delay = 0
+		}
+
+		ok, err = gl.Check(net.ParseIP(c.ip), c.from, c.rcpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Assert(t, `Check`, c.exp, ok)
+	}
+}
+
+// TestGreylist_Check_save test that the file only written when the
+// triplet is created or passed.
+func TestGreylist_Check_save(t *testing.T) {
+	var (
+		file = filepath.Join(t.TempDir(), `greylist`)
+		ip   = net.ParseIP(`192.0.2.1`)
+		from = `sender@example.org`
+		rcpt = `first@mail.kilabit.local`
+
+		gl  *Greylist
+		err error
+	)
+
+	gl, err = NewGreylist(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gl.Delay = 0
+
+	var expOK = []bool{false, true}
+	for _, exp := range expOK {
+		var ok bool
+		ok, err = gl.Check(ip, from, rcpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Assert(t, `Check`, exp, ok)
+
+		err = gl.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = os.Remove(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the passed triplet should not write the file.
+	_, err = gl.Check(ip, from, rcpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gl.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(file)
+	test.Assert(t, `file not written`, true, errors.Is(err, fs.ErrNotExist))
+}
@@ -0,0 +1,406 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/shuLhan/share/lib/email"
+	"github.com/shuLhan/share/lib/email/dkim"
+	"github.com/shuLhan/share/lib/email/dmarc"
+	liberrors "github.com/shuLhan/share/lib/errors"
+	"github.com/shuLhan/share/lib/spf"
+)
+
+// defDKIMKeyTTL define the duration, in seconds, of DKIM public key
+// cached in the server key pool.
+const defDKIMKeyTTL = 3600
+
+// InboundPolicy define the sender authentication checks for incoming mail
+// from other MTA on port 25.
+//
+// The result of each check is recorded in the "Authentication-Results"
+// header field (RFC 8601) at the top of message.
+type InboundPolicy struct {
+	// CheckSPF enable the SPF check (RFC 7208) on MAIL command, using
+	// the client IP address and the domain of reverse-path, or the
+	// domain of HELO/EHLO if the reverse-path is null.
+	CheckSPF bool
+
+	// CheckDKIM enable the verification of the first DKIM-Signature
+	// (RFC 6376) in the message.
+	CheckDKIM bool
+
+	// CheckDMARC enable the DMARC (RFC 7489) evaluation of the domain in
+	// "From" field.
+	// If its true, the SPF and DKIM checks are also run, even if
+	// CheckSPF or CheckDKIM is false.
+	CheckDMARC bool
+
+	// RejectSPFFail reject the MAIL command if the SPF result is "fail".
+	RejectSPFFail bool
+
+	// EnforceDMARC apply the DMARC policy published by author domain if
+	// the DMARC result is "fail".
+	// Policy "reject" cause the message rejected at the end of DATA,
+	// and policy "quarantine" cause the MailTx.Quarantine set to true.
+	EnforceDMARC bool
+}
+
+func (policy *InboundPolicy) isCheckSPF() bool {
+	return policy.CheckSPF || policy.CheckDMARC
+}
+
+func (policy *InboundPolicy) isCheckDKIM() bool {
+	return policy.CheckDKIM || policy.CheckDMARC
+}
+
+// spfResolver convert the Resolver into spf.Resolver and dmarc.Resolver,
+// where non-existent domain is not an error.
+type spfResolver struct {
+	Resolver
+}
+
+func (r spfResolver) LookupAddr(ip net.IP) (names []string, err error) {
+	names, err = r.Resolver.LookupAddr(ip)
+	if errors.Is(err, errDomainNotFound) {
+		return nil, nil
+	}
+	return names, err
+}
+
+func (r spfResolver) LookupIP(host string) (ips []net.IP, err error) {
+	ips, err = r.Resolver.LookupIP(host)
+	if errors.Is(err, errDomainNotFound) {
+		return nil, nil
+	}
+	return ips, err
+}
+
+func (r spfResolver) LookupMX(domain string) (hosts []string, err error) {
+	hosts, err = r.Resolver.LookupMX(domain)
+	if errors.Is(err, errDomainNotFound) {
+		return nil, nil
+	}
+	return hosts, err
+}
+
+func (r spfResolver) LookupTXT(domain string) (txts []string, err error) {
+	txts, err = r.Resolver.LookupTXT(domain)
+	if errors.Is(err, errDomainNotFound) {
+		return nil, nil
+	}
+	return txts, err
+}
+
+// checkSPF evaluate the SPF of sender in the current mail transaction.
+// It will return an error if the SPF result is "fail" and the policy is
+// set to reject it.
+func (srv *Server) checkSPF(recv *receiver) (err error) {
+	var host, _, errSplit = net.SplitHostPort(recv.clientAddress)
+	if errSplit != nil {
+		host = recv.clientAddress
+	}
+
+	var ip = net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+
+	var (
+		sender = recv.mail.From
+		domain = recv.clientDomain
+	)
+	if len(sender) == 0 {
+		sender = recv.clientDomain
+	} else {
+		var at = strings.LastIndexByte(sender, '@')
+		if at >= 0 {
+			domain = sender[at+1:]
+		}
+	}
+
+	recv.spfResult = spf.CheckHostWith(spfResolver{srv.Resolver}, ip,
+		domain, sender, srv.Env.PrimaryDomain.Name)
+
+	if srv.Inbound.RejectSPFFail && recv.spfResult.Code == spf.ResultCodeFail {
+		return &liberrors.E{
+			Code:    StatusMailboxNotFound,
+			Message: `5.7.23 SPF validation failed for ` + domain,
+		}
+	}
+	return nil
+}
+
+// checkMessage verify the DKIM signature and evaluate the DMARC policy of
+// received message, and insert the "Authentication-Results" field into
+// the message.
+// Any existing "Authentication-Results" fields with our authserv-id are
+// removed from the message, since they are not created by us.
+// It will return an error if the message should be rejected.
+func (srv *Server) checkMessage(recv *receiver) (err error) {
+	var (
+		policy = srv.Inbound
+		mail   = recv.mail
+		ar     = authResults{
+			servID: srv.Env.PrimaryDomain.Name,
+		}
+
+		msg        *email.Message
+		dkimStatus *dkim.Status
+		fromDomain string
+	)
+
+	if recv.spfResult != nil {
+		ar.add(`spf`, recv.spfResult.String(), `smtp.mailfrom`,
+			string(recv.spfResult.Sender))
+	}
+
+	msg, _, err = email.ParseMessage(unstuff(mail.Data))
+	if err != nil || msg == nil {
+		if policy.isCheckDKIM() {
+			ar.add(`dkim`, `permerror`)
+		}
+		if policy.CheckDMARC {
+			ar.add(`dmarc`, `permerror`)
+		}
+		mail.Data = ar.prependTo(mail.Data)
+		return nil
+	}
+
+	if policy.isCheckDKIM() {
+		dkimStatus, _ = msg.DKIMVerifyWith(srv.dkimKeys)
+		if dkimStatus == nil {
+			dkimStatus = &dkim.Status{Type: dkim.StatusPermFail}
+		}
+		var props = []string{`header.d`, string(dkimStatus.SDID)}
+		if msg.DKIMSignature != nil {
+			props = append(props, `header.s`, string(msg.DKIMSignature.Selector))
+		}
+		ar.add(`dkim`, dkimResultName(dkimStatus.Type), props...)
+	}
+
+	if !policy.CheckDMARC {
+		mail.Data = ar.prependTo(mail.Data)
+		return nil
+	}
+
+	var fields = msg.Header.Filter(email.FieldTypeFrom)
+	if len(fields) > 0 {
+		var mbox = email.ParseMailbox([]byte(fields[0].Value))
+		if mbox != nil {
+			fromDomain = mbox.Domain
+		}
+	}
+
+	var in = dmarc.Input{
+		FromDomain: fromDomain,
+		DKIMDomain: string(dkimStatus.SDID),
+		DKIMPass:   dkimStatus.Type == dkim.StatusOK,
+	}
+	if recv.spfResult != nil {
+		in.SPFDomain = string(recv.spfResult.Domain)
+		in.SPFPass = recv.spfResult.Code == spf.ResultCodePass
+	}
+
+	var res = dmarc.Evaluate(spfResolver{srv.Resolver}, &in)
+
+	ar.add(`dmarc`, res.String(), `header.from`, fromDomain)
+	mail.Data = ar.prependTo(mail.Data)
+
+	if !policy.EnforceDMARC || res.Code != dmarc.ResultCodeFail {
+		return nil
+	}
+
+	switch res.Policy {
+	case dmarc.PolicyReject:
+		return &liberrors.E{
+			Code:    StatusMailboxNotFound,
+			Message: `5.7.1 Rejected by DMARC policy of ` + fromDomain,
+		}
+	case dmarc.PolicyQuarantine:
+		mail.Quarantine = true
+	}
+	return nil
+}
+
+// lookupDKIMKey lookup the DKIM public key in DNS TXT record using the
+// server Resolver.
+func (srv *Server) lookupDKIMKey(dname string) (key *dkim.Key, err error) {
+	var (
+		logp = `lookupDKIMKey`
+		txts []string
+	)
+
+	txts, err = srv.Resolver.LookupTXT(dname)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if len(txts) == 0 {
+		return nil, fmt.Errorf(`%s: no TXT record on %q`, logp, dname)
+	}
+	if len(txts) != 1 {
+		return nil, fmt.Errorf(`%s: multiple TXT records on %q`, logp, dname)
+	}
+
+	return dkim.ParseTXT([]byte(txts[0]), defDKIMKeyTTL)
+}
+
+// dkimResultName convert the DKIM status into result name in
+// Authentication-Results.
+func dkimResultName(st dkim.StatusType) string {
+	switch st {
+	case dkim.StatusOK:
+		return `pass`
+	case dkim.StatusNoSignature:
+		return `none`
+	case dkim.StatusTempFail:
+		return `temperror`
+	}
+	return `fail`
+}
+
+// authResults build the "Authentication-Results" header field, as
+// defined in RFC 8601.
+type authResults struct {
+	servID  string
+	results []string
+}
+
+// add the result of authentication method with optional list of
+// property and its value.
+func (ar *authResults) add(method, result string, props ...string) {
+	var sb strings.Builder
+
+	sb.WriteString(method)
+	sb.WriteByte('=')
+	sb.WriteString(result)
+	for x := 0; x+1 < len(props); x += 2 {
+		if len(props[x+1]) == 0 {
+			continue
+		}
+		sb.WriteByte(' ')
+		sb.WriteString(props[x])
+		sb.WriteByte('=')
+		sb.WriteString(props[x+1])
+	}
+	ar.results = append(ar.results, sb.String())
+}
+
+// pack the header field into bytes, with each result folded into its own
+// line.
+func (ar *authResults) pack() []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, `Authentication-Results: %s;`, ar.servID)
+	if len(ar.results) == 0 {
+		buf.WriteString(" none\r\n")
+		return buf.Bytes()
+	}
+	for x, res := range ar.results {
+		buf.WriteString("\r\n\t")
+		buf.WriteString(res)
+		if x < len(ar.results)-1 {
+			buf.WriteByte(';')
+		}
+	}
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// prependTo remove any "Authentication-Results" fields with the same
+// authserv-id from the header of raw message data, as required by RFC 8601
+// section 5, and then insert the packed field at the top.
+func (ar *authResults) prependTo(data []byte) []byte {
+	data = removeAuthResults(data, ar.servID)
+	return append(ar.pack(), data...)
+}
+
+// removeAuthResults remove the "Authentication-Results" fields whose
+// authserv-id equal to servID from the header of raw message data.
+func removeAuthResults(data []byte, servID string) (out []byte) {
+	var (
+		fieldName = []byte(`authentication-results:`)
+
+		field []byte
+		end   int
+	)
+	out = make([]byte, 0, len(data))
+	for len(data) > 0 {
+		if data[0] == '\r' || data[0] == '\n' {
+			// End of header.
+			break
+		}
+
+		// Get the field including its continuation lines.
+		end = 0
+		for {
+			var x = bytes.IndexByte(data[end:], '\n')
+			if x < 0 {
+				end = len(data)
+				break
+			}
+			end += x + 1
+			if end == len(data) || (data[end] != ' ' && data[end] != '\t') {
+				break
+			}
+		}
+		field = data[:end]
+		data = data[end:]
+
+		if len(field) > len(fieldName) &&
+			bytes.EqualFold(field[:len(fieldName)], fieldName) &&
+			strings.EqualFold(authServID(field[len(fieldName):]), servID) {
+			continue
+		}
+		out = append(out, field...)
+	}
+	return append(out, data...)
+}
+
+// authServID return the authserv-id from the value of
+// "Authentication-Results" field, skipping the leading white spaces and
+// comments.
+func authServID(value []byte) string {
+	var (
+		depth int
+		x     int
+		c     byte
+	)
+	for x, c = range value {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0:
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			value = value[x:]
+			x = bytes.IndexAny(value, " \t\r\n(;")
+			if x >= 0 {
+				value = value[:x]
+			}
+			return string(value)
+		}
+	}
+	return ``
+}
+
+// unstuff remove the leading period on each line that has been added by
+// client for transparency, RFC 5321 section 4.5.2.
+func unstuff(in []byte) (out []byte) {
+	out = bytes.ReplaceAll(in, []byte("\r\n.."), []byte("\r\n."))
+	if bytes.HasPrefix(out, []byte(`..`)) {
+		out = out[1:]
+	}
+	return out
+}
@@ -0,0 +1,333 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	libcrypto "github.com/shuLhan/share/lib/crypto"
+	"github.com/shuLhan/share/lib/email"
+	"github.com/shuLhan/share/lib/email/dkim"
+	liberrors "github.com/shuLhan/share/lib/errors"
+	"github.com/shuLhan/share/lib/test"
+)
+
+// testSignMessage sign the raw message using DKIM with domain
+// "example.org" and selector "sel".
+func testSignMessage(t *testing.T, pkey *rsa.PrivateKey, raw string) []byte {
+	var (
+		msg *email.Message
+		err error
+	)
+
+	msg, _, err = email.ParseMessage([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sig = dkim.NewSignature([]byte(`example.org`), []byte(`sel`))
+
+	err = msg.DKIMSign(pkey, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []byte(`DKIM-Signature:` + string(sig.Pack(true)) + raw)
+}
+
+func TestServer_checkInbound(t *testing.T) {
+	var (
+		pkey, err = libcrypto.LoadPrivateKey(testFilePrivateKey, nil)
+
+		rsakey *rsa.PrivateKey
+		pubkey []byte
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsakey = pkey.(*rsa.PrivateKey)
+
+	pubkey, err = x509.MarshalPKIXPublicKey(&rsakey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		resolver = &mockResolver{
+			txt: map[string][]string{
+				`example.org`:                {`v=spf1 ip4:192.0.2.0/24 -all`},
+				`_dmarc.example.org`:         {`v=DMARC1; p=reject`},
+				`sel._domainkey.example.org`: {`v=DKIM1; k=rsa; p=` + base64.StdEncoding.EncodeToString(pubkey)},
+				`quarantine.org`:             {`v=spf1 -all`},
+				`_dmarc.quarantine.org`:      {`v=DMARC1; p=quarantine`},
+			},
+		}
+		srv = &Server{
+			Env: &Environment{
+				PrimaryDomain: NewDomain(testDomain, nil),
+			},
+			Resolver: resolver,
+			Inbound: &InboundPolicy{
+				CheckDMARC:    true,
+				RejectSPFFail: true,
+				EnforceDMARC:  true,
+			},
+		}
+
+		rawMsg = "From: Alice <alice@example.org>\r\n" +
+			"To: <bob@" + testDomain + ">\r\n" +
+			"Subject: Test\r\n" +
+			"Date: Mon, 1 Jan 2024 00:00:00 +0000\r\n" +
+			"Message-ID: <1@example.org>\r\n" +
+			"\r\n" +
+			"Hello Bob.\r\n"
+		rawQuarantine = "From: <carol@quarantine.org>\r\n" +
+			"To: <bob@" + testDomain + ">\r\n" +
+			"Subject: Test\r\n" +
+			"\r\n" +
+			"Hello Bob.\r\n"
+	)
+	srv.dkimKeys = dkim.NewKeyPool(srv.lookupDKIMKey)
+
+	type testCase struct {
+		desc          string
+		clientAddress string
+		from          string
+		expError      string
+		expAuthResult string
+
+		// notContain is the text that should be removed from the
+		// message.
+		notContain string
+
+		data          []byte
+		expQuarantine bool
+	}
+
+	var cases = []testCase{{
+		desc:          `With SPF, DKIM, and DMARC pass`,
+		clientAddress: `192.0.2.10:4321`,
+		from:          `alice@example.org`,
+		data:          testSignMessage(t, rsakey, rawMsg),
+		expAuthResult: "Authentication-Results: " + testDomain + ";\r\n" +
+			"\tspf=pass smtp.mailfrom=alice@example.org;\r\n" +
+			"\tdkim=pass header.d=example.org header.s=sel;\r\n" +
+			"\tdmarc=pass header.from=example.org\r\n",
+	}, {
+		desc:          `With SPF fail`,
+		clientAddress: `198.51.100.1:4321`,
+		from:          `alice@example.org`,
+		expError:      `550 5.7.23 SPF validation failed for example.org`,
+	}, {
+		desc:          `With DMARC reject`,
+		clientAddress: `198.51.100.1:4321`,
+		from:          `alice@other.test`,
+		data:          []byte(rawMsg),
+		expError:      `550 5.7.1 Rejected by DMARC policy of example.org`,
+	}, {
+		desc:          `With DMARC quarantine`,
+		clientAddress: `198.51.100.1:4321`,
+		from:          `carol@other.test`,
+		data:          []byte(rawQuarantine),
+		expAuthResult: "Authentication-Results: " + testDomain + ";\r\n" +
+			"\tspf=none smtp.mailfrom=carol@other.test;\r\n" +
+			"\tdkim=none;\r\n" +
+			"\tdmarc=fail header.from=quarantine.org\r\n",
+		expQuarantine: true,
+	}, {
+		desc:          `With forged Authentication-Results`,
+		clientAddress: `198.51.100.1:4321`,
+		from:          `carol@other.test`,
+		data: []byte("Authentication-Results: " + testDomain + ";\r\n" +
+			"\tdkim=pass header.d=quarantine.org;\r\n" +
+			"\tdmarc=pass header.from=quarantine.org\r\n" +
+			rawQuarantine),
+		expAuthResult: "Authentication-Results: " + testDomain + ";\r\n" +
+			"\tspf=none smtp.mailfrom=carol@other.test;\r\n" +
+			"\tdkim=none;\r\n" +
+			"\tdmarc=fail header.from=quarantine.org\r\n" +
+			"From: <carol@quarantine.org>\r\n",
+		notContain:    `=pass`,
+		expQuarantine: true,
+	}}
+
+	var (
+		c    testCase
+		recv *receiver
+	)
+	for _, c = range cases {
+		recv = &receiver{
+			clientAddress: c.clientAddress,
+			clientDomain:  `mx.example.org`,
+			mail: &MailTx{
+				From: c.from,
+				Data: c.data,
+			},
+		}
+
+		err = srv.checkSPF(recv)
+		if err == nil {
+			err = srv.checkMessage(recv)
+		}
+		if err != nil {
+			var errs = err.(*liberrors.E)
+			test.Assert(t, c.desc, c.expError,
+				fmt.Sprintf(`%d %s`, errs.Code, errs.Message))
+			continue
+		}
+
+		test.Assert(t, c.desc+`: Authentication-Results`, true,
+			bytes.HasPrefix(recv.mail.Data, []byte(c.expAuthResult)))
+		test.Assert(t, c.desc+`: Quarantine`, c.expQuarantine, recv.mail.Quarantine)
+		if len(c.notContain) != 0 {
+			test.Assert(t, c.desc+`: not contain `+c.notContain, false,
+				bytes.Contains(recv.mail.Data, []byte(c.notContain)))
+		}
+	}
+}
+
+// TestServer_checkInbound_reset test that the mail transaction is reset
+// after the message rejected by DMARC policy, so the next DATA command
+// does not continue the rejected message.
+func TestServer_checkInbound_reset(t *testing.T) {
+	var (
+		env = &Environment{
+			PrimaryDomain: NewDomain(testDomain, nil),
+		}
+		srv = &Server{
+			Env:     env,
+			Handler: NewLocalHandler(env),
+			Resolver: &mockResolver{
+				txt: map[string][]string{
+					`_dmarc.example.org`: {`v=DMARC1; p=reject`},
+				},
+			},
+			Inbound: &InboundPolicy{
+				CheckDMARC:   true,
+				EnforceDMARC: true,
+			},
+			running: true,
+		}
+
+		ln, err = net.Listen(`tcp`, `127.0.0.1:0`)
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+	srv.dkimKeys = dkim.NewKeyPool(srv.lookupDKIMKey)
+
+	go func() {
+		for {
+			var conn, errAccept = ln.Accept()
+			if errAccept != nil {
+				return
+			}
+			go srv.handle(newReceiver(conn, receiverModeServer))
+		}
+	}()
+
+	var conn net.Conn
+
+	conn, err = net.Dial(`tcp`, ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var (
+		cmds = []string{
+			``,
+			"HELO mx.other.test\r\n",
+			"MAIL FROM:<alice@other.test>\r\n",
+			"RCPT TO:<bob@" + testDomain + ">\r\n",
+			"DATA\r\n",
+			"From: <alice@example.org>\r\n\r\nHello Bob.\r\n.\r\n",
+			"DATA\r\n",
+		}
+		exp = []string{
+			`220 ` + testDomain,
+			`250 ` + testDomain,
+			`250 OK`,
+			`250 OK`,
+			`354 Start mail input.`,
+			`550 5.7.1 Rejected by DMARC policy of example.org`,
+			`503 Bad sequences of commands`,
+		}
+
+		reader = bufio.NewReader(conn)
+		got    []string
+		line   string
+	)
+	for _, cmd := range cmds {
+		if len(cmd) > 0 {
+			_, err = conn.Write([]byte(cmd))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strings.TrimSpace(line))
+	}
+
+	test.Assert(t, `replies`, exp, got)
+}
+
+func TestRemoveAuthResults(t *testing.T) {
+	type testCase struct {
+		desc string
+		data string
+		exp  string
+	}
+
+	var cases = []testCase{{
+		desc: `With our authserv-id`,
+		data: "Authentication-Results: " + testDomain + "; dkim=pass\r\n" +
+			"Subject: test\r\n\r\nBody\r\n",
+		exp: "Subject: test\r\n\r\nBody\r\n",
+	}, {
+		desc: `With folded field, comment, and different case`,
+		data: "Subject: test\r\n" +
+			"authentication-results:  (forged) " + strings.ToUpper(testDomain) + ";\r\n" +
+			"\tdmarc=pass\r\n" +
+			"To: <bob@" + testDomain + ">\r\n\r\nBody\r\n",
+		exp: "Subject: test\r\n" +
+			"To: <bob@" + testDomain + ">\r\n\r\nBody\r\n",
+	}, {
+		desc: `With other authserv-id`,
+		data: "Authentication-Results: mx.other.test; dkim=pass\r\n" +
+			"Subject: test\r\n\r\nBody\r\n",
+		exp: "Authentication-Results: mx.other.test; dkim=pass\r\n" +
+			"Subject: test\r\n\r\nBody\r\n",
+	}, {
+		desc: `With field inside body`,
+		data: "Subject: test\r\n\r\n" +
+			"Authentication-Results: " + testDomain + "; dkim=pass\r\n",
+		exp: "Subject: test\r\n\r\n" +
+			"Authentication-Results: " + testDomain + "; dkim=pass\r\n",
+	}}
+
+	var (
+		c   testCase
+		got []byte
+	)
+	for _, c = range cases {
+		got = removeAuthResults([]byte(c.data), testDomain)
+		test.Assert(t, c.desc, c.exp, string(got))
+	}
+}
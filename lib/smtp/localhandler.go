@@ -4,7 +4,10 @@
 
 package smtp
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+)
 
 // LocalHandler is an handler using local environment.
 type LocalHandler struct {
@@ -79,18 +82,43 @@ func (lh *LocalHandler) ServeAuth(username, password string) (
 }
 
 // ServeBounce handle email transaction with unknown or invalid recipent.
-func (lh *LocalHandler) ServeBounce(_ *MailTx) (res *Response, err error) {
-	// TODO: send delivery status notification to sender address.
-	return nil, nil
+// It return the reply code and message from the mail LastError.
+// The delivery status notification is sent to the sender by server.
+func (lh *LocalHandler) ServeBounce(mail *MailTx) (res *Response, err error) {
+	res = &Response{
+		Code:    StatusMailboxNotFound,
+		Message: mail.LastError,
+	}
+
+	var code, msg, found = strings.Cut(mail.LastError, ` `)
+	if found {
+		var n, errAtoi = strconv.Atoi(code)
+		if errAtoi == nil && n >= 400 && n < 600 {
+			res.Code = n
+			res.Message = msg
+		}
+	}
+	return res, nil
 }
 
 // ServeExpand handle SMTP EXPN command.
-//
-// TODO: The group feature currently is not supported.
-func (lh *LocalHandler) ServeExpand(_ string) (res *Response, err error) {
+// It return the members of mailing list in Environment Lists.
+func (lh *LocalHandler) ServeExpand(mailingList string) (res *Response, err error) {
+	var mlist = lh.env.Lists[strings.ToLower(strings.TrimSpace(mailingList))]
+	if mlist == nil || len(mlist.Members) == 0 {
+		res = &Response{
+			Code:    StatusMailboxNotFound,
+			Message: "5.1.1 Mailing list not found",
+		}
+		return res, nil
+	}
+
 	res = &Response{
-		Code:    StatusCmdNotImplemented,
-		Message: "Command not implemented",
+		Code:    StatusOK,
+		Message: `<` + mlist.Members[0] + `>`,
+	}
+	for _, member := range mlist.Members[1:] {
+		res.Body = append(res.Body, `<`+member+`>`)
 	}
 	return res, nil
 }
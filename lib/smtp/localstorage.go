@@ -7,27 +7,35 @@ package smtp
 import (
 	"bytes"
 	"encoding/gob"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 const (
 	defDirBounce = "bounce"
 	defDirSpool  = "/var/spool/smtp"
+
+	// defDirTmp is the directory where the mail file is written before
+	// its moved into spool directory.
+	defDirTmp = "tmp"
 )
 
 // LocalStorage implement the Storage interface where mail object is save and
 // retrieved in file system inside a directory.
+//
+// Each mail is written into temporary file, synced, and then renamed into
+// spool directory, so the mail file is never partially written even if the
+// system crash.
 type LocalStorage struct {
-	enc  *gob.Encoder
-	dec  *gob.Decoder
-	dir  string
-	buff bytes.Buffer
+	dir string
 }
 
 // NewLocalStorage create and initialize new file storage.  If directory is
 // empty, the default storage is located at "/var/spool/smtp/".
+// Any temporary files left by previous crash are removed.
 func NewLocalStorage(dir string) (storage *LocalStorage, err error) {
 	if len(dir) == 0 {
 		dir = defDirSpool
@@ -45,23 +53,40 @@ func NewLocalStorage(dir string) (storage *LocalStorage, err error) {
 		return nil, err
 	}
 
+	dirTmp := filepath.Join(dir, defDirTmp)
+
+	err = os.RemoveAll(dirTmp)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dirTmp, 0700)
+	if err != nil {
+		return nil, err
+	}
+
 	storage = &LocalStorage{
 		dir: dir,
 	}
 
-	storage.enc = gob.NewEncoder(&storage.buff)
-	storage.dec = gob.NewDecoder(&storage.buff)
-
 	return storage, nil
 }
 
 // MailBounce move the incoming mail to bounced state.  In this storage
 // service, the mail file is moved to "{dir}/bounce".
-func (fs *LocalStorage) MailBounce(id string) error {
+func (fs *LocalStorage) MailBounce(id string) (err error) {
 	oldp := filepath.Join(fs.dir, id)
 	newp := filepath.Join(fs.dir, defDirBounce, id)
 
-	return os.Rename(oldp, newp)
+	err = os.Rename(oldp, newp)
+	if err != nil {
+		return err
+	}
+
+	err = syncDir(filepath.Join(fs.dir, defDirBounce))
+	if err != nil {
+		return err
+	}
+	return syncDir(fs.dir)
 }
 
 // MailDelete the mail object on file system by ID.
@@ -71,7 +96,12 @@ func (fs *LocalStorage) MailDelete(id string) (err error) {
 	}
 
 	fpath := filepath.Join(fs.dir, id)
-	return os.Remove(fpath)
+
+	err = os.Remove(fpath)
+	if err != nil {
+		return err
+	}
+	return syncDir(fs.dir)
 }
 
 // MailLoad read the mail object from file system by ID.
@@ -96,6 +126,7 @@ func (fs *LocalStorage) MailLoadAll() (mails []*MailTx, err error) {
 	if err != nil {
 		return nil, err
 	}
+	defer d.Close()
 
 	fis, err := d.Readdir(0)
 	if err != nil {
@@ -103,7 +134,7 @@ func (fs *LocalStorage) MailLoadAll() (mails []*MailTx, err error) {
 	}
 
 	for _, fi := range fis {
-		if fi.IsDir() {
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
 			continue
 		}
 
@@ -120,31 +151,80 @@ func (fs *LocalStorage) MailLoadAll() (mails []*MailTx, err error) {
 }
 
 // MailSave save the mail object into file system.
+// The mail is written into temporary file first, synced to the disk, and
+// then renamed into its final path.
 func (fs *LocalStorage) MailSave(mail *MailTx) (err error) {
 	if mail == nil {
 		return
 	}
 
-	fs.buff.Reset()
-	err = fs.enc.Encode(mail)
+	var (
+		logp = `MailSave`
+		buf  bytes.Buffer
+	)
+
+	err = gob.NewEncoder(&buf).Encode(mail)
 	if err != nil {
-		return
+		return fmt.Errorf(`%s: %w`, logp, err)
 	}
 
-	fpath := filepath.Join(fs.dir, mail.ID)
+	var f *os.File
 
-	return os.WriteFile(fpath, fs.buff.Bytes(), 0600)
+	f, err = os.CreateTemp(filepath.Join(fs.dir, defDirTmp), mail.ID+`.*`)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	_, err = f.Write(buf.Bytes())
+	if err == nil {
+		err = f.Sync()
+	}
+	var errClose = f.Close()
+	if err == nil {
+		err = errClose
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	err = os.Rename(f.Name(), filepath.Join(fs.dir, mail.ID))
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	err = syncDir(fs.dir)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return nil
 }
 
 func (fs *LocalStorage) loadRaw(b []byte) (mail *MailTx, err error) {
-	fs.buff.Reset()
-	fs.buff.Write(b)
-
 	mail = &MailTx{}
-	err = fs.dec.Decode(mail)
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(mail)
 	if err != nil {
 		return nil, err
 	}
 
 	return mail, nil
 }
+
+// syncDir commit the changes on directory entries, for example after
+// creating, renaming, or removing file, into the disk.
+func syncDir(dir string) (err error) {
+	var d *os.File
+
+	d, err = os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	var errClose = d.Close()
+	if err == nil {
+		err = errClose
+	}
+	return err
+}
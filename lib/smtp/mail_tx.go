@@ -11,8 +11,26 @@ import (
 	"time"
 )
 
+// List of mail states in the spool.
+const (
+	// MailStateQueued is the state of mail that is waiting to be
+	// relayed for the first time.
+	MailStateQueued = `queued`
+
+	// MailStateDeferred is the state of mail that failed to be relayed
+	// temporarily and waiting to be retried at Postpone.
+	MailStateDeferred = `deferred`
+
+	// MailStateBounced is the state of mail that failed to be relayed
+	// permanently or expired, and its bounced back to the sender.
+	MailStateBounced = `bounced`
+)
+
 // MailTx define a mail transaction.
 type MailTx struct {
+	// Postpone contains the time when the mail transaction will be
+	// processed again, after failed to be delivered.
+	// This field is ignored in Client.MailTx.
 	Postpone time.Time
 
 	// Received contains the time when the message arrived on server.
@@ -35,7 +53,27 @@ type MailTx struct {
 	// This field is optional in Client.MailTx.
 	Data []byte
 
+	// State contains the delivery state of mail in the spool, one of
+	// the MailState constants.
+	// Since the server split the mail transaction into one mail per
+	// recipient, the State is the delivery state of the recipient.
+	// This field is ignored in Client.MailTx.
+	State string
+
+	// LastError contains the error message from the last failed
+	// delivery.
+	// This field is ignored in Client.MailTx.
+	LastError string
+
+	// Retry contains the number of failed delivery.
+	// This field is ignored in Client.MailTx.
 	Retry int
+
+	// Quarantine is true if the mail should be delivered into quarantine
+	// (for example, spam folder), set by server when the DMARC policy
+	// of author domain is "quarantine".
+	// This field is ignored in Client.MailTx.
+	Quarantine bool
 }
 
 // NewMailTx create and return new mail object.
@@ -58,6 +96,8 @@ func (mail *MailTx) Reset() {
 	mail.From = ""
 	mail.Recipients = nil
 	mail.Data = nil
+	mail.State = ""
+	mail.Quarantine = false
 }
 
 // isTerminated will return true if data is end with "\r\n.\r\n".
@@ -69,19 +109,31 @@ func (mail *MailTx) isTerminated() bool {
 	return bytes.Equal(mail.Data[l-5:l], []byte{'\r', '\n', '.', '\r', '\n'})
 }
 
-// postpone the mail transaction.
-func (mail *MailTx) postpone() {
+// postpone the mail transaction after failed delivery.
+// The mail will be processed again after the interval, which is doubled on
+// each retry.
+func (mail *MailTx) postpone(interval time.Duration, lastErr error) {
 	mail.Retry++
-	mail.Postpone = mail.Received.Add(time.Duration(mail.Retry*30) * time.Minute)
+	if lastErr != nil {
+		mail.LastError = lastErr.Error()
+	}
+	interval = interval << (mail.Retry - 1)
+	mail.Postpone = time.Now().Add(interval).Round(0)
 }
 
 func (mail *MailTx) isPostponed() bool {
 	return mail.Postpone.After(time.Now())
 }
 
+// isExpired return true if the mail has been in the queue longer than
+// expiry.
+func (mail *MailTx) isExpired(expiry time.Duration) bool {
+	return time.Since(mail.Received) >= expiry
+}
+
 // seal the mail envelope by inserting trace information into message content.
 func (mail *MailTx) seal(clientDomain, clientAddress, localAddress string) {
-	line := fmt.Sprintf("FROM %s (%s)\r\n\tBY %s WITH SMTP ID %s;\r\n\t%s",
+	line := fmt.Sprintf("Received: from %s (%s)\r\n\tby %s with SMTP id %s;\r\n\t%s\r\n",
 		clientDomain, clientAddress, localAddress, mail.ID,
 		mail.Received.Format(time.RFC1123Z))
 	mail.Data = append([]byte(line), mail.Data...)
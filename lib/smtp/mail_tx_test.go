@@ -5,7 +5,9 @@
 package smtp
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/shuLhan/share/lib/test"
 )
@@ -50,3 +52,30 @@ func TestFormat(t *testing.T) {
 	got = format([]byte(in))
 	test.Assert(t, `format`, []byte(exp), got)
 }
+
+func TestMailTx_postpone(t *testing.T) {
+	var (
+		mail     = NewMailTx(`me`, []string{`a@box.com`}, nil)
+		interval = time.Minute
+		errRelay = errors.New(`451 4.4.1 No answer`)
+	)
+
+	for x := 1; x <= 3; x++ {
+		var now = time.Now()
+
+		mail.postpone(interval, errRelay)
+
+		var (
+			exp = now.Add(interval << (x - 1))
+			got = mail.Postpone
+		)
+		test.Assert(t, `Retry`, x, mail.Retry)
+		test.Assert(t, `LastError`, errRelay.Error(), mail.LastError)
+		test.Assert(t, `isPostponed`, true, mail.isPostponed())
+		test.Assert(t, `Postpone >= expected`, true, !got.Before(exp))
+		test.Assert(t, `Postpone < expected+1s`, true, got.Before(exp.Add(time.Second)))
+	}
+
+	test.Assert(t, `isExpired`, false, mail.isExpired(time.Hour))
+	test.Assert(t, `isExpired`, true, mail.isExpired(0))
+}
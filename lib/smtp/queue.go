@@ -0,0 +1,156 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// ErrMailNotQueued define an error when the mail is not found in the
+// queue.
+var ErrMailNotQueued = errors.New(`mail is not in the queue`)
+
+// QueueList return the list of mails in the queue that are waiting to be
+// relayed, ordered by their next retry time.
+// The returned mails are copy of mail in the queue, without the Data.
+func (srv *Server) QueueList() (list []*MailTx) {
+	srv.retryMtx.Lock()
+	for _, mail := range srv.retryQueue {
+		var dup = *mail
+		dup.Data = nil
+		list = append(list, &dup)
+	}
+	srv.retryMtx.Unlock()
+
+	sort.SliceStable(list, func(x, y int) bool {
+		return list[x].Postpone.Before(list[y].Postpone)
+	})
+	return list
+}
+
+// QueueFlush relay the mails in the queue immediately, without waiting
+// for their next retry time.
+// If ids is empty, all mails in the queue are flushed.
+// It return the number of mails that are flushed.
+func (srv *Server) QueueFlush(ids ...string) (n int) {
+	var (
+		filter = map[string]bool{}
+		ready  []*MailTx
+	)
+	for _, id := range ids {
+		filter[id] = true
+	}
+
+	srv.retryMtx.Lock()
+	var x int
+	for _, mail := range srv.retryQueue {
+		if len(filter) > 0 && !filter[mail.ID] {
+			srv.retryQueue[x] = mail
+			x++
+			continue
+		}
+		mail.Postpone = time.Time{}
+		ready = append(ready, mail)
+	}
+	srv.retryQueue = srv.retryQueue[:x]
+	srv.retryMtx.Unlock()
+
+	for _, mail := range ready {
+		srv.enqueue(srv.relayQueue, mail)
+	}
+	return len(ready)
+}
+
+// QueueDelete remove the mail from the queue and from Storage, without
+// bouncing it back to the sender.
+// It will return [ErrMailNotQueued] if the mail with id is not in the
+// queue, for example when its being relayed.
+func (srv *Server) QueueDelete(id string) (err error) {
+	var (
+		logp = `QueueDelete`
+
+		mail *MailTx
+	)
+
+	srv.retryMtx.Lock()
+	for x, m := range srv.retryQueue {
+		if m.ID != id {
+			continue
+		}
+		mail = m
+		srv.retryQueue = append(srv.retryQueue[:x], srv.retryQueue[x+1:]...)
+		break
+	}
+	srv.retryMtx.Unlock()
+
+	if mail == nil {
+		return fmt.Errorf(`%s: %s: %w`, logp, id, ErrMailNotQueued)
+	}
+	if srv.Storage != nil {
+		err = srv.Storage.MailDelete(mail.ID)
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+	}
+	return nil
+}
+
+// recoverQueue load the mails from Storage, so the mails that are queued
+// or deferred before the server stopped are processed again.
+// The queued mail for recipient that is managed by server is pushed back
+// to the mail queue for local delivery.
+// Other mails are pushed into the retry queue, where the queued mail is
+// relayed on the next retry tick, while the deferred mail is relayed
+// after its Postpone time.
+func (srv *Server) recoverQueue() (err error) {
+	if srv.Storage == nil {
+		return nil
+	}
+
+	var mails []*MailTx
+
+	mails, err = srv.Storage.MailLoadAll()
+	if err != nil {
+		return fmt.Errorf(`recoverQueue: %w`, err)
+	}
+
+	var local []*MailTx
+
+	srv.retryMtx.Lock()
+	for _, mail := range mails {
+		if len(mail.Recipients) == 0 {
+			log.Printf(`smtp: recoverQueue %s: empty recipients`, mail.ID)
+			continue
+		}
+		switch mail.State {
+		case MailStateQueued:
+			if !srv.isRelayRecipient(mail.Recipients[0]) {
+				local = append(local, mail)
+				continue
+			}
+			mail.Postpone = time.Time{}
+		case MailStateDeferred:
+		default:
+			log.Printf(`smtp: recoverQueue %s: unknown state %q`, mail.ID, mail.State)
+			continue
+		}
+		srv.retryQueue = append(srv.retryQueue, mail)
+	}
+	srv.retryMtx.Unlock()
+
+	if len(local) != 0 {
+		// The mail queue is consumed after the server started.
+		go func() {
+			for _, mail := range local {
+				srv.enqueue(srv.mailTxQueue, mail)
+			}
+		}()
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestServer_queue(t *testing.T) {
+	var (
+		dir          = t.TempDir()
+		storage, err = NewLocalStorage(dir)
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		postpone = time.Now().Add(time.Hour).Round(0)
+		received = time.Now().Add(-time.Hour).Round(0)
+
+		mailQueued = &MailTx{
+			ID:         `1.0`,
+			Received:   received,
+			From:       `sender@local`,
+			Recipients: []string{`a@remote.local`},
+			Data:       []byte("Subject: a\r\n\r\n"),
+			State:      MailStateQueued,
+		}
+		mailDeferred = &MailTx{
+			Postpone:   postpone,
+			ID:         `2.0`,
+			Received:   received,
+			From:       `sender@local`,
+			Recipients: []string{`b@remote.local`},
+			Data:       []byte("Subject: b\r\n\r\n"),
+			State:      MailStateDeferred,
+			LastError:  `451 4.4.1 No answer`,
+			Retry:      2,
+		}
+		mailLocal = &MailTx{
+			ID:         `4.0`,
+			Received:   received,
+			From:       `sender@remote.local`,
+			Recipients: []string{`first@local`},
+			Data:       []byte("Subject: d\r\n\r\n"),
+			State:      MailStateQueued,
+		}
+		mailBounced = &MailTx{
+			ID:         `3.0`,
+			Recipients: []string{`c@remote.local`},
+			State:      MailStateBounced,
+		}
+	)
+
+	for _, mail := range []*MailTx{mailQueued, mailDeferred, mailLocal, mailBounced} {
+		err = storage.MailSave(mail)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tmpFiles []os.DirEntry
+	tmpFiles, err = os.ReadDir(filepath.Join(dir, defDirTmp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `MailSave: no temporary files`, 0, len(tmpFiles))
+
+	var srv = &Server{
+		Env: &Environment{
+			PrimaryDomain: NewDomain(`local`, nil),
+		},
+		Storage:     storage,
+		mailTxQueue: make(chan *MailTx, 1),
+		relayQueue:  make(chan *MailTx, 2),
+		stopc:       make(chan struct{}),
+	}
+
+	err = srv.recoverQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		expQueued   = *mailQueued
+		expDeferred = *mailDeferred
+	)
+	expQueued.Data = nil
+	expDeferred.Data = nil
+
+	test.Assert(t, `QueueList`, []*MailTx{&expQueued, &expDeferred}, srv.QueueList())
+
+	// The queued mail for local recipient is pushed back to mail
+	// queue.
+	var gotLocal = <-srv.mailTxQueue
+	test.Assert(t, `recoverQueue: local`, mailLocal, gotLocal)
+
+	err = srv.QueueDelete(`3.0`)
+	test.Assert(t, `QueueDelete: not queued`, true, errors.Is(err, ErrMailNotQueued))
+
+	err = srv.QueueDelete(mailQueued.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.MailLoad(mailQueued.ID)
+	test.Assert(t, `QueueDelete: removed from storage`, true, errors.Is(err, os.ErrNotExist))
+
+	test.Assert(t, `QueueFlush: unknown ID`, 0, srv.QueueFlush(`5.0`))
+	test.Assert(t, `QueueFlush`, 1, srv.QueueFlush())
+	test.Assert(t, `QueueList: after flush`, 0, len(srv.QueueList()))
+
+	var got = <-srv.relayQueue
+	test.Assert(t, `QueueFlush: relayed`, mailDeferred.ID, got.ID)
+	test.Assert(t, `QueueFlush: Postpone`, true, got.Postpone.IsZero())
+}
+
+// failStorage is the Storage that always fail on MailSave.
+type failStorage struct {
+	Storage
+	deleted []string
+}
+
+func (fs *failStorage) MailSave(mail *MailTx) error {
+	if mail.ID == `1.1` {
+		return errors.New(`disk full`)
+	}
+	return nil
+}
+
+func (fs *failStorage) MailDelete(id string) error {
+	fs.deleted = append(fs.deleted, id)
+	return nil
+}
+
+// TestServer_processMailTx test that each recipient mail is stored with
+// queued state before being pushed to the mail queue, and the DSN is
+// stored before the bounced mail moved to bounce.
+func TestServer_processMailTx(t *testing.T) {
+	var (
+		dir          = t.TempDir()
+		storage, err = NewLocalStorage(dir)
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var srv = &Server{
+		Env: &Environment{
+			PrimaryDomain: NewDomain(`local`, nil),
+		},
+		Storage:     storage,
+		Handler:     &LocalHandler{},
+		mailTxQueue: make(chan *MailTx, 2),
+		bounceQueue: make(chan *MailTx, 1),
+		stopc:       make(chan struct{}),
+	}
+
+	var mail = &MailTx{
+		ID:         `1`,
+		From:       `sender@remote.local`,
+		Recipients: []string{`a@remote.local`, `b@remote.local`},
+		Data:       []byte("Subject: a\r\n\r\n"),
+	}
+
+	err = srv.processMailTx(mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		expIDs = []string{`1.0`, `1.1`}
+		stored *MailTx
+		got    *MailTx
+	)
+	for _, id := range expIDs {
+		got = <-srv.mailTxQueue
+		test.Assert(t, `queued ID`, id, got.ID)
+
+		stored, err = storage.MailLoad(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Assert(t, id+`: State`, MailStateQueued, stored.State)
+	}
+
+	// Bounce the last mail, the DSN should be stored.
+
+	got.LastError = `550 5.1.1 Bad destination mailbox address`
+	srv.bounceQueue <- got
+
+	srv.wg.Add(1)
+	go srv.processBounceQueue()
+
+	var dsn = <-srv.mailTxQueue
+	close(srv.stopc)
+	srv.wg.Wait()
+
+	stored, err = storage.MailLoad(dsn.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `DSN: State`, MailStateQueued, stored.State)
+	test.Assert(t, `DSN: Recipients`, []string{mail.From}, stored.Recipients)
+
+	// The mail that failed to be stored should not be queued.
+
+	var fs = &failStorage{}
+
+	srv.Storage = fs
+	srv.stopc = make(chan struct{})
+
+	err = srv.processMailTx(mail)
+	test.Assert(t, `processMailTx: error`, `1.1: disk full`, err.Error())
+	test.Assert(t, `processMailTx: deleted`, []string{`1.0`}, fs.deleted)
+	test.Assert(t, `processMailTx: queue`, 0, len(srv.mailTxQueue))
+}
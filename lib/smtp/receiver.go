@@ -5,16 +5,24 @@
 package smtp
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
-	"strings"
+	"strconv"
+	"time"
 
 	liberrors "github.com/shuLhan/share/lib/errors"
+	"github.com/shuLhan/share/lib/spf"
 )
 
+// maxLineLength define the maximum length of command or AUTH response
+// line, as recommended by RFC 4954 section 4.
+const maxLineLength = 12288
+
 type receiverMode int
 
 const (
@@ -32,33 +40,109 @@ type receiver struct {
 	conn net.Conn
 	mail *MailTx
 
+	// spfResult contains the result of SPF check on MAIL command.
+	spfResult *spf.Result
+
+	// ftx contains the state of session passed to Server Filters.
+	ftx *FilterTx
+
 	clientDomain  string
 	clientAddress string
 	localAddress  string
 
-	data []byte
+	// username contains the authenticated account.
+	username string
+
+	reader *bufio.Reader
+
+	// line contains the last line read from client.
+	line []byte
 	buff bytes.Buffer
 
+	// chunkSize contains the total size of BDAT chunks received in
+	// current mail transaction.
+	chunkSize int64
+
 	mode  receiverMode
 	state CommandKind
 
 	authenticated bool
+
+	// cmdWindow contains the start time of current one minute window
+	// for counting the commands.
+	cmdWindow time.Time
+	cmdCount  int
+
+	// isSMTPUTF8 is true if the MAIL command has SMTPUTF8 parameter.
+	isSMTPUTF8 bool
 }
 
 func newReceiver(conn net.Conn, mode receiverMode) (recv *receiver) {
 	recv = &receiver{
-		conn: conn,
-		mode: mode,
-		data: make([]byte, 4096),
-		mail: &MailTx{},
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		mode:   mode,
+		mail:   &MailTx{},
 	}
 
 	recv.clientAddress = conn.RemoteAddr().String()
 	recv.localAddress = conn.LocalAddr().String()
 
+	recv.ftx = &FilterTx{
+		Mail:          recv.mail,
+		ClientAddress: recv.clientAddress,
+	}
+
 	return recv
 }
 
+// allowCommand count the command received from client and return false
+// if the number of commands in the last one minute has exceeded max.
+func (recv *receiver) allowCommand(max int) bool {
+	if max <= 0 {
+		return true
+	}
+
+	var now = time.Now()
+	if now.Sub(recv.cmdWindow) >= time.Minute {
+		recv.cmdWindow = now
+		recv.cmdCount = 0
+	}
+	recv.cmdCount++
+
+	return recv.cmdCount <= max
+}
+
+// clientIP return the IP address of client.
+func (recv *receiver) clientIP() (ip net.IP) {
+	var host, _, err = net.SplitHostPort(recv.clientAddress)
+	if err != nil {
+		host = recv.clientAddress
+	}
+	return net.ParseIP(host)
+}
+
+// isEarlyTalker wait for the duration of delay and return true if client
+// send any data before the greeting.
+func (recv *receiver) isEarlyTalker(delay time.Duration) bool {
+	var err = recv.conn.SetReadDeadline(time.Now().Add(delay))
+	if err != nil {
+		log.Printf(`isEarlyTalker: %s`, err)
+		return false
+	}
+
+	_, err = recv.reader.Peek(1)
+
+	var errDeadline = recv.conn.SetReadDeadline(time.Time{})
+	if errDeadline != nil {
+		log.Printf(`isEarlyTalker: %s`, errDeadline)
+	}
+
+	// Client that close the connection is not considered as early
+	// talker, the next read will return an error anyway.
+	return err == nil
+}
+
 // close the receiving line.
 func (recv *receiver) close() {
 	err := recv.conn.Close()
@@ -76,31 +160,14 @@ func (recv *receiver) isAuthenticated() bool {
 	return false
 }
 
-// readAuthData read AUTH initial response from client into Command Param.
-func (recv *receiver) readAuthData(cmd *Command) (err error) {
-	recv.buff.Reset()
-
-	for {
-		recv.data = recv.data[0:]
-		n, err := recv.conn.Read(recv.data)
-		if n > 0 {
-			_, _ = recv.buff.Write(recv.data[:n])
-		}
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-		if n == cap(recv.data) {
-			continue
-		}
-		break
+// readAuthData read the SASL response from client.
+// The returned line does not contains the CRLF.
+func (recv *receiver) readAuthData() (line []byte, err error) {
+	line, err = recv.readLine(maxLineLength)
+	if err != nil {
+		return nil, err
 	}
-
-	cmd.Param = strings.TrimSpace(recv.buff.String())
-
-	return nil
+	return bytes.TrimSpace(line), nil
 }
 
 // readCommand from client.
@@ -111,69 +178,139 @@ func (recv *receiver) readAuthData(cmd *Command) (err error) {
 // An error returned from this function, MUST be considered error on system
 // which should stop the receiver for further processing.
 func (recv *receiver) readCommand() (cmd *Command, err error) {
-	recv.buff.Reset()
-
-	cmd = newCommand()
+	var line []byte
 
-	var n int
-	for {
-		recv.data = recv.data[0:]
-		n, err = recv.conn.Read(recv.data)
-		if n > 0 {
-			_, _ = recv.buff.Write(recv.data[:n])
-		}
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			err = fmt.Errorf("smtp: recv: readCommand: " + err.Error())
-			return nil, err
-		}
-		if n == cap(recv.data) {
-			continue
+	line, err = recv.readLine(maxLineLength)
+	if err != nil {
+		if errors.Is(err, errCmdTooLong) {
+			return nil, recv.sendError(errCmdTooLong)
 		}
-		break
+		return nil, fmt.Errorf(`smtp: recv: readCommand: %w`, err)
 	}
 
-	err = cmd.unpack(recv.buff.Bytes())
+	cmd = newCommand()
+
+	err = cmd.unpack(line)
 	if err != nil {
-		err = fmt.Errorf("smtp: cmd.unpack: " + err.Error())
-		return nil, err
+		return nil, recv.sendError(err)
 	}
 
 	return cmd, nil
 }
 
 // readDATA start mail input.
-func (recv *receiver) readDATA() (err error) {
-	var n int
+// If the size of mail data is larger than maxSize, the rest of data is
+// discarded and it will return errMessageTooBig.
+func (recv *receiver) readDATA(maxSize int64) (err error) {
+	var (
+		line    []byte
+		tooBig  bool
+		dataLen int64
+	)
 	for {
-		recv.data = recv.data[0:]
-		n, err = recv.conn.Read(recv.data)
+		line, err = recv.readLine(0)
 		if err != nil {
 			return err
 		}
-
-		recv.mail.Data = append(recv.mail.Data, recv.data[:n]...)
-
-		if recv.mail.isTerminated() {
+		if bytes.Equal(line, []byte(".\r\n")) {
 			break
 		}
+		if tooBig {
+			continue
+		}
+		dataLen += int64(len(line))
+		if maxSize > 0 && dataLen > maxSize {
+			tooBig = true
+			recv.mail.Data = nil
+			continue
+		}
+		recv.mail.Data = append(recv.mail.Data, line...)
+	}
+	if tooBig {
+		return errMessageTooBig
 	}
 
-	var l = len(recv.mail.Data)
+	// Remove the CRLF from the last line, which is part of the
+	// end-of-mail data indicator.
+	recv.mail.Data = bytes.TrimSuffix(recv.mail.Data, []byte("\r\n"))
 
-	// Remove the end-of-mail data indicator.
-	recv.mail.Data = recv.mail.Data[:l-5]
+	recv.endData()
 
-	recv.mail.seal(recv.clientDomain, recv.clientAddress, recv.localAddress)
+	return nil
+}
+
+// readChunk read the BDAT chunk with specific size from client.
+// If discard is true, the chunk is read but not stored.
+func (recv *receiver) readChunk(size int64, discard bool) (err error) {
+	if discard {
+		_, err = io.CopyN(io.Discard, recv.reader, size)
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	_, err = io.CopyN(&buf, recv.reader, size)
+	if err != nil {
+		return err
+	}
+
+	recv.mail.Data = append(recv.mail.Data, buf.Bytes()...)
+	recv.chunkSize += size
 
 	return nil
 }
 
+// endData mark the end of mail data by setting the mail ID and inserting
+// trace information.
+func (recv *receiver) endData() {
+	recv.mail.Received = time.Now().Round(0)
+	recv.mail.ID = strconv.FormatInt(recv.mail.Received.UnixNano(), 10)
+
+	recv.mail.seal(recv.clientDomain, recv.clientAddress, recv.localAddress)
+}
+
+// readLine read one line, including the CRLF, from client.
+// If maxLen is greater than zero and the line is longer than maxLen, the
+// line is discarded and it will return errCmdTooLong.
+func (recv *receiver) readLine(maxLen int) (line []byte, err error) {
+	var (
+		chunk   []byte
+		tooLong bool
+	)
+
+	recv.line = recv.line[:0]
+	for {
+		chunk, err = recv.reader.ReadSlice('\n')
+		if !tooLong {
+			recv.line = append(recv.line, chunk...)
+			if maxLen > 0 && len(recv.line) > maxLen {
+				tooLong = true
+				recv.line = recv.line[:0]
+			}
+		}
+		if err == nil {
+			break
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		return nil, err
+	}
+	if tooLong {
+		return nil, errCmdTooLong
+	}
+	return recv.line, nil
+}
+
 func (recv *receiver) reset() {
 	recv.state = CommandZERO
+	recv.spfResult = nil
+	recv.chunkSize = 0
+	recv.isSMTPUTF8 = false
 	recv.mail.Reset()
+	if recv.ftx != nil {
+		recv.ftx.reset()
+	}
 }
 
 func (recv *receiver) sendError(errRes error) (err error) {
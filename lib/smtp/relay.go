@@ -0,0 +1,171 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	liberrors "github.com/shuLhan/share/lib/errors"
+)
+
+const (
+	defRelayExpiry        = 5 * 24 * time.Hour
+	defRelayPort          = 25
+	defRelayRetryInterval = 30 * time.Minute
+)
+
+// relay the mail to the mail exchanger of recipient domain.
+//
+// It will try each mail exchanger ordered by their preference, and each IP
+// address of mail exchanger, until the mail is accepted or rejected
+// permanently.
+//
+// The returned error is always *liberrors.E, where the Code is the SMTP
+// status code.
+// If the Code is 5xx, the relay is failed permanently and it should not be
+// retried.
+func (srv *Server) relay(mail *MailTx) (err error) {
+	var (
+		rcpt = mail.Recipients[0]
+		at   = strings.LastIndexByte(rcpt, '@')
+	)
+	if at < 0 {
+		return &liberrors.E{
+			Code:    StatusMailboxIncorrect,
+			Message: `5.1.3 Bad destination mailbox address syntax`,
+		}
+	}
+
+	var (
+		domain = rcpt[at+1:]
+		hosts  []string
+	)
+
+	hosts, err = srv.Resolver.LookupMX(domain)
+	if err != nil {
+		if errors.Is(err, errDomainNotFound) {
+			return &liberrors.E{
+				Code:    StatusMailboxNotFound,
+				Message: `5.1.2 Bad destination system address: ` + domain,
+			}
+		}
+		return &liberrors.E{
+			Code:    StatusLocalError,
+			Message: `4.4.3 Directory server failure: ` + err.Error(),
+		}
+	}
+	if len(hosts) == 0 {
+		// RFC 5321, section 5.1, if the domain does not have MX
+		// record, use the domain itself as implicit MX.
+		hosts = append(hosts, domain)
+	}
+
+	var (
+		host string
+		ips  []net.IP
+		ip   net.IP
+	)
+
+	err = &liberrors.E{
+		Code:    StatusMailboxNotFound,
+		Message: `5.4.4 Unable to route to ` + domain,
+	}
+
+	for _, host = range hosts {
+		ips, err = srv.Resolver.LookupIP(host)
+		if err != nil {
+			err = &liberrors.E{
+				Code:    StatusLocalError,
+				Message: `4.4.3 Directory server failure: ` + err.Error(),
+			}
+			continue
+		}
+		for _, ip = range ips {
+			err = srv.relayTo(ip, mail)
+			if err == nil {
+				return nil
+			}
+			if isPermanentError(err) {
+				return err
+			}
+		}
+	}
+
+	return err
+}
+
+// relayTo send the mail to mail exchanger at specific IP address.
+//
+// The connection is upgraded to TLS using STARTTLS if the remote server
+// support it.
+// Since this is an opportunistic TLS (RFC 7435), the remote certificate is
+// not verified.
+func (srv *Server) relayTo(ip net.IP, mail *MailTx) (err error) {
+	var (
+		address = net.JoinHostPort(ip.String(), strconv.Itoa(srv.relayPort))
+		opts    = ClientOptions{
+			LocalName: srv.Env.PrimaryDomain.Name,
+			ServerUrl: `smtp://` + address,
+			Insecure:  true,
+		}
+
+		cl  *Client
+		res *Response
+	)
+
+	cl, err = NewClient(opts)
+	if err != nil {
+		return &liberrors.E{
+			Code:    StatusLocalError,
+			Message: `4.4.1 No answer from host ` + address + `: ` + err.Error(),
+		}
+	}
+	defer func() {
+		_, _ = cl.Quit()
+	}()
+
+	_, ok := cl.ServerInfo.Exts[`starttls`]
+	if ok {
+		_, err = cl.StartTLS()
+		if err == nil {
+			_, err = cl.ehlo(opts.LocalName)
+		}
+		if err != nil {
+			return &liberrors.E{
+				Code:    StatusLocalError,
+				Message: `4.7.0 STARTTLS failed: ` + err.Error(),
+			}
+		}
+	}
+
+	res, err = cl.sendMailTx(mail)
+	if err == nil {
+		return nil
+	}
+	if res == nil {
+		return &liberrors.E{
+			Code:    StatusLocalError,
+			Message: `4.4.2 Bad connection: ` + err.Error(),
+		}
+	}
+	return &liberrors.E{
+		Code:    res.Code,
+		Message: fmt.Sprintf(`%d %s`, res.Code, res.Message),
+	}
+}
+
+// isPermanentError return true if err is *liberrors.E with 5xx code.
+func isPermanentError(err error) bool {
+	var errRelay *liberrors.E
+	if errors.As(err, &errRelay) {
+		return errRelay.Code >= 500
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	libnet "github.com/shuLhan/share/lib/net"
+	"github.com/shuLhan/share/lib/test"
+)
+
+// testRunRemoteServer run the second server that act as the destination
+// of relayed mail.
+func testRunRemoteServer(t *testing.T) (srv *Server, handler *mockHandler) {
+	var (
+		env = &Environment{
+			PrimaryDomain: NewDomain(testRemoteDomain, nil),
+		}
+		err error
+	)
+
+	handler = newMockHandler(env)
+
+	srv = &Server{
+		address:    testRemoteAddress,
+		tlsAddress: testRemoteTLS,
+		Env:        env,
+		Handler:    handler,
+		Resolver:   testResolver,
+	}
+
+	err = srv.LoadCertificate(testFileCertificate, testFilePrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		var errStart = srv.Start()
+		if errStart != nil {
+			t.Log(errStart)
+		}
+	}()
+
+	err = libnet.WaitAlive(`tcp`, testRemoteAddress, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return srv, handler
+}
+
+func TestServer_relay(t *testing.T) {
+	var (
+		remote, remoteHandler = testRunRemoteServer(t)
+
+		cl = testNewClient(true)
+	)
+	t.Cleanup(remote.Stop)
+
+	type testCase struct {
+		desc      string
+		rcpt      string
+		expStatus string
+		expDiag   string
+		isBounced bool
+	}
+
+	var cases = []testCase{{
+		desc: `With success relay`,
+		rcpt: `user@` + testRemoteDomain,
+	}, {
+		desc:      `With unknown domain`,
+		rcpt:      `user@unknown.local`,
+		isBounced: true,
+		expStatus: `Status: 5.1.2`,
+	}, {
+		desc:      `With rejected recipient`,
+		rcpt:      `user@reject.local`,
+		isBounced: true,
+		expStatus: `Status: 5.7.1`,
+		expDiag:   `Diagnostic-Code: smtp; 550 5.7.1 Relaying denied`,
+	}, {
+		desc:      `With expired relay`,
+		rcpt:      `user@down.local`,
+		isBounced: true,
+		expStatus: `Status: 4.4.1`,
+		expDiag:   `connection refused`,
+	}}
+
+	var (
+		from = testAccountFirst.Short()
+		data = []byte("Subject: relay\r\n\r\nHello\r\n")
+
+		c    testCase
+		mail *MailTx
+		err  error
+	)
+
+	for _, c = range cases {
+		t.Log(c.desc)
+
+		_, err = cl.MailTx(NewMailTx(from, []string{c.rcpt}, data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !c.isBounced {
+			mail = remoteHandler.waitMail(5 * time.Second)
+			if mail == nil {
+				t.Fatalf(`%s: timeout waiting relayed mail`, c.desc)
+			}
+			test.Assert(t, `From`, from, mail.From)
+			test.Assert(t, `Recipients`, []string{c.rcpt}, mail.Recipients)
+			test.Assert(t, `has Subject`, true,
+				bytes.Contains(mail.Data, []byte("Subject: relay\r\n")))
+			continue
+		}
+
+		mail = testHandler.waitMail(5 * time.Second)
+		if mail == nil {
+			t.Fatalf(`%s: timeout waiting DSN`, c.desc)
+		}
+
+		test.Assert(t, `DSN From`, ``, mail.From)
+		test.Assert(t, `DSN Recipients`, []string{from}, mail.Recipients)
+		test.Assert(t, `DSN has Final-Recipient`, true,
+			bytes.Contains(mail.Data, []byte(`Final-Recipient: rfc822; `+c.rcpt)))
+		test.Assert(t, `DSN has `+c.expStatus, true,
+			bytes.Contains(mail.Data, []byte(c.expStatus)))
+		if len(c.expDiag) > 0 {
+			test.Assert(t, `DSN has `+c.expDiag, true,
+				bytes.Contains(mail.Data, []byte(c.expDiag)))
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	libdns "github.com/shuLhan/share/lib/dns"
+)
+
+// defNameServer define the name server used by default resolver if the
+// system does not have any name servers.
+const defNameServer = `1.1.1.1`
+
+// errDomainNotFound returned by Resolver if the domain name does not exist.
+var errDomainNotFound = errors.New(`domain name does not exist`)
+
+// Resolver define an interface to lookup the DNS records required by the
+// Server.
+type Resolver interface {
+	// LookupMX return list of mail exchanger host names for domain,
+	// ordered by their preference.
+	// If domain does not exist it should return an error
+	// errDomainNotFound.
+	LookupMX(domain string) (hosts []string, err error)
+
+	// LookupIP return list of IPv4 and IPv6 addresses of host.
+	LookupIP(host string) (ips []net.IP, err error)
+
+	// LookupTXT return list of TXT records of domain.
+	// If domain does not exist it should return an error
+	// errDomainNotFound.
+	LookupTXT(domain string) (txts []string, err error)
+
+	// LookupAddr return list of host names of IP address using the
+	// PTR record.
+	LookupAddr(ip net.IP) (names []string, err error)
+}
+
+// dnsResolver implement the Resolver using the DNS client from lib/dns.
+type dnsResolver struct {
+	pool *libdns.UDPClientPool
+}
+
+// newDNSResolver create new resolver using list of name servers.
+// If nameServers is empty, it will use the name servers from system
+// resolv.conf.
+func newDNSResolver(nameServers []string) (res *dnsResolver, err error) {
+	if len(nameServers) == 0 {
+		nameServers = libdns.GetSystemNameServers(``)
+		if len(nameServers) == 0 {
+			nameServers = append(nameServers, defNameServer)
+		}
+	}
+
+	res = &dnsResolver{}
+
+	res.pool, err = libdns.NewUDPClientPool(nameServers)
+	if err != nil {
+		return nil, fmt.Errorf(`newDNSResolver: %w`, err)
+	}
+	return res, nil
+}
+
+// LookupMX lookup the MX records of domain.
+func (res *dnsResolver) LookupMX(domain string) (hosts []string, err error) {
+	var answers []libdns.ResourceRecord
+
+	answers, err = res.lookup(domain, libdns.RecordTypeMX)
+	if err != nil {
+		return nil, fmt.Errorf(`LookupMX: %w`, err)
+	}
+
+	var mxs = make([]*libdns.RDataMX, 0, len(answers))
+	for _, rr := range answers {
+		var mx, ok = rr.Value.(*libdns.RDataMX)
+		if ok {
+			mxs = append(mxs, mx)
+		}
+	}
+
+	sort.SliceStable(mxs, func(x, y int) bool {
+		return mxs[x].Preference < mxs[y].Preference
+	})
+
+	for _, mx := range mxs {
+		hosts = append(hosts, mx.Exchange)
+	}
+	return hosts, nil
+}
+
+// LookupIP lookup the A and AAAA records of host.
+func (res *dnsResolver) LookupIP(host string) (ips []net.IP, err error) {
+	var (
+		rtypes = []libdns.RecordType{libdns.RecordTypeA, libdns.RecordTypeAAAA}
+
+		answers []libdns.ResourceRecord
+		rtype   libdns.RecordType
+	)
+	for _, rtype = range rtypes {
+		answers, err = res.lookup(host, rtype)
+		if err != nil {
+			return nil, fmt.Errorf(`LookupIP: %w`, err)
+		}
+		for _, rr := range answers {
+			var v, ok = rr.Value.(string)
+			if !ok {
+				continue
+			}
+			var ip = net.ParseIP(v)
+			if ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips, nil
+}
+
+// LookupTXT lookup the TXT records of domain.
+func (res *dnsResolver) LookupTXT(domain string) (txts []string, err error) {
+	var answers []libdns.ResourceRecord
+
+	answers, err = res.lookup(domain, libdns.RecordTypeTXT)
+	if err != nil {
+		return nil, fmt.Errorf(`LookupTXT: %w`, err)
+	}
+	for _, rr := range answers {
+		var txt, ok = rr.Value.(string)
+		if ok {
+			txts = append(txts, txt)
+		}
+	}
+	return txts, nil
+}
+
+// LookupAddr lookup the PTR record of IP address.
+func (res *dnsResolver) LookupAddr(ip net.IP) (names []string, err error) {
+	var (
+		cl   = res.pool.Get()
+		name string
+	)
+
+	name, err = libdns.LookupPTR(cl, ip)
+	res.pool.Put(cl)
+	if err != nil {
+		return nil, fmt.Errorf(`LookupAddr: %w`, err)
+	}
+	if len(name) > 0 {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// lookup query the DNS record with specific type on domain name.
+func (res *dnsResolver) lookup(dname string, rtype libdns.RecordType) (
+	answers []libdns.ResourceRecord, err error,
+) {
+	var (
+		cl = res.pool.Get()
+		q  = libdns.MessageQuestion{
+			Name: dname,
+			Type: rtype,
+		}
+
+		msg *libdns.Message
+	)
+
+	msg, err = cl.Lookup(q, true)
+	res.pool.Put(cl)
+	if err != nil {
+		return nil, err
+	}
+
+	switch msg.Header.RCode {
+	case libdns.RCodeOK:
+	case libdns.RCodeErrName:
+		return nil, errDomainNotFound
+	default:
+		return nil, fmt.Errorf(`%s: DNS response code %d`, dname, msg.Header.RCode)
+	}
+
+	return msg.FilterAnswers(rtype), nil
+}
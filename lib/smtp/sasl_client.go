@@ -0,0 +1,212 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// saslClient define the client side of SASL mechanism exchange.
+type saslClient interface {
+	// start return the initial response, or nil if the mechanism does
+	// not have initial response.
+	start() ([]byte, error)
+
+	// next return the response for the server challenge.
+	next(challenge []byte) ([]byte, error)
+}
+
+// newSaslClient create the client side of SASL mechanism.
+// For XOAUTH2, the password is the OAuth 2.0 access token.
+// It will return nil if the mechanism is unknown.
+func newSaslClient(mech SaslMechanism, username, password string) saslClient {
+	switch mech {
+	case SaslMechanismPlain:
+		return &saslPlainClient{user: username, pass: password}
+	case SaslMechanismLogin:
+		return &saslLoginClient{user: username, pass: password}
+	case SaslMechanismCramMD5:
+		return &saslCramMD5Client{user: username, pass: password}
+	case SaslMechanismScramSHA256:
+		return &saslScramClient{user: username, pass: password}
+	case SaslMechanismXOAuth2:
+		return &saslXOAuth2Client{user: username, token: password}
+	}
+	return nil
+}
+
+type saslPlainClient struct {
+	user string
+	pass string
+}
+
+func (plain *saslPlainClient) start() ([]byte, error) {
+	return []byte("\x00" + plain.user + "\x00" + plain.pass), nil
+}
+
+func (plain *saslPlainClient) next(_ []byte) ([]byte, error) {
+	return nil, errors.New(`PLAIN: unexpected server challenge`)
+}
+
+type saslLoginClient struct {
+	user string
+	pass string
+	step int
+}
+
+func (login *saslLoginClient) start() ([]byte, error) {
+	return nil, nil
+}
+
+func (login *saslLoginClient) next(challenge []byte) ([]byte, error) {
+	login.step++
+	switch {
+	case bytes.HasPrefix(bytes.ToLower(challenge), []byte(`user`)):
+		return []byte(login.user), nil
+	case bytes.HasPrefix(bytes.ToLower(challenge), []byte(`pass`)):
+		return []byte(login.pass), nil
+	case login.step == 1:
+		return []byte(login.user), nil
+	case login.step == 2:
+		return []byte(login.pass), nil
+	}
+	return nil, errors.New(`LOGIN: unexpected server challenge`)
+}
+
+type saslCramMD5Client struct {
+	user string
+	pass string
+}
+
+func (cram *saslCramMD5Client) start() ([]byte, error) {
+	return nil, nil
+}
+
+func (cram *saslCramMD5Client) next(challenge []byte) ([]byte, error) {
+	var mac = hmac.New(md5.New, []byte(cram.pass))
+	_, _ = mac.Write(challenge)
+	return []byte(cram.user + ` ` + hex.EncodeToString(mac.Sum(nil))), nil
+}
+
+type saslScramClient struct {
+	user      string
+	pass      string
+	cnonce    string
+	bare      string
+	serverSig []byte
+	step      int
+}
+
+func (scram *saslScramClient) start() ([]byte, error) {
+	var nonce = make([]byte, 18)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	scram.cnonce = base64.StdEncoding.EncodeToString(nonce)
+
+	var user = strings.NewReplacer(`=`, `=3D`, `,`, `=2C`).Replace(scram.user)
+
+	scram.bare = `n=` + user + `,r=` + scram.cnonce
+
+	return []byte(`n,,` + scram.bare), nil
+}
+
+func (scram *saslScramClient) next(challenge []byte) ([]byte, error) {
+	scram.step++
+	if scram.step == 2 {
+		return scram.verify(challenge)
+	}
+	if scram.step > 2 {
+		return nil, errors.New(`SCRAM-SHA-256: unexpected server challenge`)
+	}
+
+	var (
+		serverFirst = string(challenge)
+		attrs       = parseScramAttrs(serverFirst)
+		nonce       = attrs['r']
+
+		salt []byte
+		iter int
+		err  error
+	)
+
+	if !strings.HasPrefix(nonce, scram.cnonce) {
+		return nil, errors.New(`SCRAM-SHA-256: invalid server nonce`)
+	}
+
+	salt, err = base64.StdEncoding.DecodeString(attrs['s'])
+	if err != nil {
+		return nil, errors.New(`SCRAM-SHA-256: invalid salt`)
+	}
+
+	iter, err = strconv.Atoi(attrs['i'])
+	if err != nil || iter <= 0 {
+		return nil, errors.New(`SCRAM-SHA-256: invalid iteration count`)
+	}
+
+	var (
+		withoutProof = `c=biws,r=` + nonce
+		authMsg      = scram.bare + `,` + serverFirst + `,` + withoutProof
+
+		saltedPass = pbkdf2.Key([]byte(scram.pass), salt, iter, sha256.Size, sha256.New)
+		clientKey  = hmacSHA256(saltedPass, []byte(`Client Key`))
+		storedKey  = sha256.Sum256(clientKey)
+		clientSig  = hmacSHA256(storedKey[:], []byte(authMsg))
+		serverKey  = hmacSHA256(saltedPass, []byte(`Server Key`))
+	)
+
+	scram.serverSig = hmacSHA256(serverKey, []byte(authMsg))
+
+	for x := range clientKey {
+		clientKey[x] ^= clientSig[x]
+	}
+
+	var clientFinal = withoutProof + `,p=` + base64.StdEncoding.EncodeToString(clientKey)
+
+	return []byte(clientFinal), nil
+}
+
+// verify the server signature in server-final-message.
+func (scram *saslScramClient) verify(challenge []byte) ([]byte, error) {
+	var attrs = parseScramAttrs(string(challenge))
+
+	if errMsg, ok := attrs['e']; ok {
+		return nil, errors.New(`SCRAM-SHA-256: ` + errMsg)
+	}
+
+	var sig, err = base64.StdEncoding.DecodeString(attrs['v'])
+	if err != nil || !hmac.Equal(sig, scram.serverSig) {
+		return nil, errors.New(`SCRAM-SHA-256: invalid server signature`)
+	}
+	return []byte{}, nil
+}
+
+type saslXOAuth2Client struct {
+	user  string
+	token string
+}
+
+func (xoauth *saslXOAuth2Client) start() ([]byte, error) {
+	return []byte("user=" + xoauth.user + "\x01auth=Bearer " + xoauth.token + "\x01\x01"), nil
+}
+
+// next handle the error challenge from server by sending an empty
+// response, so the server can reply with the final status.
+func (xoauth *saslXOAuth2Client) next(_ []byte) ([]byte, error) {
+	return []byte{}, nil
+}
@@ -4,11 +4,52 @@
 
 package smtp
 
+import "strings"
+
 // SaslMechanism represent Simple Authentication and Security Layer (SASL)
 // mechanism (RFC 4422).
 type SaslMechanism int
 
 // List of available SASL mechanism.
 const (
-	SaslMechanismPlain SaslMechanism = 1
+	SaslMechanismPlain       SaslMechanism = 1
+	SaslMechanismLogin       SaslMechanism = 2 // draft-murchison-sasl-login.
+	SaslMechanismCramMD5     SaslMechanism = 3 // RFC 2195.
+	SaslMechanismScramSHA256 SaslMechanism = 4 // RFC 5802 and RFC 7677.
+	SaslMechanismXOAuth2     SaslMechanism = 5 // Google and Microsoft OAuth 2.0.
 )
+
+// saslMechanismNames contains the mapping of SASL mechanism to its name in
+// AUTH command.
+var saslMechanismNames = map[SaslMechanism]string{
+	SaslMechanismPlain:       `PLAIN`,
+	SaslMechanismLogin:       `LOGIN`,
+	SaslMechanismCramMD5:     `CRAM-MD5`,
+	SaslMechanismScramSHA256: `SCRAM-SHA-256`,
+	SaslMechanismXOAuth2:     `XOAUTH2`,
+}
+
+// defAuthMechanisms define the default SASL mechanisms that server
+// advertise if [Server.AuthMechanisms] is empty.
+var defAuthMechanisms = []SaslMechanism{
+	SaslMechanismPlain,
+	SaslMechanismLogin,
+	SaslMechanismScramSHA256,
+}
+
+// parseSaslMechanism return the SASL mechanism by its name, case
+// insensitive.
+// It will return 0 if the name is unknown.
+func parseSaslMechanism(name string) SaslMechanism {
+	for mech, mechName := range saslMechanismNames {
+		if strings.EqualFold(name, mechName) {
+			return mech
+		}
+	}
+	return 0
+}
+
+// String return the name of SASL mechanism, as used in AUTH command.
+func (mech SaslMechanism) String() string {
+	return saslMechanismNames[mech]
+}
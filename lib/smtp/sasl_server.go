@@ -0,0 +1,372 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// saslServer define the server side of SASL mechanism exchange.
+type saslServer interface {
+	// next process the client response and return the next server
+	// challenge.
+	// The resp is nil if client does not send the initial response.
+	// If done is true, the client has been authenticated.
+	next(resp []byte) (challenge []byte, done bool, err error)
+
+	// username return the authenticated identity.
+	username() string
+}
+
+// newSaslServer create the server side of SASL mechanism.
+// It will return nil if the mechanism is unknown.
+func newSaslServer(mech SaslMechanism, cred CredentialStore, hostname string) saslServer {
+	switch mech {
+	case SaslMechanismPlain:
+		return &saslPlainServer{cred: cred}
+	case SaslMechanismLogin:
+		return &saslLoginServer{cred: cred}
+	case SaslMechanismCramMD5:
+		return &saslCramMD5Server{cred: cred, hostname: hostname}
+	case SaslMechanismScramSHA256:
+		return &saslScramServer{cred: cred}
+	case SaslMechanismXOAuth2:
+		return &saslXOAuth2Server{cred: cred}
+	}
+	return nil
+}
+
+// saslPlainServer implement the PLAIN mechanism (RFC 4616).
+type saslPlainServer struct {
+	cred CredentialStore
+	user string
+}
+
+func (plain *saslPlainServer) next(resp []byte) (challenge []byte, done bool, err error) {
+	if resp == nil {
+		return []byte{}, false, nil
+	}
+
+	// message = [authzid] UTF8NUL authcid UTF8NUL passwd
+	var args = bytes.Split(resp, []byte{0})
+	if len(args) != 3 {
+		return nil, false, errCmdSyntaxError
+	}
+	if len(args[0]) != 0 && !bytes.Equal(args[0], args[1]) {
+		// Authorizing as other user is not supported.
+		return nil, false, ErrInvalidCredential
+	}
+
+	err = plain.cred.Authenticate(string(args[1]), string(args[2]))
+	if err != nil {
+		return nil, false, err
+	}
+
+	plain.user = string(args[1])
+
+	return nil, true, nil
+}
+
+func (plain *saslPlainServer) username() string {
+	return plain.user
+}
+
+// saslLoginServer implement the LOGIN mechanism.
+type saslLoginServer struct {
+	cred  CredentialStore
+	user  string
+	state int
+}
+
+func (login *saslLoginServer) next(resp []byte) (challenge []byte, done bool, err error) {
+	switch login.state {
+	case 0:
+		if resp == nil {
+			login.state = 1
+			return []byte(`Username:`), false, nil
+		}
+		// The initial response contains the user name.
+		login.user = string(resp)
+		login.state = 2
+		return []byte(`Password:`), false, nil
+
+	case 1:
+		login.user = string(resp)
+		login.state = 2
+		return []byte(`Password:`), false, nil
+	}
+
+	err = login.cred.Authenticate(login.user, string(resp))
+	if err != nil {
+		return nil, false, err
+	}
+	return nil, true, nil
+}
+
+func (login *saslLoginServer) username() string {
+	return login.user
+}
+
+// saslCramMD5Server implement the CRAM-MD5 mechanism (RFC 2195).
+type saslCramMD5Server struct {
+	cred      CredentialStore
+	hostname  string
+	user      string
+	challenge []byte
+}
+
+func (cram *saslCramMD5Server) next(resp []byte) (challenge []byte, done bool, err error) {
+	if cram.challenge == nil {
+		if len(resp) != 0 {
+			return nil, false, errCmdSyntaxError
+		}
+
+		var nonce = make([]byte, 8)
+		_, err = rand.Read(nonce)
+		if err != nil {
+			return nil, false, err
+		}
+
+		cram.challenge = []byte(fmt.Sprintf(`<%x.%d@%s>`, nonce,
+			time.Now().Unix(), cram.hostname))
+
+		return cram.challenge, false, nil
+	}
+
+	// resp = user SP digest
+	var sp = bytes.LastIndexByte(resp, ' ')
+	if sp <= 0 {
+		return nil, false, errCmdSyntaxError
+	}
+
+	var (
+		user   = string(resp[:sp])
+		digest = resp[sp+1:]
+		pass   string
+	)
+
+	pass, err = cram.cred.Password(user)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var mac = hmac.New(md5.New, []byte(pass))
+	_, _ = mac.Write(cram.challenge)
+
+	var exp = []byte(hex.EncodeToString(mac.Sum(nil)))
+	if !hmac.Equal(exp, bytes.ToLower(digest)) {
+		return nil, false, ErrInvalidCredential
+	}
+
+	cram.user = user
+
+	return nil, true, nil
+}
+
+func (cram *saslCramMD5Server) username() string {
+	return cram.user
+}
+
+// saslScramServer implement the SCRAM-SHA-256 mechanism (RFC 5802 and
+// RFC 7677), without channel binding.
+type saslScramServer struct {
+	cred    CredentialStore
+	scram   *ScramCredential
+	user    string
+	gs2     string
+	nonce   string
+	authMsg string
+	state   int
+}
+
+func (scram *saslScramServer) next(resp []byte) (challenge []byte, done bool, err error) {
+	switch scram.state {
+	case 0:
+		if resp == nil {
+			return []byte{}, false, nil
+		}
+		return scram.serverFirst(string(resp))
+	case 1:
+		return scram.serverFinal(string(resp))
+	}
+	// The client acknowledge the server signature.
+	if len(resp) != 0 {
+		return nil, false, errCmdSyntaxError
+	}
+	return nil, true, nil
+}
+
+// serverFirst process the client-first-message and return the
+// server-first-message.
+func (scram *saslScramServer) serverFirst(clientFirst string) (challenge []byte, done bool, err error) {
+	// client-first-message = gs2-header client-first-message-bare
+	// gs2-header = gs2-cbind-flag "," [ authzid ] ","
+	if len(clientFirst) < 3 || (clientFirst[0] != 'n' && clientFirst[0] != 'y') {
+		return nil, false, errCmdSyntaxError
+	}
+	var x = strings.IndexByte(clientFirst[2:], ',')
+	if clientFirst[1] != ',' || x < 0 {
+		return nil, false, errCmdSyntaxError
+	}
+	x += 3
+
+	scram.gs2 = clientFirst[:x]
+
+	var (
+		bare  = clientFirst[x:]
+		attrs = parseScramAttrs(bare)
+
+		cnonce string
+		ok     bool
+	)
+
+	scram.user, ok = attrs['n']
+	if !ok || len(scram.user) == 0 {
+		return nil, false, errCmdSyntaxError
+	}
+	scram.user = strings.NewReplacer(`=2C`, `,`, `=3D`, `=`).Replace(scram.user)
+
+	cnonce, ok = attrs['r']
+	if !ok || len(cnonce) == 0 {
+		return nil, false, errCmdSyntaxError
+	}
+
+	scram.scram, err = scram.cred.ScramCredential(scram.user)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var snonce = make([]byte, 18)
+	_, err = rand.Read(snonce)
+	if err != nil {
+		return nil, false, err
+	}
+
+	scram.nonce = cnonce + base64.StdEncoding.EncodeToString(snonce)
+
+	var serverFirst = `r=` + scram.nonce +
+		`,s=` + base64.StdEncoding.EncodeToString(scram.scram.Salt) +
+		`,i=` + strconv.Itoa(scram.scram.Iteration)
+
+	scram.authMsg = bare + `,` + serverFirst
+	scram.state = 1
+
+	return []byte(serverFirst), false, nil
+}
+
+// serverFinal verify the client-final-message and return the
+// server-final-message.
+func (scram *saslScramServer) serverFinal(clientFinal string) (challenge []byte, done bool, err error) {
+	var x = strings.LastIndex(clientFinal, `,p=`)
+	if x < 0 {
+		return nil, false, errCmdSyntaxError
+	}
+
+	var (
+		withoutProof = clientFinal[:x]
+		attrs        = parseScramAttrs(withoutProof)
+
+		proof []byte
+	)
+
+	if attrs['c'] != base64.StdEncoding.EncodeToString([]byte(scram.gs2)) {
+		return nil, false, ErrInvalidCredential
+	}
+	if attrs['r'] != scram.nonce {
+		return nil, false, ErrInvalidCredential
+	}
+
+	proof, err = base64.StdEncoding.DecodeString(clientFinal[x+3:])
+	if err != nil || len(proof) != sha256.Size {
+		return nil, false, errCmdSyntaxError
+	}
+
+	scram.authMsg += `,` + withoutProof
+
+	var clientSig = hmacSHA256(scram.scram.StoredKey, []byte(scram.authMsg))
+	for x = range proof {
+		proof[x] ^= clientSig[x]
+	}
+
+	var storedKey = sha256.Sum256(proof)
+	if !hmac.Equal(storedKey[:], scram.scram.StoredKey) {
+		return nil, false, ErrInvalidCredential
+	}
+
+	var serverSig = hmacSHA256(scram.scram.ServerKey, []byte(scram.authMsg))
+
+	scram.state = 2
+
+	return []byte(`v=` + base64.StdEncoding.EncodeToString(serverSig)), false, nil
+}
+
+func (scram *saslScramServer) username() string {
+	return scram.user
+}
+
+// parseScramAttrs parse the SCRAM message "a=value,b=value" into map of
+// attribute name and its value.
+func parseScramAttrs(msg string) (attrs map[byte]string) {
+	attrs = make(map[byte]string)
+	for _, field := range strings.Split(msg, `,`) {
+		if len(field) < 2 || field[1] != '=' {
+			continue
+		}
+		attrs[field[0]] = field[2:]
+	}
+	return attrs
+}
+
+// saslXOAuth2Server implement the XOAUTH2 mechanism.
+type saslXOAuth2Server struct {
+	cred CredentialStore
+	user string
+}
+
+func (xoauth *saslXOAuth2Server) next(resp []byte) (challenge []byte, done bool, err error) {
+	if resp == nil {
+		return []byte{}, false, nil
+	}
+
+	// resp = "user=" user "^Aauth=Bearer " token "^A^A"
+	var (
+		user  string
+		token string
+	)
+	for _, field := range bytes.Split(resp, []byte{1}) {
+		switch {
+		case bytes.HasPrefix(field, []byte(`user=`)):
+			user = string(field[5:])
+		case bytes.HasPrefix(field, []byte(`auth=Bearer `)):
+			token = string(field[12:])
+		}
+	}
+	if len(user) == 0 || len(token) == 0 {
+		return nil, false, errCmdSyntaxError
+	}
+
+	err = xoauth.cred.AuthenticateToken(user, token)
+	if err != nil {
+		return nil, false, err
+	}
+
+	xoauth.user = user
+
+	return nil, true, nil
+}
+
+func (xoauth *saslXOAuth2Server) username() string {
+	return xoauth.user
+}
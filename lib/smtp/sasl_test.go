@@ -0,0 +1,200 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+// mockCredential implement the CredentialStore with single account.
+type mockCredential struct {
+	scram    *ScramCredential
+	username string
+	password string
+	token    string
+}
+
+func (mc *mockCredential) Authenticate(username, password string) error {
+	if username != mc.username || password != mc.password {
+		return ErrInvalidCredential
+	}
+	return nil
+}
+
+func (mc *mockCredential) Password(username string) (string, error) {
+	if username != mc.username {
+		return ``, ErrInvalidCredential
+	}
+	return mc.password, nil
+}
+
+func (mc *mockCredential) ScramCredential(username string) (*ScramCredential, error) {
+	if username != mc.username {
+		return nil, ErrInvalidCredential
+	}
+	return mc.scram, nil
+}
+
+func (mc *mockCredential) AuthenticateToken(username, token string) error {
+	if username != mc.username || token != mc.token {
+		return ErrInvalidCredential
+	}
+	return nil
+}
+
+func TestSasl(t *testing.T) {
+	var cred = &mockCredential{
+		username: `user@example.org`,
+		password: `pencil`,
+		token:    `ya29.token`,
+		scram:    NewScramCredential(`pencil`, []byte(`salt`), 0),
+	}
+
+	type testCase struct {
+		desc     string
+		password string
+		expErr   string
+		mech     SaslMechanism
+	}
+
+	var cases = []testCase{{
+		desc:     `PLAIN`,
+		mech:     SaslMechanismPlain,
+		password: `pencil`,
+	}, {
+		desc:     `PLAIN with invalid password`,
+		mech:     SaslMechanismPlain,
+		password: `invalid`,
+		expErr:   ErrInvalidCredential.Message,
+	}, {
+		desc:     `LOGIN`,
+		mech:     SaslMechanismLogin,
+		password: `pencil`,
+	}, {
+		desc:     `CRAM-MD5`,
+		mech:     SaslMechanismCramMD5,
+		password: `pencil`,
+	}, {
+		desc:     `CRAM-MD5 with invalid password`,
+		mech:     SaslMechanismCramMD5,
+		password: `invalid`,
+		expErr:   ErrInvalidCredential.Message,
+	}, {
+		desc:     `SCRAM-SHA-256`,
+		mech:     SaslMechanismScramSHA256,
+		password: `pencil`,
+	}, {
+		desc:     `SCRAM-SHA-256 with invalid password`,
+		mech:     SaslMechanismScramSHA256,
+		password: `invalid`,
+		expErr:   ErrInvalidCredential.Message,
+	}, {
+		desc:     `XOAUTH2`,
+		mech:     SaslMechanismXOAuth2,
+		password: `ya29.token`,
+	}, {
+		desc:     `XOAUTH2 with invalid token`,
+		mech:     SaslMechanismXOAuth2,
+		password: `invalid`,
+		expErr:   ErrInvalidCredential.Message,
+	}}
+
+	var (
+		c         testCase
+		client    saslClient
+		server    saslServer
+		resp      []byte
+		challenge []byte
+		done      bool
+		err       error
+	)
+	for _, c = range cases {
+		t.Log(c.desc)
+
+		client = newSaslClient(c.mech, cred.username, c.password)
+		server = newSaslServer(c.mech, cred, `mail.example.org`)
+
+		resp, err = client.start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			challenge, done, err = server.next(resp)
+			if err != nil || done {
+				break
+			}
+			resp, err = client.next(challenge)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err != nil {
+			test.Assert(t, `error`, c.expErr, err.Error())
+			continue
+		}
+		test.Assert(t, `error`, c.expErr, ``)
+		test.Assert(t, `username`, cred.username, server.username())
+	}
+}
+
+func TestSaslPlainServer_authzid(t *testing.T) {
+	var cred = &mockCredential{
+		username: `user@example.org`,
+		password: `pencil`,
+	}
+
+	type testCase struct {
+		desc   string
+		resp   string
+		expErr string
+	}
+
+	var cases = []testCase{{
+		desc: `Without authzid`,
+		resp: "\x00user@example.org\x00pencil",
+	}, {
+		desc: `With authzid equal to authcid`,
+		resp: "user@example.org\x00user@example.org\x00pencil",
+	}, {
+		desc:   `With authzid different from authcid`,
+		resp:   "admin@example.org\x00user@example.org\x00pencil",
+		expErr: ErrInvalidCredential.Message,
+	}}
+
+	var (
+		c      testCase
+		server saslServer
+		err    error
+	)
+	for _, c = range cases {
+		server = newSaslServer(SaslMechanismPlain, cred, `mail.example.org`)
+
+		_, _, err = server.next([]byte(c.resp))
+		if err != nil {
+			test.Assert(t, c.desc, c.expErr, err.Error())
+			continue
+		}
+		test.Assert(t, c.desc, c.expErr, ``)
+	}
+}
+
+func TestServer_initialize_cramMD5(t *testing.T) {
+	var (
+		srv = &Server{
+			Env: &Environment{
+				PrimaryDomain: NewDomain(testDomain, nil),
+			},
+			AuthMechanisms: []SaslMechanism{SaslMechanismPlain, SaslMechanismCramMD5},
+		}
+		expErr = `smtp: AuthMechanisms CRAM-MD5 require Credentials`
+		err    = srv.initialize()
+	)
+	if err == nil {
+		t.Fatalf(`expecting error %q`, expErr)
+	}
+	test.Assert(t, `initialize`, expErr, err.Error())
+}
@@ -8,15 +8,26 @@ import (
 	"bytes"
 	"crypto/tls"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/shuLhan/share/lib/email"
+	"github.com/shuLhan/share/lib/email/dkim"
+	liberrors "github.com/shuLhan/share/lib/errors"
 )
 
 const (
 	localPostmaster = "postmaster"
+
+	// defMaxMessageSize define the default maximum message size, 10 MiB.
+	defMaxMessageSize int64 = 10 << 20
 )
 
 // Server defines parameters for running an SMTP server.
@@ -32,6 +43,13 @@ type Server struct {
 	//
 	Handler Handler
 
+	// Credentials define the backend that store the account credentials
+	// for AUTH command.
+	// This field is optional, if not set, it will use the Handler
+	// ServeAuth for PLAIN and LOGIN mechanisms, and the Account in
+	// Environment for SCRAM-SHA-256 mechanism.
+	Credentials CredentialStore
+
 	// listenMta is a socket that listen for new connection from other mail
 	// transfer agent (MTA) on port 25.
 	listenMta net.Listener
@@ -50,6 +68,48 @@ type Server struct {
 	// relayQueue hold mail objects that need to be relayed to other MTA.
 	relayQueue chan *MailTx
 
+	// stopc signal all queue processors to stop.
+	stopc chan struct{}
+
+	// Storage define the permanent storage for mail objects that are
+	// waiting to be delivered or relayed.
+	// Each accepted mail is saved into Storage before the server reply
+	// to the client.
+	// Each change on the mail delivery state is saved into Storage, and
+	// the queued and deferred mails are loaded back when server Start.
+	// This field is optional, if its nil the mail objects only live in
+	// memory.
+	Storage Storage
+
+	// Resolver define the DNS resolver to lookup the mail exchanger of
+	// relayed domain.
+	// This field is optional, default to resolver that use the system
+	// name servers.
+	Resolver Resolver
+
+	// Inbound define the sender authentication checks for incoming mail
+	// from other MTA.
+	// This field is optional, if its nil no checks will be applied.
+	Inbound *InboundPolicy
+
+	// Filters define the list of Filter that are called on each stage
+	// of SMTP session, in order.
+	// This field is optional.
+	Filters []Filter
+
+	// Abuse define the limits and checks to protect server from abusive
+	// clients.
+	// This field is optional, if its nil, only the default maximum
+	// recipients per mail transaction is applied.
+	Abuse *AbusePolicy
+
+	// conns contains the number of connections per client IP address.
+	conns map[string]int
+
+	// dkimKeys cache the DKIM public keys when verifying the incoming
+	// mail.
+	dkimKeys *dkim.KeyPool
+
 	// TLSCert the server certificate for TLS or nil if no certificate.
 	// This field is optional, if its non nil, the server will also listen
 	// on address defined in TLSAddress.
@@ -65,13 +125,48 @@ type Server struct {
 	// If its empty, it will set default to ":465".
 	tlsAddress string
 
+	// retryQueue hold mail objects that failed to be relayed
+	// temporarily.
+	retryQueue []*MailTx
+
 	//
 	// Exts define list of custom extensions that the server will provide.
 	//
 	Exts []Extension
 
-	wg      sync.WaitGroup
-	running bool
+	// AuthMechanisms define list of SASL mechanisms that server accept
+	// on AUTH command.
+	// This field is optional, default to PLAIN, LOGIN, and
+	// SCRAM-SHA-256.
+	// The CRAM-MD5 mechanism require the plain text password, so it
+	// can be set only if the Credentials is set.
+	AuthMechanisms []SaslMechanism
+
+	// MaxMessageSize define the maximum size of message data, in bytes,
+	// advertised by the SIZE extension (RFC 1870).
+	// This field is optional, default to 10 MiB.
+	MaxMessageSize int64
+
+	// RelayRetryInterval define the initial duration to wait before
+	// retrying the failed relay.
+	// The duration is doubled on each retry.
+	// This field is optional, default to 30 minutes.
+	RelayRetryInterval time.Duration
+
+	// RelayExpiry define the maximum duration of mail kept in the queue
+	// before its bounced back to sender.
+	// This field is optional, default to 5 days.
+	RelayExpiry time.Duration
+
+	// relayPort define the port of remote server when relaying mail.
+	// This field is optional and exist only for the purpose of testing.
+	// If its zero, it will set default to 25.
+	relayPort int
+
+	wg       sync.WaitGroup
+	retryMtx sync.Mutex
+	connMtx  sync.Mutex
+	running  bool
 }
 
 // LoadCertificate load TLS certificate and its private key from file.
@@ -94,11 +189,19 @@ func (srv *Server) Start() (err error) {
 		return
 	}
 
+	err = srv.recoverQueue()
+	if err != nil {
+		return fmt.Errorf(`smtp: %w`, err)
+	}
+
 	srv.running = true
 
 	srv.wg.Add(1)
 	go srv.processRelayQueue()
 
+	srv.wg.Add(1)
+	go srv.processRetryQueue()
+
 	srv.wg.Add(1)
 	go srv.processBounceQueue()
 
@@ -129,9 +232,14 @@ func (srv *Server) Stop() {
 		log.Printf("smtp: listenMta.Close: %s", err)
 	}
 
-	close(srv.mailTxQueue)
-	close(srv.bounceQueue)
-	close(srv.relayQueue)
+	close(srv.stopc)
+
+	if srv.Abuse != nil && srv.Abuse.Greylist != nil {
+		err = srv.Abuse.Greylist.Flush()
+		if err != nil {
+			log.Printf(`smtp: %s`, err)
+		}
+	}
 }
 
 // serveIncoming serve incoming message from other mail transfer agent on port
@@ -168,7 +276,24 @@ func (srv *Server) serveSubmission() {
 
 // handle receiver connection.
 func (srv *Server) handle(recv *receiver) {
-	err := recv.sendReply(StatusReady, srv.Env.PrimaryDomain.Name, nil)
+	if !srv.acquireConn(recv) {
+		_ = recv.sendError(errTooManyConnections)
+		recv.close()
+		return
+	}
+	defer srv.releaseConn(recv)
+
+	err := srv.checkClient(recv)
+	if err == nil {
+		err = srv.runFilters(recv, FilterStageConnect)
+	}
+	if err != nil {
+		_ = recv.sendError(err)
+		recv.close()
+		return
+	}
+
+	err = recv.sendReply(StatusReady, srv.Env.PrimaryDomain.Name, nil)
 	if err != nil {
 		log.Println("receiver.sendReply: ", err.Error())
 		recv.close()
@@ -178,6 +303,9 @@ func (srv *Server) handle(recv *receiver) {
 	for srv.running {
 		cmd, err := recv.readCommand()
 		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
 			log.Println(err)
 			_ = recv.sendError(err)
 			break
@@ -185,6 +313,10 @@ func (srv *Server) handle(recv *receiver) {
 		if cmd == nil {
 			continue
 		}
+		if srv.Abuse != nil && !recv.allowCommand(srv.Abuse.MaxCommandsPerMinute) {
+			_ = recv.sendError(errTooManyCommands)
+			break
+		}
 
 		for _, ext := range srv.Exts {
 			err = ext.ValidateCommand(cmd)
@@ -205,16 +337,16 @@ func (srv *Server) handle(recv *receiver) {
 
 		switch recv.state {
 		case CommandDATA:
-			srv.processMailTx(recv.mail)
-			// TODO: add return error and check it.
-			//			if err != nil {
-			//				log.Println("server.processMailTx: ", err.Error())
-			//				err = recv.sendError(errInProcessing)
-			//				if err != nil {
-			//					goto out
-			//				}
-			//				continue
-			//			}
+			err = srv.processMailTx(recv.mail)
+			if err != nil {
+				log.Printf(`smtp: processMailTx: %s`, err)
+				err = recv.sendError(errInProcessing)
+				if err != nil {
+					goto out
+				}
+				recv.reset()
+				continue
+			}
 
 			err = recv.sendReply(StatusOK, "OK", nil)
 			if err != nil {
@@ -237,6 +369,9 @@ func (srv *Server) handleCommand(recv *receiver, cmd *Command) (err error) {
 	case CommandAUTH:
 		err = srv.handleAUTH(recv, cmd)
 
+	case CommandBDAT:
+		err = srv.handleBDAT(recv, cmd)
+
 	case CommandDATA:
 		err = srv.handleDATA(recv)
 
@@ -246,6 +381,11 @@ func (srv *Server) handleCommand(recv *receiver, cmd *Command) (err error) {
 	case CommandHELO:
 		recv.clientDomain = cmd.Arg
 
+		err = srv.runFilters(recv, FilterStageHelo)
+		if err != nil {
+			return recv.sendError(err)
+		}
+
 		err = recv.sendReply(StatusOK, srv.Env.PrimaryDomain.Name, nil)
 		if err != nil {
 			return err
@@ -297,6 +437,14 @@ func (srv *Server) handleCommand(recv *receiver, cmd *Command) (err error) {
 	return err
 }
 
+// enqueue push the mail into queue, unless the server has been stopped.
+func (srv *Server) enqueue(queue chan *MailTx, mail *MailTx) {
+	select {
+	case queue <- mail:
+	case <-srv.stopc:
+	}
+}
+
 // handleAUTH process the AUTH command from client.
 func (srv *Server) handleAUTH(recv *receiver, cmd *Command) (err error) {
 	if recv.mode == receiverModeServer {
@@ -312,70 +460,128 @@ func (srv *Server) handleAUTH(recv *receiver, cmd *Command) (err error) {
 		return recv.sendError(errBadSequence)
 	}
 
-	var username, password string
+	var mech = parseSaslMechanism(cmd.Arg)
+	if !srv.isAuthMechanism(mech) {
+		return recv.sendError(errAuthMechanism)
+	}
 
-	switch cmd.Arg {
-	case "PLAIN":
-		// AUTH PLAIN with two steps handshake.
-		if len(cmd.Param) == 0 {
-			err = recv.sendReply(StatusAuthReady, "", nil)
-			if err != nil {
-				return err
-			}
+	var (
+		sasl = newSaslServer(mech, srv.Credentials, srv.Env.PrimaryDomain.Name)
 
-			err = recv.readAuthData(cmd)
-			if err != nil {
-				return err
-			}
+		resp      []byte
+		challenge []byte
+		done      bool
+	)
 
-			if cmd.Param == "*" {
-				err = recv.sendReply(StatusCmdSyntaxError,
-					"Authentication cancelled", nil)
-				return err
+	// SASL initial-response, with "=" indicate empty response (RFC
+	// 4954 section 4).
+	if len(cmd.Param) > 0 {
+		resp, err = decodeSaslResponse([]byte(cmd.Param))
+		if err != nil {
+			return recv.sendError(errCmdSyntaxError)
+		}
+	}
+
+	for {
+		challenge, done, err = sasl.next(resp)
+		if err != nil {
+			var errAuth *liberrors.E
+			if !errors.As(err, &errAuth) {
+				log.Printf(`handleAUTH: %s`, err)
+				errAuth = ErrInvalidCredential
 			}
+			return recv.sendError(errAuth)
+		}
+		if done {
+			break
 		}
 
-		var param []byte
-		param, err = base64.StdEncoding.DecodeString(cmd.Param)
+		err = recv.sendReply(StatusAuthReady,
+			base64.StdEncoding.EncodeToString(challenge), nil)
 		if err != nil {
-			_ = recv.sendError(errCmdSyntaxError)
 			return err
 		}
 
-		var args = bytes.Split(param, []byte{'\x00'})
-		if len(args) != 3 {
-			return recv.sendError(errCmdSyntaxError)
+		resp, err = recv.readAuthData()
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(resp, []byte(`*`)) {
+			return recv.sendError(errAuthCancelled)
 		}
 
-		username = string(args[1])
-		password = string(args[2])
+		resp, err = decodeSaslResponse(resp)
+		if err != nil {
+			return recv.sendError(errCmdSyntaxError)
+		}
+	}
 
-	default:
-		return recv.sendError(errAuthMechanism)
+	err = recv.sendReply(StatusAuthenticated,
+		`2.7.0 Authentication successful`, nil)
+	if err != nil {
+		return err
 	}
 
-	res, err := srv.Handler.ServeAuth(username, password)
+	recv.authenticated = true
+	recv.username = sasl.username()
+	recv.state = CommandAUTH
+
+	return nil
+}
+
+// handleBDAT handle the BDAT command (RFC 3030).
+// The chunk data is always read from client, even if the command is
+// rejected.
+func (srv *Server) handleBDAT(recv *receiver, cmd *Command) (err error) {
+	var size int64
+
+	size, err = strconv.ParseInt(cmd.Arg, 10, 64)
 	if err != nil {
-		return recv.sendError(err)
+		return recv.sendError(errCmdSyntaxError)
 	}
 
-	err = recv.sendReply(res.Code, res.Message, res.Body)
+	var errReply *liberrors.E
+
+	switch {
+	case recv.mode == receiverModeClient && !recv.isAuthenticated():
+		errReply = errNotAuthenticated
+	case recv.state != CommandRCPT && recv.state != CommandBDAT:
+		errReply = errBadSequence
+	case srv.MaxMessageSize > 0 && recv.chunkSize+size > srv.MaxMessageSize:
+		errReply = errMessageTooBig
+	}
+
+	err = recv.readChunk(size, errReply != nil)
 	if err != nil {
 		return err
 	}
+	if errReply != nil {
+		// A failed BDAT command end the mail transaction (RFC 3030
+		// section 2), so the next "BDAT n LAST" does not deliver
+		// partial message.
+		err = recv.sendError(errReply)
+		recv.reset()
+		return err
+	}
 
-	recv.authenticated = true
-	recv.state = CommandAUTH
+	if cmd.Param != `LAST` {
+		recv.state = CommandBDAT
+		return recv.sendReply(StatusOK,
+			fmt.Sprintf(`2.0.0 %d octets received`, size), nil)
+	}
 
-	return nil
+	// Convert the message into wire format, the same as the one
+	// received from DATA command.
+	recv.mail.Data = bytes.TrimSuffix(format(recv.mail.Data), []byte("\r\n"))
+
+	recv.endData()
+
+	return srv.endMailData(recv)
 }
 
 func (srv *Server) handleDATA(recv *receiver) (err error) {
-	if !recv.isAuthenticated() {
-		err = recv.sendError(errNotAuthenticated)
-		if err != nil {
-			return err
-		}
+	if recv.mode == receiverModeClient && !recv.isAuthenticated() {
+		return recv.sendError(errNotAuthenticated)
 	}
 	if recv.state != CommandRCPT {
 		err = recv.sendReply(StatusCmdBadSequence,
@@ -392,8 +598,42 @@ func (srv *Server) handleDATA(recv *receiver) (err error) {
 		return err
 	}
 
-	err = recv.readDATA()
+	err = recv.readDATA(srv.MaxMessageSize)
+	if err != nil {
+		if errors.Is(err, errMessageTooBig) {
+			err = recv.sendError(errMessageTooBig)
+			recv.reset()
+			return err
+		}
+		return err
+	}
+
+	return srv.endMailData(recv)
+}
+
+// endMailData process the received mail data, by signing or checking the
+// message, and passing it to the Filters, before the mail is queued.
+func (srv *Server) endMailData(recv *receiver) (err error) {
+	if recv.mode == receiverModeClient {
+		err = srv.signMail(recv)
+		if err != nil {
+			log.Printf(`handleDATA: %s`, err)
+		}
+	}
+
+	if recv.mode == receiverModeServer && srv.Inbound != nil {
+		err = srv.checkMessage(recv)
+		if err != nil {
+			err = recv.sendError(err)
+			recv.reset()
+			return err
+		}
+	}
+
+	err = srv.runFilters(recv, FilterStageData)
 	if err != nil {
+		err = recv.sendError(err)
+		recv.reset()
 		return err
 	}
 
@@ -405,14 +645,25 @@ func (srv *Server) handleDATA(recv *receiver) (err error) {
 func (srv *Server) handleEHLO(recv *receiver, cmd *Command) (err error) {
 	recv.clientDomain = cmd.Arg
 
+	err = srv.runFilters(recv, FilterStageHelo)
+	if err != nil {
+		return recv.sendError(err)
+	}
+
 	body := make([]string, len(srv.Exts))
 	for x, ext := range srv.Exts {
 		body[x] = ext.Name()
-		body[x] += " " + ext.Params()
+		if params := ext.Params(); len(params) > 0 {
+			body[x] += " " + params
+		}
 	}
 
-	if !recv.isAuthenticated() {
-		body = append(body, "AUTH PLAIN")
+	if recv.mode == receiverModeClient && !recv.isAuthenticated() {
+		var auth = "AUTH"
+		for _, mech := range srv.AuthMechanisms {
+			auth += " " + mech.String()
+		}
+		body = append(body, auth)
 	}
 
 	err = recv.sendReply(StatusOK, srv.Env.PrimaryDomain.Name, body)
@@ -431,10 +682,7 @@ func (srv *Server) handleEXPN(recv *receiver, cmd *Command) (err error) {
 	}
 
 	if !recv.isAuthenticated() {
-		err = recv.sendError(errNotAuthenticated)
-		if err != nil {
-			return err
-		}
+		return recv.sendError(errNotAuthenticated)
 	}
 
 	res, err := srv.Handler.ServeExpand(cmd.Arg)
@@ -447,12 +695,28 @@ func (srv *Server) handleEXPN(recv *receiver, cmd *Command) (err error) {
 	return err
 }
 
+// handleMAIL handle the MAIL command.
+// On port 25, the MAIL command is accepted without authentication.
 func (srv *Server) handleMAIL(recv *receiver, cmd *Command) (err error) {
-	if !recv.isAuthenticated() {
+	if recv.mode == receiverModeClient && !recv.isAuthenticated() {
 		return recv.sendError(errNotAuthenticated)
 	}
 
 	recv.mail.From = cmd.Arg
+	_, recv.isSMTPUTF8 = cmd.param(`SMTPUTF8`)
+
+	if recv.mode == receiverModeServer && srv.Inbound != nil && srv.Inbound.isCheckSPF() {
+		err = srv.checkSPF(recv)
+		if err != nil {
+			return recv.sendError(err)
+		}
+	}
+
+	err = srv.runFilters(recv, FilterStageMail)
+	if err != nil {
+		recv.mail.From = ``
+		return recv.sendError(err)
+	}
 
 	err = recv.sendReply(StatusOK, "OK", nil)
 	if err != nil {
@@ -468,23 +732,48 @@ func (srv *Server) handleHELP(recv *receiver) (err error) {
 	return recv.sendReply(StatusHelp, "Everything will be alright", nil)
 }
 
+// handleRCPT handle the RCPT command.
+// On port 25, only recipient with our domain is accepted.
 func (srv *Server) handleRCPT(recv *receiver, cmd *Command) (err error) {
-	if !recv.isAuthenticated() {
-		err = recv.sendError(errNotAuthenticated)
-		if err != nil {
-			return err
-		}
+	if recv.mode == receiverModeClient && !recv.isAuthenticated() {
+		return recv.sendError(errNotAuthenticated)
+	}
+	if recv.state != CommandMAIL && recv.state != CommandRCPT {
+		return recv.sendError(errBadSequence)
+	}
+	if recv.mode == receiverModeServer && !srv.isOurRecipient(cmd.Arg) {
+		// Reject the recipient without resetting the mail
+		// transaction.
+		return recv.sendReply(errRelayDenied.Code, errRelayDenied.Message, nil)
 	}
 
-	recv.mail.Recipients = append(recv.mail.Recipients, cmd.Arg)
+	if !recv.isSMTPUTF8 && !isASCII(cmd.Arg) {
+		return recv.sendReply(errUTF8Required.Code, errUTF8Required.Message, nil)
+	}
 
 	// RFC 5321, 4.5.3.1.8.  Recipients Buffer
-	if len(recv.mail.Recipients) > 100 {
-		err = recv.sendReply(StatusNoStorage,
-			"Too many recipients", nil)
-	} else {
-		err = recv.sendReply(StatusOK, "OK", nil)
+	if len(recv.mail.Recipients) >= srv.Abuse.maxRecipients() {
+		return recv.sendReply(errTooManyRecipients.Code, errTooManyRecipients.Message, nil)
+	}
+
+	err = srv.checkGreylist(recv, cmd.Arg)
+	if err != nil {
+		return recv.sendReply(errGreylisted.Code, errGreylisted.Message, nil)
+	}
+
+	recv.ftx.Rcpt = cmd.Arg
+	err = srv.runFilters(recv, FilterStageRcpt)
+	recv.ftx.Rcpt = ``
+	if err != nil {
+		// Reject the recipient without resetting the mail
+		// transaction.
+		var errReply = filterReply(err)
+		return recv.sendReply(errReply.Code, errReply.Message, nil)
 	}
+
+	recv.mail.Recipients = append(recv.mail.Recipients, cmd.Arg)
+
+	err = recv.sendReply(StatusOK, "OK", nil)
 	if err != nil {
 		return err
 	}
@@ -500,10 +789,7 @@ func (srv *Server) handleVRFY(recv *receiver, cmd *Command) (err error) {
 	}
 
 	if !recv.isAuthenticated() {
-		err = recv.sendError(errNotAuthenticated)
-		if err != nil {
-			return err
-		}
+		return recv.sendError(errNotAuthenticated)
 	}
 
 	res, err := srv.Handler.ServeVerify(cmd.Arg)
@@ -528,13 +814,42 @@ func (srv *Server) initialize() (err error) {
 	if srv.Handler == nil {
 		srv.Handler = NewLocalHandler(srv.Env)
 	}
-
-	if srv.Exts == nil {
-		srv.Exts = defaultExts
-	} else {
-		srv.Exts = append(srv.Exts, defaultExts...)
+	if srv.Credentials == nil {
+		if srv.isAuthMechanism(SaslMechanismCramMD5) {
+			return fmt.Errorf(`smtp: AuthMechanisms %s require Credentials`, SaslMechanismCramMD5)
+		}
+		srv.Credentials = &localCredential{
+			handler: srv.Handler,
+			env:     srv.Env,
+		}
+	}
+	if len(srv.AuthMechanisms) == 0 {
+		srv.AuthMechanisms = defAuthMechanisms
+	}
+	if srv.MaxMessageSize <= 0 {
+		srv.MaxMessageSize = defMaxMessageSize
+	}
+	if srv.Resolver == nil {
+		srv.Resolver, err = newDNSResolver(nil)
+		if err != nil {
+			return fmt.Errorf(`smtp: %w`, err)
+		}
+	}
+	if srv.Inbound != nil {
+		srv.dkimKeys = dkim.NewKeyPool(srv.lookupDKIMKey)
+	}
+	if srv.RelayRetryInterval <= 0 {
+		srv.RelayRetryInterval = defRelayRetryInterval
+	}
+	if srv.RelayExpiry <= 0 {
+		srv.RelayExpiry = defRelayExpiry
+	}
+	if srv.relayPort == 0 {
+		srv.relayPort = defRelayPort
 	}
 
+	srv.Exts = append(srv.Exts, srv.defaultExts()...)
+
 	err = srv.initListener()
 	if err != nil {
 		return err
@@ -543,6 +858,7 @@ func (srv *Server) initialize() (err error) {
 	srv.mailTxQueue = make(chan *MailTx, 512)
 	srv.bounceQueue = make(chan *MailTx, 512)
 	srv.relayQueue = make(chan *MailTx, 512)
+	srv.stopc = make(chan struct{})
 
 	return nil
 }
@@ -582,6 +898,17 @@ func (srv *Server) initListener() (err error) {
 	return err
 }
 
+// isAuthMechanism return true if the SASL mechanism is accepted by
+// server.
+func (srv *Server) isAuthMechanism(mech SaslMechanism) bool {
+	for _, m := range srv.AuthMechanisms {
+		if m == mech {
+			return true
+		}
+	}
+	return false
+}
+
 func (srv *Server) isOurDomain(d string) bool {
 	if d == srv.Env.PrimaryDomain.Name {
 		return true
@@ -594,6 +921,16 @@ func (srv *Server) isOurDomain(d string) bool {
 	return false
 }
 
+// isOurRecipient return true if the domain of recipient address is managed
+// by server, or the recipient is local postmaster.
+func (srv *Server) isOurRecipient(rcpt string) bool {
+	var at = strings.LastIndexByte(rcpt, '@')
+	if at < 0 {
+		return strings.EqualFold(rcpt, localPostmaster)
+	}
+	return srv.isOurDomain(strings.ToLower(rcpt[at+1:]))
+}
+
 // processMailTxQueue process incoming mail transactions.
 //
 // There are three possibilities for incoming mail:
@@ -607,9 +944,14 @@ func (srv *Server) isOurDomain(d string) bool {
 // (3) when recipient address is unknown or invalid, the mail transaction will
 // be bounced back to sender.
 func (srv *Server) processMailTxQueue() {
-	for mail, ok := <-srv.mailTxQueue; ok; {
-		if mail.isPostponed() {
-			continue
+	defer srv.wg.Done()
+
+	var mail *MailTx
+	for {
+		select {
+		case mail = <-srv.mailTxQueue:
+		case <-srv.stopc:
+			return
 		}
 
 		// At this point, only one recipient exist in mail object.
@@ -623,73 +965,310 @@ func (srv *Server) processMailTxQueue() {
 			if srv.isOurDomain(addr[1]) {
 				// This is the first case.
 				_, err = srv.Handler.ServeMailTx(mail)
+				if err == nil {
+					srv.deleteMail(mail)
+				}
 			} else {
 				// This is the second case.
-				srv.relayQueue <- mail
+				// The mail has been stored with queued state.
+				srv.enqueue(srv.relayQueue, mail)
 			}
 		case 1:
 			// The first case, where recipient domain is assumed
 			// to be primary domain.
 			if addr[0] == localPostmaster {
 				_, err = srv.Handler.ServeMailTx(mail)
+				if err == nil {
+					srv.deleteMail(mail)
+				}
 			} else {
-				srv.bounceQueue <- mail
+				mail.LastError = `550 5.1.1 Bad destination mailbox address`
+				srv.enqueue(srv.bounceQueue, mail)
 			}
 		default:
-			srv.bounceQueue <- mail
+			mail.LastError = `553 5.1.3 Bad destination mailbox address syntax`
+			srv.enqueue(srv.bounceQueue, mail)
 		}
 
 		if err != nil {
-			if mail.Retry < 5 {
-				mail.postpone()
-			} else {
-				srv.bounceQueue <- mail
-			}
+			mail.LastError = err.Error()
+			srv.enqueue(srv.bounceQueue, mail)
 		}
 	}
-	srv.wg.Done()
 }
 
 // processBounceQueue send the mail back to reverse-path (sender).
 //
-// If sender domain is one of ours, call the handler; otherwise send them
-// using SMTP through relay queue.
+// Each bounced mail is passed to handler ServeBounce and then a delivery
+// status notification (DSN) is generated and sent to the sender.
+// If sender domain is one of ours, the DSN is passed to the handler
+// ServeMailTx; otherwise it will be send through relay queue.
+//
+// Mail with null reverse-path, which is a DSN by itself, is not
+// bounced back to avoid loop.
 func (srv *Server) processBounceQueue() {
-	var err error
+	defer srv.wg.Done()
+
+	var (
+		mail *MailTx
+		err  error
+	)
+	for {
+		select {
+		case mail = <-srv.bounceQueue:
+		case <-srv.stopc:
+			return
+		}
 
-	for mail, ok := <-srv.bounceQueue; ok; {
 		_, err = srv.Handler.ServeBounce(mail)
 		if err != nil {
-			continue
+			log.Printf(`smtp: ServeBounce %s: %s`, mail.ID, err)
+		}
+
+		// Store the DSN before moving the mail to bounce, so the
+		// DSN is not lost if the server stopped in between.
+		var dsn *MailTx
+		if len(mail.From) != 0 {
+			dsn = srv.newDSN(mail)
+			dsn.State = MailStateQueued
+			srv.saveMail(dsn)
+		}
+
+		if srv.Storage != nil && len(mail.State) != 0 {
+			// Only the mail that has been stored in spool
+			// moved to bounce.
+			mail.State = MailStateBounced
+			srv.saveMail(mail)
+			err = srv.Storage.MailBounce(mail.ID)
+			if err != nil {
+				log.Printf(`smtp: MailBounce %s: %s`, mail.ID, err)
+			}
+		}
+
+		if dsn != nil {
+			srv.enqueue(srv.mailTxQueue, dsn)
 		}
 	}
-	srv.wg.Done()
 }
 
 // processRelayQueue send mail to other MTA or final destination.
 // A mail transaction will be relayed on the following conditions: the
 // domain's name in MAIL FROM is managed by server and the recipient domain's
 // address is not managed by server.
+//
+// If the relay is failed temporarily, the mail is pushed to the retry
+// queue, until its expired.
+// If the relay is failed permanently or expired, the mail is pushed to
+// the bounce queue.
 func (srv *Server) processRelayQueue() {
-	var mailtx *MailTx
-	for mailtx = range srv.relayQueue {
-		// TODO: implement it.
-		if mailtx == nil {
-			break
+	defer srv.wg.Done()
+
+	var (
+		mail *MailTx
+		err  error
+	)
+	for {
+		select {
+		case mail = <-srv.relayQueue:
+		case <-srv.stopc:
+			return
+		}
+
+		err = srv.relay(mail)
+		if err == nil {
+			srv.deleteMail(mail)
+			continue
+		}
+
+		if isPermanentError(err) {
+			mail.LastError = err.Error()
+			srv.enqueue(srv.bounceQueue, mail)
+			continue
+		}
+
+		mail.postpone(srv.RelayRetryInterval, err)
+		if mail.isExpired(srv.RelayExpiry) {
+			srv.enqueue(srv.bounceQueue, mail)
+			continue
+		}
+
+		mail.State = MailStateDeferred
+		srv.saveMail(mail)
+
+		srv.retryMtx.Lock()
+		srv.retryQueue = append(srv.retryQueue, mail)
+		srv.retryMtx.Unlock()
+	}
+}
+
+// processRetryQueue periodically check the retry queue and push back the
+// mail that is no longer postponed to the relay queue.
+func (srv *Server) processRetryQueue() {
+	defer srv.wg.Done()
+
+	var tick = time.Minute
+	if srv.RelayRetryInterval < tick {
+		tick = srv.RelayRetryInterval
+	}
+
+	var ticker = time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-srv.stopc:
+			return
 		}
+
+		var ready []*MailTx
+
+		srv.retryMtx.Lock()
+		var x int
+		for _, mail := range srv.retryQueue {
+			if mail.isPostponed() {
+				srv.retryQueue[x] = mail
+				x++
+				continue
+			}
+			ready = append(ready, mail)
+		}
+		srv.retryQueue = srv.retryQueue[:x]
+		srv.retryMtx.Unlock()
+
+		for _, mail := range ready {
+			srv.enqueue(srv.relayQueue, mail)
+		}
+	}
+}
+
+// deleteMail remove the mail from Storage, if its set.
+func (srv *Server) deleteMail(mail *MailTx) {
+	if srv.Storage == nil {
+		return
+	}
+	var err = srv.Storage.MailDelete(mail.ID)
+	if err != nil {
+		log.Printf(`smtp: MailDelete %s: %s`, mail.ID, err)
+	}
+}
+
+// saveMail store the mail into Storage, if its set.
+func (srv *Server) saveMail(mail *MailTx) {
+	if srv.Storage == nil {
+		return
+	}
+	var err = srv.Storage.MailSave(mail)
+	if err != nil {
+		log.Printf(`smtp: MailSave %s: %s`, mail.ID, err)
 	}
-	srv.wg.Done()
 }
 
 // processMailTx process mail transaction by breaking down recipients into one
-// mail object, storing it into storage, and push it to the queue for further
-// processing.
-func (srv *Server) processMailTx(mail *MailTx) {
-	mails := make([]*MailTx, len(mail.Recipients))
-	for x, rcpt := range mail.Recipients {
-		mails[x] = NewMailTx(mail.From, []string{rcpt}, mail.Data)
+// mail object and push it to the queue for further processing.
+// Each recipient is expanded into final recipients using the aliases,
+// mailing lists, and catch-alls in Environment.
+//
+// If the Storage is set, each mail is stored with queued state before
+// being pushed to the queue, so the accepted mail is not lost when the
+// server stopped.
+// If one of the mail cannot be stored, all of them are removed and it
+// will return an error, so the client can retry later.
+func (srv *Server) processMailTx(mail *MailTx) (err error) {
+	var (
+		list      = srv.Env.expand(mail.From, mail.Recipients)
+		rcptMails = make([]*MailTx, 0, len(list))
+	)
+	for x, dlv := range list {
+		var rcptMail = &MailTx{
+			ID:         fmt.Sprintf(`%s.%d`, mail.ID, x),
+			Received:   mail.Received,
+			From:       dlv.from,
+			Recipients: []string{dlv.rcpt},
+			Data:       mail.Data,
+			Quarantine: mail.Quarantine,
+			State:      MailStateQueued,
+		}
+		if srv.Storage != nil {
+			err = srv.Storage.MailSave(rcptMail)
+			if err != nil {
+				for _, saved := range rcptMails {
+					srv.deleteMail(saved)
+				}
+				return fmt.Errorf(`%s: %w`, rcptMail.ID, err)
+			}
+		}
+		rcptMails = append(rcptMails, rcptMail)
+	}
+	for _, rcptMail := range rcptMails {
+		srv.enqueue(srv.mailTxQueue, rcptMail)
+	}
+	return nil
+}
+
+// isRelayRecipient return true if the domain of recipient address is not
+// managed by server.
+func (srv *Server) isRelayRecipient(rcpt string) bool {
+	var addr = strings.Split(rcpt, "@")
+	return len(addr) == 2 && !srv.isOurDomain(addr[1])
+}
+
+// signMail sign the mail from authenticated account using DKIM, if the
+// domain of account has DKIMOptions.
+func (srv *Server) signMail(recv *receiver) (err error) {
+	var (
+		logp = `signMail`
+		at   = strings.LastIndexByte(recv.username, '@')
+	)
+	if at < 0 {
+		return nil
 	}
-	for _, mail := range mails {
-		srv.mailTxQueue <- mail
+
+	var domain = srv.Env.lookupDomain(recv.username[at+1:])
+	if domain == nil || domain.dkimOpts == nil {
+		return nil
+	}
+
+	var (
+		opts = domain.dkimOpts
+		sig  = opts.newSignature()
+		msg  *email.Message
+	)
+
+	msg, _, err = email.ParseMessage(unstuff(recv.mail.Data))
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if msg == nil {
+		return nil
+	}
+
+	err = msg.DKIMSign(opts.PrivateKey, sig)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var field = []byte(`DKIM-Signature:`)
+
+	field = append(field, sig.Pack(true)...)
+	recv.mail.Data = append(field, recv.mail.Data...)
+
+	return nil
+}
+
+// decodeSaslResponse decode the base64 SASL response from client.
+// The response "=" is an empty response.
+func decodeSaslResponse(in []byte) (out []byte, err error) {
+	if bytes.Equal(in, []byte(`=`)) {
+		return []byte{}, nil
+	}
+	out = make([]byte, base64.StdEncoding.DecodedLen(len(in)))
+
+	var n int
+
+	n, err = base64.StdEncoding.Decode(out, in)
+	if err != nil {
+		return nil, err
 	}
+	return out[:n], nil
 }
@@ -205,6 +205,11 @@ func parseLocalDomain(data []byte, allow []byte) (out []byte) {
 			out = append(out, data[x])
 			continue
 		}
+		if data[x] >= 0x80 {
+			// UTF-8 characters in mailbox (RFC 6531).
+			out = append(out, data[x])
+			continue
+		}
 		found = false
 		for _, c := range allow {
 			if c == data[x] {
@@ -8,6 +8,7 @@ import (
 	"crypto"
 	"crypto/rsa"
 	"log"
+	"net"
 	"os"
 	"testing"
 	"time"
@@ -23,6 +24,10 @@ const (
 	testDomain          = "mail.kilabit.local"
 	testPassword        = "secret"
 	testTLSAddress      = "127.0.0.1:2533"
+	testRemoteAddress   = "127.0.0.1:2526"
+	testRemoteTLS       = "127.0.0.1:2534"
+	testRemoteDomain    = "remote.local"
+	testRemotePort      = 2526
 	testSMTPSAddress    = "smtps://" + testTLSAddress
 	testFileCertificate = "testdata/" + testDomain + ".cert.pem"
 	testFilePrivateKey  = "testdata/" + testDomain + ".key.pem"
@@ -30,10 +35,98 @@ const (
 
 var (
 	testServer        *Server
+	testHandler       *mockHandler
 	testAccountFirst  *Account
 	testAccountSecond *Account
+
+	testResolver = &mockResolver{
+		mx: map[string][]string{
+			testRemoteDomain: {"mx." + testRemoteDomain},
+			"down.local":     {"mx.down.local"},
+			"reject.local":   {"mx." + testRemoteDomain},
+		},
+		ip: map[string][]net.IP{
+			"mx." + testRemoteDomain: {net.ParseIP("127.0.0.1")},
+			"mx.down.local":          {net.ParseIP("127.0.0.2")},
+		},
+	}
 )
 
+// mockHandler implement the Handler that capture the delivered mail.
+type mockHandler struct {
+	*LocalHandler
+	mailc chan *MailTx
+}
+
+func newMockHandler(env *Environment) (mh *mockHandler) {
+	return &mockHandler{
+		LocalHandler: NewLocalHandler(env),
+		mailc:        make(chan *MailTx, 16),
+	}
+}
+
+// ServeMailTx push the mail to channel mailc.
+func (mh *mockHandler) ServeMailTx(mail *MailTx) (res *Response, err error) {
+	select {
+	case mh.mailc <- mail:
+	default:
+	}
+	return nil, nil
+}
+
+// waitMail wait for delivered mail or return nil after timeout.
+func (mh *mockHandler) waitMail(timeout time.Duration) (mail *MailTx) {
+	select {
+	case mail = <-mh.mailc:
+	case <-time.After(timeout):
+	}
+	return mail
+}
+
+// mockResolver implement the Resolver using static records.
+type mockResolver struct {
+	mx  map[string][]string
+	ip  map[string][]net.IP
+	txt map[string][]string
+	ptr map[string][]string
+}
+
+func (mr *mockResolver) LookupMX(domain string) (hosts []string, err error) {
+	var ok bool
+	hosts, ok = mr.mx[domain]
+	if !ok {
+		return nil, errDomainNotFound
+	}
+	return hosts, nil
+}
+
+func (mr *mockResolver) LookupIP(host string) (ips []net.IP, err error) {
+	var ok bool
+	ips, ok = mr.ip[host]
+	if !ok {
+		return nil, errDomainNotFound
+	}
+	return ips, nil
+}
+
+func (mr *mockResolver) LookupTXT(domain string) (txts []string, err error) {
+	var ok bool
+	txts, ok = mr.txt[domain]
+	if !ok {
+		return nil, errDomainNotFound
+	}
+	return txts, nil
+}
+
+func (mr *mockResolver) LookupAddr(ip net.IP) (names []string, err error) {
+	var ok bool
+	names, ok = mr.ptr[ip.String()]
+	if !ok {
+		return nil, errDomainNotFound
+	}
+	return names, nil
+}
+
 func testRunServer() {
 	var err error
 
@@ -73,13 +166,29 @@ func testRunServer() {
 
 	env := &Environment{
 		PrimaryDomain: primaryDomain,
+		Lists: map[string]*MailingList{
+			"team@" + testDomain: {
+				Address: "team@" + testDomain,
+				Members: []string{
+					testAccountFirst.Short(),
+					testAccountSecond.Short(),
+				},
+			},
+		},
 	}
 
+	testHandler = newMockHandler(env)
+
 	testServer = &Server{
 		address:    testAddress,
 		tlsAddress: testTLSAddress,
+		relayPort:  testRemotePort,
 		Env:        env,
-		Handler:    NewLocalHandler(env),
+		Handler:    testHandler,
+		Resolver:   testResolver,
+
+		RelayRetryInterval: 100 * time.Millisecond,
+		RelayExpiry:        time.Second,
 	}
 
 	err = testServer.LoadCertificate(
@@ -0,0 +1,194 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	// defSRSMaxAge is the default maximum age of SRS address, in
+	// days.
+	defSRSMaxAge = 21
+
+	srsPrefix0 = `SRS0=`
+	srsPrefix1 = `SRS1=`
+
+	// srsHashLength is the number of characters of hash in SRS
+	// address.
+	srsHashLength = 4
+
+	srsTimeAlphabet = `ABCDEFGHIJKLMNOPQRSTUVWXYZ234567`
+
+	// srsTimePrecision is the number of seconds in a day.
+	srsTimePrecision = 24 * 60 * 60
+
+	// srsTimeSlots is the number of unique timestamps, 32*32.
+	srsTimeSlots = 1024
+)
+
+// ErrSRSInvalid define an error when reversing invalid, forged, or
+// expired SRS address.
+var ErrSRSInvalid = errors.New(`invalid SRS address`)
+
+// SRS implement the Sender Rewriting Scheme, to rewrite the envelope
+// sender of forwarded mail so the SPF check pass on the next hop.
+//
+// The sender "user@example.org" is rewritten into
+// "SRS0=HHHH=TT=example.org=user@{Domain}", where HHHH is the hash of
+// address and TT is the timestamp.
+// The bounce to rewritten address can be reversed back to original
+// sender only if the hash is valid and the timestamp is not expired.
+type SRS struct {
+	// Domain is our domain where the rewritten address is located.
+	Domain string
+
+	// Secret is the key to generate the hash.
+	Secret []byte
+
+	// MaxAge is the maximum age of rewritten address, in days.
+	// This field is optional, default to 21 days.
+	MaxAge int
+}
+
+// Forward rewrite the sender address.
+// The address in our Domain or the empty address is returned as is.
+// The address that has been rewritten by other forwarder, SRS0, is
+// rewritten into SRS1 address.
+func (srs *SRS) Forward(sender string) string {
+	var at = strings.LastIndexByte(sender, '@')
+	if at < 0 {
+		return sender
+	}
+
+	var (
+		local  = sender[:at]
+		domain = sender[at+1:]
+	)
+	if strings.EqualFold(domain, srs.Domain) {
+		return sender
+	}
+
+	if hasPrefixFold(local, srsPrefix0) {
+		// SRS1=HHHH=domain==HHHH=TT=orig-domain=orig-local
+		var rest = local[len(srsPrefix0)-1:]
+		return srsPrefix1 + srs.hash(domain+rest) + `=` + domain + `=` + rest + `@` + srs.Domain
+	}
+	if hasPrefixFold(local, srsPrefix1) {
+		var (
+			_, rest, _     = strings.Cut(local[len(srsPrefix1):], `=`)
+			first, srs0, _ = strings.Cut(rest, `==`)
+		)
+		srs0 = `=` + srs0
+		return srsPrefix1 + srs.hash(first+srs0) + `=` + first + `=` + srs0 + `@` + srs.Domain
+	}
+
+	var tt = srsTimestamp(time.Now())
+	return srsPrefix0 + srs.hash(tt+domain+local) + `=` + tt + `=` + domain + `=` + local + `@` + srs.Domain
+}
+
+// Reverse the rewritten address back into original sender.
+// The SRS1 address is reversed into SRS0 address of the first forwarder.
+// It will return [ErrSRSInvalid] if the address is not SRS address, the
+// hash does not match, or the timestamp is expired.
+func (srs *SRS) Reverse(addr string) (sender string, err error) {
+	var at = strings.LastIndexByte(addr, '@')
+	if at < 0 {
+		return ``, ErrSRSInvalid
+	}
+	var local = addr[:at]
+
+	if hasPrefixFold(local, srsPrefix1) {
+		var (
+			hash, rest, _  = strings.Cut(local[len(srsPrefix1):], `=`)
+			first, srs0, _ = strings.Cut(rest, `==`)
+		)
+		if len(first) == 0 || len(srs0) == 0 {
+			return ``, ErrSRSInvalid
+		}
+		if !strings.EqualFold(hash, srs.hash(first+`=`+srs0)) {
+			return ``, ErrSRSInvalid
+		}
+		return srsPrefix0 + srs0 + `@` + first, nil
+	}
+
+	if !hasPrefixFold(local, srsPrefix0) {
+		return ``, ErrSRSInvalid
+	}
+
+	var fields = strings.SplitN(local[len(srsPrefix0):], `=`, 4)
+	if len(fields) != 4 {
+		return ``, ErrSRSInvalid
+	}
+
+	var (
+		hash   = fields[0]
+		tt     = fields[1]
+		domain = fields[2]
+		user   = fields[3]
+	)
+	if !strings.EqualFold(hash, srs.hash(tt+domain+user)) {
+		return ``, ErrSRSInvalid
+	}
+	if !srs.isValidTimestamp(tt, time.Now()) {
+		return ``, ErrSRSInvalid
+	}
+	return user + `@` + domain, nil
+}
+
+// isSRS return true if the local part of address is SRS address.
+func (srs *SRS) isSRS(local string) bool {
+	return hasPrefixFold(local, srsPrefix0) || hasPrefixFold(local, srsPrefix1)
+}
+
+// hash generate the HMAC-SHA1 of lowercase data, encoded in base64 and
+// truncated into four characters.
+func (srs *SRS) hash(data string) string {
+	var mac = hmac.New(sha1.New, srs.Secret)
+	mac.Write([]byte(strings.ToLower(data)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))[:srsHashLength]
+}
+
+func (srs *SRS) isValidTimestamp(tt string, now time.Time) bool {
+	if len(tt) != 2 {
+		return false
+	}
+	tt = strings.ToUpper(tt)
+
+	var (
+		hi = strings.IndexByte(srsTimeAlphabet, tt[0])
+		lo = strings.IndexByte(srsTimeAlphabet, tt[1])
+	)
+	if hi < 0 || lo < 0 {
+		return false
+	}
+
+	var (
+		then   = int64(hi<<5 | lo)
+		today  = (now.Unix() / srsTimePrecision) % srsTimeSlots
+		age    = (today - then + srsTimeSlots) % srsTimeSlots
+		maxAge = srs.MaxAge
+	)
+	if maxAge <= 0 {
+		maxAge = defSRSMaxAge
+	}
+	return age <= int64(maxAge)
+}
+
+// srsTimestamp return the number of days since epoch modulo 1024, encoded
+// using base32 in two characters.
+func srsTimestamp(now time.Time) string {
+	var day = (now.Unix() / srsTimePrecision) % srsTimeSlots
+	return string([]byte{srsTimeAlphabet[day>>5], srsTimeAlphabet[day&31]})
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,150 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smtp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestSRS_Forward(t *testing.T) {
+	var (
+		srs = &SRS{
+			Domain: `forward.local`,
+			Secret: []byte(`secret`),
+		}
+		other = &SRS{
+			Domain: `other.local`,
+			Secret: []byte(`other`),
+		}
+
+		srs0 = srs.Forward(`user@example.org`)
+		tt   = srsTimestamp(time.Now())
+	)
+
+	test.Assert(t, `Forward: SRS0`, `SRS0=`+srs.hash(tt+`example.orguser`)+`=`+tt+`=example.org=user@forward.local`, srs0)
+	test.Assert(t, `Forward: our domain`, `user@forward.local`, srs.Forward(`user@forward.local`))
+	test.Assert(t, `Forward: no domain`, `user`, srs.Forward(`user`))
+
+	var srs1 = other.Forward(srs0)
+	test.Assert(t, `Forward: SRS1 prefix`, true, strings.HasPrefix(srs1, `SRS1=`))
+	test.Assert(t, `Forward: SRS1 suffix`, true, strings.HasSuffix(srs1, `=forward.local==`+srs0[len(`SRS0=`):strings.IndexByte(srs0, '@')]+`@other.local`))
+
+	// The SRS1 forwarded by another forwarder keep the first forwarder.
+	var third = &SRS{Domain: `third.local`, Secret: []byte(`third`)}
+	var srs1b = third.Forward(srs1)
+	test.Assert(t, `Forward: SRS1 again`, true, strings.HasSuffix(srs1b, `=forward.local==`+srs0[len(`SRS0=`):strings.IndexByte(srs0, '@')]+`@third.local`))
+
+	var (
+		got string
+		err error
+	)
+
+	got, err = third.Reverse(srs1b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Reverse: SRS1`, srs0, got)
+
+	got, err = srs.Reverse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Reverse: SRS0`, `user@example.org`, got)
+}
+
+func TestSRS_Reverse(t *testing.T) {
+	type testCase struct {
+		expErr error
+		desc   string
+		addr   string
+		exp    string
+	}
+
+	var (
+		srs = &SRS{
+			Domain: `forward.local`,
+			Secret: []byte(`secret`),
+		}
+		srs0 = srs.Forward(`user@example.org`)
+	)
+
+	var cases = []testCase{{
+		desc: `With valid address`,
+		addr: srs0,
+		exp:  `user@example.org`,
+	}, {
+		desc: `With uppercase address`,
+		addr: strings.ToUpper(srs0),
+		exp:  `USER@EXAMPLE.ORG`,
+	}, {
+		desc:   `With invalid hash`,
+		addr:   `SRS0=AAAA=` + srs0[10:],
+		expErr: ErrSRSInvalid,
+	}, {
+		desc:   `With non SRS address`,
+		addr:   `user@forward.local`,
+		expErr: ErrSRSInvalid,
+	}, {
+		desc:   `With missing fields`,
+		addr:   `SRS0=AAAA=TT@forward.local`,
+		expErr: ErrSRSInvalid,
+	}, {
+		desc:   `With invalid SRS1`,
+		addr:   `SRS1=AAAA=forward.local@other.local`,
+		expErr: ErrSRSInvalid,
+	}}
+
+	var (
+		c   testCase
+		got string
+		err error
+	)
+	for _, c = range cases {
+		got, err = srs.Reverse(c.addr)
+		test.Assert(t, c.desc+`: error`, c.expErr, err)
+		test.Assert(t, c.desc, c.exp, got)
+	}
+}
+
+func TestSRS_isValidTimestamp(t *testing.T) {
+	type testCase struct {
+		desc string
+		now  time.Time
+		exp  bool
+	}
+
+	var (
+		srs  = &SRS{MaxAge: 7}
+		then = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		tt   = srsTimestamp(then)
+		day  = 24 * time.Hour
+	)
+
+	var cases = []testCase{{
+		desc: `With the same day`,
+		now:  then,
+		exp:  true,
+	}, {
+		desc: `With maximum age`,
+		now:  then.Add(7 * day),
+		exp:  true,
+	}, {
+		desc: `With expired`,
+		now:  then.Add(8 * day),
+	}, {
+		desc: `With timestamp in the future`,
+		now:  then.Add(-1 * day),
+	}}
+
+	for _, c := range cases {
+		test.Assert(t, c.desc, c.exp, srs.isValidTimestamp(tt, c.now))
+	}
+
+	test.Assert(t, `With invalid timestamp`, false, srs.isValidTimestamp(`a1`, then))
+}
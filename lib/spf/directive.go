@@ -4,6 +4,12 @@
 
 package spf
 
+import (
+	"bytes"
+	"errors"
+	"net"
+)
+
 // List of known qualifier for directive.
 const (
 	qualifierPass     byte = '+'
@@ -21,11 +27,147 @@ const (
 	mechanismPtr     = "ptr"
 	mechanismIP4     = "ip4"
 	mechanismIP6     = "ip6"
-	mechanismExist   = "exist"
+	mechanismExist   = "exists"
 )
 
 type directive struct {
+	// ipnet contains the network for mechanism "ip4" and "ip6".
+	ipnet *net.IPNet
+
 	mech  string
 	value []byte
-	qual  byte
+
+	// cidr4 and cidr6 contains the prefix length for mechanism "a"
+	// and "mx".
+	cidr4 int
+	cidr6 int
+
+	qual byte
+}
+
+// containsIP return true if the IP address of target is in the same
+// network with ip, masked using CIDR length in directive.
+func (dir *directive) containsIP(ip, target net.IP) bool {
+	var ip4, target4 = ip.To4(), target.To4()
+	if ip4 != nil && target4 != nil {
+		var mask = net.CIDRMask(dir.cidr4, 32)
+		return ip4.Mask(mask).Equal(target4.Mask(mask))
+	}
+	if ip4 == nil && target4 == nil {
+		var mask = net.CIDRMask(dir.cidr6, 128)
+		return ip.Mask(mask).Equal(target.Mask(mask))
+	}
+	return false
+}
+
+// parseDomainCIDR parse the optional domain-spec and dual-cidr-length for
+// mechanism "a", "mx", and "ptr".
+//
+//	[ ":" domain-spec ] [ dual-cidr-length ]
+//	dual-cidr-length = [ ip4-cidr-length ] [ "/" ip6-cidr-length ]
+//	ip4-cidr-length  = "/" ("0" / %x31-39 0*1DIGIT) ; value range 0-32
+//	ip6-cidr-length  = "/" ("0" / %x31-39 0*2DIGIT) ; value range 0-128
+func (dir *directive) parseDomainCIDR(result *Result, value []byte) (err error) {
+	dir.cidr4 = 32
+	dir.cidr6 = 128
+	dir.value = result.Domain
+
+	var cidr []byte
+
+	x := indexCIDR(value)
+	if x >= 0 {
+		cidr = value[x:]
+		value = value[:x]
+	}
+
+	if len(value) > 0 {
+		if value[0] != ':' || len(value) == 1 {
+			return errors.New("invalid domain-spec")
+		}
+		dir.value, err = macroExpand(result, dir.mech, value[1:])
+		if err != nil {
+			return err
+		}
+	}
+	if len(cidr) == 0 {
+		return nil
+	}
+	if dir.mech == mechanismPtr {
+		return errors.New("CIDR is not allowed")
+	}
+
+	// Remove the first "/".
+	cidr = cidr[1:]
+
+	var v4, v6 []byte
+
+	x = bytes.Index(cidr, []byte{'/'})
+	if x < 0 {
+		v4 = cidr
+	} else {
+		v4 = cidr[:x]
+		if x+1 >= len(cidr) || cidr[x+1] != '/' {
+			return errors.New("invalid dual-cidr-length")
+		}
+		v6 = cidr[x+2:]
+	}
+	if len(v4) > 0 {
+		dir.cidr4, err = parseCIDR(v4, 32)
+		if err != nil {
+			return err
+		}
+	}
+	if len(v6) > 0 {
+		dir.cidr6, err = parseCIDR(v6, 128)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseNetwork parse the IPv4 or IPv6 network, with optional CIDR length,
+// for mechanism "ip4" and "ip6".
+func (dir *directive) parseNetwork(value []byte) (err error) {
+	var snet = string(value)
+
+	if bytes.IndexByte(value, '/') < 0 {
+		if dir.mech == mechanismIP4 {
+			snet += "/32"
+		} else {
+			snet += "/128"
+		}
+	}
+
+	var ip net.IP
+
+	ip, dir.ipnet, err = net.ParseCIDR(snet)
+	if err != nil {
+		return err
+	}
+
+	var isIPv4 = ip.To4() != nil
+	if (dir.mech == mechanismIP4) != isIPv4 {
+		return errors.New("invalid network address")
+	}
+	return nil
+}
+
+// indexCIDR return the index of first "/" outside of macro expansion
+// "%{...}", or -1 if not found.
+func indexCIDR(value []byte) int {
+	var inMacro bool
+	for x, c := range value {
+		switch c {
+		case '{':
+			inMacro = true
+		case '}':
+			inMacro = false
+		case '/':
+			if !inMacro {
+				return x
+			}
+		}
+	}
+	return -1
 }
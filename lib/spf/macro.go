@@ -9,10 +9,10 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/shuLhan/share/lib/ascii"
-	libdns "github.com/shuLhan/share/lib/dns"
 	libnet "github.com/shuLhan/share/lib/net"
 )
 
@@ -239,13 +239,17 @@ func (m *macro) expandLetter() (value []byte) {
 	case macroIP:
 		value = toDotIP(m.ref.IP)
 	case macroPtr:
-		ptrDomain, err := libdns.LookupPTR(dnsClient, m.ref.IP)
+		var ptrDomain string
+
+		names, err := m.ref.resolver.LookupAddr(m.ref.IP)
 
 		// If there are no validated domain names or if a DNS error
 		// occurs, the string "unknown" is used.
 		// RFC 7208 Section 7.3.
-		if err != nil || len(ptrDomain) == 0 {
+		if err != nil || len(names) == 0 {
 			ptrDomain = "unknown"
+		} else {
+			ptrDomain = strings.TrimSuffix(names[0], ".")
 		}
 		value = []byte(ptrDomain)
 
@@ -0,0 +1,138 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spf
+
+import (
+	"net"
+
+	libdns "github.com/shuLhan/share/lib/dns"
+)
+
+// Resolver define an interface to lookup DNS records required when
+// evaluating SPF record.
+//
+// Each method should return empty result without an error if the domain
+// name does not exist or does not have the requested records.
+// An error should be returned only on transient failure, which will
+// cause the check to return "temperror".
+type Resolver interface {
+	// LookupAddr return list of host names of IP address (PTR
+	// records).
+	LookupAddr(ip net.IP) (names []string, err error)
+
+	// LookupIP return list of IPv4 and IPv6 addresses of host.
+	LookupIP(host string) (ips []net.IP, err error)
+
+	// LookupMX return list of mail exchanger host names of domain.
+	LookupMX(domain string) (hosts []string, err error)
+
+	// LookupTXT return list of TXT records of domain.
+	LookupTXT(domain string) (txts []string, err error)
+}
+
+// dnsResolver implement Resolver using the default DNS client.
+type dnsResolver struct{}
+
+// LookupAddr lookup the PTR record of IP address.
+func (res dnsResolver) LookupAddr(ip net.IP) (names []string, err error) {
+	var name string
+
+	name, err = libdns.LookupPTR(dnsClient, ip)
+	if err != nil {
+		return nil, err
+	}
+	if len(name) > 0 {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// LookupIP lookup the A and AAAA records of host.
+func (res dnsResolver) LookupIP(host string) (ips []net.IP, err error) {
+	var (
+		rtypes = []libdns.RecordType{libdns.RecordTypeA, libdns.RecordTypeAAAA}
+
+		answers []libdns.ResourceRecord
+		rtype   libdns.RecordType
+	)
+	for _, rtype = range rtypes {
+		answers, err = res.lookup(host, rtype)
+		if err != nil {
+			return nil, err
+		}
+		for _, rr := range answers {
+			var v, ok = rr.Value.(string)
+			if !ok {
+				continue
+			}
+			var ip = net.ParseIP(v)
+			if ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips, nil
+}
+
+// LookupMX lookup the MX records of domain.
+func (res dnsResolver) LookupMX(domain string) (hosts []string, err error) {
+	var answers []libdns.ResourceRecord
+
+	answers, err = res.lookup(domain, libdns.RecordTypeMX)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range answers {
+		var mx, ok = rr.Value.(*libdns.RDataMX)
+		if ok {
+			hosts = append(hosts, mx.Exchange)
+		}
+	}
+	return hosts, nil
+}
+
+// LookupTXT lookup the TXT records of domain.
+func (res dnsResolver) LookupTXT(domain string) (txts []string, err error) {
+	var answers []libdns.ResourceRecord
+
+	answers, err = res.lookup(domain, libdns.RecordTypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range answers {
+		var txt, ok = rr.Value.(string)
+		if ok {
+			txts = append(txts, txt)
+		}
+	}
+	return txts, nil
+}
+
+func (res dnsResolver) lookup(dname string, rtype libdns.RecordType) (
+	answers []libdns.ResourceRecord, err error,
+) {
+	var (
+		q = libdns.MessageQuestion{
+			Name: dname,
+			Type: rtype,
+		}
+		msg *libdns.Message
+	)
+
+	msg, err = dnsClient.Lookup(q, true)
+	if err != nil {
+		return nil, err
+	}
+
+	switch msg.Header.RCode {
+	case libdns.RCodeOK:
+	case libdns.RCodeErrName:
+		return nil, nil
+	default:
+		return nil, errServerFailure
+	}
+
+	return msg.FilterAnswers(rtype), nil
+}
@@ -8,16 +8,26 @@ import (
 	"bytes"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 
-	"github.com/shuLhan/share/lib/dns"
 	libnet "github.com/shuLhan/share/lib/net"
 )
 
+// maxDNSLookup define the maximum number of mechanisms and modifiers that
+// do DNS lookups, RFC 7208 section 4.6.4.
+const maxDNSLookup = 10
+
 // Result contains the output of CheckHost function.
 type Result struct {
 	IP net.IP // The IP address of sender.
 
+	resolver Resolver
+
+	// nlookup count the number of DNS lookups, shared with the
+	// "include" and "redirect" results.
+	nlookup *int
+
 	Err string
 
 	Domain   []byte // The domain address of sender from SMTP EHLO or MAIL FROM command.
@@ -33,6 +43,30 @@ type Result struct {
 	Code byte // Result of check host.
 }
 
+// checkError define an error during evaluation with its result code.
+type checkError struct {
+	msg  string
+	code byte
+}
+
+func (cerr *checkError) Error() string {
+	return cerr.msg
+}
+
+func permError(format string, args ...any) *checkError {
+	return &checkError{
+		code: ResultCodePermError,
+		msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+func tempError(format string, args ...any) *checkError {
+	return &checkError{
+		code: ResultCodeTempError,
+		msg:  fmt.Sprintf(format, args...),
+	}
+}
+
 // newResult initialize new SPF result on single domain.
 func newResult(ip net.IP, domain, sender, hostname string) (result *Result) {
 	bsender := []byte(sender)
@@ -45,15 +79,10 @@ func newResult(ip net.IP, domain, sender, hostname string) (result *Result) {
 		Hostname:     []byte(hostname),
 		senderLocal:  bsender[:at],
 		senderDomain: bsender[at+1:],
+		resolver:     dnsResolver{},
 	}
 
-	if !libnet.IsHostnameValid(result.Domain, true) {
-		result.Code = ResultCodeNone
-		result.Err = "invalid domain name"
-		return
-	}
-
-	return
+	return result
 }
 
 // Error return the string representation of the result as error message.
@@ -61,74 +90,104 @@ func (result *Result) Error() string {
 	return fmt.Sprintf("spf: %q %s", result.Domain, result.Err)
 }
 
-// lookup the TXT record that contains SPF record on domain name.
-func (result *Result) lookup() {
-	var (
-		dnsMsg *dns.Message
-		err    error
-		txts   []dns.ResourceRecord
-		q      = dns.MessageQuestion{
-			Name: string(result.Domain),
-			Type: dns.RecordTypeTXT,
-		}
-	)
-	dnsMsg, err = dnsClient.Lookup(q, true)
+// String return the result code as text, for example "pass" or "fail".
+func (result *Result) String() string {
+	return resultCodeNames[result.Code]
+}
+
+// check run the check_host() function on result's domain.
+func (result *Result) check() {
+	if !libnet.IsHostnameValid(result.Domain, true) {
+		result.Code = ResultCodeNone
+		result.Err = "invalid domain name"
+		return
+	}
+
+	var err = result.lookup()
 	if err != nil {
-		result.Code = ResultCodeTempError
-		result.Err = err.Error()
+		result.setError(err)
 		return
 	}
 
-	switch dnsMsg.Header.RCode {
-	case dns.RCodeOK:
-	case dns.RCodeErrName:
-		result.Code = ResultCodeNone
-		result.Err = "domain name does not exist"
+	err = result.evaluateSPFRecord()
+	if err != nil {
+		result.setError(err)
 		return
-	default:
+	}
+
+	err = result.evaluate()
+	if err != nil {
+		result.setError(err)
+	}
+}
+
+// newSubResult create new result for "include" or "redirect" that inherit
+// the IP, sender, resolver, and lookup counter from result.
+func (result *Result) newSubResult(domain []byte) (sub *Result) {
+	sub = newResult(result.IP, string(domain), string(result.Sender), string(result.Hostname))
+	sub.resolver = result.resolver
+	sub.nlookup = result.nlookup
+	return sub
+}
+
+// setError set the result code and error message based on err.
+func (result *Result) setError(err error) {
+	var cerr, ok = err.(*checkError)
+	if ok {
+		result.Code = cerr.code
+	} else {
 		result.Code = ResultCodeTempError
-		result.Err = "server failure"
-		return
 	}
+	result.Err = err.Error()
+}
 
-	txts = dnsMsg.FilterAnswers(dns.RecordTypeTXT)
-	if len(txts) == 0 {
-		result.Code = ResultCodeNone
-		result.Err = "no SPF record found"
-		return
+// countLookup increment the number of DNS lookups and return permerror
+// if its exceed the limit.
+func (result *Result) countLookup() (err error) {
+	*result.nlookup++
+	if *result.nlookup > maxDNSLookup {
+		return permError("too many DNS lookups")
+	}
+	return nil
+}
+
+// lookup the TXT record that contains SPF record on domain name.
+func (result *Result) lookup() (err error) {
+	var txts []string
+
+	txts, err = result.resolver.LookupTXT(string(result.Domain))
+	if err != nil {
+		return tempError("%s", err)
 	}
 
 	var found int
 
-	for x := 0; x < len(txts); x++ {
-		rdata, ok := txts[x].Value.(string)
-		if !ok {
+	for _, rdata := range txts {
+		if rdata != "v=spf1" && !strings.HasPrefix(rdata, "v=spf1 ") {
 			continue
 		}
-
-		if strings.HasPrefix(rdata, "v=spf1") {
-			found++
-			if found == 1 {
-				result.terms = []byte(rdata)
-			}
+		found++
+		if found == 1 {
+			result.terms = []byte(rdata)
 		}
 	}
 	if found == 0 {
-		result.Code = ResultCodeNone
-		result.Err = "no SPF record found"
-		return
+		return &checkError{
+			code: ResultCodeNone,
+			msg:  "no SPF record found",
+		}
 	}
 	if found > 1 {
-		result.Code = ResultCodePermError
-		result.Err = "multiple SPF records found"
-		return
+		return permError("multiple SPF records found")
 	}
 
 	result.terms = bytes.ToLower(result.terms)
+
+	return nil
 }
 
-// evaluateSPFRecord parse and evaluate each directive with its modifiers
-// in the SPF record.
+// evaluateSPFRecord parse each directive with its modifiers in the SPF
+// record.
 //
 //	terms            = *( 1*SP ( directive / modifier ) )
 //
@@ -141,45 +200,241 @@ func (result *Result) lookup() {
 //	                 ; where name is not any known modifier
 //
 //	name             = ALPHA *( ALPHA / DIGIT / "-" / "_" / "." )
-func (result *Result) evaluateSPFRecord() {
+func (result *Result) evaluateSPFRecord() (err error) {
 	terms := bytes.Fields(result.terms)
 
 	// Ignore the first field "v=spf1".
 
 	for x := 1; x < len(terms); x++ {
-		dir := result.parseDirective(terms[x])
+		var dir *directive
+
+		dir, err = result.parseDirective(terms[x])
+		if err != nil {
+			return err
+		}
 		if dir != nil {
 			result.dirs = append(result.dirs, dir)
 
 			// Mechanisms after "all" will never be tested and MUST be
 			// ignored -- RFC 7208 section 5.1.
 			if dir.mech == mechanismAll {
-				return
+				return nil
 			}
 			continue
 		}
 
-		if result.Code != 0 {
-			return
-		}
+		var mod *modifier
 
-		mod := result.parseModifier(terms[x])
-		if mod == nil {
-			return
+		mod, err = result.parseModifier(terms[x])
+		if err != nil {
+			return err
 		}
 
 		result.mods = append(result.mods, mod)
 	}
+	return nil
 }
 
-// parseDirective parse directive from single term.
-// It will return non-nil if term is a directive, otherwise it will return
-// nil.
-func (result *Result) parseDirective(term []byte) (dir *directive) {
+// evaluate each directive in order until one of them match.
+// If none of directive match, evaluate the "redirect" modifier if its
+// exist, otherwise the result is neutral.
+func (result *Result) evaluate() (err error) {
 	var (
-		qual byte
-		err  error
+		dir     *directive
+		isMatch bool
 	)
+	for _, dir = range result.dirs {
+		isMatch, err = result.match(dir)
+		if err != nil {
+			return err
+		}
+		if isMatch {
+			result.Code = qualifierCode(dir.qual)
+			return nil
+		}
+	}
+
+	for _, mod := range result.mods {
+		if mod.name != modifierRedirect {
+			continue
+		}
+
+		err = result.countLookup()
+		if err != nil {
+			return err
+		}
+
+		var domain []byte
+
+		domain, err = macroExpand(result, modifierRedirect, []byte(mod.value))
+		if err != nil {
+			return permError("%s", err)
+		}
+
+		var sub = result.newSubResult(domain)
+		sub.check()
+		if sub.Code == ResultCodeNone {
+			return permError("redirect to %q: %s", domain, sub.Err)
+		}
+		result.Code = sub.Code
+		result.Err = sub.Err
+		return nil
+	}
+
+	result.Code = ResultCodeNeutral
+	return nil
+}
+
+// match return true if the IP address match with the directive's
+// mechanism.
+func (result *Result) match(dir *directive) (isMatch bool, err error) {
+	switch dir.mech {
+	case mechanismAll:
+		return true, nil
+
+	case mechanismInclude:
+		return result.matchInclude(dir)
+
+	case mechanismA:
+		err = result.countLookup()
+		if err != nil {
+			return false, err
+		}
+		return result.matchHost(dir, dir.value)
+
+	case mechanismMx:
+		err = result.countLookup()
+		if err != nil {
+			return false, err
+		}
+		return result.matchMX(dir)
+
+	case mechanismPtr:
+		err = result.countLookup()
+		if err != nil {
+			return false, err
+		}
+		return result.matchPtr(dir)
+
+	case mechanismIP4, mechanismIP6:
+		return dir.ipnet.Contains(result.IP), nil
+
+	case mechanismExist:
+		err = result.countLookup()
+		if err != nil {
+			return false, err
+		}
+		var ips []net.IP
+		ips, err = result.resolver.LookupIP(string(dir.value))
+		if err != nil {
+			return false, tempError("%s", err)
+		}
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, nil
+}
+
+func (result *Result) matchInclude(dir *directive) (isMatch bool, err error) {
+	err = result.countLookup()
+	if err != nil {
+		return false, err
+	}
+
+	var sub = result.newSubResult(dir.value)
+
+	sub.check()
+
+	switch sub.Code {
+	case ResultCodePass:
+		return true, nil
+	case ResultCodeFail, ResultCodeSoftfail, ResultCodeNeutral:
+		return false, nil
+	case ResultCodeTempError:
+		return false, tempError("include %q: %s", dir.value, sub.Err)
+	}
+	return false, permError("include %q: %s", dir.value, sub.Err)
+}
+
+// matchHost return true if one of the IP address of host match with the
+// IP address in result, using the CIDR in directive.
+func (result *Result) matchHost(dir *directive, host []byte) (isMatch bool, err error) {
+	var ips []net.IP
+
+	ips, err = result.resolver.LookupIP(string(host))
+	if err != nil {
+		return false, tempError("%s", err)
+	}
+	for _, ip := range ips {
+		if dir.containsIP(ip, result.IP) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (result *Result) matchMX(dir *directive) (isMatch bool, err error) {
+	var hosts []string
+
+	hosts, err = result.resolver.LookupMX(string(dir.value))
+	if err != nil {
+		return false, tempError("%s", err)
+	}
+	if len(hosts) > maxDNSLookup {
+		return false, permError("too many MX records")
+	}
+	for _, host := range hosts {
+		isMatch, err = result.matchHost(dir, []byte(host))
+		if err != nil || isMatch {
+			return isMatch, err
+		}
+	}
+	return false, nil
+}
+
+// matchPtr match the validated host names of IP address with the domain
+// in directive, RFC 7208 section 5.5.
+func (result *Result) matchPtr(dir *directive) (isMatch bool, err error) {
+	var names []string
+
+	names, err = result.resolver.LookupAddr(result.IP)
+	if err != nil {
+		return false, nil
+	}
+	if len(names) > maxDNSLookup {
+		names = names[:maxDNSLookup]
+	}
+
+	var target = strings.TrimSuffix(string(dir.value), ".")
+
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if name != target && !strings.HasSuffix(name, "."+target) {
+			continue
+		}
+		var ips []net.IP
+		ips, err = result.resolver.LookupIP(name)
+		if err != nil {
+			continue
+		}
+		for _, ip := range ips {
+			if ip.Equal(result.IP) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// parseDirective parse directive from single term.
+// It will return non-nil directive if term is a directive, or nil if the
+// term is modifier.
+func (result *Result) parseDirective(term []byte) (dir *directive, err error) {
+	var qual byte
 
 	switch term[0] {
 	case qualifierPass, qualifierNeutral, qualifierSoftfail, qualifierFail:
@@ -189,65 +444,99 @@ func (result *Result) parseDirective(term []byte) (dir *directive) {
 		qual = qualifierPass
 	}
 
-	// Try to split the term to get the mechanism and domain-spec.
-	kv := bytes.Split(term, []byte{':'})
+	var x = bytes.IndexAny(term, ":/=")
+	if x >= 0 && term[x] == '=' {
+		// The term is modifier.
+		return nil, nil
+	}
 
-	mech := string(kv[0])
+	var (
+		mech  = term
+		value []byte
+	)
+	if x >= 0 {
+		mech = term[:x]
+		value = term[x:]
+	}
 
-	switch mech {
+	dir = &directive{
+		qual: qual,
+		mech: string(mech),
+	}
+
+	switch dir.mech {
 	case mechanismAll:
-		dir = &directive{
-			qual: qual,
-			mech: mech,
+		if len(value) != 0 {
+			return nil, permError("invalid mechanism %q", term)
 		}
-		return dir
 
-	case mechanismInclude:
-		dir = &directive{
-			qual: qual,
-			mech: mech,
-		}
-		if len(kv) >= 2 {
-			dir.value, err = macroExpand(result, mechanismInclude, kv[1])
-			if err != nil {
-				result.Code = ResultCodePermError
-				result.Err = err.Error()
-				return nil
-			}
+	case mechanismInclude, mechanismExist:
+		if len(value) < 2 || value[0] != ':' {
+			return nil, permError("missing domain-spec in %q", term)
+		}
+		dir.value, err = macroExpand(result, dir.mech, value[1:])
+		if err != nil {
+			return nil, permError("%s: %s", term, err)
 		}
-		return dir
-
-	case mechanismA:
-
-	case mechanismMx:
-
-	case mechanismPtr:
 
-	case mechanismIP4:
+	case mechanismA, mechanismMx, mechanismPtr:
+		err = dir.parseDomainCIDR(result, value)
+		if err != nil {
+			return nil, permError("%s: %s", term, err)
+		}
 
-	case mechanismIP6:
+	case mechanismIP4, mechanismIP6:
+		if len(value) < 2 || value[0] != ':' {
+			return nil, permError("missing network in %q", term)
+		}
+		err = dir.parseNetwork(value[1:])
+		if err != nil {
+			return nil, permError("%s: %s", term, err)
+		}
 
-	case mechanismExist:
+	default:
+		return nil, permError("unknown mechanism %q", term)
 	}
 
-	return nil
+	return dir, nil
 }
 
-func (result *Result) parseModifier(term []byte) (mod *modifier) {
-	kv := bytes.Split(term, []byte{'='})
+func (result *Result) parseModifier(term []byte) (mod *modifier, err error) {
+	kv := bytes.SplitN(term, []byte{'='}, 2)
+	if len(kv) != 2 || len(kv[0]) == 0 {
+		return nil, permError("invalid term %q", term)
+	}
 
 	mod = &modifier{
-		name: string(kv[0]),
+		name:  string(kv[0]),
+		value: string(kv[1]),
 	}
-	if len(kv) >= 2 {
-		mod.value = string(kv[1])
+
+	if mod.name == modifierRedirect && len(mod.value) == 0 {
+		return nil, permError("empty redirect domain")
 	}
 
-	switch mod.name {
-	case modifierExp:
-		return mod
-	case modifierRedirect:
-		return mod
+	return mod, nil
+}
+
+// qualifierCode convert the qualifier into result code.
+func qualifierCode(qual byte) byte {
+	switch qual {
+	case qualifierFail:
+		return ResultCodeFail
+	case qualifierSoftfail:
+		return ResultCodeSoftfail
+	case qualifierNeutral:
+		return ResultCodeNeutral
+	}
+	return ResultCodePass
+}
+
+// parseCIDR parse the prefix length from string.
+func parseCIDR(v []byte, max int) (n int, err error) {
+	n, err = strconv.Atoi(string(v))
+	if err != nil || n < 0 || n > max {
+		return 0, fmt.Errorf("invalid CIDR length %q", v)
 	}
-	return mod
+	return n, nil
 }
@@ -6,6 +6,7 @@
 package spf
 
 import (
+	"errors"
 	"log"
 	"net"
 	"strings"
@@ -51,6 +52,19 @@ const (
 	ResultCodePermError
 )
 
+var errServerFailure = errors.New("DNS server failure")
+
+// resultCodeNames contains the text representation of each result code.
+var resultCodeNames = map[byte]string{
+	ResultCodePass:      "pass",
+	ResultCodeNone:      "none",
+	ResultCodeNeutral:   "neutral",
+	ResultCodeFail:      "fail",
+	ResultCodeSoftfail:  "softfail",
+	ResultCodeTempError: "temperror",
+	ResultCodePermError: "permerror",
+}
+
 var (
 	dnsClient       *libdns.UDPClient
 	defSystemResolv = ""
@@ -75,25 +89,24 @@ func init() {
 // whether a particular host is or is not permitted to send mail with a given
 // identity.
 func CheckHost(ip net.IP, domain, sender, hostname string) (result *Result) {
+	return CheckHostWith(dnsResolver{}, ip, domain, sender, hostname)
+}
+
+// CheckHostWith is like CheckHost but use the custom resolver to lookup
+// the DNS records.
+func CheckHostWith(r Resolver, ip net.IP, domain, sender, hostname string) (result *Result) {
 	at := strings.Index(sender, "@")
 	if at == -1 {
 		sender = "postmaster@" + sender
 	}
 
-	result = newResult(ip, domain, sender, hostname)
-	if result.Code != 0 {
-		return result
-	}
+	var nlookup int
 
-	result.lookup()
-	if result.Code != 0 {
-		return result
-	}
+	result = newResult(ip, domain, sender, hostname)
+	result.resolver = r
+	result.nlookup = &nlookup
 
-	result.evaluateSPFRecord()
-	if result.Code != 0 {
-		return result
-	}
+	result.check()
 
 	return result
 }
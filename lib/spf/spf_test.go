@@ -0,0 +1,170 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package spf
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+// mockResolver implement Resolver using static records.
+type mockResolver struct {
+	txt map[string][]string
+	ip  map[string][]net.IP
+	mx  map[string][]string
+	ptr map[string][]string
+}
+
+func (res *mockResolver) LookupAddr(ip net.IP) ([]string, error) {
+	return res.ptr[ip.String()], nil
+}
+
+func (res *mockResolver) LookupIP(host string) ([]net.IP, error) {
+	return res.ip[host], nil
+}
+
+func (res *mockResolver) LookupMX(domain string) ([]string, error) {
+	return res.mx[domain], nil
+}
+
+func (res *mockResolver) LookupTXT(domain string) ([]string, error) {
+	if domain == `temp.test` {
+		return nil, errors.New(`timeout`)
+	}
+	return res.txt[domain], nil
+}
+
+func TestCheckHostWith(t *testing.T) {
+	var res = &mockResolver{
+		txt: map[string][]string{
+			`a.test`:        {`v=spf1 a -all`},
+			`mx.test`:       {`v=spf1 mx/24 -all`},
+			`ip4.test`:      {`v=spf1 ip4:192.0.2.0/24 ~all`},
+			`ip6.test`:      {`v=spf1 ip6:2001:db8::/32 -all`},
+			`include.test`:  {`v=spf1 include:ip4.test -all`},
+			`redirect.test`: {`v=spf1 redirect=ip4.test`},
+			`ptr.test`:      {`v=spf1 ptr -all`},
+			`exists.test`:   {`v=spf1 exists:%{i}._spf.exists.test -all`},
+			`neutral.test`:  {`v=spf1 ip4:10.0.0.1`},
+			`multi.test`:    {`v=spf1 -all`, `v=spf1 +all`},
+			`unknown.test`:  {`v=spf1 foo:bar -all`},
+			`notspf.test`:   {`google-site-verification=xyz`},
+			`loop.test`:     {`v=spf1 include:loop.test -all`},
+		},
+		ip: map[string][]net.IP{
+			`a.test`:                     {net.ParseIP(`192.0.2.1`)},
+			`mail.mx.test`:               {net.ParseIP(`198.51.100.10`)},
+			`host.ptr.test`:              {net.ParseIP(`192.0.2.3`)},
+			`192.0.2.5._spf.exists.test`: {net.ParseIP(`127.0.0.2`)},
+		},
+		mx: map[string][]string{
+			`mx.test`: {`mail.mx.test`},
+		},
+		ptr: map[string][]string{
+			`192.0.2.3`: {`host.ptr.test.`},
+		},
+	}
+
+	type testCase struct {
+		desc   string
+		ip     string
+		domain string
+		exp    byte
+	}
+
+	var cases = []testCase{{
+		desc:   `With "a" match`,
+		ip:     `192.0.2.1`,
+		domain: `a.test`,
+		exp:    ResultCodePass,
+	}, {
+		desc:   `With "a" not match`,
+		ip:     `192.0.2.2`,
+		domain: `a.test`,
+		exp:    ResultCodeFail,
+	}, {
+		desc:   `With "mx" and CIDR`,
+		ip:     `198.51.100.200`,
+		domain: `mx.test`,
+		exp:    ResultCodePass,
+	}, {
+		desc:   `With "ip4" softfail`,
+		ip:     `203.0.113.1`,
+		domain: `ip4.test`,
+		exp:    ResultCodeSoftfail,
+	}, {
+		desc:   `With "ip6"`,
+		ip:     `2001:db8::1`,
+		domain: `ip6.test`,
+		exp:    ResultCodePass,
+	}, {
+		desc:   `With "include"`,
+		ip:     `192.0.2.100`,
+		domain: `include.test`,
+		exp:    ResultCodePass,
+	}, {
+		desc:   `With "include" not match`,
+		ip:     `203.0.113.1`,
+		domain: `include.test`,
+		exp:    ResultCodeFail,
+	}, {
+		desc:   `With "redirect"`,
+		ip:     `203.0.113.1`,
+		domain: `redirect.test`,
+		exp:    ResultCodeSoftfail,
+	}, {
+		desc:   `With "ptr"`,
+		ip:     `192.0.2.3`,
+		domain: `ptr.test`,
+		exp:    ResultCodePass,
+	}, {
+		desc:   `With "exists" macro`,
+		ip:     `192.0.2.5`,
+		domain: `exists.test`,
+		exp:    ResultCodePass,
+	}, {
+		desc:   `With no match and no "all"`,
+		ip:     `192.0.2.5`,
+		domain: `neutral.test`,
+		exp:    ResultCodeNeutral,
+	}, {
+		desc:   `With multiple records`,
+		ip:     `192.0.2.5`,
+		domain: `multi.test`,
+		exp:    ResultCodePermError,
+	}, {
+		desc:   `With unknown mechanism`,
+		ip:     `192.0.2.5`,
+		domain: `unknown.test`,
+		exp:    ResultCodePermError,
+	}, {
+		desc:   `With no SPF record`,
+		ip:     `192.0.2.5`,
+		domain: `notspf.test`,
+		exp:    ResultCodeNone,
+	}, {
+		desc:   `With DNS failure`,
+		ip:     `192.0.2.5`,
+		domain: `temp.test`,
+		exp:    ResultCodeTempError,
+	}, {
+		desc:   `With include loop`,
+		ip:     `192.0.2.5`,
+		domain: `loop.test`,
+		exp:    ResultCodePermError,
+	}}
+
+	var (
+		c   testCase
+		got *Result
+	)
+	for _, c = range cases {
+		got = CheckHostWith(res, net.ParseIP(c.ip), c.domain, `user@`+c.domain, `mx.local`)
+		test.Assert(t, c.desc, resultCodeNames[c.exp], got.String())
+	}
+}
@@ -33,6 +33,13 @@ type Client struct {
 	config  *ssh.ClientConfig
 	section *config.Section
 
+	// proxy is the client to the last jump host in ProxyJump, where
+	// the connection to remote address is made.
+	proxy *Client
+
+	// master is the shared connection, if ControlMaster is enabled.
+	master *controlMaster
+
 	stdout io.Writer
 	stderr io.Writer
 
@@ -42,6 +49,10 @@ type Client struct {
 	remoteAddr string
 
 	listKnownHosts []string
+
+	// isForwardAgent is true if the agent is forwarded to the
+	// remote host.
+	isForwardAgent bool
 }
 
 // NewClientInteractive create a new SSH connection using predefined
@@ -56,26 +67,41 @@ type Client struct {
 // [crypto.LoadPrivateKeyInteractive] for more information.
 //
 // The following section keys are recognized and implemented by Client,
+//   - ControlMaster, if its enabled, the connection to the same user,
+//     host, and port is shared by all Client in the same process, see
+//     [Client.Close].
+//   - ForwardAgent, see [Client.NewSession].
 //   - Hostname
 //   - IdentityAgent
 //   - IdentityFile
 //   - Port
+//   - ProxyCommand, the connection is made through the standard input and
+//     output of the command.
+//   - ProxyJump, the connection is made through each of the jump host,
+//     see [config.Section.ProxyJump].
+//     If its set, the ProxyCommand is ignored.
 //   - User
 //   - UserKnownHostsFile, setting this to "none" will set HostKeyCallback
 //     to [ssh.InsecureIgnoreHostKey].
+//
+// The DynamicForward, LocalForward, and RemoteForward are started using
+// [Client.StartForwarding].
 func NewClientInteractive(section *config.Section) (cl *Client, err error) {
 	if section == nil {
 		return nil, nil
 	}
 
-	var (
-		logp = `NewClientInteractive`
+	var logp = `NewClientInteractive`
 
-		sshAgent agent.ExtendedAgent
-		signers  []ssh.Signer
-		signer   ssh.Signer
-	)
+	cl, err = newClient(section)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return cl, nil
+}
 
+// newClient create and connect the Client to the remote host in section.
+func newClient(section *config.Section) (cl *Client, err error) {
 	cl = &Client{
 		sysEnvs: libos.Environments(),
 		config: &ssh.ClientConfig{
@@ -84,54 +110,165 @@ func NewClientInteractive(section *config.Section) (cl *Client, err error) {
 		section:    section,
 		stdout:     os.Stdout,
 		stderr:     os.Stderr,
-		remoteAddr: fmt.Sprintf(`%s:%s`, section.Hostname(), section.Port()),
+		remoteAddr: net.JoinHostPort(section.Hostname(), section.Port()),
+	}
+
+	if section.ControlMaster() && cl.useControlMaster() {
+		return cl, nil
 	}
 
 	err = cl.setConfigHostKeyCallback()
 	if err != nil {
-		return nil, fmt.Errorf(`%s: %w`, logp, err)
+		return nil, err
+	}
+
+	err = cl.dialProxyJump()
+	if err != nil {
+		return nil, err
 	}
 
+	err = cl.connect()
+	if err != nil {
+		if cl.proxy != nil {
+			_ = cl.proxy.Close()
+		}
+		return nil, err
+	}
+
+	var agentSock = section.ForwardAgent()
+	if len(agentSock) != 0 {
+		err = agent.ForwardToRemote(cl.Client, agentSock)
+		if err != nil {
+			_ = cl.Close()
+			return nil, fmt.Errorf(`ForwardAgent: %w`, err)
+		}
+		cl.isForwardAgent = true
+	}
+
+	if section.ControlMaster() {
+		cl.setControlMaster()
+	}
+	return cl, nil
+}
+
+// connect to the remote address using the key from IdentityAgent or
+// IdentityFile.
+func (cl *Client) connect() (err error) {
+	var (
+		section = cl.section
+
+		sshAgent agent.ExtendedAgent
+		signers  []ssh.Signer
+		signer   ssh.Signer
+	)
+
 	var sshAgentSockPath = section.IdentityAgent()
 	if len(sshAgentSockPath) > 0 {
 		var sshAgentSock net.Conn
 
 		sshAgentSock, err = net.Dial("unix", sshAgentSockPath)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", logp, err)
+			return err
 		}
 
 		sshAgent = agent.NewClient(sshAgentSock)
 
 		signers, err = sshAgent.Signers()
 		if err != nil {
-			return nil, fmt.Errorf(`%s: %w`, logp, err)
+			return err
 		}
 
 		signer, err = cl.dialWithSigners(signers)
 		if signer != nil {
 			// Client connected with one of the key in agent.
-			return cl, nil
+			return nil
 		}
 
 		if err != nil && strings.Contains(err.Error(), `knownhosts`) {
 			// Host key is either unknown or mismatch with one
 			// of known_hosts files, so no need to continue with
 			// dialWithPrivateKeys.
-			return nil, fmt.Errorf(`%s: %w`, logp, err)
+			return err
 		}
 	}
 
 	if len(section.IdentityFile) == 0 {
-		return nil, fmt.Errorf(`%s: empty IdentityFile`, logp)
+		return fmt.Errorf(`empty IdentityFile`)
+	}
+
+	return cl.dialWithPrivateKeys(sshAgent)
+}
+
+// dialProxyJump connect to each of jump host in ProxyJump, where the next
+// jump host is connected through the previous one.
+func (cl *Client) dialProxyJump() (err error) {
+	var (
+		jumps = cl.section.ProxyJump()
+
+		jump  *config.Section
+		proxy *Client
+	)
+	for _, jump = range jumps {
+		proxy = &Client{
+			sysEnvs: cl.sysEnvs,
+			config: &ssh.ClientConfig{
+				User: jump.User(),
+			},
+			section:    jump,
+			stdout:     cl.stdout,
+			stderr:     cl.stderr,
+			remoteAddr: net.JoinHostPort(jump.Hostname(), jump.Port()),
+			proxy:      cl.proxy,
+		}
+
+		err = proxy.setConfigHostKeyCallback()
+		if err == nil {
+			err = proxy.connect()
+		}
+		if err != nil {
+			if cl.proxy != nil {
+				_ = cl.proxy.Close()
+				cl.proxy = nil
+			}
+			return fmt.Errorf(`ProxyJump %s: %w`, proxy.remoteAddr, err)
+		}
+		cl.proxy = proxy
 	}
+	return nil
+}
+
+// dial connect to the remote address, directly or through the ProxyJump
+// or ProxyCommand.
+func (cl *Client) dial() (sshc *ssh.Client, err error) {
+	var (
+		proxyCommand = cl.section.ProxyCommand()
 
-	err = cl.dialWithPrivateKeys(sshAgent)
+		conn net.Conn
+	)
+	switch {
+	case cl.proxy != nil:
+		conn, err = cl.proxy.Client.Dial(`tcp`, cl.remoteAddr)
+	case len(proxyCommand) != 0:
+		conn, err = newProxyCommandConn(proxyCommand, cl.section.WorkingDir)
+	default:
+		return ssh.Dial(`tcp`, cl.remoteAddr, cl.config)
+	}
 	if err != nil {
-		return nil, fmt.Errorf(`%s: %w`, logp, err)
+		return nil, err
 	}
 
-	return cl, nil
+	var (
+		sshConn ssh.Conn
+		chans   <-chan ssh.NewChannel
+		reqs    <-chan *ssh.Request
+	)
+
+	sshConn, chans, reqs, err = ssh.NewClientConn(conn, cl.remoteAddr, cl.config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
 }
 
 // setConfigHostKeyCallback set the config.HostKeyCallback based on the
@@ -201,7 +338,7 @@ func (cl *Client) dialWithSigners(signers []ssh.Signer) (signer ssh.Signer, err
 		cl.config.Auth = []ssh.AuthMethod{
 			ssh.PublicKeys(signer),
 		}
-		cl.Client, err = ssh.Dial(`tcp`, cl.remoteAddr, cl.config)
+		cl.Client, err = cl.dial()
 		if err == nil {
 			return signer, nil
 		}
@@ -242,7 +379,7 @@ func (cl *Client) dialWithPrivateKeys(sshAgent agent.ExtendedAgent) (err error)
 			ssh.PublicKeys(signer),
 		}
 
-		cl.Client, err = ssh.Dial(`tcp`, cl.remoteAddr, cl.config)
+		cl.Client, err = cl.dial()
 		if err == nil {
 			cl.identityFile = identityFile
 			break
@@ -277,9 +414,24 @@ func (cl *Client) dialWithPrivateKeys(sshAgent agent.ExtendedAgent) (err error)
 }
 
 // Close the client connection and release all resources.
+//
+// If the connection is shared using ControlMaster, the connection is
+// closed only when all of the Client that share it has been closed.
 func (cl *Client) Close() (err error) {
-	err = cl.Client.Conn.Close()
+	if cl.master != nil {
+		err = cl.master.release()
+	} else {
+		err = cl.Client.Conn.Close()
+		if cl.proxy != nil {
+			var errProxy = cl.proxy.Close()
+			if err == nil {
+				err = errProxy
+			}
+		}
+	}
 
+	cl.proxy = nil
+	cl.master = nil
 	cl.sysEnvs = nil
 	cl.Client = nil
 	cl.config = nil
@@ -293,7 +445,7 @@ func (cl *Client) Close() (err error) {
 
 // Execute a command on remote server.
 func (cl *Client) Execute(ctx context.Context, cmd string) (err error) {
-	sess, err := cl.Client.NewSession()
+	sess, err := cl.NewSession()
 	if err != nil {
 		return fmt.Errorf("ssh: NewSession: " + err.Error())
 	}
@@ -326,7 +478,7 @@ func (cl *Client) Output(cmd string) (stdout, stderr []byte, err error) {
 
 	var sess *ssh.Session
 
-	sess, err = cl.Client.NewSession()
+	sess, err = cl.NewSession()
 	if err != nil {
 		return nil, nil, fmt.Errorf(`%s %q: %w`, logp, cmd, err)
 	}
@@ -355,6 +507,24 @@ func (cl *Client) Output(cmd string) (stdout, stderr []byte, err error) {
 	return bufout.Bytes(), buferr.Bytes(), nil
 }
 
+// NewSession open new session on the connection.
+// If ForwardAgent is set in the Section, the session request the agent
+// forwarding, so the program in remote host can use the local agent.
+func (cl *Client) NewSession() (sess *ssh.Session, err error) {
+	sess, err = cl.Client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	if cl.isForwardAgent {
+		err = agent.RequestAgentForwarding(sess)
+		if err != nil {
+			_ = sess.Close()
+			return nil, fmt.Errorf(`NewSession: %w`, err)
+		}
+	}
+	return sess, nil
+}
+
 // ScpGet copy file from remote into local storage using scp.
 //
 // The local file should be use the absolute path, or relative to the file in
@@ -6,10 +6,15 @@ package ssh
 
 import (
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
 	"github.com/shuLhan/share/lib/ssh/config"
 	"github.com/shuLhan/share/lib/test"
 )
@@ -63,3 +68,274 @@ func TestNewClient_KeyError_notExist(t *testing.T) {
 	}
 	test.Assert(t, `NewClientInteractive: error`, expError, gotError)
 }
+
+// newTestConfig load the SSH config from content, with the default Host
+// "*" section that use the testClientKey to connect to testServer.
+func newTestConfig(t *testing.T, content string) (cfg *config.Config) {
+	var (
+		identityFile string
+		err          error
+	)
+	identityFile, err = filepath.Abs(testClientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content = fmt.Sprintf("Host *\n\tUser test\n\tIdentityFile %s\n\tIdentityAgent none\n\tUserKnownHostsFile none\n\n%s",
+		identityFile, content)
+
+	var file = filepath.Join(t.TempDir(), `config`)
+
+	err = os.WriteFile(file, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err = config.Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// testOutput run the command in remote host and return its output.
+func testOutput(t *testing.T, cl *Client, cmd string) string {
+	var (
+		stdout []byte
+		stderr []byte
+		err    error
+	)
+	stdout, stderr, err = cl.Output(cmd)
+	if err != nil {
+		t.Fatalf(`%s: %s`, err, stderr)
+	}
+	return string(stdout)
+}
+
+func TestClient_ProxyJump(t *testing.T) {
+	var (
+		jump1  = newTestServer(t, `jump1`)
+		jump2  = newTestServer(t, `jump2`)
+		target = newTestServer(t, `target`)
+	)
+
+	var cfg = newTestConfig(t, fmt.Sprintf(`Host jump1
+	Hostname 127.0.0.1
+	Port %s
+
+Host jump2
+	Hostname 127.0.0.1
+	Port %s
+
+Host target
+	Hostname 127.0.0.1
+	Port %s
+	ProxyJump jump1,jump2
+`, jump1.port(), jump2.port(), target.port()))
+
+	var (
+		cl  *Client
+		err error
+	)
+	cl, err = NewClientInteractive(cfg.Get(`target`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `Output`, `target`, testOutput(t, cl, `hostname`))
+	test.Assert(t, `jump1: direct-tcpip`, int64(1), jump1.ndirect.Load())
+	test.Assert(t, `jump2: direct-tcpip`, int64(1), jump2.ndirect.Load())
+	test.Assert(t, `target: connection`, int64(1), target.nconn.Load())
+
+	err = cl.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_ProxyCommand(t *testing.T) {
+	var (
+		srv = newTestServer(t, `target`)
+
+		exe string
+		err error
+	)
+	exe, err = os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(`TEST_PROXY_COMMAND`, `1`)
+
+	var cfg = newTestConfig(t, fmt.Sprintf(`Host target
+	Hostname 127.0.0.1
+	Port %s
+	ProxyCommand %s -test.run=TestHelperProxyCommand %%h %%p
+`, srv.port(), exe))
+
+	var cl *Client
+
+	cl, err = NewClientInteractive(cfg.Get(`target`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `Output`, `target`, testOutput(t, cl, `hostname`))
+
+	err = cl.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHelperProxyCommand is not a real test.
+// It is run by TestClient_ProxyCommand as ProxyCommand, that connect to
+// the host and port in the last two arguments.
+func TestHelperProxyCommand(t *testing.T) {
+	if os.Getenv(`TEST_PROXY_COMMAND`) != `1` {
+		return
+	}
+
+	var (
+		n    = len(os.Args)
+		conn net.Conn
+		err  error
+	)
+	conn, err = net.Dial(`tcp`, net.JoinHostPort(os.Args[n-2], os.Args[n-1]))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	go func() {
+		_, _ = io.Copy(conn, os.Stdin)
+		os.Exit(0)
+	}()
+	_, _ = io.Copy(os.Stdout, conn)
+	os.Exit(0)
+}
+
+func TestClient_ControlMaster(t *testing.T) {
+	var (
+		srv = newTestServer(t, `target`)
+		cfg = newTestConfig(t, fmt.Sprintf(`Host target
+	Hostname 127.0.0.1
+	Port %s
+	ControlMaster yes
+`, srv.port()))
+
+		cl1 *Client
+		cl2 *Client
+		err error
+	)
+
+	cl1, err = NewClientInteractive(cfg.Get(`target`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl2, err = NewClientInteractive(cfg.Get(`target`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `connection`, int64(1), srv.nconn.Load())
+	test.Assert(t, `cl1: Output`, `target`, testOutput(t, cl1, `hostname`))
+
+	err = cl1.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The shared connection still open until the last Client closed.
+	test.Assert(t, `cl2: Output`, `target`, testOutput(t, cl2, `hostname`))
+
+	err = cl2.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cl1, err = NewClientInteractive(cfg.Get(`target`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `connection after closed`, int64(2), srv.nconn.Load())
+
+	err = cl1.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_ForwardAgent(t *testing.T) {
+	var (
+		keyring = agent.NewKeyring()
+
+		rawKey []byte
+		err    error
+	)
+	rawKey, err = os.ReadFile(testClientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pkey any
+
+	pkey, err = ssh.ParseRawPrivateKey(rawKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = keyring.Add(agent.AddedKey{PrivateKey: pkey, Comment: `test-key`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		agentSock = filepath.Join(t.TempDir(), `agent.sock`)
+		ln        net.Listener
+	)
+	ln, err = net.Listen(`unix`, agentSock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		var (
+			conn    net.Conn
+			errConn error
+		)
+		for {
+			conn, errConn = ln.Accept()
+			if errConn != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				_ = agent.ServeAgent(keyring, conn)
+				_ = conn.Close()
+			}(conn)
+		}
+	}()
+
+	var (
+		srv = newTestServer(t, `target`)
+		cfg = newTestConfig(t, fmt.Sprintf(`Host target
+	Hostname 127.0.0.1
+	Port %s
+	ForwardAgent %s
+`, srv.port(), agentSock))
+
+		cl *Client
+	)
+	cl, err = NewClientInteractive(cfg.Get(`target`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `agent-list`, `test-key`, testOutput(t, cl, `agent-list`))
+
+	err = cl.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
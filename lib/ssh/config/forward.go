@@ -0,0 +1,95 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Forward define the address for LocalForward and RemoteForward.
+type Forward struct {
+	// Listen is the address, in the format "host:port", where the
+	// connection is accepted.
+	// An empty host means all interfaces.
+	Listen string
+
+	// Connect is the address, in the format "host:port", where the
+	// accepted connection is forwarded.
+	Connect string
+}
+
+// parseForward parse the value of LocalForward or RemoteForward in the
+// format "[bind_address:]port host:hostport".
+func parseForward(key, value string) (fwd Forward, err error) {
+	var fields = strings.Fields(value)
+	if len(fields) != 2 {
+		return fwd, fmt.Errorf(`%s: invalid value %q`, key, value)
+	}
+
+	fwd.Listen, err = parseForwardListen(key, fields[0])
+	if err != nil {
+		return fwd, err
+	}
+
+	var host, port string
+
+	host, port, err = net.SplitHostPort(fields[1])
+	if err != nil {
+		return fwd, fmt.Errorf(`%s: invalid address %q: %w`, key, fields[1], err)
+	}
+	err = validatePort(key, port)
+	if err != nil {
+		return fwd, err
+	}
+	fwd.Connect = net.JoinHostPort(host, port)
+
+	return fwd, nil
+}
+
+// parseForwardListen parse the listen address in the format
+// "[bind_address:]port".
+// If bind_address is not set, it default to "localhost".
+// If bind_address is "*", it will listen on all interfaces.
+func parseForwardListen(key, value string) (addr string, err error) {
+	var host, port string
+
+	if strings.HasPrefix(value, `[`) {
+		host, port, err = net.SplitHostPort(value)
+		if err != nil {
+			return ``, fmt.Errorf(`%s: invalid address %q: %w`, key, value, err)
+		}
+	} else {
+		var idx = strings.LastIndexByte(value, ':')
+		if idx < 0 {
+			host = `localhost`
+			port = value
+		} else {
+			host = value[:idx]
+			port = value[idx+1:]
+		}
+	}
+	if host == `*` {
+		host = ``
+	}
+
+	err = validatePort(key, port)
+	if err != nil {
+		return ``, err
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func validatePort(key, port string) (err error) {
+	var v int
+
+	v, err = strconv.Atoi(port)
+	if err != nil || v < 0 || v > 65535 {
+		return fmt.Errorf(`%s: invalid port %q`, key, port)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// jumpHost contains one of the host in ProxyJump.
+type jumpHost struct {
+	user string
+	host string
+	port string
+}
+
+// parseProxyJump parse the comma separated list of jump host, where each
+// host is in the format "[user@]host[:port]" or
+// "ssh://[user@]host[:port]".
+func parseProxyJump(value string) (list []jumpHost, err error) {
+	if value == `` || value == ValueNone {
+		return nil, nil
+	}
+
+	var (
+		raw string
+		jh  jumpHost
+		idx int
+	)
+	for _, raw = range strings.Split(value, `,`) {
+		raw = strings.TrimSpace(raw)
+		raw = strings.TrimPrefix(raw, `ssh://`)
+
+		jh = jumpHost{}
+
+		idx = strings.LastIndexByte(raw, '@')
+		if idx >= 0 {
+			jh.user = raw[:idx]
+			raw = raw[idx+1:]
+		}
+
+		switch {
+		case strings.HasPrefix(raw, `[`):
+			jh.host, jh.port, err = net.SplitHostPort(raw)
+			if err != nil {
+				return nil, fmt.Errorf(`%s: invalid host %q: %w`, KeyProxyJump, raw, err)
+			}
+		case strings.Count(raw, `:`) == 1:
+			jh.host, jh.port, _ = strings.Cut(raw, `:`)
+		default:
+			jh.host = raw
+		}
+		if jh.host == `` {
+			return nil, fmt.Errorf(`%s: empty host in %q`, KeyProxyJump, value)
+		}
+		if jh.port != `` {
+			err = validatePort(KeyProxyJump, jh.port)
+			if err != nil {
+				return nil, err
+			}
+		}
+		list = append(list, jh)
+	}
+	return list, nil
+}
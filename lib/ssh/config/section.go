@@ -54,9 +54,16 @@ const (
 
 	// List of key in Host or Match with value fetched using method.
 	KeyCanonicalizePermittedCNames = `canonicalizepermittedcnames`
+	KeyControlMaster               = `controlmaster`
+	KeyDynamicForward              = `dynamicforward`
+	KeyForwardAgent                = `forwardagent`
 	KeyHostname                    = `hostname`
 	KeyIdentityAgent               = `identityagent`
+	KeyLocalForward                = `localforward`
 	KeyPort                        = `port`
+	KeyProxyCommand                = `proxycommand`
+	KeyProxyJump                   = `proxyjump`
+	KeyRemoteForward               = `remoteforward`
 	KeyUser                        = `user`
 )
 
@@ -65,15 +72,12 @@ const (
 // nolint: deadcode,varcheck
 const (
 	keyCiphers                          = "ciphers"
-	keyControlMaster                    = "controlmaster"
 	keyControlPath                      = "controlpath"
 	keyControlPersist                   = "controlpersist"
-	keyDynamicForward                   = "dynamicforward"
 	keyEnableSSHKeysign                 = "enablesshkeysign"
 	keyEscapeChar                       = "escapechar"
 	keyExitOnForwardFailure             = "keyexitonforwardfailure"
 	keyFingerprintHash                  = "fingerprinthash"
-	keyForwardAgent                     = "forwardagent"
 	keyForwardX11                       = "forwardx11"
 	keyForwardX11Timeout                = "forwardx11timeout"
 	keyForwardX11Trusted                = "forwardx11trusted"
@@ -94,7 +98,6 @@ const (
 	keyKbdInteractiveDevices            = "kbdinteractivedevices"
 	keyKexAlgorithms                    = "kexalgorithms"
 	keyLocalCommand                     = "localcommand"
-	keyLocalForward                     = "localforward"
 	keyLogLevel                         = "loglevel"
 	keyMACs                             = "macs"
 	keyNoHostAuthenticationForLocalhost = "nohostauthenticationforlocalhost"
@@ -103,14 +106,11 @@ const (
 	keyPermitLocalCommand               = "permitlocalcommand"
 	keyPKCS11Provider                   = "pkcs11provider"
 	keyPreferredAuthentications         = "preferredauthentications"
-	keyProxyCommand                     = "proxycommand"
-	keyProxyJump                        = "proxyjump"
 	keyProxyUseFdpass                   = "proxyusefdpass"
 	keyPubkeyAcceptedKeyTypes           = "pubkeyacceptedkeytypes"
 	keyPubkeyAuthentication             = "pubkeyauthentication"
 	keyRekeyLimit                       = "rekeylimit"
 	keyRemoteCommand                    = "remotecommand"
-	keyRemoteForward                    = "remoteforward"
 	keyRequestTTY                       = "requesttty"
 	keyRevokeHostKeys                   = "revokehostkeys"
 	keyServerAliveCountMax              = "serveralivecountmax"
@@ -132,6 +132,8 @@ const (
 	ValueAcceptNew = `accept-new`
 	ValueAlways    = `always`
 	ValueAsk       = `ask`
+	ValueAuto      = `auto`
+	ValueAutoAsk   = `autoask`
 	ValueConfirm   = `confirm`
 	ValueOff       = `off`
 	ValueNo        = `no`
@@ -203,6 +205,10 @@ type Section struct {
 	// env contains the key and value from SetEnv field.
 	env map[string]string
 
+	// cfg is the Config where this section belong, used to get the
+	// Section for jump host in ProxyJump.
+	cfg *Config
+
 	// name contains the raw value after Host or Match.
 	name string
 
@@ -224,6 +230,12 @@ type Section struct {
 	knownHostsFile  []string
 	sendEnv         []string
 
+	// List of raw value for DynamicForward, LocalForward, and
+	// RemoteForward.
+	dynamicForward []string
+	localForward   []string
+	remoteForward  []string
+
 	// Patterns for Host section.
 	patterns []*pattern
 
@@ -251,6 +263,7 @@ func NewSection(cfg *Config, name string) (section *Section) {
 	}
 
 	if cfg != nil {
+		section.cfg = cfg
 		section.dir = cfg.dir
 		section.homeDir = cfg.homeDir
 		section.WorkingDir = cfg.workDir
@@ -314,6 +327,31 @@ func (section *Section) CertificateFile() []string {
 	return section.certificateFile
 }
 
+// ControlMaster return true if the connection to the remote host can be
+// shared by multiple clients, where KeyControlMaster set to "yes",
+// "ask", "auto", or "autoask".
+func (section *Section) ControlMaster() bool {
+	switch section.Field[KeyControlMaster] {
+	case ValueYes, ValueAsk, ValueAuto, ValueAutoAsk:
+		return true
+	}
+	return false
+}
+
+// DynamicForward return list of local address, in the format "host:port",
+// for dynamic port forwarding, set from KeyDynamicForward.
+func (section *Section) DynamicForward() (listAddr []string) {
+	var (
+		raw  string
+		addr string
+	)
+	for _, raw = range section.dynamicForward {
+		addr, _ = parseForwardListen(KeyDynamicForward, raw)
+		listAddr = append(listAddr, addr)
+	}
+	return listAddr
+}
+
 // Environments return system and/or custom environment that will be passed
 // to remote machine.
 // The key and value is derived from "SendEnv" and "SetEnv".
@@ -363,6 +401,29 @@ func (section *Section) FieldInt64(key string) (val int64) {
 	return val
 }
 
+// ForwardAgent return the path to the agent socket that will be forwarded
+// to the remote host, set from KeyForwardAgent.
+//
+// There are four possible value: "yes", "no", <$STRING>, or <PATH>.
+// If "yes", the socket path is taken from [Section.IdentityAgent].
+// If value start with "$", then the socket path is set based on value of
+// that environment variable.
+//
+// It will return empty string if ForwardAgent is not set or set to "no".
+func (section *Section) ForwardAgent() string {
+	var value = section.Field[KeyForwardAgent]
+	switch value {
+	case ``, ValueNo:
+		return ``
+	case ValueYes:
+		return section.IdentityAgent()
+	}
+	if value[0] == '$' {
+		return os.Getenv(value[1:])
+	}
+	return section.pathUnfold(value)
+}
+
 // Hostname return the hostname of this section.
 func (section *Section) Hostname() string {
 	return section.Field[KeyHostname]
@@ -398,11 +459,113 @@ func (section *Section) IdentityAgent() string {
 	return value
 }
 
+// LocalForward return list of [Forward] set from KeyLocalForward.
+// The Listen address is on local host and the Connect address is
+// connected from the remote host.
+func (section *Section) LocalForward() (listFwd []Forward) {
+	var (
+		raw string
+		fwd Forward
+	)
+	for _, raw = range section.localForward {
+		fwd, _ = parseForward(KeyLocalForward, raw)
+		listFwd = append(listFwd, fwd)
+	}
+	return listFwd
+}
+
 // Port return the remote machine port of this section.
 func (section *Section) Port() string {
 	return section.Field[KeyPort]
 }
 
+// ProxyCommand return the command to connect to the remote host, set
+// from KeyProxyCommand.
+// The following tokens in command are expanded: "%h" with Hostname, "%p"
+// with Port, "%r" with User, and "%%" with "%".
+//
+// It will return empty string if ProxyCommand is not set or set to
+// "none".
+func (section *Section) ProxyCommand() string {
+	var value = section.Field[KeyProxyCommand]
+	if value == `` || value == ValueNone {
+		return ``
+	}
+
+	var replacer = strings.NewReplacer(
+		`%%`, `%`,
+		`%h`, section.Hostname(),
+		`%p`, section.Port(),
+		`%r`, section.User(),
+	)
+	return replacer.Replace(value)
+}
+
+// ProxyJump return the list of jump hosts set from KeyProxyJump, in the
+// order of connection.
+//
+// Each jump host is returned as Section.
+// If this section is created from [Config], the jump host Section is taken
+// from [Config.Get]; otherwise it inherit the User, IdentityAgent,
+// IdentityFile, and UserKnownHostsFile from this section.
+// The user and port in jump host value override the User and Port in the
+// Section.
+// The ProxyJump and ProxyCommand in jump host Section are removed, since
+// the connection to jump host is made through the previous jump host.
+func (section *Section) ProxyJump() (jumps []*Section) {
+	var listJump, _ = parseProxyJump(section.Field[KeyProxyJump])
+	if len(listJump) == 0 {
+		return nil
+	}
+
+	var (
+		jh   jumpHost
+		jump *Section
+	)
+	for _, jh = range listJump {
+		if section.cfg != nil {
+			jump = section.cfg.Get(jh.host)
+		} else {
+			jump = NewSection(nil, jh.host)
+			jump.dir = section.dir
+			jump.homeDir = section.homeDir
+			jump.WorkingDir = section.WorkingDir
+			jump.IdentityFile = append(jump.IdentityFile, section.IdentityFile...)
+			jump.knownHostsFile = append(jump.knownHostsFile, section.knownHostsFile...)
+			jump.Field[KeyUser] = section.User()
+			jump.Field[KeyIdentityAgent] = section.Field[KeyIdentityAgent]
+			jump.setDefaults()
+			jump.Field[KeyHostname] = jh.host
+		}
+		delete(jump.Field, KeyProxyJump)
+		delete(jump.Field, KeyProxyCommand)
+
+		if len(jh.user) != 0 {
+			jump.Field[KeyUser] = jh.user
+		}
+		if len(jh.port) != 0 {
+			jump.Field[KeyPort] = jh.port
+		}
+		jumps = append(jumps, jump)
+	}
+	return jumps
+}
+
+// RemoteForward return list of [Forward] set from KeyRemoteForward.
+// The Listen address is on remote host and the Connect address is
+// connected from the local host.
+func (section *Section) RemoteForward() (listFwd []Forward) {
+	var (
+		raw string
+		fwd Forward
+	)
+	for _, raw = range section.remoteForward {
+		fwd, _ = parseForward(KeyRemoteForward, raw)
+		listFwd = append(listFwd, fwd)
+	}
+	return listFwd
+}
+
 // Signers convert the IdentityFile to ssh.Signer for authentication using
 // PublicKey.
 //
@@ -498,6 +661,9 @@ func (section *Section) setDefaults() {
 		section.knownHostsFile = defaultUserKnownHostsFile()
 	}
 	for x, file = range section.knownHostsFile {
+		if file == ValueNone {
+			continue
+		}
 		section.knownHostsFile[x] = section.pathUnfold(file)
 	}
 
@@ -529,6 +695,9 @@ func (section *Section) merge(other *Section) {
 	section.IdentityFile = append(section.IdentityFile, other.IdentityFile...)
 	section.knownHostsFile = append(section.knownHostsFile, other.knownHostsFile...)
 	section.sendEnv = append(section.sendEnv, other.sendEnv...)
+	section.dynamicForward = append(section.dynamicForward, other.dynamicForward...)
+	section.localForward = append(section.localForward, other.localForward...)
+	section.remoteForward = append(section.remoteForward, other.remoteForward...)
 }
 
 // Set the section field by raw key and value.
@@ -573,6 +742,16 @@ func (section *Section) Set(key, value string) (err error) {
 		_, err = strconv.Atoi(value)
 	case KeyConnectTimeout:
 		_, err = strconv.Atoi(value)
+	case KeyControlMaster:
+		err = validateControlMaster(value)
+
+	case KeyDynamicForward:
+		_, err = parseForwardListen(key, value)
+		if err == nil {
+			section.dynamicForward = append(section.dynamicForward, value)
+		}
+
+	case KeyForwardAgent:
 
 	case KeyIdentityAgent:
 
@@ -581,9 +760,26 @@ func (section *Section) Set(key, value string) (err error) {
 
 	case KeyHostname:
 		value = strings.ToLower(value)
+
+	case KeyLocalForward:
+		_, err = parseForward(key, value)
+		if err == nil {
+			section.localForward = append(section.localForward, value)
+		}
+
 	case KeyPort:
 		_, err = strconv.Atoi(value)
 
+	case KeyProxyCommand:
+	case KeyProxyJump:
+		_, err = parseProxyJump(value)
+
+	case KeyRemoteForward:
+		_, err = parseForward(key, value)
+		if err == nil {
+			section.remoteForward = append(section.remoteForward, value)
+		}
+
 	case KeySendEnv:
 		section.sendEnv = append(section.sendEnv, value)
 	case KeySetEnv:
@@ -677,6 +873,17 @@ func (section *Section) MarshalText() (text []byte, err error) {
 			}
 			continue
 		}
+		var listValue = section.multipleValues(key)
+		if len(listValue) != 0 {
+			for _, val = range listValue {
+				buf.WriteString(`  `)
+				buf.WriteString(key)
+				buf.WriteByte(' ')
+				buf.WriteString(val)
+				buf.WriteByte('\n')
+			}
+			continue
+		}
 
 		buf.WriteString(`  `)
 		buf.WriteString(key)
@@ -730,6 +937,20 @@ func (section *Section) pathUnfold(in string) string {
 	return filepath.Join(section.dir, in)
 }
 
+// multipleValues return the list of raw values for key that can be set
+// multiple times.
+func (section *Section) multipleValues(key string) []string {
+	switch key {
+	case KeyDynamicForward:
+		return section.dynamicForward
+	case KeyLocalForward:
+		return section.localForward
+	case KeyRemoteForward:
+		return section.remoteForward
+	}
+	return nil
+}
+
 // setEnv set the Environments with key and value of format "KEY=VALUE".
 func (section *Section) setEnv(env string) {
 	kv := strings.SplitN(env, "=", 2)
@@ -754,6 +975,15 @@ func validateAddKeysToAgent(val string) (err error) {
 	return nil
 }
 
+func validateControlMaster(val string) (err error) {
+	switch val {
+	case ValueAsk, ValueAuto, ValueAutoAsk, ValueNo, ValueYes:
+	default:
+		return fmt.Errorf(`%s: invalid value %q`, KeyControlMaster, val)
+	}
+	return nil
+}
+
 func validateAddressFamily(val string) (err error) {
 	switch val {
 	case ValueAny, ValueInet, ValueInet6:
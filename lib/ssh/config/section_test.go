@@ -179,3 +179,184 @@ func TestSection_UserKnownHostsFile(t *testing.T) {
 		test.Assert(t, c.value, c.exp, section.UserKnownHostsFile())
 	}
 }
+
+func TestSection_forward(t *testing.T) {
+	type testCase struct {
+		key      string
+		value    string
+		expError string
+	}
+
+	var listCase = []testCase{{
+		key:   KeyLocalForward,
+		value: `8080 localhost:80`,
+	}, {
+		key:   KeyLocalForward,
+		value: `*:8081 [::1]:81`,
+	}, {
+		key:   KeyRemoteForward,
+		value: `[::1]:9090 127.0.0.1:90`,
+	}, {
+		key:   KeyDynamicForward,
+		value: `127.0.0.1:1080`,
+	}, {
+		key:      KeyLocalForward,
+		value:    `8080`,
+		expError: `localforward: invalid value "8080"`,
+	}, {
+		key:      KeyLocalForward,
+		value:    `8080 localhost`,
+		expError: `localforward: invalid address "localhost": address localhost: missing port in address`,
+	}, {
+		key:      KeyRemoteForward,
+		value:    `x:y localhost:80`,
+		expError: `remoteforward: invalid port "y"`,
+	}, {
+		key:      KeyDynamicForward,
+		value:    `70000`,
+		expError: `dynamicforward: invalid port "70000"`,
+	}}
+
+	var (
+		section = NewSection(nil, `test`)
+
+		c   testCase
+		err error
+	)
+	for _, c = range listCase {
+		err = section.Set(c.key, c.value)
+		if err != nil {
+			test.Assert(t, c.value, c.expError, err.Error())
+			continue
+		}
+		test.Assert(t, c.value+`: error`, c.expError, ``)
+	}
+
+	var expLocal = []Forward{{
+		Listen:  `localhost:8080`,
+		Connect: `localhost:80`,
+	}, {
+		Listen:  `:8081`,
+		Connect: `[::1]:81`,
+	}}
+	test.Assert(t, `LocalForward`, expLocal, section.LocalForward())
+
+	var expRemote = []Forward{{
+		Listen:  `[::1]:9090`,
+		Connect: `127.0.0.1:90`,
+	}}
+	test.Assert(t, `RemoteForward`, expRemote, section.RemoteForward())
+	test.Assert(t, `DynamicForward`, []string{`127.0.0.1:1080`}, section.DynamicForward())
+
+	var (
+		exp = `Host test
+  dynamicforward 127.0.0.1:1080
+  localforward 8080 localhost:80
+  localforward *:8081 [::1]:81
+  remoteforward [::1]:9090 127.0.0.1:90
+`
+		got []byte
+	)
+	got, _ = section.MarshalText()
+	test.Assert(t, `MarshalText`, exp, string(got))
+}
+
+func TestSection_ProxyCommand(t *testing.T) {
+	var section = NewSection(nil, `test`)
+
+	_ = section.Set(KeyHostname, `example.com`)
+	_ = section.Set(KeyPort, `2222`)
+	_ = section.Set(KeyUser, `alice`)
+	_ = section.Set(KeyProxyCommand, `nc -X 5 -x proxy:1080 %h %p # %r 100%%`)
+
+	test.Assert(t, `ProxyCommand`,
+		`nc -X 5 -x proxy:1080 example.com 2222 # alice 100%`,
+		section.ProxyCommand())
+
+	_ = section.Set(KeyProxyCommand, ValueNone)
+	test.Assert(t, `ProxyCommand none`, ``, section.ProxyCommand())
+}
+
+func TestSection_ProxyJump(t *testing.T) {
+	type testCase struct {
+		value    string
+		expError string
+		exp      []string
+	}
+
+	var listCase = []testCase{{
+		value: `jump1`,
+		exp:   []string{`alice@jump1:22`},
+	}, {
+		value: `bob@jump1:2222, ssh://[::1]:22,carol@10.0.0.1`,
+		exp: []string{
+			`bob@jump1:2222`,
+			`alice@::1:22`,
+			`carol@10.0.0.1:22`,
+		},
+	}, {
+		value: ValueNone,
+	}, {
+		value:    `bob@:22`,
+		expError: `proxyjump: empty host in "bob@:22"`,
+	}, {
+		value:    `jump1:ssh`,
+		expError: `proxyjump: invalid port "ssh"`,
+	}}
+
+	var (
+		section = NewSection(nil, `test`)
+
+		c     testCase
+		jumps []*Section
+		jump  *Section
+		got   []string
+		err   error
+	)
+
+	_ = section.Set(KeyUser, `alice`)
+	_ = section.Set(KeyIdentityFile, `/tmp/id_ed25519`)
+	section.setDefaults()
+
+	for _, c = range listCase {
+		err = section.Set(KeyProxyJump, c.value)
+		if err != nil {
+			test.Assert(t, c.value, c.expError, err.Error())
+			continue
+		}
+
+		jumps = section.ProxyJump()
+		got = nil
+		for _, jump = range jumps {
+			got = append(got, jump.User()+`@`+jump.Hostname()+`:`+jump.Port())
+			test.Assert(t, c.value+`: IdentityFile`, section.IdentityFile, jump.IdentityFile)
+			test.Assert(t, c.value+`: ProxyJump`, ``, jump.Field[KeyProxyJump])
+		}
+		test.Assert(t, c.value, c.exp, got)
+	}
+}
+
+func TestSection_ProxyJump_config(t *testing.T) {
+	var (
+		cfg *Config
+		err error
+	)
+
+	cfg, err = Load(`./testdata/config_proxyjump`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		section = cfg.Get(`target`)
+		jumps   = section.ProxyJump()
+		jump    *Section
+		got     []string
+	)
+	for _, jump = range jumps {
+		got = append(got, jump.User()+`@`+jump.Hostname()+`:`+jump.Port())
+		test.Assert(t, `IdentityFile`, cfg.homeDir+`/.ssh/jump`, jump.IdentityFile[0])
+		test.Assert(t, `ProxyJump`, ``, jump.Field[KeyProxyJump])
+	}
+	test.Assert(t, `ProxyJump`, []string{`bob@jump:2222`, `jumpuser@jump2:22`}, got)
+}
@@ -0,0 +1,120 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssh
+
+import (
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// controlMaster contains the connection that is shared by multiple Client,
+// in the same way as ControlMaster in OpenSSH but only within the same
+// process.
+type controlMaster struct {
+	client *ssh.Client
+
+	// proxy is the client to jump host of the shared connection.
+	proxy *Client
+
+	// key is the user, host, and port of remote address.
+	key string
+
+	identityFile string
+
+	// nref is the number of Client that use the shared connection.
+	nref int
+
+	isForwardAgent bool
+}
+
+var (
+	// controlMasters contains the shared connections indexed by
+	// "user@host:port".
+	controlMasters = map[string]*controlMaster{}
+
+	controlMastersMtx sync.Mutex
+)
+
+// release decrement the number of Client that use the shared connection
+// and close it if there is no Client using it.
+func (master *controlMaster) release() (err error) {
+	controlMastersMtx.Lock()
+	master.nref--
+	if master.nref > 0 {
+		controlMastersMtx.Unlock()
+		return nil
+	}
+	if controlMasters[master.key] == master {
+		delete(controlMasters, master.key)
+	}
+	controlMastersMtx.Unlock()
+
+	err = master.client.Close()
+	if master.proxy != nil {
+		var errProxy = master.proxy.Close()
+		if err == nil {
+			err = errProxy
+		}
+	}
+	return err
+}
+
+// setControlMaster register the connection in Client as shared
+// connection.
+// If another Client has been registered with the same key, the connection
+// is not shared.
+func (cl *Client) setControlMaster() {
+	var master = &controlMaster{
+		client:         cl.Client,
+		proxy:          cl.proxy,
+		key:            cl.String(),
+		identityFile:   cl.identityFile,
+		nref:           1,
+		isForwardAgent: cl.isForwardAgent,
+	}
+
+	controlMastersMtx.Lock()
+	defer controlMastersMtx.Unlock()
+
+	if controlMasters[master.key] != nil {
+		return
+	}
+	controlMasters[master.key] = master
+	cl.master = master
+	cl.proxy = nil
+
+	// Unregister the shared connection once its closed by remote, so
+	// the next Client will create new connection.
+	go func() {
+		_ = master.client.Wait()
+
+		controlMastersMtx.Lock()
+		if controlMasters[master.key] == master {
+			delete(controlMasters, master.key)
+		}
+		controlMastersMtx.Unlock()
+	}()
+}
+
+// useControlMaster set the Client to use the shared connection with the
+// same user, host, and port, if its exist.
+func (cl *Client) useControlMaster() bool {
+	controlMastersMtx.Lock()
+	defer controlMastersMtx.Unlock()
+
+	var master = controlMasters[cl.String()]
+	if master == nil {
+		return false
+	}
+	master.nref++
+
+	cl.Client = master.client
+	cl.identityFile = master.identityFile
+	cl.isForwardAgent = master.isForwardAgent && len(cl.section.ForwardAgent()) != 0
+	cl.master = master
+
+	return true
+}
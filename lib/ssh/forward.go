@@ -0,0 +1,269 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/shuLhan/share/lib/ssh/config"
+)
+
+// Forwarder forward the connection accepted on listener to the other
+// side of SSH connection.
+// It is created by [Client.LocalForward], [Client.RemoteForward], or
+// [Client.DynamicForward].
+type Forwarder struct {
+	ln net.Listener
+
+	// dial connect to the destination address.
+	dial func(addr string) (net.Conn, error)
+
+	// conns contains the active connections.
+	conns map[net.Conn]struct{}
+
+	// connect is the destination address.
+	// If its empty, the destination is read from SOCKS5 request.
+	connect string
+
+	wg  sync.WaitGroup
+	mtx sync.Mutex
+
+	isClosed bool
+}
+
+// DynamicForward accept the SOCKS5 connection on local address and forward
+// it to the address requested by the SOCKS5 client, from the remote host.
+// Only the CONNECT command without authentication is supported.
+func (cl *Client) DynamicForward(localAddr string) (fwd *Forwarder, err error) {
+	var ln net.Listener
+
+	ln, err = net.Listen(`tcp`, localAddr)
+	if err != nil {
+		return nil, fmt.Errorf(`DynamicForward: %w`, err)
+	}
+	fwd = newForwarder(ln, ``, cl.dialRemote)
+	return fwd, nil
+}
+
+// LocalForward accept the connection on local address and forward it to
+// the remote address, connected from the remote host.
+func (cl *Client) LocalForward(localAddr, remoteAddr string) (fwd *Forwarder, err error) {
+	var ln net.Listener
+
+	ln, err = net.Listen(`tcp`, localAddr)
+	if err != nil {
+		return nil, fmt.Errorf(`LocalForward: %w`, err)
+	}
+	fwd = newForwarder(ln, remoteAddr, cl.dialRemote)
+	return fwd, nil
+}
+
+// RemoteForward accept the connection on remote address, in the remote
+// host, and forward it to the local address.
+func (cl *Client) RemoteForward(remoteAddr, localAddr string) (fwd *Forwarder, err error) {
+	var ln net.Listener
+
+	ln, err = cl.Client.Listen(`tcp`, remoteAddr)
+	if err != nil {
+		return nil, fmt.Errorf(`RemoteForward: %w`, err)
+	}
+	fwd = newForwarder(ln, localAddr, dialLocal)
+	return fwd, nil
+}
+
+// StartForwarding start all of DynamicForward, LocalForward, and
+// RemoteForward defined in the Section.
+// On failure, all of the forwarding that has been started will be
+// closed.
+func (cl *Client) StartForwarding() (listFwd []*Forwarder, err error) {
+	var (
+		fwd     *Forwarder
+		cfgFwd  config.Forward
+		addr    string
+		errStop error
+	)
+	for _, addr = range cl.section.DynamicForward() {
+		fwd, err = cl.DynamicForward(addr)
+		if err != nil {
+			goto fail
+		}
+		listFwd = append(listFwd, fwd)
+	}
+	for _, cfgFwd = range cl.section.LocalForward() {
+		fwd, err = cl.LocalForward(cfgFwd.Listen, cfgFwd.Connect)
+		if err != nil {
+			goto fail
+		}
+		listFwd = append(listFwd, fwd)
+	}
+	for _, cfgFwd = range cl.section.RemoteForward() {
+		fwd, err = cl.RemoteForward(cfgFwd.Listen, cfgFwd.Connect)
+		if err != nil {
+			goto fail
+		}
+		listFwd = append(listFwd, fwd)
+	}
+	return listFwd, nil
+
+fail:
+	for _, fwd = range listFwd {
+		errStop = fwd.Close()
+		if errStop != nil {
+			err = fmt.Errorf(`%w: %s`, err, errStop)
+		}
+	}
+	return nil, fmt.Errorf(`StartForwarding: %w`, err)
+}
+
+func (cl *Client) dialRemote(addr string) (net.Conn, error) {
+	return cl.Client.Dial(`tcp`, addr)
+}
+
+func dialLocal(addr string) (net.Conn, error) {
+	return net.Dial(`tcp`, addr)
+}
+
+func newForwarder(ln net.Listener, connect string, dial func(string) (net.Conn, error)) (fwd *Forwarder) {
+	fwd = &Forwarder{
+		ln:      ln,
+		dial:    dial,
+		conns:   map[net.Conn]struct{}{},
+		connect: connect,
+	}
+	fwd.wg.Add(1)
+	go fwd.serve()
+	return fwd
+}
+
+// Addr return the address where the connection is accepted.
+func (fwd *Forwarder) Addr() net.Addr {
+	return fwd.ln.Addr()
+}
+
+// Close stop accepting new connection, close all of the active
+// connections, and wait until all of them has been finished.
+func (fwd *Forwarder) Close() (err error) {
+	fwd.mtx.Lock()
+	if fwd.isClosed {
+		fwd.mtx.Unlock()
+		return nil
+	}
+	fwd.isClosed = true
+
+	err = fwd.ln.Close()
+
+	var conn net.Conn
+	for conn = range fwd.conns {
+		_ = conn.Close()
+	}
+	fwd.mtx.Unlock()
+
+	fwd.wg.Wait()
+
+	if err != nil {
+		return fmt.Errorf(`Close: %w`, err)
+	}
+	return nil
+}
+
+func (fwd *Forwarder) serve() {
+	defer fwd.wg.Done()
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	for {
+		conn, err = fwd.ln.Accept()
+		if err != nil {
+			return
+		}
+		if !fwd.track(conn) {
+			_ = conn.Close()
+			return
+		}
+		fwd.wg.Add(1)
+		go fwd.handle(conn)
+	}
+}
+
+// handle connect to the destination address and copy the data between
+// conn and destination.
+func (fwd *Forwarder) handle(conn net.Conn) {
+	defer fwd.wg.Done()
+	defer fwd.untrack(conn)
+
+	var (
+		addr = fwd.connect
+		err  error
+	)
+	if len(addr) == 0 {
+		addr, err = socks5Handshake(conn)
+		if err != nil {
+			return
+		}
+	}
+
+	var dst net.Conn
+
+	dst, err = fwd.dial(addr)
+	if len(fwd.connect) == 0 {
+		var errReply = socks5Reply(conn, err)
+		if errReply != nil && err == nil {
+			_ = dst.Close()
+			return
+		}
+	}
+	if err != nil {
+		return
+	}
+	if !fwd.track(dst) {
+		_ = dst.Close()
+		return
+	}
+	defer fwd.untrack(dst)
+
+	var done = make(chan struct{}, 2)
+
+	go func() {
+		_, _ = io.Copy(dst, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, dst)
+		done <- struct{}{}
+	}()
+
+	// Close both connections once one of the side finished.
+	<-done
+	_ = conn.Close()
+	_ = dst.Close()
+	<-done
+}
+
+// track add the conn into active connections.
+// It will return false if the Forwarder has been closed.
+func (fwd *Forwarder) track(conn net.Conn) bool {
+	fwd.mtx.Lock()
+	defer fwd.mtx.Unlock()
+
+	if fwd.isClosed {
+		return false
+	}
+	fwd.conns[conn] = struct{}{}
+	return true
+}
+
+// untrack close and remove the conn from active connections.
+func (fwd *Forwarder) untrack(conn net.Conn) {
+	_ = conn.Close()
+
+	fwd.mtx.Lock()
+	delete(fwd.conns, conn)
+	fwd.mtx.Unlock()
+}
@@ -0,0 +1,228 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"golang.org/x/net/proxy"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+// newEchoServer run the TCP server that write back any data it receive.
+func newEchoServer(t *testing.T) (addr string) {
+	var (
+		ln  net.Listener
+		err error
+	)
+	ln, err = net.Listen(`tcp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		var (
+			conn    net.Conn
+			errConn error
+		)
+		for {
+			conn, errConn = ln.Accept()
+			if errConn != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				_, _ = io.Copy(conn, conn)
+				_ = conn.Close()
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// testEcho write the msg into conn and return the data echoed back.
+func testEcho(t *testing.T, conn net.Conn, msg string) string {
+	var err error
+
+	_, err = io.WriteString(conn, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got = make([]byte, len(msg))
+
+	_, err = io.ReadFull(conn, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(got)
+}
+
+// newTestClient create Client that connect to testServer srv.
+func newTestClient(t *testing.T, srv *testServer, extra string) (cl *Client) {
+	var (
+		cfg = newTestConfig(t, fmt.Sprintf("Host target\n\tHostname 127.0.0.1\n\tPort %s\n%s", srv.port(), extra))
+		err error
+	)
+	cl, err = NewClientInteractive(cfg.Get(`target`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cl.Close() })
+	return cl
+}
+
+func TestClient_LocalForward(t *testing.T) {
+	var (
+		echoAddr = newEchoServer(t)
+		cl       = newTestClient(t, newTestServer(t, `target`), ``)
+
+		fwd *Forwarder
+		err error
+	)
+	fwd, err = cl.LocalForward(`127.0.0.1:0`, echoAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var conn net.Conn
+
+	conn, err = net.Dial(`tcp`, fwd.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `LocalForward`, `hello`, testEcho(t, conn, `hello`))
+
+	// Closing the Forwarder should close the active connection.
+	err = fwd.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = conn.Read(make([]byte, 1))
+	test.Assert(t, `Read after Close`, io.EOF, err)
+	_ = conn.Close()
+}
+
+func TestClient_RemoteForward(t *testing.T) {
+	var (
+		echoAddr = newEchoServer(t)
+		cl       = newTestClient(t, newTestServer(t, `target`), ``)
+
+		fwd *Forwarder
+		err error
+	)
+	fwd, err = cl.RemoteForward(`127.0.0.1:0`, echoAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var conn net.Conn
+
+	// The test server listen on the same host.
+	conn, err = net.Dial(`tcp`, fwd.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `RemoteForward`, `hello`, testEcho(t, conn, `hello`))
+
+	_ = conn.Close()
+
+	err = fwd.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_DynamicForward(t *testing.T) {
+	var (
+		echoAddr = newEchoServer(t)
+		cl       = newTestClient(t, newTestServer(t, `target`), ``)
+
+		fwd *Forwarder
+		err error
+	)
+	fwd, err = cl.DynamicForward(`127.0.0.1:0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fwd.Close()
+
+	var socks proxy.Dialer
+
+	socks, err = proxy.SOCKS5(`tcp`, fwd.Addr().String(), nil, proxy.Direct)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var conn net.Conn
+
+	conn, err = socks.Dial(`tcp`, echoAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Assert(t, `DynamicForward`, `hello`, testEcho(t, conn, `hello`))
+	_ = conn.Close()
+
+	// Connecting to closed port should return failure reply.
+	var ln net.Listener
+
+	ln, err = net.Listen(`tcp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var closedAddr = ln.Addr().String()
+	_ = ln.Close()
+
+	_, err = socks.Dial(`tcp`, closedAddr)
+	if err == nil {
+		t.Fatalf(`DynamicForward: expecting error on closed port %s`, closedAddr)
+	}
+}
+
+func TestClient_StartForwarding(t *testing.T) {
+	var (
+		echoAddr = newEchoServer(t)
+		srv      = newTestServer(t, `target`)
+		cl       = newTestClient(t, srv, fmt.Sprintf("\tDynamicForward 127.0.0.1:0\n\tLocalForward 127.0.0.1:0 %s\n\tRemoteForward 127.0.0.1:0 %s\n", echoAddr, echoAddr))
+
+		listFwd []*Forwarder
+		err     error
+	)
+	listFwd, err = cl.StartForwarding()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `number of Forwarder`, 3, len(listFwd))
+
+	var (
+		fwd  *Forwarder
+		conn net.Conn
+	)
+	// Skip the first one, the DynamicForward.
+	for _, fwd = range listFwd[1:] {
+		conn, err = net.Dial(`tcp`, fwd.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.Assert(t, fwd.Addr().String(), `hello`, testEcho(t, conn, `hello`))
+		_ = conn.Close()
+	}
+
+	for _, fwd = range listFwd {
+		err = fwd.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
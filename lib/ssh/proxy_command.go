@@ -0,0 +1,92 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// proxyCommandConn implement the [net.Conn] using the standard input and
+// output of ProxyCommand.
+type proxyCommandConn struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out io.ReadCloser
+}
+
+// newProxyCommandConn run the command using shell and return the
+// connection to its standard input and output.
+// The standard error of command is written to [os.Stderr].
+func newProxyCommandConn(command, workDir string) (conn *proxyCommandConn, err error) {
+	var logp = `ProxyCommand`
+
+	conn = &proxyCommandConn{
+		cmd: exec.Command(`/bin/sh`, `-c`, command),
+	}
+	conn.cmd.Dir = workDir
+	conn.cmd.Stderr = os.Stderr
+
+	conn.in, err = conn.cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	conn.out, err = conn.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	err = conn.cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf(`%s %q: %w`, logp, command, err)
+	}
+	return conn, nil
+}
+
+func (conn *proxyCommandConn) Read(b []byte) (int, error) {
+	return conn.out.Read(b)
+}
+
+func (conn *proxyCommandConn) Write(b []byte) (int, error) {
+	return conn.in.Write(b)
+}
+
+// Close the standard input and stop the command.
+func (conn *proxyCommandConn) Close() (err error) {
+	err = conn.in.Close()
+	_ = conn.cmd.Process.Kill()
+	_ = conn.cmd.Wait()
+	return err
+}
+
+// LocalAddr return zero TCP address.
+func (conn *proxyCommandConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4zero}
+}
+
+// RemoteAddr return zero TCP address, so the host key is checked using
+// the host name.
+func (conn *proxyCommandConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4zero}
+}
+
+// SetDeadline is not supported, it always return nil.
+func (conn *proxyCommandConn) SetDeadline(time.Time) error {
+	return nil
+}
+
+// SetReadDeadline is not supported, it always return nil.
+func (conn *proxyCommandConn) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+// SetWriteDeadline is not supported, it always return nil.
+func (conn *proxyCommandConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
@@ -5,6 +5,7 @@
 package sftp
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -19,6 +20,10 @@ import (
 )
 
 // Client for SFTP.
+//
+// The Client is safe to be used concurrently.
+// Each request is sent without waiting for the response of previous
+// requests, and the response is passed to the request based on its ID.
 type Client struct {
 	sess *ssh.Session
 
@@ -27,13 +32,24 @@ type Client struct {
 	pipeOut io.Reader
 	pipeErr io.Reader
 
+	// pending contains the requests that wait for response, indexed by
+	// request ID.
+	// It will be set to nil once the connection to server closed.
+	pending map[uint32]chan *packet
+
+	// errLoop contains the error that cause the connection to server
+	// closed.
+	errLoop error
+
 	// The requestID is unique number that will be incremented by client,
 	// to prevent the same ID generated on concurrent operations.
 	requestID uint32
 
 	version uint32
 
-	mtxID sync.Mutex
+	mtxID      sync.Mutex
+	mtxPending sync.Mutex
+	mtxWrite   sync.Mutex
 }
 
 // NewClient create and initialize new client for SSH file transfer protocol.
@@ -78,6 +94,9 @@ func NewClient(sshc *ssh.Client) (cl *Client, err error) {
 		return nil, fmt.Errorf("%s: %w", logp, err)
 	}
 
+	cl.pending = make(map[uint32]chan *packet)
+	go cl.loop(cl.pipeOut)
+
 	return cl, nil
 }
 
@@ -85,10 +104,12 @@ func NewClient(sshc *ssh.Client) (cl *Client, err error) {
 func (cl *Client) Close() (err error) {
 	err = cl.sess.Close()
 
+	cl.mtxWrite.Lock()
 	cl.requestID = 0
 	cl.pipeErr = nil
 	cl.pipeOut = nil
 	cl.pipeIn = nil
+	cl.mtxWrite.Unlock()
 
 	if err != nil {
 		return fmt.Errorf(`Close: %w`, err)
@@ -181,42 +202,38 @@ func (cl *Client) Fstat(fh *FileHandle) (fa *FileAttrs, err error) {
 // Get copy remote file to local.
 // The local file will be created if its not exist; otherwise it will
 // truncated.
+// The remote file is read using several concurrent requests, see
+// [FileHandle.ReadAt].
 func (cl *Client) Get(remoteFile, localFile string) (err error) {
-	var (
-		logp   = "Get"
-		offset uint64
-	)
+	var logp = "Get"
 
 	fin, err := cl.Open(remoteFile)
 	if err != nil {
 		return fmt.Errorf("%s: %w", logp, err)
 	}
 
-	fout, err := os.Create(localFile)
+	fa, err := cl.Fstat(fin)
 	if err != nil {
+		_ = cl.CloseFile(fin)
 		return fmt.Errorf("%s: %w", logp, err)
 	}
 
-	var data []byte
-	for {
-		data, err = cl.Read(fin, offset)
-		if len(data) > 0 {
-			_, err = fout.Write(data)
-			if err != nil {
-				break
-			}
-		}
-		if err != nil {
-			break
-		}
-		offset += uint64(len(data))
+	fout, err := os.Create(localFile)
+	if err != nil {
+		_ = cl.CloseFile(fin)
+		return fmt.Errorf("%s: %w", logp, err)
 	}
-	if err != nil && !errors.Is(err, io.EOF) {
+
+	err = copyAt(fout, fin, 0, fa.Size())
+	if err != nil {
+		_ = fout.Close()
+		_ = cl.CloseFile(fin)
 		return fmt.Errorf("%s: %w", logp, err)
 	}
 
 	err = fout.Close()
 	if err != nil {
+		_ = cl.CloseFile(fin)
 		return fmt.Errorf("%s: %w", logp, err)
 	}
 
@@ -225,7 +242,7 @@ func (cl *Client) Get(remoteFile, localFile string) (err error) {
 		return fmt.Errorf("%s: %w", logp, err)
 	}
 
-	return err
+	return nil
 }
 
 // Lstat get the file attributes based on the remote file path.
@@ -376,6 +393,7 @@ func (cl *Client) OpenFile(remoteFile string, flags uint32, fa *FileAttrs) (fh *
 		return nil, errUnexpectedResponse(packetKindFxpStatus, res.kind)
 	}
 	fh = res.fh
+	fh.cl = cl
 	fh.remotePath = remoteFile
 	res.fh = nil
 	return fh, nil
@@ -399,12 +417,15 @@ func (cl *Client) Opendir(path string) (fh *FileHandle, err error) {
 		return nil, errUnexpectedResponse(packetKindFxpHandle, res.kind)
 	}
 	fh = res.fh
+	fh.cl = cl
 	fh.remotePath = path
 	res.fh = nil
 	return fh, nil
 }
 
 // Put local file to remote file.
+// The remote file is written using several concurrent requests, see
+// [FileHandle.WriteAt].
 func (cl *Client) Put(localFile, remoteFile string) (err error) {
 	logp := "Put"
 
@@ -412,6 +433,7 @@ func (cl *Client) Put(localFile, remoteFile string) (err error) {
 	if err != nil {
 		return fmt.Errorf("%s: %w", logp, err)
 	}
+	defer fin.Close()
 
 	finfo, err := fin.Stat()
 	if err != nil {
@@ -425,29 +447,9 @@ func (cl *Client) Put(localFile, remoteFile string) (err error) {
 		return fmt.Errorf("%s: %w", logp, err)
 	}
 
-	var (
-		offset uint64
-		data   = make([]byte, 32000)
-		n      int
-	)
-
-	n, err = fin.Read(data)
-	for n > 0 {
-		if err != nil {
-			break
-		}
-		err = cl.Write(fout, offset, data[:n])
-		if err != nil {
-			break
-		}
-		if n < len(data) {
-			break
-		}
-
-		offset += uint64(n)
-		n, err = fin.Read(data)
-	}
+	err = copyAt(fout, fin, 0, finfo.Size())
 	if err != nil {
+		_ = cl.CloseFile(fout)
 		return fmt.Errorf("%s: %w", logp, err)
 	}
 
@@ -462,10 +464,15 @@ func (cl *Client) Put(localFile, remoteFile string) (err error) {
 // Read the remote file using handle on specific offset.
 // On end-of-file it will return empty data with [io.EOF].
 func (cl *Client) Read(fh *FileHandle, offset uint64) (data []byte, err error) {
+	return cl.readData(fh, offset, maxPacketRead)
+}
+
+// readData read at most length bytes of the remote file at offset.
+func (cl *Client) readData(fh *FileHandle, offset uint64, length uint32) (data []byte, err error) {
 	var (
 		logp    = "Read"
 		req     = cl.generatePacket()
-		payload = req.fxpRead(fh, offset, maxPacketRead)
+		payload = req.fxpRead(fh, offset, length)
 	)
 
 	res, err := cl.send(payload)
@@ -745,10 +752,18 @@ func (cl *Client) init() (err error) {
 	req := cl.generatePacket()
 	payload := req.fxpInit(defFxpVersion)
 
-	res, err := cl.send(payload)
+	_, err = cl.pipeIn.Write(payload)
+	if err != nil {
+		return fmt.Errorf("%s: Write: %w", logp, err)
+	}
+
+	res, err := readResponse(cl.pipeOut)
 	if err != nil {
 		return fmt.Errorf("%s: %w", logp, err)
 	}
+	if res.kind != packetKindFxpVersion {
+		return errUnexpectedResponse(packetKindFxpVersion, res.kind)
+	}
 
 	cl.version = res.version
 	cl.exts = res.exts
@@ -761,49 +776,103 @@ func (cl *Client) init() (err error) {
 	return nil
 }
 
-func (cl *Client) read() (res []byte, err error) {
+// loop read the response from server and pass it to the pending request
+// with the same ID.
+// The response with unknown ID is ignored.
+// Once the reading failed, all of the pending requests will receive nil
+// response.
+func (cl *Client) loop(pipeOut io.Reader) {
 	var (
-		logp  = "read"
-		block = make([]byte, 1024)
+		res *packet
+		ch  chan *packet
+		err error
 	)
-
-	n, err := cl.pipeOut.Read(block)
-	for n > 0 {
-		res = append(res, block[:n]...)
+	for {
+		res, err = readResponse(pipeOut)
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				break
-			}
-			return nil, fmt.Errorf("%s: %w", logp, err)
-		}
-		if n < len(block) {
 			break
 		}
-		n, err = cl.pipeOut.Read(block)
+
+		cl.mtxPending.Lock()
+		ch = cl.pending[res.requestID]
+		delete(cl.pending, res.requestID)
+		cl.mtxPending.Unlock()
+
+		if ch != nil {
+			ch <- res
+		}
 	}
-	return res, nil
+
+	if errors.Is(err, io.EOF) {
+		err = ErrConnectionLost
+	} else {
+		err = fmt.Errorf("%w: %s", ErrConnectionLost, err)
+	}
+
+	cl.mtxPending.Lock()
+	cl.errLoop = err
+	for _, ch = range cl.pending {
+		close(ch)
+	}
+	cl.pending = nil
+	cl.mtxPending.Unlock()
 }
 
+// send the request payload to server and wait for its response.
 func (cl *Client) send(payload []byte) (res *packet, err error) {
 	var (
 		logp = "send"
+		id   = binary.BigEndian.Uint32(payload[5:9])
+		ch   = make(chan *packet, 1)
 	)
 
-	_, err = cl.pipeIn.Write(payload)
+	cl.mtxPending.Lock()
+	if cl.pending == nil {
+		err = cl.errLoop
+		cl.mtxPending.Unlock()
+		if err == nil {
+			err = ErrNoConnection
+		}
+		return nil, fmt.Errorf("%s: %w", logp, err)
+	}
+	cl.pending[id] = ch
+	cl.mtxPending.Unlock()
+
+	cl.mtxWrite.Lock()
+	if cl.pipeIn == nil {
+		err = ErrNoConnection
+	} else {
+		_, err = cl.pipeIn.Write(payload)
+	}
+	cl.mtxWrite.Unlock()
 	if err != nil {
+		cl.mtxPending.Lock()
+		delete(cl.pending, id)
+		cl.mtxPending.Unlock()
 		return nil, fmt.Errorf("%s: Write: %w", logp, err)
 	}
 
-	payload, err = cl.read()
-	if err != nil {
+	res = <-ch
+	if res == nil {
+		cl.mtxPending.Lock()
+		err = cl.errLoop
+		cl.mtxPending.Unlock()
 		return nil, fmt.Errorf("%s: %w", logp, err)
 	}
+	return res, nil
+}
+
+// readResponse read and unpack one response packet from r.
+func readResponse(r io.Reader) (res *packet, err error) {
+	var payload []byte
 
-	// TODO: check the response ID.
-	res, err = unpackPacket(payload)
+	payload, err = readPacketFrom(r)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", logp, err)
+		return nil, err
+	}
+	res, err = unpackPacket(sealPacket(payload))
+	if err != nil {
+		return nil, err
 	}
-
 	return res, nil
 }
@@ -0,0 +1,496 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// SyncOptions define the options for [Client.Sync].
+type SyncOptions struct {
+	// Progress is called each time a block of file has been
+	// transferred, with the file path relative to the source
+	// directory, the number of bytes that has been transferred, and
+	// the file size.
+	// This field is optional.
+	Progress func(name string, n, size int64)
+
+	// Download define the direction of Sync.
+	// If its false, the files are copied from local directory to
+	// remote directory.
+	// If its true, the files are copied from remote directory to local
+	// directory.
+	Download bool
+
+	// Resume the transfer of file that has been partially copied,
+	// where the destination file is smaller than the source file.
+	// Instead of copying the whole file, only the remaining content,
+	// start from the size of destination file, is copied.
+	// The content that has been copied is not compared.
+	Resume bool
+}
+
+// syncer copy the directory recursively, between local and remote.
+type syncer struct {
+	cl   *Client
+	opts SyncOptions
+
+	// buf is the buffer to copy the content of file.
+	buf []byte
+
+	// force copy all files, without comparing the size and
+	// modification time.
+	force bool
+}
+
+// GetDir copy all files in remote directory into local directory,
+// recursively.
+// The local directory will be created if its not exist, but its parent
+// must exist.
+// Only the directory and regular file are copied, other file types like
+// symbolic link are skipped.
+func (cl *Client) GetDir(remoteDir, localDir string) (err error) {
+	var syn = cl.newSyncer(SyncOptions{Download: true})
+
+	syn.force = true
+
+	err = syn.getDir(remoteDir, localDir, ``)
+	if err != nil {
+		return fmt.Errorf(`GetDir: %w`, err)
+	}
+	return nil
+}
+
+// PutDir copy all files in local directory into remote directory,
+// recursively.
+// The remote directory will be created if its not exist, but its parent
+// must exist.
+// Only the directory and regular file are copied, other file types like
+// symbolic link are skipped.
+func (cl *Client) PutDir(localDir, remoteDir string) (err error) {
+	var syn = cl.newSyncer(SyncOptions{})
+
+	syn.force = true
+
+	err = syn.putDir(localDir, remoteDir, ``)
+	if err != nil {
+		return fmt.Errorf(`PutDir: %w`, err)
+	}
+	return nil
+}
+
+// Sync the files between local and remote directories, recursively, like
+// rsync.
+// The direction is defined by [SyncOptions] Download.
+//
+// The file is copied only if its not exist in destination, or if the
+// size or modification time is different with the source.
+// After the file has been copied, the modification time of destination
+// file is set to the source file, so the next Sync will skip it.
+// Files in destination that does not exist in source are not removed.
+//
+// Like [Client.GetDir] and [Client.PutDir], only the directory and
+// regular file are copied.
+func (cl *Client) Sync(localDir, remoteDir string, opts SyncOptions) (err error) {
+	var syn = cl.newSyncer(opts)
+
+	if opts.Download {
+		err = syn.getDir(remoteDir, localDir, ``)
+	} else {
+		err = syn.putDir(localDir, remoteDir, ``)
+	}
+	if err != nil {
+		return fmt.Errorf(`Sync: %w`, err)
+	}
+	return nil
+}
+
+func (cl *Client) newSyncer(opts SyncOptions) (syn *syncer) {
+	syn = &syncer{
+		cl:   cl,
+		opts: opts,
+		buf:  make([]byte, maxRequests*int(maxPacketRead)),
+	}
+	return syn
+}
+
+// getDir copy the remote directory into local directory.
+// The relDir is the path of directory relative to the source, used in
+// Progress.
+func (syn *syncer) getDir(remoteDir, localDir, relDir string) (err error) {
+	var fa *FileAttrs
+
+	fa, err = syn.cl.Stat(remoteDir)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, remoteDir, err)
+	}
+	if !fa.IsDir() {
+		return fmt.Errorf(`%s: not a directory`, remoteDir)
+	}
+
+	var fi fs.FileInfo
+
+	fi, err = os.Stat(localDir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		err = os.Mkdir(localDir, fa.Mode().Perm()|0700)
+		if err != nil {
+			return err
+		}
+	} else if !fi.IsDir() {
+		return fmt.Errorf(`%s: not a directory`, localDir)
+	}
+
+	var list []fs.DirEntry
+
+	list, err = syn.cl.readdirAll(remoteDir)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, remoteDir, err)
+	}
+
+	var (
+		de   fs.DirEntry
+		name string
+		info fs.FileInfo
+	)
+	for _, de = range list {
+		name = de.Name()
+		if name == `.` || name == `..` {
+			continue
+		}
+		info, _ = de.Info()
+		switch {
+		case info.IsDir():
+			err = syn.getDir(path.Join(remoteDir, name),
+				filepath.Join(localDir, name),
+				path.Join(relDir, name))
+		case info.Mode().IsRegular():
+			err = syn.getFile(path.Join(remoteDir, name),
+				filepath.Join(localDir, name),
+				path.Join(relDir, name), info)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getFile copy the remote file with information rfi into local file.
+func (syn *syncer) getFile(remoteFile, localFile, relFile string, rfi fs.FileInfo) (err error) {
+	var (
+		offset int64
+		lfi    fs.FileInfo
+	)
+
+	lfi, err = os.Stat(localFile)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	} else {
+		if lfi.IsDir() {
+			return fmt.Errorf(`%s: is a directory`, localFile)
+		}
+		if !syn.force && isSameFile(lfi, rfi) {
+			return nil
+		}
+		if syn.opts.Resume && lfi.Size() < rfi.Size() {
+			offset = lfi.Size()
+		}
+	}
+
+	var fin *FileHandle
+
+	fin, err = syn.cl.Open(remoteFile)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, remoteFile, err)
+	}
+
+	var flag = os.O_WRONLY | os.O_CREATE
+	if offset == 0 {
+		flag |= os.O_TRUNC
+	}
+
+	var fout *os.File
+
+	fout, err = os.OpenFile(localFile, flag, rfi.Mode().Perm())
+	if err != nil {
+		_ = syn.cl.CloseFile(fin)
+		return err
+	}
+
+	err = syn.copy(fout, fin, offset, relFile, rfi.Size())
+	if err != nil {
+		_ = fout.Close()
+		_ = syn.cl.CloseFile(fin)
+		return fmt.Errorf(`%s: %w`, remoteFile, err)
+	}
+
+	err = fout.Close()
+	if err != nil {
+		_ = syn.cl.CloseFile(fin)
+		return err
+	}
+	err = syn.cl.CloseFile(fin)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, remoteFile, err)
+	}
+
+	var mtime = rfi.ModTime()
+
+	err = os.Chtimes(localFile, mtime, mtime)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// putDir copy the local directory into remote directory.
+func (syn *syncer) putDir(localDir, remoteDir, relDir string) (err error) {
+	var fi fs.FileInfo
+
+	fi, err = os.Stat(localDir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf(`%s: not a directory`, localDir)
+	}
+
+	var fa *FileAttrs
+
+	fa, err = syn.cl.Stat(remoteDir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf(`%s: %w`, remoteDir, err)
+		}
+		fa = newFileAttrs()
+		fa.SetPermissions(uint32(fi.Mode().Perm() | 0700))
+
+		err = syn.cl.Mkdir(remoteDir, fa)
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, remoteDir, err)
+		}
+	} else if !fa.IsDir() {
+		return fmt.Errorf(`%s: not a directory`, remoteDir)
+	}
+
+	var list []fs.DirEntry
+
+	list, err = os.ReadDir(localDir)
+	if err != nil {
+		return err
+	}
+
+	var (
+		de   fs.DirEntry
+		name string
+	)
+	for _, de = range list {
+		name = de.Name()
+		switch {
+		case de.IsDir():
+			err = syn.putDir(filepath.Join(localDir, name),
+				path.Join(remoteDir, name),
+				path.Join(relDir, name))
+		case de.Type().IsRegular():
+			err = syn.putFile(filepath.Join(localDir, name),
+				path.Join(remoteDir, name),
+				path.Join(relDir, name))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putFile copy the local file into remote file.
+func (syn *syncer) putFile(localFile, remoteFile, relFile string) (err error) {
+	var fin *os.File
+
+	fin, err = os.Open(localFile)
+	if err != nil {
+		return err
+	}
+	defer fin.Close()
+
+	var lfi fs.FileInfo
+
+	lfi, err = fin.Stat()
+	if err != nil {
+		return err
+	}
+
+	var (
+		offset int64
+		rfa    *FileAttrs
+	)
+
+	rfa, err = syn.cl.Stat(remoteFile)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf(`%s: %w`, remoteFile, err)
+		}
+	} else {
+		if rfa.IsDir() {
+			return fmt.Errorf(`%s: is a directory`, remoteFile)
+		}
+		if !syn.force && isSameFile(lfi, rfa) {
+			return nil
+		}
+		if syn.opts.Resume && rfa.Size() < lfi.Size() {
+			offset = rfa.Size()
+		}
+	}
+
+	var fout *FileHandle
+
+	if offset > 0 {
+		fout, err = syn.cl.OpenFile(remoteFile, OpenFlagWrite, nil)
+	} else {
+		fout, err = syn.cl.Create(remoteFile, NewFileAttrs(lfi))
+	}
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, remoteFile, err)
+	}
+
+	err = syn.copy(fout, fin, offset, relFile, lfi.Size())
+	if err != nil {
+		_ = syn.cl.CloseFile(fout)
+		return fmt.Errorf(`%s: %w`, remoteFile, err)
+	}
+
+	err = syn.cl.CloseFile(fout)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, remoteFile, err)
+	}
+
+	var mtime = uint32(lfi.ModTime().Unix())
+
+	rfa = newFileAttrs()
+	rfa.SetAccessTime(mtime)
+	rfa.SetModifiedTime(mtime)
+
+	err = syn.cl.Setstat(remoteFile, rfa)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, remoteFile, err)
+	}
+	return nil
+}
+
+// copy the content of src into dst start from offset, and report the
+// progress.
+func (syn *syncer) copy(dst io.WriterAt, src io.ReaderAt, offset int64, relFile string, size int64) (err error) {
+	var progress func(n int64)
+
+	if syn.opts.Progress != nil {
+		progress = func(n int64) {
+			syn.opts.Progress(relFile, n, size)
+		}
+	}
+	return copyBuffer(dst, src, offset, size, syn.buf, progress)
+}
+
+// readdirAll return all entries in remote directory, sorted by name.
+func (cl *Client) readdirAll(dir string) (list []fs.DirEntry, err error) {
+	var fh *FileHandle
+
+	fh, err = cl.Opendir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []fs.DirEntry
+	for {
+		entries, err = cl.Readdir(fh)
+		if err != nil {
+			break
+		}
+		list = append(list, entries...)
+	}
+	if !errors.Is(err, io.EOF) {
+		_ = cl.CloseFile(fh)
+		return nil, err
+	}
+
+	err = cl.CloseFile(fh)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(list, func(x, y int) bool {
+		return list[x].Name() < list[y].Name()
+	})
+	return list, nil
+}
+
+// copyAt copy the content of src with size into dst start from offset.
+func copyAt(dst io.WriterAt, src io.ReaderAt, offset, size int64) (err error) {
+	var buf = make([]byte, maxRequests*int(maxPacketRead))
+	return copyBuffer(dst, src, offset, size, buf, nil)
+}
+
+// copyBuffer copy the content of src into dst start from offset, using
+// buf as temporary storage.
+// The size is the expected size of src, its used to limit the number of
+// read requests, but the content is copied until end-of-file.
+// The progress, if its not nil, is called after each block has been
+// written, with the offset of next block.
+func copyBuffer(dst io.WriterAt, src io.ReaderAt, offset, size int64, buf []byte, progress func(n int64)) (err error) {
+	var (
+		block []byte
+		n     int
+	)
+	for {
+		block = buf
+		switch {
+		case offset >= size:
+			// Probably end-of-file, read one packet only.
+			block = buf[:maxPacketRead]
+		case size-offset < int64(len(buf)):
+			block = buf[:size-offset]
+		}
+
+		n, err = src.ReadAt(block, offset)
+		if n > 0 {
+			var errw error
+
+			_, errw = dst.WriteAt(block[:n], offset)
+			if errw != nil {
+				return errw
+			}
+			offset += int64(n)
+			if progress != nil {
+				progress(offset)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// isSameFile return true if both files have the same size and
+// modification time, in seconds.
+func isSameFile(a, b fs.FileInfo) bool {
+	if a.Size() != b.Size() {
+		return false
+	}
+	return a.ModTime().Unix() == b.ModTime().Unix()
+}
@@ -0,0 +1,229 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+// newTestDiskServer create SFTP server on temporary directory and return
+// the client and the directory.
+func newTestDiskServer(t *testing.T) (cl *Client, root string) {
+	var (
+		diskfs *DiskFS
+		err    error
+	)
+
+	root = t.TempDir()
+
+	diskfs, err = NewDiskFS(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cl = newTestServer(t, ServerOptions{FS: diskfs}, `alice`)
+
+	return cl, root
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	var (
+		name    string
+		content string
+		err     error
+	)
+	for name, content = range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+
+		err = os.MkdirAll(filepath.Dir(name), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(name, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTestFiles(t *testing.T, dir string) (files map[string]string) {
+	files = map[string]string{}
+
+	var err = filepath.Walk(dir, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		var content []byte
+
+		content, err = os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		name, _ = filepath.Rel(dir, name)
+		files[filepath.ToSlash(name)] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestClient_PutDir_GetDir(t *testing.T) {
+	var (
+		cl, root = newTestDiskServer(t)
+		localDir = t.TempDir()
+		files    = map[string]string{
+			`a.txt`:       `a`,
+			`b/c.txt`:     `c`,
+			`b/d/e.txt`:   `e`,
+			`b/d/empty`:   ``,
+			`f/g/h/i.txt`: `i`,
+		}
+		err error
+	)
+
+	writeTestFiles(t, localDir, files)
+
+	err = cl.PutDir(localDir, `/put`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `PutDir`, files, readTestFiles(t, filepath.Join(root, `put`)))
+
+	var getDir = filepath.Join(t.TempDir(), `get`)
+
+	err = cl.GetDir(`/put`, getDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `GetDir`, files, readTestFiles(t, getDir))
+
+	var notDir = filepath.Join(localDir, `a.txt`)
+
+	err = cl.PutDir(notDir, `/put`)
+	test.Assert(t, `PutDir: not a directory`,
+		`PutDir: `+notDir+`: not a directory`, err.Error())
+}
+
+func TestClient_Sync(t *testing.T) {
+	var (
+		cl, root = newTestDiskServer(t)
+		localDir = t.TempDir()
+		files    = map[string]string{
+			`a.txt`:   `aaaa`,
+			`b/c.txt`: `cccc`,
+		}
+
+		progress []string
+		opts     = SyncOptions{
+			Progress: func(name string, n, size int64) {
+				progress = append(progress, name)
+			},
+		}
+		err error
+	)
+
+	writeTestFiles(t, localDir, files)
+
+	err = cl.Sync(localDir, `/sync`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remoteDir = filepath.Join(root, `sync`)
+
+	test.Assert(t, `Sync upload`, files, readTestFiles(t, remoteDir))
+	sort.Strings(progress)
+	test.Assert(t, `Sync upload: progress`, []string{`a.txt`, `b/c.txt`}, progress)
+
+	// Sync again should not copy anything.
+	progress = nil
+	err = cl.Sync(localDir, `/sync`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Sync upload unchanged`, []string(nil), progress)
+
+	// Changing the modification time should copy the file.
+	var mtime = time.Now().Add(-time.Hour)
+
+	err = os.Chtimes(filepath.Join(localDir, `a.txt`), mtime, mtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cl.Sync(localDir, `/sync`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Sync upload changed`, []string{`a.txt`}, progress)
+
+	var fi os.FileInfo
+
+	fi, err = os.Stat(filepath.Join(remoteDir, `a.txt`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Sync upload: mtime`, mtime.Unix(), fi.ModTime().Unix())
+
+	// Resume the partial file.
+	err = os.WriteFile(filepath.Join(localDir, `b/c.txt`), []byte(`ccccdddd`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Make the remote file different, so we know only the remaining
+	// content is copied.
+	err = os.WriteFile(filepath.Join(remoteDir, `b/c.txt`), []byte(`xxxx`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int64
+
+	opts.Resume = true
+	opts.Progress = func(name string, n, size int64) {
+		got = append(got, n, size)
+	}
+	err = cl.Sync(localDir, `/sync`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Sync resume: progress`, []int64{8, 8}, got)
+
+	var content []byte
+
+	content, err = os.ReadFile(filepath.Join(remoteDir, `b/c.txt`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Sync resume`, `xxxxdddd`, string(content))
+
+	// Download.
+	var downDir = filepath.Join(t.TempDir(), `down`)
+
+	opts = SyncOptions{
+		Download: true,
+	}
+	err = cl.Sync(downDir, `/sync`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files[`b/c.txt`] = `xxxxdddd`
+	test.Assert(t, `Sync download`, files, readTestFiles(t, downDir))
+
+	fi, err = os.Stat(filepath.Join(downDir, `a.txt`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Sync download: mtime`, mtime.Unix(), fi.ModTime().Unix())
+}
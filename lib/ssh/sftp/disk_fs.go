@@ -0,0 +1,446 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DiskFS implement the [FS], [ChrootFS], and [SymlinkFS] using the
+// directory on local disk as the root.
+//
+// Any symbolic link that point to outside of the root directory cannot be
+// followed, the operation on it will return [fs.ErrPermission].
+//
+// The path is checked before the operation, so there is a window where
+// other process that can write inside the root directory, for example
+// other client on the same directory, replace one of the parent
+// directories with symbolic link that point to outside of the root
+// before the operation is executed.
+// If the root directory is shared by untrusted users, isolate each of
+// them using the operating system, for example by running the server on
+// chroot or container per user.
+type DiskFS struct {
+	// root is the absolute path of directory, with all symbolic links
+	// resolved.
+	root string
+}
+
+// NewDiskFS create new [FS] for the directory dir.
+func NewDiskFS(dir string) (diskfs *DiskFS, err error) {
+	var logp = `NewDiskFS`
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var fi fs.FileInfo
+
+	fi, err = os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf(`%s: %q is not a directory`, logp, dir)
+	}
+
+	diskfs = &DiskFS{
+		root: dir,
+	}
+	return diskfs, nil
+}
+
+// Chroot create new DiskFS with the directory dir as its root.
+// The symbolic links in dir are resolved, and it must be inside the
+// root directory.
+func (diskfs *DiskFS) Chroot(dir string) (fsys FS, err error) {
+	var (
+		logp = `chroot`
+
+		sysPath string
+	)
+
+	sysPath, err = diskfs.sysPath(dir, true)
+	if err != nil {
+		return nil, pathError(logp, dir, err)
+	}
+	fsys, err = NewDiskFS(sysPath)
+	if err != nil {
+		return nil, pathError(logp, dir, err)
+	}
+	return fsys, nil
+}
+
+// OpenFile open the file using [os.OpenFile].
+func (diskfs *DiskFS) OpenFile(name string, flag int, perm fs.FileMode) (f File, err error) {
+	var (
+		logp = `open`
+
+		sysPath string
+		osf     *os.File
+	)
+
+	sysPath, err = diskfs.sysPath(name, true)
+	if err != nil {
+		return nil, pathError(logp, name, err)
+	}
+	osf, err = os.OpenFile(sysPath, flag, perm)
+	if err != nil {
+		return nil, pathError(logp, name, err)
+	}
+	return osf, nil
+}
+
+// ReadDir return the list of files information inside the directory.
+// The information is not following the symbolic link.
+func (diskfs *DiskFS) ReadDir(name string) (list []fs.FileInfo, err error) {
+	var (
+		logp = `readdir`
+
+		sysPath string
+		des     []os.DirEntry
+		de      os.DirEntry
+		fi      fs.FileInfo
+	)
+
+	sysPath, err = diskfs.sysPath(name, true)
+	if err != nil {
+		return nil, pathError(logp, name, err)
+	}
+	des, err = os.ReadDir(sysPath)
+	if err != nil {
+		return nil, pathError(logp, name, err)
+	}
+	for _, de = range des {
+		fi, err = de.Info()
+		if err != nil {
+			// The file may have been removed.
+			continue
+		}
+		list = append(list, fi)
+	}
+	return list, nil
+}
+
+// Stat return the file information, following the symbolic link.
+func (diskfs *DiskFS) Stat(name string) (fi fs.FileInfo, err error) {
+	var sysPath string
+
+	sysPath, err = diskfs.sysPath(name, true)
+	if err != nil {
+		return nil, pathError(`stat`, name, err)
+	}
+	fi, err = os.Stat(sysPath)
+	if err != nil {
+		return nil, pathError(`stat`, name, err)
+	}
+	return fi, nil
+}
+
+// Lstat return the file information, without following the symbolic
+// link.
+func (diskfs *DiskFS) Lstat(name string) (fi fs.FileInfo, err error) {
+	var sysPath string
+
+	sysPath, err = diskfs.sysPath(name, false)
+	if err != nil {
+		return nil, pathError(`lstat`, name, err)
+	}
+	fi, err = os.Lstat(sysPath)
+	if err != nil {
+		return nil, pathError(`lstat`, name, err)
+	}
+	return fi, nil
+}
+
+// Mkdir create the directory.
+func (diskfs *DiskFS) Mkdir(name string, perm fs.FileMode) (err error) {
+	var sysPath string
+
+	sysPath, err = diskfs.sysPath(name, false)
+	if err == nil {
+		err = os.Mkdir(sysPath, perm)
+	}
+	if err != nil {
+		return pathError(`mkdir`, name, err)
+	}
+	return nil
+}
+
+// Remove the file, or symbolic link.
+func (diskfs *DiskFS) Remove(name string) (err error) {
+	var (
+		logp = `remove`
+
+		sysPath string
+		fi      fs.FileInfo
+	)
+
+	sysPath, err = diskfs.sysPath(name, false)
+	if err != nil {
+		return pathError(logp, name, err)
+	}
+	fi, err = os.Lstat(sysPath)
+	if err != nil {
+		return pathError(logp, name, err)
+	}
+	if fi.IsDir() {
+		return pathError(logp, name, errors.New(`is a directory`))
+	}
+	err = os.Remove(sysPath)
+	if err != nil {
+		return pathError(logp, name, err)
+	}
+	return nil
+}
+
+// Rmdir remove the empty directory.
+func (diskfs *DiskFS) Rmdir(name string) (err error) {
+	var (
+		logp = `rmdir`
+
+		sysPath string
+		fi      fs.FileInfo
+	)
+
+	sysPath, err = diskfs.sysPath(name, false)
+	if err != nil {
+		return pathError(logp, name, err)
+	}
+	if sysPath == diskfs.root {
+		return pathError(logp, name, fs.ErrPermission)
+	}
+	fi, err = os.Lstat(sysPath)
+	if err != nil {
+		return pathError(logp, name, err)
+	}
+	if !fi.IsDir() {
+		return pathError(logp, name, errors.New(`not a directory`))
+	}
+	err = os.Remove(sysPath)
+	if err != nil {
+		return pathError(logp, name, err)
+	}
+	return nil
+}
+
+// Rename the file or directory.
+func (diskfs *DiskFS) Rename(oldname, newname string) (err error) {
+	var (
+		logp = `rename`
+
+		oldSysPath string
+		newSysPath string
+	)
+
+	oldSysPath, err = diskfs.sysPath(oldname, false)
+	if err != nil {
+		return pathError(logp, oldname, err)
+	}
+	newSysPath, err = diskfs.sysPath(newname, false)
+	if err != nil {
+		return pathError(logp, newname, err)
+	}
+	if oldSysPath == diskfs.root || newSysPath == diskfs.root {
+		return pathError(logp, oldname, fs.ErrPermission)
+	}
+	// The SFTP v3 does not allow rename to replace existing file.
+	_, err = os.Lstat(newSysPath)
+	if err == nil {
+		return pathError(logp, newname, fs.ErrExist)
+	}
+	err = os.Rename(oldSysPath, newSysPath)
+	if err != nil {
+		return pathError(logp, oldname, err)
+	}
+	return nil
+}
+
+// Chmod change the permission of file.
+func (diskfs *DiskFS) Chmod(name string, mode fs.FileMode) (err error) {
+	var sysPath string
+
+	sysPath, err = diskfs.sysPath(name, true)
+	if err == nil {
+		err = os.Chmod(sysPath, mode)
+	}
+	if err != nil {
+		return pathError(`chmod`, name, err)
+	}
+	return nil
+}
+
+// Chtimes change the access and modification time of file.
+func (diskfs *DiskFS) Chtimes(name string, atime, mtime time.Time) (err error) {
+	var sysPath string
+
+	sysPath, err = diskfs.sysPath(name, true)
+	if err == nil {
+		err = os.Chtimes(sysPath, atime, mtime)
+	}
+	if err != nil {
+		return pathError(`chtimes`, name, err)
+	}
+	return nil
+}
+
+// Truncate change the size of file.
+func (diskfs *DiskFS) Truncate(name string, size int64) (err error) {
+	var sysPath string
+
+	sysPath, err = diskfs.sysPath(name, true)
+	if err == nil {
+		err = os.Truncate(sysPath, size)
+	}
+	if err != nil {
+		return pathError(`truncate`, name, err)
+	}
+	return nil
+}
+
+// Readlink return the target of symbolic link.
+// The absolute target inside the root directory is returned relative to
+// the root, for example "/dir/file".
+func (diskfs *DiskFS) Readlink(name string) (target string, err error) {
+	var (
+		logp = `readlink`
+
+		sysPath string
+	)
+
+	sysPath, err = diskfs.sysPath(name, false)
+	if err != nil {
+		return ``, pathError(logp, name, err)
+	}
+	target, err = os.Readlink(sysPath)
+	if err != nil {
+		return ``, pathError(logp, name, err)
+	}
+	if !filepath.IsAbs(target) {
+		return filepath.ToSlash(target), nil
+	}
+	if !diskfs.isInside(target) {
+		return ``, pathError(logp, name, fs.ErrPermission)
+	}
+	target = strings.TrimPrefix(target, diskfs.root)
+	return path.Join(`/`, filepath.ToSlash(target)), nil
+}
+
+// Symlink create the symbolic link with the name link that point to
+// target.
+// The absolute target is created relative to the root directory.
+// The relative target that resolved to outside of the root directory,
+// from the directory of link, is rejected with [fs.ErrPermission].
+func (diskfs *DiskFS) Symlink(target, link string) (err error) {
+	var (
+		logp = `symlink`
+
+		sysPath string
+	)
+
+	sysPath, err = diskfs.sysPath(link, false)
+	if err != nil {
+		return pathError(logp, link, err)
+	}
+	if path.IsAbs(target) {
+		target = filepath.Join(diskfs.root, filepath.FromSlash(path.Clean(target)))
+	} else {
+		target = filepath.FromSlash(target)
+		if !diskfs.isInside(filepath.Join(filepath.Dir(sysPath), target)) {
+			return pathError(logp, link, fs.ErrPermission)
+		}
+	}
+	err = os.Symlink(target, sysPath)
+	if err != nil {
+		return pathError(logp, link, err)
+	}
+	return nil
+}
+
+// sysPath return the path of name in the local disk.
+// The symbolic links on the parent directories are always resolved.
+// If follow is true, the symbolic link on the last element of name is
+// also resolved.
+// It will return [fs.ErrPermission] if the resolved path is outside of
+// the root directory.
+func (diskfs *DiskFS) sysPath(name string, follow bool) (sysPath string, err error) {
+	name = path.Clean(`/` + name)
+	if name == `/` {
+		return diskfs.root, nil
+	}
+
+	var (
+		dir  = filepath.Join(diskfs.root, filepath.FromSlash(path.Dir(name)))
+		base = path.Base(name)
+	)
+
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		return ``, err
+	}
+	if !diskfs.isInside(dir) {
+		return ``, fs.ErrPermission
+	}
+
+	sysPath = filepath.Join(dir, base)
+	if !follow {
+		return sysPath, nil
+	}
+
+	var realPath string
+
+	realPath, err = filepath.EvalSymlinks(sysPath)
+	if err == nil {
+		if !diskfs.isInside(realPath) {
+			return ``, fs.ErrPermission
+		}
+		return realPath, nil
+	}
+
+	var fi fs.FileInfo
+
+	fi, err = os.Lstat(sysPath)
+	if err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+		// Dangling symbolic link, which may point to outside of
+		// root when the file is created.
+		return ``, fs.ErrPermission
+	}
+	return sysPath, nil
+}
+
+// isInside return true if the sysPath is the root directory or inside it.
+func (diskfs *DiskFS) isInside(sysPath string) bool {
+	if sysPath == diskfs.root {
+		return true
+	}
+	return strings.HasPrefix(sysPath, diskfs.root+string(filepath.Separator))
+}
+
+// pathError return [fs.PathError] with the name in the FS, to prevent
+// leaking the path in local disk to client.
+func pathError(op, name string, err error) error {
+	var (
+		pe *fs.PathError
+		le *os.LinkError
+	)
+	if errors.As(err, &pe) {
+		err = pe.Err
+	} else if errors.As(err, &le) {
+		err = le.Err
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
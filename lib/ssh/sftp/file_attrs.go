@@ -68,6 +68,54 @@ func NewFileAttrs(fi fs.FileInfo) (fa *FileAttrs) {
 	return fa
 }
 
+// fileAttrsFromInfo create [FileAttrs] from [fs.FileInfo] for response
+// from server.
+// Unlike [NewFileAttrs], the permissions contains the file type and the
+// access time is set to modification time.
+func fileAttrsFromInfo(fi fs.FileInfo) (fa *FileAttrs) {
+	var (
+		mode  = fi.Mode()
+		mtime = uint32(fi.ModTime().Unix())
+		perm  = uint32(mode.Perm())
+	)
+
+	switch {
+	case mode.IsDir():
+		perm |= fileTypeDirectory
+	case mode&fs.ModeSymlink != 0:
+		perm |= fileTypeSymlink
+	case mode&fs.ModeNamedPipe != 0:
+		perm |= fileTypeFifo
+	case mode&fs.ModeSocket != 0:
+		perm |= fileTypeSocket
+	case mode&fs.ModeCharDevice != 0:
+		perm |= fileTypeCharDevice
+	case mode&fs.ModeDevice != 0:
+		perm |= fileTypeBlockDevice
+	default:
+		perm |= fileTypeRegular
+	}
+	if mode&fs.ModeSetuid != 0 {
+		perm |= fileModeSetuid
+	}
+	if mode&fs.ModeSetgid != 0 {
+		perm |= fileModeSetgid
+	}
+	if mode&fs.ModeSticky != 0 {
+		perm |= fileModeSticky
+	}
+
+	fa = &FileAttrs{
+		name: fi.Name(),
+	}
+	fa.SetSize(uint64(fi.Size()))
+	fa.SetPermissions(perm)
+	fa.SetAccessTime(mtime)
+	fa.SetModifiedTime(mtime)
+
+	return fa
+}
+
 func newFileAttrs() (fa *FileAttrs) {
 	return &FileAttrs{}
 }
@@ -4,8 +4,145 @@
 
 package sftp
 
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxRequests define the maximum number of concurrent requests sent by
+// one ReadAt or WriteAt.
+const maxRequests = 64
+
 // FileHandle define the container to store remote file.
+//
+// The FileHandle returned from [Client.OpenFile] implement the
+// [io.ReaderAt] and [io.WriterAt].
 type FileHandle struct {
+	cl *Client // The client that open the file.
+
 	remotePath string // The remote path.
 	v          []byte // The handle value returned from open().
 }
+
+// ReadAt read len(p) bytes from remote file at offset off.
+// The read is splitted into several Read requests that sent concurrently,
+// so the time to read large content is not multiplied by the latency of
+// each request.
+// It return [io.EOF] if the remote file has less than len(p) bytes at
+// offset off.
+func (fh *FileHandle) ReadAt(p []byte, off int64) (n int, err error) {
+	var logp = `ReadAt`
+
+	if fh.cl == nil {
+		return 0, fmt.Errorf(`%s: %w`, logp, ErrNoConnection)
+	}
+	if off < 0 {
+		return 0, fmt.Errorf(`%s: negative offset %d`, logp, off)
+	}
+
+	n, err = eachChunk(p, off, fh.readChunk)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return n, io.EOF
+		}
+		return n, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return n, nil
+}
+
+// WriteAt write len(p) bytes into remote file at offset off.
+// Like [FileHandle.ReadAt], the write is splitted into several Write
+// requests that sent concurrently.
+func (fh *FileHandle) WriteAt(p []byte, off int64) (n int, err error) {
+	var logp = `WriteAt`
+
+	if fh.cl == nil {
+		return 0, fmt.Errorf(`%s: %w`, logp, ErrNoConnection)
+	}
+	if off < 0 {
+		return 0, fmt.Errorf(`%s: negative offset %d`, logp, off)
+	}
+
+	n, err = eachChunk(p, off, fh.writeChunk)
+	if err != nil {
+		return n, fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return n, nil
+}
+
+// readChunk read the remote file at offset off until the chunk is full or
+// end-of-file.
+// The server may return less data than requested, so the remaining is
+// requested again.
+func (fh *FileHandle) readChunk(chunk []byte, off int64) (n int, err error) {
+	var data []byte
+
+	for n < len(chunk) {
+		data, err = fh.cl.readData(fh, uint64(off)+uint64(n), uint32(len(chunk)-n))
+		n += copy(chunk[n:], data)
+		if err != nil {
+			return n, err
+		}
+		if len(data) == 0 {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+func (fh *FileHandle) writeChunk(chunk []byte, off int64) (n int, err error) {
+	err = fh.cl.Write(fh, uint64(off), chunk)
+	if err != nil {
+		return 0, err
+	}
+	return len(chunk), nil
+}
+
+// eachChunk split the p into chunks of maxPacketRead bytes and call fn
+// for each of them concurrently, limited by maxRequests.
+// It return the number of bytes processed from the beginning of p until
+// the first chunk that is not fully processed, and its error.
+func eachChunk(p []byte, off int64, fn func(chunk []byte, off int64) (int, error)) (n int, err error) {
+	type result struct {
+		err error
+		n   int
+	}
+
+	var (
+		size    = int(maxPacketRead)
+		nchunk  = (len(p) + size - 1) / size
+		results = make([]result, nchunk)
+		sem     = make(chan struct{}, maxRequests)
+
+		wg    sync.WaitGroup
+		x     int
+		start int
+		end   int
+	)
+	for x = 0; x < nchunk; x++ {
+		start = x * size
+		end = start + size
+		if end > len(p) {
+			end = len(p)
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(x, start, end int) {
+			results[x].n, results[x].err = fn(p[start:end], off+int64(start))
+			<-sem
+			wg.Done()
+		}(x, start, end)
+	}
+	wg.Wait()
+
+	for x = range results {
+		n += results[x].n
+		if results[x].err != nil {
+			return n, results[x].err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuLhan/share/lib/test"
+)
+
+func TestFileHandle_ReadAtWriteAt(t *testing.T) {
+	var (
+		opts = ServerOptions{
+			FS: NewMemFS(nil),
+		}
+		cl = newTestServer(t, opts, `alice`)
+
+		// Content that require more than maxRequests of chunks.
+		content = bytes.Repeat([]byte(`0123456789abcdef`), 200_000)
+
+		fh  *FileHandle
+		n   int
+		err error
+	)
+
+	fh, err = cl.OpenFile(`/big`, OpenFlagRead|OpenFlagWrite|OpenFlagCreate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err = fh.WriteAt(content, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `WriteAt`, len(content), n)
+
+	var got = make([]byte, len(content))
+
+	n, err = fh.ReadAt(got, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `ReadAt: n`, len(content), n)
+	test.Assert(t, `ReadAt: content`, true, bytes.Equal(content, got))
+
+	got = make([]byte, 100)
+
+	n, err = fh.ReadAt(got, int64(len(content)-10))
+	test.Assert(t, `ReadAt EOF: n`, 10, n)
+	test.Assert(t, `ReadAt EOF: err`, io.EOF, err)
+	test.Assert(t, `ReadAt EOF: content`, string(content[len(content)-10:]), string(got[:n]))
+
+	err = cl.CloseFile(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Get and Put use the ReadAt and WriteAt.
+
+	var (
+		dir       = t.TempDir()
+		localFile = filepath.Join(dir, `big`)
+	)
+
+	err = cl.Get(`/big`, localFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = os.ReadFile(localFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Get`, true, bytes.Equal(content, got))
+
+	err = cl.Put(localFile, `/big.put`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fa *FileAttrs
+
+	fa, err = cl.Stat(`/big.put`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Put: Size`, int64(len(content)), fa.Size())
+}
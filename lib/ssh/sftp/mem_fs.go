@@ -0,0 +1,364 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shuLhan/share/lib/memfs"
+)
+
+// MemFS implement the [FS] using the [memfs.MemFS].
+//
+// The content of file opened for writing is kept in memory and written
+// into the MemFS when the file is closed.
+// Use [memfs.NewOverlay] to serve the MemFS without modifying the
+// original one.
+type MemFS struct {
+	mfs *memfs.MemFS
+}
+
+// NewMemFS create new [FS] for the MemFS mfs.
+// If mfs is nil, new empty MemFS will be created.
+func NewMemFS(mfs *memfs.MemFS) (memFS *MemFS) {
+	if mfs == nil {
+		mfs = &memfs.MemFS{}
+	}
+	// Make sure the root directory is exist.
+	_, _ = mfs.MkdirAll(`/`, 0700)
+
+	memFS = &MemFS{
+		mfs: mfs,
+	}
+	return memFS
+}
+
+// OpenFile open the file in MemFS.
+func (memFS *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (f File, err error) {
+	var (
+		logp = `open`
+
+		node *memfs.Node
+	)
+
+	node, err = memFS.get(logp, name)
+	if err != nil {
+		if flag&os.O_CREATE == 0 || !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		node, err = memFS.mfs.WriteFile(name, nil, perm)
+		if err != nil {
+			return nil, pathError(logp, name, err)
+		}
+	} else if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, pathError(logp, name, fs.ErrExist)
+	}
+	if node.IsDir() {
+		return nil, pathError(logp, name, errors.New(`is a directory`))
+	}
+
+	var mf = &memFile{
+		fsys:    memFS,
+		name:    name,
+		perm:    node.Mode().Perm(),
+		isWrite: flag&(os.O_WRONLY|os.O_RDWR) != 0,
+	}
+	if flag&os.O_TRUNC != 0 && mf.isWrite {
+		mf.isChanged = true
+	} else {
+		mf.content, err = memFS.mfs.ReadFile(strings.TrimPrefix(node.Path, `/`))
+		if err != nil {
+			return nil, pathError(logp, name, err)
+		}
+	}
+	return mf, nil
+}
+
+// ReadDir return the list of files information inside the directory.
+func (memFS *MemFS) ReadDir(name string) (list []fs.FileInfo, err error) {
+	var (
+		logp = `readdir`
+
+		node  *memfs.Node
+		child *memfs.Node
+	)
+
+	node, err = memFS.get(logp, name)
+	if err != nil {
+		return nil, err
+	}
+	if !node.IsDir() {
+		return nil, pathError(logp, name, errors.New(`not a directory`))
+	}
+	for _, child = range node.Childs {
+		list = append(list, child)
+	}
+	return list, nil
+}
+
+// Stat return the file information.
+func (memFS *MemFS) Stat(name string) (fi fs.FileInfo, err error) {
+	var node *memfs.Node
+
+	node, err = memFS.get(`stat`, name)
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// Lstat return the file information.
+// Since MemFS does not have symbolic link, its equal to Stat.
+func (memFS *MemFS) Lstat(name string) (fi fs.FileInfo, err error) {
+	return memFS.Stat(name)
+}
+
+// Mkdir create the directory.
+func (memFS *MemFS) Mkdir(name string, perm fs.FileMode) (err error) {
+	_, err = memFS.mfs.Mkdir(name, perm)
+	if err != nil {
+		return pathError(`mkdir`, name, err)
+	}
+	return nil
+}
+
+// Remove the file.
+func (memFS *MemFS) Remove(name string) (err error) {
+	var (
+		logp = `remove`
+
+		node *memfs.Node
+	)
+
+	node, err = memFS.get(logp, name)
+	if err != nil {
+		return err
+	}
+	if node.IsDir() {
+		return pathError(logp, name, errors.New(`is a directory`))
+	}
+	err = memFS.mfs.Remove(name)
+	if err != nil {
+		return pathError(logp, name, err)
+	}
+	return nil
+}
+
+// Rmdir remove the empty directory.
+func (memFS *MemFS) Rmdir(name string) (err error) {
+	var (
+		logp = `rmdir`
+
+		node *memfs.Node
+	)
+
+	node, err = memFS.get(logp, name)
+	if err != nil {
+		return err
+	}
+	if !node.IsDir() {
+		return pathError(logp, name, errors.New(`not a directory`))
+	}
+	err = memFS.mfs.Remove(name)
+	if err != nil {
+		return pathError(logp, name, err)
+	}
+	return nil
+}
+
+// Rename the file or directory.
+func (memFS *MemFS) Rename(oldname, newname string) (err error) {
+	var logp = `rename`
+
+	_, err = memFS.get(logp, newname)
+	if err == nil {
+		return pathError(logp, newname, fs.ErrExist)
+	}
+	err = memFS.mfs.Rename(oldname, newname)
+	if err != nil {
+		return pathError(logp, oldname, err)
+	}
+	return nil
+}
+
+// Chmod change the permission of file.
+func (memFS *MemFS) Chmod(name string, mode fs.FileMode) (err error) {
+	var node *memfs.Node
+
+	node, err = memFS.get(`chmod`, name)
+	if err != nil {
+		return err
+	}
+	node.SetMode(node.Mode()&^fs.ModePerm | mode.Perm())
+	return nil
+}
+
+// Chtimes change the modification time of file.
+// The access time is ignored.
+func (memFS *MemFS) Chtimes(name string, _, mtime time.Time) (err error) {
+	var node *memfs.Node
+
+	node, err = memFS.get(`chtimes`, name)
+	if err != nil {
+		return err
+	}
+	node.SetModTime(mtime)
+	return nil
+}
+
+// Truncate change the size of file.
+func (memFS *MemFS) Truncate(name string, size int64) (err error) {
+	var (
+		logp = `truncate`
+
+		node    *memfs.Node
+		content []byte
+	)
+
+	node, err = memFS.get(logp, name)
+	if err != nil {
+		return err
+	}
+	if node.IsDir() {
+		return pathError(logp, name, errors.New(`is a directory`))
+	}
+	content, err = memFS.mfs.ReadFile(strings.TrimPrefix(node.Path, `/`))
+	if err != nil {
+		return pathError(logp, name, err)
+	}
+	content = resize(content, size)
+
+	_, err = memFS.mfs.WriteFile(name, content, node.Mode().Perm())
+	if err != nil {
+		return pathError(logp, name, err)
+	}
+	return nil
+}
+
+// get the node in MemFS.
+func (memFS *MemFS) get(op, name string) (node *memfs.Node, err error) {
+	node, err = memFS.mfs.Get(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = fs.ErrNotExist
+		}
+		return nil, pathError(op, name, err)
+	}
+	return node, nil
+}
+
+// memFile implement the [File] for [MemFS].
+type memFile struct {
+	fsys *MemFS
+
+	name    string
+	content []byte
+
+	perm fs.FileMode
+
+	mtx sync.Mutex
+
+	isWrite   bool
+	isChanged bool
+}
+
+// ReadAt read the content at offset off.
+func (mf *memFile) ReadAt(p []byte, off int64) (n int, err error) {
+	mf.mtx.Lock()
+	defer mf.mtx.Unlock()
+
+	if off < 0 {
+		return 0, pathError(`read`, mf.name, fs.ErrInvalid)
+	}
+	if off >= int64(len(mf.content)) {
+		return 0, io.EOF
+	}
+	n = copy(p, mf.content[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt write the p into content at offset off.
+func (mf *memFile) WriteAt(p []byte, off int64) (n int, err error) {
+	mf.mtx.Lock()
+	defer mf.mtx.Unlock()
+
+	if !mf.isWrite {
+		return 0, pathError(`write`, mf.name, fs.ErrPermission)
+	}
+	if off < 0 {
+		return 0, pathError(`write`, mf.name, fs.ErrInvalid)
+	}
+
+	var end = off + int64(len(p))
+	if end > int64(len(mf.content)) {
+		mf.content = resize(mf.content, end)
+	}
+	n = copy(mf.content[off:], p)
+	mf.isChanged = true
+
+	return n, nil
+}
+
+// Close write the content into MemFS if its changed.
+func (mf *memFile) Close() (err error) {
+	mf.mtx.Lock()
+	defer mf.mtx.Unlock()
+
+	if !mf.isChanged {
+		return nil
+	}
+	_, err = mf.fsys.mfs.WriteFile(mf.name, mf.content, mf.perm)
+	if err != nil {
+		return pathError(`close`, mf.name, err)
+	}
+	mf.isChanged = false
+	return nil
+}
+
+// Stat return the file information, with the size of current content.
+func (mf *memFile) Stat() (fi fs.FileInfo, err error) {
+	fi, err = mf.fsys.Stat(mf.name)
+	if err != nil {
+		return nil, err
+	}
+
+	mf.mtx.Lock()
+	fi = &memFileInfo{
+		FileInfo: fi,
+		size:     int64(len(mf.content)),
+	}
+	mf.mtx.Unlock()
+
+	return fi, nil
+}
+
+// memFileInfo override the size of file information of opened file.
+type memFileInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (fi *memFileInfo) Size() int64 {
+	return fi.size
+}
+
+// resize return the content with new size, padded with zero if its grow.
+func resize(content []byte, size int64) []byte {
+	if size <= int64(len(content)) {
+		return content[:size]
+	}
+	var v = make([]byte, size)
+	copy(v, content)
+	return v
+}
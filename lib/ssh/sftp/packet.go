@@ -381,6 +381,81 @@ func (pac *packet) fxpWrite(fh *FileHandle, offset uint64, data []byte) []byte {
 	return sealPacket(buf.Bytes())
 }
 
+func (pac *packet) fxpAttrs(fa *FileAttrs) []byte {
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, binary.BigEndian, byte(packetKindFxpAttrs))
+	_ = binary.Write(&buf, binary.BigEndian, pac.requestID)
+	fa.pack(&buf)
+
+	return sealPacket(buf.Bytes())
+}
+
+func (pac *packet) fxpData(data []byte) []byte {
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, binary.BigEndian, byte(packetKindFxpData))
+	_ = binary.Write(&buf, binary.BigEndian, pac.requestID)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(data)))
+	_ = binary.Write(&buf, binary.BigEndian, data)
+
+	return sealPacket(buf.Bytes())
+}
+
+func (pac *packet) fxpHandle(handle string) []byte {
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, binary.BigEndian, byte(packetKindFxpHandle))
+	_ = binary.Write(&buf, binary.BigEndian, pac.requestID)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(handle)))
+	_ = binary.Write(&buf, binary.BigEndian, []byte(handle))
+
+	return sealPacket(buf.Bytes())
+}
+
+func (pac *packet) fxpName(nodes []*dirEntry) []byte {
+	var (
+		buf  bytes.Buffer
+		node *dirEntry
+	)
+
+	_ = binary.Write(&buf, binary.BigEndian, byte(packetKindFxpName))
+	_ = binary.Write(&buf, binary.BigEndian, pac.requestID)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(nodes)))
+	for _, node = range nodes {
+		_ = binary.Write(&buf, binary.BigEndian, uint32(len(node.fileName)))
+		_ = binary.Write(&buf, binary.BigEndian, []byte(node.fileName))
+		_ = binary.Write(&buf, binary.BigEndian, uint32(len(node.longName)))
+		_ = binary.Write(&buf, binary.BigEndian, []byte(node.longName))
+		node.attrs.pack(&buf)
+	}
+
+	return sealPacket(buf.Bytes())
+}
+
+func (pac *packet) fxpStatus(code uint32, message string) []byte {
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, binary.BigEndian, byte(packetKindFxpStatus))
+	_ = binary.Write(&buf, binary.BigEndian, pac.requestID)
+	_ = binary.Write(&buf, binary.BigEndian, code)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(message)))
+	_ = binary.Write(&buf, binary.BigEndian, []byte(message))
+	// Empty language tag.
+	_ = binary.Write(&buf, binary.BigEndian, uint32(0))
+
+	return sealPacket(buf.Bytes())
+}
+
+func (pac *packet) fxpVersion(version uint32) []byte {
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, binary.BigEndian, packetKindFxpVersion)
+	_ = binary.Write(&buf, binary.BigEndian, version)
+
+	return sealPacket(buf.Bytes())
+}
+
 func sealPacket(in []byte) (out []byte) {
 	lin := uint32(len(in))
 	out = make([]byte, lin+4)
@@ -0,0 +1,256 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// maxPacketSize define the maximum size of packet received by server or
+// client.
+const maxPacketSize uint32 = 256 * 1024
+
+// defMaxHandles define the default maximum number of files and
+// directories opened by one session.
+const defMaxHandles = 256
+
+// List of operation passed to the [ServerOptions] Permission.
+const (
+	// OpList for Opendir.
+	OpList = `list`
+
+	// OpRead for Open with read only flag and Readlink.
+	OpRead = `read`
+
+	// OpWrite for Open with write, append, or create flag, Setstat,
+	// and Fsetstat.
+	OpWrite = `write`
+
+	// OpMkdir for Mkdir.
+	OpMkdir = `mkdir`
+
+	// OpRemove for Remove.
+	OpRemove = `remove`
+
+	// OpRmdir for Rmdir.
+	OpRmdir = `rmdir`
+
+	// OpRename for Rename, called for both the old and new path.
+	OpRename = `rename`
+
+	// OpSymlink for Symlink, called with the link path.
+	OpSymlink = `symlink`
+)
+
+// ServerOptions define the options for [Server].
+type ServerOptions struct {
+	// FS define the backend file system to be served.
+	// This field is required.
+	FS FS
+
+	// Chroot return the directory in FS that become the root directory
+	// for the user on connection conn.
+	// The user cannot access any files outside of it.
+	// If the FS implement [ChrootFS], the symbolic link inside it
+	// cannot point to outside of it either.
+	// This field is optional, if its nil or return empty string, the
+	// root of FS is used.
+	Chroot func(conn ssh.ConnMetadata) string
+
+	// Permission is called before each operation op on path, where
+	// the path is relative to the chroot directory.
+	// If it return non-nil error, the operation is denied and the
+	// client receive permission denied status.
+	// This field is optional, if its nil all operations are allowed.
+	Permission func(conn ssh.ConnMetadata, op, path string) error
+
+	// MaxHandles define the maximum number of files and directories
+	// opened by one session at the same time, so one client cannot
+	// exhaust the file descriptors of server.
+	// This field is optional, default to 256.
+	MaxHandles int
+}
+
+// Server implement the SSH File Transfer Protocol version 3 that serve
+// the "sftp" subsystem on SSH channel.
+type Server struct {
+	opts ServerOptions
+}
+
+// NewServer create new SFTP server.
+func NewServer(opts ServerOptions) (srv *Server, err error) {
+	var logp = `NewServer`
+
+	if opts.FS == nil {
+		return nil, fmt.Errorf(`%s: empty FS`, logp)
+	}
+
+	if opts.MaxHandles <= 0 {
+		opts.MaxHandles = defMaxHandles
+	}
+
+	srv = &Server{
+		opts: opts,
+	}
+	return srv, nil
+}
+
+// ServeConn serve the "sftp" subsystem on each "session" channel on SSH
+// server connection conn.
+// The chans is the channels returned from [ssh.NewServerConn].
+// It will return once the chans closed and all sessions has been
+// finished.
+func (srv *Server) ServeConn(conn *ssh.ServerConn, chans <-chan ssh.NewChannel) {
+	var (
+		logp = `ServeConn`
+
+		wg         sync.WaitGroup
+		newChannel ssh.NewChannel
+		channel    ssh.Channel
+		requests   <-chan *ssh.Request
+		err        error
+	)
+	for newChannel = range chans {
+		if newChannel.ChannelType() != `session` {
+			_ = newChannel.Reject(ssh.UnknownChannelType, `unknown channel type`)
+			continue
+		}
+		channel, requests, err = newChannel.Accept()
+		if err != nil {
+			log.Printf(`%s: %s`, logp, err)
+			continue
+		}
+
+		wg.Add(1)
+		go func(channel ssh.Channel, requests <-chan *ssh.Request) {
+			srv.serveSession(conn, channel, requests)
+			wg.Done()
+		}(channel, requests)
+	}
+	wg.Wait()
+}
+
+// serveSession wait for "subsystem" request with name "sftp" on session
+// channel and then serve it.
+func (srv *Server) serveSession(conn *ssh.ServerConn, channel ssh.Channel, requests <-chan *ssh.Request) {
+	var (
+		logp = `ServeConn`
+
+		req     *ssh.Request
+		payload struct{ Name string }
+		err     error
+	)
+
+	defer channel.Close()
+
+	for req = range requests {
+		if req.Type != `subsystem` {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		err = ssh.Unmarshal(req.Payload, &payload)
+		if err != nil || payload.Name != subsystemNameSftp {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		_ = req.Reply(true, nil)
+
+		go ssh.DiscardRequests(requests)
+
+		err = srv.Serve(conn, channel)
+		if err != nil {
+			log.Printf(`%s: %s`, logp, err)
+		}
+
+		var exitStatus = struct{ Status uint32 }{}
+		if err != nil {
+			exitStatus.Status = 1
+		}
+		_, _ = channel.SendRequest(`exit-status`, false, ssh.Marshal(&exitStatus))
+		return
+	}
+}
+
+// Serve the SFTP protocol on channel for the user on connection conn.
+// The conn is passed to the Chroot and Permission options, and it may be
+// nil.
+//
+// It will return nil once the channel reach end-of-file, or an error if
+// the channel failed or the client send malformed packet.
+func (srv *Server) Serve(conn ssh.ConnMetadata, channel io.ReadWriter) (err error) {
+	var (
+		logp = `Serve`
+		sess = newServerSession(srv, conn, channel)
+	)
+
+	defer sess.closeAll()
+
+	err = sess.init()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+
+	var (
+		payload []byte
+		req     *request
+	)
+	for {
+		payload, err = sess.readPacket()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+
+		req, err = unpackRequest(payload)
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+
+		payload = sess.handle(req)
+
+		_, err = channel.Write(payload)
+		if err != nil {
+			return fmt.Errorf(`%s: %w`, logp, err)
+		}
+	}
+}
+
+// readPacketFrom read one packet, without the length, from r.
+func readPacketFrom(r io.Reader) (payload []byte, err error) {
+	var header [4]byte
+
+	_, err = io.ReadFull(r, header[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var size = binary.BigEndian.Uint32(header[:])
+	if size == 0 || size > maxPacketSize {
+		return nil, errBadMessage(fmt.Sprintf(`invalid packet size %d`, size))
+	}
+
+	payload = make([]byte, size)
+
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return payload, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"io"
+	"io/fs"
+	"time"
+)
+
+// FS define the backend file system for [Server].
+//
+// The name passed to each method is slash separated, absolute, and clean
+// path, for example "/dir/file.txt", where "/" is the root of file
+// system.
+// The error returned from each method should wrap [fs.ErrNotExist],
+// [fs.ErrExist], or [fs.ErrPermission] where applicable, so the server can
+// reply with the proper status code.
+type FS interface {
+	// OpenFile open the file with flag from [os.O_RDONLY],
+	// [os.O_WRONLY], [os.O_RDWR], [os.O_CREATE], [os.O_TRUNC], and
+	// [os.O_EXCL].
+	// The perm is used when the file is created.
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+
+	// ReadDir return the list of files information inside the
+	// directory.
+	ReadDir(name string) ([]fs.FileInfo, error)
+
+	// Stat return the file information, following the symbolic link.
+	Stat(name string) (fs.FileInfo, error)
+
+	// Lstat return the file information, without following the
+	// symbolic link.
+	Lstat(name string) (fs.FileInfo, error)
+
+	// Mkdir create the directory.
+	Mkdir(name string, perm fs.FileMode) error
+
+	// Remove the file.
+	// It should return an error if the name is directory.
+	Remove(name string) error
+
+	// Rmdir remove the empty directory.
+	Rmdir(name string) error
+
+	// Rename the file or directory.
+	Rename(oldname, newname string) error
+
+	// Chmod change the permission of file.
+	Chmod(name string, mode fs.FileMode) error
+
+	// Chtimes change the access and modification time of file.
+	Chtimes(name string, atime, mtime time.Time) error
+
+	// Truncate change the size of file.
+	Truncate(name string, size int64) error
+}
+
+// SymlinkFS is an optional interface for [FS] that support symbolic
+// link.
+// If the FS does not implement it, the server reply the Readlink and
+// Symlink requests with [ErrOpUnsupported].
+type SymlinkFS interface {
+	// Readlink return the target of symbolic link.
+	Readlink(name string) (string, error)
+
+	// Symlink create the symbolic link with the name link that point
+	// to target.
+	Symlink(target, link string) error
+}
+
+// ChrootFS is an optional interface for [FS] that can create new FS with
+// the directory dir as its root.
+// If the FS implement it, the [Server] use it for the chroot directory,
+// instead of joining the chroot directory with each path.
+// This is required if the FS support symbolic link, to prevent the link
+// from pointing to outside of chroot directory.
+type ChrootFS interface {
+	Chroot(dir string) (FS, error)
+}
+
+// File define the file opened from [FS].
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+
+	// Stat return the file information.
+	Stat() (fs.FileInfo, error)
+}
@@ -0,0 +1,191 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"encoding/binary"
+)
+
+// request contains the packet sent by client to server.
+type request struct {
+	fa *FileAttrs
+
+	// path contains the filename or path from Open, Opendir, Lstat,
+	// Stat, Setstat, Remove, Mkdir, Rmdir, Realpath, Readlink, the
+	// old path from Rename, or the target path from Symlink.
+	path string
+
+	// path2 contains the new path from Rename or the link path from
+	// Symlink.
+	path2 string
+
+	handle string
+
+	data []byte
+
+	offset uint64
+
+	// version from FxpInit.
+	version uint32
+
+	// pflags from FxpOpen.
+	pflags uint32
+
+	// length from FxpRead.
+	length uint32
+
+	id   uint32
+	kind byte
+}
+
+// decoder read the fields from packet payload with bounds checking.
+type decoder struct {
+	payload []byte
+	err     error
+}
+
+func (dec *decoder) uint32() (v uint32) {
+	if dec.err != nil {
+		return 0
+	}
+	if len(dec.payload) < 4 {
+		dec.err = errBadMessage(`packet too short`)
+		return 0
+	}
+	v = binary.BigEndian.Uint32(dec.payload)
+	dec.payload = dec.payload[4:]
+	return v
+}
+
+func (dec *decoder) uint64() (v uint64) {
+	if dec.err != nil {
+		return 0
+	}
+	if len(dec.payload) < 8 {
+		dec.err = errBadMessage(`packet too short`)
+		return 0
+	}
+	v = binary.BigEndian.Uint64(dec.payload)
+	dec.payload = dec.payload[8:]
+	return v
+}
+
+func (dec *decoder) bytes() (v []byte) {
+	var size = dec.uint32()
+	if dec.err != nil {
+		return nil
+	}
+	if uint32(len(dec.payload)) < size {
+		dec.err = errBadMessage(`packet too short`)
+		return nil
+	}
+	v = dec.payload[:size]
+	dec.payload = dec.payload[size:]
+	return v
+}
+
+func (dec *decoder) string() string {
+	return string(dec.bytes())
+}
+
+// fileAttrs decode the ATTRS, the same as unpackFileAttrs.
+func (dec *decoder) fileAttrs() (fa *FileAttrs) {
+	fa = &FileAttrs{}
+
+	fa.flags = dec.uint32()
+	if fa.flags&attrSize != 0 {
+		fa.size = dec.uint64()
+	}
+	if fa.flags&attrUIDGID != 0 {
+		fa.uid = dec.uint32()
+		fa.gid = dec.uint32()
+	}
+	if fa.flags&attrPermissions != 0 {
+		fa.permissions = dec.uint32()
+		fa.updateFsmode()
+	}
+	if fa.flags&attrAcModtime != 0 {
+		fa.atime = dec.uint32()
+		fa.mtime = dec.uint32()
+	}
+	if fa.flags&attrExtended != 0 {
+		var (
+			n    = dec.uint32()
+			name string
+			x    uint32
+		)
+		fa.exts = extensions{}
+		for ; x < n && dec.err == nil; x++ {
+			name = dec.string()
+			fa.exts[name] = dec.string()
+		}
+	}
+	return fa
+}
+
+// unpackRequest decode the packet payload, without the length, from
+// client.
+func unpackRequest(payload []byte) (req *request, err error) {
+	if len(payload) == 0 {
+		return nil, errBadMessage(`empty packet`)
+	}
+
+	var dec = decoder{
+		payload: payload[1:],
+	}
+
+	req = &request{
+		kind: payload[0],
+	}
+
+	if req.kind == packetKindFxpInit {
+		req.version = dec.uint32()
+		return req, dec.err
+	}
+
+	req.id = dec.uint32()
+
+	switch req.kind {
+	case packetKindFxpOpen:
+		req.path = dec.string()
+		req.pflags = dec.uint32()
+		req.fa = dec.fileAttrs()
+
+	case packetKindFxpClose, packetKindFxpFstat, packetKindFxpReaddir:
+		req.handle = dec.string()
+
+	case packetKindFxpRead:
+		req.handle = dec.string()
+		req.offset = dec.uint64()
+		req.length = dec.uint32()
+
+	case packetKindFxpWrite:
+		req.handle = dec.string()
+		req.offset = dec.uint64()
+		req.data = dec.bytes()
+
+	case packetKindFxpLstat, packetKindFxpStat, packetKindFxpOpendir,
+		packetKindFxpRemove, packetKindFxpRmdir,
+		packetKindFxpRealpath, packetKindFxpReadlink:
+		req.path = dec.string()
+
+	case packetKindFxpSetstat, packetKindFxpMkdir:
+		req.path = dec.string()
+		req.fa = dec.fileAttrs()
+
+	case packetKindFxpFsetstat:
+		req.handle = dec.string()
+		req.fa = dec.fileAttrs()
+
+	case packetKindFxpRename, packetKindFxpSymlink:
+		req.path = dec.string()
+		req.path2 = dec.string()
+	}
+
+	if dec.err != nil {
+		return req, dec.err
+	}
+	return req, nil
+}
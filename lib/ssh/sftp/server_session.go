@@ -0,0 +1,721 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// maxReaddir define the maximum number of entries returned by one
+// Readdir.
+const maxReaddir = 100
+
+// msgTooManyHandles is the status message when the session has opened
+// ServerOptions MaxHandles files and directories.
+const msgTooManyHandles = `too many open handles`
+
+// serverHandle contains the file or directory opened by client.
+type serverHandle struct {
+	file File
+
+	// name of file or directory in FS.
+	name string
+
+	// dir contains the entries of directory that has not been
+	// returned to client.
+	dir []fs.FileInfo
+
+	isDir    bool
+	isAppend bool
+}
+
+// serverSession contains the states for one SFTP session.
+type serverSession struct {
+	srv  *Server
+	conn ssh.ConnMetadata
+	rw   io.ReadWriter
+
+	// fsys is the FS for this session.
+	// If the FS implement ChrootFS, its the FS rooted at chroot
+	// directory.
+	fsys FS
+
+	handles map[string]*serverHandle
+
+	// root is the chroot directory in FS.
+	root string
+
+	// owner is the name of user, used in the long name of Readdir.
+	owner string
+
+	lastHandle uint64
+}
+
+func newServerSession(srv *Server, conn ssh.ConnMetadata, rw io.ReadWriter) (sess *serverSession) {
+	sess = &serverSession{
+		srv:     srv,
+		conn:    conn,
+		rw:      rw,
+		handles: make(map[string]*serverHandle),
+		fsys:    srv.opts.FS,
+		root:    `/`,
+		owner:   `-`,
+	}
+	if srv.opts.Chroot != nil {
+		var root = srv.opts.Chroot(conn)
+		if len(root) > 0 {
+			sess.root = path.Clean(`/` + root)
+		}
+	}
+	if conn != nil {
+		sess.owner = conn.User()
+	}
+	return sess
+}
+
+// init receive the FxpInit and reply with FxpVersion.
+func (sess *serverSession) init() (err error) {
+	var (
+		logp = `init`
+
+		payload []byte
+		req     *request
+		fi      fs.FileInfo
+	)
+
+	payload, err = sess.readPacket()
+	if err != nil {
+		return err
+	}
+	req, err = unpackRequest(payload)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	if req.kind != packetKindFxpInit {
+		return fmt.Errorf(`%s: %w`, logp, errUnexpectedResponse(packetKindFxpInit, req.kind))
+	}
+
+	fi, err = sess.fsys.Stat(sess.root)
+	if err != nil {
+		return fmt.Errorf(`%s: chroot: %w`, logp, err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf(`%s: chroot %q is not a directory`, logp, sess.root)
+	}
+
+	var chrootfs, ok = sess.fsys.(ChrootFS)
+	if ok && sess.root != `/` {
+		sess.fsys, err = chrootfs.Chroot(sess.root)
+		if err != nil {
+			return fmt.Errorf(`%s: chroot: %w`, logp, err)
+		}
+		sess.root = `/`
+	}
+
+	var res = &packet{}
+
+	_, err = sess.rw.Write(res.fxpVersion(defFxpVersion))
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, logp, err)
+	}
+	return nil
+}
+
+func (sess *serverSession) readPacket() (payload []byte, err error) {
+	return readPacketFrom(sess.rw)
+}
+
+// closeAll close all opened handles.
+func (sess *serverSession) closeAll() {
+	var h *serverHandle
+	for _, h = range sess.handles {
+		if h.file != nil {
+			_ = h.file.Close()
+		}
+	}
+	sess.handles = nil
+}
+
+// handle process the request and return the response packet.
+func (sess *serverSession) handle(req *request) []byte {
+	var res = &packet{
+		requestID: req.id,
+	}
+
+	switch req.kind {
+	case packetKindFxpOpen:
+		return sess.handleOpen(res, req)
+	case packetKindFxpClose:
+		return sess.handleClose(res, req)
+	case packetKindFxpRead:
+		return sess.handleRead(res, req)
+	case packetKindFxpWrite:
+		return sess.handleWrite(res, req)
+	case packetKindFxpLstat, packetKindFxpStat:
+		return sess.handleStat(res, req)
+	case packetKindFxpFstat:
+		return sess.handleFstat(res, req)
+	case packetKindFxpSetstat:
+		return sess.handleSetstat(res, req)
+	case packetKindFxpFsetstat:
+		return sess.handleFsetstat(res, req)
+	case packetKindFxpOpendir:
+		return sess.handleOpendir(res, req)
+	case packetKindFxpReaddir:
+		return sess.handleReaddir(res, req)
+	case packetKindFxpRemove:
+		return sess.handleRemove(res, req)
+	case packetKindFxpMkdir:
+		return sess.handleMkdir(res, req)
+	case packetKindFxpRmdir:
+		return sess.handleRmdir(res, req)
+	case packetKindFxpRealpath:
+		return sess.handleRealpath(res, req)
+	case packetKindFxpRename:
+		return sess.handleRename(res, req)
+	case packetKindFxpReadlink:
+		return sess.handleReadlink(res, req)
+	case packetKindFxpSymlink:
+		return sess.handleSymlink(res, req)
+	}
+	return res.fxpStatus(statusCodeOpUnsupported, `operation unsupported`)
+}
+
+func (sess *serverSession) handleOpen(res *packet, req *request) []byte {
+	var (
+		clientPath, name = sess.resolve(req.path)
+
+		flag int
+		op   = OpRead
+		perm = fs.FileMode(0644)
+	)
+
+	switch {
+	case req.pflags&OpenFlagRead != 0 && req.pflags&OpenFlagWrite != 0:
+		flag = os.O_RDWR
+	case req.pflags&OpenFlagWrite != 0:
+		flag = os.O_WRONLY
+	default:
+		flag = os.O_RDONLY
+	}
+	if req.pflags&OpenFlagCreate != 0 {
+		flag |= os.O_CREATE
+		if req.pflags&OpenFlagTruncate != 0 {
+			flag |= os.O_TRUNC
+		}
+		if req.pflags&OpenFlagExcl != 0 {
+			flag |= os.O_EXCL
+		}
+	}
+	if req.pflags&(OpenFlagWrite|OpenFlagAppend|OpenFlagCreate) != 0 {
+		op = OpWrite
+	}
+	if req.fa != nil && req.fa.flags&attrPermissions != 0 {
+		perm = fs.FileMode(req.fa.permissions).Perm()
+	}
+
+	var err = sess.permit(op, clientPath)
+	if err != nil {
+		return sess.status(res, err)
+	}
+	if sess.isHandlesFull() {
+		return res.fxpStatus(statusCodeFailure, msgTooManyHandles)
+	}
+
+	var f File
+
+	f, err = sess.fsys.OpenFile(name, flag, perm)
+	if err != nil {
+		return sess.status(res, err)
+	}
+
+	var h = &serverHandle{
+		file:     f,
+		name:     name,
+		isAppend: req.pflags&OpenFlagAppend != 0,
+	}
+	return res.fxpHandle(sess.addHandle(h))
+}
+
+func (sess *serverSession) handleClose(res *packet, req *request) []byte {
+	var h = sess.handles[req.handle]
+	if h == nil {
+		return res.fxpStatus(statusCodeFailure, `invalid handle`)
+	}
+	delete(sess.handles, req.handle)
+
+	if h.file != nil {
+		var err = h.file.Close()
+		if err != nil {
+			return sess.status(res, err)
+		}
+	}
+	return res.fxpStatus(statusCodeOK, ``)
+}
+
+func (sess *serverSession) handleRead(res *packet, req *request) []byte {
+	var h = sess.handles[req.handle]
+	if h == nil || h.file == nil {
+		return res.fxpStatus(statusCodeFailure, `invalid handle`)
+	}
+
+	var length = req.length
+	if length > maxPacketRead {
+		length = maxPacketRead
+	}
+
+	var (
+		data   = make([]byte, length)
+		n, err = h.file.ReadAt(data, int64(req.offset))
+	)
+	if n > 0 {
+		return res.fxpData(data[:n])
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	return sess.status(res, err)
+}
+
+func (sess *serverSession) handleWrite(res *packet, req *request) []byte {
+	var h = sess.handles[req.handle]
+	if h == nil || h.file == nil {
+		return res.fxpStatus(statusCodeFailure, `invalid handle`)
+	}
+
+	var (
+		offset = int64(req.offset)
+		err    error
+	)
+	if h.isAppend {
+		var fi fs.FileInfo
+
+		fi, err = h.file.Stat()
+		if err != nil {
+			return sess.status(res, err)
+		}
+		offset = fi.Size()
+	}
+
+	_, err = h.file.WriteAt(req.data, offset)
+	if err != nil {
+		return sess.status(res, err)
+	}
+	return res.fxpStatus(statusCodeOK, ``)
+}
+
+func (sess *serverSession) handleStat(res *packet, req *request) []byte {
+	var (
+		_, name = sess.resolve(req.path)
+
+		fi  fs.FileInfo
+		err error
+	)
+	if req.kind == packetKindFxpLstat {
+		fi, err = sess.fsys.Lstat(name)
+	} else {
+		fi, err = sess.fsys.Stat(name)
+	}
+	if err != nil {
+		return sess.status(res, err)
+	}
+	return res.fxpAttrs(fileAttrsFromInfo(fi))
+}
+
+func (sess *serverSession) handleFstat(res *packet, req *request) []byte {
+	var h = sess.handles[req.handle]
+	if h == nil {
+		return res.fxpStatus(statusCodeFailure, `invalid handle`)
+	}
+
+	var (
+		fi  fs.FileInfo
+		err error
+	)
+	if h.file != nil {
+		fi, err = h.file.Stat()
+	} else {
+		fi, err = sess.fsys.Stat(h.name)
+	}
+	if err != nil {
+		return sess.status(res, err)
+	}
+	return res.fxpAttrs(fileAttrsFromInfo(fi))
+}
+
+func (sess *serverSession) handleSetstat(res *packet, req *request) []byte {
+	var (
+		clientPath, name = sess.resolve(req.path)
+
+		err = sess.permit(OpWrite, clientPath)
+	)
+	if err != nil {
+		return sess.status(res, err)
+	}
+
+	err = sess.setstat(name, req.fa)
+	if err != nil {
+		return sess.status(res, err)
+	}
+	return res.fxpStatus(statusCodeOK, ``)
+}
+
+func (sess *serverSession) handleFsetstat(res *packet, req *request) []byte {
+	var h = sess.handles[req.handle]
+	if h == nil {
+		return res.fxpStatus(statusCodeFailure, `invalid handle`)
+	}
+
+	var err = sess.permit(OpWrite, sess.clientPath(h.name))
+	if err != nil {
+		return sess.status(res, err)
+	}
+
+	err = sess.setstat(h.name, req.fa)
+	if err != nil {
+		return sess.status(res, err)
+	}
+	return res.fxpStatus(statusCodeOK, ``)
+}
+
+// setstat apply the size, permissions, and times in attributes fa to
+// the file name.
+// The user and group ID is ignored.
+func (sess *serverSession) setstat(name string, fa *FileAttrs) (err error) {
+	var fsys = sess.fsys
+
+	if fa.flags&attrSize != 0 {
+		err = fsys.Truncate(name, int64(fa.size))
+		if err != nil {
+			return err
+		}
+	}
+	if fa.flags&attrPermissions != 0 {
+		err = fsys.Chmod(name, fs.FileMode(fa.permissions).Perm())
+		if err != nil {
+			return err
+		}
+	}
+	if fa.flags&attrAcModtime != 0 {
+		var (
+			atime = time.Unix(int64(fa.atime), 0)
+			mtime = time.Unix(int64(fa.mtime), 0)
+		)
+		err = fsys.Chtimes(name, atime, mtime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sess *serverSession) handleOpendir(res *packet, req *request) []byte {
+	var (
+		clientPath, name = sess.resolve(req.path)
+
+		err = sess.permit(OpList, clientPath)
+	)
+	if err != nil {
+		return sess.status(res, err)
+	}
+	if sess.isHandlesFull() {
+		return res.fxpStatus(statusCodeFailure, msgTooManyHandles)
+	}
+
+	var list []fs.FileInfo
+
+	list, err = sess.fsys.ReadDir(name)
+	if err != nil {
+		return sess.status(res, err)
+	}
+	sort.Slice(list, func(x, y int) bool {
+		return list[x].Name() < list[y].Name()
+	})
+
+	var h = &serverHandle{
+		name:  name,
+		dir:   list,
+		isDir: true,
+	}
+	return res.fxpHandle(sess.addHandle(h))
+}
+
+func (sess *serverSession) handleReaddir(res *packet, req *request) []byte {
+	var h = sess.handles[req.handle]
+	if h == nil || !h.isDir {
+		return res.fxpStatus(statusCodeFailure, `invalid handle`)
+	}
+	if len(h.dir) == 0 {
+		return res.fxpStatus(statusCodeEOF, `end of file`)
+	}
+
+	var n = len(h.dir)
+	if n > maxReaddir {
+		n = maxReaddir
+	}
+
+	var (
+		nodes = make([]*dirEntry, 0, n)
+		fi    fs.FileInfo
+	)
+	for _, fi = range h.dir[:n] {
+		nodes = append(nodes, &dirEntry{
+			fileName: fi.Name(),
+			longName: sess.longName(fi),
+			attrs:    fileAttrsFromInfo(fi),
+		})
+	}
+	h.dir = h.dir[n:]
+
+	return res.fxpName(nodes)
+}
+
+func (sess *serverSession) handleRemove(res *packet, req *request) []byte {
+	var (
+		clientPath, name = sess.resolve(req.path)
+
+		err = sess.permit(OpRemove, clientPath)
+	)
+	if err == nil {
+		err = sess.fsys.Remove(name)
+	}
+	if err != nil {
+		return sess.status(res, err)
+	}
+	return res.fxpStatus(statusCodeOK, ``)
+}
+
+func (sess *serverSession) handleMkdir(res *packet, req *request) []byte {
+	var (
+		clientPath, name = sess.resolve(req.path)
+
+		perm = fs.FileMode(0755)
+		err  = sess.permit(OpMkdir, clientPath)
+	)
+	if err != nil {
+		return sess.status(res, err)
+	}
+	if req.fa != nil && req.fa.flags&attrPermissions != 0 {
+		perm = fs.FileMode(req.fa.permissions).Perm()
+	}
+
+	err = sess.fsys.Mkdir(name, perm)
+	if err != nil {
+		return sess.status(res, err)
+	}
+	return res.fxpStatus(statusCodeOK, ``)
+}
+
+func (sess *serverSession) handleRmdir(res *packet, req *request) []byte {
+	var (
+		clientPath, name = sess.resolve(req.path)
+
+		err = sess.permit(OpRmdir, clientPath)
+	)
+	if err == nil {
+		if name == sess.root {
+			err = fs.ErrPermission
+		} else {
+			err = sess.fsys.Rmdir(name)
+		}
+	}
+	if err != nil {
+		return sess.status(res, err)
+	}
+	return res.fxpStatus(statusCodeOK, ``)
+}
+
+func (sess *serverSession) handleRealpath(res *packet, req *request) []byte {
+	var (
+		clientPath, _ = sess.resolve(req.path)
+
+		nodes = []*dirEntry{{
+			fileName: clientPath,
+			longName: clientPath,
+			attrs:    newFileAttrs(),
+		}}
+	)
+	return res.fxpName(nodes)
+}
+
+func (sess *serverSession) handleRename(res *packet, req *request) []byte {
+	var (
+		oldClientPath, oldName = sess.resolve(req.path)
+		newClientPath, newName = sess.resolve(req.path2)
+
+		err = sess.permit(OpRename, oldClientPath)
+	)
+	if err == nil {
+		err = sess.permit(OpRename, newClientPath)
+	}
+	if err == nil {
+		if oldName == sess.root || newName == sess.root {
+			err = fs.ErrPermission
+		} else {
+			err = sess.fsys.Rename(oldName, newName)
+		}
+	}
+	if err != nil {
+		return sess.status(res, err)
+	}
+	return res.fxpStatus(statusCodeOK, ``)
+}
+
+func (sess *serverSession) handleReadlink(res *packet, req *request) []byte {
+	var symfs, ok = sess.fsys.(SymlinkFS)
+	if !ok {
+		return res.fxpStatus(statusCodeOpUnsupported, `operation unsupported`)
+	}
+
+	var (
+		clientPath, name = sess.resolve(req.path)
+
+		err    = sess.permit(OpRead, clientPath)
+		target string
+	)
+	if err != nil {
+		return sess.status(res, err)
+	}
+
+	target, err = symfs.Readlink(name)
+	if err != nil {
+		return sess.status(res, err)
+	}
+	if path.IsAbs(target) {
+		target = sess.clientPath(target)
+	}
+
+	var nodes = []*dirEntry{{
+		fileName: target,
+		longName: target,
+		attrs:    newFileAttrs(),
+	}}
+	return res.fxpName(nodes)
+}
+
+func (sess *serverSession) handleSymlink(res *packet, req *request) []byte {
+	var symfs, ok = sess.fsys.(SymlinkFS)
+	if !ok {
+		return res.fxpStatus(statusCodeOpUnsupported, `operation unsupported`)
+	}
+
+	// The order of arguments follow the OpenSSH implementation, the
+	// target path first and then the link path.
+	var (
+		target              = req.path
+		linkClientPath, lnk = sess.resolve(req.path2)
+
+		err = sess.permit(OpSymlink, linkClientPath)
+	)
+	if err != nil {
+		return sess.status(res, err)
+	}
+	if path.IsAbs(target) {
+		_, target = sess.resolve(target)
+	}
+
+	err = symfs.Symlink(target, lnk)
+	if err != nil {
+		return sess.status(res, err)
+	}
+	return res.fxpStatus(statusCodeOK, ``)
+}
+
+// isHandlesFull return true if the number of opened handles has reached
+// the ServerOptions MaxHandles.
+func (sess *serverSession) isHandlesFull() bool {
+	return len(sess.handles) >= sess.srv.opts.MaxHandles
+}
+
+// addHandle store the handle h and return its ID.
+func (sess *serverSession) addHandle(h *serverHandle) (id string) {
+	sess.lastHandle++
+	id = strconv.FormatUint(sess.lastHandle, 10)
+	sess.handles[id] = h
+	return id
+}
+
+// resolve the path from client into absolute path relative to chroot,
+// and the path in FS.
+// The relative path is resolved from the chroot directory, and the ".."
+// cannot go above it.
+func (sess *serverSession) resolve(p string) (clientPath, name string) {
+	clientPath = path.Clean(`/` + p)
+	name = path.Join(sess.root, clientPath)
+	return clientPath, name
+}
+
+// clientPath return the path in FS relative to chroot directory.
+func (sess *serverSession) clientPath(name string) string {
+	if sess.root == `/` {
+		return name
+	}
+	name = strings.TrimPrefix(name, sess.root)
+	return path.Join(`/`, name)
+}
+
+// permit call the Permission hook for operation op on clientPath.
+func (sess *serverSession) permit(op, clientPath string) (err error) {
+	if sess.srv.opts.Permission == nil {
+		return nil
+	}
+	err = sess.srv.opts.Permission(sess.conn, op, clientPath)
+	if err != nil {
+		return fmt.Errorf(`%w: %s`, fs.ErrPermission, err)
+	}
+	return nil
+}
+
+// longName return the file information formatted like "ls -l" output.
+func (sess *serverSession) longName(fi fs.FileInfo) string {
+	var (
+		mtime  = fi.ModTime()
+		layout = `Jan _2 15:04`
+	)
+	if time.Since(mtime) > 180*24*time.Hour {
+		layout = `Jan _2  2006`
+	}
+	return fmt.Sprintf(`%s 1 %s %s %d %s %s`, fi.Mode(), sess.owner,
+		sess.owner, fi.Size(), mtime.Format(layout), fi.Name())
+}
+
+// status return the FxpStatus based on error.
+func (sess *serverSession) status(res *packet, err error) []byte {
+	var (
+		code = statusCodeFailure
+		msg  = err.Error()
+		pe   *fs.PathError
+	)
+	if errors.As(err, &pe) {
+		// Report only the underlying error, the path is already
+		// known by client.
+		msg = pe.Err.Error()
+	}
+
+	switch {
+	case errors.Is(err, io.EOF):
+		code = statusCodeEOF
+	case errors.Is(err, fs.ErrNotExist):
+		code = statusCodeNoSuchFile
+	case errors.Is(err, fs.ErrPermission):
+		code = statusCodePermissionDenied
+	case errors.Is(err, ErrBadMessage):
+		code = statusCodeBadMessage
+	case errors.Is(err, ErrOpUnsupported):
+		code = statusCodeOpUnsupported
+	}
+	return res.fxpStatus(code, msg)
+}
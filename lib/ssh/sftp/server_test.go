@@ -0,0 +1,473 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sftp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/shuLhan/share/lib/memfs"
+	"github.com/shuLhan/share/lib/test"
+)
+
+// newTestServer run the SFTP server with opts on random port and return
+// the connected client for user.
+func newTestServer(t *testing.T, opts ServerOptions, user string) (cl *Client) {
+	var (
+		srv *Server
+		err error
+	)
+
+	srv, err = NewServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var privKey ed25519.PrivateKey
+
+	_, privKey, err = ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hostKey ssh.Signer
+
+	hostKey, err = ssh.NewSignerFromKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var serverConfig = &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, _ []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	serverConfig.AddHostKey(hostKey)
+
+	var ln net.Listener
+
+	ln, err = net.Listen(`tcp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	go func() {
+		for {
+			var nconn, err = ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				var conn, chans, reqs, err = ssh.NewServerConn(nconn, serverConfig)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				srv.ServeConn(conn, chans)
+			}()
+		}
+	}()
+
+	var (
+		clientConfig = &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{ssh.Password(`secret`)},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(), //nolint:gosec
+		}
+		sshClient *ssh.Client
+	)
+
+	sshClient, err = ssh.Dial(`tcp`, ln.Addr().String(), clientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = sshClient.Close()
+	})
+
+	cl, err = NewClient(sshClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cl.Close()
+	})
+	return cl
+}
+
+func readdirNames(t *testing.T, cl *Client, dir string) (names []string) {
+	var (
+		fh  *FileHandle
+		err error
+	)
+
+	fh, err = cl.Opendir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var list []fs.DirEntry
+	for {
+		list, err = cl.Readdir(fh)
+		if err != nil {
+			break
+		}
+		var de fs.DirEntry
+		for _, de = range list {
+			names = append(names, de.Name())
+		}
+	}
+	if !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+
+	err = cl.CloseFile(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestServer_DiskFS(t *testing.T) {
+	var (
+		root    = t.TempDir()
+		userDir = filepath.Join(root, `alice`)
+		err     error
+	)
+
+	err = os.Mkdir(userDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Symbolic link that point to outside of chroot.
+	err = os.Symlink(root, filepath.Join(userDir, `escape`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var diskfs *DiskFS
+
+	diskfs, err = NewDiskFS(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		opts = ServerOptions{
+			FS: diskfs,
+			Chroot: func(conn ssh.ConnMetadata) string {
+				return conn.User()
+			},
+		}
+		cl = newTestServer(t, opts, `alice`)
+
+		localDir  = t.TempDir()
+		localFile = filepath.Join(localDir, `local.txt`)
+		content   = []byte(`Hello, SFTP server!`)
+	)
+
+	err = os.WriteFile(localFile, content, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cl.Mkdir(`dir`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cl.Put(localFile, `/dir/a.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []byte
+
+	got, err = os.ReadFile(filepath.Join(userDir, `dir`, `a.txt`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Put`, string(content), string(got))
+
+	var fa *FileAttrs
+
+	fa, err = cl.Stat(`dir/a.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Stat: Size`, int64(len(content)), fa.Size())
+	test.Assert(t, `Stat: IsDir`, false, fa.IsDir())
+
+	var getFile = filepath.Join(localDir, `get.txt`)
+
+	err = cl.Get(`/dir/a.txt`, getFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = os.ReadFile(getFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Get`, string(content), string(got))
+
+	err = cl.Rename(`/dir/a.txt`, `/dir/b.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Readdir`, []string{`b.txt`}, readdirNames(t, cl, `/dir`))
+	test.Assert(t, `Readdir root`, []string{`dir`, `escape`}, readdirNames(t, cl, `/`))
+
+	var de fs.DirEntry
+
+	de, err = cl.Realpath(`../../dir/./`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Realpath`, `/dir`, de.Name())
+
+	_, err = cl.Stat(`/../alice/dir`)
+	test.Assert(t, `Stat outside chroot`, fs.ErrNotExist, err)
+
+	_, err = cl.Stat(`/escape`)
+	test.Assert(t, `Stat symlink outside chroot`, fs.ErrPermission, err)
+
+	err = cl.Symlink(`/dir/b.txt`, `/link`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	de, err = cl.Readlink(`/link`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Readlink`, `/dir/b.txt`, de.Name())
+
+	err = cl.Symlink(`../..`, `/dir/outside`)
+	test.Assert(t, `Symlink relative outside chroot`, fs.ErrPermission, err)
+
+	err = cl.Remove(`/link`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cl.Remove(`/dir/b.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cl.Rmdir(`/dir`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(filepath.Join(userDir, `dir`))
+	test.Assert(t, `Rmdir`, true, os.IsNotExist(err))
+}
+
+func TestServer_MaxHandles(t *testing.T) {
+	var (
+		mfs  = &memfs.MemFS{}
+		opts = ServerOptions{
+			FS:         NewMemFS(mfs),
+			MaxHandles: 2,
+		}
+		err error
+	)
+
+	_, err = mfs.WriteFile(`/a.txt`, []byte(`a`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cl  = newTestServer(t, opts, `bob`)
+		fhs []*FileHandle
+		fh  *FileHandle
+	)
+
+	fh, err = cl.Open(`/a.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fhs = append(fhs, fh)
+
+	fh, err = cl.Opendir(`/`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fhs = append(fhs, fh)
+
+	_, err = cl.Open(`/a.txt`)
+	test.Assert(t, `Open on full`, `sftp: failure: too many open handles`, err.Error())
+
+	_, err = cl.Opendir(`/`)
+	test.Assert(t, `Opendir on full`, `sftp: failure: too many open handles`, err.Error())
+
+	err = cl.CloseFile(fhs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fh, err = cl.Open(`/a.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fhs[0] = fh
+
+	for _, fh = range fhs {
+		err = cl.CloseFile(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestServer_MemFS(t *testing.T) {
+	var (
+		mfs  = &memfs.MemFS{}
+		opts = ServerOptions{
+			FS: NewMemFS(mfs),
+			Permission: func(conn ssh.ConnMetadata, op, path string) error {
+				if conn.User() == `guest` && op != OpRead && op != OpList {
+					return errors.New(`read only`)
+				}
+				return nil
+			},
+		}
+		err error
+	)
+
+	_, err = mfs.WriteFile(`/readme.txt`, []byte(`read me`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cl = newTestServer(t, opts, `bob`)
+		fh *FileHandle
+	)
+
+	fh, err = cl.Create(`/upload.txt`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cl.Write(fh, 0, []byte(`hello`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cl.Write(fh, 5, []byte(` world`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fa *FileAttrs
+
+	fa, err = cl.Fstat(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Fstat: Size`, int64(11), fa.Size())
+
+	err = cl.CloseFile(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var node *memfs.Node
+
+	node, err = mfs.Get(`/upload.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `upload content`, `hello world`, string(node.Content))
+
+	fh, err = cl.OpenFile(`/upload.txt`, OpenFlagWrite|OpenFlagAppend, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cl.Write(fh, 0, []byte(`!`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cl.CloseFile(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fh, err = cl.Open(`/upload.txt`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data []byte
+
+	data, err = cl.Read(fh, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Assert(t, `Read append`, `hello world!`, string(data))
+
+	_, err = cl.Read(fh, uint64(len(data)))
+	test.Assert(t, `Read EOF`, io.EOF, err)
+
+	err = cl.CloseFile(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cl.Readlink(`/upload.txt`)
+	test.Assert(t, `Readlink unsupported`, ErrOpUnsupported, err)
+
+	var guest = newTestServer(t, opts, `guest`)
+
+	test.Assert(t, `guest Readdir`, []string{`readme.txt`, `upload.txt`},
+		readdirNames(t, guest, `/`))
+
+	_, err = guest.Create(`/guest.txt`, nil)
+	test.Assert(t, `guest Create`, fs.ErrPermission, err)
+
+	err = guest.Remove(`/readme.txt`)
+	test.Assert(t, `guest Remove`, fs.ErrPermission, err)
+
+	_, err = guest.Open(`/notexist`)
+	test.Assert(t, `guest Open not exist`, fs.ErrNotExist, err)
+}
+
+func TestUnpackRequest_malformed(t *testing.T) {
+	type testCase struct {
+		desc    string
+		payload []byte
+	}
+
+	var cases = []testCase{{
+		desc: `empty`,
+	}, {
+		desc:    `short ID`,
+		payload: []byte{packetKindFxpStat, 0, 0},
+	}, {
+		desc:    `short path`,
+		payload: []byte{packetKindFxpStat, 0, 0, 0, 1, 0, 0, 0, 10, 'a'},
+	}, {
+		desc:    `short attrs`,
+		payload: []byte{packetKindFxpMkdir, 0, 0, 0, 1, 0, 0, 0, 1, 'a', 0, 0, 0, 1},
+	}}
+
+	var (
+		c   testCase
+		err error
+	)
+	for _, c = range cases {
+		_, err = unpackRequest(c.payload)
+		test.Assert(t, c.desc, true, errors.Is(err, ErrBadMessage))
+	}
+}
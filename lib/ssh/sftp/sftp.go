@@ -8,6 +8,14 @@
 // The sftp package extend the golang.org/x/crypto/ssh package by
 // implementing "sftp" subsystem using the [ssh.Client] connection.
 //
+// The [Client] send each request without waiting for the previous
+// responses, so it can be used concurrently.
+// The content of file is read and written using several concurrent
+// requests, see [FileHandle.ReadAt] and [FileHandle.WriteAt], to reduce
+// the effect of network latency.
+// The directory can be copied recursively using [Client.GetDir],
+// [Client.PutDir], and [Client.Sync].
+//
 // For information, even if scp working normally on server, this package
 // functionalities will not working if the server disable or does not
 // support the "sftp" subsystem.
@@ -17,7 +25,21 @@
 //
 // should be un-commented on /etc/ssh/sshd_config if its exist.
 //
+// # Server
+//
+// The [Server] serve the "sftp" subsystem on SSH server connection created
+// by [ssh.NewServerConn], using [Server.ServeConn], or on any channel
+// using [Server.Serve].
+// The files are served from the backend [FS].
+// This package provide two backends: [DiskFS] that serve directory on
+// local disk, and [MemFS] that serve the [memfs.MemFS].
+//
+// Each user can be restricted to specific directory in FS using the
+// [ServerOptions] Chroot, and each operation can be allowed or denied using
+// the [ServerOptions] Permission.
+//
 // [draft-ietf-secsh-filexfer-02.txt]: https://datatracker.ietf.org/doc/html/draft-ietf-secsh-filexfer-02#page-15
+// [memfs.MemFS]: https://pkg.go.dev/github.com/shuLhan/share/lib/memfs#MemFS
 package sftp
 
 import (
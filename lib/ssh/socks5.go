@@ -0,0 +1,150 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssh
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// List of SOCKS5 values, as defined in RFC 1928.
+const (
+	socks5Version byte = 5
+
+	socks5MethodNoAuth       byte = 0
+	socks5MethodNoAcceptable byte = 0xFF
+
+	socks5CmdConnect byte = 1
+
+	socks5AtypIPv4   byte = 1
+	socks5AtypDomain byte = 3
+	socks5AtypIPv6   byte = 4
+
+	socks5ReplySuccess         byte = 0
+	socks5ReplyFailure         byte = 1
+	socks5ReplyCmdUnsupported  byte = 7
+	socks5ReplyAtypUnsupported byte = 8
+)
+
+var (
+	errSocks5Version = errors.New(`socks5: unsupported version`)
+	errSocks5Method  = errors.New(`socks5: no acceptable method`)
+)
+
+// socks5Handshake read the SOCKS5 method selection and CONNECT request
+// from conn, and return the destination address.
+// On unsupported request, the failure reply is written to conn.
+func socks5Handshake(conn io.ReadWriter) (addr string, err error) {
+	var header = make([]byte, 2)
+
+	_, err = io.ReadFull(conn, header)
+	if err != nil {
+		return ``, err
+	}
+	if header[0] != socks5Version {
+		return ``, errSocks5Version
+	}
+
+	var methods = make([]byte, header[1])
+
+	_, err = io.ReadFull(conn, methods)
+	if err != nil {
+		return ``, err
+	}
+
+	var (
+		method = socks5MethodNoAcceptable
+		m      byte
+	)
+	for _, m = range methods {
+		if m == socks5MethodNoAuth {
+			method = m
+			break
+		}
+	}
+	_, err = conn.Write([]byte{socks5Version, method})
+	if err != nil {
+		return ``, err
+	}
+	if method == socks5MethodNoAcceptable {
+		return ``, errSocks5Method
+	}
+
+	// Read the request: VER CMD RSV ATYP.
+	header = make([]byte, 4)
+
+	_, err = io.ReadFull(conn, header)
+	if err != nil {
+		return ``, err
+	}
+	if header[0] != socks5Version {
+		return ``, errSocks5Version
+	}
+	if header[1] != socks5CmdConnect {
+		_ = writeSocks5Reply(conn, socks5ReplyCmdUnsupported)
+		return ``, fmt.Errorf(`socks5: unsupported command %d`, header[1])
+	}
+
+	var host string
+
+	switch header[3] {
+	case socks5AtypIPv4:
+		var ip = make([]byte, net.IPv4len)
+		_, err = io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+
+	case socks5AtypIPv6:
+		var ip = make([]byte, net.IPv6len)
+		_, err = io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+
+	case socks5AtypDomain:
+		var size = make([]byte, 1)
+		_, err = io.ReadFull(conn, size)
+		if err != nil {
+			return ``, err
+		}
+		var domain = make([]byte, size[0])
+		_, err = io.ReadFull(conn, domain)
+		host = string(domain)
+
+	default:
+		_ = writeSocks5Reply(conn, socks5ReplyAtypUnsupported)
+		return ``, fmt.Errorf(`socks5: unsupported address type %d`, header[3])
+	}
+	if err != nil {
+		return ``, err
+	}
+
+	var port = make([]byte, 2)
+
+	_, err = io.ReadFull(conn, port)
+	if err != nil {
+		return ``, err
+	}
+
+	addr = net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+	return addr, nil
+}
+
+// socks5Reply write the reply for CONNECT request based on the error from
+// connecting to destination.
+func socks5Reply(w io.Writer, errConnect error) error {
+	if errConnect != nil {
+		return writeSocks5Reply(w, socks5ReplyFailure)
+	}
+	return writeSocks5Reply(w, socks5ReplySuccess)
+}
+
+// writeSocks5Reply write the reply with zero IPv4 bind address.
+func writeSocks5Reply(w io.Writer, rep byte) (err error) {
+	_, err = w.Write([]byte{socks5Version, rep, 0, socks5AtypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -4,4 +4,11 @@
 
 // Package ssh provide a wrapper for golang.org/x/crypto/ssh and a parser for
 // SSH client configuration specification ssh_config(5).
+//
+// The [Client] connect to the remote host using the Section from SSH
+// config, including through the jump hosts in ProxyJump or the command in
+// ProxyCommand.
+// Once connected, the Client can share its connection with other Client
+// using ControlMaster, forward the local SSH agent, and forward the local,
+// remote, or dynamic (SOCKS5) port using [Client.StartForwarding].
 package ssh
@@ -0,0 +1,425 @@
+// Copyright 2024, Shulhan <ms@kilabit.info>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// testClientKey is the private key used by Client to connect to
+// testServer.
+const testClientKey = `testdata/localhost/client.key`
+
+// testServer is the in-process SSH server for testing Client.
+//
+// It accept the client with testClientKey and support the following
+// requests,
+//
+//   - "exec" with command "hostname", that reply with the server name,
+//     and "agent-list", that reply with the comment of each key in the
+//     forwarded agent,
+//   - "direct-tcpip" channel, for LocalForward, DynamicForward, and
+//     ProxyJump, and
+//   - "tcpip-forward" and "cancel-tcpip-forward", for RemoteForward.
+type testServer struct {
+	t *testing.T
+
+	ln     net.Listener
+	config *ssh.ServerConfig
+
+	name string
+
+	// nconn is the number of SSH connections that has been accepted.
+	nconn atomic.Int64
+
+	// ndirect is the number of direct-tcpip channels that has been
+	// accepted.
+	ndirect atomic.Int64
+
+	wg sync.WaitGroup
+}
+
+// newTestServer create and run new testServer on random local port.
+func newTestServer(t *testing.T, name string) (srv *testServer) {
+	var (
+		rawKey []byte
+		err    error
+	)
+	rawKey, err = os.ReadFile(testClientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var clientKey ssh.Signer
+
+	clientKey, err = ssh.ParsePrivateKey(rawKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hostKey ed25519.PrivateKey
+
+	_, hostKey, err = ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hostSigner ssh.Signer
+
+	hostSigner, err = ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var authorizedKey = clientKey.PublicKey().Marshal()
+
+	srv = &testServer{
+		t:    t,
+		name: name,
+		config: &ssh.ServerConfig{
+			PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+				if bytes.Equal(key.Marshal(), authorizedKey) {
+					return nil, nil
+				}
+				return nil, fmt.Errorf(`unknown public key`)
+			},
+		},
+	}
+	srv.config.AddHostKey(hostSigner)
+
+	srv.ln, err = net.Listen(`tcp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.wg.Add(1)
+	go srv.serve()
+
+	t.Cleanup(srv.close)
+
+	return srv
+}
+
+// port return the port where the server listen.
+func (srv *testServer) port() string {
+	return strconv.Itoa(srv.ln.Addr().(*net.TCPAddr).Port)
+}
+
+func (srv *testServer) close() {
+	_ = srv.ln.Close()
+	srv.wg.Wait()
+}
+
+func (srv *testServer) serve() {
+	defer srv.wg.Done()
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	for {
+		conn, err = srv.ln.Accept()
+		if err != nil {
+			return
+		}
+		go srv.serveConn(conn)
+	}
+}
+
+func (srv *testServer) serveConn(conn net.Conn) {
+	var (
+		sconn *ssh.ServerConn
+		chans <-chan ssh.NewChannel
+		reqs  <-chan *ssh.Request
+		err   error
+	)
+	sconn, chans, reqs, err = ssh.NewServerConn(conn, srv.config)
+	if err != nil {
+		return
+	}
+	defer sconn.Close()
+
+	srv.nconn.Add(1)
+
+	go srv.handleGlobalRequests(sconn, reqs)
+
+	var newChannel ssh.NewChannel
+	for newChannel = range chans {
+		switch newChannel.ChannelType() {
+		case `session`:
+			go srv.handleSession(sconn, newChannel)
+		case `direct-tcpip`:
+			srv.ndirect.Add(1)
+			go handleDirectTCPIP(newChannel)
+		default:
+			_ = newChannel.Reject(ssh.UnknownChannelType, `unknown channel type`)
+		}
+	}
+}
+
+func (srv *testServer) handleGlobalRequests(sconn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	var (
+		listeners = map[string]net.Listener{}
+
+		req *ssh.Request
+		err error
+	)
+	defer func() {
+		var ln net.Listener
+		for _, ln = range listeners {
+			_ = ln.Close()
+		}
+	}()
+
+	for req = range reqs {
+		var payload struct {
+			Addr string
+			Port uint32
+		}
+		err = ssh.Unmarshal(req.Payload, &payload)
+		if err != nil {
+			_ = req.Reply(false, nil)
+			continue
+		}
+
+		switch req.Type {
+		case `tcpip-forward`:
+			var (
+				addr = net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port)))
+				ln   net.Listener
+			)
+			ln, err = net.Listen(`tcp`, addr)
+			if err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+
+			var port = uint32(ln.Addr().(*net.TCPAddr).Port)
+			listeners[ln.Addr().String()] = ln
+
+			_ = req.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+
+			go serveForwardedTCPIP(sconn, ln, payload.Addr, port)
+
+		case `cancel-tcpip-forward`:
+			var addr = net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port)))
+			var ln = listeners[addr]
+			if ln == nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = ln.Close()
+			delete(listeners, addr)
+			_ = req.Reply(true, nil)
+
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+// serveForwardedTCPIP forward each connection accepted on ln to the client
+// through "forwarded-tcpip" channel.
+func serveForwardedTCPIP(sconn *ssh.ServerConn, ln net.Listener, addr string, port uint32) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	for {
+		conn, err = ln.Accept()
+		if err != nil {
+			return
+		}
+
+		var (
+			origin  = conn.RemoteAddr().(*net.TCPAddr)
+			payload = struct {
+				Addr       string
+				Port       uint32
+				OriginAddr string
+				OriginPort uint32
+			}{addr, port, origin.IP.String(), uint32(origin.Port)}
+
+			channel ssh.Channel
+			reqs    <-chan *ssh.Request
+		)
+		channel, reqs, err = sconn.OpenChannel(`forwarded-tcpip`, ssh.Marshal(&payload))
+		if err != nil {
+			_ = conn.Close()
+			continue
+		}
+		go ssh.DiscardRequests(reqs)
+		go pipe(channel, conn)
+	}
+}
+
+func handleDirectTCPIP(newChannel ssh.NewChannel) {
+	var (
+		payload struct {
+			Host       string
+			Port       uint32
+			OriginAddr string
+			OriginPort uint32
+		}
+		err error
+	)
+	err = ssh.Unmarshal(newChannel.ExtraData(), &payload)
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	var (
+		addr = net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+		conn net.Conn
+	)
+	conn, err = net.Dial(`tcp`, addr)
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	var (
+		channel ssh.Channel
+		reqs    <-chan *ssh.Request
+	)
+	channel, reqs, err = newChannel.Accept()
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	pipe(channel, conn)
+}
+
+func (srv *testServer) handleSession(sconn *ssh.ServerConn, newChannel ssh.NewChannel) {
+	var (
+		channel ssh.Channel
+		reqs    <-chan *ssh.Request
+		err     error
+	)
+	channel, reqs, err = newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	var (
+		isAgentForwarded bool
+		req              *ssh.Request
+	)
+	for req = range reqs {
+		switch req.Type {
+		case `auth-agent-req@openssh.com`:
+			isAgentForwarded = true
+			_ = req.Reply(true, nil)
+
+		case `env`:
+			_ = req.Reply(true, nil)
+
+		case `exec`:
+			var payload struct{ Command string }
+
+			err = ssh.Unmarshal(req.Payload, &payload)
+			if err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+
+			var status uint32
+			switch payload.Command {
+			case `hostname`:
+				_, _ = io.WriteString(channel, srv.name)
+			case `agent-list`:
+				if !isAgentForwarded {
+					_, _ = io.WriteString(channel.Stderr(), `agent is not forwarded`)
+					status = 1
+					break
+				}
+				err = writeAgentList(sconn, channel)
+				if err != nil {
+					_, _ = io.WriteString(channel.Stderr(), err.Error())
+					status = 1
+				}
+			default:
+				_, _ = io.WriteString(channel.Stderr(), `unknown command`)
+				status = 127
+			}
+
+			_, _ = channel.SendRequest(`exit-status`, false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+// writeAgentList write the comment of each key in the agent forwarded by
+// client into w.
+func writeAgentList(sconn *ssh.ServerConn, w io.Writer) (err error) {
+	var (
+		channel ssh.Channel
+		reqs    <-chan *ssh.Request
+	)
+	channel, reqs, err = sconn.OpenChannel(`auth-agent@openssh.com`, nil)
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	go ssh.DiscardRequests(reqs)
+
+	var keys []*agent.Key
+
+	keys, err = agent.NewClient(channel).List()
+	if err != nil {
+		return err
+	}
+
+	var (
+		comments []string
+		key      *agent.Key
+	)
+	for _, key = range keys {
+		comments = append(comments, key.Comment)
+	}
+	_, err = io.WriteString(w, strings.Join(comments, `,`))
+	return err
+}
+
+// pipe copy data between a and b until one of them closed.
+func pipe(a, b io.ReadWriteCloser) {
+	var done = make(chan struct{}, 2)
+
+	go func() {
+		_, _ = io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(b, a)
+		done <- struct{}{}
+	}()
+
+	<-done
+	_ = a.Close()
+	_ = b.Close()
+	<-done
+}